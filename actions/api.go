@@ -0,0 +1,143 @@
+package actions
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tinabot"
+)
+
+// apiError is the body of the errors of the API
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// apiTokens returns the tokens accepted by the API, listed comma separated
+// in API_TOKENS
+func apiTokens() []string {
+	var tokens []string
+	for _, t := range strings.Split(os.Getenv("API_TOKENS"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+// validToken returns true if token is one of tokens
+func validToken(token string, tokens []string) bool {
+	ok := false
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			ok = true
+		}
+	}
+	return ok && token != ""
+}
+
+// requestToken returns the API token of the request, sent as
+// "Authorization: Bearer <token>" or, for the EventSource of the browsers
+// which can't set the headers, as the token parameter
+func requestToken(req *http.Request) string {
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return req.URL.Query().Get("token")
+}
+
+// apiAuth lets through only the requests with one of the API tokens, see
+// requestToken
+func apiAuth(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		if !validToken(requestToken(c.Request()), apiTokens()) {
+			return c.Render(http.StatusUnauthorized, r.JSON(apiError{"token non valido"}))
+		}
+		return next(c)
+	}
+}
+
+// officeStore returns the part of root of the office in the office
+// parameter, root itself if there's none
+func officeStore(c buffalo.Context, root brain.Store) (brain.Store, bool) {
+	office := c.Param("office")
+	if office == "" {
+		return root, true
+	}
+	return tinabot.OfficeStore(root, office)
+}
+
+// withOffice opens the brain and calls fn with the part of the office of
+// the request, see officeStore
+func withOffice(c buffalo.Context, fn func(root, b brain.Store) error) error {
+	root, err := openBrain(brain.URLFromEnv())
+	if err != nil {
+		log.Println(err)
+		return c.Render(http.StatusServiceUnavailable, r.JSON(apiError{"servizio non disponibile"}))
+	}
+	b, ok := officeStore(c, root)
+	if !ok {
+		return c.Render(http.StatusNotFound, r.JSON(apiError{"ufficio non trovato"}))
+	}
+	return fn(root, b)
+}
+
+// apiNow returns the time of the requests, in the time zone of the office
+func apiNow() time.Time {
+	if loc, err := time.LoadLocation("Europe/Rome"); err == nil {
+		return time.Now().In(loc)
+	}
+	return time.Now()
+}
+
+// APIMenuTodayHandler serves today's menu
+func APIMenuTodayHandler(c buffalo.Context) error {
+	return withOffice(c, func(root, b brain.Store) error {
+		menu, err := tinabot.APIMenuToday(b, apiNow())
+		if err != nil {
+			return c.Render(http.StatusNotFound, r.JSON(apiError{err.Error()}))
+		}
+		return c.Render(http.StatusOK, r.JSON(menu))
+	})
+}
+
+// APIOrderHandler serves today's order
+func APIOrderHandler(c buffalo.Context) error {
+	return withOffice(c, func(root, b brain.Store) error {
+		return c.Render(http.StatusOK, r.JSON(tinabot.APIOrderToday(b)))
+	})
+}
+
+// apiOrderRequest is the body of the orders placed through the API: the
+// name of the user and the rows of the dishes of today's menu
+type apiOrderRequest struct {
+	Name string `json:"name"`
+	Rows []int  `json:"rows"`
+}
+
+// APIPlaceOrderHandler replaces the order of the user in the path, by ID,
+// with the dishes of the body, see apiOrderRequest
+func APIPlaceOrderHandler(c buffalo.Context) error {
+	var req apiOrderRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
+		return c.Render(http.StatusBadRequest, r.JSON(apiError{"richiesta non valida: " + err.Error()}))
+	}
+	id := c.Param("user")
+	if req.Name == "" {
+		req.Name = id
+	}
+	return withOffice(c, func(root, b brain.Store) error {
+		u, err := tinabot.PlaceAPIOrder(b, tinabot.User{Name: req.Name, ID: id}, req.Rows, apiNow())
+		if err != nil {
+			return c.Render(http.StatusConflict, r.JSON(apiError{err.Error()}))
+		}
+		return c.Render(http.StatusOK, r.JSON(u))
+	})
+}
@@ -0,0 +1,25 @@
+package actions
+
+import "strings"
+
+func (as *ActionSuite) Test_API_Unauthorized() {
+	res := as.JSON("/api/v1/order").Get()
+	as.Equal(401, res.Code)
+}
+
+func (as *ActionSuite) Test_ValidToken() {
+	as.True(validToken("t2", []string{"t1", "t2"}))
+	as.False(validToken("t3", []string{"t1", "t2"}))
+	as.False(validToken("", nil))
+}
+
+func (as *ActionSuite) Test_API_StreamUnauthorized() {
+	res := as.HTML("/api/v1/order/stream?token=nope").Get()
+	as.Equal(401, res.Code)
+}
+
+func (as *ActionSuite) Test_WriteEvent() {
+	var b strings.Builder
+	as.NoError(writeEvent(&b, "order", map[string]int{"n": 1}))
+	as.Equal("event: order\ndata: {\"n\":1}\n\n", b.String())
+}
@@ -1,6 +1,8 @@
 package actions
 
 import (
+	"log"
+
 	"github.com/gobuffalo/buffalo"
 	"github.com/gobuffalo/envy"
 	forcessl "github.com/gobuffalo/mw-forcessl"
@@ -8,6 +10,10 @@ import (
 	"github.com/unrolled/secure"
 
 	"github.com/develersrl/lunches/models"
+	"github.com/develersrl/lunches/pkg/logging"
+	"github.com/develersrl/lunches/pkg/sentry"
+	"github.com/develersrl/lunches/pkg/staging"
+	"github.com/develersrl/lunches/pkg/tracing"
 	"github.com/gobuffalo/buffalo-pop/pop/popmw"
 	i18n "github.com/gobuffalo/mw-i18n"
 	"github.com/gobuffalo/packr"
@@ -34,6 +40,19 @@ var T *i18n.Translator
 // declared after it to never be called.
 func App() *buffalo.App {
 	if app == nil {
+		// the logs of the bot as JSON lines, see LOG_FORMAT and LOG_LEVEL
+		logging.Setup()
+		// the rehearsals in staging, see STAGING
+		staging.Setup()
+		// the spans of the events, see OTEL_EXPORTER_OTLP_ENDPOINT
+		tracing.Setup()
+		// the configuration shared by the offices, see CONFIG_FILE
+		setupConfig()
+		// the panics and the failures, see SENTRY_DSN
+		if err := sentry.Setup(); err != nil {
+			log.Println("Error setting up Sentry:", err)
+		}
+
 		app = buffalo.New(buffalo.Options{
 			Env:         ENV,
 			SessionName: "_lunches_session",
@@ -42,6 +61,12 @@ func App() *buffalo.App {
 		// Automatically redirect to SSL
 		app.Use(forceSSL())
 
+		// Refuse the changes once the shutdown began, see Shutdown
+		app.Use(drainRequests)
+
+		// Report the panics of the handlers to Sentry
+		app.Use(reportPanics)
+
 		// Log request parameters (filters apply).
 		app.Use(paramlogger.ParameterLogger)
 
@@ -60,7 +85,25 @@ func App() *buffalo.App {
 		app.GET("/", HomeHandler)
 
 		app.POST("/slack/handler", SlackHandler)
+		app.POST("/slack/command", SlashCommandHandler)
+		app.POST("/slack/interactive", InteractionHandler)
+		app.GET("/slack/install", SlackInstallHandler)
+		app.GET("/slack/oauth", SlackOAuthHandler)
 		app.POST("/email/handler", EmailHandler)
+		app.POST("/teams/messages", TeamsHandler)
+		app.GET("/guest/{token}", GuestHandler)
+		app.POST("/guest/{token}", GuestOrderHandler)
+		app.GET("/calendar.ics", CalendarFeedHandler)
+		app.GET("/display", DisplayHandler)
+		app.GET("/metrics", MetricsHandler)
+		app.GET("/healthz", HealthHandler)
+		app.GET("/readyz", ReadyHandler)
+
+		app.GET("/api/openapi.json", OpenAPIHandler)
+		api := app.Group(apiPrefix)
+		api.Use(apiAuth)
+		addAPIRoutes(api)
+
 		app.ServeFiles("/", assetsBox) // serve files from the public directory
 	}
 
@@ -0,0 +1,43 @@
+package actions
+
+import (
+	"sync"
+
+	"github.com/develersrl/lunches/pkg/brain"
+)
+
+// brains are the stores opened by openBrain, by URL, shared by the handlers
+// so that the cache and the subscriptions of the invalidations are made once
+// per process rather than once per request
+var (
+	brainsMu sync.Mutex
+	brains   = map[string]brain.Store{}
+)
+
+// openBrain returns the store of uri, opening it the first time, see
+// brain.Open. The store is shared, the callers must not close it; the
+// failures are not remembered, so the next call tries again.
+func openBrain(uri string) (brain.Store, error) {
+	brainsMu.Lock()
+	defer brainsMu.Unlock()
+	if b, ok := brains[uri]; ok {
+		return b, nil
+	}
+	b, err := brain.Open(uri)
+	if err != nil {
+		return nil, err
+	}
+	brains[uri] = b
+	return b, nil
+}
+
+// closeBrains closes the stores opened by openBrain, once the handlers are
+// done with them
+func closeBrains() {
+	brainsMu.Lock()
+	defer brainsMu.Unlock()
+	for uri, b := range brains {
+		b.Close()
+		delete(brains, uri)
+	}
+}
@@ -0,0 +1,18 @@
+package actions
+
+func (as *ActionSuite) Test_OpenBrain() {
+	defer closeBrains()
+	b, err := openBrain("memory://")
+	as.NoError(err)
+	as.NoError(b.Set("k", "v"))
+
+	// the handlers share the same store
+	again, err := openBrain("memory://")
+	as.NoError(err)
+	var v string
+	as.NoError(again.Get("k", &v))
+	as.Equal("v", v)
+
+	_, err = openBrain("ftp://host")
+	as.Error(err)
+}
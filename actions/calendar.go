@@ -0,0 +1,41 @@
+package actions
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/buffalo/render"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tinabot"
+)
+
+// CalendarFeedHandler serves the iCal feed of the order deadlines and of the
+// deliveries, of the office in the office parameter if any, to subscribe to
+// from the calendar apps. The address is shown by "calendario link".
+func CalendarFeedHandler(c buffalo.Context) error {
+	root, err := openBrain(brain.URLFromEnv())
+	if err != nil {
+		log.Println(err)
+		return c.Render(http.StatusServiceUnavailable, r.String(""))
+	}
+
+	b, ok := officeStore(c, root)
+	if !ok {
+		return c.Render(http.StatusNotFound, r.String(""))
+	}
+
+	loc, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		log.Println("LoadLocation error: ", err)
+		return c.Render(http.StatusInternalServerError, r.String(""))
+	}
+	feed := tinabot.ICalFeed(b, c.Param("office"), time.Now().In(loc))
+	return c.Render(http.StatusOK, r.Func("text/calendar; charset=utf-8", func(w io.Writer, d render.Data) error {
+		_, err := io.WriteString(w, feed)
+		return err
+	}))
+}
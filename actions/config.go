@@ -0,0 +1,30 @@
+package actions
+
+import (
+	"log"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/config"
+)
+
+// setupConfig loads and validates the configuration, exiting if it's invalid
+// rather than running with half of it, and keeps its live keys in sync with
+// the brain, see config.Watch
+func setupConfig() {
+	if err := config.Setup(); err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+	url := brain.URLFromEnv()
+	if url == "" {
+		return
+	}
+	// the brain is kept open to receive the reloads until exiting
+	b, err := openBrain(url)
+	if err != nil {
+		log.Println("Error opening the brain for the configuration:", err)
+		return
+	}
+	if err := config.Watch(b); err != nil {
+		log.Println("Error watching the configuration:", err)
+	}
+}
@@ -0,0 +1,51 @@
+package actions
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gobuffalo/buffalo"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tinabot"
+)
+
+// displayRefresh is how often the display page reloads, in seconds
+const displayRefresh = 60
+
+// renderDisplay shows the display page with a message, today's menu and
+// the dishes ordered so far, without the names of the users
+func renderDisplay(c buffalo.Context, status int, rows []guestRow, order tinabot.APIOrder, message string) error {
+	c.Set("refresh", displayRefresh)
+	c.Set("rows", rows)
+	c.Set("order", order)
+	c.Set("message", message)
+	return c.Render(status, r.HTML("display.html"))
+}
+
+// DisplayHandler shows today's menu and the anonymous summary of the order,
+// read only, for the displays on the walls of the offices. The token
+// parameter must be the shared token in DISPLAY_TOKEN, office picks the
+// office as in the API.
+func DisplayHandler(c buffalo.Context) error {
+	if !validToken(c.Param("token"), []string{os.Getenv("DISPLAY_TOKEN")}) {
+		return renderDisplay(c, http.StatusUnauthorized, nil, tinabot.APIOrder{}, "Accesso non autorizzato")
+	}
+	root, err := openBrain(brain.URLFromEnv())
+	if err != nil {
+		log.Println(err)
+		return renderDisplay(c, http.StatusServiceUnavailable, nil, tinabot.APIOrder{}, "Servizio non disponibile, riprova più tardi")
+	}
+	b, ok := officeStore(c, root)
+	if !ok {
+		return renderDisplay(c, http.StatusNotFound, nil, tinabot.APIOrder{}, "Ufficio non trovato")
+	}
+
+	order := tinabot.APIOrderToday(b)
+	menu, err := tinabot.LoadMenu(b, apiNow())
+	if err != nil {
+		return renderDisplay(c, http.StatusOK, nil, order, "Il menù di oggi non è ancora disponibile")
+	}
+	return renderDisplay(c, http.StatusOK, guestRows(menu), order, "")
+}
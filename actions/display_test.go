@@ -0,0 +1,7 @@
+package actions
+
+func (as *ActionSuite) Test_Display_Unauthorized() {
+	res := as.HTML("/display?token=nope").Get()
+	as.Equal(401, res.Code)
+	as.Contains(res.Body.String(), "Accesso non autorizzato")
+}
@@ -1,31 +1,38 @@
 package actions
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"os"
 	"strconv"
 	"strings"
 
 	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/config"
+	"github.com/develersrl/lunches/pkg/logging"
+	"github.com/develersrl/lunches/pkg/sentry"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tinabot"
+	"github.com/develersrl/lunches/pkg/tracing"
 	"github.com/develersrl/lunches/pkg/tuttobene"
 	"github.com/gobuffalo/buffalo"
 	"github.com/mailgun/mailgun-go/v3"
-	"github.com/nlopes/slack"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // EmailHandler default implementation.
 func EmailHandler(c buffalo.Context) error {
-	log.Println("Email received!")
+	l := logging.Event(c.Param("Message-Id"), "", "", "")
+	l.Info("Email received")
 	domain := os.Getenv("MAILGUN_DOMAIN")
 	if domain == "" {
-		log.Println("MAILGUN_DOMAIN not set")
+		l.Error("MAILGUN_DOMAIN not set")
 		return nil
 	}
 
 	apiKey := os.Getenv("MAILGUN_API_KEY")
 	if apiKey == "" {
-		log.Println("MAILGUN_API_KEY not set")
+		l.Error("MAILGUN_API_KEY not set")
 		return nil
 	}
 
@@ -38,91 +45,106 @@ func EmailHandler(c buffalo.Context) error {
 	})
 
 	if err != nil {
-		log.Println(err)
+		l.Error("Error verifying the Mailgun signature", "err", err)
 		return nil
 	}
 
 	if !verified {
-		log.Printf("Mailgun signature verification error")
+		l.Warn("Mailgun signature verification error")
 		return nil
 	}
 
 	if !strings.HasPrefix(c.Param("Content-Type"), "multipart/mixed") {
-		log.Printf("Wrong POST Content-Type: '%s'", c.Param("Content-Type"))
+		l.Warn("Wrong POST Content-Type", "content_type", c.Param("Content-Type"))
 		return nil
 	}
 	if c.Param("attachment-count") == "" {
-		log.Println("No attachment found")
+		l.Warn("No attachment found")
 		return nil
 	}
 
 	n, err := strconv.Atoi(c.Param("attachment-count"))
 	if err != nil {
-		log.Println(err)
+		l.Warn("Invalid attachment count", "err", err)
 		return nil
 	}
 
-	token := os.Getenv("SLACK_BOT_TOKEN")
-	if token == "" {
-		log.Fatalln("No slackbot token found!")
-	}
-
-	channel := os.Getenv("FOOD_CHANNEL")
+	channel := config.Current().FoodChannel
 	if channel == "" {
-		log.Println("No channel found!")
+		l.Error("No channel found!")
+		return nil
+	}
+	brainURL := brain.URLFromEnv()
+	if brainURL == "" {
+		l.Error("No brain URL found!")
 		return nil
 	}
-	api := slack.New(token)
+	b, err := openBrain(brainURL)
+	if err != nil {
+		l.Error("Error opening the brain", "err", err)
+		return nil
+	}
+	// the menus by email are for the workspace configured by hand, the one
+	// of the food channel
+	platform, botID, err := slackPlatform(b, slackbot.LegacyTeam())
+	if err != nil {
+		l.Error("No bot token for the workspace", "err", err)
+		return nil
+	}
+	ctx, span := tracing.Start(tracing.FromRequest(c.Request()), "email.menu")
+	defer span.End()
 
 	for i := 0; i < n; i++ {
 		f, h, err := c.Request().FormFile(fmt.Sprintf("attachment-%d", i+1))
 		if err != nil {
-			log.Println(err)
+			l.Error("Error reading the attachment", "err", err)
 			return nil
 		}
 		name := strings.ToLower(h.Filename)
 		if strings.Contains(name, ".xlsx") {
-			if h.Size > 500000 {
-				log.Println("Attachemnt too large!")
-				api.PostMessage(channel, slack.MsgOptionText("Menu ricevuto, file in attachment di dimensioni eccessive!", false))
+			if h.Size > tuttobene.MaxFileSize {
+				l.Warn("Attachment too large!", "file", h.Filename, "size", h.Size)
+				platform.SendMessage(channel, "", "Menu ricevuto, file in attachment di dimensioni eccessive!")
 				return nil
 			}
 			buf := make([]byte, h.Size)
 
 			_, err := f.Read(buf)
 			if err != nil {
-				log.Println(err)
+				l.Error("Error reading the attachment", "file", h.Filename, "err", err)
 				return nil
 			}
 
+			_, parse := tracing.Start(ctx, "menu.parse", attribute.String("menu.file", h.Filename))
 			m, err := tuttobene.ParseMenuBytes(buf)
+			tracing.End(parse, err)
 
 			if err != nil {
-				log.Println("Menu parse error: ", err)
-				api.PostMessage(channel, slack.MsgOptionText("Menu ricevuto, errore durante l'analisi: "+err.Error(), false))
-				return nil
-			}
-			redisURL := os.Getenv("REDIS_URL")
-			if redisURL == "" {
-				log.Println("No redis URL found!")
+				l.Error("Menu parse error", "file", h.Filename, "err", err)
+				if !tinabot.IsMenuFileRefused(err) {
+					sentry.CaptureError(sentry.WithContext(ctx, nil, map[string]any{"file": h.Filename, "size": h.Size}), err)
+				}
+				platform.SendMessage(channel, "", tinabot.MenuFileError(h.Filename, err))
 				return nil
 			}
+			tb := brain.Trace(b, func() context.Context { return ctx })
 
-			b := brain.New(redisURL)
-			defer b.Close()
+			tinabot.SaveMenu(tb, *m)
 
-			b.Set("menu", *m)
-
-			log.Println("Tuttobene menu parsed correctly")
+			l.Info("Tuttobene menu parsed correctly", "date", m.Date.Format("2006-01-02"))
 
 			date := m.Date.Format("02/01/2006")
-			api.PostMessage(channel, slack.MsgOptionText("Ho appena ricevuto e impostato correttamente il menu per il giorno "+date, false))
+			platform.SendMessage(channel, "", "Ho appena ricevuto e impostato correttamente il menu per il giorno "+date)
+			err = tinabot.PinMenu(platform, tb, botID, channel, *m)
+			if err != nil {
+				l.Error("Error pinning menu", "err", err)
+			}
 			return nil
 		}
 
-		log.Println("Unrecognized attachment:", h.Filename)
+		l.Warn("Unrecognized attachment", "file", h.Filename)
 	}
 
-	log.Println("No menu parsed from email")
+	l.Warn("No menu parsed from email")
 	return nil
 }
@@ -0,0 +1,116 @@
+package actions
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gobuffalo/buffalo"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/config"
+	"github.com/develersrl/lunches/pkg/tinabot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// guestRow is a dish of the menu shown to the guests
+type guestRow struct {
+	Index   int
+	Type    string
+	Content string
+	Price   string
+}
+
+// guestRows returns the dishes of menu the guests can pick
+func guestRows(menu tuttobene.Menu) []guestRow {
+	var rows []guestRow
+	for i, r := range menu.Rows {
+		if r.Type == tuttobene.Empty || r.Type == tuttobene.Unknonwn {
+			continue
+		}
+		price := ""
+		if r.Price.IsPositive() {
+			price = "€" + r.Price.StringFixed(2)
+		}
+		rows = append(rows, guestRow{i, tuttobene.Titles[r.Type], r.Content, price})
+	}
+	return rows
+}
+
+// renderGuest shows the guest page with a message and the menu, if any,
+// to order as the guest of by
+func renderGuest(c buffalo.Context, status int, by string, rows []guestRow, message string) error {
+	c.Set("by", by)
+	c.Set("rows", rows)
+	c.Set("message", message)
+	return c.Render(status, r.HTML("guest.html"))
+}
+
+// GuestHandler shows the menu to the guest of a link created with "link
+// ospite", to order once without Slack
+func GuestHandler(c buffalo.Context) error {
+	if !config.Current().Enabled(config.FeatureGuests) {
+		return renderGuest(c, http.StatusNotFound, "", nil, "Mi spiace, gli ordini degli ospiti non sono attivi")
+	}
+	b, err := openBrain(brain.URLFromEnv())
+	if err != nil {
+		log.Println(err)
+		return renderGuest(c, http.StatusServiceUnavailable, "", nil, "Servizio non disponibile, riprova più tardi")
+	}
+
+	l, menu, err := tinabot.GuestMenu(b, c.Param("token"))
+	if err == tinabot.ErrGuestLink {
+		return renderGuest(c, http.StatusNotFound, "", nil, "Mi spiace, "+err.Error())
+	}
+	if err != nil {
+		return renderGuest(c, http.StatusOK, "", nil, "Mi spiace, "+err.Error())
+	}
+	return renderGuest(c, http.StatusOK, l.By.Name, guestRows(menu), "")
+}
+
+// GuestOrderHandler places the order of the guest and tells the channel
+// where the link was created
+func GuestOrderHandler(c buffalo.Context) error {
+	if !config.Current().Enabled(config.FeatureGuests) {
+		return renderGuest(c, http.StatusNotFound, "", nil, "Mi spiace, gli ordini degli ospiti non sono attivi")
+	}
+	b, err := openBrain(brain.URLFromEnv())
+	if err != nil {
+		log.Println(err)
+		return renderGuest(c, http.StatusServiceUnavailable, "", nil, "Servizio non disponibile, riprova più tardi")
+	}
+
+	req := c.Request()
+	if err := req.ParseForm(); err != nil {
+		return renderGuest(c, http.StatusBadRequest, "", nil, "Richiesta non valida")
+	}
+	var rows []int
+	for _, v := range req.PostForm["dish"] {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return renderGuest(c, http.StatusBadRequest, "", nil, "Richiesta non valida")
+		}
+		rows = append(rows, i)
+	}
+
+	token := c.Param("token")
+	l, placed, err := tinabot.PlaceGuestOrder(b, token, req.PostForm.Get("name"), rows)
+	if err == tinabot.ErrGuestLink {
+		return renderGuest(c, http.StatusNotFound, "", nil, "Mi spiace, "+err.Error())
+	}
+	if err != nil {
+		// the guest can fix the order and try again
+		if _, menu, merr := tinabot.GuestMenu(b, token); merr == nil {
+			return renderGuest(c, http.StatusOK, l.By.Name, guestRows(menu), "Mi spiace, "+err.Error())
+		}
+		return renderGuest(c, http.StatusOK, "", nil, "Mi spiace, "+err.Error())
+	}
+
+	if p, _, err := slackPlatform(b, l.Team); err == nil {
+		p.SendMessage(l.Channel, "", placed)
+	} else {
+		log.Printf("No bot token for the team %s: %v", l.Team, err)
+	}
+	return renderGuest(c, http.StatusOK, "", nil, "Ordine ricevuto, buon appetito!\n"+strings.SplitN(placed, "\n", 2)[1])
+}
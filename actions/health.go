@@ -0,0 +1,161 @@
+package actions
+
+import (
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/nlopes/slack"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tinabot"
+)
+
+// healthCheck is the outcome of a check of the bot, the endpoint fails if a
+// required one does
+type healthCheck struct {
+	Name     string `json:"name"`
+	OK       bool   `json:"ok"`
+	Detail   string `json:"detail,omitempty"`
+	Required bool   `json:"required"`
+}
+
+// healthReport is the body of the health endpoints, Status is "ok",
+// "degraded" if only the optional checks failed, or "down"
+type healthReport struct {
+	Status string        `json:"status"`
+	Checks []healthCheck `json:"checks"`
+}
+
+// newHealthReport returns the report of checks with its HTTP status code
+func newHealthReport(checks ...healthCheck) (int, healthReport) {
+	rep := healthReport{Status: "ok", Checks: checks}
+	for _, c := range checks {
+		switch {
+		case c.OK:
+		case c.Required:
+			rep.Status = "down"
+		case rep.Status == "ok":
+			rep.Status = "degraded"
+		}
+	}
+	if rep.Status == "down" {
+		return http.StatusServiceUnavailable, rep
+	}
+	return http.StatusOK, rep
+}
+
+// checkBrain pings the server of the brain
+func checkBrain(root brain.Store) healthCheck {
+	c := healthCheck{Name: "brain", Required: true}
+	if err := root.Healthy(); err != nil {
+		c.Detail = err.Error()
+		return c
+	}
+	c.OK = true
+	return c
+}
+
+// checkSocket checks the Socket Mode connection, which the bot needs to
+// receive the events when SLACK_MODE=socket
+func checkSocket() healthCheck {
+	c := healthCheck{Name: "socket", Required: true, OK: true}
+	if !SocketMode() {
+		c.Detail = "non usato"
+		return c
+	}
+	if !slackbot.SocketConnected() {
+		c.OK, c.Detail = false, "connessione Socket Mode assente"
+	}
+	return c
+}
+
+// slackCheckTTL is how long the outcome of the call to Slack is reused, so
+// that the probes don't hit its rate limits
+const slackCheckTTL = time.Minute
+
+var slackCheck struct {
+	sync.Mutex
+	at    time.Time
+	check healthCheck
+}
+
+// checkSlack calls auth.test with SLACK_BOT_TOKEN, if set, to check that
+// Slack can be reached and the token is still valid
+func checkSlack() healthCheck {
+	token := os.Getenv("SLACK_BOT_TOKEN")
+	if token == "" {
+		return healthCheck{Name: "slack", OK: true, Required: true, Detail: "SLACK_BOT_TOKEN non impostato"}
+	}
+	slackCheck.Lock()
+	defer slackCheck.Unlock()
+	if time.Since(slackCheck.at) < slackCheckTTL {
+		return slackCheck.check
+	}
+
+	c := healthCheck{Name: "slack", Required: true}
+	if res, err := slack.New(token).AuthTest(); err != nil {
+		slackbot.APIError("auth.test")
+		c.Detail = err.Error()
+	} else {
+		c.OK, c.Detail = true, res.Team
+	}
+	slackCheck.at, slackCheck.check = time.Now(), c
+	return c
+}
+
+// checkMenu checks that the menu of today is set, unless there's no lunch
+// today, like in the weekends. It's not required: the menu comes in the
+// morning.
+func checkMenu(b brain.Store, now time.Time) healthCheck {
+	c := healthCheck{Name: "menu", OK: true}
+	if now.Weekday() == time.Saturday || now.Weekday() == time.Sunday {
+		c.Detail = "fine settimana"
+		return c
+	}
+	var cal tinabot.Calendar
+	cal.Load(b)
+	if closed, reason := cal.IsClosed(now); closed {
+		c.Detail = reason
+		return c
+	}
+	if _, err := tinabot.LoadMenu(b, now); err != nil {
+		c.OK, c.Detail = false, "nessun menù per oggi"
+	}
+	return c
+}
+
+// withHealth opens the brain and renders the report of the checks returned
+// by fn, the brain is down if it can't be opened
+func withHealth(c buffalo.Context, fn func(root brain.Store) []healthCheck) error {
+	var checks []healthCheck
+	root, err := openBrain(brain.URLFromEnv())
+	if err != nil {
+		checks = []healthCheck{{Name: "brain", Required: true, Detail: err.Error()}}
+	} else {
+		checks = fn(root)
+	}
+	status, rep := newHealthReport(checks...)
+	return c.Render(status, r.JSON(rep))
+}
+
+// HealthHandler tells if the bot is alive: the brain answers and, in Socket
+// Mode, the connection to Slack is up. It fails only when restarting the bot
+// may help, for the liveness probes of the hosting platform.
+func HealthHandler(c buffalo.Context) error {
+	return withHealth(c, func(root brain.Store) []healthCheck {
+		return []healthCheck{checkBrain(root), checkSocket()}
+	})
+}
+
+// ReadyHandler tells if the bot is ready to take the orders: the brain
+// answers, Slack can be reached with the bot token, and the menu of today,
+// reported without failing if missing.
+func ReadyHandler(c buffalo.Context) error {
+	return withHealth(c, func(root brain.Store) []healthCheck {
+		return []healthCheck{checkBrain(root), checkSocket(), checkSlack(), checkMenu(root, apiNow())}
+	})
+}
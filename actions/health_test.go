@@ -0,0 +1,45 @@
+package actions
+
+import (
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tinabot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func (as *ActionSuite) Test_HealthReport() {
+	code, rep := newHealthReport(healthCheck{Name: "brain", OK: true, Required: true}, healthCheck{Name: "menu"})
+	as.Equal(200, code)
+	as.Equal("degraded", rep.Status)
+
+	code, rep = newHealthReport(healthCheck{Name: "brain", Required: true}, healthCheck{Name: "menu", OK: true})
+	as.Equal(503, code)
+	as.Equal("down", rep.Status)
+
+	code, rep = newHealthReport(healthCheck{Name: "brain", OK: true, Required: true})
+	as.Equal(200, code)
+	as.Equal("ok", rep.Status)
+}
+
+func (as *ActionSuite) Test_CheckMenu() {
+	b := brain.NewMemory()
+	monday := time.Date(2019, 3, 11, 10, 0, 0, 0, time.Local)
+	as.False(checkMenu(b, monday).OK)
+	as.True(checkMenu(b, monday.AddDate(0, 0, -1)).OK)
+
+	as.NoError(tinabot.SaveMenu(b, tuttobene.Menu{Date: monday}))
+	as.True(checkMenu(b, monday).OK)
+
+	var cal tinabot.Calendar
+	cal.Close(monday.AddDate(0, 0, 1), "sciopero")
+	as.NoError(cal.Save(b))
+	c := checkMenu(b, monday.AddDate(0, 0, 1))
+	as.True(c.OK)
+	as.Equal("sciopero", c.Detail)
+}
+
+func (as *ActionSuite) Test_Healthz() {
+	res := as.JSON("/healthz").Get()
+	as.Contains(res.Body.String(), `"name":"brain"`)
+}
@@ -0,0 +1,108 @@
+package actions
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/nlopes/slack"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// slackCredentials returns the bot token and user ID for the events of
+// team, see slackbot.Installations.Credentials
+func slackCredentials(root brain.Store, team string) (token, botID string, err error) {
+	inst, err := slackbot.InstallationsFromEnv(root)
+	if err != nil {
+		return "", "", err
+	}
+	return inst.Credentials(team)
+}
+
+// slackPlatform returns the platform of the bot in team and its user ID,
+// see slackCredentials
+func slackPlatform(root brain.Store, team string) (*slackbot.Platform, string, error) {
+	token, botID, err := slackCredentials(root, team)
+	if err != nil {
+		return nil, "", err
+	}
+	return &slackbot.Platform{Client: slack.New(token)}, botID, nil
+}
+
+// uninstall forgets the token of team, when the app is uninstalled from
+// the workspace or its tokens are revoked
+func uninstall(root brain.Store, team string) {
+	inst, err := slackbot.InstallationsFromEnv(root)
+	if err == nil {
+		err = inst.Delete(team)
+	}
+	if err != nil {
+		log.Printf("Error removing the installation of the team %s: %v", team, err)
+		return
+	}
+	log.Printf("Bot uninstalled from the team %s", team)
+}
+
+// renderInstall shows the outcome of the installation
+func renderInstall(c buffalo.Context, status int, message string) error {
+	c.Set("message", message)
+	return c.Render(status, r.HTML("install.html"))
+}
+
+// openInstallations opens the brain and the installations, rendering the
+// error if the workspaces can't install the bot
+func openInstallations(c buffalo.Context, fn func(root brain.Store, o *slackbot.OAuth, inst *slackbot.Installations) error) error {
+	o, ok := slackbot.OAuthFromEnv()
+	if !ok {
+		return renderInstall(c, http.StatusNotFound, "L'installazione non è abilitata")
+	}
+	root, err := openBrain(brain.URLFromEnv())
+	if err != nil {
+		log.Println(err)
+		return renderInstall(c, http.StatusServiceUnavailable, "Servizio non disponibile, riprova più tardi")
+	}
+	inst, err := slackbot.InstallationsFromEnv(root)
+	if err != nil || !inst.Enabled() {
+		log.Println("Installations disabled: ", err)
+		return renderInstall(c, http.StatusNotFound, "L'installazione non è abilitata")
+	}
+	return fn(root, o, inst)
+}
+
+// SlackInstallHandler sends the user to Slack to install the bot in their
+// workspace
+func SlackInstallHandler(c buffalo.Context) error {
+	return openInstallations(c, func(root brain.Store, o *slackbot.OAuth, inst *slackbot.Installations) error {
+		u, err := o.AuthorizeURL(root)
+		if err != nil {
+			log.Println(err)
+			return renderInstall(c, http.StatusServiceUnavailable, "Servizio non disponibile, riprova più tardi")
+		}
+		return c.Redirect(http.StatusFound, u)
+	})
+}
+
+// SlackOAuthHandler completes the installation authorized on Slack, saving
+// the token of the workspace
+func SlackOAuthHandler(c buffalo.Context) error {
+	if e := c.Param("error"); e != "" {
+		return renderInstall(c, http.StatusOK, "Installazione annullata: "+e)
+	}
+	return openInstallations(c, func(root brain.Store, o *slackbot.OAuth, inst *slackbot.Installations) error {
+		installation, err := o.Exchange(root, c.Param("state"), c.Param("code"))
+		if err == slackbot.ErrState {
+			return renderInstall(c, http.StatusBadRequest, "Richiesta scaduta o non valida, riprova l'installazione")
+		}
+		if err == nil {
+			err = inst.Save(installation)
+		}
+		if err != nil {
+			log.Println("Error installing the bot: ", err)
+			return renderInstall(c, http.StatusBadGateway, "Errore nell'installazione, riprova più tardi")
+		}
+		log.Printf("Bot installed in the team %s (%s)", installation.TeamName, installation.TeamID)
+		return renderInstall(c, http.StatusOK, "Fatto! Il bot è installato in "+installation.TeamName+", invitalo nel canale del pranzo")
+	})
+}
@@ -0,0 +1,46 @@
+package actions
+
+import (
+	"context"
+	"log"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/jobs"
+	"github.com/develersrl/lunches/pkg/tinabot"
+)
+
+// jobWorkers is how many jobs each instance runs at the same time
+const jobWorkers = 4
+
+// stopJobs stops the workers started by StartJobs
+var stopJobs context.CancelFunc = func() {}
+
+// StartJobs runs the jobs queued by the bot in the background, like the
+// exports and the webhooks, see tinabot.HandleJobs
+func StartJobs() {
+	brainURL := brain.URLFromEnv()
+	if brainURL == "" {
+		log.Fatalln("No brain URL found!")
+	}
+	// the brain is shared by the workers until the bot exits
+	root, err := openBrain(brainURL)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	w := jobs.NewWorker(tinabot.Jobs(root))
+	tinabot.HandleJobs(w, root, func(team string) (chat.Platform, error) {
+		p, _, err := slackPlatform(root, team)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	})
+
+	var ctx context.Context
+	ctx, stopJobs = context.WithCancel(context.Background())
+	for i := 0; i < jobWorkers; i++ {
+		go w.Run(ctx)
+	}
+}
@@ -0,0 +1,23 @@
+package actions
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var metricsHandler = promhttp.Handler()
+
+// MetricsHandler exposes the metrics of the bot to Prometheus: the commands
+// handled, the menus parsed, the orders placed, the failed calls to Slack
+// and the latencies of the brain. If METRICS_TOKEN is set, the scraper must
+// send it as "Authorization: Bearer <token>".
+func MetricsHandler(c buffalo.Context) error {
+	if token := os.Getenv("METRICS_TOKEN"); token != "" && !validToken(requestToken(c.Request()), []string{token}) {
+		return c.Render(http.StatusUnauthorized, r.String(""))
+	}
+	metricsHandler.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
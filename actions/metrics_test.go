@@ -0,0 +1,19 @@
+package actions
+
+import "os"
+
+func (as *ActionSuite) Test_Metrics() {
+	res := as.HTML("/metrics").Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "bot_last_command_timestamp_seconds")
+}
+
+func (as *ActionSuite) Test_Metrics_Token() {
+	os.Setenv("METRICS_TOKEN", "secret")
+	defer os.Unsetenv("METRICS_TOKEN")
+
+	res := as.HTML("/metrics").Get()
+	as.Equal(401, res.Code)
+	res = as.HTML("/metrics?token=secret").Get()
+	as.Equal(200, res.Code)
+}
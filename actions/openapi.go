@@ -0,0 +1,110 @@
+package actions
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gobuffalo/buffalo"
+
+	"github.com/develersrl/lunches/pkg/openapi"
+	"github.com/develersrl/lunches/pkg/tinabot"
+)
+
+// apiPrefix is the path of the API
+const apiPrefix = "/api/v1"
+
+// apiRoute is an operation of the API with its handler: the routes are
+// added and documented from the same list, see apiRoutes
+type apiRoute struct {
+	openapi.Operation
+	Handler buffalo.Handler
+}
+
+// officeParam picks the office of the requests, see officeStore
+var officeParam = openapi.Param{Name: "office", In: "query", Description: "the ID of the office, as in the ufficio command, none outside of the offices"}
+
+// apiRoutes are the operations of the API, the paths relative to apiPrefix
+var apiRoutes = []apiRoute{
+	{openapi.Operation{
+		Method: "GET", Path: "/menu/today", ID: "getMenuToday",
+		Summary:  "Today's menu, Row is the index to order a dish",
+		Params:   []openapi.Param{officeParam},
+		Response: tinabot.APIMenu{},
+		Errors:   map[int]string{http.StatusNotFound: "Office not found or today's menu not known yet"},
+	}, APIMenuTodayHandler},
+	{openapi.Operation{
+		Method: "GET", Path: "/order", ID: "getOrder",
+		Summary:  "Today's order",
+		Params:   []openapi.Param{officeParam},
+		Response: tinabot.APIOrder{},
+	}, APIOrderHandler},
+	{openapi.Operation{
+		Method: "GET", Path: "/order/stream", ID: "streamOrder",
+		Summary: "Server-sent events of today's order: order with the whole order on connect and after every change, " +
+			"preceded by dish_added, dish_removed and order_state with the changes",
+		Params:      []openapi.Param{officeParam},
+		Response:    tinabot.OrderChange{},
+		ContentType: "text/event-stream",
+	}, APIOrderStreamHandler},
+	{openapi.Operation{
+		Method: "POST", Path: "/order/{user}", ID: "placeOrder",
+		Summary:  "Replace the order of a user with the dishes of today's menu, nothing if rows is empty",
+		Params:   []openapi.Param{{Name: "user", In: "path", Description: "the ID of the user"}, officeParam},
+		Request:  apiOrderRequest{},
+		Response: tinabot.APIUser{},
+		Errors: map[int]string{
+			http.StatusBadRequest: "Invalid body",
+			http.StatusConflict:   "The order can't be changed or the dishes aren't in the menu",
+		},
+	}, APIPlaceOrderHandler},
+}
+
+// addAPIRoutes adds the routes of the API to the group of apiPrefix
+func addAPIRoutes(api *buffalo.App) {
+	for _, rt := range apiRoutes {
+		switch rt.Method {
+		case "GET":
+			api.GET(rt.Path, rt.Handler)
+		case "POST":
+			api.POST(rt.Path, rt.Handler)
+		default:
+			panic("unsupported API method " + rt.Method)
+		}
+	}
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  *openapi.Document
+)
+
+// openAPIDocument returns the OpenAPI document of the API
+func openAPIDocument() *openapi.Document {
+	openAPIOnce.Do(func() {
+		ops := make([]openapi.Operation, len(apiRoutes))
+		for i, rt := range apiRoutes {
+			ops[i] = rt.Operation
+			ops[i].Path = apiPrefix + rt.Path
+			// the errors of apiAuth and withOffice, unless described
+			ops[i].Errors = map[int]string{
+				http.StatusUnauthorized:       "Invalid token",
+				http.StatusNotFound:           "Office not found",
+				http.StatusServiceUnavailable: "Service unavailable",
+			}
+			for code, desc := range rt.Errors {
+				ops[i].Errors[code] = desc
+			}
+		}
+		openAPIDoc = openapi.New("Lunches", "1", ops, apiError{}, map[string]openapi.SecurityScheme{
+			"bearer": {Type: "http", Scheme: "bearer"},
+			// for the EventSource of the browsers, see requestToken
+			"token": {Type: "apiKey", Name: "token", In: "query"},
+		})
+	})
+	return openAPIDoc
+}
+
+// OpenAPIHandler serves the OpenAPI document of the API
+func OpenAPIHandler(c buffalo.Context) error {
+	return c.Render(http.StatusOK, r.JSON(openAPIDocument()))
+}
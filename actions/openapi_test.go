@@ -0,0 +1,15 @@
+package actions
+
+func (as *ActionSuite) Test_OpenAPIDocument() {
+	d := openAPIDocument()
+	for _, rt := range apiRoutes {
+		e := d.Paths[apiPrefix+rt.Path][map[string]string{"GET": "get", "POST": "post"}[rt.Method]]
+		as.NotNil(e, rt.Path)
+		as.Contains(e.Responses, "401")
+	}
+	as.Contains(d.Components.Schemas, "APIOrder")
+
+	res := as.JSON("/api/openapi.json").Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), `"openapi":"3.0.3"`)
+}
@@ -0,0 +1,22 @@
+package actions
+
+import (
+	"github.com/gobuffalo/buffalo"
+
+	"github.com/develersrl/lunches/pkg/sentry"
+)
+
+// reportPanics reports the panics of the handlers to Sentry, with the route
+// of the request, then lets Buffalo answer them as before
+func reportPanics(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		defer func() {
+			if v := recover(); v != nil {
+				req := c.Request()
+				sentry.CapturePanic(sentry.WithContext(req.Context(), map[string]string{"method": req.Method, "path": req.URL.Path}, nil), v)
+				panic(v)
+			}
+		}()
+		return next(c)
+	}
+}
@@ -0,0 +1,72 @@
+package actions
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"net"
+	"os"
+
+	"google.golang.org/grpc"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/rpc"
+)
+
+// rpcServer is the gRPC server started by StartRPC, if any, closing
+// rpcStop ends its streams
+var (
+	rpcServer *grpc.Server
+	rpcStop   chan struct{}
+)
+
+// StartRPC serves the Lunches gRPC service on RPC_ADDR, e.g. ":9090", to the
+// clients with one of the API tokens, see apiTokens. It does nothing if
+// RPC_ADDR is not set.
+func StartRPC() {
+	addr := os.Getenv("RPC_ADDR")
+	if addr == "" {
+		return
+	}
+	brainURL := brain.URLFromEnv()
+	if brainURL == "" {
+		log.Fatalln("No brain URL found!")
+	}
+	// the brain is shared by the calls until the bot exits
+	root, err := openBrain(brainURL)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	rpcStop = make(chan struct{})
+	rpcServer = rpc.NewServer(&rpc.Service{Root: root, Now: apiNow, Stop: rpcStop}, apiTokens)
+	go func() {
+		if err := rpcServer.Serve(lis); err != nil {
+			log.Fatalln(err)
+		}
+	}()
+	slog.Info("Serving gRPC", "addr", addr)
+}
+
+// stopRPC ends the streams of the order updates, stops taking new calls and
+// waits for the ones being handled until ctx is done, then drops them
+func stopRPC(ctx context.Context) {
+	if rpcServer == nil {
+		return
+	}
+	close(rpcStop)
+	stopped := make(chan struct{})
+	go func() {
+		rpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		rpcServer.Stop()
+	}
+}
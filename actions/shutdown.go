@@ -0,0 +1,69 @@
+package actions
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+
+	"github.com/develersrl/lunches/pkg/inflight"
+)
+
+// defaultShutdownTimeout is how long the shutdown waits for the events being
+// handled, less than the 30 seconds most platforms wait before killing the
+// bot
+const defaultShutdownTimeout = 25 * time.Second
+
+// ShutdownTimeout returns how long the shutdown waits for the events being
+// handled, set with SHUTDOWN_TIMEOUT, e.g. "10s"
+func ShutdownTimeout() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT")); err == nil && d > 0 {
+		return d
+	}
+	return defaultShutdownTimeout
+}
+
+// drainRequests counts the requests changing something as in progress, and
+// refuses them once the shutdown began so that the senders, like Slack,
+// retry them later on another instance
+func drainRequests(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		switch c.Request().Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return next(c)
+		}
+		done, ok := inflight.Begin()
+		if !ok {
+			c.Response().Header().Set("Retry-After", "10")
+			return c.Render(http.StatusServiceUnavailable, r.String("Il bot si sta riavviando, riprova tra poco"))
+		}
+		defer done()
+		return next(c)
+	}
+}
+
+// Shutdown stops taking new events, refusing the requests and closing the
+// Socket Mode connection and the gRPC server, and waits until ctx is done for the events being
+// handled and the jobs being run. The brain is written synchronously and the
+// jobs not started stay queued, so nothing is lost once they are done; the
+// shared brain is closed then.
+func Shutdown(ctx context.Context) error {
+	slog.Info("Shutting down, waiting for the events being handled")
+	start := time.Now()
+	drained := make(chan error, 1)
+	go func() { drained <- inflight.Drain(ctx) }()
+	stopJobs()
+	stopRPC(ctx)
+	if err := stopSocketMode(ctx); err != nil {
+		slog.Error("Socket Mode not stopped", "err", err)
+	}
+	if err := <-drained; err != nil {
+		return err
+	}
+	closeBrains()
+	slog.Info("Shutdown done", "duration", time.Since(start).String())
+	return nil
+}
@@ -2,47 +2,44 @@ package actions
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"io/ioutil"
 	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 
 	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/logging"
+	"github.com/develersrl/lunches/pkg/sentry"
 	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/staging"
 	"github.com/develersrl/lunches/pkg/tinabot"
+	"github.com/develersrl/lunches/pkg/tracing"
 	"github.com/gobuffalo/buffalo"
 	"github.com/nlopes/slack"
 	"github.com/nlopes/slack/slackevents"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // SlackHandler default implementation.
 func SlackHandler(c buffalo.Context) error {
 	//return c.Render(200, r.HTML("slack/handler.html"))
-	slackToken := os.Getenv("SLACK_BOT_TOKEN")
-	if slackToken == "" {
-		log.Fatalln("No SLACK_BOT_TOKEN found!")
-	}
 	accessToken := os.Getenv("SLACK_VERIFICATION_TOKEN")
 	if accessToken == "" {
 		log.Fatalln("No SLACK_VERIFICATION_TOKEN found!")
 	}
-	botID := os.Getenv("BOT_ID")
-	if accessToken == "" {
-		log.Fatalln("No BOT_ID found!")
-	}
-	redisURL := os.Getenv("REDIS_URL")
-	if redisURL == "" {
-		log.Fatalln("No redis URL found!")
+	brainURL := brain.URLFromEnv()
+	if brainURL == "" {
+		log.Fatalln("No brain URL found!")
 	}
 
-	api := slack.New(slackToken)
-
-	brain := brain.New(redisURL)
-	defer brain.Close()
-
-	bot := slackbot.New(botID, api)
-	tina := tinabot.New(bot, brain)
-	tina.AddCommands()
+	brain, err := openBrain(brainURL)
+	if err != nil {
+		log.Fatalln(err)
+	}
 
 	w := c.Response()
 	r := c.Request()
@@ -64,16 +61,259 @@ func SlackHandler(c buffalo.Context) error {
 		w.Write([]byte(r.Challenge))
 	}
 	if eventsAPIEvent.Type == slackevents.CallbackEvent {
-		innerEvent := eventsAPIEvent.InnerEvent
+		team := eventsAPIEvent.TeamID
+		switch eventsAPIEvent.InnerEvent.Type {
+		case slackevents.AppUninstalled, slackevents.TokensRevoked:
+			uninstall(brain, team)
+			return nil
+		}
+		slackToken, botID, err := slackCredentials(brain, team)
+		if err != nil {
+			slog.Warn("No bot token for the team", "team", team, "err", err)
+			return nil
+		}
+		ctx, span := tracing.Start(tracing.FromRequest(r), "slack.event", eventAttributes(eventsAPIEvent)...)
+		defer span.End()
+		ctx = sentry.WithContext(ctx, nil, map[string]any{"event": sentry.SanitizeJSON([]byte(body))})
+		channel, user := eventScope(eventsAPIEvent.InnerEvent)
+		bot, tina := newTina(ctx, eventID(eventsAPIEvent), botID, slackToken, brain, team, channel, user)
+		defer tina.Close()
+		tina.AddCommands()
+		handleEvent(bot, tina, eventsAPIEvent.InnerEvent)
+	}
+
+	return nil
+}
+
+// fileSharedEvent is the file_shared event of the Events API, missing in
+// slackevents
+type fileSharedEvent struct {
+	Type      string `json:"type"`
+	ChannelID string `json:"channel_id"`
+	FileID    string `json:"file_id"`
+	UserID    string `json:"user_id"`
+}
+
+// reactionEvent is the reaction_added or reaction_removed event of the
+// Events API, missing in slackevents
+type reactionEvent struct {
+	Type     string `json:"type"`
+	User     string `json:"user"`
+	Reaction string `json:"reaction"`
+	Item     struct {
+		Type    string `json:"type"`
+		Channel string `json:"channel"`
+		TS      string `json:"ts"`
+	} `json:"item"`
+}
 
-		switch ev := innerEvent.Data.(type) {
-		case *slackevents.AppMentionEvent:
-			bot.HandleMsg(ev.Channel, ev.User, ev.Text)
-		case *slackevents.MessageEvent:
-			bot.HandleMsg(ev.Channel, ev.User, ev.Text)
+func init() {
+	slackevents.EventsAPIInnerEventMapping["file_shared"] = fileSharedEvent{}
+	slackevents.EventsAPIInnerEventMapping["reaction_added"] = reactionEvent{}
+	slackevents.EventsAPIInnerEventMapping["reaction_removed"] = reactionEvent{}
+}
+
+// newTina returns the bot for the messages of user in channel of team,
+// working on the state of their office and logging with the correlation ID
+// of the event being handled, whose span is in ctx
+func newTina(ctx context.Context, id, botID, slackToken string, root brain.Store, team, channel, user string) (*slackbot.Bot, *tinabot.TinaBot) {
+	bot := slackbot.New(botID, slack.New(slackToken))
+	bot.Token = slackToken
+	bot.SetContext(sentry.WithContext(ctx, map[string]string{"event_id": id, "team": team, "channel": channel, "user": user}, nil))
+	bot.Log = logging.Event(id, team, channel, user)
+	if traceID := tracing.TraceID(ctx); traceID != "" {
+		bot.Log = bot.Log.With("trace_id", traceID)
+	}
+	return bot, tinabot.NewScoped(bot, brain.Trace(root, bot.Context), team, channel, user)
+}
+
+// eventID returns the ID of an event of the Events API, empty if it has none
+func eventID(ev slackevents.EventsAPIEvent) string {
+	if cb, ok := ev.Data.(*slackevents.EventsAPICallbackEvent); ok {
+		return cb.EventID
+	}
+	return ""
+}
+
+// eventAttributes returns the attributes of the span of an event of the
+// Events API
+func eventAttributes(ev slackevents.EventsAPIEvent) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("slack.event_id", eventID(ev)),
+		attribute.String("slack.event_type", ev.InnerEvent.Type),
+		attribute.String("slack.team", ev.TeamID),
+	}
+}
+
+// eventScope returns the channel and the user of an event of the Events API
+func eventScope(innerEvent slackevents.EventsAPIInnerEvent) (channel, user string) {
+	switch ev := innerEvent.Data.(type) {
+	case *slackevents.AppMentionEvent:
+		return ev.Channel, ev.User
+	case *slackevents.MessageEvent:
+		return ev.Channel, ev.User
+	case *slackevents.AppHomeOpenedEvent:
+		return ev.Channel, ev.User
+	case *fileSharedEvent:
+		return ev.ChannelID, ev.UserID
+	case *reactionEvent:
+		return ev.Item.Channel, ev.User
+	}
+	return "", ""
+}
+
+// handleEvent handles an event of the Events API, received either by
+// SlackHandler or in Socket Mode. A panic is reported, not to crash the bot.
+func handleEvent(bot *slackbot.Bot, tina *tinabot.TinaBot, innerEvent slackevents.EventsAPIInnerEvent) {
+	defer sentry.Recover(bot.Context())
+	bot.Logger().Debug("Slack event received", "type", innerEvent.Type)
+	switch ev := innerEvent.Data.(type) {
+	case *slackevents.AppMentionEvent:
+		bot.HandleMsg(ev.Channel, ev.User, ev.Text)
+	case *slackevents.MessageEvent:
+		bot.HandleMsg(ev.Channel, ev.User, ev.Text)
+	case *slackevents.AppHomeOpenedEvent:
+		tina.HomeOpened(ev.User)
+	case *fileSharedEvent:
+		tina.FileShared(ev.ChannelID, ev.UserID, ev.FileID)
+	case *reactionEvent:
+		if ev.Item.Type == "message" {
+			tina.Reaction(ev.Item.Channel, ev.Item.TS, ev.User, ev.Reaction, ev.Type == "reaction_added")
 		}
+	}
+}
+
+// SlashCommandHandler handles the /lunch slash command, verifying that the
+// request was signed by Slack with SLACK_SIGNING_SECRET
+func SlashCommandHandler(c buffalo.Context) error {
+	signingSecret := os.Getenv("SLACK_SIGNING_SECRET")
+	if signingSecret == "" {
+		log.Fatalln("No SLACK_SIGNING_SECRET found!")
+	}
+	brainURL := brain.URLFromEnv()
+	if brainURL == "" {
+		log.Fatalln("No brain URL found!")
+	}
+
+	w := c.Response()
+	r := c.Request()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+	if err := verifySlackSignature(r.Header, body, signingSecret); err != nil {
+		slog.Warn("Invalid slash command signature", "err", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	cmd, err := slack.SlashCommandParse(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+
+	brain, err := openBrain(brainURL)
+	if err != nil {
+		log.Fatalln(err)
+	}
 
+	slackToken, botID, err := slackCredentials(brain, cmd.TeamID)
+	if err != nil {
+		slog.Warn("No bot token for the team", "team", cmd.TeamID, "err", err)
+		w.WriteHeader(http.StatusNotFound)
+		return nil
 	}
+	ctx, span := tracing.Start(tracing.FromRequest(r), "slack.command",
+		attribute.String("slack.command", cmd.Command), attribute.String("slack.team", cmd.TeamID))
+	defer span.End()
+	_, tina := newTina(ctx, cmd.TriggerID, botID, slackToken, brain, cmd.TeamID, cmd.ChannelID, cmd.UserID)
+	defer tina.Close()
+	tina.AddCommands()
 
+	if text := tina.SlashCommand(slackbot.ChatCommand(cmd)); text != "" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(staging.Mark(text)))
+	}
 	return nil
 }
+
+// InteractionHandler handles the clicks on the interactive messages of the
+// bot, e.g. the menu to order by picking the dishes
+func InteractionHandler(c buffalo.Context) error {
+	signingSecret := os.Getenv("SLACK_SIGNING_SECRET")
+	if signingSecret == "" {
+		log.Fatalln("No SLACK_SIGNING_SECRET found!")
+	}
+	brainURL := brain.URLFromEnv()
+	if brainURL == "" {
+		log.Fatalln("No brain URL found!")
+	}
+
+	w := c.Response()
+	r := c.Request()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+	if err := verifySlackSignature(r.Header, body, signingSecret); err != nil {
+		slog.Warn("Invalid interaction signature", "err", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+	var cb slack.InteractionCallback
+	if err := json.Unmarshal([]byte(form.Get("payload")), &cb); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+	if cb.Type != slack.InteractionTypeBlockActions && cb.Type != slackbot.InteractionTypeViewSubmission {
+		return nil
+	}
+
+	brain, err := openBrain(brainURL)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	slackToken, botID, err := slackCredentials(brain, cb.Team.ID)
+	if err != nil {
+		slog.Warn("No bot token for the team", "team", cb.Team.ID, "err", err)
+		return nil
+	}
+	ctx, span := tracing.Start(tracing.FromRequest(r), "slack.interaction",
+		attribute.String("slack.interaction", string(cb.Type)), attribute.String("slack.team", cb.Team.ID))
+	defer span.End()
+	ctx = sentry.WithContext(ctx, nil, map[string]any{"interaction": sentry.SanitizeJSON([]byte(form.Get("payload")))})
+	bot, tina := newTina(ctx, cb.TriggerID, botID, slackToken, brain, cb.Team.ID, cb.Channel.ID, cb.User.ID)
+	defer tina.Close()
+	defer sentry.Recover(bot.Context())
+	if cb.Type == slackbot.InteractionTypeViewSubmission {
+		// an empty answer closes the modal
+		if sub, err := slackbot.ChatSubmission([]byte(form.Get("payload"))); err == nil {
+			tina.ViewSubmission(sub)
+		}
+		return nil
+	}
+	tina.BlockAction(slackbot.ChatInteraction(cb))
+	return nil
+}
+
+// verifySlackSignature checks the signature of a request sent by Slack,
+// see https://api.slack.com/docs/verifying-requests-from-slack
+func verifySlackSignature(header http.Header, body []byte, secret string) error {
+	sv, err := slack.NewSecretsVerifier(header, secret)
+	if err != nil {
+		return err
+	}
+	if _, err := sv.Write(body); err != nil {
+		return err
+	}
+	return sv.Ensure()
+}
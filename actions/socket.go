@@ -0,0 +1,173 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"os"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/sentry"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/staging"
+	"github.com/develersrl/lunches/pkg/tracing"
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// SocketMode tells if the bot receives the Slack events in Socket Mode, set
+// with SLACK_MODE=socket, instead of on the HTTP endpoints
+func SocketMode() bool {
+	return os.Getenv("SLACK_MODE") == "socket"
+}
+
+// StartSocketMode connects to Slack in Socket Mode with the app-level token
+// SLACK_APP_TOKEN, handling the events, the slash commands and the
+// interactions like the HTTP endpoints do. It does nothing unless SocketMode.
+func StartSocketMode() {
+	if !SocketMode() {
+		return
+	}
+	appToken := os.Getenv("SLACK_APP_TOKEN")
+	if appToken == "" {
+		log.Fatalln("No SLACK_APP_TOKEN found!")
+	}
+	brainURL := brain.URLFromEnv()
+	if brainURL == "" {
+		log.Fatalln("No brain URL found!")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	socket.stop, socket.done = cancel, make(chan struct{})
+	go func() {
+		defer close(socket.done)
+		err := slackbot.ServeSocket(ctx, appToken, func(env slackbot.SocketEnvelope) interface{} {
+			return handleSocket(brainURL, env)
+		})
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}()
+}
+
+// socket stops the Socket Mode connection, done is closed once the envelope
+// being handled is done
+var socket struct {
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// stopSocketMode closes the Socket Mode connection, if any, and waits for
+// the envelope being handled until ctx is done
+func stopSocketMode(ctx context.Context) error {
+	if socket.stop == nil {
+		return nil
+	}
+	socket.stop()
+	select {
+	case <-socket.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// handleSocket handles an envelope received in Socket Mode, returns the
+// reply to a slash command
+func handleSocket(brainURL string, env slackbot.SocketEnvelope) interface{} {
+	// a panic would crash the bot with all the envelopes being handled
+	defer sentry.Recover(sentry.WithContext(context.Background(), map[string]string{"envelope_id": env.EnvelopeID, "envelope_type": env.Type}, nil))
+
+	brain, err := openBrain(brainURL)
+	if err != nil {
+		slog.Error("Error opening the brain", "envelope_id", env.EnvelopeID, "err", err)
+		return nil
+	}
+	// credentials returns the bot of team, false if it has none
+	credentials := func(team string) (slackToken, botID string, ok bool) {
+		slackToken, botID, err := slackCredentials(brain, team)
+		if err != nil {
+			slog.Warn("No bot token for the team", "team", team, "err", err)
+			return "", "", false
+		}
+		return slackToken, botID, true
+	}
+
+	switch env.Type {
+	case slackbot.SocketEventsAPI:
+		ev, err := slackevents.ParseEvent(env.Payload, slackevents.OptionNoVerifyToken())
+		if err != nil {
+			slog.Warn("Invalid Socket Mode event", "envelope_id", env.EnvelopeID, "err", err)
+			return nil
+		}
+		if ev.Type == slackevents.CallbackEvent {
+			slackToken, botID, ok := credentials(ev.TeamID)
+			if !ok {
+				return nil
+			}
+			channel, user := eventScope(ev.InnerEvent)
+			id := eventID(ev)
+			if id == "" {
+				id = env.EnvelopeID
+			}
+			ctx, span := tracing.Start(context.Background(), "slack.event", eventAttributes(ev)...)
+			defer span.End()
+			ctx = sentry.WithContext(ctx, nil, map[string]any{"event": sentry.SanitizeJSON(env.Payload)})
+			bot, tina := newTina(ctx, id, botID, slackToken, brain, ev.TeamID, channel, user)
+			defer tina.Close()
+			tina.AddCommands()
+			handleEvent(bot, tina, ev.InnerEvent)
+		}
+
+	case slackbot.SocketSlashCommand:
+		var cmd slack.SlashCommand
+		if err := json.Unmarshal(env.Payload, &cmd); err != nil {
+			slog.Warn("Invalid Socket Mode slash command", "envelope_id", env.EnvelopeID, "err", err)
+			return nil
+		}
+		slackToken, botID, ok := credentials(cmd.TeamID)
+		if !ok {
+			return nil
+		}
+		ctx, span := tracing.Start(context.Background(), "slack.command",
+			attribute.String("slack.command", cmd.Command), attribute.String("slack.team", cmd.TeamID))
+		defer span.End()
+		_, tina := newTina(ctx, env.EnvelopeID, botID, slackToken, brain, cmd.TeamID, cmd.ChannelID, cmd.UserID)
+		defer tina.Close()
+		tina.AddCommands()
+		if text := tina.SlashCommand(slackbot.ChatCommand(cmd)); text != "" {
+			return map[string]string{"text": staging.Mark(text)}
+		}
+
+	case slackbot.SocketInteractive:
+		var cb slack.InteractionCallback
+		if err := json.Unmarshal(env.Payload, &cb); err != nil {
+			slog.Warn("Invalid Socket Mode interaction", "envelope_id", env.EnvelopeID, "err", err)
+			return nil
+		}
+		if cb.Type == slack.InteractionTypeBlockActions || cb.Type == slackbot.InteractionTypeViewSubmission {
+			slackToken, botID, ok := credentials(cb.Team.ID)
+			if !ok {
+				return nil
+			}
+			ctx, span := tracing.Start(context.Background(), "slack.interaction",
+				attribute.String("slack.interaction", string(cb.Type)), attribute.String("slack.team", cb.Team.ID))
+			defer span.End()
+			ctx = sentry.WithContext(ctx, nil, map[string]any{"interaction": sentry.SanitizeJSON(env.Payload)})
+			bot, tina := newTina(ctx, env.EnvelopeID, botID, slackToken, brain, cb.Team.ID, cb.Channel.ID, cb.User.ID)
+			defer tina.Close()
+			defer sentry.Recover(bot.Context())
+			if cb.Type == slackbot.InteractionTypeViewSubmission {
+				// an empty ack closes the modal
+				if sub, err := slackbot.ChatSubmission(env.Payload); err == nil {
+					tina.ViewSubmission(sub)
+				}
+				return nil
+			}
+			tina.BlockAction(slackbot.ChatInteraction(cb))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tinabot"
+)
+
+// streamPing is how often the order stream sends a comment, to keep the
+// connection open through the proxies
+const streamPing = 30 * time.Second
+
+// writeEvent writes a server-sent event named name with data as JSON
+func writeEvent(w io.Writer, name string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, b)
+	return err
+}
+
+// APIOrderStreamHandler streams today's order as server-sent events, for
+// the live views like the display of the kitchen: an "order" event with
+// the whole order on connect and after every change, preceded by an event
+// for each change, see tinabot.OrderChange.
+func APIOrderStreamHandler(c buffalo.Context) error {
+	return withOffice(c, func(root, b brain.Store) error {
+		w := c.Response()
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			return c.Render(http.StatusInternalServerError, r.JSON(apiError{"streaming non supportato"}))
+		}
+
+		updates := make(chan *tinabot.Order, 16)
+		stop, err := tinabot.WatchOrder(b, func(o *tinabot.Order) {
+			select {
+			case updates <- o:
+			default:
+				// the client is too slow, the next order has its changes too
+			}
+		})
+		if err != nil {
+			return c.Render(http.StatusNotImplemented, r.JSON(apiError{"aggiornamenti non disponibili"}))
+		}
+		defer stop()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		order := tinabot.APIOrderToday(b)
+		if err := writeEvent(w, "order", order); err != nil {
+			return nil
+		}
+		flusher.Flush()
+
+		ping := time.NewTicker(streamPing)
+		defer ping.Stop()
+		for {
+			select {
+			case <-c.Request().Context().Done():
+				return nil
+			case <-ping.C:
+				if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+					return nil
+				}
+			case o := <-updates:
+				next := tinabot.NewAPIOrder(o)
+				for _, change := range tinabot.OrderChanges(order, next) {
+					if err := writeEvent(w, change.Type, change); err != nil {
+						return nil
+					}
+				}
+				if err := writeEvent(w, "order", next); err != nil {
+					return nil
+				}
+				order = next
+			}
+			flusher.Flush()
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package actions
+
+import (
+	"errors"
+	"log"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/teams"
+	"github.com/develersrl/lunches/pkg/tinabot"
+	"github.com/develersrl/lunches/pkg/tracing"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// The platform of the Teams bot, kept between the requests to reuse the
+// token and the signing keys
+var (
+	teamsOnce     sync.Once
+	teamsPlatform *teams.Platform
+)
+
+// teamsMenuCard returns the card of the menu, a button for each dish to
+// order it
+func teamsMenuCard(menu tuttobene.Menu) teams.Attachment {
+	var buttons []teams.Button
+	for _, r := range menu.Rows {
+		if r.Type == tuttobene.Empty || r.Type == tuttobene.Unknonwn {
+			continue
+		}
+		buttons = append(buttons, teams.Button{Title: r.Content, Command: "per me " + r.Content})
+	}
+	return teams.ActionCard("Scegli un piatto del menù di oggi:", buttons)
+}
+
+// TeamsHandler receives the activities sent by Teams through the Bot
+// Framework and runs the messages as commands, like the Slack messages.
+// The users and the conversations of Teams are kept apart from the Slack
+// ones by the "teams:" prefix.
+func TeamsHandler(c buffalo.Context) error {
+	teamsOnce.Do(func() {
+		teamsPlatform = teams.NewPlatform()
+	})
+	if teamsPlatform == nil {
+		slog.Warn("No TEAMS_APP_ID and TEAMS_APP_PASSWORD found!")
+		return c.Render(http.StatusNotFound, r.String(""))
+	}
+
+	// a copy for this request, answering its activity
+	platform := &teams.Platform{Client: teamsPlatform.Client, Verifier: teamsPlatform.Verifier}
+	ev, err := platform.ParseEvent(c.Request())
+	if errors.Is(err, teams.ErrUnauthorized) {
+		slog.Warn("Teams request refused", "err", err)
+		return c.Render(http.StatusUnauthorized, r.String(""))
+	}
+	if err != nil {
+		return c.Render(http.StatusBadRequest, r.String(""))
+	}
+	if ev == nil {
+		return c.Render(http.StatusOK, r.String(""))
+	}
+
+	brainURL := brain.URLFromEnv()
+	if brainURL == "" {
+		log.Fatalln("No brain URL found!")
+	}
+	root, err := openBrain(brainURL)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	team := platform.Team()
+	if ev.Text == "ordina" {
+		// the interactive menu of Slack is a card in Teams
+		if menu, err := tinabot.LoadMenu(tinabot.Scope(root, team, ev.Channel, ev.User), time.Now()); err == nil {
+			reply := teams.Reply(platform.Activity, "")
+			reply.Attachments = []teams.Attachment{teamsMenuCard(menu)}
+			if err := platform.Client.Send(platform.Activity, reply); err != nil {
+				slog.Error("Error sending the menu card", "err", err)
+			}
+			return c.Render(http.StatusOK, r.String(""))
+		}
+	}
+
+	ctx, span := tracing.Start(tracing.FromRequest(c.Request()), "teams.activity", attribute.String("teams.team", team))
+	defer span.End()
+	// the bot answers on Teams, it needs no Slack token
+	bot, tina := newTina(ctx, platform.Activity.ID, teams.Prefix+platform.Activity.Recipient.ID, "", root, team, ev.Channel, ev.User)
+	defer tina.Close()
+	bot.Platform = platform
+	tina.AddCommands()
+	bot.HandleText(ev.Channel, ev.User, ev.Text)
+	return c.Render(http.StatusOK, r.String(""))
+}
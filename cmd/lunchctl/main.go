@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/events"
+	"github.com/develersrl/lunches/pkg/tinabot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+const usage = `A tool for the admins of the lunches bot
+
+Usage: lunchctl <command> [<args>]
+
+Commands:
+  parse <file.xlsx>            print the menu of the file as JSON and its problems
+  menu publish <file>          make the menu of an xlsx or JSON file today's one
+  order list [json]            print today's order
+  order clear                  empty today's order
+  brain dump [<file>] [<pattern>]
+                               dump the brain keys matching pattern to a JSON archive
+  brain restore <file>         restore the keys of an archive made by dump
+
+The brain is the one of BRAIN_URL, add ?namespace=<namespace> to work on an
+office (see the tinabot:offices task).
+`
+
+// fail prints the error and exits
+func fail(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
+
+// openBrain opens the brain configured in the environment, or exits
+func openBrain() brain.Store {
+	uri := brain.URLFromEnv()
+	if uri == "" {
+		fail("No brain URL found, set BRAIN_URL")
+	}
+	b, err := brain.Open(uri)
+	if err != nil {
+		fail("Could not open the brain: %v", err)
+	}
+	return b
+}
+
+// send tells the running bots about an event, if the brain supports pub/sub
+func send(b brain.Store, topic events.Topic, data interface{}) {
+	if ps, ok := b.(events.Broadcaster); ok {
+		if err := events.Send(ps, topic, data); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not send the %s event: %v\n", topic, err)
+		}
+	}
+}
+
+// today returns the current time in the time zone of the offices
+func today() time.Time {
+	if loc, err := time.LoadLocation("Europe/Rome"); err == nil {
+		return time.Now().In(loc)
+	}
+	return time.Now()
+}
+
+// readMenu returns the menu of an xlsx file or of the JSON printed by parse
+func readMenu(path string) (*tuttobene.Menu, error) {
+	if strings.ToLower(filepath.Ext(path)) != ".json" {
+		return tuttobene.ParseMenuFile(path)
+	}
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m tuttobene.Menu
+	err = json.Unmarshal(bs, &m)
+	return &m, err
+}
+
+func printJSON(w io.Writer, v interface{}) {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fail("Could not marshal: %v", err)
+	}
+	fmt.Fprintln(w, string(out))
+}
+
+func parse(args []string) {
+	if len(args) != 1 {
+		fail("Usage: lunchctl parse <file.xlsx>")
+	}
+	m, err := tuttobene.ParseMenuFile(args[0])
+	if err != nil {
+		fail("Could not parse file: %v", err)
+	}
+	printJSON(os.Stdout, m)
+	for _, d := range m.Diagnostics() {
+		fmt.Fprintln(os.Stderr, "warning:", d)
+	}
+}
+
+func menu(args []string) {
+	if len(args) != 2 || args[0] != "publish" {
+		fail("Usage: lunchctl menu publish <file.xlsx|file.json>")
+	}
+	m, err := readMenu(args[1])
+	if err != nil {
+		fail("Could not read the menu: %v", err)
+	}
+	if m.Date.IsZero() {
+		m.Date = today()
+	}
+
+	b := openBrain()
+	defer b.Close()
+	if err := tinabot.SaveMenu(b, *m); err != nil {
+		fail("Could not save the menu: %v", err)
+	}
+	send(b, events.MenuPublished, *m)
+	fmt.Printf("Menu of %s published, %d rows\n", m.Date.Format("02/01/2006"), len(m.Rows))
+}
+
+func order(args []string) {
+	if len(args) < 1 {
+		fail("Usage: lunchctl order list [json] | order clear")
+	}
+	b := openBrain()
+	defer b.Close()
+
+	switch args[0] {
+	case "list":
+		o := tinabot.TodayOrder(b)
+		if len(args) > 1 && args[1] == "json" {
+			printJSON(os.Stdout, tinabot.NewAPIOrder(o))
+			return
+		}
+		fmt.Printf("Order %s, %d users\n%s", o.State, len(o.Users), o.Format(true, true))
+	case "clear":
+		o, err := tinabot.ClearOrder(b)
+		if err != nil {
+			fail("Could not clear the order: %v", err)
+		}
+		send(b, events.OrderUpdated, o)
+		fmt.Println("Order cleared")
+	default:
+		fail("Unknown order command %q, use list or clear", args[0])
+	}
+}
+
+func brainCmd(args []string) {
+	if len(args) < 1 {
+		fail("Usage: lunchctl brain dump [<file>] [<pattern>] | brain restore <file>")
+	}
+	switch args[0] {
+	case "dump":
+		out, pattern := os.Stdout, "*"
+		if len(args) > 1 && args[1] != "-" {
+			f, err := os.Create(args[1])
+			if err != nil {
+				fail("Could not create the archive: %v", err)
+			}
+			defer f.Close()
+			out = f
+		}
+		if len(args) > 2 {
+			pattern = args[2]
+		}
+		b := openBrain()
+		defer b.Close()
+		if err := brain.Export(b, pattern, out); err != nil {
+			fail("Could not dump the brain: %v", err)
+		}
+	case "restore":
+		if len(args) != 2 {
+			fail("Usage: lunchctl brain restore <file>")
+		}
+		f, err := os.Open(args[1])
+		if err != nil {
+			fail("Could not open the archive: %v", err)
+		}
+		defer f.Close()
+		b := openBrain()
+		defer b.Close()
+		n, err := brain.Import(b, f)
+		fmt.Printf("Restored %d keys\n", n)
+		if err != nil {
+			fail("Could not restore the brain: %v", err)
+		}
+	default:
+		fail("Unknown brain command %q, use dump or restore", args[0])
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Print(usage)
+		os.Exit(1)
+	}
+
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "parse":
+		parse(args)
+	case "menu":
+		menu(args)
+	case "order":
+		order(args)
+	case "brain":
+		brainCmd(args)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n", os.Args[1])
+		fmt.Print(usage)
+		os.Exit(1)
+	}
+}
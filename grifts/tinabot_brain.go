@@ -0,0 +1,215 @@
+package grifts
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	. "github.com/markbates/grift/grift"
+)
+
+// openBrain opens the brain configured in the environment, or exits
+func openBrain() brain.Store {
+	brainURL := brain.URLFromEnv()
+	if brainURL == "" {
+		log.Fatalln("No brain URL found!")
+	}
+
+	b, err := brain.Open(brainURL)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return b
+}
+
+// openVersioned opens the brain configured in the environment, or exits if
+// it does not keep the versions of the keys
+func openVersioned() *brain.Versioned {
+	b, ok := openBrain().(*brain.Versioned)
+	if !ok {
+		log.Fatalln("The brain does not keep versions, set BRAIN_VERSIONS")
+	}
+	return b
+}
+
+// defaultBackupKeep is how many backups are kept if BRAIN_BACKUP_KEEP is not set
+const defaultBackupKeep = 30
+
+// openBackend opens the backup backend in BRAIN_BACKUP_URL, or exits
+func openBackend() brain.Backend {
+	uri := os.Getenv("BRAIN_BACKUP_URL")
+	if uri == "" {
+		log.Fatalln("No backup URL found, set BRAIN_BACKUP_URL")
+	}
+	b, err := brain.OpenBackend(uri)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return b
+}
+
+var _ = Namespace("tinabot", func() {
+
+	Desc("keys", "list the brain keys matching a glob pattern, with their TTL. Usage: keys [<pattern>]")
+	Add("keys", func(c *Context) error {
+		pattern := "*"
+		if len(c.Args) > 0 {
+			pattern = c.Args[0]
+		}
+
+		b := openBrain()
+		defer b.Close()
+
+		keys, err := b.Keys(pattern)
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if ttl, err := b.TTL(k); err == nil && ttl > 0 {
+				fmt.Printf("%s (expires in %v)\n", k, ttl.Round(time.Second))
+			} else {
+				fmt.Println(k)
+			}
+		}
+		return nil
+	})
+
+	Desc("export", "dump the brain keys matching a glob pattern to a JSON archive, on stdout if no file is given. Usage: export [<file>] [<pattern>]")
+	Add("export", func(c *Context) error {
+		out, pattern := os.Stdout, "*"
+		if len(c.Args) > 0 && c.Args[0] != "-" {
+			f, err := os.Create(c.Args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+		if len(c.Args) > 1 {
+			pattern = c.Args[1]
+		}
+
+		b := openBrain()
+		defer b.Close()
+		return brain.Export(b, pattern, out)
+	})
+
+	Desc("import", "restore the keys of a JSON archive made by export, overwriting the existing ones. Usage: import <file>")
+	Add("import", func(c *Context) error {
+		if len(c.Args) < 1 {
+			log.Fatalln("Not enough arguments, usage: import <file>")
+		}
+		f, err := os.Open(c.Args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		b := openBrain()
+		defer b.Close()
+		n, err := brain.Import(b, f)
+		log.Printf("Restored %d keys", n)
+		return err
+	})
+
+	Desc("backup", "save all the brain keys to BRAIN_BACKUP_URL, keeping the newest BRAIN_BACKUP_KEEP backups (default 30), encrypted with BRAIN_ENCRYPTION_KEY if set, e.g. add it to the crons. Usage: backup")
+	Add("backup", func(c *Context) error {
+		backend := openBackend()
+		keep := defaultBackupKeep
+		if env := os.Getenv("BRAIN_BACKUP_KEEP"); env != "" {
+			n, err := strconv.Atoi(env)
+			if err != nil || n < 1 {
+				log.Fatalf("Invalid BRAIN_BACKUP_KEEP %q", env)
+			}
+			keep = n
+		}
+
+		b := openBrain()
+		defer b.Close()
+		name, err := brain.Backup(b, backend)
+		if err != nil {
+			return err
+		}
+		log.Printf("Saved backup %s", name)
+
+		deleted, err := brain.Prune(backend, keep)
+		for _, n := range deleted {
+			log.Printf("Deleted old backup %s", n)
+		}
+		return err
+	})
+
+	Desc("backups", "list the backups in BRAIN_BACKUP_URL, from the oldest to the newest. Usage: backups")
+	Add("backups", func(c *Context) error {
+		backups, err := brain.Backups(openBackend())
+		if err != nil {
+			return err
+		}
+		for _, n := range backups {
+			fmt.Println(n)
+		}
+		return nil
+	})
+
+	Desc("restore", "restore a backup from BRAIN_BACKUP_URL, the newest one if no name is given, overwriting the existing keys. Usage: restore [<name>]")
+	Add("restore", func(c *Context) error {
+		backend := openBackend()
+		name := ""
+		if len(c.Args) > 0 {
+			name = c.Args[0]
+		}
+
+		b := openBrain()
+		defer b.Close()
+		n, err := brain.Restore(b, backend, name)
+		log.Printf("Restored %d keys", n)
+		return err
+	})
+
+	Desc("versions", "list the previous versions of a brain key, from the newest. Usage: versions <key>")
+	Add("versions", func(c *Context) error {
+		if len(c.Args) < 1 {
+			log.Fatalln("Not enough arguments, usage: versions <key>")
+		}
+		b := openVersioned()
+		defer b.Close()
+
+		history, err := b.History(c.Args[0])
+		if err != nil {
+			return err
+		}
+		for i, v := range history {
+			val := string(v.Value)
+			if v.Value == nil {
+				val = "(deleted)"
+			}
+			fmt.Printf("%d - %s: %s\n", i+1, v.Time.Format(time.RFC3339), val)
+		}
+		return nil
+	})
+
+	Desc("rollback", "restore a previous version of a brain key, the one before the last write if no number is given. Usage: rollback <key> [<n>]")
+	Add("rollback", func(c *Context) error {
+		if len(c.Args) < 1 {
+			log.Fatalln("Not enough arguments, usage: rollback <key> [<n>]")
+		}
+		n := 1
+		if len(c.Args) > 1 {
+			var err error
+			if n, err = strconv.Atoi(c.Args[1]); err != nil {
+				log.Fatalf("Invalid version %q", c.Args[1])
+			}
+		}
+		b := openVersioned()
+		defer b.Close()
+
+		if err := b.Rollback(c.Args[0], n); err != nil {
+			return err
+		}
+		log.Printf("Rolled back %s to version %d", c.Args[0], n)
+		return nil
+	})
+})
@@ -0,0 +1,113 @@
+package grifts
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/config"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tinabot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+	"github.com/go-redis/redis"
+	. "github.com/markbates/grift/grift"
+)
+
+var _ = Namespace("tinabot", func() {
+
+	Desc("simulate", "fast-forward a whole day of scheduled tasks on the test channel: the publish of the menu, the posts, the reminders, the email of the order and the removal of the old menus pinned are shown there without sending anything else. On the days without lunch only the cleanup is done, as by the cron. Usage: simulate [<channel>] [<seconds>]")
+	Add("simulate", func(c *Context) error {
+		channel := config.Current().TestChannel
+		if len(c.Args) > 0 {
+			channel = c.Args[0]
+		}
+		if channel == "" {
+			log.Fatalln("No test channel found, usage: simulate [<channel>] [<seconds>]")
+		}
+
+		duration := 60 * time.Second
+		if len(c.Args) > 1 {
+			n, err := strconv.Atoi(c.Args[1])
+			if err != nil {
+				log.Fatalln("Invalid duration: ", err)
+			}
+			duration = time.Duration(n) * time.Second
+		}
+
+		root := openRoot()
+		defer root.Close()
+		// the test channel is in the workspace configured by hand
+		ws, err := workspaceOf(root, slackbot.LegacyTeam())
+		if err != nil {
+			return err
+		}
+		platform, err := ws.platform()
+		if err != nil {
+			return err
+		}
+		brain := ws.Brain
+		var sched []string
+		err = brain.Get("cron", &sched)
+		if err == redis.Nil || len(sched) == 0 {
+			log.Println("No cron set")
+			return nil
+		}
+
+		loc, err := time.LoadLocation("Europe/Rome")
+		if err != nil {
+			log.Println("LoadLocation error: ", err)
+			return nil
+		}
+
+		post := func(msg string) {
+			platform.SendMessage(channel, "", msg)
+		}
+		sim := &tinabot.Simulation{
+			Brain: brain,
+			Post:  post,
+			Pin: func(menu tuttobene.Menu) error {
+				return tinabot.PinMenu(platform, brain, ws.BotID, channel, menu)
+			},
+			Unpin: func() error {
+				return tinabot.UnpinMenus(platform, brain, ws.BotID, channel, true)
+			},
+		}
+
+		step := 10 * time.Minute
+		y, m, d := time.Now().In(loc).Date()
+		day := time.Date(y, m, d, 0, 0, 0, 0, loc)
+		steps := int(24 * time.Hour / step)
+		tick := duration / time.Duration(steps)
+
+		post(fmt.Sprintf("Inizio simulazione della giornata del %s (%d cron impostati)", day.Format("02/01/2006"), len(sched)))
+		// on the days without lunch only the cleanup is done, see runCron
+		closed, reason := sim.Closed(day)
+		if closed {
+			post("Oggi non si ordina il pranzo, verranno eseguiti solo i cron di pulizia: " + reason)
+			sim.ClearOldOrder(day)
+		} else {
+			sim.PublishMenu(day)
+		}
+
+		for i := 0; i < steps; i++ {
+			now := day.Add(time.Duration(i) * step)
+			for _, e := range dueCrons(sched, now, step) {
+				if closed && !cleanupTasks[strings.Split(e.Cmd, " ")[0]] {
+					continue
+				}
+				post(fmt.Sprintf("[%s] cron #%d - `%s`", now.Format("15:04"), e.Index, e.Cmd))
+				sim.Task(now, e.Cmd)
+			}
+			// the reminders are sent at each run of the cron
+			if !closed {
+				sim.Reminders(now, step)
+			}
+			time.Sleep(tick)
+		}
+
+		post("Fine simulazione")
+		return nil
+	})
+})
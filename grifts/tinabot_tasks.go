@@ -1,7 +1,6 @@
 package grifts
 
 import (
-	"context"
 	"fmt"
 	"log"
 	"os"
@@ -14,362 +13,861 @@ import (
 	"github.com/develersrl/lunches/pkg/tinabot"
 
 	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/events"
+	"github.com/develersrl/lunches/pkg/mailer"
 	"github.com/go-redis/redis"
-	"github.com/mailgun/mailgun-go/v3"
 	. "github.com/markbates/grift/grift"
-	"github.com/nlopes/slack"
 	"github.com/robfig/cron"
 )
 
-var _ = Namespace("tinabot", func() {
-
-	Desc("cron", "Execute scheduled tasks")
-	Add("cron", func(c *Context) error {
-		redisURL := os.Getenv("REDIS_URL")
-		if redisURL == "" {
-			log.Fatalln("No redis URL found!")
+type cronEntry struct {
+	Index int
+	Cmd   string
+}
+
+// dueCrons returns the scheduled entries that fall within the interval
+// centered on now, in schedule order.
+func dueCrons(sched []string, now time.Time, interval time.Duration) []cronEntry {
+	var due []cronEntry
+	for i, s := range sched {
+		r := strings.SplitN(s, ";", 2)
+		if len(r) < 2 {
+			log.Println("Malformed cron string: " + s)
+			continue
 		}
+		sch, err := cron.ParseStandard(r[0])
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		start := now.Add(-interval / 2)
+		next := sch.Next(start)
 
-		timerInterval := 10 * time.Minute
-		interval := os.Getenv("INTERVAL_MINUTES")
-		if interval != "" {
-			n, err := strconv.Atoi(interval)
-			if err != nil {
-				timerInterval = time.Duration(n) * time.Minute
-			}
+		if start.Add(interval).Sub(next) > 0 {
+			due = append(due, cronEntry{i, strings.TrimSpace(r[1])})
+		}
+	}
+	return due
+}
+
+// cleanupTasks are the cron tasks run also on the days without lunch, to
+// tidy up after the previous ones
+var cleanupTasks = map[string]bool{
+	"unpinmenu": true,
+}
+
+// cronInterval returns how often the cron task is run, INTERVAL_MINUTES or
+// 10 minutes by default
+func cronInterval() time.Duration {
+	timerInterval := 10 * time.Minute
+	interval := os.Getenv("INTERVAL_MINUTES")
+	if interval != "" {
+		n, err := strconv.Atoi(interval)
+		if err == nil {
+			timerInterval = time.Duration(n) * time.Minute
 		}
+	}
+	return timerInterval
+}
+
+// runReminders sends the reminders to the users who haven't ordered yet: to
+// everyone with the reminder active today if force, otherwise only the ones
+// due in the interval according to the reminder settings, posting also the
+// countdowns on the channel.
+func runReminders(ws workspace, force bool, interval time.Duration) error {
+	brain := ws.Brain
+
+	if closed, _ := tinabot.IsClosedToday(brain); closed {
+		return nil
+	}
 
-		brain := brain.New(redisURL)
-		defer brain.Close()
+	loc, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		log.Println("LoadLocation error: ", err)
+		return nil
+	}
+	now := time.Now().In(loc)
+
+	dmDue := force
+	var countdowns []int
+	var settings tinabot.ReminderSettings
+	if !force {
+		settings.Load(brain)
+		dmDue, countdowns = settings.Due(now, interval)
+	}
+
+	var remind map[string]int
+	brain.Get("remind", &remind)
+	var snoozes tinabot.Snoozes
+	snoozes.Load(brain)
+	users := tinabot.ReminderRecipients(remind, snoozes, dmDue, now, interval)
+	if len(users) == 0 && len(countdowns) == 0 {
+		return nil
+	}
 
-		var sched []string
-		err := brain.Get("cron", &sched)
-		if err == redis.Nil || len(sched) == 0 {
-			log.Println("No cron set")
-			return nil
-		}
+	var order tinabot.Order
+	order.Load(brain)
+
+	var menu tuttobene.Menu
+	err = brain.Get("menu", &menu)
+	if err == redis.Nil {
+		log.Println("No menu found")
+		return nil
+	}
+
+	if !menu.IsUpdated() || !order.IsUpdated() || order.State != tinabot.Open {
+		return nil
+	}
+
+	platform, err := ws.platform()
+	if err != nil {
+		return err
+	}
 
-		loc, err := time.LoadLocation("Europe/Rome")
+	for _, m := range countdowns {
+		txt := settings.Countdown(m, len(order.Users))
+		platform.SendMessage(settings.Channel, tinabot.MenuThread(brain, settings.Channel), txt)
+	}
+
+	fmtmsg := "Ciao %s, scusa il disturbo. Vedo che non hai ancora ordinato il pranzo e mi hai chiesto di ricordartelo. Ecco il menù di oggi:\n" + menu.String()
+	for _, userid := range users {
+		user, err := platform.UserInfo(userid)
 		if err != nil {
-			log.Println("LoadLocation error: ", err)
-			return nil
+			log.Println(err)
+			continue
 		}
 
-		for i, s := range sched {
-			r := strings.SplitN(s, ";", 2)
-			if len(r) < 2 {
-				log.Println("Malformed cron string: " + s)
-				continue
-			}
-			sch, err := cron.ParseStandard(r[0])
+		if !order.Ordered(tinabot.User{Name: user.Name, ID: user.ID}) {
+			log.Printf("Sending reminder to %s\n", user.Name)
+			ch, err := platform.DirectChannel(user.ID)
 			if err != nil {
 				log.Println(err)
 				continue
 			}
-			txt := strings.TrimSpace(r[1])
-			now := time.Now().In(loc)
-			now = now.Add(-timerInterval / 2)
-			next := sch.Next(now)
-
-			if now.Add(timerInterval).Sub(next) > 0 {
-				log.Printf("Executing cron #%d - %s", i, s)
-
-				args := strings.Split(txt, " ")
-				if len(args) < 1 {
-					log.Println("No task specified!")
-					continue
-				}
-				task := "tinabot:" + args[0]
-				ctx := NewContext(task)
-				ctx.Args = args[1:]
-				err := Run(task, ctx)
-				if err != nil {
-					log.Println(err)
-				}
-			}
+
+			txt := fmt.Sprintf(fmtmsg, user.Name)
+			platform.SendMessage(ch, "", txt)
 		}
+	}
+	return nil
+}
+
+// runMenuRequests sends the menu requests due in the interval centered on now
+func runMenuRequests(ws workspace, interval time.Duration) error {
+	brain := ws.Brain
+
+	loc, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		log.Println("LoadLocation error: ", err)
 		return nil
-	})
+	}
+	now := time.Now().In(loc)
 
-	Desc("post", "post on slack. Usage: post <channel> [<options>] <message>")
-	Add("post", func(c *Context) error {
-		token := os.Getenv("SLACK_BOT_TOKEN")
-		if token == "" {
-			log.Fatalln("No slackbot token found!")
-		}
-
-		if len(c.Args) < 2 {
-			log.Fatalln("Not enough arguments, usage: post <channel> [<options>] <message>")
-		}
-		channel := c.Args[0]
-		onlyValidOrder := false
-		onlyValidMenu := false
-		startMsg := 1
-		for i := 0; i < len(c.Args); i++ {
-			opt := c.Args[i]
-			if strings.HasPrefix(opt, "-") {
-				startMsg = i + 1
-				// Post only if there is a valid order
-				if strings.Contains(opt, "o") {
-					onlyValidOrder = true
-				}
+	due := tinabot.DueMenuRequests(brain, now, interval)
+	if len(due) == 0 {
+		return nil
+	}
 
-				// Post only if there is a valid menu
-				if strings.Contains(opt, "m") {
-					onlyValidMenu = true
-				}
-			}
-		}
+	platform, err := ws.platform()
+	if err != nil {
+		return err
+	}
 
-		redisURL := os.Getenv("REDIS_URL")
-		if redisURL == "" {
-			log.Fatalln("No redis URL found!")
+	for channel, r := range due {
+		if r.Email == "" {
+			platform.SendMessage(channel, "", tinabot.MenuRequestReminder(brain, channel, r, now))
+			continue
 		}
 
-		brain := brain.New(redisURL)
-		defer brain.Close()
+		log.Printf("Requesting the menu to %s for %s", r.Email, channel)
+		m, err := mailer.New()
+		if err == nil {
+			err = m.Send("cibo@develer.com", []string{r.Email}, "Menù del "+now.Format("02/01/2006"), r.Text(now))
+		}
+		if err != nil {
+			log.Println(err)
+			platform.SendMessage(channel, "", fmt.Sprintf("Non sono riuscito a chiedere il menù a %s: %v", r.Email, err))
+			continue
+		}
+		platform.SendMessage(channel, "", "Ho chiesto il menù di oggi a "+r.Email)
+	}
+	return nil
+}
+
+// runDigest sends the emails of the digest due now to its addresses
+func runDigest(ws workspace) error {
+	brain := ws.Brain
+
+	loc, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		log.Println("LoadLocation error: ", err)
+		return nil
+	}
+	now := time.Now().In(loc)
 
-		var order tinabot.Order
-		order.Load(brain)
+	var d tinabot.Digest
+	d.Load(brain)
+	emails := d.Due(brain, now)
+	if len(emails) == 0 {
+		return nil
+	}
 
-		var menu tuttobene.Menu
-		err := brain.Get("menu", &menu)
-		if err == redis.Nil {
-			log.Println("No menu found")
+	m, err := mailer.New()
+	if err != nil {
+		return err
+	}
+	for _, e := range emails {
+		log.Printf("Sending the %s digest to %s", e.Kind, strings.Join(d.Addresses, ", "))
+		if err := m.Send("cibo@develer.com", d.Addresses, e.Subject, e.Body); err != nil {
+			log.Println(err)
+			continue
 		}
+		d.Sent(e.Kind, now)
+	}
+	return d.Save(brain)
+}
+
+// runAccounting emails the report of the lunches of the previous month to
+// the addresses of the accounting, once the month is over
+func runAccounting(ws workspace) error {
+	brain := ws.Brain
+
+	loc, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		log.Println("LoadLocation error: ", err)
+		return nil
+	}
 
-		if onlyValidMenu && (err == redis.Nil || !menu.IsUpdated()) {
-			return nil
-		}
+	var a tinabot.Accounting
+	a.Load(brain)
+	month, due := a.Due(time.Now().In(loc))
+	if !due {
+		return nil
+	}
+	report, n, err := a.Report(brain, month)
+	if err != nil {
+		return err
+	}
 
-		if onlyValidOrder && !order.IsUpdated() {
-			return nil
-		}
+	m, err := mailer.New()
+	if err != nil {
+		return err
+	}
+	name := "pranzi-" + month.Format("2006-01") + ".csv"
+	body := fmt.Sprintf("In allegato i pranzi di %s, %d righe.", month.Format("01/2006"), n)
+	log.Printf("Sending the accounting report of %s to %s", month.Format("2006-01"), strings.Join(a.Addresses, ", "))
+	err = mailer.SendFiles(m, "cibo@develer.com", a.Addresses, "Pranzi di "+month.Format("01/2006"), body,
+		mailer.Attachment{Name: name, ContentType: "text/csv", Data: report})
+	if err != nil {
+		return err
+	}
+	a.Sent = month.Format("2006-01")
+	return a.Save(brain)
+}
+
+// runReport posts the report of the spending of the previous week on the
+// channel of the report settings, on Monday morning
+func runReport(ws workspace) error {
+	brain := ws.Brain
+
+	loc, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		log.Println("LoadLocation error: ", err)
+		return nil
+	}
 
-		msg := strings.Join(c.Args[startMsg:], " ")
-		msg = strings.Replace(msg, "$MENU", menu.String(), -1)
-		msg = strings.Replace(msg, "$ORDER_NONAMES", order.Format(false, false), -1)
-		msg = strings.Replace(msg, "$ORDER", order.Format(true, false), -1)
-		msg = strings.Replace(msg, "$BILL", order.Format(true, true), -1)
-		msg = strings.Replace(msg, "$BILL_NONAMES", order.Format(false, true), -1)
-		msg = strings.Replace(msg, "\\n", "\n", -1)
+	var s tinabot.ReportSettings
+	s.Load(brain)
+	week, due := s.Due(time.Now().In(loc))
+	if !due {
+		return nil
+	}
+	r, err := tinabot.Spending(brain, week)
+	if err != nil {
+		return err
+	}
 
-		api := slack.New(token)
-		api.PostMessage(channel, slack.MsgOptionText(msg, false))
+	platform, err := ws.platform()
+	if err != nil {
+		return err
+	}
+	var p tinabot.Privacy
+	p.Load(brain)
+	log.Printf("Posting the report of the week of %s on %s", week.Start.Format("2006-01-02"), s.Channel)
+	if _, err := platform.SendMessage(s.Channel, "", tinabot.FormatSpending(r, !p.Anonymous)); err != nil {
+		return err
+	}
+	s.Sent = week.Start.Format("2006-01-02")
+	return s.Save(brain)
+}
+
+// runNutrition sends the users who asked for them the nutrition estimates
+// of the previous week, on Monday morning
+func runNutrition(ws workspace) error {
+	brain := ws.Brain
+
+	loc, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		log.Println("LoadLocation error: ", err)
 		return nil
-	})
+	}
 
-	Desc("sendmail", "send the email of the lunch order to the given address(es)")
-	Add("sendmail", func(c *Context) error {
-		domain := os.Getenv("MAILGUN_DOMAIN")
-		if domain == "" {
-			log.Println("MAILGUN_DOMAIN not set")
-			return nil
+	platform, err := ws.platform()
+	if err != nil {
+		return err
+	}
+	return tinabot.SendNutritionDigests(brain, time.Now().In(loc), func(userID, text string) error {
+		log.Printf("Sending the nutrition estimates to %s", userID)
+		ch, err := platform.DirectChannel(userID)
+		if err != nil {
+			return err
 		}
+		_, err = platform.SendMessage(ch, "", text)
+		return err
+	})
+}
 
-		apiKey := os.Getenv("MAILGUN_API_KEY")
-		if apiKey == "" {
-			log.Println("MAILGUN_API_KEY not set")
-			return nil
-		}
+// runLeaderboard posts the leaderboard of the previous month on the channel
+// of the leaderboard settings, on the first day of the month
+func runLeaderboard(ws workspace) error {
+	brain := ws.Brain
 
-		redisURL := os.Getenv("REDIS_URL")
-		if redisURL == "" {
-			log.Fatalln("No redis URL found!")
-		}
+	loc, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		log.Println("LoadLocation error: ", err)
+		return nil
+	}
 
-		brain := brain.New(redisURL)
-		defer brain.Close()
+	var s tinabot.LeaderboardSettings
+	s.Load(brain)
+	month, due := s.Due(time.Now().In(loc))
+	if !due {
+		return nil
+	}
+	p, err := tinabot.Participation(brain, month)
+	if err != nil {
+		return err
+	}
 
-		var order tinabot.Order
-		order.Load(brain)
+	platform, err := ws.platform()
+	if err != nil {
+		return err
+	}
+	log.Printf("Posting the leaderboard of %s on %s", month.Start.Format("2006-01"), s.Channel)
+	if _, err := platform.SendMessage(s.Channel, "", tinabot.FormatLeaderboard(p)); err != nil {
+		return err
+	}
+	s.Sent = month.Start.Format("2006-01")
+	return s.Save(brain)
+}
+
+// runPrices posts the price increases of the menus saved since the last run
+// on the channel of the price alerts, and the summary of the previous month
+// on its first day
+func runPrices(ws workspace) error {
+	brain := ws.Brain
+
+	loc, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		log.Println("LoadLocation error: ", err)
+		return nil
+	}
 
-		var menu tuttobene.Menu
-		err := brain.Get("menu", &menu)
-		if err == redis.Nil {
-			log.Println("No menu found")
-		}
+	var s tinabot.PriceAlerts
+	s.Load(brain)
+	if s.Channel == "" {
+		return nil
+	}
+	month, due := s.Due(time.Now().In(loc))
+	alert, err := tinabot.PendingPriceAlert(brain)
+	if err != nil {
+		return err
+	}
+	if alert == "" && !due {
+		return nil
+	}
 
-		if !menu.IsUpdated() || !order.IsUpdated() {
-			return nil
+	platform, err := ws.platform()
+	if err != nil {
+		return err
+	}
+	if alert != "" {
+		log.Printf("Posting the price increases on %s", s.Channel)
+		if _, err := platform.SendMessage(s.Channel, "", alert); err != nil {
+			return err
 		}
+	}
+	if !due {
+		return nil
+	}
+	log.Printf("Posting the price changes of %s on %s", month.Start.Format("2006-01"), s.Channel)
+	summary := tinabot.FormatInflation(tinabot.PriceChanges(brain, month), month, false)
+	if _, err := platform.SendMessage(s.Channel, "", summary); err != nil {
+		return err
+	}
+	s.Sent = month.Start.Format("2006-01")
+	return s.Save(brain)
+}
+
+// runRetention deletes the data older than the limits of the retention
+// settings, once a day
+func runRetention(ws workspace) error {
+	brain := ws.Brain
+
+	loc, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		log.Println("LoadLocation error: ", err)
+		return nil
+	}
+	now := time.Now().In(loc)
 
-		mg := mailgun.NewMailgun(domain, apiKey)
-		var addresses []string
-		sendBill := false
-		sendNames := false
-
-		for _, a := range c.Args {
-			switch a {
-			case "--bill":
-				sendBill = true
-			case "--names":
-				sendNames = true
-			default:
-				if strings.HasPrefix(a, "<mailto:") {
-					a = strings.TrimPrefix(a, "<mailto:")
-					a = strings.Split(a, "|")[0]
-				}
-				addresses = append(addresses, a)
-			}
+	var s tinabot.RetentionSettings
+	s.Load(brain)
+	if !s.Due(now) {
+		return nil
+	}
+	n, err := s.Purge(brain, now)
+	if err != nil {
+		return err
+	}
+	log.Printf("Purged %d items older than the retention limits", n)
+	s.Purged = now.Format("2006-01-02")
+	return s.Save(brain)
+}
+
+// runCron runs the crons of the workspace due now, then the tasks run at
+// every cron, each only on the workspace
+func runCron(ws workspace) error {
+	brain := ws.Brain
+	timerInterval := cronInterval()
+
+	// the reminders, the menu requests and the digest are sent even without crons
+	var sched []string
+	err := brain.Get("cron", &sched)
+	if err == redis.Nil || len(sched) == 0 {
+		log.Printf("No cron set in the workspace %s", ws)
+	}
+
+	loc, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		log.Println("LoadLocation error: ", err)
+		return nil
+	}
+
+	run := func(task string, args []string) error {
+		ctx := NewContext(task)
+		ctx.Args = args
+		ctx.Set(teamKey, ws.Team)
+		return Run(task, ctx)
+	}
+
+	// on the days without lunch only the cleanup is done
+	closed, reason := tinabot.IsClosedToday(brain)
+	if closed {
+		log.Println("No lunch today, running only the cleanup crons: ", reason)
+		if cleared, err := tinabot.ClearOldOrder(brain); err != nil {
+			log.Println("Error clearing the old order: ", err)
+		} else if cleared {
+			log.Println("Cleared the order of the previous day")
 		}
+	}
 
-		if len(addresses) < 1 {
-			log.Println("No recipients found!")
-			return nil
+	for _, e := range dueCrons(sched, time.Now().In(loc), timerInterval) {
+		args := strings.Split(e.Cmd, " ")
+		if len(args) < 1 {
+			log.Println("No task specified!")
+			continue
+		}
+		if closed && !cleanupTasks[args[0]] {
+			continue
 		}
+		log.Printf("Executing cron #%d - %s", e.Index, sched[e.Index])
 
-		to := strings.Join(addresses, ",")
+		if err := run("tinabot:"+args[0], args[1:]); err != nil {
+			log.Println(err)
+		}
+	}
 
-		subj := "Ordine Develer del giorno " + order.Timestamp.Format("02/01/2006")
-		from := "cibo@develer.com"
-		body := order.Format(sendNames, sendBill)
-		m := mg.NewMessage(from, subj, body, to)
+	if closed {
+		return nil
+	}
+	for _, task := range everyCron {
+		if err := run(task, nil); err != nil {
+			log.Println(err)
+		}
+	}
+	return nil
+}
+
+// everyCron are the tasks run at every cron, they do something only when
+// due according to their settings
+var everyCron = []string{
+	"tinabot:reminders",
+	"tinabot:menurequests",
+	"tinabot:polls",
+	"tinabot:digest",
+	"tinabot:accounting",
+	"tinabot:report",
+	"tinabot:nutrition",
+	"tinabot:leaderboard",
+	"tinabot:prices",
+	"tinabot:retention",
+}
+
+// runSendmail sends the email of the order of the workspace, see the
+// sendmail task
+func runSendmail(ws workspace, args []string) error {
+	brain := ws.Brain
+
+	var order tinabot.Order
+	order.Load(brain)
+
+	var menu tuttobene.Menu
+	err := brain.Get("menu", &menu)
+	if err == redis.Nil {
+		log.Println("No menu found")
+	}
+
+	if !menu.IsUpdated() || !order.IsUpdated() {
+		return nil
+	}
+
+	var addresses []string
+	sendBill := false
+	sendNames := false
+	dryRun := false
+
+	for _, a := range args {
+		switch a {
+		case "--bill":
+			sendBill = true
+		case "--names":
+			sendNames = true
+		case "--dry-run":
+			dryRun = true
+		default:
+			if strings.HasPrefix(a, "<mailto:") {
+				a = strings.TrimPrefix(a, "<mailto:")
+				a = strings.Split(a, "|")[0]
+			}
+			addresses = append(addresses, a)
+		}
+	}
 
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
-		defer cancel()
+	if len(addresses) < 1 {
+		log.Println("No recipients found!")
+		return nil
+	}
+
+	// the channel doesn't show who ordered what, the restaurant needs it
+	// to label the dishes
+	var privacy tinabot.Privacy
+	privacy.Load(brain)
+	if privacy.Anonymous {
+		sendNames = true
+	}
+
+	var m mailer.Mailer = mailer.DryRun{}
+	if !dryRun {
+		m, err = mailer.New()
+		if err != nil {
+			log.Println(err)
+			return nil
+		}
+	}
 
-		_, id, err := mg.Send(ctx, m)
-		log.Println("Sendmail ID", id)
+	subj, body := order.RestaurantEmail(tinabot.LoadRestaurantInfo(brain), sendNames, sendBill)
+	if err := m.Send("cibo@develer.com", addresses, subj, body); err != nil {
 		return err
-	})
+	}
+	if dryRun {
+		return nil
+	}
 
-	Desc("reminder", "send the users the reminder to order")
-	Add("reminder", func(c *Context) error {
-		redisURL := os.Getenv("REDIS_URL")
-		if redisURL == "" {
-			log.Fatalln("No redis URL found!")
+	err = order.SaveCAS(brain, func(o *tinabot.Order) error {
+		if o.State == tinabot.Open {
+			o.Transition(tinabot.Locked)
 		}
-
-		brain := brain.New(redisURL)
-		defer brain.Close()
-
-		var remind map[string]int
-		err := brain.Get("remind", &remind)
-		if err == redis.Nil || len(remind) == 0 {
-			return nil
+		return o.Transition(tinabot.Sent)
+	})
+	if err != nil {
+		log.Println("Error updating order state: ", err)
+		return nil
+	}
+	if ps, ok := brain.(events.Broadcaster); ok {
+		if err := events.Send(ps, events.OrderUpdated, &order); err != nil {
+			log.Println("Error sending the order update: ", err)
 		}
+	}
+	return nil
+}
+
+// runPolls closes the poll of the workspace when its closing time has
+// passed
+func runPolls(ws workspace) error {
+	brain := ws.Brain
+
+	loc, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		log.Println("LoadLocation error: ", err)
+		return nil
+	}
 
-		var order tinabot.Order
-		order.Load(brain)
+	p, closed, err := tinabot.ClosePoll(brain, time.Now().In(loc), false)
+	if err != nil {
+		return err
+	}
+	if !closed {
+		return nil
+	}
 
-		var menu tuttobene.Menu
-		err = brain.Get("menu", &menu)
-		if err == redis.Nil {
-			log.Println("No menu found")
-			return nil
-		}
+	platform, err := ws.platform()
+	if err != nil {
+		return err
+	}
+	log.Printf("Closing the poll on %s", p.Channel)
+	tinabot.PublishPollResult(platform, p)
+	return nil
+}
 
-		if !menu.IsUpdated() || !order.IsUpdated() {
-			return nil
-		}
+// runMark marks the lunches of the order of the workspace on the sheet,
+// telling each user
+func runMark(ws workspace) error {
+	brain := ws.Brain
 
-		token := os.Getenv("SLACK_BOT_TOKEN")
-		if token == "" {
-			log.Fatalln("No slackbot token found!")
-		}
-		api := slack.New(token)
+	var order tinabot.Order
+	order.Load(brain)
 
-		loc, err := time.LoadLocation("Europe/Rome")
-		if err != nil {
-			log.Println("LoadLocation error: ", err)
-			return nil
-		}
+	if !order.IsUpdated() {
+		return nil
+	}
 
-		weekmask := 1 << uint(time.Now().In(loc).Weekday())
+	bus := events.New()
+	tinabot.SubscribeHistory(bus, brain)
+	bus.Publish(events.OrderClosed, &order)
+
+	platform, err := ws.platform()
+	if err != nil {
+		return err
+	}
+	users, err := platform.Users()
 
-		fmtmsg := "Ciao %s, scusa il disturbo. Vedo che non hai ancora ordinato il pranzo e mi hai chiesto di ricordartelo. Ecco il menù di oggi:\n" + menu.String()
-		for userid, v := range remind {
-			if v&weekmask != 0 {
-				user, err := api.GetUserInfo(userid)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	log.Printf("Today we have %d users for lunch\n", len(order.Users))
+	for u, v := range order.Users {
+		found := false
+		log.Printf("Marking lunch for user %s - ID [%s]\n", u.Name, u.ID)
+		for _, user := range users {
+			if user.ID == u.ID {
+				log.Printf("User %s found!\n", u.Name)
+				ch, err := platform.DirectChannel(user.ID)
 				if err != nil {
 					log.Println(err)
-					continue
+					break
 				}
+				log.Printf("Got channel ID [%s]\n", ch)
 
-				if _, ok := order.Users[tinabot.User{Name: user.Name, ID: user.ID}]; !ok {
-					log.Printf("Sending reminder to %s\n", user.Name)
-					_, _, ch, err := api.OpenIMChannel(user.ID)
-					if err != nil {
-						log.Println(err)
-						continue
-					}
+				txt := fmt.Sprintf("Ciao %s, oggi hai ordinato:\n%s\n-------\n", user.Name, v.String())
 
-					txt := fmt.Sprintf(fmtmsg, user.Name)
-					api.PostMessage(ch, slack.MsgOptionText(txt, false))
+				log.Printf("Calling mark function for user %s...\n", u.Name)
+				err = tinabot.MarkUser(&user, v.Mark())
+				if err != nil {
+					log.Printf("ERROR marking user %s: %s\n", u.Name, err.Error())
+					txt = txt + fmt.Sprintf("C'è stato un errore nel segnare il pranzo: %s.", err.Error())
+				} else {
+					log.Printf("Marking user %s: %s\n", u.Name, v.Mark())
+					txt = txt + fmt.Sprintf("Ho segnato `%s` sul foglio dei pranzi.\nSe non fosse corretto, usa il comando `segna` per modificarlo.", v.Mark())
 				}
+
+				platform.SendMessage(ch, "", txt)
+				found = true
+				break
 			}
 		}
+		if !found {
+			log.Printf("WARN:user %s - ID [%s] not found, lunch not marked.\n", u.Name, u.ID)
+		}
+	}
 
-		return nil
+	log.Printf("Marking lunch fineshed correctly\n")
+	return nil
+}
+
+var _ = Namespace("tinabot", func() {
+
+	Desc("cron", "Execute scheduled tasks on every workspace")
+	Add("cron", func(c *Context) error {
+		return forEachWorkspace(c, runCron)
 	})
 
-	Desc("mark", "mark the lunch on the spreadsheet")
-	Add("mark", func(c *Context) error {
-		redisURL := os.Getenv("REDIS_URL")
-		if redisURL == "" {
-			log.Fatalln("No redis URL found!")
-		}
+	Desc("post", "post on slack. Usage: post <channel> [<options>] <message>")
+	Add("post", func(c *Context) error {
+		return forEachWorkspace(c, func(ws workspace) error {
+			channel, msg, ok, err := tinabot.CronPost(ws.Brain, c.Args)
+			if err != nil || !ok {
+				return err
+			}
+			platform, err := ws.platform()
+			if err != nil {
+				return err
+			}
+			_, err = platform.SendMessage(channel, tinabot.MenuThread(ws.Brain, channel), msg)
+			return err
+		})
+	})
 
-		brain := brain.New(redisURL)
-		defer brain.Close()
+	Desc("unpinmenu", "remove the outdated menus pinned on the channel. Usage: unpinmenu <channel>")
+	Add("unpinmenu", func(c *Context) error {
+		if len(c.Args) < 1 {
+			log.Fatalln("Not enough arguments, usage: unpinmenu <channel>")
+		}
+		return forEachWorkspace(c, func(ws workspace) error {
+			platform, err := ws.platform()
+			if err != nil {
+				return err
+			}
+			return tinabot.UnpinMenus(platform, ws.Brain, ws.BotID, c.Args[0], true)
+		})
+	})
 
-		var order tinabot.Order
-		order.Load(brain)
+	Desc("offices", "list the offices with the namespace of their brain, to run the other tasks on an office with BRAIN_URL \"...?namespace=<namespace>\"")
+	Add("offices", func(c *Context) error {
+		brainURL := brain.URLFromEnv()
+		if brainURL == "" {
+			log.Fatalln("No brain URL found!")
+		}
 
-		if !order.IsUpdated() {
-			return nil
+		brain, err := brain.Open(brainURL)
+		if err != nil {
+			log.Fatalln(err)
 		}
+		defer brain.Close()
 
-		token := os.Getenv("SLACK_BOT_TOKEN")
-		if token == "" {
-			log.Fatalln("No slackbot token found!")
+		ids, err := tinabot.Offices(brain)
+		if err != nil {
+			return err
 		}
-		api := slack.New(token)
+		for _, id := range ids {
+			fmt.Printf("%s\t%s\n", id, tinabot.OfficeNamespace(id))
+		}
+		return nil
+	})
+
+	Desc("sendmail", "send the email of the lunch order to the given address(es). Usage: sendmail [--bill] [--names] [--dry-run] <address>...")
+	Add("sendmail", func(c *Context) error {
+		return forEachWorkspace(c, func(ws workspace) error {
+			return runSendmail(ws, c.Args)
+		})
+	})
 
-		users, err := api.GetUsers()
+	Desc("reminder", "send the users the reminder to order")
+	Add("reminder", func(c *Context) error {
+		return forEachWorkspace(c, func(ws workspace) error {
+			return runReminders(ws, true, 0)
+		})
+	})
+
+	Desc("reminders", "send the reminders and the countdowns due now, according to the promemoria settings")
+	Add("reminders", func(c *Context) error {
+		return forEachWorkspace(c, func(ws workspace) error {
+			return runReminders(ws, false, cronInterval())
+		})
+	})
+
+	Desc("menurequests", "ask the restaurant for the menu, or remind the admins to, according to the richiesta menu settings of each channel")
+	Add("menurequests", func(c *Context) error {
+		return forEachWorkspace(c, func(ws workspace) error {
+			return runMenuRequests(ws, cronInterval())
+		})
+	})
 
+	Desc("digest", "email today's menu and the summary of the order to the addresses of the digest, when due")
+	Add("digest", func(c *Context) error {
+		return forEachWorkspace(c, func(ws workspace) error {
+			return runDigest(ws)
+		})
+	})
+
+	Desc("accounting", "email the report of the lunches of the previous month to the addresses of the accounting, when due; with <aaaa-mm> print the report of that month")
+	Add("accounting", func(c *Context) error {
+		if len(c.Args) == 0 {
+			return forEachWorkspace(c, func(ws workspace) error {
+				return runAccounting(ws)
+			})
+		}
+		month, err := time.Parse("2006-01", c.Args[0])
 		if err != nil {
-			log.Println(err)
-			return nil
+			return fmt.Errorf("invalid month %q, use aaaa-mm", c.Args[0])
 		}
-		log.Printf("Today we have %d users for lunch\n", len(order.Users))
-		for u, v := range order.Users {
-			found := false
-			log.Printf("Marking lunch for user %s - ID [%s]\n", u.Name, u.ID)
-			for _, user := range users {
-				if user.ID == u.ID {
-					log.Printf("User %s found!\n", u.Name)
-					_, _, ch, err := api.OpenIMChannel(user.ID)
-					if err != nil {
-						log.Println(err)
-						break
-					}
-					log.Printf("Got channel ID [%s]\n", ch)
-
-					txt := fmt.Sprintf("Ciao %s, oggi hai ordinato:\n%s\n-------\n", user.Name, v.String())
-
-					log.Printf("Calling mark function for user %s...\n", u.Name)
-					err = tinabot.MarkUser(&user, v.Mark())
-					if err != nil {
-						log.Printf("ERROR marking user %s: %s\n", u.Name, err.Error())
-						txt = txt + fmt.Sprintf("C'è stato un errore nel segnare il pranzo: %s.", err.Error())
-					} else {
-						log.Printf("Marking user %s: %s\n", u.Name, v.Mark())
-						txt = txt + fmt.Sprintf("Ho segnato `%s` sul foglio dei pranzi.\nSe non fosse corretto, usa il comando `segna` per modificarlo.", v.Mark())
-					}
-
-					api.PostMessage(ch, slack.MsgOptionText(txt, false))
-					found = true
-					break
-				}
-			}
-			if !found {
-				log.Printf("WARN:user %s - ID [%s] not found, lunch not marked.\n", u.Name, u.ID)
-			}
+
+		brainURL := brain.URLFromEnv()
+		if brainURL == "" {
+			log.Fatalln("No brain URL found!")
 		}
+		brain, err := brain.Open(brainURL)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer brain.Close()
 
-		log.Printf("Marking lunch fineshed correctly\n")
-		return nil
+		var a tinabot.Accounting
+		a.Load(brain)
+		rows, err := a.Rows(brain, month)
+		if err != nil {
+			return err
+		}
+		return a.WriteCSV(os.Stdout, rows)
+	})
+
+	Desc("report", "post the report of the spending of the previous week on the channel of the report, on Monday morning")
+	Add("report", func(c *Context) error {
+		return forEachWorkspace(c, func(ws workspace) error {
+			return runReport(ws)
+		})
+	})
+
+	Desc("nutrition", "send the users who asked for them the nutrition estimates of the previous week, on Monday morning")
+	Add("nutrition", func(c *Context) error {
+		return forEachWorkspace(c, func(ws workspace) error {
+			return runNutrition(ws)
+		})
+	})
+
+	Desc("leaderboard", "post the leaderboard of the previous month on the channel of the leaderboard, on the first day of the month")
+	Add("leaderboard", func(c *Context) error {
+		return forEachWorkspace(c, func(ws workspace) error {
+			return runLeaderboard(ws)
+		})
+	})
+
+	Desc("prices", "post the price increases of the new menus on the channel of the price alerts, and the summary of the changes of the previous month on its first day")
+	Add("prices", func(c *Context) error {
+		return forEachWorkspace(c, func(ws workspace) error {
+			return runPrices(ws)
+		})
+	})
+
+	Desc("retention", "delete the history of the orders and the ratings older than the limits of the conservazione settings, once a day")
+	Add("retention", func(c *Context) error {
+		return forEachWorkspace(c, func(ws workspace) error {
+			return runRetention(ws)
+		})
+	})
+
+	Desc("polls", "close the poll on the restaurant of the day when its closing time has passed")
+	Add("polls", func(c *Context) error {
+		return forEachWorkspace(c, func(ws workspace) error {
+			return runPolls(ws)
+		})
+	})
+
+	Desc("mark", "mark the lunch on the spreadsheet")
+	Add("mark", func(c *Context) error {
+		return forEachWorkspace(c, func(ws workspace) error {
+			return runMark(ws)
+		})
 	})
 })
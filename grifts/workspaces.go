@@ -0,0 +1,138 @@
+package grifts
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/nlopes/slack"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tinabot"
+	. "github.com/markbates/grift/grift"
+)
+
+// teamKey is the key of the Context of the tasks run for a single
+// workspace, see forEachWorkspace
+const teamKey = "team"
+
+// errNoToken is returned by the tasks posting on Slack for the workspaces
+// without a bot token
+var errNoToken = errors.New("no slackbot token found")
+
+// workspace is a Slack workspace of the bot, with the brain of its state
+type workspace struct {
+	Team  string
+	Brain brain.Store
+	// Platform posts on the workspace, nil if it has no bot token
+	Platform *slackbot.Platform
+	BotID    string
+}
+
+func (ws workspace) String() string {
+	if ws.Team == "" {
+		return "default"
+	}
+	return ws.Team
+}
+
+// platform returns the platform of the workspace, errNoToken if it has none
+func (ws workspace) platform() (*slackbot.Platform, error) {
+	if ws.Platform == nil {
+		return nil, errNoToken
+	}
+	return ws.Platform, nil
+}
+
+// newWorkspace returns the workspace of team, whose state is in b
+func newWorkspace(inst *slackbot.Installations, b brain.Store, team string) workspace {
+	ws := workspace{Team: team, Brain: b}
+	token, botID, err := inst.Credentials(team)
+	if err != nil {
+		log.Printf("No bot token for the workspace %s: %v", ws, err)
+		return ws
+	}
+	ws.Platform = &slackbot.Platform{Client: slack.New(token)}
+	ws.BotID = botID
+	return ws
+}
+
+// workspaces returns the workspaces of the bot: the one configured by hand,
+// see slackbot.LegacyTeam, on root and the ones which installed the bot
+// through OAuth on their namespace of root. If c was run for a workspace,
+// only that one is returned.
+func workspaces(root brain.Store, c *Context) ([]workspace, error) {
+	inst, err := slackbot.InstallationsFromEnv(root)
+	if err != nil {
+		return nil, err
+	}
+	teams, err := inst.Teams()
+	if err != nil {
+		return nil, err
+	}
+
+	legacy := slackbot.LegacyTeam()
+	only, single := c.Value(teamKey).(string)
+	var wss []workspace
+	if !single || only == legacy {
+		wss = append(wss, newWorkspace(inst, root, legacy))
+	}
+	for _, team := range teams {
+		if team == legacy || (single && team != only) {
+			continue
+		}
+		wss = append(wss, newWorkspace(inst, brain.Namespace(root, tinabot.TeamNamespace(team)), team))
+	}
+	if single && len(wss) == 0 {
+		return nil, fmt.Errorf("workspace %s not installed", only)
+	}
+	return wss, nil
+}
+
+// workspaceOf returns the workspace of team, see workspaces
+func workspaceOf(root brain.Store, team string) (workspace, error) {
+	c := NewContext("")
+	c.Set(teamKey, team)
+	wss, err := workspaces(root, c)
+	if err != nil {
+		return workspace{}, err
+	}
+	return wss[0], nil
+}
+
+// openRoot opens the root brain, the one of BRAIN_URL
+func openRoot() brain.Store {
+	brainURL := brain.URLFromEnv()
+	if brainURL == "" {
+		log.Fatalln("No brain URL found!")
+	}
+	root, err := brain.Open(brainURL)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return root
+}
+
+// forEachWorkspace runs fn on each workspace of the bot, see workspaces.
+// The errors of a workspace don't stop the others, the first one is
+// returned.
+func forEachWorkspace(c *Context, fn func(ws workspace) error) error {
+	root := openRoot()
+	defer root.Close()
+
+	wss, err := workspaces(root, c)
+	if err != nil {
+		return err
+	}
+	var first error
+	for _, ws := range wss {
+		if err := fn(ws); err != nil {
+			log.Printf("Error in the workspace %s: %v", ws, err)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
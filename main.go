@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"log"
 
 	"github.com/develersrl/lunches/actions"
+	"github.com/develersrl/lunches/pkg/sentry"
+	"github.com/develersrl/lunches/pkg/tracing"
 )
 
 // main is the starting point for your Buffalo application.
@@ -14,7 +17,22 @@ import (
 // application that is. :)
 func main() {
 	app := actions.App()
-	if err := app.Serve(); err != nil {
+	actions.StartSocketMode()
+	actions.StartJobs()
+	actions.StartRPC()
+	err := app.Serve()
+
+	// Serve returns on SIGTERM, without waiting for the requests being
+	// handled: wait for them and for the Socket Mode events, not to drop the
+	// orders being placed during a deploy
+	ctx, cancel := context.WithTimeout(context.Background(), actions.ShutdownTimeout())
+	defer cancel()
+	if err := actions.Shutdown(ctx); err != nil {
+		log.Println("Shutdown:", err)
+	}
+	tracing.Shutdown(ctx)
+	sentry.Flush(ctx)
+	if err != nil {
 		log.Fatal(err)
 	}
 }
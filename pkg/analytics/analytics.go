@@ -0,0 +1,156 @@
+// Package analytics computes the spending on the lunches over a period: the
+// totals of each user and team, the average price of an order and the most
+// expensive days
+package analytics
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Lunch is the order of a user in a day
+type Lunch struct {
+	Date   time.Time
+	User   string // the key of the user
+	Name   string
+	Team   string // empty if the user is in no team
+	Amount decimal.Decimal
+}
+
+// Period is a span of days, from Start included to End excluded
+type Period struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Week returns the week of t, from Monday
+func Week(t time.Time) Period {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	start := day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	return Period{start, start.AddDate(0, 0, 7)}
+}
+
+// Month returns the month of t
+func Month(t time.Time) Period {
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return Period{start, start.AddDate(0, 1, 0)}
+}
+
+// Contains tells if t is in the period
+func (p Period) Contains(t time.Time) bool {
+	return !t.Before(p.Start) && t.Before(p.End)
+}
+
+// Spend is the spending of a user or a team
+type Spend struct {
+	Key    string
+	Name   string
+	Total  decimal.Decimal
+	Orders int
+}
+
+// Average returns the average price of an order
+func (s Spend) Average() decimal.Decimal {
+	if s.Orders == 0 {
+		return decimal.Zero
+	}
+	return s.Total.Div(decimal.New(int64(s.Orders), 0)).Round(2)
+}
+
+// Day is the spending of all the users in a day
+type Day struct {
+	Date   time.Time
+	Total  decimal.Decimal
+	Orders int
+}
+
+// Report is the spending in a period
+type Report struct {
+	Period
+	Spend
+	// Users and Teams are sorted by total, the highest first
+	Users []Spend
+	Teams []Spend
+	// Days are the days with some order, the most expensive first
+	Days []Day
+}
+
+// New returns the report of the lunches in the period, the others are
+// ignored
+func New(lunches []Lunch, p Period) Report {
+	r := Report{Period: p}
+	users := make(map[string]*Spend)
+	teams := make(map[string]*Spend)
+	days := make(map[string]*Day)
+	for _, l := range lunches {
+		if !p.Contains(l.Date) {
+			continue
+		}
+		r.Total = r.Total.Add(l.Amount)
+		r.Orders++
+		add(users, l.User, l.Name, l.Amount)
+		if l.Team != "" {
+			add(teams, l.Team, l.Team, l.Amount)
+		}
+
+		key := l.Date.Format("2006-01-02")
+		d, ok := days[key]
+		if !ok {
+			d = &Day{Date: time.Date(l.Date.Year(), l.Date.Month(), l.Date.Day(), 0, 0, 0, 0, l.Date.Location())}
+			days[key] = d
+		}
+		d.Total = d.Total.Add(l.Amount)
+		d.Orders++
+	}
+
+	r.Users = sorted(users)
+	r.Teams = sorted(teams)
+	for _, d := range days {
+		r.Days = append(r.Days, *d)
+	}
+	sort.Slice(r.Days, func(i, j int) bool {
+		if !r.Days[i].Total.Equal(r.Days[j].Total) {
+			return r.Days[i].Total.GreaterThan(r.Days[j].Total)
+		}
+		return r.Days[i].Date.Before(r.Days[j].Date)
+	})
+	return r
+}
+
+func add(spends map[string]*Spend, key, name string, amount decimal.Decimal) {
+	s, ok := spends[key]
+	if !ok {
+		s = &Spend{Key: key, Name: name}
+		spends[key] = s
+	}
+	s.Total = s.Total.Add(amount)
+	s.Orders++
+}
+
+// sorted returns the spends by total, the highest first, then by name
+func sorted(spends map[string]*Spend) []Spend {
+	var r []Spend
+	for _, s := range spends {
+		r = append(r, *s)
+	}
+	sort.Slice(r, func(i, j int) bool {
+		if !r[i].Total.Equal(r[j].Total) {
+			return r[i].Total.GreaterThan(r[j].Total)
+		}
+		return strings.ToLower(r[i].Name) < strings.ToLower(r[j].Name)
+	})
+	return r
+}
+
+// User returns the spending of the user with key, false if they didn't order
+func (r Report) User(key string) (Spend, bool) {
+	for _, s := range r.Users {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return Spend{}, false
+}
@@ -0,0 +1,69 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestPeriods(t *testing.T) {
+	sunday := time.Date(2019, 3, 17, 20, 0, 0, 0, time.UTC)
+	w := Week(sunday)
+	if w.Start.Format("2006-01-02") != "2019-03-11" || w.End.Format("2006-01-02") != "2019-03-18" {
+		t.Errorf("wrong week of sunday: %v", w)
+	}
+	if !w.Contains(sunday) || w.Contains(w.End) {
+		t.Errorf("wrong bounds of %v", w)
+	}
+	if w := Week(w.End); w.Start.Format("2006-01-02") != "2019-03-18" {
+		t.Errorf("wrong week of monday: %v", w)
+	}
+
+	m := Month(sunday)
+	if m.Start.Format("2006-01-02") != "2019-03-01" || m.End.Format("2006-01-02") != "2019-04-01" {
+		t.Errorf("wrong month: %v", m)
+	}
+}
+
+func TestNew(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2019, 3, d, 0, 0, 0, 0, time.UTC)
+	}
+	eur := func(n int64) decimal.Decimal {
+		return decimal.New(n, 0)
+	}
+	lunches := []Lunch{
+		{day(11), "U1", "mario", "R&D", eur(10)},
+		{day(11), "U2", "luigi", "R&D", eur(5)},
+		{day(12), "U1", "mario", "R&D", eur(8)},
+		{day(12), "U3", "peach", "Vendite", eur(12)},
+		{day(13), "ospite", "ospite", "", eur(4)},
+		{day(20), "U1", "mario", "R&D", eur(100)},
+	}
+	r := New(lunches, Week(day(11)))
+
+	if r.Total.String() != "39" || r.Orders != 5 || r.Average().String() != "7.8" {
+		t.Errorf("wrong totals: %v %d %v", r.Total, r.Orders, r.Average())
+	}
+	if len(r.Users) != 4 || r.Users[0].Name != "mario" || r.Users[0].Total.String() != "18" || r.Users[0].Average().String() != "9" {
+		t.Errorf("wrong users: %+v", r.Users)
+	}
+	if len(r.Teams) != 2 || r.Teams[0].Name != "R&D" || r.Teams[0].Orders != 3 || r.Teams[1].Total.String() != "12" {
+		t.Errorf("wrong teams: %+v", r.Teams)
+	}
+	if len(r.Days) != 3 || !r.Days[0].Date.Equal(day(12)) || r.Days[0].Total.String() != "20" || !r.Days[2].Date.Equal(day(13)) {
+		t.Errorf("wrong days: %+v", r.Days)
+	}
+	if s, ok := r.User("U2"); !ok || s.Orders != 1 {
+		t.Errorf("wrong user U2: %+v", s)
+	}
+	if _, ok := r.User("U4"); ok {
+		t.Error("U4 didn't order")
+	}
+
+	empty := New(nil, Month(day(1)))
+	if empty.Orders != 0 || !empty.Average().IsZero() || len(empty.Users) != 0 {
+		t.Errorf("wrong empty report: %+v", empty)
+	}
+}
@@ -0,0 +1,94 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// minSamples is how many past days of the same weekday a forecast needs,
+// with fewer it uses the last days whatever their weekday
+const minSamples = 2
+
+// DayCount is how many people ordered in a day, and how many dishes of each
+// type
+type DayCount struct {
+	Date     time.Time
+	People   int
+	Sections map[tuttobene.MenuRowType]int
+}
+
+// SectionForecast is the expected number of dishes of a type
+type SectionForecast struct {
+	Type  tuttobene.MenuRowType
+	Count float64
+}
+
+// Forecast is the expected order of a day
+type Forecast struct {
+	Date time.Time
+	// Samples are the days averaged, SameWeekday tells if they are all the
+	// same weekday of Date
+	Samples     []time.Time
+	SameWeekday bool
+	People      float64
+	// Sections are in the order of tuttobene
+	Sections []SectionForecast
+}
+
+// NewForecast returns the forecast of the order of date as the moving
+// average of the last n days before it with the same weekday, or of the last
+// n days if there are too few. The days nobody ordered are skipped.
+func NewForecast(days []DayCount, date time.Time, n int) Forecast {
+	f := Forecast{Date: date, SameWeekday: true}
+	var past []DayCount
+	for _, d := range days {
+		if d.People > 0 && d.Date.Before(date) {
+			past = append(past, d)
+		}
+	}
+	sort.Slice(past, func(i, j int) bool {
+		return past[i].Date.After(past[j].Date)
+	})
+
+	var samples []DayCount
+	for _, d := range past {
+		if d.Date.Weekday() == date.Weekday() && len(samples) < n {
+			samples = append(samples, d)
+		}
+	}
+	if len(samples) < minSamples {
+		f.SameWeekday = false
+		samples = past
+		if len(samples) > n {
+			samples = samples[:n]
+		}
+	}
+	if len(samples) == 0 {
+		return f
+	}
+
+	sections := make(map[tuttobene.MenuRowType]int)
+	people := 0
+	for _, d := range samples {
+		f.Samples = append(f.Samples, d.Date)
+		people += d.People
+		for t, c := range d.Sections {
+			sections[t] += c
+		}
+	}
+	f.People = float64(people) / float64(len(samples))
+	for t := tuttobene.Primo; t <= tuttobene.Panino; t++ {
+		if c, ok := sections[t]; ok {
+			f.Sections = append(f.Sections, SectionForecast{t, float64(c) / float64(len(samples))})
+		}
+	}
+	return f
+}
+
+// Round returns the expected count rounded to the closest integer
+func Round(count float64) int {
+	return int(math.Round(count))
+}
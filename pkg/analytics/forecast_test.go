@@ -0,0 +1,49 @@
+package analytics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestForecast(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2019, 3, d, 0, 0, 0, 0, time.UTC)
+	}
+	count := func(d, people, primi, secondi int) DayCount {
+		return DayCount{day(d), people, map[tuttobene.MenuRowType]int{tuttobene.Primo: primi, tuttobene.Secondo: secondi}}
+	}
+	// fridays, the 8th nobody ordered, and other days
+	days := []DayCount{
+		count(1, 10, 6, 4),
+		{Date: day(8)},
+		count(15, 12, 8, 5),
+		count(22, 11, 7, 4),
+		count(25, 20, 10, 10),
+		count(29, 40, 20, 20),
+	}
+
+	f := NewForecast(days, day(29), 4)
+	if !f.SameWeekday || !reflect.DeepEqual(f.Samples, []time.Time{day(22), day(15), day(1)}) {
+		t.Fatalf("wrong samples: %+v", f)
+	}
+	if f.People != 11 || Round(f.People) != 11 {
+		t.Errorf("wrong people: %v", f.People)
+	}
+	want := []SectionForecast{{tuttobene.Primo, 7}, {tuttobene.Secondo, 13.0 / 3}}
+	if !reflect.DeepEqual(f.Sections, want) {
+		t.Errorf("wrong sections: %+v", f.Sections)
+	}
+
+	// a single monday, the last days are used
+	f = NewForecast(days, day(25).AddDate(0, 0, 7), 2)
+	if f.SameWeekday || !reflect.DeepEqual(f.Samples, []time.Time{day(29), day(25)}) || f.People != 30 {
+		t.Errorf("wrong fallback: %+v", f)
+	}
+
+	if f := NewForecast(nil, day(29), 4); len(f.Samples) != 0 || len(f.Sections) != 0 {
+		t.Errorf("forecast without history: %+v", f)
+	}
+}
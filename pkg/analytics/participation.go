@@ -0,0 +1,125 @@
+package analytics
+
+import (
+	"sort"
+	"strings"
+)
+
+// Attendance is how often a user ordered in a period
+type Attendance struct {
+	User string
+	Name string
+	// Days is how many of the days with an order the user ordered
+	Days int
+	// Longest and Current are the longest streak of days with an order the
+	// user ordered, and the one up to the last day
+	Longest int
+	Current int
+	// Firsts is how many times the user was the first to order
+	Firsts int
+}
+
+// Participation is how often the users ordered in a period
+type Participation struct {
+	Period
+	// Days is how many days someone ordered
+	Days int
+	// Users are sorted by days, then by longest streak, then by name
+	Users []Attendance
+}
+
+// NewParticipation returns the participation of the users in the lunches of
+// the period, first being who ordered first each day by "2006-01-02". The
+// days count only if someone ordered, so the closing days don't break the
+// streaks.
+func NewParticipation(lunches []Lunch, first map[string]string, p Period) Participation {
+	r := Participation{Period: p}
+	var days []string
+	ordered := make(map[string]map[string]bool) // by day and user
+	users := make(map[string]*Attendance)
+	for _, l := range lunches {
+		if !p.Contains(l.Date) {
+			continue
+		}
+		day := l.Date.Format("2006-01-02")
+		if ordered[day] == nil {
+			ordered[day] = make(map[string]bool)
+			days = append(days, day)
+		}
+		ordered[day][l.User] = true
+		if _, ok := users[l.User]; !ok {
+			users[l.User] = &Attendance{User: l.User, Name: l.Name}
+		}
+	}
+	sort.Strings(days)
+	r.Days = len(days)
+
+	for _, day := range days {
+		for _, a := range users {
+			if !ordered[day][a.User] {
+				a.Current = 0
+				continue
+			}
+			a.Days++
+			a.Current++
+			if a.Current > a.Longest {
+				a.Longest = a.Current
+			}
+		}
+		if a, ok := users[first[day]]; ok {
+			a.Firsts++
+		}
+	}
+
+	for _, a := range users {
+		r.Users = append(r.Users, *a)
+	}
+	sort.Slice(r.Users, func(i, j int) bool {
+		a, b := r.Users[i], r.Users[j]
+		if a.Days != b.Days {
+			return a.Days > b.Days
+		}
+		if a.Longest != b.Longest {
+			return a.Longest > b.Longest
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+	return r
+}
+
+// Rate returns the percentage of the days of p the user ordered
+func (a Attendance) Rate(p Participation) int {
+	if p.Days == 0 {
+		return 0
+	}
+	return a.Days * 100 / p.Days
+}
+
+// By returns the first n users by value, the highest first, skipping the
+// ones for which it's zero
+func (p Participation) By(n int, value func(Attendance) int) []Attendance {
+	users := make([]Attendance, 0, len(p.Users))
+	for _, a := range p.Users {
+		if value(a) > 0 {
+			users = append(users, a)
+		}
+	}
+	sort.SliceStable(users, func(i, j int) bool {
+		return value(users[i]) > value(users[j])
+	})
+	if len(users) > n {
+		users = users[:n]
+	}
+	return users
+}
+
+// User returns the attendance of the user with key, false if they never
+// ordered
+func (p Participation) User(key string) (Attendance, bool) {
+	for _, a := range p.Users {
+		if a.User == key {
+			return a, true
+		}
+	}
+	return Attendance{}, false
+}
@@ -0,0 +1,48 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParticipation(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2019, 3, d, 0, 0, 0, 0, time.UTC)
+	}
+	var lunches []Lunch
+	add := func(user string, days ...int) {
+		for _, d := range days {
+			lunches = append(lunches, Lunch{Date: day(d), User: user, Name: user})
+		}
+	}
+	// the 13th nobody ordered, the streaks go on
+	add("mario", 11, 12, 14, 15, 18)
+	add("luigi", 11, 14, 15, 18, 19)
+	add("peach", 12)
+	lunches = append(lunches, Lunch{Date: day(1).AddDate(0, 0, -1), User: "toad", Name: "toad"})
+	first := map[string]string{"2019-03-11": "luigi", "2019-03-12": "peach", "2019-03-14": "luigi", "2019-03-20": "mario"}
+
+	p := NewParticipation(lunches, first, Month(day(11)))
+	if p.Days != 6 || len(p.Users) != 3 {
+		t.Fatalf("wrong participation: %+v", p)
+	}
+	// same days, mario has the longest streak
+	if p.Users[0].User != "mario" || p.Users[2].User != "peach" {
+		t.Errorf("wrong order: %+v", p.Users)
+	}
+	if luigi, _ := p.User("luigi"); luigi.Days != 5 || luigi.Longest != 4 || luigi.Current != 4 || luigi.Firsts != 2 || luigi.Rate(p) != 83 {
+		t.Errorf("wrong luigi: %+v", luigi)
+	}
+	if mario := p.Users[0]; mario.Days != 5 || mario.Longest != 5 || mario.Current != 0 || mario.Firsts != 0 {
+		t.Errorf("wrong mario: %+v", mario)
+	}
+
+	longest := p.By(2, func(a Attendance) int { return a.Longest })
+	if len(longest) != 2 || longest[0].User != "mario" || longest[1].User != "luigi" {
+		t.Errorf("wrong longest streaks: %+v", longest)
+	}
+	firsts := p.By(3, func(a Attendance) int { return a.Firsts })
+	if len(firsts) != 2 || firsts[0].User != "luigi" || firsts[1].User != "peach" {
+		t.Errorf("wrong firsts: %+v", firsts)
+	}
+}
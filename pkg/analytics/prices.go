@@ -0,0 +1,69 @@
+package analytics
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// PriceChange is the change of the price of a dish from a menu to the
+// following one which had it
+type PriceChange struct {
+	Date time.Time
+	Name string
+	Type tuttobene.MenuRowType
+	Old  decimal.Decimal
+	New  decimal.Decimal
+}
+
+// Percent returns the change in percent of the old price
+func (c PriceChange) Percent() float64 {
+	if !c.Old.IsPositive() {
+		return 0
+	}
+	p, _ := c.New.Sub(c.Old).Div(c.Old).Mul(decimal.New(100, 0)).Float64()
+	return p
+}
+
+// Inflation is how the prices of a type of dishes changed in a period
+type Inflation struct {
+	Type      tuttobene.MenuRowType
+	Increases int
+	Decreases int
+	// Average is the average change in percent of the dishes whose price
+	// changed
+	Average float64
+}
+
+// NewInflation returns how the prices changed in the period for each type
+// of dishes, in the order of tuttobene, skipping the ones without changes
+func NewInflation(changes []PriceChange, p Period) []Inflation {
+	sections := make(map[tuttobene.MenuRowType]*Inflation)
+	for _, c := range changes {
+		if !p.Contains(c.Date) || c.New.Equal(c.Old) {
+			continue
+		}
+		s, ok := sections[c.Type]
+		if !ok {
+			s = &Inflation{Type: c.Type}
+			sections[c.Type] = s
+		}
+		if c.New.GreaterThan(c.Old) {
+			s.Increases++
+		} else {
+			s.Decreases++
+		}
+		s.Average += c.Percent()
+	}
+
+	var r []Inflation
+	for t := tuttobene.Primo; t <= tuttobene.Panino; t++ {
+		if s, ok := sections[t]; ok {
+			s.Average /= float64(s.Increases + s.Decreases)
+			r = append(r, *s)
+		}
+	}
+	return r
+}
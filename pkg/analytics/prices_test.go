@@ -0,0 +1,42 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestInflation(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2019, 3, d, 0, 0, 0, 0, time.UTC)
+	}
+	change := func(d int, typ tuttobene.MenuRowType, old, new float64) PriceChange {
+		return PriceChange{day(d), "piatto", typ, decimal.NewFromFloat(old), decimal.NewFromFloat(new)}
+	}
+	changes := []PriceChange{
+		change(1, tuttobene.Secondo, 8, 10),
+		change(4, tuttobene.Primo, 7, 7.7),
+		change(5, tuttobene.Primo, 5, 4.5),
+		change(6, tuttobene.Primo, 6, 6.3),
+		change(40, tuttobene.Primo, 6, 60),
+	}
+	if p := changes[0].Percent(); p != 25 {
+		t.Errorf("wrong percent: %v", p)
+	}
+
+	r := NewInflation(changes, Month(day(1)))
+	if len(r) != 2 || r[0].Type != tuttobene.Primo || r[1].Type != tuttobene.Secondo {
+		t.Fatalf("wrong sections: %+v", r)
+	}
+	// +10%, -10%, +5%
+	if r[0].Increases != 2 || r[0].Decreases != 1 || math.Abs(r[0].Average-5.0/3) > 1e-9 {
+		t.Errorf("wrong primi: %+v", r[0])
+	}
+	if r[1].Increases != 1 || r[1].Decreases != 0 || r[1].Average != 25 {
+		t.Errorf("wrong secondi: %+v", r[1])
+	}
+}
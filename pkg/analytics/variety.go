@@ -0,0 +1,135 @@
+package analytics
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// Dish is a dish of the menus, by its canonical name
+type Dish struct {
+	Name string // as written in the last menu
+	Type tuttobene.MenuRowType
+	// Days is how many menus had the dish
+	Days int
+}
+
+// Section is a type of dishes in the menus
+type Section struct {
+	Type tuttobene.MenuRowType
+	// Dishes is how many different dishes the section had
+	Dishes  int
+	Average decimal.Decimal
+	Min     decimal.Decimal
+	Max     decimal.Decimal
+}
+
+// Variety is how varied the menus of a period were
+type Variety struct {
+	Menus int
+	// Dishes are the different dishes, the most recurring first
+	Dishes []Dish
+	// New are the dishes not in known, in order of appearance
+	New []Dish
+	// Sections are the types of dishes in the order of tuttobene
+	Sections []Section
+}
+
+// NewVariety returns the variety of the menus. The dishes in known, by
+// canonical name, are the ones already seen before the menus; the empty rows
+// and the ones of unknown type are ignored.
+func NewVariety(menus []tuttobene.Menu, known map[string]bool) Variety {
+	sort.SliceStable(menus, func(i, j int) bool {
+		return menus[i].Date.Before(menus[j].Date)
+	})
+
+	v := Variety{Menus: len(menus)}
+	dishes := make(map[string]*Dish)
+	type prices struct {
+		total    decimal.Decimal
+		n        int
+		min, max decimal.Decimal
+		dishes   map[string]bool
+	}
+	sections := make(map[tuttobene.MenuRowType]*prices)
+	for _, m := range menus {
+		seen := make(map[string]bool)
+		for _, r := range m.Rows {
+			name := tuttobene.CanonicalName(r.Content)
+			if r.Type == tuttobene.Empty || r.Type == tuttobene.Unknonwn || name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			d, ok := dishes[name]
+			if !ok {
+				d = &Dish{Type: r.Type}
+				dishes[name] = d
+				if !known[name] {
+					v.New = append(v.New, Dish{Name: r.Content, Type: r.Type})
+				}
+			}
+			d.Name = r.Content
+			d.Days++
+
+			s, ok := sections[r.Type]
+			if !ok {
+				s = &prices{dishes: make(map[string]bool)}
+				sections[r.Type] = s
+			}
+			s.dishes[name] = true
+			if !r.Price.IsPositive() {
+				continue
+			}
+			if s.n == 0 || r.Price.LessThan(s.min) {
+				s.min = r.Price
+			}
+			if s.n == 0 || r.Price.GreaterThan(s.max) {
+				s.max = r.Price
+			}
+			s.total = s.total.Add(r.Price)
+			s.n++
+		}
+	}
+
+	for _, d := range dishes {
+		v.Dishes = append(v.Dishes, *d)
+	}
+	sort.Slice(v.Dishes, func(i, j int) bool {
+		if v.Dishes[i].Days != v.Dishes[j].Days {
+			return v.Dishes[i].Days > v.Dishes[j].Days
+		}
+		return strings.ToLower(v.Dishes[i].Name) < strings.ToLower(v.Dishes[j].Name)
+	})
+	for i := range v.New {
+		v.New[i].Days = dishes[tuttobene.CanonicalName(v.New[i].Name)].Days
+	}
+
+	for t := tuttobene.Primo; t <= tuttobene.Panino; t++ {
+		s, ok := sections[t]
+		if !ok {
+			continue
+		}
+		section := Section{Type: t, Dishes: len(s.dishes), Min: s.min, Max: s.max}
+		if s.n > 0 {
+			section.Average = s.total.Div(decimal.New(int64(s.n), 0)).Round(2)
+		}
+		v.Sections = append(v.Sections, section)
+	}
+	return v
+}
+
+// Recurring returns the dishes in at least min menus
+func (v Variety) Recurring(min int) []Dish {
+	var r []Dish
+	for _, d := range v.Dishes {
+		if d.Days < min {
+			break
+		}
+		r = append(r, d)
+	}
+	return r
+}
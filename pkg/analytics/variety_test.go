@@ -0,0 +1,49 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestVariety(t *testing.T) {
+	row := func(content string, typ tuttobene.MenuRowType, price int64) tuttobene.MenuRow {
+		return tuttobene.MenuRow{Content: content, Type: typ, Price: decimal.New(price, 0)}
+	}
+	menu := func(day int, rows ...tuttobene.MenuRow) tuttobene.Menu {
+		return tuttobene.Menu{Date: time.Date(2019, 3, day, 0, 0, 0, 0, time.UTC), Rows: rows}
+	}
+	menus := []tuttobene.Menu{
+		menu(12, row("Pasta al pomodoro", tuttobene.Primo, 5), row("Pollo arrosto", tuttobene.Secondo, 7), row("", tuttobene.Empty, 0)),
+		menu(11, row("pasta al pomodoro.", tuttobene.Primo, 5), row("Risotto", tuttobene.Primo, 6), row("Insalata", tuttobene.Contorno, 0)),
+		menu(13, row("Pasta al pomodoro", tuttobene.Primo, 5), row("Lasagne", tuttobene.Primo, 8), row("Pollo arrosto", tuttobene.Secondo, 9)),
+	}
+	v := NewVariety(menus, map[string]bool{"risotto": true, "pollo arrosto": true})
+
+	if v.Menus != 3 || len(v.Dishes) != 5 {
+		t.Fatalf("wrong counts: %+v", v)
+	}
+	if d := v.Dishes[0]; d.Name != "Pasta al pomodoro" || d.Days != 3 || v.Dishes[1].Name != "Pollo arrosto" {
+		t.Errorf("wrong recurring dishes: %+v", v.Dishes)
+	}
+	if r := v.Recurring(2); len(r) != 2 {
+		t.Errorf("wrong recurring: %+v", r)
+	}
+	if len(v.New) != 3 || v.New[0].Name != "pasta al pomodoro." || v.New[0].Days != 3 || v.New[2].Name != "Lasagne" {
+		t.Errorf("wrong new dishes: %+v", v.New)
+	}
+
+	if len(v.Sections) != 3 {
+		t.Fatalf("wrong sections: %+v", v.Sections)
+	}
+	primi := v.Sections[0]
+	if primi.Type != tuttobene.Primo || primi.Dishes != 3 || primi.Average.String() != "5.8" || primi.Min.String() != "5" || primi.Max.String() != "8" {
+		t.Errorf("wrong primi: %+v", primi)
+	}
+	if contorni := v.Sections[2]; contorni.Dishes != 1 || !contorni.Average.IsZero() {
+		t.Errorf("the dishes without price have no average: %+v", contorni)
+	}
+}
@@ -0,0 +1,168 @@
+package brain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// The types of the keys in an archive, as named by redis
+const (
+	TypeString = "string"
+	TypeList   = "list"
+	TypeSet    = "set"
+	TypeZSet   = "zset"
+)
+
+// typer is implemented by the stores with native collection types, the
+// other ones keep everything as JSON strings
+type typer interface {
+	Type(key string) (string, error)
+}
+
+// Entry is a key saved in an archive
+type Entry struct {
+	Key  string
+	Type string
+	// Value is the JSON value of a string, an array for the collections
+	Value   json.RawMessage
+	Expires *time.Time `json:",omitempty"`
+}
+
+// Archive is a dump of the keys of a store, for debugging and for moving
+// the bot state from a store to another
+type Archive struct {
+	Created time.Time
+	Entries []Entry
+}
+
+// entry reads key from s
+func entry(s Store, key string) (Entry, error) {
+	e := Entry{Key: key, Type: TypeString}
+	if t, ok := s.(typer); ok {
+		var err error
+		if e.Type, err = t.Type(key); err != nil {
+			return e, err
+		}
+	}
+
+	var val interface{}
+	var err error
+	switch e.Type {
+	case TypeString:
+		var raw string
+		raw, err = s.Read(key)
+		val = json.RawMessage(raw)
+	case TypeList:
+		var list []json.RawMessage
+		err = s.Range(key, 0, -1, &list)
+		val = list
+	case TypeSet:
+		val, err = s.SMembers(key)
+	case TypeZSet:
+		val, err = s.ZRange(key, 0, -1)
+	default:
+		err = fmt.Errorf("brain: unsupported type %s of key %s", e.Type, key)
+	}
+	if err != nil {
+		return e, err
+	}
+	if e.Value, err = json.Marshal(val); err != nil {
+		return e, err
+	}
+
+	ttl, err := s.TTL(key)
+	if ttl > 0 {
+		expires := time.Now().Add(ttl).Truncate(time.Second)
+		e.Expires = &expires
+	}
+	return e, err
+}
+
+// Export writes to w the JSON archive of the keys of s matching pattern,
+// the keys deleted or expired in the meantime are skipped
+func Export(s Store, pattern string, w io.Writer) error {
+	keys, err := s.Keys(pattern)
+	if err != nil {
+		return err
+	}
+	a := Archive{Created: time.Now(), Entries: []Entry{}}
+	for _, k := range keys {
+		e, err := entry(s, k)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		a.Entries = append(a.Entries, e)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(a)
+}
+
+// restore writes the entry to s, replacing the key if it exists
+func restore(s Store, e Entry) error {
+	if err := s.Delete(e.Key); err != nil {
+		return err
+	}
+
+	var err error
+	switch e.Type {
+	case TypeString:
+		err = s.Set(e.Key, e.Value)
+	case TypeList:
+		var list []json.RawMessage
+		if err = json.Unmarshal(e.Value, &list); err == nil && len(list) > 0 {
+			vals := make([]interface{}, len(list))
+			for i := range list {
+				vals[i] = list[i]
+			}
+			err = s.Append(e.Key, vals...)
+		}
+	case TypeSet:
+		var members []string
+		if err = json.Unmarshal(e.Value, &members); err == nil && len(members) > 0 {
+			err = s.SAdd(e.Key, members...)
+		}
+	case TypeZSet:
+		var zs []Scored
+		if err = json.Unmarshal(e.Value, &zs); err == nil {
+			for _, z := range zs {
+				if err = s.ZAdd(e.Key, z.Member, z.Score); err != nil {
+					break
+				}
+			}
+		}
+	default:
+		err = fmt.Errorf("brain: unsupported type %s of key %s", e.Type, e.Key)
+	}
+	if err != nil || e.Expires == nil {
+		return err
+	}
+	return s.ExpireAt(e.Key, *e.Expires)
+}
+
+// Import restores in s the keys of the archive read from r, overwriting the
+// existing ones, and returns how many keys were restored. The keys expired
+// since the export are skipped.
+func Import(s Store, r io.Reader) (int, error) {
+	var a Archive
+	if err := json.NewDecoder(r).Decode(&a); err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, e := range a.Entries {
+		if e.Expires != nil && !e.Expires.After(time.Now()) {
+			continue
+		}
+		if err := restore(s, e); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
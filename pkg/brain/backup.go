@@ -0,0 +1,222 @@
+package brain
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupPrefix and backupSuffix frame the names of the backups, the time
+// in the middle sorts them from the oldest to the newest. The encrypted
+// backups end with encryptedSuffix too.
+const (
+	backupPrefix    = "brain-"
+	backupSuffix    = ".json.gz"
+	encryptedSuffix = ".enc"
+	backupTime      = "20060102-150405"
+)
+
+// Backend is where the backups are kept
+type Backend interface {
+	// Put writes the backup name
+	Put(name string, data []byte) error
+	// Get returns the content of the backup name
+	Get(name string) ([]byte, error)
+	// Delete removes the backup name
+	Delete(name string) error
+	// List returns the names of the backups, in any order
+	List() ([]string, error)
+}
+
+// OpenBackend returns the backup backend described by uri:
+//
+//	s3://bucket/prefix   an Amazon S3 bucket, see OpenS3
+//	gs://bucket/prefix   a Google Cloud Storage bucket, see OpenS3
+//	file:///path/to/dir  a local directory, also given as a plain path
+func OpenBackend(uri string) (Backend, error) {
+	switch {
+	case strings.HasPrefix(uri, "s3://") || strings.HasPrefix(uri, "gs://"):
+		return OpenS3(uri)
+	case strings.HasPrefix(uri, "file://"):
+		return dirBackend(strings.TrimPrefix(uri, "file://")), nil
+	case !strings.Contains(uri, "://"):
+		return dirBackend(uri), nil
+	}
+	return nil, fmt.Errorf("brain: unsupported backup backend %s", uri)
+}
+
+// dirBackend keeps the backups as files in a directory
+type dirBackend string
+
+func (d dirBackend) Put(name string, data []byte) error {
+	if err := os.MkdirAll(string(d), 0700); err != nil {
+		return err
+	}
+	tmp := filepath.Join(string(d), "."+name)
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(string(d), name))
+}
+
+func (d dirBackend) Get(name string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(string(d), name))
+}
+
+func (d dirBackend) Delete(name string) error {
+	return os.Remove(filepath.Join(string(d), name))
+}
+
+func (d dirBackend) List() ([]string, error) {
+	files, err := ioutil.ReadDir(string(d))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() && !strings.HasPrefix(f.Name(), ".") {
+			names = append(names, f.Name())
+		}
+	}
+	return names, nil
+}
+
+// Backups returns the names of the backups in b, from the oldest to the newest
+func Backups(b Backend) ([]string, error) {
+	names, err := b.List()
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, n := range names {
+		if strings.HasPrefix(n, backupPrefix) && strings.HasSuffix(strings.TrimSuffix(n, encryptedSuffix), backupSuffix) {
+			backups = append(backups, n)
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// Backup writes to b a compressed archive of all the keys of s, see Export,
+// and returns its name. If BRAIN_ENCRYPTION_KEY is set the archive is
+// encrypted with it, as the values of the store, since Export reads them in
+// plaintext.
+func Backup(s Store, b Backend) (string, error) {
+	key, err := KeyFromEnv()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := Export(s, "*", zw); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	name := backupPrefix + time.Now().UTC().Format(backupTime) + backupSuffix
+	data := buf.Bytes()
+	if key != nil {
+		if data, err = sealArchive(key, data); err != nil {
+			return "", err
+		}
+		name += encryptedSuffix
+	}
+	return name, b.Put(name, data)
+}
+
+// sealArchive encrypts data with key as the values, see Encrypted
+func sealArchive(key, data []byte) ([]byte, error) {
+	e, err := Encrypt(nil, key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return e.aead.Seal(nonce, nonce, data, nil), nil
+}
+
+// openArchive decrypts an archive encrypted by sealArchive
+func openArchive(key, sealed []byte) ([]byte, error) {
+	e, err := Encrypt(nil, key)
+	if err != nil {
+		return nil, err
+	}
+	n := e.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, errors.New("brain: encrypted backup too short")
+	}
+	return e.aead.Open(nil, sealed[:n], sealed[n:], nil)
+}
+
+// Restore imports in s the backup name from b, the newest one if name is
+// empty, and returns how many keys were restored, see Import
+func Restore(s Store, b Backend, name string) (int, error) {
+	if name == "" {
+		backups, err := Backups(b)
+		if err != nil {
+			return 0, err
+		}
+		if len(backups) == 0 {
+			return 0, fmt.Errorf("brain: no backups found")
+		}
+		name = backups[len(backups)-1]
+	}
+	data, err := b.Get(name)
+	if err != nil {
+		return 0, err
+	}
+	if strings.HasSuffix(name, encryptedSuffix) {
+		key, err := KeyFromEnv()
+		if err != nil {
+			return 0, err
+		}
+		if key == nil {
+			return 0, fmt.Errorf("brain: %s is encrypted, set BRAIN_ENCRYPTION_KEY", name)
+		}
+		if data, err = openArchive(key, data); err != nil {
+			return 0, fmt.Errorf("brain: cannot decrypt %s: %v", name, err)
+		}
+		name = strings.TrimSuffix(name, encryptedSuffix)
+	}
+	var r io.Reader = bytes.NewReader(data)
+	if strings.HasSuffix(name, ".gz") {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return 0, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	return Import(s, r)
+}
+
+// Prune deletes the oldest backups in b, keeping the newest keep ones, and
+// returns the names of the deleted ones
+func Prune(b Backend, keep int) ([]string, error) {
+	backups, err := Backups(b)
+	if err != nil || len(backups) <= keep {
+		return nil, err
+	}
+	old := backups[:len(backups)-keep]
+	for i, n := range old {
+		if err := b.Delete(n); err != nil {
+			return old[:i], err
+		}
+	}
+	return old, nil
+}
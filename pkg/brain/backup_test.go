@@ -0,0 +1,194 @@
+package brain
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func testBackend(t *testing.T, b Backend) {
+	s := NewMemory()
+	s.Set("order", map[string]int{"pasta": 2})
+	s.SAdd("groups", "dev")
+	first, err := Backup(s, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an older backup, restored only when asked by name
+	b.Put("brain-20190101-120000.json.gz", mustData(t, b, first))
+
+	s.Set("order", map[string]int{"pizza": 1})
+	if n, err := Restore(s, b, ""); err != nil || n != 2 {
+		t.Fatalf("expected 2 keys restored, got %d, %v", n, err)
+	}
+	var order map[string]int
+	s.Get("order", &order)
+	if order["pasta"] != 2 || order["pizza"] != 0 {
+		t.Fatalf("unexpected order %v", order)
+	}
+
+	backups, _ := Backups(b)
+	if len(backups) != 2 || backups[1] != first {
+		t.Fatalf("unexpected backups %v", backups)
+	}
+	deleted, err := Prune(b, 1)
+	if err != nil || len(deleted) != 1 || deleted[0] != "brain-20190101-120000.json.gz" {
+		t.Fatalf("unexpected pruned %v, %v", deleted, err)
+	}
+	if backups, _ = Backups(b); len(backups) != 1 {
+		t.Fatalf("unexpected backups %v", backups)
+	}
+}
+
+func mustData(t *testing.T, b Backend, name string) []byte {
+	data, err := b.Get(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDirBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	b, err := OpenBackend("file://" + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testBackend(t, b)
+}
+
+// fakeS3 is a bucket answering to the few requests of the S3 backend
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+		http.Error(w, "unsigned request", http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/bucket":
+		var res struct {
+			XMLName  xml.Name `xml:"ListBucketResult"`
+			Contents []struct{ Key string }
+		}
+		var keys []string
+		for k := range f.objects {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			res.Contents = append(res.Contents, struct{ Key string }{k})
+		}
+		xml.NewEncoder(w).Encode(res)
+	case r.Method == "PUT":
+		f.objects[key], _ = ioutil.ReadAll(r.Body)
+	case r.Method == "GET":
+		data, ok := f.objects[key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	case r.Method == "DELETE":
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestEncryptedBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	b := dirBackend(dir)
+
+	t.Setenv("BRAIN_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	s := NewMemory()
+	s.Set("order", map[string]int{"pasta": 2})
+	name, err := Backup(s, b)
+	if err != nil || !strings.HasSuffix(name, ".json.gz.enc") {
+		t.Fatalf("unexpected backup %q, %v", name, err)
+	}
+	if _, err := gzip.NewReader(bytes.NewReader(mustData(t, b, name))); err == nil {
+		t.Fatal("backup not encrypted")
+	}
+	if backups, _ := Backups(b); len(backups) != 1 || backups[0] != name {
+		t.Fatalf("unexpected backups %v", backups)
+	}
+
+	s.Set("order", map[string]int{"pizza": 1})
+	if n, err := Restore(s, b, ""); err != nil || n != 1 {
+		t.Fatalf("expected 1 key restored, got %d, %v", n, err)
+	}
+	var order map[string]int
+	s.Get("order", &order)
+	if order["pasta"] != 2 {
+		t.Fatalf("unexpected order %v", order)
+	}
+
+	t.Setenv("BRAIN_ENCRYPTION_KEY", "")
+	if _, err := Restore(s, b, name); err == nil {
+		t.Fatal("encrypted backup restored without the key")
+	}
+	t.Setenv("BRAIN_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString([]byte("01234567890123456789012345678901")))
+	if _, err := Restore(s, b, name); err == nil {
+		t.Fatal("encrypted backup restored with another key")
+	}
+}
+
+func TestS3Backend(t *testing.T) {
+	srv := httptest.NewServer(&fakeS3{objects: make(map[string][]byte)})
+	defer srv.Close()
+
+	for k, v := range map[string]string{
+		"AWS_ACCESS_KEY_ID":     "key",
+		"AWS_SECRET_ACCESS_KEY": "secret",
+		"BRAIN_BACKUP_ENDPOINT": srv.URL,
+	} {
+		old, ok := os.LookupEnv(k)
+		os.Setenv(k, v)
+		if ok {
+			defer os.Setenv(k, old)
+		} else {
+			defer os.Unsetenv(k)
+		}
+	}
+
+	b, err := OpenBackend("s3://bucket/backups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testBackend(t, b)
+}
+
+func TestAWSEscape(t *testing.T) {
+	if s := awsEscape("/a b/c+d~", false); s != "/a%20b/c%2Bd~" {
+		t.Fatalf("unexpected %s", s)
+	}
+	if s := awsEscape("a/b", true); s != "a%2Fb" {
+		t.Fatalf("unexpected %s", s)
+	}
+}
@@ -0,0 +1,63 @@
+package brain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// batchOp is a write of a Batch, a delete if val is nil
+type batchOp struct {
+	key string
+	val []byte
+	ttl time.Duration
+}
+
+// Batch collects writes to be applied all together by Commit: either all of
+// them are applied or none is
+type Batch struct {
+	ops    []batchOp
+	err    error
+	commit func([]batchOp) error
+}
+
+func newBatch(commit func([]batchOp) error) *Batch {
+	return &Batch{commit: commit}
+}
+
+// Set adds the write of val to key
+func (b *Batch) Set(key string, val interface{}) {
+	b.SetWithTTL(key, val, 0)
+}
+
+// SetWithTTL adds the write of val to key, expiring after ttl
+func (b *Batch) SetWithTTL(key string, val interface{}, ttl time.Duration) {
+	encoded, err := json.Marshal(val)
+	if err != nil {
+		if b.err == nil {
+			b.err = err
+		}
+		return
+	}
+	b.ops = append(b.ops, batchOp{key, encoded, ttl})
+}
+
+// Delete adds the deletion of key
+func (b *Batch) Delete(key string) {
+	b.ops = append(b.ops, batchOp{key: key})
+}
+
+// Len returns how many writes were added
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// Commit applies the writes, nothing is written if a value could not be encoded
+func (b *Batch) Commit() error {
+	if b.err != nil {
+		return b.err
+	}
+	if len(b.ops) == 0 {
+		return nil
+	}
+	return b.commit(b.ops)
+}
@@ -2,36 +2,176 @@ package brain
 
 import (
 	"encoding/json"
-	"errors"
+	"path"
+	"sort"
+	"time"
 )
 
-type BrainMock map[string][]byte
+// BrainMock is an in-memory Store for the tests, not safe for concurrent
+// use. It has its own clock, moved with Advance, so that the expiration of
+// the keys can be tested without waiting, and can simulate a slow or broken
+// server with SetLatency and Fail.
+type BrainMock struct {
+	data     map[string][]byte
+	expires  map[string]time.Time
+	now      time.Time
+	latency  time.Duration
+	failures []mockFailure
+}
+
+// mockFailure makes the operations op on the keys matching pattern fail
+// with err, an empty op or pattern matches everything
+type mockFailure struct {
+	op      string
+	pattern string
+	err     error
+}
+
+// mockRaw is the BrainMock without failures and latency, used to emulate
+// the collections
+type mockRaw BrainMock
+
+func NewBrainMock() *BrainMock {
+	return &BrainMock{
+		data:    make(map[string][]byte),
+		expires: make(map[string]time.Time),
+		now:     time.Now(),
+	}
+}
+
+// Now returns the time of the mock clock
+func (b *BrainMock) Now() time.Time {
+	return b.now
+}
+
+// Advance moves the mock clock forward by d, expiring the keys
+func (b *BrainMock) Advance(d time.Duration) {
+	b.now = b.now.Add(d)
+}
+
+// SetLatency makes every operation take d more
+func (b *BrainMock) SetLatency(d time.Duration) {
+	b.latency = d
+}
+
+// Fail makes the operation op on the keys matching the glob pattern return
+// err, until Recover is called. The operations are named as in the
+// metrics, e.g. "get", "set", "update", "delete", "keys", "batch" or
+// "ping"; an empty op or pattern matches them all, so Fail("", "", err)
+// simulates a server down.
+func (b *BrainMock) Fail(op, pattern string, err error) {
+	b.failures = append(b.failures, mockFailure{op, pattern, err})
+}
 
-func NewBrainMock() BrainMock {
-	return make(BrainMock)
+// Recover removes all the failures added by Fail
+func (b *BrainMock) Recover() {
+	b.failures = nil
 }
 
-func (b BrainMock) Set(key string, val interface{}) error {
+// op simulates the latency and the failures of the operation op on key
+func (b *BrainMock) op(op, key string) error {
+	if b.latency > 0 {
+		time.Sleep(b.latency)
+	}
+	return b.failure(op, key)
+}
+
+// failure returns the error of the first failure matching op and key
+func (b *BrainMock) failure(op, key string) error {
+	for _, f := range b.failures {
+		if f.op != "" && f.op != op {
+			continue
+		}
+		if ok, _ := path.Match(f.pattern, key); f.pattern == "" || ok {
+			return f.err
+		}
+	}
+	return nil
+}
+
+// expired deletes key if it's expired, and tells so
+func (b *BrainMock) expired(key string) bool {
+	at, ok := b.expires[key]
+	if !ok || b.now.Before(at) {
+		return false
+	}
+	delete(b.data, key)
+	delete(b.expires, key)
+	return true
+}
+
+func (b *BrainMock) Set(key string, val interface{}) error {
+	return b.SetWithTTL(key, val, 0)
+}
+
+func (b *BrainMock) SetWithTTL(key string, val interface{}, ttl time.Duration) error {
+	if err := b.op("set", key); err != nil {
+		return err
+	}
 	encoded, err := json.Marshal(val)
 	if err != nil {
 		return err
 	}
+	b.data[key] = encoded
+	if ttl > 0 {
+		b.expires[key] = b.now.Add(ttl)
+	} else {
+		delete(b.expires, key)
+	}
+	return nil
+}
 
-	b[key] = encoded
-
+func (b *BrainMock) ExpireAt(key string, at time.Time) error {
+	if err := b.op("expire", key); err != nil {
+		return err
+	}
+	if _, ok := b.data[key]; !ok || b.expired(key) {
+		return ErrNotFound
+	}
+	b.expires[key] = at
+	b.expired(key)
 	return nil
 }
-func (b BrainMock) Read(key string) (string, error) {
-	val, ok := b[key]
 
-	if !ok {
-		return "", errors.New("key not found")
+func (b *BrainMock) TTL(key string) (time.Duration, error) {
+	if err := b.op("ttl", key); err != nil {
+		return 0, err
+	}
+	if _, ok := b.data[key]; !ok || b.expired(key) {
+		return 0, ErrNotFound
 	}
+	if at, ok := b.expires[key]; ok {
+		return at.Sub(b.now), nil
+	}
+	return 0, nil
+}
 
-	return string(val), nil
+func (b *BrainMock) Read(key string) (string, error) {
+	if err := b.op("get", key); err != nil {
+		return "", err
+	}
+	return (*mockRaw)(b).Read(key)
+}
+
+// ReadMany reads the keys with a single round trip, failing as a get of any
+// of them would
+func (b *BrainMock) ReadMany(keys ...string) (map[string]string, error) {
+	if b.latency > 0 {
+		time.Sleep(b.latency)
+	}
+	vals := make(map[string]string, len(keys))
+	for _, k := range keys {
+		if err := b.failure("get", k); err != nil {
+			return nil, err
+		}
+		if val, err := (*mockRaw)(b).Read(k); err == nil {
+			vals[k] = val
+		}
+	}
+	return vals, nil
 }
 
-func (b BrainMock) Get(key string, q interface{}) error {
+func (b *BrainMock) Get(key string, q interface{}) error {
 
 	val, err := b.Read(key)
 
@@ -42,7 +182,187 @@ func (b BrainMock) Get(key string, q interface{}) error {
 	return json.Unmarshal([]byte(val), q)
 }
 
-func (b BrainMock) Close() error {
-	b = nil
+// UpdateRaw calls fn with the current value of key, nil if it does not
+// exist, and writes back the returned value. The expiration of key is kept.
+func (b *BrainMock) UpdateRaw(key string, fn func(old []byte) ([]byte, error)) error {
+	if err := b.op("update", key); err != nil {
+		return err
+	}
+	return (*mockRaw)(b).UpdateRaw(key, fn)
+}
+
+func (b *BrainMock) Update(key string, q interface{}, fn func() error) error {
+	return updateJSON(b.UpdateRaw, key, q, fn)
+}
+
+func (b *BrainMock) Delete(key string) error {
+	if err := b.op("delete", key); err != nil {
+		return err
+	}
+	delete(b.data, key)
+	delete(b.expires, key)
+	return nil
+}
+
+func (b *BrainMock) Keys(pattern string) ([]string, error) {
+	if err := b.op("keys", pattern); err != nil {
+		return nil, err
+	}
+	var keys []string
+	for k := range b.data {
+		if b.expired(k) {
+			continue
+		}
+		if ok, _ := path.Match(pattern, k); ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (r *mockRaw) Read(key string) (string, error) {
+	b := (*BrainMock)(r)
+	b.expired(key)
+	val, ok := b.data[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return string(val), nil
+}
+
+func (r *mockRaw) UpdateRaw(key string, fn func(old []byte) ([]byte, error)) error {
+	b := (*BrainMock)(r)
+	b.expired(key)
+	encoded, err := fn(b.data[key])
+	if err != nil {
+		return err
+	}
+	b.data[key] = encoded
+	return nil
+}
+
+func (b *BrainMock) Append(key string, vals ...interface{}) error {
+	if err := b.op("append", key); err != nil {
+		return err
+	}
+	return listAppend((*mockRaw)(b), key, vals...)
+}
+
+func (b *BrainMock) Range(key string, start, stop int64, q interface{}) error {
+	if err := b.op("range", key); err != nil {
+		return err
+	}
+	return listRange((*mockRaw)(b), key, start, stop, q)
+}
+
+func (b *BrainMock) Pop(key string, q interface{}) error {
+	if err := b.op("pop", key); err != nil {
+		return err
+	}
+	return listPop((*mockRaw)(b), key, q)
+}
+
+func (b *BrainMock) Move(src, dst string, q interface{}) error {
+	if err := b.op("move", src); err != nil {
+		return err
+	}
+	return listMove((*mockRaw)(b), src, dst, q)
+}
+
+func (b *BrainMock) Remove(key string, val interface{}) error {
+	if err := b.op("remove", key); err != nil {
+		return err
+	}
+	return listRemove((*mockRaw)(b), key, val)
+}
+
+func (b *BrainMock) SAdd(key string, members ...string) error {
+	if err := b.op("sadd", key); err != nil {
+		return err
+	}
+	return setAdd((*mockRaw)(b), key, members...)
+}
+
+func (b *BrainMock) SRem(key string, members ...string) error {
+	if err := b.op("srem", key); err != nil {
+		return err
+	}
+	return setRemove((*mockRaw)(b), key, members...)
+}
+
+func (b *BrainMock) SMembers(key string) ([]string, error) {
+	if err := b.op("smembers", key); err != nil {
+		return nil, err
+	}
+	return setMembers((*mockRaw)(b), key)
+}
+
+func (b *BrainMock) ZAdd(key string, member string, score float64) error {
+	if err := b.op("zadd", key); err != nil {
+		return err
+	}
+	return zsetAdd((*mockRaw)(b), key, member, score)
+}
+
+func (b *BrainMock) ZRem(key string, member string) (bool, error) {
+	if err := b.op("zrem", key); err != nil {
+		return false, err
+	}
+	return zsetRemove((*mockRaw)(b), key, member)
+}
+
+func (b *BrainMock) ZRange(key string, start, stop int64) ([]Scored, error) {
+	if err := b.op("zrange", key); err != nil {
+		return nil, err
+	}
+	return zsetRange((*mockRaw)(b), key, start, stop, false)
+}
+
+func (b *BrainMock) ZRevRange(key string, start, stop int64) ([]Scored, error) {
+	if err := b.op("zrange", key); err != nil {
+		return nil, err
+	}
+	return zsetRange((*mockRaw)(b), key, start, stop, true)
+}
+
+// Batch returns a batch failing as a whole if the "batch" operation or the
+// "set" or "delete" of any of its keys fail
+func (b *BrainMock) Batch() *Batch {
+	return newBatch(func(ops []batchOp) error {
+		if err := b.op("batch", ""); err != nil {
+			return err
+		}
+		for _, op := range ops {
+			name := "set"
+			if op.val == nil {
+				name = "delete"
+			}
+			if err := b.failure(name, op.key); err != nil {
+				return err
+			}
+		}
+		for _, op := range ops {
+			if op.val == nil {
+				delete(b.data, op.key)
+				delete(b.expires, op.key)
+				continue
+			}
+			b.data[op.key] = op.val
+			if op.ttl > 0 {
+				b.expires[op.key] = b.now.Add(op.ttl)
+			} else {
+				delete(b.expires, op.key)
+			}
+		}
+		return nil
+	})
+}
+
+func (b *BrainMock) Healthy() error {
+	return b.op("ping", "")
+}
+
+func (b *BrainMock) Close() error {
 	return nil
 }
@@ -0,0 +1,400 @@
+package brain
+
+import (
+	"container/list"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// invalidateChannel is where the cached stores announce their writes, so
+// that the other bot instances drop the keys from their cache
+const invalidateChannel = "brain:invalidate"
+
+// DefaultCacheTTL is how long a value is cached if no TTL is configured
+const DefaultCacheTTL = time.Minute
+
+var cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "brain_cache_requests_total",
+	Help: "Reads served by the brain cache, by result (hit or miss).",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(cacheRequests)
+}
+
+// Cached is a view of a Store keeping the most recently used values in
+// memory, so that the menu and the orders are not read from the server on
+// every message. The writes go through to the store and update the cache;
+// if the store supports pub/sub the other instances are told to drop the
+// written keys, otherwise their copies expire after the TTL. Only the plain
+// values are cached, the collections are always read from the store.
+type Cached struct {
+	store Store
+	size  int
+	ttl   time.Duration
+	id    string
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	gen     uint64 // incremented on each invalidation
+	stop    func() error
+}
+
+type cacheEntry struct {
+	key     string
+	val     string
+	expires time.Time
+}
+
+// invalidation is the message published on invalidateChannel
+type invalidation struct {
+	Source string   `json:"source"`
+	Keys   []string `json:"keys"`
+}
+
+// Cache returns the view of s caching up to size values for ttl
+func Cache(s Store, size int, ttl time.Duration) (*Cached, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("brain: invalid cache size %d", size)
+	}
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	c := &Cached{
+		store:   s,
+		size:    size,
+		ttl:     ttl,
+		id:      hex.EncodeToString(id),
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+	if ps, ok := s.(PubSub); ok {
+		stop, err := ps.Subscribe(invalidateChannel, c.onInvalidate)
+		if err != nil {
+			return nil, err
+		}
+		c.stop = stop
+	}
+	return c, nil
+}
+
+// CacheFromEnv returns the cache size in BRAIN_CACHE_SIZE, 0 if the cache
+// is disabled, and the TTL in BRAIN_CACHE_TTL, e.g. "30s"
+func CacheFromEnv() (int, time.Duration, error) {
+	var (
+		size int
+		ttl  time.Duration
+		err  error
+	)
+	if env := os.Getenv("BRAIN_CACHE_SIZE"); env != "" {
+		if size, err = strconv.Atoi(env); err != nil || size < 0 {
+			return 0, 0, fmt.Errorf("brain: invalid BRAIN_CACHE_SIZE %q", env)
+		}
+	}
+	if env := os.Getenv("BRAIN_CACHE_TTL"); env != "" {
+		if ttl, err = time.ParseDuration(env); err != nil || ttl <= 0 {
+			return 0, 0, fmt.Errorf("brain: invalid BRAIN_CACHE_TTL %q", env)
+		}
+	}
+	return size, ttl, nil
+}
+
+func (c *Cached) onInvalidate(msg []byte) {
+	var inv invalidation
+	if err := json.Unmarshal(msg, &inv); err != nil {
+		slog.Warn("Invalid cache invalidation", "msg", string(msg), "err", err)
+		return
+	}
+	if inv.Source == c.id {
+		return
+	}
+	c.mu.Lock()
+	c.dropLocked(inv.Keys...)
+	c.mu.Unlock()
+}
+
+func (c *Cached) dropLocked(keys ...string) {
+	c.gen++
+	for _, key := range keys {
+		if e, ok := c.entries[key]; ok {
+			c.lru.Remove(e)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// lookup returns the cached value of key and the current generation
+func (c *Cached) lookup(key string) (string, bool, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return "", false, c.gen
+	}
+	entry := e.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.lru.Remove(e)
+		delete(c.entries, key)
+		return "", false, c.gen
+	}
+	c.lru.MoveToFront(e)
+	return entry.val, true, c.gen
+}
+
+// put caches val for key, unless the cache was invalidated after gen, since
+// the value may be already stale
+func (c *Cached) put(key, val string, ttl time.Duration, gen uint64) {
+	if ttl <= 0 || ttl > c.ttl {
+		ttl = c.ttl
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	entry := &cacheEntry{key, val, time.Now().Add(ttl)}
+	if e, ok := c.entries[key]; ok {
+		e.Value = entry
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// invalidate drops keys from the cache and tells the other instances
+func (c *Cached) invalidate(keys ...string) {
+	c.mu.Lock()
+	c.dropLocked(keys...)
+	c.mu.Unlock()
+
+	if ps, ok := c.store.(PubSub); ok {
+		if err := ps.Publish(invalidateChannel, invalidation{c.id, keys}); err != nil {
+			slog.Error("Cannot publish the cache invalidation", "keys", keys, "err", err)
+		}
+	}
+}
+
+func (c *Cached) Set(key string, val interface{}) error {
+	return c.SetWithTTL(key, val, 0)
+}
+
+func (c *Cached) SetWithTTL(key string, val interface{}, ttl time.Duration) error {
+	encoded, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	err = c.store.SetWithTTL(key, json.RawMessage(encoded), ttl)
+	c.invalidate(key)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	gen := c.gen
+	c.mu.Unlock()
+	c.put(key, string(encoded), ttl, gen)
+	return nil
+}
+
+func (c *Cached) ExpireAt(key string, at time.Time) error {
+	defer c.invalidate(key)
+	return c.store.ExpireAt(key, at)
+}
+
+func (c *Cached) TTL(key string) (time.Duration, error) {
+	return c.store.TTL(key)
+}
+
+func (c *Cached) Read(key string) (string, error) {
+	val, ok, gen := c.lookup(key)
+	if ok {
+		cacheRequests.WithLabelValues("hit").Inc()
+		return val, nil
+	}
+	cacheRequests.WithLabelValues("miss").Inc()
+	val, err := c.store.Read(key)
+	if err != nil {
+		return "", err
+	}
+	c.put(key, val, 0, gen)
+	return val, nil
+}
+
+// ReadMany reads from the store only the keys not cached
+func (c *Cached) ReadMany(keys ...string) (map[string]string, error) {
+	vals := make(map[string]string, len(keys))
+	var (
+		missing []string
+		gen     uint64
+	)
+	for _, k := range keys {
+		val, ok, g := c.lookup(k)
+		if ok {
+			cacheRequests.WithLabelValues("hit").Inc()
+			vals[k] = val
+			continue
+		}
+		cacheRequests.WithLabelValues("miss").Inc()
+		if len(missing) == 0 {
+			gen = g
+		}
+		missing = append(missing, k)
+	}
+	if len(missing) == 0 {
+		return vals, nil
+	}
+	read, err := c.store.ReadMany(missing...)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range read {
+		c.put(k, v, 0, gen)
+		vals[k] = v
+	}
+	return vals, nil
+}
+
+func (c *Cached) Get(key string, q interface{}) error {
+	val, err := c.Read(key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(val), q)
+}
+
+func (c *Cached) Delete(key string) error {
+	defer c.invalidate(key)
+	return c.store.Delete(key)
+}
+
+func (c *Cached) Keys(pattern string) ([]string, error) {
+	return c.store.Keys(pattern)
+}
+
+func (c *Cached) UpdateRaw(key string, fn func(old []byte) ([]byte, error)) error {
+	defer c.invalidate(key)
+	return c.store.UpdateRaw(key, fn)
+}
+
+func (c *Cached) Update(key string, q interface{}, fn func() error) error {
+	return updateJSON(c.UpdateRaw, key, q, fn)
+}
+
+func (c *Cached) Append(key string, vals ...interface{}) error {
+	return c.store.Append(key, vals...)
+}
+
+func (c *Cached) Range(key string, start, stop int64, q interface{}) error {
+	return c.store.Range(key, start, stop, q)
+}
+
+func (c *Cached) Pop(key string, q interface{}) error {
+	return c.store.Pop(key, q)
+}
+
+func (c *Cached) Move(src, dst string, q interface{}) error {
+	return c.store.Move(src, dst, q)
+}
+
+func (c *Cached) Remove(key string, val interface{}) error {
+	return c.store.Remove(key, val)
+}
+
+func (c *Cached) SAdd(key string, members ...string) error {
+	return c.store.SAdd(key, members...)
+}
+
+func (c *Cached) SRem(key string, members ...string) error {
+	return c.store.SRem(key, members...)
+}
+
+func (c *Cached) SMembers(key string) ([]string, error) {
+	return c.store.SMembers(key)
+}
+
+func (c *Cached) ZAdd(key string, member string, score float64) error {
+	return c.store.ZAdd(key, member, score)
+}
+
+func (c *Cached) ZRem(key string, member string) (bool, error) {
+	return c.store.ZRem(key, member)
+}
+
+func (c *Cached) ZRange(key string, start, stop int64) ([]Scored, error) {
+	return c.store.ZRange(key, start, stop)
+}
+
+func (c *Cached) ZRevRange(key string, start, stop int64) ([]Scored, error) {
+	return c.store.ZRevRange(key, start, stop)
+}
+
+// Type returns the type of key, if the underlying store has native types
+func (c *Cached) Type(key string) (string, error) {
+	if t, ok := c.store.(typer); ok {
+		return t.Type(key)
+	}
+	if _, err := c.store.Read(key); err != nil {
+		return "", err
+	}
+	return TypeString, nil
+}
+
+func (c *Cached) Publish(channel string, msg interface{}) error {
+	ps, ok := c.store.(PubSub)
+	if !ok {
+		return ErrNoPubSub
+	}
+	return ps.Publish(channel, msg)
+}
+
+func (c *Cached) Subscribe(channel string, fn func(msg []byte)) (func() error, error) {
+	ps, ok := c.store.(PubSub)
+	if !ok {
+		return nil, ErrNoPubSub
+	}
+	return ps.Subscribe(channel, fn)
+}
+
+// Batch returns a batch of the underlying store, dropping the written keys
+// from the cache on commit
+func (c *Cached) Batch() *Batch {
+	return newBatch(func(ops []batchOp) error {
+		keys := make([]string, len(ops))
+		for i, op := range ops {
+			keys[i] = op.key
+		}
+		defer c.invalidate(keys...)
+		inner := c.store.Batch()
+		inner.ops = ops
+		return inner.Commit()
+	})
+}
+
+func (c *Cached) Healthy() error {
+	return c.store.Healthy()
+}
+
+func (c *Cached) Close() error {
+	if c.stop != nil {
+		c.stop()
+	}
+	return c.store.Close()
+}
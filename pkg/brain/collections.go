@@ -0,0 +1,270 @@
+package brain
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Scored is a member of a sorted set
+type Scored struct {
+	Member string
+	Score  float64
+}
+
+// rawStore is what's needed to emulate the redis collections on a store
+// without native support for them
+type rawStore interface {
+	Read(key string) (string, error)
+	UpdateRaw(key string, fn func(old []byte) ([]byte, error)) error
+}
+
+// The stores without native collections keep each of them JSON encoded in
+// its key: a list is an array of values, a set a sorted array of strings and
+// a sorted set an array of Scored ordered by score.
+
+// decodeRange fills q, a pointer to a slice, with the JSON encoded elements
+func decodeRange(elems []string, q interface{}) error {
+	return json.Unmarshal([]byte("["+strings.Join(elems, ",")+"]"), q)
+}
+
+// rangeBounds converts the inclusive start and stop, negative if counting
+// from the end as in redis, to the bounds of a slice of length n
+func rangeBounds(start, stop int64, n int) (int, int) {
+	if start < 0 {
+		start += int64(n)
+	}
+	if stop < 0 {
+		stop += int64(n)
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= int64(n) {
+		stop = int64(n) - 1
+	}
+	if start > stop {
+		return 0, 0
+	}
+	return int(start), int(stop) + 1
+}
+
+func listAppend(s rawStore, key string, vals ...interface{}) error {
+	var encoded []json.RawMessage
+	for _, v := range vals {
+		e, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		encoded = append(encoded, e)
+	}
+	return s.UpdateRaw(key, func(old []byte) ([]byte, error) {
+		var list []json.RawMessage
+		if old != nil {
+			if err := json.Unmarshal(old, &list); err != nil {
+				return nil, err
+			}
+		}
+		return json.Marshal(append(list, encoded...))
+	})
+}
+
+func listRange(s rawStore, key string, start, stop int64, q interface{}) error {
+	var list []json.RawMessage
+	if err := readJSON(s, key, &list); err != nil {
+		return err
+	}
+	from, to := rangeBounds(start, stop, len(list))
+	var elems []string
+	for _, e := range list[from:to] {
+		elems = append(elems, string(e))
+	}
+	return decodeRange(elems, q)
+}
+
+// listPop removes the first element of the list, the list emptied is left
+// as an empty array
+func listPop(s rawStore, key string, q interface{}) error {
+	var first json.RawMessage
+	err := s.UpdateRaw(key, func(old []byte) ([]byte, error) {
+		var list []json.RawMessage
+		if old != nil {
+			if err := json.Unmarshal(old, &list); err != nil {
+				return nil, err
+			}
+		}
+		if len(list) == 0 {
+			return nil, ErrNotFound
+		}
+		first = list[0]
+		return json.Marshal(list[1:])
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(first, q)
+}
+
+// listMove pops the first element of the list at src and appends it to the
+// one at dst. Without native lists the two keys can't be changed together,
+// so the element is appended back to src if it can't be appended to dst.
+func listMove(s rawStore, src, dst string, q interface{}) error {
+	var elem json.RawMessage
+	if err := listPop(s, src, &elem); err != nil {
+		return err
+	}
+	if err := listAppend(s, dst, elem); err != nil {
+		listAppend(s, src, elem)
+		return err
+	}
+	return json.Unmarshal(elem, q)
+}
+
+// listRemove removes the elements of the list equal to val once encoded
+func listRemove(s rawStore, key string, val interface{}) error {
+	encoded, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return s.UpdateRaw(key, func(old []byte) ([]byte, error) {
+		var list []json.RawMessage
+		if old != nil {
+			if err := json.Unmarshal(old, &list); err != nil {
+				return nil, err
+			}
+		}
+		kept := []json.RawMessage{}
+		for _, e := range list {
+			if !bytes.Equal(e, encoded) {
+				kept = append(kept, e)
+			}
+		}
+		return json.Marshal(kept)
+	})
+}
+
+// readJSON decodes key into q, leaving it alone if key does not exist
+func readJSON(s rawStore, key string, q interface{}) error {
+	val, err := s.Read(key)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(val), q)
+}
+
+// updateSet applies fn to the members of the set at key
+func updateSet(s rawStore, key string, fn func(map[string]bool)) error {
+	return s.UpdateRaw(key, func(old []byte) ([]byte, error) {
+		var members []string
+		if old != nil {
+			if err := json.Unmarshal(old, &members); err != nil {
+				return nil, err
+			}
+		}
+		set := make(map[string]bool)
+		for _, m := range members {
+			set[m] = true
+		}
+		fn(set)
+		members = members[:0]
+		for m := range set {
+			members = append(members, m)
+		}
+		sort.Strings(members)
+		return json.Marshal(members)
+	})
+}
+
+func setAdd(s rawStore, key string, members ...string) error {
+	return updateSet(s, key, func(set map[string]bool) {
+		for _, m := range members {
+			set[m] = true
+		}
+	})
+}
+
+func setRemove(s rawStore, key string, members ...string) error {
+	return updateSet(s, key, func(set map[string]bool) {
+		for _, m := range members {
+			delete(set, m)
+		}
+	})
+}
+
+func setMembers(s rawStore, key string) ([]string, error) {
+	var members []string
+	err := readJSON(s, key, &members)
+	return members, err
+}
+
+func sortScored(zs []Scored) {
+	sort.Slice(zs, func(i, j int) bool {
+		if zs[i].Score != zs[j].Score {
+			return zs[i].Score < zs[j].Score
+		}
+		return zs[i].Member < zs[j].Member
+	})
+}
+
+func zsetAdd(s rawStore, key, member string, score float64) error {
+	return s.UpdateRaw(key, func(old []byte) ([]byte, error) {
+		var zs []Scored
+		if old != nil {
+			if err := json.Unmarshal(old, &zs); err != nil {
+				return nil, err
+			}
+		}
+		found := false
+		for i := range zs {
+			if zs[i].Member == member {
+				zs[i].Score, found = score, true
+			}
+		}
+		if !found {
+			zs = append(zs, Scored{member, score})
+		}
+		sortScored(zs)
+		return json.Marshal(zs)
+	})
+}
+
+func zsetRemove(s rawStore, key, member string) (bool, error) {
+	var removed bool
+	err := s.UpdateRaw(key, func(old []byte) ([]byte, error) {
+		var zs []Scored
+		if old != nil {
+			if err := json.Unmarshal(old, &zs); err != nil {
+				return nil, err
+			}
+		}
+		removed = false
+		kept := []Scored{}
+		for _, z := range zs {
+			if z.Member == member {
+				removed = true
+				continue
+			}
+			kept = append(kept, z)
+		}
+		return json.Marshal(kept)
+	})
+	return removed, err
+}
+
+func zsetRange(s rawStore, key string, start, stop int64, reverse bool) ([]Scored, error) {
+	var zs []Scored
+	if err := readJSON(s, key, &zs); err != nil {
+		return nil, err
+	}
+	if reverse {
+		for i, j := 0, len(zs)-1; i < j; i, j = i+1, j-1 {
+			zs[i], zs[j] = zs[j], zs[i]
+		}
+	}
+	from, to := rangeBounds(start, stop, len(zs))
+	return zs[from:to], nil
+}
@@ -0,0 +1,348 @@
+package brain
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// encryptedPrefix marks the encrypted values, the other ones are read as
+// they are, so that a store can be encrypted gradually. The values of
+// encryptedPrefixV1 were encrypted before being bound to their key, they are
+// read until they're written again.
+const (
+	encryptedPrefix   = "enc:v2:"
+	encryptedPrefixV1 = "enc:v1:"
+)
+
+// Encrypted is a view of a Store encrypting the values with AES-GCM, so
+// that the personal data is not kept in plaintext on a hosted server. Each
+// value is bound to its key, so that it can't be copied to another one; the
+// elements of the lists are not, they're moved between the lists by Move.
+// Keys, set members and pub/sub messages are not encrypted.
+type Encrypted struct {
+	store Store
+	aead  cipher.AEAD
+}
+
+// Encrypt returns the view of s encrypting the values with key, which must
+// be 16, 24 or 32 bytes long
+func Encrypt(s Store, key []byte) (*Encrypted, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Encrypted{s, aead}, nil
+}
+
+// KeyFromEnv returns the base64 encoded encryption key in
+// BRAIN_ENCRYPTION_KEY, nil if it's not set
+func KeyFromEnv() ([]byte, error) {
+	env := os.Getenv("BRAIN_ENCRYPTION_KEY")
+	if env == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(env)
+	if err != nil {
+		return nil, fmt.Errorf("brain: invalid BRAIN_ENCRYPTION_KEY: %v", err)
+	}
+	return key, nil
+}
+
+// seal encrypts the JSON value plain of key, empty for the elements of the
+// lists, returning it as a JSON string
+func (e *Encrypted) seal(key string, plain []byte) ([]byte, error) {
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	sealed := e.aead.Seal(nonce, nonce, plain, []byte(key))
+	return json.Marshal(encryptedPrefix + base64.StdEncoding.EncodeToString(sealed))
+}
+
+// open decrypts a value of key written by seal, the values not encrypted
+// are returned as they are
+func (e *Encrypted) open(key string, val []byte) ([]byte, error) {
+	var s string
+	if json.Unmarshal(val, &s) != nil {
+		return val, nil
+	}
+	var data []byte
+	switch {
+	case strings.HasPrefix(s, encryptedPrefix):
+		s, data = strings.TrimPrefix(s, encryptedPrefix), []byte(key)
+	case strings.HasPrefix(s, encryptedPrefixV1):
+		s = strings.TrimPrefix(s, encryptedPrefixV1)
+	default:
+		return val, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	n := e.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, errors.New("brain: encrypted value too short")
+	}
+	return e.aead.Open(nil, sealed[:n], sealed[n:], data)
+}
+
+func (e *Encrypted) Set(key string, val interface{}) error {
+	return e.SetWithTTL(key, val, 0)
+}
+
+func (e *Encrypted) SetWithTTL(key string, val interface{}, ttl time.Duration) error {
+	plain, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	sealed, err := e.seal(key, plain)
+	if err != nil {
+		return err
+	}
+	return e.store.SetWithTTL(key, json.RawMessage(sealed), ttl)
+}
+
+func (e *Encrypted) ExpireAt(key string, at time.Time) error {
+	return e.store.ExpireAt(key, at)
+}
+
+func (e *Encrypted) TTL(key string) (time.Duration, error) {
+	return e.store.TTL(key)
+}
+
+func (e *Encrypted) Read(key string) (string, error) {
+	val, err := e.store.Read(key)
+	if err != nil {
+		return "", err
+	}
+	plain, err := e.open(key, []byte(val))
+	return string(plain), err
+}
+
+func (e *Encrypted) ReadMany(keys ...string) (map[string]string, error) {
+	vals, err := e.store.ReadMany(keys...)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range vals {
+		plain, err := e.open(k, []byte(v))
+		if err != nil {
+			return nil, err
+		}
+		vals[k] = string(plain)
+	}
+	return vals, nil
+}
+
+func (e *Encrypted) Get(key string, q interface{}) error {
+	val, err := e.Read(key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(val), q)
+}
+
+func (e *Encrypted) Delete(key string) error {
+	return e.store.Delete(key)
+}
+
+func (e *Encrypted) Keys(pattern string) ([]string, error) {
+	return e.store.Keys(pattern)
+}
+
+func (e *Encrypted) UpdateRaw(key string, fn func(old []byte) ([]byte, error)) error {
+	return e.store.UpdateRaw(key, func(old []byte) ([]byte, error) {
+		if old != nil {
+			var err error
+			if old, err = e.open(key, old); err != nil {
+				return nil, err
+			}
+		}
+		plain, err := fn(old)
+		if err != nil {
+			return nil, err
+		}
+		return e.seal(key, plain)
+	})
+}
+
+func (e *Encrypted) Update(key string, q interface{}, fn func() error) error {
+	return updateJSON(e.UpdateRaw, key, q, fn)
+}
+
+// Append encrypts each element of the list on its own
+func (e *Encrypted) Append(key string, vals ...interface{}) error {
+	sealed := make([]interface{}, len(vals))
+	for i, v := range vals {
+		plain, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		s, err := e.seal("", plain)
+		if err != nil {
+			return err
+		}
+		sealed[i] = json.RawMessage(s)
+	}
+	return e.store.Append(key, sealed...)
+}
+
+func (e *Encrypted) Range(key string, start, stop int64, q interface{}) error {
+	var sealed []json.RawMessage
+	if err := e.store.Range(key, start, stop, &sealed); err != nil {
+		return err
+	}
+	elems := make([]string, len(sealed))
+	for i, s := range sealed {
+		plain, err := e.open("", s)
+		if err != nil {
+			return err
+		}
+		elems[i] = string(plain)
+	}
+	return decodeRange(elems, q)
+}
+
+func (e *Encrypted) SAdd(key string, members ...string) error {
+	return e.store.SAdd(key, members...)
+}
+
+func (e *Encrypted) SRem(key string, members ...string) error {
+	return e.store.SRem(key, members...)
+}
+
+func (e *Encrypted) SMembers(key string) ([]string, error) {
+	return e.store.SMembers(key)
+}
+
+func (e *Encrypted) ZAdd(key string, member string, score float64) error {
+	return e.store.ZAdd(key, member, score)
+}
+
+func (e *Encrypted) ZRem(key string, member string) (bool, error) {
+	return e.store.ZRem(key, member)
+}
+
+func (e *Encrypted) ZRange(key string, start, stop int64) ([]Scored, error) {
+	return e.store.ZRange(key, start, stop)
+}
+
+func (e *Encrypted) ZRevRange(key string, start, stop int64) ([]Scored, error) {
+	return e.store.ZRevRange(key, start, stop)
+}
+
+func (e *Encrypted) Pop(key string, q interface{}) error {
+	var sealed json.RawMessage
+	if err := e.store.Pop(key, &sealed); err != nil {
+		return err
+	}
+	plain, err := e.open("", sealed)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(plain, q)
+}
+
+func (e *Encrypted) Move(src, dst string, q interface{}) error {
+	var sealed json.RawMessage
+	if err := e.store.Move(src, dst, &sealed); err != nil {
+		return err
+	}
+	plain, err := e.open("", sealed)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(plain, q)
+}
+
+// Remove finds the elements equal to val once decrypted, each of them is
+// encrypted with its own nonce
+func (e *Encrypted) Remove(key string, val interface{}) error {
+	encoded, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	var sealed []json.RawMessage
+	if err := e.store.Range(key, 0, -1, &sealed); err != nil {
+		return err
+	}
+	for _, s := range sealed {
+		plain, err := e.open("", s)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(plain, encoded) {
+			if err := e.store.Remove(key, s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Type returns the type of key, if the underlying store has native types
+func (e *Encrypted) Type(key string) (string, error) {
+	if t, ok := e.store.(typer); ok {
+		return t.Type(key)
+	}
+	if _, err := e.store.Read(key); err != nil {
+		return "", err
+	}
+	return TypeString, nil
+}
+
+func (e *Encrypted) Publish(channel string, msg interface{}) error {
+	ps, ok := e.store.(PubSub)
+	if !ok {
+		return ErrNoPubSub
+	}
+	return ps.Publish(channel, msg)
+}
+
+func (e *Encrypted) Subscribe(channel string, fn func(msg []byte)) (func() error, error) {
+	ps, ok := e.store.(PubSub)
+	if !ok {
+		return nil, ErrNoPubSub
+	}
+	return ps.Subscribe(channel, fn)
+}
+
+// Batch returns a batch of the underlying store, encrypting the values
+func (e *Encrypted) Batch() *Batch {
+	return newBatch(func(ops []batchOp) error {
+		inner := e.store.Batch()
+		for _, op := range ops {
+			if op.val != nil {
+				sealed, err := e.seal(op.key, op.val)
+				if err != nil {
+					return err
+				}
+				op.val = sealed
+			}
+			inner.ops = append(inner.ops, op)
+		}
+		return inner.Commit()
+	})
+}
+
+func (e *Encrypted) Healthy() error {
+	return e.store.Healthy()
+}
+
+func (e *Encrypted) Close() error {
+	return e.store.Close()
+}
@@ -0,0 +1,238 @@
+package brain
+
+import (
+	"time"
+
+	"github.com/develersrl/lunches/pkg/staging"
+)
+
+// Faulty is a view of a Store failing the operations as if Redis was down
+// while staging mode simulates it, see staging.Inject
+type Faulty struct {
+	Store
+}
+
+// Fault returns the view of s failing while staging mode simulates it
+func Fault(s Store) *Faulty {
+	return &Faulty{s}
+}
+
+// fail returns the error of the operation, if it has to fail
+func (s *Faulty) fail() error {
+	if staging.Fail(staging.Redis) {
+		return staging.ErrRedisDown
+	}
+	return nil
+}
+
+func (s *Faulty) Set(key string, val interface{}) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.Store.Set(key, val)
+}
+
+func (s *Faulty) SetWithTTL(key string, val interface{}, ttl time.Duration) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.Store.SetWithTTL(key, val, ttl)
+}
+
+func (s *Faulty) ExpireAt(key string, at time.Time) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.Store.ExpireAt(key, at)
+}
+
+func (s *Faulty) TTL(key string) (time.Duration, error) {
+	if err := s.fail(); err != nil {
+		return 0, err
+	}
+	return s.Store.TTL(key)
+}
+
+func (s *Faulty) Get(key string, q interface{}) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.Store.Get(key, q)
+}
+
+func (s *Faulty) Read(key string) (string, error) {
+	if err := s.fail(); err != nil {
+		return "", err
+	}
+	return s.Store.Read(key)
+}
+
+func (s *Faulty) ReadMany(keys ...string) (map[string]string, error) {
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return s.Store.ReadMany(keys...)
+}
+
+func (s *Faulty) Delete(key string) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.Store.Delete(key)
+}
+
+func (s *Faulty) Keys(pattern string) ([]string, error) {
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return s.Store.Keys(pattern)
+}
+
+func (s *Faulty) UpdateRaw(key string, fn func(old []byte) ([]byte, error)) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.Store.UpdateRaw(key, fn)
+}
+
+func (s *Faulty) Update(key string, q interface{}, fn func() error) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.Store.Update(key, q, fn)
+}
+
+func (s *Faulty) Append(key string, vals ...interface{}) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.Store.Append(key, vals...)
+}
+
+func (s *Faulty) Range(key string, start, stop int64, q interface{}) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.Store.Range(key, start, stop, q)
+}
+
+func (s *Faulty) Pop(key string, q interface{}) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.Store.Pop(key, q)
+}
+
+func (s *Faulty) Move(src, dst string, q interface{}) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.Store.Move(src, dst, q)
+}
+
+func (s *Faulty) Remove(key string, val interface{}) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.Store.Remove(key, val)
+}
+
+func (s *Faulty) SAdd(key string, members ...string) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.Store.SAdd(key, members...)
+}
+
+func (s *Faulty) SRem(key string, members ...string) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.Store.SRem(key, members...)
+}
+
+func (s *Faulty) SMembers(key string) ([]string, error) {
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return s.Store.SMembers(key)
+}
+
+func (s *Faulty) ZAdd(key string, member string, score float64) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.Store.ZAdd(key, member, score)
+}
+
+func (s *Faulty) ZRem(key string, member string) (bool, error) {
+	if err := s.fail(); err != nil {
+		return false, err
+	}
+	return s.Store.ZRem(key, member)
+}
+
+func (s *Faulty) ZRange(key string, start, stop int64) ([]Scored, error) {
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return s.Store.ZRange(key, start, stop)
+}
+
+func (s *Faulty) ZRevRange(key string, start, stop int64) ([]Scored, error) {
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return s.Store.ZRevRange(key, start, stop)
+}
+
+// Batch returns a batch of the underlying store, failing on commit
+func (s *Faulty) Batch() *Batch {
+	return newBatch(func(ops []batchOp) error {
+		if err := s.fail(); err != nil {
+			return err
+		}
+		inner := s.Store.Batch()
+		inner.ops = ops
+		return inner.Commit()
+	})
+}
+
+func (s *Faulty) Publish(channel string, msg interface{}) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	ps, ok := s.Store.(PubSub)
+	if !ok {
+		return ErrNoPubSub
+	}
+	return ps.Publish(channel, msg)
+}
+
+func (s *Faulty) Subscribe(channel string, fn func(msg []byte)) (func() error, error) {
+	ps, ok := s.Store.(PubSub)
+	if !ok {
+		return nil, ErrNoPubSub
+	}
+	return ps.Subscribe(channel, fn)
+}
+
+func (s *Faulty) Healthy() error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.Store.Healthy()
+}
+
+func (s *Faulty) Type(key string) (string, error) {
+	if err := s.fail(); err != nil {
+		return "", err
+	}
+	if t, ok := s.Store.(typer); ok {
+		return t.Type(key)
+	}
+	if _, err := s.Store.Read(key); err != nil {
+		return "", err
+	}
+	return TypeString, nil
+}
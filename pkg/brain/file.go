@@ -0,0 +1,139 @@
+package brain
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fileData is the content of the file store
+type fileData struct {
+	Data    map[string]string
+	Expires map[string]time.Time `json:",omitempty"`
+}
+
+// OpenFile returns a Store keeping the data in memory and saving it to the
+// JSON file at path after each change. Good enough for running the bot
+// locally, not for a busy deploy. The processes sharing the file, e.g. the
+// web server and the grift tasks, take turns with a lock on path.lock and
+// reload the file when the others change it.
+func OpenFile(path string) (*Memory, error) {
+	m := NewMemory()
+	f := &file{path: path, m: m}
+	release, err := f.sync()
+	if err != nil {
+		return nil, err
+	}
+	release()
+
+	m.sync = f.sync
+	m.persist = f.persist
+	return m, nil
+}
+
+// file is the file of a file store. Its lock file holds the generation of
+// the data, incremented on each write, so that the processes know when to
+// reload it.
+type file struct {
+	path string
+	m    *Memory
+	// lock is the lock file, while taken
+	lock *os.File
+	// gen is the generation of the data loaded, valid if loaded
+	gen    uint64
+	loaded bool
+}
+
+// sync takes the lock of the file and reloads it if another process changed
+// it since it was loaded
+func (f *file) sync() (func(), error) {
+	lock, err := os.OpenFile(f.path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(lock); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	release := func() {
+		f.lock = nil
+		unlockFile(lock)
+		lock.Close()
+	}
+	f.lock = lock
+
+	buf, err := ioutil.ReadAll(lock)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	gen, _ := strconv.ParseUint(strings.TrimSpace(string(buf)), 10, 64)
+	if f.loaded && gen == f.gen {
+		return release, nil
+	}
+	if err := f.load(); err != nil {
+		release()
+		return nil, err
+	}
+	f.gen, f.loaded = gen, true
+	return release, nil
+}
+
+// load reads the data of the file, none if it's missing
+func (f *file) load() error {
+	d := fileData{Data: make(map[string]string), Expires: make(map[string]time.Time)}
+	buf, err := ioutil.ReadFile(f.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(buf) > 0 {
+		if err := json.Unmarshal(buf, &d); err != nil {
+			return err
+		}
+	}
+	if d.Data == nil {
+		d.Data = make(map[string]string)
+	}
+	if d.Expires == nil {
+		d.Expires = make(map[string]time.Time)
+	}
+	f.m.data, f.m.expires = d.Data, d.Expires
+	return nil
+}
+
+// persist writes the data to the file and bumps its generation, holding
+// its lock
+func (f *file) persist() error {
+	buf, err := json.MarshalIndent(fileData{f.m.data, f.m.expires}, "", "  ")
+	if err != nil {
+		return err
+	}
+	// write and rename, so that a crash doesn't leave a truncated file
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return err
+	}
+
+	f.gen++
+	if err := f.lock.Truncate(0); err != nil {
+		return err
+	}
+	_, err = f.lock.WriteAt([]byte(strconv.FormatUint(f.gen, 10)), 0)
+	return err
+}
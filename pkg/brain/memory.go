@@ -0,0 +1,363 @@
+package brain
+
+import (
+	"encoding/json"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory is a Store keeping the data in memory, safe for concurrent use
+type Memory struct {
+	mu      sync.Mutex
+	data    map[string]string
+	expires map[string]time.Time
+	// persist is called with the lock held after each change
+	persist func() error
+	// sync, if set, is called taking the lock, before each access: it
+	// returns the function releasing what it took
+	sync func() (func(), error)
+
+	subscribers map[string]map[int]func([]byte)
+	lastSub     int
+}
+
+// NewMemory returns an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{
+		data:        make(map[string]string),
+		expires:     make(map[string]time.Time),
+		subscribers: make(map[string]map[int]func([]byte)),
+	}
+}
+
+// store sets the encoded val of key, leaving its expiration alone
+func (m *Memory) store(key string, val interface{}) error {
+	encoded, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	m.data[key] = string(encoded)
+	return m.save()
+}
+
+// lock takes the lock of the store, see sync, and returns the function
+// releasing it
+func (m *Memory) lock() (func(), error) {
+	m.mu.Lock()
+	if m.sync == nil {
+		return m.mu.Unlock, nil
+	}
+	release, err := m.sync()
+	if err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		release()
+		m.mu.Unlock()
+	}, nil
+}
+
+func (m *Memory) save() error {
+	if m.persist == nil {
+		return nil
+	}
+	return m.persist()
+}
+
+// expired deletes key if it's expired, and tells so
+func (m *Memory) expired(key string, now time.Time) bool {
+	at, ok := m.expires[key]
+	if !ok || now.Before(at) {
+		return false
+	}
+	delete(m.data, key)
+	delete(m.expires, key)
+	return true
+}
+
+func (m *Memory) Set(key string, val interface{}) error {
+	return m.SetWithTTL(key, val, 0)
+}
+
+func (m *Memory) SetWithTTL(key string, val interface{}, ttl time.Duration) error {
+	unlock, err := m.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if ttl > 0 {
+		m.expires[key] = time.Now().Add(ttl)
+	} else {
+		delete(m.expires, key)
+	}
+	return m.store(key, val)
+}
+
+func (m *Memory) ExpireAt(key string, at time.Time) error {
+	unlock, err := m.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if _, ok := m.data[key]; !ok || m.expired(key, time.Now()) {
+		return ErrNotFound
+	}
+	m.expires[key] = at
+	m.expired(key, time.Now())
+	return m.save()
+}
+
+func (m *Memory) TTL(key string) (time.Duration, error) {
+	unlock, err := m.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	now := time.Now()
+	if _, ok := m.data[key]; !ok || m.expired(key, now) {
+		return 0, ErrNotFound
+	}
+	if at, ok := m.expires[key]; ok {
+		return at.Sub(now), nil
+	}
+	return 0, nil
+}
+
+func (m *Memory) Read(key string) (string, error) {
+	unlock, err := m.lock()
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	m.expired(key, time.Now())
+	val, ok := m.data[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return val, nil
+}
+
+func (m *Memory) ReadMany(keys ...string) (map[string]string, error) {
+	unlock, err := m.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	now := time.Now()
+	vals := make(map[string]string, len(keys))
+	for _, k := range keys {
+		m.expired(k, now)
+		if val, ok := m.data[k]; ok {
+			vals[k] = val
+		}
+	}
+	return vals, nil
+}
+
+func (m *Memory) Get(key string, q interface{}) error {
+	val, err := m.Read(key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(val), q)
+}
+
+func (m *Memory) Delete(key string) error {
+	unlock, err := m.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	delete(m.data, key)
+	delete(m.expires, key)
+	return m.save()
+}
+
+// Keys returns the sorted keys matching the glob pattern, as in redis KEYS
+func (m *Memory) Keys(pattern string) ([]string, error) {
+	unlock, err := m.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	now := time.Now()
+	var keys []string
+	for k := range m.data {
+		if m.expired(k, now) {
+			continue
+		}
+		ok, err := path.Match(pattern, k)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// UpdateRaw calls fn with the current value of key, nil if it does not
+// exist, and writes back the returned value. fn is called without the lock,
+// so it can read the store, and again if key changed in the meantime. The
+// expiration of key is kept.
+func (m *Memory) UpdateRaw(key string, fn func(old []byte) ([]byte, error)) error {
+	for {
+		unlock, err := m.lock()
+		if err != nil {
+			return err
+		}
+		m.expired(key, time.Now())
+		val, ok := m.data[key]
+		unlock()
+
+		var old []byte
+		if ok {
+			old = []byte(val)
+		}
+		encoded, err := fn(old)
+		if err != nil {
+			return err
+		}
+
+		if unlock, err = m.lock(); err != nil {
+			return err
+		}
+		m.expired(key, time.Now())
+		if cur, curOK := m.data[key]; cur != val || curOK != ok {
+			unlock()
+			continue
+		}
+		m.data[key] = string(encoded)
+		err = m.save()
+		unlock()
+		return err
+	}
+}
+
+// Update reads key into q, calls fn to modify it and writes q back, see UpdateRaw
+func (m *Memory) Update(key string, q interface{}, fn func() error) error {
+	return updateJSON(m.UpdateRaw, key, q, fn)
+}
+
+func (m *Memory) Append(key string, vals ...interface{}) error {
+	return listAppend(m, key, vals...)
+}
+
+func (m *Memory) Range(key string, start, stop int64, q interface{}) error {
+	return listRange(m, key, start, stop, q)
+}
+
+func (m *Memory) Pop(key string, q interface{}) error {
+	return listPop(m, key, q)
+}
+
+func (m *Memory) Move(src, dst string, q interface{}) error {
+	return listMove(m, src, dst, q)
+}
+
+func (m *Memory) Remove(key string, val interface{}) error {
+	return listRemove(m, key, val)
+}
+
+func (m *Memory) SAdd(key string, members ...string) error {
+	return setAdd(m, key, members...)
+}
+
+func (m *Memory) SRem(key string, members ...string) error {
+	return setRemove(m, key, members...)
+}
+
+func (m *Memory) SMembers(key string) ([]string, error) {
+	return setMembers(m, key)
+}
+
+func (m *Memory) ZAdd(key string, member string, score float64) error {
+	return zsetAdd(m, key, member, score)
+}
+
+func (m *Memory) ZRem(key string, member string) (bool, error) {
+	return zsetRemove(m, key, member)
+}
+
+func (m *Memory) ZRange(key string, start, stop int64) ([]Scored, error) {
+	return zsetRange(m, key, start, stop, false)
+}
+
+func (m *Memory) ZRevRange(key string, start, stop int64) ([]Scored, error) {
+	return zsetRange(m, key, start, stop, true)
+}
+
+// Publish calls the subscribers of channel in the calling goroutine, they
+// only live in this process
+func (m *Memory) Publish(channel string, msg interface{}) error {
+	encoded, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	var subs []func([]byte)
+	for _, fn := range m.subscribers[channel] {
+		subs = append(subs, fn)
+	}
+	m.mu.Unlock()
+
+	for _, fn := range subs {
+		fn(encoded)
+	}
+	return nil
+}
+
+func (m *Memory) Subscribe(channel string, fn func(msg []byte)) (func() error, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.subscribers[channel] == nil {
+		m.subscribers[channel] = make(map[int]func([]byte))
+	}
+	m.lastSub++
+	id := m.lastSub
+	m.subscribers[channel][id] = fn
+	return func() error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.subscribers[channel], id)
+		return nil
+	}, nil
+}
+
+// Batch returns a batch applied holding the lock, and saved once
+func (m *Memory) Batch() *Batch {
+	return newBatch(func(ops []batchOp) error {
+		unlock, err := m.lock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		now := time.Now()
+		for _, op := range ops {
+			delete(m.expires, op.key)
+			if op.val == nil {
+				delete(m.data, op.key)
+				continue
+			}
+			m.data[op.key] = string(op.val)
+			if op.ttl > 0 {
+				m.expires[op.key] = now.Add(op.ttl)
+			}
+		}
+		return m.save()
+	})
+}
+
+// Healthy always returns nil, there's no server to reach
+func (m *Memory) Healthy() error {
+	return nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
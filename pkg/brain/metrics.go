@@ -0,0 +1,248 @@
+package brain
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	opsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "brain_operations_total",
+		Help: "Brain operations by operation, key namespace and result.",
+	}, []string{"op", "namespace", "result"})
+
+	opsDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "brain_operation_duration_seconds",
+		Help:    "Latency of the brain operations by operation and key namespace.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"op", "namespace"})
+)
+
+func init() {
+	prometheus.MustRegister(opsTotal, opsDuration)
+}
+
+// defaultSlowOp is the latency after which an operation is logged
+const defaultSlowOp = 100 * time.Millisecond
+
+// Instrumented is a view of a Store counting and timing every operation,
+// and logging the slow ones
+type Instrumented struct {
+	store  Store
+	slowOp time.Duration
+}
+
+// Instrument returns the instrumented view of s, the operations slower than
+// BRAIN_SLOW_OP, e.g. "50ms", are logged
+func Instrument(s Store) *Instrumented {
+	slowOp := defaultSlowOp
+	if d, err := time.ParseDuration(os.Getenv("BRAIN_SLOW_OP")); err == nil && d > 0 {
+		slowOp = d
+	}
+	return &Instrumented{s, slowOp}
+}
+
+// keyNamespace returns the first part of key, e.g. "order" for
+// "order:2019-03-14", so that the metrics have a bounded number of labels
+func keyNamespace(key string) string {
+	ns := key
+	if i := strings.IndexAny(key, ":*?["); i >= 0 {
+		ns = key[:i]
+	}
+	if ns == "" {
+		return "*"
+	}
+	return ns
+}
+
+// firstKey returns the first of keys, to label the operations on many keys
+// with the namespace of the first one
+func firstKey(keys []string) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	return keys[0]
+}
+
+func (s *Instrumented) observe(op, key string, start time.Time, err error) {
+	d := time.Since(start)
+	ns := keyNamespace(key)
+	result := "ok"
+	switch {
+	case err == ErrNotFound:
+		result = "not_found"
+	case err != nil:
+		result = "error"
+	}
+	opsTotal.WithLabelValues(op, ns, result).Inc()
+	opsDuration.WithLabelValues(op, ns).Observe(d.Seconds())
+	if d >= s.slowOp {
+		slog.Warn("Slow brain operation", "op", op, "key", key, "duration", d.String())
+	}
+}
+
+func (s *Instrumented) Set(key string, val interface{}) (err error) {
+	defer func(start time.Time) { s.observe("set", key, start, err) }(time.Now())
+	return s.store.Set(key, val)
+}
+
+func (s *Instrumented) SetWithTTL(key string, val interface{}, ttl time.Duration) (err error) {
+	defer func(start time.Time) { s.observe("set", key, start, err) }(time.Now())
+	return s.store.SetWithTTL(key, val, ttl)
+}
+
+func (s *Instrumented) ExpireAt(key string, at time.Time) (err error) {
+	defer func(start time.Time) { s.observe("expire", key, start, err) }(time.Now())
+	return s.store.ExpireAt(key, at)
+}
+
+func (s *Instrumented) TTL(key string) (ttl time.Duration, err error) {
+	defer func(start time.Time) { s.observe("ttl", key, start, err) }(time.Now())
+	return s.store.TTL(key)
+}
+
+func (s *Instrumented) Get(key string, q interface{}) (err error) {
+	defer func(start time.Time) { s.observe("get", key, start, err) }(time.Now())
+	return s.store.Get(key, q)
+}
+
+func (s *Instrumented) Read(key string) (val string, err error) {
+	defer func(start time.Time) { s.observe("get", key, start, err) }(time.Now())
+	return s.store.Read(key)
+}
+
+func (s *Instrumented) ReadMany(keys ...string) (vals map[string]string, err error) {
+	defer func(start time.Time) { s.observe("mget", firstKey(keys), start, err) }(time.Now())
+	return s.store.ReadMany(keys...)
+}
+
+func (s *Instrumented) Delete(key string) (err error) {
+	defer func(start time.Time) { s.observe("delete", key, start, err) }(time.Now())
+	return s.store.Delete(key)
+}
+
+func (s *Instrumented) Keys(pattern string) (keys []string, err error) {
+	defer func(start time.Time) { s.observe("keys", pattern, start, err) }(time.Now())
+	return s.store.Keys(pattern)
+}
+
+func (s *Instrumented) UpdateRaw(key string, fn func(old []byte) ([]byte, error)) (err error) {
+	defer func(start time.Time) { s.observe("update", key, start, err) }(time.Now())
+	return s.store.UpdateRaw(key, fn)
+}
+
+func (s *Instrumented) Update(key string, q interface{}, fn func() error) (err error) {
+	defer func(start time.Time) { s.observe("update", key, start, err) }(time.Now())
+	return s.store.Update(key, q, fn)
+}
+
+func (s *Instrumented) Append(key string, vals ...interface{}) (err error) {
+	defer func(start time.Time) { s.observe("append", key, start, err) }(time.Now())
+	return s.store.Append(key, vals...)
+}
+
+func (s *Instrumented) Range(key string, start, stop int64, q interface{}) (err error) {
+	defer func(t time.Time) { s.observe("range", key, t, err) }(time.Now())
+	return s.store.Range(key, start, stop, q)
+}
+
+func (s *Instrumented) Pop(key string, q interface{}) (err error) {
+	defer func(start time.Time) { s.observe("pop", key, start, err) }(time.Now())
+	return s.store.Pop(key, q)
+}
+
+func (s *Instrumented) Move(src, dst string, q interface{}) (err error) {
+	defer func(start time.Time) { s.observe("move", src, start, err) }(time.Now())
+	return s.store.Move(src, dst, q)
+}
+
+func (s *Instrumented) Remove(key string, val interface{}) (err error) {
+	defer func(start time.Time) { s.observe("remove", key, start, err) }(time.Now())
+	return s.store.Remove(key, val)
+}
+
+func (s *Instrumented) SAdd(key string, members ...string) (err error) {
+	defer func(start time.Time) { s.observe("sadd", key, start, err) }(time.Now())
+	return s.store.SAdd(key, members...)
+}
+
+func (s *Instrumented) SRem(key string, members ...string) (err error) {
+	defer func(start time.Time) { s.observe("srem", key, start, err) }(time.Now())
+	return s.store.SRem(key, members...)
+}
+
+func (s *Instrumented) SMembers(key string) (members []string, err error) {
+	defer func(start time.Time) { s.observe("smembers", key, start, err) }(time.Now())
+	return s.store.SMembers(key)
+}
+
+func (s *Instrumented) ZAdd(key string, member string, score float64) (err error) {
+	defer func(start time.Time) { s.observe("zadd", key, start, err) }(time.Now())
+	return s.store.ZAdd(key, member, score)
+}
+
+func (s *Instrumented) ZRem(key string, member string) (removed bool, err error) {
+	defer func(start time.Time) { s.observe("zrem", key, start, err) }(time.Now())
+	return s.store.ZRem(key, member)
+}
+
+func (s *Instrumented) ZRange(key string, start, stop int64) (zs []Scored, err error) {
+	defer func(t time.Time) { s.observe("zrange", key, t, err) }(time.Now())
+	return s.store.ZRange(key, start, stop)
+}
+
+func (s *Instrumented) ZRevRange(key string, start, stop int64) (zs []Scored, err error) {
+	defer func(t time.Time) { s.observe("zrange", key, t, err) }(time.Now())
+	return s.store.ZRevRange(key, start, stop)
+}
+
+// Batch returns a batch of the underlying store, timed on commit
+func (s *Instrumented) Batch() *Batch {
+	return newBatch(func(ops []batchOp) (err error) {
+		defer func(start time.Time) { s.observe("batch", "", start, err) }(time.Now())
+		inner := s.store.Batch()
+		inner.ops = ops
+		return inner.Commit()
+	})
+}
+
+func (s *Instrumented) Type(key string) (t string, err error) {
+	defer func(start time.Time) { s.observe("type", key, start, err) }(time.Now())
+	if tp, ok := s.store.(typer); ok {
+		return tp.Type(key)
+	}
+	if _, err := s.store.Read(key); err != nil {
+		return "", err
+	}
+	return TypeString, nil
+}
+
+func (s *Instrumented) Publish(channel string, msg interface{}) (err error) {
+	defer func(start time.Time) { s.observe("publish", channel, start, err) }(time.Now())
+	ps, ok := s.store.(PubSub)
+	if !ok {
+		return ErrNoPubSub
+	}
+	return ps.Publish(channel, msg)
+}
+
+func (s *Instrumented) Subscribe(channel string, fn func(msg []byte)) (func() error, error) {
+	ps, ok := s.store.(PubSub)
+	if !ok {
+		return nil, ErrNoPubSub
+	}
+	return ps.Subscribe(channel, fn)
+}
+
+func (s *Instrumented) Healthy() (err error) {
+	defer func(start time.Time) { s.observe("ping", "", start, err) }(time.Now())
+	return s.store.Healthy()
+}
+
+func (s *Instrumented) Close() error {
+	return s.store.Close()
+}
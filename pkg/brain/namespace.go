@@ -0,0 +1,201 @@
+package brain
+
+import (
+	"strings"
+	"time"
+)
+
+// Namespaced is a view of a Store where all the keys are prefixed, so that
+// several teams or channels can share the same store without collisions
+type Namespaced struct {
+	store  Store
+	prefix string
+}
+
+// Namespace returns the view of s prefixing all the keys with prefix and a
+// colon, namespaces can be nested
+func Namespace(s Store, prefix string) *Namespaced {
+	return &Namespaced{s, prefix + ":"}
+}
+
+// Namespace returns a view of the brain prefixing all the keys, see Namespace
+func (b *Brain) Namespace(prefix string) *Namespaced {
+	return Namespace(b, prefix)
+}
+
+// Key returns key as written in the store under the namespace, with the
+// prefixes of the nested namespaces
+func (n *Namespaced) Key(key string) string {
+	if inner, ok := n.store.(*Namespaced); ok {
+		return inner.Key(n.prefix + key)
+	}
+	return n.prefix + key
+}
+
+// escapeGlob escapes the characters having a meaning in a glob pattern
+func escapeGlob(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`).Replace(s)
+}
+
+func (n *Namespaced) Set(key string, val interface{}) error {
+	return n.store.Set(n.prefix+key, val)
+}
+
+func (n *Namespaced) SetWithTTL(key string, val interface{}, ttl time.Duration) error {
+	return n.store.SetWithTTL(n.prefix+key, val, ttl)
+}
+
+func (n *Namespaced) ExpireAt(key string, at time.Time) error {
+	return n.store.ExpireAt(n.prefix+key, at)
+}
+
+func (n *Namespaced) TTL(key string) (time.Duration, error) {
+	return n.store.TTL(n.prefix + key)
+}
+
+// Type returns the type of key, if the underlying store has native types
+func (n *Namespaced) Type(key string) (string, error) {
+	if t, ok := n.store.(typer); ok {
+		return t.Type(n.prefix + key)
+	}
+	if _, err := n.store.Read(n.prefix + key); err != nil {
+		return "", err
+	}
+	return TypeString, nil
+}
+
+func (n *Namespaced) Get(key string, q interface{}) error {
+	return n.store.Get(n.prefix+key, q)
+}
+
+func (n *Namespaced) Read(key string) (string, error) {
+	return n.store.Read(n.prefix + key)
+}
+
+func (n *Namespaced) ReadMany(keys ...string) (map[string]string, error) {
+	prefixed := make([]string, len(keys))
+	for i, k := range keys {
+		prefixed[i] = n.prefix + k
+	}
+	vals, err := n.store.ReadMany(prefixed...)
+	if err != nil {
+		return nil, err
+	}
+	unprefixed := make(map[string]string, len(vals))
+	for k, v := range vals {
+		unprefixed[strings.TrimPrefix(k, n.prefix)] = v
+	}
+	return unprefixed, nil
+}
+
+func (n *Namespaced) Delete(key string) error {
+	return n.store.Delete(n.prefix + key)
+}
+
+// Keys returns the sorted keys of the namespace matching the glob pattern,
+// without the prefix
+func (n *Namespaced) Keys(pattern string) ([]string, error) {
+	keys, err := n.store.Keys(escapeGlob(n.prefix) + pattern)
+	if err != nil {
+		return nil, err
+	}
+	for i, k := range keys {
+		keys[i] = strings.TrimPrefix(k, n.prefix)
+	}
+	return keys, nil
+}
+
+func (n *Namespaced) UpdateRaw(key string, fn func(old []byte) ([]byte, error)) error {
+	return n.store.UpdateRaw(n.prefix+key, fn)
+}
+
+func (n *Namespaced) Update(key string, q interface{}, fn func() error) error {
+	return n.store.Update(n.prefix+key, q, fn)
+}
+
+func (n *Namespaced) Append(key string, vals ...interface{}) error {
+	return n.store.Append(n.prefix+key, vals...)
+}
+
+func (n *Namespaced) Range(key string, start, stop int64, q interface{}) error {
+	return n.store.Range(n.prefix+key, start, stop, q)
+}
+
+func (n *Namespaced) Pop(key string, q interface{}) error {
+	return n.store.Pop(n.prefix+key, q)
+}
+
+func (n *Namespaced) Move(src, dst string, q interface{}) error {
+	return n.store.Move(n.prefix+src, n.prefix+dst, q)
+}
+
+func (n *Namespaced) Remove(key string, val interface{}) error {
+	return n.store.Remove(n.prefix+key, val)
+}
+
+func (n *Namespaced) SAdd(key string, members ...string) error {
+	return n.store.SAdd(n.prefix+key, members...)
+}
+
+func (n *Namespaced) SRem(key string, members ...string) error {
+	return n.store.SRem(n.prefix+key, members...)
+}
+
+func (n *Namespaced) SMembers(key string) ([]string, error) {
+	return n.store.SMembers(n.prefix + key)
+}
+
+func (n *Namespaced) ZAdd(key string, member string, score float64) error {
+	return n.store.ZAdd(n.prefix+key, member, score)
+}
+
+func (n *Namespaced) ZRem(key string, member string) (bool, error) {
+	return n.store.ZRem(n.prefix+key, member)
+}
+
+func (n *Namespaced) ZRange(key string, start, stop int64) ([]Scored, error) {
+	return n.store.ZRange(n.prefix+key, start, stop)
+}
+
+func (n *Namespaced) ZRevRange(key string, start, stop int64) ([]Scored, error) {
+	return n.store.ZRevRange(n.prefix+key, start, stop)
+}
+
+// Publish publishes on the channel prefixed as the keys
+func (n *Namespaced) Publish(channel string, msg interface{}) error {
+	ps, ok := n.store.(PubSub)
+	if !ok {
+		return ErrNoPubSub
+	}
+	return ps.Publish(n.prefix+channel, msg)
+}
+
+// Subscribe subscribes to the channel prefixed as the keys
+func (n *Namespaced) Subscribe(channel string, fn func(msg []byte)) (func() error, error) {
+	ps, ok := n.store.(PubSub)
+	if !ok {
+		return nil, ErrNoPubSub
+	}
+	return ps.Subscribe(n.prefix+channel, fn)
+}
+
+// Batch returns a batch of the underlying store, prefixing the keys
+func (n *Namespaced) Batch() *Batch {
+	return newBatch(func(ops []batchOp) error {
+		inner := n.store.Batch()
+		for _, op := range ops {
+			op.key = n.prefix + op.key
+			inner.ops = append(inner.ops, op)
+		}
+		return inner.Commit()
+	})
+}
+
+func (n *Namespaced) Healthy() error {
+	return n.store.Healthy()
+}
+
+// Close closes the underlying store, shared with the other namespaces
+func (n *Namespaced) Close() error {
+	return n.store.Close()
+}
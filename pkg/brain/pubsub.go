@@ -0,0 +1,18 @@
+package brain
+
+import (
+	"errors"
+)
+
+// PubSub is implemented by the stores able to broadcast messages to all the
+// bot instances sharing them
+type PubSub interface {
+	// Publish sends the JSON encoded msg to the subscribers of channel
+	Publish(channel string, msg interface{}) error
+	// Subscribe calls fn with each message published on channel, until the
+	// returned function is called
+	Subscribe(channel string, fn func(msg []byte)) (func() error, error)
+}
+
+// ErrNoPubSub is returned when the store does not support pub/sub
+var ErrNoPubSub = errors.New("brain: pub/sub not supported")
@@ -0,0 +1,67 @@
+package brain
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis"
+)
+
+// ParseURL returns the options to connect to the redis server at uri, one of
+//
+//	host:port
+//	redis://[[user]:password@]host[:port][/db][?options]
+//	rediss://[[user]:password@]host[:port][/db][?options]  over TLS
+//
+// The options are pool_size, the maximum number of connections, and
+// tls_skip_verify, not to verify the server certificate. The user "h" of
+// the Heroku URLs is not a real one and is ignored, the other users are
+// authenticated with the ACLs of redis 6.
+func ParseURL(uri string) (*redis.Options, error) {
+	if !strings.Contains(uri, "://") {
+		return &redis.Options{Addr: uri}, nil
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	u.RawQuery = ""
+	opt, err := redis.ParseURL(u.String())
+	if err != nil {
+		return nil, err
+	}
+
+	if user := u.User.Username(); user != "" && user != "h" {
+		pass := opt.Password
+		opt.Password = ""
+		opt.OnConnect = func(cn *redis.Conn) error {
+			return cn.Do("auth", user, pass).Err()
+		}
+	}
+
+	for k := range query {
+		v := query.Get(k)
+		switch k {
+		case "pool_size":
+			if opt.PoolSize, err = strconv.Atoi(v); err != nil || opt.PoolSize <= 0 {
+				return nil, fmt.Errorf("brain: invalid pool_size %q", v)
+			}
+		case "tls_skip_verify":
+			skip, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("brain: invalid tls_skip_verify %q", v)
+			}
+			if opt.TLSConfig == nil {
+				return nil, fmt.Errorf("brain: tls_skip_verify needs a rediss:// URL")
+			}
+			opt.TLSConfig.InsecureSkipVerify = skip
+		default:
+			return nil, fmt.Errorf("brain: unknown redis option %s", k)
+		}
+	}
+	return opt, nil
+}
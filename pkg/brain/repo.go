@@ -0,0 +1,119 @@
+package brain
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// KV is the part of a Store needed by a Repo
+type KV interface {
+	Set(key string, val interface{}) error
+	Get(key string, q interface{}) error
+	Delete(key string) error
+	Keys(pattern string) ([]string, error)
+}
+
+// Repo stores the values of type T under the keys made of a common prefix
+// and an id, e.g. the diet of each user under "diet:<user id>"
+type Repo[T any] struct {
+	store  KV
+	prefix string
+}
+
+// NewRepo returns the repository of the values under prefix in s
+func NewRepo[T any](s KV, prefix string) Repo[T] {
+	return Repo[T]{s, prefix}
+}
+
+// Key returns the store key of id
+func (r Repo[T]) Key(id string) string {
+	return r.prefix + id
+}
+
+// Get returns the value of id, the zero value and ErrNotFound if there's none
+func (r Repo[T]) Get(id string) (T, error) {
+	var v T
+	if err := r.store.Get(r.Key(id), &v); err != nil {
+		var zero T
+		return zero, err
+	}
+	return v, nil
+}
+
+// Put stores the value of id
+func (r Repo[T]) Put(id string, v T) error {
+	return r.store.Set(r.Key(id), v)
+}
+
+// Delete removes the value of id
+func (r Repo[T]) Delete(id string) error {
+	return r.store.Delete(r.Key(id))
+}
+
+// IDs returns the sorted ids of the stored values
+func (r Repo[T]) IDs() ([]string, error) {
+	keys, err := r.store.Keys(escapeGlob(r.prefix) + "*")
+	if err != nil {
+		return nil, err
+	}
+	for i, k := range keys {
+		keys[i] = strings.TrimPrefix(k, r.prefix)
+	}
+	return keys, nil
+}
+
+// manyReader is a KV able to read many keys at once, see Store.ReadMany
+type manyReader interface {
+	ReadMany(keys ...string) (map[string]string, error)
+}
+
+// GetMany returns the values of the ids found, by id, read all together if
+// the store can, one by one otherwise
+func (r Repo[T]) GetMany(ids ...string) (map[string]T, error) {
+	vals := make(map[string]T, len(ids))
+	mr, ok := r.store.(manyReader)
+	if !ok {
+		for _, id := range ids {
+			v, err := r.Get(id)
+			if err == ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			vals[id] = v
+		}
+		return vals, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = r.Key(id)
+	}
+	raw, err := mr.ReadMany(keys...)
+	if err != nil {
+		return nil, err
+	}
+	for i, id := range ids {
+		val, ok := raw[keys[i]]
+		if !ok {
+			continue
+		}
+		var v T
+		if err := json.Unmarshal([]byte(val), &v); err != nil {
+			return nil, err
+		}
+		vals[id] = v
+	}
+	return vals, nil
+}
+
+// All returns all the stored values by id, the ones deleted in the meantime
+// are skipped
+func (r Repo[T]) All() (map[string]T, error) {
+	ids, err := r.IDs()
+	if err != nil {
+		return nil, err
+	}
+	return r.GetMany(ids...)
+}
@@ -0,0 +1,52 @@
+package brain
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy tells how many times and how often a failed operation on the
+// store server is retried, the backoff doubles after each attempt
+type RetryPolicy struct {
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultRetry is the retry policy used if none is configured
+var DefaultRetry = RetryPolicy{Attempts: 5, Backoff: 100 * time.Millisecond, MaxBackoff: 5 * time.Second}
+
+// RetryFromEnv returns DefaultRetry overridden by BRAIN_RETRIES, the number
+// of attempts, and BRAIN_RETRY_BACKOFF, the first backoff e.g. "200ms"
+func RetryFromEnv() RetryPolicy {
+	p := DefaultRetry
+	if n, err := strconv.Atoi(os.Getenv("BRAIN_RETRIES")); err == nil && n > 0 {
+		p.Attempts = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("BRAIN_RETRY_BACKOFF")); err == nil && d > 0 {
+		p.Backoff = d
+	}
+	return p
+}
+
+// Do calls fn until it succeeds or the attempts are over, returns the last error
+func (p RetryPolicy) Do(what string, fn func() error) error {
+	backoff := p.Backoff
+	var err error
+	for i := 0; i < p.Attempts; i++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if i == p.Attempts-1 {
+			break
+		}
+		slog.Warn(what+" failed, retrying", "backoff", backoff.String(), "err", err)
+		time.Sleep(backoff)
+		if backoff *= 2; p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+	return err
+}
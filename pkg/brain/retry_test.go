@@ -0,0 +1,31 @@
+package brain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	p := RetryPolicy{Attempts: 3, Backoff: time.Millisecond}
+
+	calls := 0
+	err := p.Do("test", func() error {
+		if calls++; calls < 3 {
+			return errors.New("down")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("unexpected %d calls, %v", calls, err)
+	}
+
+	calls = 0
+	err = p.Do("test", func() error {
+		calls++
+		return errors.New("down")
+	})
+	if err == nil || calls != 3 {
+		t.Fatalf("unexpected %d calls, %v", calls, err)
+	}
+}
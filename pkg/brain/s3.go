@@ -0,0 +1,243 @@
+package brain
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 is a backup backend on a bucket of an S3 compatible object storage,
+// the requests are signed with AWS Signature Version 4
+type S3 struct {
+	Endpoint  string // e.g. https://s3.eu-west-1.amazonaws.com
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	Token     string // the session token of temporary credentials, if any
+
+	client *http.Client
+}
+
+// OpenS3 returns the backend of uri, s3://bucket/prefix for Amazon S3 or
+// gs://bucket/prefix for Google Cloud Storage through its interoperability
+// API. The credentials are read from AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN, using an HMAC key for Google
+// Cloud Storage. AWS_REGION defaults to us-east-1 and BRAIN_BACKUP_ENDPOINT
+// overrides the server, e.g. for minio.
+func OpenS3(uri string) (*S3, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("brain: no bucket in %s", uri)
+	}
+	s := &S3{
+		Region:    os.Getenv("AWS_REGION"),
+		Bucket:    u.Host,
+		Prefix:    strings.Trim(u.Path, "/"),
+		AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		Token:     os.Getenv("AWS_SESSION_TOKEN"),
+		client:    &http.Client{Timeout: time.Minute},
+	}
+	if s.AccessKey == "" || s.SecretKey == "" {
+		return nil, fmt.Errorf("brain: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are needed for %s", uri)
+	}
+	if s.Prefix != "" {
+		s.Prefix += "/"
+	}
+	if u.Scheme == "gs" {
+		s.Endpoint, s.Region = "https://storage.googleapis.com", "auto"
+	}
+	if s.Region == "" {
+		s.Region = "us-east-1"
+	}
+	if s.Endpoint == "" {
+		s.Endpoint = "https://s3." + s.Region + ".amazonaws.com"
+	}
+	if env := os.Getenv("BRAIN_BACKUP_ENDPOINT"); env != "" {
+		s.Endpoint = strings.TrimSuffix(env, "/")
+	}
+	return s, nil
+}
+
+func (s *S3) Put(name string, data []byte) error {
+	_, err := s.do("PUT", s.Prefix+name, nil, data)
+	return err
+}
+
+func (s *S3) Get(name string) ([]byte, error) {
+	return s.do("GET", s.Prefix+name, nil, nil)
+}
+
+func (s *S3) Delete(name string) error {
+	_, err := s.do("DELETE", s.Prefix+name, nil, nil)
+	return err
+}
+
+func (s *S3) List() ([]string, error) {
+	var names []string
+	q := url.Values{"list-type": {"2"}, "prefix": {s.Prefix}}
+	for {
+		body, err := s.do("GET", "", q, nil)
+		if err != nil {
+			return nil, err
+		}
+		var res struct {
+			Contents []struct {
+				Key string
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		if err := xml.Unmarshal(body, &res); err != nil {
+			return nil, err
+		}
+		for _, c := range res.Contents {
+			if name := strings.TrimPrefix(c.Key, s.Prefix); !strings.Contains(name, "/") {
+				names = append(names, name)
+			}
+		}
+		if !res.IsTruncated {
+			return names, nil
+		}
+		q.Set("continuation-token", res.NextContinuationToken)
+	}
+}
+
+// do sends a signed request for key, the bucket itself if key is empty,
+// and returns the response body
+func (s *S3) do(method, key string, q url.Values, body []byte) ([]byte, error) {
+	path := "/" + s.Bucket
+	if key != "" {
+		path += "/" + key
+	}
+	u, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.Path, u.RawPath = path, awsEscape(path, false)
+	u.RawQuery = canonicalQuery(q)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, path, body, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("brain: %s %s: %s %s", method, path, resp.Status, bytes.TrimSpace(data))
+	}
+	return data, nil
+}
+
+// sign adds to req the AWS Signature Version 4 headers
+func (s *S3) sign(req *http.Request, path string, body []byte, now time.Time) {
+	payload := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(payload[:])
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if s.Token != "" {
+		req.Header.Set("X-Amz-Security-Token", s.Token)
+		headers["x-amz-security-token"] = s.Token
+	}
+	names := make([]string, 0, len(headers))
+	for h := range headers {
+		names = append(names, h)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, h := range names {
+		canonicalHeaders.WriteString(h + ":" + headers[h] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsEscape(path, false),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + s.Region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := []byte("AWS4" + s.SecretKey)
+	for _, part := range []string{day, s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery encodes q sorted by key, as the signature wants it
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, awsEscape(k, true)+"="+awsEscape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape percent-encodes all the bytes of s but the unreserved ones, and
+// the slashes unless escapeSlash is set
+func awsEscape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !escapeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
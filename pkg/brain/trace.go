@@ -0,0 +1,225 @@
+package brain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/develersrl/lunches/pkg/sentry"
+	"github.com/develersrl/lunches/pkg/tracing"
+)
+
+// Traced is a view of a Store tracing every operation with a span, child of
+// the span of the step being handled
+type Traced struct {
+	store Store
+	ctx   func() context.Context
+}
+
+// Trace returns the traced view of s, the spans of the operations are
+// children of the one in the context returned by ctx when they start
+func Trace(s Store, ctx func() context.Context) *Traced {
+	return &Traced{s, ctx}
+}
+
+// trace starts the span of op on key, the returned function ends it with
+// the error pointed by err, a missing key is not an error
+func (s *Traced) trace(op, key string) func(err *error) {
+	_, span := tracing.Start(s.ctx(), "brain "+op,
+		attribute.String("brain.op", op),
+		attribute.String("brain.namespace", keyNamespace(key)))
+	return func(err *error) {
+		if *err == ErrNotFound {
+			tracing.End(span, nil)
+			return
+		}
+		tracing.End(span, *err)
+		if IsStoreError(*err) {
+			sentry.CaptureError(sentry.WithContext(s.ctx(), map[string]string{"brain.op": op}, nil), *err)
+		}
+	}
+}
+
+// IsStoreError tells if err is a failure of the store server, e.g. Redis
+// being down, rather than an error of the caller or a missing key
+func IsStoreError(err error) bool {
+	if err == nil || err == ErrNotFound {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	// the errors replied by Redis, e.g. "redis: transaction failed" or
+	// "ERR max number of clients reached"
+	msg := err.Error()
+	return strings.HasPrefix(msg, "redis: ") || strings.HasPrefix(msg, "ERR ") ||
+		strings.HasPrefix(msg, "LOADING ") || strings.HasPrefix(msg, "READONLY ")
+}
+
+func (s *Traced) Set(key string, val interface{}) (err error) {
+	defer s.trace("set", key)(&err)
+	return s.store.Set(key, val)
+}
+
+func (s *Traced) SetWithTTL(key string, val interface{}, ttl time.Duration) (err error) {
+	defer s.trace("set", key)(&err)
+	return s.store.SetWithTTL(key, val, ttl)
+}
+
+func (s *Traced) ExpireAt(key string, at time.Time) (err error) {
+	defer s.trace("expire", key)(&err)
+	return s.store.ExpireAt(key, at)
+}
+
+func (s *Traced) TTL(key string) (ttl time.Duration, err error) {
+	defer s.trace("ttl", key)(&err)
+	return s.store.TTL(key)
+}
+
+func (s *Traced) Get(key string, q interface{}) (err error) {
+	defer s.trace("get", key)(&err)
+	return s.store.Get(key, q)
+}
+
+func (s *Traced) Read(key string) (val string, err error) {
+	defer s.trace("get", key)(&err)
+	return s.store.Read(key)
+}
+
+func (s *Traced) ReadMany(keys ...string) (vals map[string]string, err error) {
+	defer s.trace("mget", firstKey(keys))(&err)
+	return s.store.ReadMany(keys...)
+}
+
+func (s *Traced) Delete(key string) (err error) {
+	defer s.trace("delete", key)(&err)
+	return s.store.Delete(key)
+}
+
+func (s *Traced) Keys(pattern string) (keys []string, err error) {
+	defer s.trace("keys", pattern)(&err)
+	return s.store.Keys(pattern)
+}
+
+func (s *Traced) UpdateRaw(key string, fn func(old []byte) ([]byte, error)) (err error) {
+	defer s.trace("update", key)(&err)
+	return s.store.UpdateRaw(key, fn)
+}
+
+func (s *Traced) Update(key string, q interface{}, fn func() error) (err error) {
+	defer s.trace("update", key)(&err)
+	return s.store.Update(key, q, fn)
+}
+
+func (s *Traced) Append(key string, vals ...interface{}) (err error) {
+	defer s.trace("append", key)(&err)
+	return s.store.Append(key, vals...)
+}
+
+func (s *Traced) Range(key string, start, stop int64, q interface{}) (err error) {
+	defer s.trace("range", key)(&err)
+	return s.store.Range(key, start, stop, q)
+}
+
+func (s *Traced) Pop(key string, q interface{}) (err error) {
+	defer s.trace("pop", key)(&err)
+	return s.store.Pop(key, q)
+}
+
+func (s *Traced) Move(src, dst string, q interface{}) (err error) {
+	defer s.trace("move", src)(&err)
+	return s.store.Move(src, dst, q)
+}
+
+func (s *Traced) Remove(key string, val interface{}) (err error) {
+	defer s.trace("remove", key)(&err)
+	return s.store.Remove(key, val)
+}
+
+func (s *Traced) SAdd(key string, members ...string) (err error) {
+	defer s.trace("sadd", key)(&err)
+	return s.store.SAdd(key, members...)
+}
+
+func (s *Traced) SRem(key string, members ...string) (err error) {
+	defer s.trace("srem", key)(&err)
+	return s.store.SRem(key, members...)
+}
+
+func (s *Traced) SMembers(key string) (members []string, err error) {
+	defer s.trace("smembers", key)(&err)
+	return s.store.SMembers(key)
+}
+
+func (s *Traced) ZAdd(key string, member string, score float64) (err error) {
+	defer s.trace("zadd", key)(&err)
+	return s.store.ZAdd(key, member, score)
+}
+
+func (s *Traced) ZRem(key string, member string) (removed bool, err error) {
+	defer s.trace("zrem", key)(&err)
+	return s.store.ZRem(key, member)
+}
+
+func (s *Traced) ZRange(key string, start, stop int64) (zs []Scored, err error) {
+	defer s.trace("zrange", key)(&err)
+	return s.store.ZRange(key, start, stop)
+}
+
+func (s *Traced) ZRevRange(key string, start, stop int64) (zs []Scored, err error) {
+	defer s.trace("zrange", key)(&err)
+	return s.store.ZRevRange(key, start, stop)
+}
+
+// Batch returns a batch of the underlying store, traced on commit
+func (s *Traced) Batch() *Batch {
+	return newBatch(func(ops []batchOp) (err error) {
+		defer s.trace("batch", "")(&err)
+		inner := s.store.Batch()
+		inner.ops = ops
+		return inner.Commit()
+	})
+}
+
+func (s *Traced) Type(key string) (t string, err error) {
+	defer s.trace("type", key)(&err)
+	if tp, ok := s.store.(typer); ok {
+		return tp.Type(key)
+	}
+	if _, err := s.store.Read(key); err != nil {
+		return "", err
+	}
+	return TypeString, nil
+}
+
+func (s *Traced) Publish(channel string, msg interface{}) (err error) {
+	defer s.trace("publish", channel)(&err)
+	ps, ok := s.store.(PubSub)
+	if !ok {
+		return ErrNoPubSub
+	}
+	return ps.Publish(channel, msg)
+}
+
+func (s *Traced) Subscribe(channel string, fn func(msg []byte)) (func() error, error) {
+	ps, ok := s.store.(PubSub)
+	if !ok {
+		return nil, ErrNoPubSub
+	}
+	return ps.Subscribe(channel, fn)
+}
+
+func (s *Traced) Healthy() (err error) {
+	defer s.trace("ping", "")(&err)
+	return s.store.Healthy()
+}
+
+func (s *Traced) Close() error {
+	return s.store.Close()
+}
@@ -0,0 +1,301 @@
+package brain
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VersionsPrefix prefixes the keys holding the previous versions of a key
+const VersionsPrefix = "versions:"
+
+// versionsRetention is how long the versions of a key are kept after its
+// last write
+const versionsRetention = 7 * 24 * time.Hour
+
+// Version is a previous value of a key
+type Version struct {
+	// Value is the JSON value, nil if the key did not exist
+	Value json.RawMessage `json:",omitempty"`
+	// Time is when the value was replaced
+	Time time.Time
+}
+
+// Versioned is a view of a Store keeping the last versions of the values it
+// writes or deletes, so that an accidental overwrite can be rolled back. The
+// collections are not versioned.
+type Versioned struct {
+	store Store
+	keep  int
+}
+
+// Versions returns the view of s keeping the last keep versions of each key
+func Versions(s Store, keep int) *Versioned {
+	return &Versioned{s, keep}
+}
+
+// VersionsFromEnv returns how many versions to keep from BRAIN_VERSIONS, 0
+// if the versioning is disabled
+func VersionsFromEnv() (int, error) {
+	env := os.Getenv("BRAIN_VERSIONS")
+	if env == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(env)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("brain: invalid BRAIN_VERSIONS %q", env)
+	}
+	return n, nil
+}
+
+// current returns the raw value of key, nil if it does not exist
+func (v *Versioned) current(key string) ([]byte, error) {
+	val, err := v.store.Read(key)
+	if err == ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(val), nil
+}
+
+// record adds old as the newest version of key, dropping the oldest ones.
+// The versions are not versioned themselves, so that deleting them, e.g. to
+// forget a user, deletes them for good.
+func (v *Versioned) record(key string, old []byte) error {
+	if strings.HasPrefix(key, VersionsPrefix) {
+		return nil
+	}
+	vkey := VersionsPrefix + key
+	err := v.store.UpdateRaw(vkey, func(data []byte) ([]byte, error) {
+		var versions []Version
+		if data != nil {
+			if err := json.Unmarshal(data, &versions); err != nil {
+				return nil, err
+			}
+		}
+		versions = append([]Version{{old, time.Now()}}, versions...)
+		if len(versions) > v.keep {
+			versions = versions[:v.keep]
+		}
+		return json.Marshal(versions)
+	})
+	if err != nil {
+		return err
+	}
+	return v.store.ExpireAt(vkey, time.Now().Add(versionsRetention))
+}
+
+// History returns the previous versions of key, from the newest one
+func (v *Versioned) History(key string) ([]Version, error) {
+	var versions []Version
+	err := v.store.Get(VersionsPrefix+key, &versions)
+	if err == ErrNotFound {
+		return nil, nil
+	}
+	return versions, err
+}
+
+// Rollback restores the n-th previous version of key, 1 being the value
+// before the last write. The replaced value becomes the newest version, so
+// that a rollback can be undone with another one.
+func (v *Versioned) Rollback(key string, n int) error {
+	versions, err := v.History(key)
+	if err != nil {
+		return err
+	}
+	if n < 1 || n > len(versions) {
+		return fmt.Errorf("brain: %s has %d versions, cannot roll back to %d", key, len(versions), n)
+	}
+	val := versions[n-1].Value
+	if val == nil {
+		return v.Delete(key)
+	}
+	// UpdateRaw keeps the expiration of key
+	return v.UpdateRaw(key, func([]byte) ([]byte, error) {
+		return val, nil
+	})
+}
+
+func (v *Versioned) Set(key string, val interface{}) error {
+	return v.SetWithTTL(key, val, 0)
+}
+
+func (v *Versioned) SetWithTTL(key string, val interface{}, ttl time.Duration) error {
+	old, err := v.current(key)
+	if err != nil {
+		return err
+	}
+	if err := v.store.SetWithTTL(key, val, ttl); err != nil {
+		return err
+	}
+	return v.record(key, old)
+}
+
+func (v *Versioned) ExpireAt(key string, at time.Time) error {
+	return v.store.ExpireAt(key, at)
+}
+
+func (v *Versioned) TTL(key string) (time.Duration, error) {
+	return v.store.TTL(key)
+}
+
+func (v *Versioned) Get(key string, q interface{}) error {
+	return v.store.Get(key, q)
+}
+
+func (v *Versioned) Read(key string) (string, error) {
+	return v.store.Read(key)
+}
+
+func (v *Versioned) ReadMany(keys ...string) (map[string]string, error) {
+	return v.store.ReadMany(keys...)
+}
+
+// Delete removes key, keeping its value as the newest version
+func (v *Versioned) Delete(key string) error {
+	old, err := v.current(key)
+	if err != nil || old == nil {
+		return err
+	}
+	if err := v.store.Delete(key); err != nil {
+		return err
+	}
+	return v.record(key, old)
+}
+
+func (v *Versioned) Keys(pattern string) ([]string, error) {
+	return v.store.Keys(pattern)
+}
+
+func (v *Versioned) UpdateRaw(key string, fn func(old []byte) ([]byte, error)) error {
+	// fn may be called again if key changes, the last call is the one written
+	var prev []byte
+	err := v.store.UpdateRaw(key, func(old []byte) ([]byte, error) {
+		prev = old
+		return fn(old)
+	})
+	if err != nil {
+		return err
+	}
+	return v.record(key, prev)
+}
+
+func (v *Versioned) Update(key string, q interface{}, fn func() error) error {
+	return updateJSON(v.UpdateRaw, key, q, fn)
+}
+
+func (v *Versioned) Append(key string, vals ...interface{}) error {
+	return v.store.Append(key, vals...)
+}
+
+func (v *Versioned) Range(key string, start, stop int64, q interface{}) error {
+	return v.store.Range(key, start, stop, q)
+}
+
+func (v *Versioned) Pop(key string, q interface{}) error {
+	return v.store.Pop(key, q)
+}
+
+func (v *Versioned) Move(src, dst string, q interface{}) error {
+	return v.store.Move(src, dst, q)
+}
+
+func (v *Versioned) Remove(key string, val interface{}) error {
+	return v.store.Remove(key, val)
+}
+
+func (v *Versioned) SAdd(key string, members ...string) error {
+	return v.store.SAdd(key, members...)
+}
+
+func (v *Versioned) SRem(key string, members ...string) error {
+	return v.store.SRem(key, members...)
+}
+
+func (v *Versioned) SMembers(key string) ([]string, error) {
+	return v.store.SMembers(key)
+}
+
+func (v *Versioned) ZAdd(key string, member string, score float64) error {
+	return v.store.ZAdd(key, member, score)
+}
+
+func (v *Versioned) ZRem(key string, member string) (bool, error) {
+	return v.store.ZRem(key, member)
+}
+
+func (v *Versioned) ZRange(key string, start, stop int64) ([]Scored, error) {
+	return v.store.ZRange(key, start, stop)
+}
+
+func (v *Versioned) ZRevRange(key string, start, stop int64) ([]Scored, error) {
+	return v.store.ZRevRange(key, start, stop)
+}
+
+// Type returns the type of key, if the underlying store has native types
+func (v *Versioned) Type(key string) (string, error) {
+	if t, ok := v.store.(typer); ok {
+		return t.Type(key)
+	}
+	if _, err := v.store.Read(key); err != nil {
+		return "", err
+	}
+	return TypeString, nil
+}
+
+func (v *Versioned) Publish(channel string, msg interface{}) error {
+	ps, ok := v.store.(PubSub)
+	if !ok {
+		return ErrNoPubSub
+	}
+	return ps.Publish(channel, msg)
+}
+
+func (v *Versioned) Subscribe(channel string, fn func(msg []byte)) (func() error, error) {
+	ps, ok := v.store.(PubSub)
+	if !ok {
+		return nil, ErrNoPubSub
+	}
+	return ps.Subscribe(channel, fn)
+}
+
+// Batch returns a batch of the underlying store, recording the replaced
+// values once committed
+func (v *Versioned) Batch() *Batch {
+	return newBatch(func(ops []batchOp) error {
+		olds := make([][]byte, len(ops))
+		for i, op := range ops {
+			var err error
+			if olds[i], err = v.current(op.key); err != nil {
+				return err
+			}
+		}
+		inner := v.store.Batch()
+		inner.ops = ops
+		if err := inner.Commit(); err != nil {
+			return err
+		}
+		for i, op := range ops {
+			if op.val == nil && olds[i] == nil {
+				continue
+			}
+			if err := v.record(op.key, olds[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (v *Versioned) Healthy() error {
+	return v.store.Healthy()
+}
+
+func (v *Versioned) Close() error {
+	return v.store.Close()
+}
@@ -0,0 +1,122 @@
+package chat
+
+// Block is a part of a rich message, as the blocks of Slack: a Section, a
+// Context, a Divider or Actions
+type Block interface {
+	block()
+}
+
+// Section is a block of Markdown text
+type Section struct {
+	Text string
+}
+
+// Context is a block of small plain text
+type Context struct {
+	Text string
+}
+
+// Divider separates the blocks
+type Divider struct{}
+
+// Actions is a row of interactive elements, Buttons or Selects
+type Actions struct {
+	Elements []Element
+}
+
+func (Section) block() {}
+func (Context) block() {}
+func (Divider) block() {}
+func (Actions) block() {}
+
+// Element is an interactive element of Actions
+type Element interface {
+	element()
+}
+
+// Style is the color of a Button
+type Style string
+
+// The styles of the buttons
+const (
+	Default Style = ""
+	Primary Style = "primary"
+	Danger  Style = "danger"
+)
+
+// Button sends an Action with its ActionID and Value when clicked, after the
+// user confirmed it if Confirm is set
+type Button struct {
+	ActionID string
+	Value    string
+	Text     string
+	Style    Style
+	Confirm  *Confirm
+}
+
+// Confirm asks the user to confirm the click of a button
+type Confirm struct {
+	Title string
+	Text  string
+	Yes   string
+	No    string
+}
+
+// Select sends an Action with its ActionID and the Value of the Option
+// chosen
+type Select struct {
+	ActionID    string
+	Placeholder string
+	Options     []Option
+	// Initial is the value of the option shown as chosen, if any
+	Initial string
+}
+
+// Option is an option of a Select
+type Option struct {
+	Text  string
+	Value string
+}
+
+func (Button) element() {}
+func (Select) element() {}
+
+// Action is a click on an interactive element
+type Action struct {
+	ActionID string
+	// Value is the value of the button, or of the option chosen
+	Value string
+}
+
+// Interaction is a user clicking on the interactive elements of a message
+// of the bot, or of its home
+type Interaction struct {
+	Team    string
+	Channel string
+	User    string
+	// ResponseURL answers the interaction, replacing the message
+	ResponseURL string
+	// TriggerID allows to open a Modal in answer to the interaction
+	TriggerID string
+	Actions   []Action
+}
+
+// Modal is a dialog asking the user to confirm something: a Submission
+// with its CallbackID and Metadata is sent when the user confirms
+type Modal struct {
+	CallbackID string
+	// Metadata is the state of the dialog, given back by the Submission
+	Metadata string
+	Title    string
+	Submit   string
+	Close    string
+	Blocks   []Block
+}
+
+// Submission is a user confirming a Modal opened by the bot
+type Submission struct {
+	Team       string
+	User       string
+	CallbackID string
+	Metadata   string
+}
@@ -0,0 +1,99 @@
+// Package chat abstracts the chat platforms the bot talks on, so that the
+// order logic doesn't depend on Slack: each platform has an adapter
+// implementing Platform, see slackbot.Platform and teams.Platform.
+package chat
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrUnsupported is returned by the platforms for the features they lack,
+// e.g. the pins on Teams
+var ErrUnsupported = errors.New("chat: not supported by the platform")
+
+// User is a user of a chat platform
+type User struct {
+	ID          string
+	Name        string
+	DisplayName string
+	Email       string
+	IsBot       bool
+	// Deleted is true for the users who left the workspace
+	Deleted bool
+}
+
+// Event is a message sent to the bot
+type Event struct {
+	Channel string
+	User    string
+	Text    string
+}
+
+// Message is a message of a channel
+type Message struct {
+	ID   string
+	User string
+	Text string
+}
+
+// File is a file shared on a platform
+type File struct {
+	ID    string
+	Name  string
+	Title string
+	// Type is the type of the file, its extension, e.g. "xlsx"
+	Type string
+	Size int64
+	// URL is where the platform serves the file, for DownloadFile
+	URL string
+}
+
+// Command is a command sent to the bot out of the conversations, e.g. a
+// slash command of Slack: the answers go to ResponseURL
+type Command struct {
+	Team        string
+	Channel     string
+	User        string
+	Text        string
+	ResponseURL string
+}
+
+// Platform sends and receives the messages of the bot on a chat platform.
+// The blocks of the messages, if any, are shown by the platforms supporting
+// them, the text by the others and in the notifications.
+type Platform interface {
+	// SendMessage posts text in channel, in the thread if not empty, and
+	// returns the id of the message
+	SendMessage(channel, thread, text string, blocks ...Block) (string, error)
+	// SendEphemeral shows text in channel only to userID
+	SendEphemeral(channel, thread, userID, text string, blocks ...Block) error
+	// UpdateMessage replaces the text of the message id in channel
+	UpdateMessage(channel, id, text string, blocks ...Block) error
+	// DirectChannel returns the channel of the direct messages with userID
+	DirectChannel(userID string) (string, error)
+	// UserInfo returns the user userID
+	UserInfo(userID string) (*User, error)
+	// Users returns all the users of the workspace
+	Users() ([]User, error)
+	// ChannelMembers returns the IDs of the members of channel
+	ChannelMembers(channel string) ([]string, error)
+	// AddReaction reacts with emoji, by name, to the message id in channel
+	AddReaction(channel, id, emoji string) error
+	// Pin pins the message id in channel
+	Pin(channel, id string) error
+	// Unpin removes the pin of the message id in channel, if any
+	Unpin(channel, id string) error
+	// Pinned returns the messages pinned in channel
+	Pinned(channel string) ([]Message, error)
+	// UploadFile shares the file f with content data in channel
+	UploadFile(channel string, f File, data []byte) error
+	// FileInfo returns the file id shared with the bot
+	FileInfo(id string) (*File, error)
+	// DownloadFile returns the content of f
+	DownloadFile(f *File) ([]byte, error)
+	// ParseEvent returns the message to the bot carried by a request of the
+	// platform, nil if it carries something else. An error is returned if
+	// the request can't be verified to come from the platform.
+	ParseEvent(r *http.Request) (*Event, error)
+}
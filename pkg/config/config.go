@@ -0,0 +1,360 @@
+// Package config is the configuration of the bot shared by all the offices:
+// the channels, the admins, the deadline and the delivery details, and the
+// features turned on. It's read at startup from the defaults, the JSON file
+// in CONFIG_FILE and the environment, in this order, and validated. The
+// live keys can then be changed in the brain, with the config command, and
+// are reloaded by all the instances of the bot without restarting them.
+//
+// The credentials and the infrastructure, like the tokens, the brain and the
+// logs, are still read from the environment by their packages.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Config is the typed configuration, see Keys
+type Config struct {
+	FoodChannel   string
+	TestChannel   string
+	Admins        []string
+	Host          string
+	Deadline      string
+	DeliveryTime  string
+	OfficeAddress string
+	MarkURL       string
+	Features      map[string]bool
+
+	// the source of each key: "file", "env" or "brain", missing for the
+	// defaults
+	source map[string]string
+}
+
+// The features which can be turned off, all on by default
+const (
+	FeatureGuests  = "ospiti"
+	FeatureRatings = "voti"
+	FeaturePolls   = "sondaggi"
+)
+
+// Features are the features which can be turned off
+var Features = []string{FeatureGuests, FeatureRatings, FeaturePolls}
+
+// Key is a key of the configuration
+type Key struct {
+	// Name is the name in the file, in the brain and in the config command
+	Name string
+	// Env is the environment variable
+	Env string
+	// Live is set if the key can be changed in the brain
+	Live        bool
+	Description string
+
+	get func(c *Config) string
+	set func(c *Config, v string) error
+}
+
+var (
+	channelRe = regexp.MustCompile(`^[CGD][A-Z0-9]{2,}$`)
+	hhmmRe    = regexp.MustCompile(`^\d{2}:\d{2}$`)
+)
+
+// parseChannel validates the ID of a Slack channel, e.g. C0123ABCD
+func parseChannel(v string) (string, error) {
+	if v != "" && !channelRe.MatchString(v) {
+		return "", fmt.Errorf("'%s' non è l'ID di un canale, ad esempio C0123ABCD", v)
+	}
+	return v, nil
+}
+
+// parseTime validates a time of the day as "15:04"
+func parseTime(v string) (string, error) {
+	if v == "" {
+		return "", nil
+	}
+	if _, err := time.Parse("15:04", v); err != nil || !hhmmRe.MatchString(v) {
+		return "", fmt.Errorf("'%s' non è un orario, usa ad esempio 12:30", v)
+	}
+	return v, nil
+}
+
+// parseURL validates an absolute http or https URL
+func parseURL(v string) (string, error) {
+	if v == "" {
+		return "", nil
+	}
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("'%s' non è un indirizzo http o https", v)
+	}
+	return v, nil
+}
+
+// splitList splits a comma separated list, dropping the empty items
+func splitList(v string) []string {
+	var items []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			items = append(items, s)
+		}
+	}
+	return items
+}
+
+// parseFeatures parses the features to turn off, "-voti,-sondaggi", or on
+func parseFeatures(v string) (map[string]bool, error) {
+	features := make(map[string]bool)
+	for _, f := range splitList(strings.ToLower(v)) {
+		on := !strings.HasPrefix(f, "-")
+		f = strings.TrimPrefix(strings.TrimPrefix(f, "-"), "+")
+		known := false
+		for _, k := range Features {
+			known = known || k == f
+		}
+		if !known {
+			return nil, fmt.Errorf("funzione '%s' sconosciuta, sono %s", f, strings.Join(Features, ", "))
+		}
+		features[f] = on
+	}
+	return features, nil
+}
+
+// Keys are the keys of the configuration
+var Keys = []Key{{
+	Name: "food_channel", Env: "FOOD_CHANNEL", Live: true,
+	Description: "il canale dove vengono pubblicati i menù ricevuti per email o caricati come file",
+	get:         func(c *Config) string { return c.FoodChannel },
+	set: func(c *Config, v string) (err error) {
+		c.FoodChannel, err = parseChannel(v)
+		return err
+	},
+}, {
+	Name: "test_channel", Env: "TEST_CHANNEL", Live: true,
+	Description: "il canale delle simulazioni",
+	get:         func(c *Config) string { return c.TestChannel },
+	set: func(c *Config, v string) (err error) {
+		c.TestChannel, err = parseChannel(v)
+		return err
+	},
+}, {
+	Name: "admins", Env: "TINABOT_ADMINS", Live: true,
+	Description: "gli amministratori di tutti i canali del loro workspace, come <team>:<ID utente>, separati da virgole",
+	get:         func(c *Config) string { return strings.Join(c.Admins, ",") },
+	set: func(c *Config, v string) error {
+		c.Admins = splitList(v)
+		return nil
+	},
+}, {
+	Name: "host", Env: "HOST",
+	Description: "l'indirizzo pubblico del bot, per i link degli ospiti, del calendario e dell'installazione",
+	get:         func(c *Config) string { return c.Host },
+	set: func(c *Config, v string) (err error) {
+		c.Host, err = parseURL(v)
+		c.Host = strings.TrimSuffix(c.Host, "/")
+		return err
+	},
+}, {
+	Name: "deadline", Env: "ORDER_DEADLINE", Live: true,
+	Description: "la scadenza degli ordini degli uffici che non ne hanno impostata una",
+	get:         func(c *Config) string { return c.Deadline },
+	set: func(c *Config, v string) (err error) {
+		c.Deadline, err = parseTime(v)
+		return err
+	},
+}, {
+	Name: "delivery_time", Env: "DELIVERY_TIME", Live: true,
+	Description: "l'orario di consegna indicato al ristorante",
+	get:         func(c *Config) string { return c.DeliveryTime },
+	set: func(c *Config, v string) (err error) {
+		c.DeliveryTime, err = parseTime(v)
+		return err
+	},
+}, {
+	Name: "office_address", Env: "OFFICE_ADDRESS", Live: true,
+	Description: "l'indirizzo di consegna indicato al ristorante",
+	get:         func(c *Config) string { return c.OfficeAddress },
+	set: func(c *Config, v string) error {
+		c.OfficeAddress = strings.TrimSpace(v)
+		return nil
+	},
+}, {
+	Name: "mark_url", Env: "MARK_URL",
+	Description: "l'indirizzo per segnare i pranzi sul foglio, con <USER> e <FOOD> al posto dell'utente e del pranzo",
+	get:         func(c *Config) string { return c.MarkURL },
+	set: func(c *Config, v string) (err error) {
+		c.MarkURL, err = parseURL(v)
+		return err
+	},
+}, {
+	Name: "features", Env: "FEATURES", Live: true,
+	Description: "le funzioni accese o spente, ad esempio -voti,-sondaggi",
+	get: func(c *Config) string {
+		var fs []string
+		for _, f := range Features {
+			if on, ok := c.Features[f]; ok && !on {
+				fs = append(fs, "-"+f)
+			}
+		}
+		return strings.Join(fs, ",")
+	},
+	set: func(c *Config, v string) (err error) {
+		c.Features, err = parseFeatures(v)
+		return err
+	},
+}}
+
+// Lookup returns the key named name
+func Lookup(name string) (Key, bool) {
+	for _, k := range Keys {
+		if k.Name == strings.ToLower(name) {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+// Get returns the value of key
+func (c *Config) Get(key string) string {
+	k, ok := Lookup(key)
+	if !ok {
+		return ""
+	}
+	return k.get(c)
+}
+
+// Source returns where the value of key comes from: "file", "env", "brain"
+// or "default"
+func (c *Config) Source(key string) string {
+	if s, ok := c.source[key]; ok {
+		return s
+	}
+	return "default"
+}
+
+// Enabled tells if feature is turned on
+func (c *Config) Enabled(feature string) bool {
+	on, ok := c.Features[feature]
+	return !ok || on
+}
+
+// set validates and sets the value of k from source
+func (c *Config) set(k Key, v, source string) error {
+	if err := k.set(c, strings.TrimSpace(v)); err != nil {
+		return fmt.Errorf("%s: %s", k.Name, err)
+	}
+	c.source[k.Name] = source
+	return nil
+}
+
+// clone returns a copy of c, to be changed without touching c
+func (c *Config) clone() *Config {
+	n := *c
+	n.Admins = append([]string(nil), c.Admins...)
+	n.Features = make(map[string]bool, len(c.Features))
+	for f, on := range c.Features {
+		n.Features[f] = on
+	}
+	n.source = make(map[string]string, len(c.source))
+	for k, s := range c.source {
+		n.source[k] = s
+	}
+	return &n
+}
+
+// Load returns the configuration with the values in the JSON file path, if
+// not empty, overridden by the environment read with getenv. All the
+// invalid values are reported.
+func Load(path string, getenv func(string) string) (*Config, error) {
+	c := &Config{Features: map[string]bool{}, source: map[string]string{}}
+	var errs []error
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			k, ok := Lookup(name)
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: chiave sconosciuta in %s", name, path))
+				continue
+			}
+			if err := c.set(k, values[name], "file"); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	for _, k := range Keys {
+		if v := getenv(k.Env); v != "" {
+			if err := c.set(k, v, "env"); err != nil {
+				errs = append(errs, fmt.Errorf("%s (%s)", err, k.Env))
+			}
+		}
+	}
+	return c, errors.Join(errs...)
+}
+
+// readFile reads the JSON object of the values of the keys in path
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return values, nil
+}
+
+var (
+	// static is the configuration loaded at startup, current adds the live
+	// keys in the brain to it
+	static  atomic.Pointer[Config]
+	current atomic.Pointer[Config]
+	once    sync.Once
+)
+
+// Setup loads the configuration from CONFIG_FILE and the environment, and
+// makes it the current one if valid
+func Setup() error {
+	c, err := Load(os.Getenv("CONFIG_FILE"), os.Getenv)
+	if err != nil {
+		return err
+	}
+	static.Store(c)
+	current.Store(c)
+	return nil
+}
+
+// Current returns the current configuration, loaded from the environment
+// if Setup wasn't called, e.g. by the tasks. It must not be changed.
+func Current() *Config {
+	once.Do(func() {
+		if current.Load() == nil {
+			c, _ := Load(os.Getenv("CONFIG_FILE"), os.Getenv)
+			if c != nil {
+				static.CompareAndSwap(nil, c)
+				current.CompareAndSwap(nil, c)
+			}
+		}
+	})
+	if c := current.Load(); c != nil {
+		return c
+	}
+	return &Config{}
+}
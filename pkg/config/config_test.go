@@ -0,0 +1,113 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/brain"
+)
+
+func env(values map[string]string) func(string) string {
+	return func(key string) string { return values[key] }
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"food_channel": "C0FOOD", "deadline": "11:00", "host": "https://tina.example.com/"}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Load(path, env(map[string]string{"ORDER_DEADLINE": "11:30", "TINABOT_ADMINS": "mario, luigi,", "FEATURES": "-voti"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.FoodChannel != "C0FOOD" || c.Source("food_channel") != "file" {
+		t.Errorf("food_channel: got %q from %s", c.FoodChannel, c.Source("food_channel"))
+	}
+	if c.Deadline != "11:30" || c.Source("deadline") != "env" {
+		t.Errorf("deadline: got %q from %s", c.Deadline, c.Source("deadline"))
+	}
+	if c.Host != "https://tina.example.com" {
+		t.Errorf("host: got %q", c.Host)
+	}
+	if got := c.Get("admins"); got != "mario,luigi" {
+		t.Errorf("admins: got %q", got)
+	}
+	if c.Source("mark_url") != "default" {
+		t.Errorf("mark_url: got %s", c.Source("mark_url"))
+	}
+	if c.Enabled(FeatureRatings) || !c.Enabled(FeaturePolls) {
+		t.Errorf("features: got %v", c.Features)
+	}
+	if got := c.Get("features"); got != "-voti" {
+		t.Errorf("features: got %q", got)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	_, err := Load("", env(map[string]string{
+		"FOOD_CHANNEL":   "#pranzo",
+		"ORDER_DEADLINE": "25:00",
+		"HOST":           "tina.example.com",
+		"FEATURES":       "-boh",
+		"DELIVERY_TIME":  "12:30",
+	}))
+	if err == nil {
+		t.Fatal("no error")
+	}
+	for _, s := range []string{"food_channel", "deadline", "host", "features"} {
+		if !strings.Contains(err.Error(), s+": ") {
+			t.Errorf("%s not reported in %q", s, err)
+		}
+	}
+	if strings.Contains(err.Error(), "delivery_time") {
+		t.Errorf("valid delivery_time reported in %q", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{"boh": "1"}`), 0o600)
+	if _, err := Load(path, env(nil)); err == nil || !strings.Contains(err.Error(), "boh: chiave sconosciuta") {
+		t.Errorf("unknown key: got %v", err)
+	}
+}
+
+func TestSet(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("ORDER_DEADLINE", "11:00")
+	t.Setenv("HOST", "https://tina.example.com")
+	t.Cleanup(func() { Setup() })
+	if err := Setup(); err != nil {
+		t.Fatal(err)
+	}
+
+	b := brain.NewMemory()
+	if err := Watch(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := Set(b, "deadline", "11:45"); err != nil {
+		t.Fatal(err)
+	}
+	if c := Current(); c.Deadline != "11:45" || c.Source("deadline") != "brain" {
+		t.Errorf("deadline: got %q from %s", c.Deadline, c.Source("deadline"))
+	}
+	if err := Set(b, "deadline", "boh"); err == nil {
+		t.Error("invalid value set")
+	}
+	if err := Set(b, "host", "https://other.example.com"); err == nil {
+		t.Error("host set live")
+	}
+	if err := Set(b, "boh", "1"); err == nil {
+		t.Error("unknown key set")
+	}
+
+	// the static value is back once the live one is removed
+	if err := Set(b, "deadline", ""); err != nil {
+		t.Fatal(err)
+	}
+	if c := Current(); c.Deadline != "11:00" || c.Source("deadline") != "env" {
+		t.Errorf("deadline: got %q from %s", c.Deadline, c.Source("deadline"))
+	}
+}
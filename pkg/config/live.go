@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/develersrl/lunches/pkg/brain"
+)
+
+// brainKey holds the values of the live keys changed with the config
+// command, by name
+const brainKey = "config"
+
+// reloadChannel is where the instances of the bot are told to reload the
+// live keys
+const reloadChannel = "config:reload"
+
+// Reload makes current the static configuration with the live keys in b
+// over it. The invalid values are skipped, they can only come from a manual
+// change of the brain.
+func Reload(b brain.KV) error {
+	var values map[string]string
+	if err := b.Get(brainKey, &values); err != nil && err != brain.ErrNotFound {
+		return err
+	}
+	Current()
+	c := static.Load()
+	if c == nil {
+		c = &Config{}
+	}
+	c = c.clone()
+	for name, v := range values {
+		k, ok := Lookup(name)
+		if !ok || !k.Live {
+			continue
+		}
+		if err := c.set(k, v, "brain"); err != nil {
+			slog.Warn("Invalid configuration in the brain", "key", name, "err", err)
+		}
+	}
+	current.Store(c)
+	return nil
+}
+
+// Watch reloads the live keys in b now and every time an instance of the
+// bot changes them, see Set. The stores without pub/sub are only reloaded
+// by the instance changing them.
+func Watch(b brain.Store) error {
+	if err := Reload(b); err != nil {
+		return err
+	}
+	ps, ok := b.(brain.PubSub)
+	if !ok {
+		return nil
+	}
+	_, err := ps.Subscribe(reloadChannel, func([]byte) {
+		if err := Reload(b); err != nil {
+			slog.Error("Error reloading the configuration", "err", err)
+		}
+	})
+	return err
+}
+
+// Set validates and stores in b the value of the live key name, empty to
+// restore the one of the file or of the environment, and reloads the
+// configuration of all the instances of the bot
+func Set(b brain.Store, name, value string) error {
+	k, ok := Lookup(name)
+	if !ok {
+		return fmt.Errorf("chiave '%s' sconosciuta", name)
+	}
+	if !k.Live {
+		return fmt.Errorf("%s si può cambiare solo nel file di configurazione o con %s", k.Name, k.Env)
+	}
+	if value != "" {
+		if err := Current().clone().set(k, value, "brain"); err != nil {
+			return err
+		}
+	}
+
+	var values map[string]string
+	err := b.Update(brainKey, &values, func() error {
+		if values == nil {
+			values = make(map[string]string)
+		}
+		if value == "" {
+			delete(values, k.Name)
+		} else {
+			values[k.Name] = value
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := Reload(b); err != nil {
+		return err
+	}
+	if ps, ok := b.(brain.PubSub); ok {
+		if err := ps.Publish(reloadChannel, k.Name); err != nil {
+			slog.Warn("Error telling the other instances to reload the configuration", "err", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package events
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Topic identifies a kind of event
+type Topic string
+
+const (
+	// MenuPublished is sent when a new menu is set, Data is the tuttobene.Menu
+	MenuPublished Topic = "menu.published"
+	// OrderUpdated is sent when a user order changes, Data is the order
+	OrderUpdated Topic = "order.updated"
+	// OrderClosed is sent when the order of the day is finalized, Data is the order
+	OrderClosed Topic = "order.closed"
+	// OrderDelivered is sent when the order of the day arrives, Data is the order
+	OrderDelivered Topic = "order.delivered"
+	// PaymentSettled is sent when a debt between two users is settled
+	PaymentSettled Topic = "payment.settled"
+)
+
+// Event is a message sent on the bus
+type Event struct {
+	Topic Topic
+	Time  time.Time
+	Data  interface{}
+	// Remote is true for the events shared by another bot instance
+	Remote bool
+}
+
+// Handler is called for each event of the subscribed topic
+type Handler func(Event)
+
+// Bus dispatches the events to the subscribed handlers
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[Topic][]Handler
+	// id tells the events of this bus from the ones of other instances
+	id string
+}
+
+// New returns an empty event bus
+func New() *Bus {
+	id := fmt.Sprintf("%d-%d", os.Getpid(), time.Now().UnixNano())
+	return &Bus{handlers: make(map[Topic][]Handler), id: id}
+}
+
+// Subscribe registers h to be called for every event of the given topic
+func (b *Bus) Subscribe(topic Topic, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[topic] = append(b.handlers[topic], h)
+}
+
+// Publish sends an event to the topic subscribers, in subscription order.
+// A panicking handler is logged and does not stop the others.
+func (b *Bus) Publish(topic Topic, data interface{}) {
+	b.publish(Event{Topic: topic, Time: time.Now(), Data: data})
+}
+
+func (b *Bus) publish(ev Event) {
+	b.mu.RLock()
+	handlers := b.handlers[ev.Topic]
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		dispatch(h, ev)
+	}
+}
+
+func dispatch(h Handler, ev Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Event handler for %s failed: %v", ev.Topic, r)
+		}
+	}()
+	h(ev)
+}
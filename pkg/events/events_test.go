@@ -0,0 +1,110 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestBus(t *testing.T) {
+	b := New()
+
+	var got []string
+	b.Subscribe(OrderUpdated, func(ev Event) {
+		got = append(got, "first:"+ev.Data.(string))
+	})
+	b.Subscribe(OrderUpdated, func(ev Event) {
+		panic("broken handler")
+	})
+	b.Subscribe(OrderUpdated, func(ev Event) {
+		got = append(got, "second:"+ev.Data.(string))
+	})
+	b.Subscribe(OrderClosed, func(ev Event) {
+		got = append(got, "closed")
+	})
+
+	b.Publish(OrderUpdated, "test")
+	b.Publish(MenuPublished, nil)
+
+	if len(got) != 2 || got[0] != "first:test" || got[1] != "second:test" {
+		t.Errorf("unexpected events: %v", got)
+	}
+}
+
+// loopback is a Broadcaster delivering the messages in process
+type loopback map[string][]func([]byte)
+
+func (l loopback) Publish(channel string, msg interface{}) error {
+	encoded, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	for _, fn := range l[channel] {
+		fn(encoded)
+	}
+	return nil
+}
+
+func (l loopback) Subscribe(channel string, fn func([]byte)) (func() error, error) {
+	l[channel] = append(l[channel], fn)
+	return func() error { return nil }, nil
+}
+
+func TestShare(t *testing.T) {
+	bc := make(loopback)
+	decode := func(data []byte) (interface{}, error) {
+		var s string
+		err := json.Unmarshal(data, &s)
+		return s, err
+	}
+
+	a, b := New(), New()
+	var got []string
+	for name, bus := range map[string]*Bus{"a": a, "b": b} {
+		name := name
+		if _, err := bus.Share(bc, OrderUpdated, decode); err != nil {
+			t.Fatal(err)
+		}
+		bus.Subscribe(OrderUpdated, func(ev Event) {
+			got = append(got, fmt.Sprintf("%s:%s:%v", name, ev.Data, ev.Remote))
+		})
+	}
+
+	a.Publish(OrderUpdated, "x")
+	sort.Strings(got)
+	if fmt.Sprint(got) != "[a:x:false b:x:true]" {
+		t.Errorf("unexpected events: %v", got)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	bc := make(loopback)
+	decode := func(data []byte) (interface{}, error) {
+		var s string
+		err := json.Unmarshal(data, &s)
+		return s, err
+	}
+
+	bus := New()
+	bus.Share(bc, OrderUpdated, decode)
+	var got []string
+	_, err := Watch(bc, OrderUpdated, decode, func(ev Event) {
+		got = append(got, fmt.Sprint(ev.Data))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus.Subscribe(OrderUpdated, func(ev Event) {
+		got = append(got, fmt.Sprintf("bus:%s", ev.Data))
+	})
+
+	bus.Publish(OrderUpdated, "x")
+	if err := Send(bc, OrderUpdated, "y"); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	if fmt.Sprint(got) != "[bus:x bus:y x y]" {
+		t.Errorf("unexpected events: %v", got)
+	}
+}
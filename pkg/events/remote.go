@@ -0,0 +1,98 @@
+package events
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// Broadcaster sends messages to all the bot instances, brain.PubSub
+// implements it
+type Broadcaster interface {
+	Publish(channel string, msg interface{}) error
+	Subscribe(channel string, fn func(msg []byte)) (func() error, error)
+}
+
+// Decoder returns the data of an event of a given topic from its JSON encoding
+type Decoder func(data []byte) (interface{}, error)
+
+// envelope is an event as sent to the other instances
+type envelope struct {
+	Source string
+	Time   time.Time
+	Data   json.RawMessage
+}
+
+func channel(topic Topic) string {
+	return "events:" + string(topic)
+}
+
+// Share sends the events of topic published on this bus to the other bot
+// instances, and publishes here the ones they send, with Remote set. decode
+// returns the event data as the handlers expect it. The returned function
+// stops sharing.
+func (b *Bus) Share(bc Broadcaster, topic Topic, decode Decoder) (func() error, error) {
+	stop, err := bc.Subscribe(channel(topic), func(msg []byte) {
+		var env envelope
+		if err := json.Unmarshal(msg, &env); err != nil {
+			log.Printf("Invalid %s event: %v", topic, err)
+			return
+		}
+		if env.Source == b.id {
+			return
+		}
+		data, err := decode(env.Data)
+		if err != nil {
+			log.Printf("Invalid %s event data: %v", topic, err)
+			return
+		}
+		b.publish(Event{Topic: topic, Time: env.Time, Data: data, Remote: true})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	b.Subscribe(topic, func(ev Event) {
+		if ev.Remote {
+			return
+		}
+		data, err := json.Marshal(ev.Data)
+		if err != nil {
+			log.Printf("Error encoding %s event: %v", topic, err)
+			return
+		}
+		if err := bc.Publish(channel(topic), envelope{b.id, ev.Time, data}); err != nil {
+			log.Printf("Error sharing %s event: %v", topic, err)
+		}
+	})
+	return stop, nil
+}
+
+// Send publishes an event of topic on the buses of all the bot instances
+// sharing it, e.g. from an HTTP handler with no bot
+func Send(bc Broadcaster, topic Topic, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return bc.Publish(channel(topic), envelope{Time: time.Now(), Data: encoded})
+}
+
+// Watch calls fn with the events of topic shared by all the bot instances,
+// see Share, as they happen: it's for the clients following the events, e.g.
+// the streams of the order updates. The returned function stops watching.
+func Watch(bc Broadcaster, topic Topic, decode Decoder, fn Handler) (func() error, error) {
+	return bc.Subscribe(channel(topic), func(msg []byte) {
+		var env envelope
+		if err := json.Unmarshal(msg, &env); err != nil {
+			log.Printf("Invalid %s event: %v", topic, err)
+			return
+		}
+		data, err := decode(env.Data)
+		if err != nil {
+			log.Printf("Invalid %s event data: %v", topic, err)
+			return
+		}
+		dispatch(fn, Event{Topic: topic, Time: env.Time, Data: data, Remote: true})
+	})
+}
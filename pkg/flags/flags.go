@@ -0,0 +1,136 @@
+// Package flags turns the risky features on in some channels only, so that
+// they can be rolled out gradually and turned off at runtime, without
+// redeploying. The rollout of each flag is kept in the brain: the share of
+// the channels having it, plus the channels where it was turned on or off
+// explicitly.
+package flags
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/brain"
+)
+
+// Flag is a feature which can be rolled out gradually
+type Flag struct {
+	Name        string
+	Description string
+}
+
+// registry are the flags defined by the features, by name
+var registry = map[string]*Flag{}
+
+// Define defines the flag name of a feature, off everywhere until rolled
+// out. It panics if name is already defined, like a duplicated route.
+func Define(name, description string) *Flag {
+	if _, ok := registry[name]; ok {
+		panic("flags: " + name + " defined twice")
+	}
+	f := &Flag{name, description}
+	registry[name] = f
+	return f
+}
+
+// Lookup returns the flag named name
+func Lookup(name string) (*Flag, bool) {
+	f, ok := registry[strings.ToLower(name)]
+	return f, ok
+}
+
+// All returns the flags defined, sorted by name
+func All() []*Flag {
+	all := make([]*Flag, 0, len(registry))
+	for _, f := range registry {
+		all = append(all, f)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// Rollout tells where a flag is on
+type Rollout struct {
+	// Percent is the share of the channels having the flag, always the same
+	// ones and including the ones of the smaller shares
+	Percent int `json:",omitempty"`
+	// Channels are the channels where the flag was turned on or off
+	// regardless of Percent
+	Channels map[string]bool `json:",omitempty"`
+}
+
+// Enabled tells if the flag is on in channel
+func (r Rollout) Enabled(name, channel string) bool {
+	if on, ok := r.Channels[channel]; ok {
+		return on
+	}
+	return bucket(name, channel) < r.Percent
+}
+
+// bucket returns the stable place of channel, from 0 to 99, in the rollout of
+// the flag name. Each flag has its own order, not to always try them all in
+// the same channels first.
+func bucket(name, channel string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name + ":" + channel))
+	return int(h.Sum32() % 100)
+}
+
+func (r Rollout) String() string {
+	s := fmt.Sprintf("%d%% dei canali", r.Percent)
+	switch r.Percent {
+	case 0:
+		s = "spento"
+	case 100:
+		s = "acceso"
+	}
+	channels := make([]string, 0, len(r.Channels))
+	for c := range r.Channels {
+		channels = append(channels, c)
+	}
+	sort.Strings(channels)
+	for _, c := range channels {
+		if r.Channels[c] {
+			s += fmt.Sprintf(", acceso in <#%s>", c)
+		} else {
+			s += fmt.Sprintf(", spento in <#%s>", c)
+		}
+	}
+	return s
+}
+
+// repo returns where the rollouts are kept in b, by flag name
+func repo(b brain.KV) brain.Repo[Rollout] {
+	return brain.NewRepo[Rollout](b, "flags:")
+}
+
+// Get returns the rollout of f in b, off everywhere if never rolled out
+func (f *Flag) Get(b brain.KV) (Rollout, error) {
+	r, err := repo(b).Get(f.Name)
+	if err == brain.ErrNotFound {
+		return Rollout{}, nil
+	}
+	return r, err
+}
+
+// Set stores the rollout of f in b
+func (f *Flag) Set(b brain.KV, r Rollout) error {
+	if r.Percent < 0 || r.Percent > 100 {
+		return fmt.Errorf("percentuale %d non valida, deve essere tra 0 e 100", r.Percent)
+	}
+	if r.Percent == 0 && len(r.Channels) == 0 {
+		return repo(b).Delete(f.Name)
+	}
+	return repo(b).Put(f.Name, r)
+}
+
+// Enabled tells if f is on in channel. It's off if the brain can't be read,
+// the features behind a flag being the risky ones.
+func (f *Flag) Enabled(b brain.KV, channel string) bool {
+	r, err := f.Get(b)
+	if err != nil {
+		return false
+	}
+	return r.Enabled(f.Name, channel)
+}
@@ -0,0 +1,66 @@
+package flags
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/brain"
+)
+
+var flagTest = Define("test", "la funzione dei test")
+
+func TestRollout(t *testing.T) {
+	b := brain.NewMemory()
+	if flagTest.Enabled(b, "C1") {
+		t.Error("on before the rollout")
+	}
+
+	// the channels of a share are in the bigger ones too
+	on := map[int]int{}
+	for _, p := range []int{10, 50, 100} {
+		if err := flagTest.Set(b, Rollout{Percent: p}); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 1000; i++ {
+			c := fmt.Sprintf("C%d", i)
+			if flagTest.Enabled(b, c) {
+				on[p]++
+			} else if p > 10 && bucket("test", c) < 10 {
+				t.Errorf("%s off at %d%%", c, p)
+			}
+		}
+	}
+	if on[10] < 50 || on[10] > 150 || on[50] < 400 || on[50] > 600 || on[100] != 1000 {
+		t.Errorf("channels on: got %v", on)
+	}
+
+	r := Rollout{Channels: map[string]bool{"C1": true, "C2": false}, Percent: 100}
+	if err := flagTest.Set(b, r); err != nil {
+		t.Fatal(err)
+	}
+	if !flagTest.Enabled(b, "C1") || flagTest.Enabled(b, "C2") || !flagTest.Enabled(b, "C3") {
+		t.Errorf("overrides not applied: %+v", r)
+	}
+	if s := r.String(); s != "acceso, acceso in <#C1>, spento in <#C2>" {
+		t.Errorf("got %q", s)
+	}
+
+	if err := flagTest.Set(b, Rollout{Percent: 101}); err == nil {
+		t.Error("invalid percent set")
+	}
+	if err := flagTest.Set(b, Rollout{}); err != nil {
+		t.Fatal(err)
+	}
+	if ids, _ := repo(b).IDs(); len(ids) != 0 {
+		t.Errorf("rollout kept when off everywhere: %v", ids)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if f, ok := Lookup("TEST"); !ok || f != flagTest {
+		t.Errorf("got %v, %v", f, ok)
+	}
+	if _, ok := Lookup("boh"); ok {
+		t.Error("unknown flag found")
+	}
+}
@@ -0,0 +1,191 @@
+// Package grammar parses the orders written by the users, e.g.
+// "2 penne + scaloppine & patate, oppure pollo + nota: ben cotto + -tiramisù"
+package grammar
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Dish is the text to look for in the menu, to be taken verbatim if quoted
+type Dish struct {
+	Text   string
+	Quoted bool
+}
+
+// Choice is a dish, possibly composed with side dishes ("secondo & contorno")
+type Choice []Dish
+
+// Item is an element of the order
+type Item struct {
+	Quantity int      // how many times the dish is ordered, at least 1
+	Choices  []Choice // the dish followed by its ranked alternatives
+	Note     string   // free text for the restaurant
+	Remove   bool     // the dish has to be removed from the current order
+}
+
+// Grammar holds the separators and keywords of the order syntax. A
+// separator preceded by a backslash is taken literally.
+type Grammar struct {
+	Combine      string   // joins the dishes of the order
+	Compose      string   // joins a dish with its side dishes
+	Alternatives []string // keywords introducing an alternative dish
+	Note         string   // prefix of a note for the previous dish
+	Remove       string   // prefix of a dish to remove
+	MaxQuantity  int      // quantities above are considered part of the dish name
+}
+
+// Default returns the grammar used by the bot
+func Default() Grammar {
+	return Grammar{
+		Combine:      "+",
+		Compose:      "&",
+		Alternatives: []string{"altrimenti", "oppure"},
+		Note:         "nota:",
+		Remove:       "-",
+		MaxQuantity:  20,
+	}
+}
+
+// Error is a syntax error in an order
+type Error struct {
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+func unescape(s, sep string) string {
+	s = strings.Replace(s, "\\"+sep, sep, -1)
+	s = strings.Replace(s, "\\\\", "\\", -1)
+	return s
+}
+
+// SplitEscaped splits s around sep, skipping the separators escaped by a
+// backslash
+func SplitEscaped(s, sep string) []string {
+	escC := byte('\\')
+
+	n := strings.Count(s, sep)
+	var a []string
+	i := 0
+	start := 0
+	startcp := 0
+
+	for i < n {
+		m := strings.Index(s[start:], sep)
+		if m < 0 {
+			break
+		}
+		m += start
+		if m == 0 || (m > 0 && s[m-1] != escC) {
+			a = append(a, unescape(s[startcp:m], sep))
+			startcp = m + len(sep)
+		}
+		start = m + len(sep)
+		i++
+	}
+
+	a = append(a, unescape(s[startcp:], sep))
+	return a
+}
+
+func (g Grammar) alternativesRe() *regexp.Regexp {
+	var kw []string
+	for _, a := range g.Alternatives {
+		kw = append(kw, regexp.QuoteMeta(a))
+	}
+	return regexp.MustCompile(`(?i),?\s+(?:` + strings.Join(kw, "|") + `)\s+`)
+}
+
+var quantityRe = regexp.MustCompile(`(?i)^(\d+)\s*x?\s+(.+)$`)
+
+// quantity splits the leading quantity, as in "2 penne" or "2x penne", from s
+func (g Grammar) quantity(s string) (int, string) {
+	m := quantityRe.FindStringSubmatch(s)
+	if m == nil {
+		return 1, s
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil || n < 1 || n > g.MaxQuantity {
+		return 1, s
+	}
+	return n, m[2]
+}
+
+// ParseChoice parses a dish composed with side dishes, the empty ones, as
+// in "pollo & ", are skipped
+func (g Grammar) ParseChoice(s string) (Choice, error) {
+	var c Choice
+	for _, d := range SplitEscaped(s, g.Compose) {
+		d = strings.TrimSpace(d)
+		quoted := len(d) > 1 && d[0] == '"' && d[len(d)-1] == '"'
+		d = strings.TrimSpace(strings.Trim(d, "\""))
+		if d == "" {
+			continue
+		}
+		c = append(c, Dish{d, quoted})
+	}
+	if len(c) == 0 {
+		return nil, &Error{fmt.Sprintf("C'è un piatto vuoto in '%s'", strings.TrimSpace(s))}
+	}
+	return c, nil
+}
+
+// parseItem parses a dish with its quantity and alternatives
+func (g Grammar) parseItem(s string) (Item, error) {
+	item := Item{Quantity: 1}
+	s = strings.TrimSpace(s)
+	if g.Remove != "" && strings.HasPrefix(s, g.Remove) {
+		item.Remove = true
+		s = strings.TrimSpace(s[len(g.Remove):])
+	} else {
+		item.Quantity, s = g.quantity(s)
+	}
+
+	for _, alt := range g.alternativesRe().Split(s, -1) {
+		c, err := g.ParseChoice(alt)
+		if err != nil {
+			return Item{}, err
+		}
+		item.Choices = append(item.Choices, c)
+	}
+	if item.Remove && len(item.Choices) > 1 {
+		return Item{}, &Error{fmt.Sprintf("Non puoi indicare alternative per un piatto da togliere: '%s'", s)}
+	}
+	return item, nil
+}
+
+// Parse parses an order
+func (g Grammar) Parse(s string) ([]Item, error) {
+	var items []Item
+	for _, part := range SplitEscaped(s, g.Combine) {
+		part = strings.TrimSpace(part)
+		if strings.HasPrefix(strings.ToLower(part), g.Note) {
+			note := strings.TrimSpace(part[len(g.Note):])
+			if len(items) == 0 || items[len(items)-1].Remove {
+				return nil, &Error{fmt.Sprintf("La nota '%s' deve seguire un piatto", note)}
+			}
+			items[len(items)-1].Note = note
+			continue
+		}
+
+		item, err := g.parseItem(part)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (c Choice) String() string {
+	var dishes []string
+	for _, d := range c {
+		dishes = append(dishes, d.Text)
+	}
+	return strings.Join(dishes, " & ")
+}
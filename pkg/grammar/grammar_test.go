@@ -0,0 +1,64 @@
+package grammar
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	g := Default()
+
+	tests := map[string]string{
+		"penne":                                  "[{1 [penne]  false}]",
+		"2 penne + 3x pollo":                     "[{2 [penne]  false} {3 [pollo]  false}]",
+		"25 penne":                               "[{1 [25 penne]  false}]",
+		"scaloppine & patate":                    "[{1 [scaloppine & patate]  false}]",
+		"scaloppine \\& patate":                  "[{1 [scaloppine & patate]  false}]",
+		"tagliata, altrimenti pollo oppure uova": "[{1 [tagliata pollo uova]  false}]",
+		"tagliata + nota: al sangue":             "[{1 [tagliata] al sangue false}]",
+		"-penne + pollo":                         "[{1 [penne]  true} {1 [pollo]  false}]",
+		"1 + 1":                                  "[{1 [1]  false} {1 [1]  false}]",
+		"scaloppine & ":                          "[{1 [scaloppine]  false}]",
+		"scaloppine & & patate":                  "[{1 [scaloppine & patate]  false}]",
+	}
+	for in, want := range tests {
+		items, err := g.Parse(in)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", in, err)
+		}
+		if got := fmt.Sprint(items); got != want {
+			t.Fatalf("%s: wanted %s, got %s", in, want, got)
+		}
+	}
+}
+
+func TestParseQuoted(t *testing.T) {
+	c, err := Default().ParseChoice(` "pasta senza glutine" & patate`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c) != 2 || c[0] != (Dish{"pasta senza glutine", true}) || c[1] != (Dish{"patate", false}) {
+		t.Fatalf("unexpected choice %#v", c)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, in := range []string{"nota: al sangue", "-penne + nota: al sangue", "-penne, oppure pollo", " & ", "penne + "} {
+		if _, err := Default().Parse(in); err == nil {
+			t.Fatalf("%s: expected an error", in)
+		}
+	}
+}
+
+func TestCustomGrammar(t *testing.T) {
+	g := Default()
+	g.Combine = ";"
+	g.Alternatives = []string{"or"}
+	items, err := g.Parse("penne or pollo; tiramisù + panna")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(items[0].Choices) != "[penne pollo]" || fmt.Sprint(items[1].Choices) != "[tiramisù + panna]" {
+		t.Fatalf("unexpected items %v", items)
+	}
+}
@@ -0,0 +1,89 @@
+// Package inflight counts the work in progress, the events being handled and
+// what they started in the background, so that the bot can wait for it to
+// be done before exiting instead of dropping the orders being placed.
+package inflight
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+var (
+	mu       sync.Mutex
+	idle     = sync.NewCond(&mu)
+	running  int
+	draining bool
+)
+
+// Begin counts a piece of work in progress until done is called, it's not
+// started and ok is false once the shutdown began, see Drain
+func Begin() (done func(), ok bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	if draining {
+		return nil, false
+	}
+	running++
+	return end(), true
+}
+
+// Go runs fn in the background, counted as work in progress even after the
+// shutdown began, since it was started by the work in progress
+func Go(fn func()) {
+	mu.Lock()
+	running++
+	mu.Unlock()
+	done := end()
+	go func() {
+		defer done()
+		fn()
+	}()
+}
+
+// end returns the function ending a piece of work, once
+func end() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			mu.Lock()
+			defer mu.Unlock()
+			if running--; running == 0 {
+				idle.Broadcast()
+			}
+		})
+	}
+}
+
+// Draining tells if the shutdown began and no new work is taken
+func Draining() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return draining
+}
+
+// Drain stops taking new work and waits for the work in progress to be done,
+// until ctx is done
+func Drain(ctx context.Context) error {
+	mu.Lock()
+	draining = true
+	mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		mu.Lock()
+		for running > 0 {
+			idle.Wait()
+		}
+		mu.Unlock()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		mu.Lock()
+		defer mu.Unlock()
+		return fmt.Errorf("%d still in progress: %w", running, ctx.Err())
+	}
+}
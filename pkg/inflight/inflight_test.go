@@ -0,0 +1,57 @@
+package inflight
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// reset takes new work again, for the next test
+func reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	draining = false
+}
+
+func TestDrain(t *testing.T) {
+	defer reset()
+	done, ok := Begin()
+	if !ok {
+		t.Fatal("work refused before the shutdown")
+	}
+	background := make(chan struct{})
+	Go(func() { <-background })
+
+	drained := make(chan error)
+	go func() { drained <- Drain(context.Background()) }()
+	for !Draining() {
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := Begin(); ok {
+		t.Fatal("work taken during the shutdown")
+	}
+
+	done()
+	done()
+	select {
+	case <-drained:
+		t.Fatal("drained with work in the background")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(background)
+	if err := <-drained; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	defer reset()
+	done, _ := Begin()
+	defer done()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+}
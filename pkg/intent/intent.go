@@ -0,0 +1,245 @@
+// Package intent routes the messages sent to the bot to the handler of what
+// the user means, e.g. ordering or looking at the menu. Each intent is
+// recognized by its patterns; a message matching none of them is scored
+// against the keywords of the intents, to suggest what the user may have
+// meant.
+package intent
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// The intents of the users of the bot
+const (
+	Order      = "order"
+	Cancel     = "cancel"
+	QueryMenu  = "menu"
+	QueryOrder = "query"
+	Help       = "help"
+	Admin      = "admin"
+	// Other is the intent of the commands not falling in the others
+	Other = "other"
+)
+
+// DefaultThreshold is the lowest score of an intent suggested to the user
+const DefaultThreshold = 0.75
+
+// maxSuggestions is the most suggestions returned for a message
+const maxSuggestions = 3
+
+// Route is a pattern recognizing an intent, with the handler of the
+// messages matching it and the usages of the commands it handles
+type Route[H any] struct {
+	Intent  string
+	Pattern *regexp.Regexp
+	Handler H
+	Usages  []Usage
+}
+
+// Usage documents a command for the help: how to write it, what it does and
+// some examples
+type Usage struct {
+	// Intent is the category of the command, the one of its route if empty
+	Intent      string
+	Syntax      string
+	Description string
+	// Details are shown only in the help of the command
+	Details  string
+	Examples []string
+}
+
+// Name returns the name of the command, the first word of its syntax
+func (u Usage) Name() string {
+	if f := strings.Fields(u.Syntax); len(f) > 0 {
+		return strings.ToLower(f[0])
+	}
+	return ""
+}
+
+// Suggestion is an intent the user may have meant, with an example of how to
+// express it
+type Suggestion struct {
+	Intent  string
+	Example string
+	Score   float64
+}
+
+// Result is the outcome of routing a message: the matching route, with the
+// submatches of its pattern, or the suggestions if none matches
+type Result[H any] struct {
+	Route       *Route[H]
+	Args        []string
+	Score       float64
+	Suggestions []Suggestion
+}
+
+type intentDef struct {
+	name     string
+	example  string
+	keywords []string
+}
+
+// Router finds the route of each message, trying the patterns in the order
+// they were added
+type Router[H any] struct {
+	// Threshold is the lowest score of a suggestion, DefaultThreshold if zero
+	Threshold float64
+
+	intents map[string]*intentDef
+	order   []string
+	routes  []*Route[H]
+}
+
+// New returns a router without intents
+func New[H any]() *Router[H] {
+	return &Router[H]{intents: make(map[string]*intentDef)}
+}
+
+// Intent defines an intent: the keywords hint it in the messages matching
+// no pattern, the example is suggested to the user in that case
+func (r *Router[H]) Intent(name, example string, keywords ...string) {
+	if _, ok := r.intents[name]; !ok {
+		r.order = append(r.order, name)
+	}
+	for i, k := range keywords {
+		keywords[i] = strings.ToLower(k)
+	}
+	r.intents[name] = &intentDef{name, example, keywords}
+}
+
+// Add routes the messages matching pattern to h, as the given intent. The
+// usages document the commands handled, the routes without usages are left
+// out of the help.
+func (r *Router[H]) Add(intent, pattern string, h H, usages ...Usage) {
+	for i := range usages {
+		if usages[i].Intent == "" {
+			usages[i].Intent = intent
+		}
+	}
+	r.routes = append(r.routes, &Route[H]{intent, regexp.MustCompile(pattern), h, usages})
+}
+
+// Usages returns the usages of all the commands, in the order they were
+// added
+func (r *Router[H]) Usages() []Usage {
+	var usages []Usage
+	for _, route := range r.routes {
+		usages = append(usages, route.Usages...)
+	}
+	return usages
+}
+
+// Lookup returns the usages of command: the ones named like its first word,
+// e.g. "per" for "per me lasagne", or else the ones of the route of command
+func (r *Router[H]) Lookup(command string) []Usage {
+	f := strings.Fields(strings.ToLower(command))
+	if len(f) == 0 {
+		return nil
+	}
+	var usages []Usage
+	for _, u := range r.Usages() {
+		if u.Name() == f[0] {
+			usages = append(usages, u)
+		}
+	}
+	if len(usages) > 0 {
+		return usages
+	}
+	if res := r.Route(command); res.Route != nil {
+		return res.Route.Usages
+	}
+	return nil
+}
+
+// Route returns the first route whose pattern matches text, with score 1.
+// If none does, the intents with keywords close enough to the words of text
+// are suggested, best first.
+func (r *Router[H]) Route(text string) Result[H] {
+	for _, route := range r.routes {
+		if m := route.Pattern.FindStringSubmatch(text); m != nil {
+			return Result[H]{Route: route, Args: m, Score: 1}
+		}
+	}
+
+	threshold := r.Threshold
+	if threshold == 0 {
+		threshold = DefaultThreshold
+	}
+	var res Result[H]
+	words := strings.Fields(strings.ToLower(text))
+	for _, name := range r.order {
+		def := r.intents[name]
+		if def.example == "" {
+			continue
+		}
+		if score := def.score(words); score >= threshold {
+			res.Suggestions = append(res.Suggestions, Suggestion{name, def.example, score})
+		}
+	}
+	sort.SliceStable(res.Suggestions, func(i, j int) bool {
+		return res.Suggestions[i].Score > res.Suggestions[j].Score
+	})
+	if len(res.Suggestions) > maxSuggestions {
+		res.Suggestions = res.Suggestions[:maxSuggestions]
+	}
+	if len(res.Suggestions) > 0 {
+		res.Score = res.Suggestions[0].Score
+	}
+	return res
+}
+
+// score returns how much the words resemble the keywords of the intent,
+// from 0 to 1
+func (def *intentDef) score(words []string) float64 {
+	best := 0.0
+	for _, w := range words {
+		for _, k := range def.keywords {
+			if s := similarity(w, k); s > best {
+				best = s
+			}
+		}
+	}
+	return best
+}
+
+// similarity returns 1 for equal strings, down to 0 for completely
+// different ones, by edit distance
+func similarity(a, b string) float64 {
+	n := utf8.RuneCountInString(a)
+	if m := utf8.RuneCountInString(b); m > n {
+		n = m
+	}
+	if n == 0 {
+		return 1
+	}
+	return 1 - float64(distance([]rune(a), []rune(b)))/float64(n)
+}
+
+// distance returns the edit distance between a and b, counting the swap of
+// two adjacent letters as a single edit, the most common typo
+func distance(a, b []rune) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
@@ -0,0 +1,94 @@
+package intent
+
+import (
+	"testing"
+)
+
+func TestRoute(t *testing.T) {
+	r := New[string]()
+	r.Intent(Order, "per me <piatto>", "per", "ordina")
+	r.Intent(QueryMenu, "menu", "menu", "menù")
+	r.Intent(Admin, "", "setmenu")
+	r.Add(Order, "^(?i)per ([^\\s:]+:?)\\s+(.*)$", "for")
+	r.Add(QueryMenu, "^(?i)menu(.*)$", "menu")
+	r.Add(Admin, "^(?i)setmenu(.*)$", "setmenu")
+
+	res := r.Route("per me lasagne")
+	if res.Route == nil || res.Route.Handler != "for" || res.Score != 1 {
+		t.Fatalf("wrong route %+v", res)
+	}
+	if len(res.Args) != 3 || res.Args[1] != "me" || res.Args[2] != "lasagne" {
+		t.Errorf("wrong args %q", res.Args)
+	}
+	if res := r.Route("Menu price"); res.Route == nil || res.Route.Intent != QueryMenu {
+		t.Errorf("wrong route %+v", res)
+	}
+
+	// a typo is not routed but suggested
+	res = r.Route("mneu")
+	if res.Route != nil {
+		t.Fatalf("unexpected route %+v", res.Route)
+	}
+	if len(res.Suggestions) != 1 || res.Suggestions[0].Example != "menu" || res.Score != res.Suggestions[0].Score {
+		t.Errorf("wrong suggestions %+v", res.Suggestions)
+	}
+
+	// the intents without example are never suggested
+	if res := r.Route("setmneu"); len(res.Suggestions) != 0 {
+		t.Errorf("unexpected suggestions %+v", res.Suggestions)
+	}
+	if res := r.Route("buongiorno a tutti"); res.Route != nil || len(res.Suggestions) != 0 || res.Score != 0 {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	r.Threshold = 0.1
+	res = r.Route("ordino il menù")
+	if len(res.Suggestions) != 2 || res.Suggestions[0].Intent != QueryMenu {
+		t.Errorf("wrong suggestions %+v", res.Suggestions)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want float64
+	}{
+		{"menu", "menu", 1},
+		{"menu", "menù", 0.75},
+		{"mneu", "menu", 0.75},
+		{"mneu", "menù", 0.5},
+		{"", "", 1},
+		{"abc", "xyz", 0},
+	} {
+		if got := similarity(c.a, c.b); got != c.want {
+			t.Errorf("similarity(%q, %q) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestUsages(t *testing.T) {
+	r := New[string]()
+	r.Add(Order, "^(?i)per ([^\\s:]+:?)\\s+(.*)$", "for",
+		Usage{Syntax: "per <utente> <ordine>", Description: "ordina"},
+		Usage{Intent: Cancel, Syntax: "per <utente> niente", Description: "cancella"})
+	r.Add(QueryOrder, "^(?i)(?:mancanti|chi manca\\??)\\s*(.*)$", "missing", Usage{Syntax: "mancanti", Description: "chi manca"})
+	r.Add(Admin, "^get (.*)$", "get")
+
+	usages := r.Usages()
+	if len(usages) != 3 || usages[0].Intent != Order || usages[1].Intent != Cancel || usages[2].Intent != QueryOrder {
+		t.Fatalf("wrong usages %+v", usages)
+	}
+	if got := r.Lookup("Per"); len(got) != 2 || got[0].Name() != "per" {
+		t.Errorf("wrong lookup %+v", got)
+	}
+	// a command not named like its usages is looked up by its route
+	if got := r.Lookup("chi manca?"); len(got) != 1 || got[0].Syntax != "mancanti" {
+		t.Errorf("wrong lookup %+v", got)
+	}
+	if got := r.Lookup("get x"); len(got) != 0 {
+		t.Errorf("unexpected lookup %+v", got)
+	}
+	if got := r.Lookup(" "); got != nil {
+		t.Errorf("unexpected lookup %+v", got)
+	}
+}
@@ -0,0 +1,364 @@
+// Package jobs runs the slow side effects of the events in the background,
+// like uploading the exports or calling the other tools. The jobs are kept
+// in a list of the brain shared by the bot instances, so that the handlers
+// answer Slack within its 3 seconds and the jobs failing are tried again
+// later, possibly by another instance.
+//
+// The jobs being run are moved to a processing list, so that the ones of an
+// instance dying halfway are queued again once their lease expires, and the
+// ones failing wait in a sorted set by the time they are due. A job is run
+// at least once, it may be run again if its instance dies right after it.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/inflight"
+	"github.com/develersrl/lunches/pkg/sentry"
+)
+
+// The defaults of a Worker
+const (
+	defaultAttempts = 5
+	defaultBackoff  = 10 * time.Second
+	defaultPoll     = time.Second
+	defaultLease    = 10 * time.Minute
+)
+
+// ErrUnknown is the error of the jobs of a kind without a handler, they are
+// not tried again
+var ErrUnknown = errors.New("jobs: unknown kind")
+
+// Store is the part of a brain.Store keeping the queue
+type Store interface {
+	Set(key string, val interface{}) error
+	Get(key string, q interface{}) error
+	Delete(key string) error
+	Append(key string, vals ...interface{}) error
+	Range(key string, start, stop int64, q interface{}) error
+	Move(src, dst string, q interface{}) error
+	Remove(key string, val interface{}) error
+	ZAdd(key string, member string, score float64) error
+	ZRange(key string, start, stop int64) ([]brain.Scored, error)
+	ZRem(key string, member string) (bool, error)
+}
+
+// Job is a piece of work in the queue
+type Job struct {
+	ID   string
+	Kind string
+	Data json.RawMessage
+	// Attempts is how many times the job failed
+	Attempts int `json:",omitempty"`
+	// Due is when the job can be tried again, after a failure
+	Due time.Time
+}
+
+// Handler runs a job with its data, the jobs failing are tried again
+type Handler func(data json.RawMessage) error
+
+// Queue is the list of the jobs at key in the store, the other keys of the
+// queue start with key too
+type Queue struct {
+	store Store
+	key   string
+}
+
+// New returns the queue of the jobs at key in store
+func New(store Store, key string) *Queue {
+	return &Queue{store, key}
+}
+
+// processing is the list of the jobs being run
+func (q *Queue) processing() string {
+	return q.key + ":processing"
+}
+
+// leases is the sorted set of the IDs of the jobs being run, by the time
+// they are taken as lost
+func (q *Queue) leases() string {
+	return q.key + ":leases"
+}
+
+// delayed is the sorted set of the IDs of the jobs failed, by the time they
+// are due
+func (q *Queue) delayed() string {
+	return q.key + ":delayed"
+}
+
+// delayedJob is the key of the failed job with id, waiting to be due
+func (q *Queue) delayedJob(id string) string {
+	return q.key + ":delayed:" + id
+}
+
+// score returns the score of t in the sorted sets of the queue
+func score(t time.Time) float64 {
+	return float64(t.UnixMilli())
+}
+
+// newID returns a random ID for a job, to follow it in the logs
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Push queues a job of kind with data, JSON encoded
+func (q *Queue) Push(kind string, data interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+	return q.store.Append(q.key, Job{ID: id, Kind: kind, Data: raw})
+}
+
+// Worker runs the jobs of a queue with the handlers of their kind
+type Worker struct {
+	queue    *Queue
+	handlers map[string]Handler
+
+	// Attempts is how many times a job is run before giving up
+	Attempts int
+	// Backoff is how long the first retry waits, doubled at each failure
+	Backoff time.Duration
+	// Poll is how long the worker waits when there are no jobs due
+	Poll time.Duration
+	// Lease is how long a job can run before the workers take it as lost
+	// with its instance and queue it again
+	Lease time.Duration
+}
+
+// NewWorker returns a worker of q, without handlers
+func NewWorker(q *Queue) *Worker {
+	return &Worker{
+		queue:    q,
+		handlers: make(map[string]Handler),
+		Attempts: defaultAttempts,
+		Backoff:  defaultBackoff,
+		Poll:     defaultPoll,
+		Lease:    defaultLease,
+	}
+}
+
+// Handle runs the jobs of kind with h
+func (w *Worker) Handle(kind string, h Handler) {
+	w.handlers[kind] = h
+}
+
+// Next runs the next job due, false if there's none. The failed jobs due
+// and the ones lost by the workers are queued again first.
+func (w *Worker) Next() (bool, error) {
+	now := time.Now()
+	if err := w.promote(now); err != nil {
+		return false, err
+	}
+	if err := w.recover(now); err != nil {
+		return false, err
+	}
+
+	var job Job
+	err := w.queue.store.Move(w.queue.key, w.queue.processing(), &job)
+	if err == brain.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := w.queue.store.ZAdd(w.queue.leases(), job.ID, score(time.Now().Add(w.Lease))); err != nil {
+		return true, err
+	}
+	if err := w.run(job); err != nil {
+		// it's still among the ones being run, it will be recovered
+		return true, err
+	}
+	return true, w.done(job)
+}
+
+// promote queues again the failed jobs due at now, behind the others
+func (w *Worker) promote(now time.Time) error {
+	for {
+		due, err := w.queue.store.ZRange(w.queue.delayed(), 0, 0)
+		if err != nil {
+			return err
+		}
+		if len(due) == 0 || due[0].Score > score(now) {
+			return nil
+		}
+		id := due[0].Member
+		var job Job
+		if err := w.queue.store.Get(w.queue.delayedJob(id), &job); err != nil && err != brain.ErrNotFound {
+			return err
+		}
+		// the worker removing the ID is the one queuing the job
+		claimed, err := w.queue.store.ZRem(w.queue.delayed(), id)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+		if job.ID != "" {
+			if err := w.queue.store.Append(w.queue.key, job); err != nil {
+				return err
+			}
+		}
+		if err := w.queue.store.Delete(w.queue.delayedJob(id)); err != nil {
+			return err
+		}
+	}
+}
+
+// recover queues again the jobs being run whose lease expired at now, their
+// worker died while running them, and counts it as a failure
+func (w *Worker) recover(now time.Time) error {
+	var running []Job
+	if err := w.queue.store.Range(w.queue.processing(), 0, -1, &running); err != nil {
+		return err
+	}
+	leases, err := w.queue.store.ZRange(w.queue.leases(), 0, -1)
+	if err != nil {
+		return err
+	}
+	expires := make(map[string]float64)
+	for _, l := range leases {
+		expires[l.Member] = l.Score
+	}
+
+	for _, job := range running {
+		expiry, ok := expires[job.ID]
+		delete(expires, job.ID)
+		if !ok {
+			// its worker died before taking the lease, or is about to take it
+			if err := w.queue.store.ZAdd(w.queue.leases(), job.ID, score(now.Add(w.Lease))); err != nil {
+				return err
+			}
+			continue
+		}
+		if expiry > score(now) {
+			continue
+		}
+		claimed, err := w.queue.store.ZRem(w.queue.leases(), job.ID)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+
+		lost := job
+		lost.Attempts++
+		log := slog.With("job_id", job.ID, "job_kind", job.Kind)
+		if lost.Attempts >= w.Attempts {
+			jobsRun.WithLabelValues(job.Kind, "failed").Inc()
+			log.Error("Job lost, giving up", "attempts", lost.Attempts)
+		} else {
+			jobsRun.WithLabelValues(job.Kind, "retried").Inc()
+			log.Warn("Job lost, trying again", "attempts", lost.Attempts)
+			if err := w.queue.store.Append(w.queue.key, lost); err != nil {
+				return err
+			}
+		}
+		if err := w.queue.store.Remove(w.queue.processing(), job); err != nil {
+			return err
+		}
+	}
+
+	// the leases of the jobs done in the meantime
+	for id, expiry := range expires {
+		if expiry <= score(now) {
+			if _, err := w.queue.store.ZRem(w.queue.leases(), id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// done removes job from the ones being run
+func (w *Worker) done(job Job) error {
+	if err := w.queue.store.Remove(w.queue.processing(), job); err != nil {
+		return err
+	}
+	_, err := w.queue.store.ZRem(w.queue.leases(), job.ID)
+	return err
+}
+
+// run runs job, delaying it if it fails and it can be tried again
+func (w *Worker) run(job Job) error {
+	ctx := sentry.WithContext(context.Background(), map[string]string{"job_id": job.ID, "job_kind": job.Kind}, nil)
+	log := slog.With("job_id", job.ID, "job_kind", job.Kind)
+
+	err := ErrUnknown
+	h, ok := w.handlers[job.Kind]
+	if ok {
+		err = handle(ctx, h, job.Data)
+	}
+	if err == nil {
+		jobsRun.WithLabelValues(job.Kind, "done").Inc()
+		return nil
+	}
+
+	job.Attempts++
+	if !ok || job.Attempts >= w.Attempts {
+		jobsRun.WithLabelValues(job.Kind, "failed").Inc()
+		log.Error("Job failed, giving up", "attempts", job.Attempts, "err", err)
+		sentry.CaptureError(ctx, err)
+		return nil
+	}
+	jobsRun.WithLabelValues(job.Kind, "retried").Inc()
+	job.Due = time.Now().Add(w.Backoff << (job.Attempts - 1))
+	log.Warn("Job failed, trying again later", "attempts", job.Attempts, "due", job.Due, "err", err)
+	if err := w.queue.store.Set(w.queue.delayedJob(job.ID), job); err != nil {
+		return err
+	}
+	return w.queue.store.ZAdd(w.queue.delayed(), job.ID, score(job.Due))
+}
+
+// handle runs h with data, a panic is reported and makes the job fail
+func handle(ctx context.Context, h Handler, data json.RawMessage) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			sentry.CapturePanic(ctx, v)
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+	return h(data)
+}
+
+// Run runs the jobs until ctx is done or the shutdown begins, the job being
+// run is counted as work in progress, see inflight. Many workers can run the
+// jobs of the same queue, also on other instances.
+func (w *Worker) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		done, ok := inflight.Begin()
+		if !ok {
+			return
+		}
+		ran, err := w.Next()
+		done()
+		if err != nil {
+			slog.Error("Error running the jobs", "queue", w.queue.key, "err", err)
+		}
+		if ran && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(w.Poll):
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+)
+
+func TestWorker(t *testing.T) {
+	m := brain.NewMemory()
+	q := New(m, "jobs")
+	w := NewWorker(q)
+	w.Backoff = 20 * time.Millisecond
+
+	var got []string
+	fail := 1
+	w.Handle("echo", func(data json.RawMessage) error {
+		var s string
+		json.Unmarshal(data, &s)
+		if s == "flaky" && fail > 0 {
+			fail--
+			return errors.New("not now")
+		}
+		got = append(got, s)
+		return nil
+	})
+	w.Handle("panic", func(data json.RawMessage) error {
+		panic("boom")
+	})
+
+	q.Push("echo", "flaky")
+	q.Push("unknown", nil)
+	q.Push("echo", "uno")
+	q.Push("panic", nil)
+
+	// the failed jobs wait behind the others
+	for i := 0; i < 4; i++ {
+		if ran, err := w.Next(); !ran || err != nil {
+			t.Fatalf("job %d not run: %v", i, err)
+		}
+	}
+	if len(got) != 1 || got[0] != "uno" {
+		t.Fatalf("unexpected jobs run %v", got)
+	}
+	if ran, err := w.Next(); ran || err != nil {
+		t.Fatalf("job run before it's due: %v", err)
+	}
+
+	// the failed jobs wait until they are due
+	delayed, _ := m.ZRange("jobs:delayed", 0, -1)
+	if len(delayed) != 2 {
+		t.Fatalf("unexpected delayed jobs %+v", delayed)
+	}
+	kinds := map[string]bool{}
+	for _, d := range delayed {
+		var j Job
+		m.Get("jobs:delayed:"+d.Member, &j)
+		if j.Attempts != 1 || j.Due.IsZero() {
+			t.Fatalf("unexpected job %+v", j)
+		}
+		kinds[j.Kind] = true
+	}
+	if !kinds["echo"] || !kinds["panic"] {
+		t.Fatalf("unexpected delayed jobs %v", kinds)
+	}
+
+	w.Attempts = 2
+	var queued, running []Job
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		w.Next()
+		delayed, _ = m.ZRange("jobs:delayed", 0, -1)
+		queued, running = nil, nil
+		m.Range("jobs", 0, -1, &queued)
+		m.Range("jobs:processing", 0, -1, &running)
+		if len(delayed)+len(queued)+len(running) == 0 {
+			break
+		}
+	}
+	// the job panicking again is given up
+	if len(delayed) != 0 || len(queued) != 0 || len(running) != 0 {
+		t.Fatalf("unexpected queue %+v %+v %+v", delayed, queued, running)
+	}
+	if keys, _ := m.Keys("jobs:*"); len(keys) > 3 {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+	if len(got) != 2 || got[1] != "flaky" {
+		t.Fatalf("unexpected jobs run %v", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	q := New(brain.NewMemory(), "jobs")
+	w := NewWorker(q)
+	w.Poll = time.Millisecond
+	ran := make(chan string, 1)
+	w.Handle("echo", func(data json.RawMessage) error {
+		var s string
+		json.Unmarshal(data, &s)
+		ran <- s
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		w.Run(ctx)
+	}()
+	q.Push("echo", "uno")
+	if s := <-ran; s != "uno" {
+		t.Fatalf("unexpected job %q", s)
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("worker not stopped")
+	}
+}
+
+func TestWorkerLost(t *testing.T) {
+	m := brain.NewMemory()
+	q := New(m, "jobs")
+	w := NewWorker(q)
+	w.Lease = time.Hour
+
+	var got []string
+	w.Handle("echo", func(data json.RawMessage) error {
+		var s string
+		json.Unmarshal(data, &s)
+		got = append(got, s)
+		return nil
+	})
+
+	// a worker died running the job
+	q.Push("echo", "uno")
+	var job Job
+	m.Move("jobs", "jobs:processing", &job)
+	m.ZAdd("jobs:leases", job.ID, score(time.Now().Add(time.Hour)))
+
+	if ran, err := w.Next(); ran || err != nil {
+		t.Fatalf("job run before its lease expired: %v", err)
+	}
+	m.ZAdd("jobs:leases", job.ID, score(time.Now().Add(-time.Second)))
+	if ran, err := w.Next(); !ran || err != nil {
+		t.Fatalf("lost job not run: %v", err)
+	}
+	if len(got) != 1 || got[0] != "uno" {
+		t.Fatalf("unexpected jobs run %v", got)
+	}
+	var running []Job
+	m.Range("jobs:processing", 0, -1, &running)
+	leases, _ := m.ZRange("jobs:leases", 0, -1)
+	if len(running) != 0 || len(leases) != 0 {
+		t.Fatalf("job still running %+v %+v", running, leases)
+	}
+}
+
+func TestWorkerDelayed(t *testing.T) {
+	m := brain.NewMemory()
+	w := NewWorker(New(m, "jobs"))
+	w.Backoff = time.Hour
+	w.Handle("fail", func(data json.RawMessage) error {
+		return errors.New("not now")
+	})
+	w.queue.Push("fail", nil)
+	w.Next()
+
+	// the jobs not due are not popped, so the workers don't spin on them
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		if ran, err := w.Next(); ran || err != nil {
+			t.Fatalf("job run before it's due: %v", err)
+		}
+	}
+	var queued []Job
+	m.Range("jobs", 0, -1, &queued)
+	if len(queued) != 0 || time.Since(start) > time.Second {
+		t.Fatalf("unexpected queue %+v", queued)
+	}
+}
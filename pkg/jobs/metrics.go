@@ -0,0 +1,14 @@
+package jobs
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var jobsRun = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "jobs_total",
+	Help: "Jobs run by kind and result: done, retried or failed.",
+}, []string{"kind", "result"})
+
+func init() {
+	prometheus.MustRegister(jobsRun)
+}
@@ -0,0 +1,70 @@
+// Package logging sets up the structured logs of the bot: JSON lines for the
+// log aggregation of the hosting platform, with levels, and loggers carrying
+// the correlation ID of the Slack event being handled, so that all the lines
+// logged for an event can be found together.
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// The formats of the logs, set with LOG_FORMAT
+const (
+	JSON = "json"
+	Text = "text"
+)
+
+// ParseLevel returns the level named by s, e.g. "debug" or "WARN", info if
+// it's not a known level
+func ParseLevel(s string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+// New returns a logger writing to w in format, JSON unless it's Text, the
+// lines below level are dropped
+func New(w io.Writer, format string, level slog.Level) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	if strings.ToLower(format) == Text {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+// Setup makes the logger set by LOG_FORMAT and LOG_LEVEL the default one,
+// JSON at info level without them. The log package writes through it too,
+// at info level.
+func Setup() {
+	slog.SetDefault(New(os.Stderr, os.Getenv("LOG_FORMAT"), ParseLevel(os.Getenv("LOG_LEVEL"))))
+}
+
+// NewID returns a random correlation ID, for the requests which have none
+func NewID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Event returns the default logger with the correlation ID of an event and
+// its team, channel and user, the empty ones are left out. A new ID is used
+// if id is empty.
+func Event(id, team, channel, user string) *slog.Logger {
+	if id == "" {
+		id = NewID()
+	}
+	args := []any{"event_id", id}
+	for _, a := range []struct{ key, value string }{{"team", team}, {"channel", channel}, {"user", user}} {
+		if a.value != "" {
+			args = append(args, a.key, a.value)
+		}
+	}
+	return slog.Default().With(args...)
+}
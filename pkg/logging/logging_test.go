@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	for s, want := range map[string]slog.Level{
+		"":       slog.LevelInfo,
+		"debug":  slog.LevelDebug,
+		"WARN":   slog.LevelWarn,
+		" error": slog.LevelError,
+		"boh":    slog.LevelInfo,
+	} {
+		if got := ParseLevel(s); got != want {
+			t.Errorf("ParseLevel(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestEvent(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(New(&buf, JSON, slog.LevelInfo))
+
+	l := Event("Ev123", "T1", "", "U1")
+	l.Debug("dropped")
+	l.Error("Error saving the order", "err", "boom")
+	// the log package goes through the default logger too
+	log.Printf("plain %d", 1)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines: %q", len(lines), buf.String())
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]string{
+		"level":    "ERROR",
+		"msg":      "Error saving the order",
+		"event_id": "Ev123",
+		"team":     "T1",
+		"user":     "U1",
+		"err":      "boom",
+	} {
+		if got[k] != want {
+			t.Errorf("%s = %v, want %s", k, got[k], want)
+		}
+	}
+	if _, ok := got["channel"]; ok {
+		t.Errorf("empty channel logged: %v", got)
+	}
+	if !strings.Contains(lines[1], `"msg":"plain 1"`) {
+		t.Errorf("log line not structured: %s", lines[1])
+	}
+
+	buf.Reset()
+	Event("", "", "", "").Info("new")
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := got["event_id"].(string); len(id) != 16 {
+		t.Errorf("bad new ID %q", id)
+	}
+}
+
+func TestNewText(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, "TEXT", slog.LevelWarn).Warn("Slow brain", "key", "order")
+	if got := buf.String(); !strings.Contains(got, "level=WARN") || !strings.Contains(got, "key=order") {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,197 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mailgun/mailgun-go/v3"
+)
+
+// Mailer sends plain text emails
+type Mailer interface {
+	Send(from string, to []string, subject, body string) error
+}
+
+// Attachment is a file attached to an email
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// AttachmentMailer sends emails with files attached
+type AttachmentMailer interface {
+	SendAttachments(from string, to []string, subject, body string, files ...Attachment) error
+}
+
+// SendFiles sends an email with files attached, appended to the body as
+// text if m can't attach them
+func SendFiles(m Mailer, from string, to []string, subject, body string, files ...Attachment) error {
+	if am, ok := m.(AttachmentMailer); ok {
+		return am.SendAttachments(from, to, subject, body, files...)
+	}
+	for _, f := range files {
+		body += fmt.Sprintf("\n\n--- %s ---\n%s", f.Name, f.Data)
+	}
+	return m.Send(from, to, subject, body)
+}
+
+// New returns the mailer configured in the environment: SMTP if SMTP_HOST is
+// set, Mailgun if MAILGUN_DOMAIN and MAILGUN_API_KEY are.
+func New() (Mailer, error) {
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return &SMTP{
+			Addr:     host + ":" + port,
+			Host:     host,
+			User:     os.Getenv("SMTP_USER"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		}, nil
+	}
+
+	domain := os.Getenv("MAILGUN_DOMAIN")
+	apiKey := os.Getenv("MAILGUN_API_KEY")
+	if domain != "" && apiKey != "" {
+		return &Mailgun{mailgun.NewMailgun(domain, apiKey)}, nil
+	}
+
+	return nil, errors.New("no mail provider configured, set SMTP_HOST or MAILGUN_DOMAIN and MAILGUN_API_KEY")
+}
+
+// SMTP sends emails through an SMTP server
+type SMTP struct {
+	Addr     string
+	Host     string
+	User     string
+	Password string
+}
+
+// Send implements Mailer
+func (s *SMTP) Send(from string, to []string, subject, body string) error {
+	var auth smtp.Auth
+	if s.User != "" {
+		auth = smtp.PlainAuth("", s.User, s.Password, s.Host)
+	}
+	return smtp.SendMail(s.Addr, auth, from, to, Message(from, to, subject, body))
+}
+
+// SendAttachments implements AttachmentMailer
+func (s *SMTP) SendAttachments(from string, to []string, subject, body string, files ...Attachment) error {
+	var auth smtp.Auth
+	if s.User != "" {
+		auth = smtp.PlainAuth("", s.User, s.Password, s.Host)
+	}
+	msg, err := MessageWithAttachments(from, to, subject, body, files...)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(s.Addr, auth, from, to, msg)
+}
+
+// Message returns the raw email message
+func Message(from string, to []string, subject, body string) []byte {
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=\"utf-8\"\r\n\r\n%s\r\n",
+		from, strings.Join(to, ", "), subject, strings.Replace(body, "\n", "\r\n", -1))
+	return []byte(msg)
+}
+
+// MessageWithAttachments returns the raw multipart email message with the
+// files attached
+func MessageWithAttachments(from string, to []string, subject, body string, files ...Attachment) ([]byte, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%q\r\n\r\n",
+		from, strings.Join(to, ", "), subject, w.Boundary())
+
+	part, err := w.CreatePart(textproto.MIMEHeader{"Content-Type": {`text/plain; charset="utf-8"`}})
+	if err != nil {
+		return nil, err
+	}
+	part.Write([]byte(strings.Replace(body, "\n", "\r\n", -1) + "\r\n"))
+
+	for _, f := range files {
+		part, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {f.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": f.Name})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoded := base64.StdEncoding.EncodeToString(f.Data)
+		for len(encoded) > 76 {
+			part.Write([]byte(encoded[:76] + "\r\n"))
+			encoded = encoded[76:]
+		}
+		part.Write([]byte(encoded + "\r\n"))
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Mailgun sends emails through the Mailgun API
+type Mailgun struct {
+	mg *mailgun.MailgunImpl
+}
+
+// Send implements Mailer
+func (m *Mailgun) Send(from string, to []string, subject, body string) error {
+	msg := m.mg.NewMessage(from, subject, body, to...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	_, id, err := m.mg.Send(ctx, msg)
+	log.Println("Sendmail ID", id)
+	return err
+}
+
+// SendAttachments implements AttachmentMailer
+func (m *Mailgun) SendAttachments(from string, to []string, subject, body string, files ...Attachment) error {
+	msg := m.mg.NewMessage(from, subject, body, to...)
+	for _, f := range files {
+		msg.AddBufferAttachment(f.Name, f.Data)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	_, id, err := m.mg.Send(ctx, msg)
+	log.Println("Sendmail ID", id)
+	return err
+}
+
+// DryRun logs the emails instead of sending them
+type DryRun struct{}
+
+// Send implements Mailer
+func (DryRun) Send(from string, to []string, subject, body string) error {
+	log.Printf("Dry run, not sending email:\n%s", Message(from, to, subject, body))
+	return nil
+}
+
+// SendAttachments implements AttachmentMailer
+func (DryRun) SendAttachments(from string, to []string, subject, body string, files ...Attachment) error {
+	var names []string
+	for _, f := range files {
+		names = append(names, fmt.Sprintf("%s (%d bytes)", f.Name, len(f.Data)))
+	}
+	log.Printf("Dry run, not sending email with %s:\n%s", strings.Join(names, ", "), Message(from, to, subject, body))
+	return nil
+}
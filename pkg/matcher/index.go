@@ -0,0 +1,183 @@
+package matcher
+
+import (
+	"hash/fnv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// maxGram is the length of the longest n-grams indexed
+const maxGram = 3
+
+// Index is a menu compiled for matching: the dish names are normalized once
+// and the n-grams of their words point to the dishes containing them, so
+// that a query is scored only against the dishes having all its words
+type Index struct {
+	rows  []tuttobene.MenuRow
+	names []string // lowercase
+	exact map[string][]int
+	grams map[string][]int // the dishes having each n-gram, in menu order
+}
+
+// Compile returns the index of menu
+func Compile(menu tuttobene.Menu) *Index {
+	ix := &Index{
+		rows:  menu.Rows,
+		names: make([]string, len(menu.Rows)),
+		exact: make(map[string][]int),
+		grams: make(map[string][]int),
+	}
+	for i, r := range menu.Rows {
+		n := strings.ToLower(r.Content)
+		ix.names[i] = n
+		ix.exact[Normalize(n)] = append(ix.exact[Normalize(n)], i)
+
+		seen := make(map[string]bool)
+		for _, w := range words(n) {
+			for _, g := range grams(w) {
+				if !seen[g] {
+					seen[g] = true
+					ix.grams[g] = append(ix.grams[g], i)
+				}
+			}
+		}
+	}
+	return ix
+}
+
+// grams returns the n-grams of w up to maxGram runes long
+func grams(w string) []string {
+	r := []rune(w)
+	var gs []string
+	for n := 1; n <= maxGram && n <= len(r); n++ {
+		for i := 0; i+n <= len(r); i++ {
+			gs = append(gs, string(r[i:i+n]))
+		}
+	}
+	return gs
+}
+
+// intersect returns the elements of the sorted a also in the sorted b
+func intersect(a, b []int) []int {
+	var r []int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			r = append(r, a[i])
+			i++
+			j++
+		}
+	}
+	return r
+}
+
+// lookup returns the dishes which can match q in menu order: the ones named
+// q and the ones having all its words, see score
+func (ix *Index) lookup(q *query) []int {
+	var found []int
+	if len(q.words) > 0 {
+		for i, w := range q.words {
+			r := []rune(w)
+			n := min(len(r), maxGram)
+			// the dishes having all the longest n-grams of the word
+			var having []int
+			for j := 0; j+n <= len(r); j++ {
+				posting := ix.grams[string(r[j:j+n])]
+				if j == 0 {
+					having = posting
+				} else {
+					having = intersect(having, posting)
+				}
+			}
+			if i == 0 {
+				found = having
+			} else {
+				found = intersect(found, having)
+			}
+			if len(found) == 0 {
+				break
+			}
+		}
+	}
+	if exact := ix.exact[q.norm]; len(exact) > 0 {
+		found = append(append([]int(nil), found...), exact...)
+		sort.Ints(found)
+	}
+	return found
+}
+
+// rank returns the dishes matching q with the learned choices, best first
+func (ix *Index) rank(q *query, learned map[string]int) []Candidate {
+	var candidates []Candidate
+	last := -1
+	for _, i := range ix.lookup(q) {
+		if i == last {
+			continue
+		}
+		last = i
+		s := q.score(ix.names[i])
+		if s == 0 {
+			continue
+		}
+		r := ix.rows[i]
+		c := Candidate{Row: r, Score: s}
+		if n := learned[r.Content]; n > 0 && s < exactScore {
+			c.Score += learnedScore * n
+			c.Learned = true
+		}
+		candidates = append(candidates, c)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates
+}
+
+// maxIndexes is the number of menus whose index is kept, for the offices
+// with different menus and the menus of the next days
+const maxIndexes = 16
+
+// indexes keeps the indexes of the menus in use, so that they're compiled
+// once when the menu is published or changes, not for each message
+var indexes = struct {
+	sync.Mutex
+	byKey map[uint64]*Index
+	keys  []uint64 // oldest first
+}{byKey: make(map[uint64]*Index)}
+
+// fingerprint returns a hash of the dishes of menu, changing with them
+func fingerprint(menu tuttobene.Menu) uint64 {
+	h := fnv.New64a()
+	for _, r := range menu.Rows {
+		io.WriteString(h, r.Content)
+		io.WriteString(h, "\x00"+strconv.Itoa(int(r.Type))+strconv.FormatBool(r.IsDailyProposal)+r.Price.String()+"\x00")
+	}
+	return h.Sum64()
+}
+
+// IndexOf returns the index of menu, compiling it if it's new or changed
+func IndexOf(menu tuttobene.Menu) *Index {
+	key := fingerprint(menu)
+	indexes.Lock()
+	defer indexes.Unlock()
+	if ix, ok := indexes.byKey[key]; ok {
+		return ix
+	}
+	ix := Compile(menu)
+	indexes.byKey[key] = ix
+	indexes.keys = append(indexes.keys, key)
+	if len(indexes.keys) > maxIndexes {
+		delete(indexes.byKey, indexes.keys[0])
+		indexes.keys = indexes.keys[1:]
+	}
+	return ix
+}
@@ -0,0 +1,72 @@
+package matcher
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// scan returns the candidates scoring every dish of menu, like the index
+// does only for the ones it finds
+func scan(menu tuttobene.Menu, text string) []Candidate {
+	q := newQuery(text)
+	var candidates []Candidate
+	for _, r := range menu.Rows {
+		if s := q.score(strings.ToLower(r.Content)); s > 0 {
+			candidates = append(candidates, Candidate{Row: r, Score: s})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates
+}
+
+func TestIndex(t *testing.T) {
+	full, err := tuttobene.ParseMenuFile(filepath.Join("..", "tuttobene", "test-fixtures", "testmenuv3.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := New()
+	for _, menu := range []tuttobene.Menu{menu, *full} {
+		queries := []string{"", "!!", "a", "po", "pol", "pasta al", "al pomodoro pasta", "diametro 12", "Pasta olio", "xyz"}
+		for _, r := range menu.Rows {
+			queries = append(queries, r.Content, strings.ToUpper(r.Content))
+			for _, w := range words(r.Content) {
+				rw := []rune(w)
+				queries = append(queries, w, string(rw[:len(rw)/2]), string(rw[len(rw)/2:]))
+			}
+		}
+		for _, q := range queries {
+			if got, want := m.Rank(menu, q), scan(menu, q); !reflect.DeepEqual(got, want) {
+				t.Fatalf("%q: wanted %v, got %v", q, names(want), names(got))
+			}
+		}
+	}
+}
+
+func TestIndexOf(t *testing.T) {
+	ix := IndexOf(menu)
+	if IndexOf(menu) != ix {
+		t.Fatal("the index of the same menu is compiled again")
+	}
+
+	changed := tuttobene.Menu{Rows: append([]tuttobene.MenuRow{{Content: "Lasagne", Type: tuttobene.Primo}}, menu.Rows...)}
+	if IndexOf(changed) == ix {
+		t.Fatal("the index of a changed menu is not compiled again")
+	}
+	if got := names(New().Match(changed, "lasagne")); len(got) != 1 || got[0] != "Lasagne" {
+		t.Fatalf("wanted the new dish, got %v", got)
+	}
+
+	for i := 0; i < maxIndexes; i++ {
+		IndexOf(tuttobene.Menu{Rows: []tuttobene.MenuRow{{Content: strings.Repeat("x", i+1)}}})
+	}
+	if len(indexes.byKey) > maxIndexes {
+		t.Fatalf("too many indexes kept: %d", len(indexes.byKey))
+	}
+}
@@ -0,0 +1,171 @@
+// Package matcher correlates the free text written by the users with the
+// dishes of the menu, ranking the candidates and learning from the choices
+// confirmed in the past.
+package matcher
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+const (
+	exactScore   = 1000 // the query is the name of the dish
+	orderedScore = 50   // the query words appear in order in the dish name
+	wordsScore   = 30   // the query words appear in the dish name in any order
+	coverScore   = 40   // split among the dish words matched by the query
+	learnedScore = 100  // for each past confirmation of the dish for the query
+
+	// Margin is the score difference under which two candidates are
+	// considered equally good
+	Margin = 10
+)
+
+// Store is where the learned choices are kept
+type Store interface {
+	Set(string, interface{}) error
+	Get(string, interface{}) error
+}
+
+// Candidate is a dish matching a query
+type Candidate struct {
+	Row     tuttobene.MenuRow
+	Score   int
+	Learned bool // the dish was chosen thanks to the past confirmations
+}
+
+// Ambiguous is returned when more dishes match a query equally well
+type Ambiguous struct {
+	Query  string
+	Dishes []string
+}
+
+func (a *Ambiguous) Error() string {
+	quoted := make([]string, len(a.Dishes))
+	for i, d := range a.Dishes {
+		quoted[i] = "'" + d + "'"
+	}
+	last := len(quoted) - 1
+	return fmt.Sprintf("Cercando per '%s' intendevi %s o %s?", a.Query, strings.Join(quoted[:last], ", "), quoted[last])
+}
+
+// Matcher ranks the dishes of a menu for a query
+type Matcher struct {
+	Learned map[string]map[string]int // confirmations of each dish for each normalized query
+}
+
+// New returns a matcher with nothing learned
+func New() *Matcher {
+	return &Matcher{Learned: make(map[string]map[string]int)}
+}
+
+// Load loads the learned choices from store, nothing is learned if missing
+func (m *Matcher) Load(store Store) error {
+	if err := store.Get("matcher", m); err != nil {
+		*m = *New()
+		return err
+	}
+	if m.Learned == nil {
+		m.Learned = make(map[string]map[string]int)
+	}
+	return nil
+}
+
+// Save saves the learned choices to store
+func (m *Matcher) Save(store Store) error {
+	return store.Set("matcher", *m)
+}
+
+// Normalize returns the query lowercase, with single spaces between words
+func Normalize(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// Learn records that the user meant dish when writing query
+func (m *Matcher) Learn(query, dish string) {
+	q := Normalize(query)
+	if m.Learned[q] == nil {
+		m.Learned[q] = make(map[string]int)
+	}
+	m.Learned[q][dish]++
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// query is a query prepared to be scored against the dish names
+type query struct {
+	norm    string
+	words   []string
+	ordered *regexp.Regexp // matches the names with the words of the query in order
+}
+
+// newQuery prepares text to be scored
+func newQuery(text string) *query {
+	q := Normalize(text)
+	return &query{
+		norm:    q,
+		words:   words(q),
+		ordered: regexp.MustCompile(strings.Replace(regexp.QuoteMeta(q), " ", ".*", -1)),
+	}
+}
+
+// score returns how well q matches the lowercase dish name n, zero if it
+// doesn't
+func (q *query) score(n string) int {
+	if q.norm == Normalize(n) {
+		return exactScore
+	}
+
+	if len(q.words) == 0 {
+		return 0
+	}
+	s := wordsScore
+	if q.ordered.MatchString(n) {
+		s = orderedScore
+	} else {
+		for _, w := range q.words {
+			if !strings.Contains(n, w) {
+				return 0
+			}
+		}
+	}
+
+	nw := words(n)
+	matched := 0
+	for _, w := range nw {
+		for _, x := range q.words {
+			if strings.Contains(w, x) {
+				matched++
+				break
+			}
+		}
+	}
+	if len(nw) > 0 {
+		s += coverScore * matched / len(nw)
+	}
+	return s
+}
+
+// Rank returns the dishes of menu matching query, best first, see IndexOf
+func (m *Matcher) Rank(menu tuttobene.Menu, query string) []Candidate {
+	return IndexOf(menu).rank(newQuery(query), m.Learned[Normalize(query)])
+}
+
+// Match returns the dish meant by query: nil if nothing matches, more than
+// one candidate if the best ones are too close to choose
+func (m *Matcher) Match(menu tuttobene.Menu, query string) []Candidate {
+	candidates := m.Rank(menu, query)
+	for i, c := range candidates {
+		if i > 0 && candidates[0].Score-c.Score >= Margin {
+			return candidates[:i]
+		}
+	}
+	return candidates
+}
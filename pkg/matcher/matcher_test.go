@@ -0,0 +1,75 @@
+package matcher
+
+import (
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+var menu = tuttobene.Menu{Rows: []tuttobene.MenuRow{
+	{Content: "Penne all'arrabbiata", Type: tuttobene.Primo},
+	{Content: "Penne al pomodoro", Type: tuttobene.Primo},
+	{Content: "Pollo arrosto", Type: tuttobene.Secondo},
+	{Content: "Insalata di pollo", Type: tuttobene.Secondo},
+	{Content: "Pollo alla cacciatora con patate e olive", Type: tuttobene.Secondo},
+	{Content: "Patate", Type: tuttobene.Contorno},
+}}
+
+func names(candidates []Candidate) []string {
+	var r []string
+	for _, c := range candidates {
+		r = append(r, c.Row.Content)
+	}
+	return r
+}
+
+func TestMatch(t *testing.T) {
+	m := New()
+
+	tests := map[string][]string{
+		"patate":            {"Patate"},
+		"arrabbiata":        {"Penne all'arrabbiata"},
+		"arrabbiata penne":  {"Penne all'arrabbiata"},
+		"penne":             {"Penne all'arrabbiata", "Penne al pomodoro"},
+		"pollo":             {"Pollo arrosto", "Insalata di pollo"},
+		"pollo cacciatora":  {"Pollo alla cacciatora con patate e olive"},
+		"lasagne":           nil,
+		"  PENNE  pomodoro": {"Penne al pomodoro"},
+	}
+	for q, want := range tests {
+		got := names(m.Match(menu, q))
+		if len(got) != len(want) {
+			t.Fatalf("%s: wanted %v, got %v", q, want, got)
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Fatalf("%s: wanted %v, got %v", q, want, got)
+			}
+		}
+	}
+}
+
+func TestLearn(t *testing.T) {
+	m := New()
+	m.Learn("Pollo", "Insalata di pollo")
+
+	got := m.Match(menu, "pollo ")
+	if len(got) != 1 || got[0].Row.Content != "Insalata di pollo" || !got[0].Learned {
+		t.Fatalf("wanted the learned dish, got %v", names(got))
+	}
+
+	m.Learn("pollo", "Pollo arrosto")
+	m.Learn("pollo", "Pollo arrosto")
+	got = m.Match(menu, "pollo")
+	if len(got) != 1 || got[0].Row.Content != "Pollo arrosto" {
+		t.Fatalf("wanted the most confirmed dish, got %v", names(got))
+	}
+}
+
+func TestAmbiguous(t *testing.T) {
+	err := &Ambiguous{"pollo", []string{"Pollo arrosto", "Insalata di pollo", "Pollo fritto"}}
+	want := "Cercando per 'pollo' intendevi 'Pollo arrosto', 'Insalata di pollo' o 'Pollo fritto'?"
+	if err.Error() != want {
+		t.Fatalf("wanted %s, got %s", want, err.Error())
+	}
+}
@@ -0,0 +1,236 @@
+// Package openapi generates the OpenAPI 3 document of an HTTP API from the
+// description of its operations and the Go types of their bodies
+package openapi
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version is the version of the OpenAPI specification of the documents
+const Version = "3.0.3"
+
+// Param is a parameter of an operation, In is "path", "query" or "header"
+type Param struct {
+	Name        string
+	In          string
+	Description string
+	Required    bool
+}
+
+// Operation describes an operation of the API. Request and Response are
+// values of the types of the JSON bodies, nil if there's none, and
+// ContentType the type of the response if it's not JSON.
+type Operation struct {
+	Method      string
+	Path        string
+	ID          string
+	Summary     string
+	Params      []Param
+	Request     interface{}
+	Response    interface{}
+	ContentType string
+	// Errors are the status codes of the errors, with their description
+	Errors map[int]string
+}
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]*Endpoint `json:"paths"`
+	Components Components                      `json:"components"`
+	Security   []map[string][]string           `json:"security,omitempty"`
+}
+
+// Info is the title and the version of the API
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// Components are the schemas of the named types and the security schemes
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme is a way to authenticate the requests
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	Name   string `json:"name,omitempty"`
+	In     string `json:"in,omitempty"`
+}
+
+// Endpoint is an operation as described in the document
+type Endpoint struct {
+	OperationID string              `json:"operationId,omitempty"`
+	Summary     string              `json:"summary,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *Body               `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+// Parameter is a parameter of an endpoint
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// Body is the body of a request
+type Body struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is a response of an endpoint
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a body of a given content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is the JSON schema of a type, Ref points to the named ones
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// New returns the document of the operations, with errorBody a value of the
+// type of the body of the errors. The operations require any one of the
+// security schemes.
+func New(title, version string, ops []Operation, errorBody interface{}, security map[string]SecurityScheme) *Document {
+	d := &Document{
+		OpenAPI:    Version,
+		Info:       Info{title, version},
+		Paths:      make(map[string]map[string]*Endpoint),
+		Components: Components{Schemas: make(map[string]*Schema), SecuritySchemes: security},
+	}
+	var names []string
+	for name := range security {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		d.Security = append(d.Security, map[string][]string{name: {}})
+	}
+
+	for _, op := range ops {
+		e := &Endpoint{OperationID: op.ID, Summary: op.Summary, Responses: make(map[string]Response)}
+		for _, p := range op.Params {
+			e.Parameters = append(e.Parameters, Parameter{p.Name, p.In, p.Description, p.Required || p.In == "path", &Schema{Type: "string"}})
+		}
+		if op.Request != nil {
+			e.RequestBody = &Body{true, map[string]MediaType{"application/json": {d.schema(reflect.TypeOf(op.Request))}}}
+		}
+
+		ok := Response{Description: "OK"}
+		if op.Response != nil {
+			ct := op.ContentType
+			if ct == "" {
+				ct = "application/json"
+			}
+			ok.Content = map[string]MediaType{ct: {d.schema(reflect.TypeOf(op.Response))}}
+		}
+		e.Responses["200"] = ok
+		for code, desc := range op.Errors {
+			r := Response{Description: desc}
+			if errorBody != nil {
+				r.Content = map[string]MediaType{"application/json": {d.schema(reflect.TypeOf(errorBody))}}
+			}
+			e.Responses[strconv.Itoa(code)] = r
+		}
+
+		if d.Paths[op.Path] == nil {
+			d.Paths[op.Path] = make(map[string]*Endpoint)
+		}
+		d.Paths[op.Path][strings.ToLower(op.Method)] = e
+	}
+	return d
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema returns the schema of t, adding the named structs to the
+// components and pointing to them
+func (d *Document) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: d.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.object(t)
+		}
+		if _, ok := d.Components.Schemas[t.Name()]; !ok {
+			// set before the fields, for the recursive types
+			d.Components.Schemas[t.Name()] = &Schema{}
+			*d.Components.Schemas[t.Name()] = *d.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + t.Name()}
+	}
+	// any value
+	return &Schema{}
+}
+
+// object returns the schema of the struct t, with the fields as encoded
+// by encoding/json
+func (d *Document) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := strings.Split(f.Tag.Get("json"), ",")
+		if tag[0] == "-" {
+			continue
+		}
+		name := f.Name
+		if tag[0] != "" {
+			name = tag[0]
+		}
+		s.Properties[name] = d.schema(f.Type)
+		omitempty := false
+		for _, o := range tag[1:] {
+			omitempty = omitempty || o == "omitempty"
+		}
+		if !omitempty {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
@@ -0,0 +1,61 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type item struct {
+	Name  string    `json:"name"`
+	Count int       `json:"count,omitempty"`
+	When  time.Time `json:"when"`
+	Tags  []string  `json:"tags"`
+	Next  *item     `json:"next,omitempty"`
+	skip  bool
+	Skip  bool `json:"-"`
+}
+
+type problem struct {
+	Error string `json:"error"`
+}
+
+func TestNew(t *testing.T) {
+	d := New("Test", "1", []Operation{
+		{Method: "GET", Path: "/items/{id}", ID: "getItem", Params: []Param{{Name: "id", In: "path"}}, Response: item{}},
+		{Method: "POST", Path: "/items", Request: []item{}, Errors: map[int]string{400: "Invalid"}},
+		{Method: "GET", Path: "/events", Response: "", ContentType: "text/event-stream"},
+	}, problem{}, map[string]SecurityScheme{"bearer": {Type: "http", Scheme: "bearer"}})
+
+	get := d.Paths["/items/{id}"]["get"]
+	if get == nil || get.OperationID != "getItem" || !get.Parameters[0].Required {
+		t.Fatalf("unexpected get %+v", get)
+	}
+	if ref := get.Responses["200"].Content["application/json"].Schema.Ref; ref != "#/components/schemas/item" {
+		t.Errorf("unexpected response schema %q", ref)
+	}
+	post := d.Paths["/items"]["post"]
+	if s := post.RequestBody.Content["application/json"].Schema; s.Type != "array" || s.Items.Ref != "#/components/schemas/item" {
+		t.Errorf("unexpected request schema %+v", s)
+	}
+	if s := post.Responses["400"].Content["application/json"].Schema; s.Ref != "#/components/schemas/problem" {
+		t.Errorf("unexpected error schema %+v", s)
+	}
+	if s := d.Paths["/events"]["get"].Responses["200"].Content["text/event-stream"].Schema; s.Type != "string" {
+		t.Errorf("unexpected stream schema %+v", s)
+	}
+
+	s := d.Components.Schemas["item"]
+	if len(s.Properties) != 5 || s.Properties["when"].Format != "date-time" || s.Properties["next"].Ref != "#/components/schemas/item" {
+		t.Errorf("unexpected item schema %+v", s)
+	}
+	if len(s.Required) != 3 || s.Required[0] != "name" || s.Required[2] != "tags" {
+		t.Errorf("unexpected required %v", s.Required)
+	}
+	if len(d.Security) != 1 || d.Security[0]["bearer"] == nil {
+		t.Errorf("unexpected security %v", d.Security)
+	}
+	if _, err := json.Marshal(d); err != nil {
+		t.Fatal(err)
+	}
+}
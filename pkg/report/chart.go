@@ -0,0 +1,182 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strings"
+	"unicode/utf8"
+)
+
+// the sizes of the chart in pixels
+const (
+	chartWidth  = 800
+	chartMargin = 16
+	barHeight   = 20
+	barSpacing  = 8
+	// maxLabel is the longest label drawn, in characters
+	maxLabel = 24
+)
+
+var (
+	chartBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	chartText       = color.RGBA{0x33, 0x33, 0x33, 0xff}
+	chartBar        = color.RGBA{0x1d, 0x9b, 0xd1, 0xff}
+)
+
+// RenderChart renders as a PNG horizontal bar chart the values of the first
+// table of the report which has some, labeled by the first cell of the rows
+// and by the last one at the end of the bars
+func RenderChart(r Report) (Output, error) {
+	var table *Table
+	for i, t := range r.Tables {
+		for _, row := range t.Rows {
+			if row.Value > 0 {
+				table = &r.Tables[i]
+				break
+			}
+		}
+		if table != nil {
+			break
+		}
+	}
+	if table == nil {
+		return Output{}, errors.New("nessun dato da mostrare in un grafico")
+	}
+
+	max := 0.0
+	for _, row := range table.Rows {
+		if row.Value > max {
+			max = row.Value
+		}
+	}
+	label := 0
+	for _, row := range table.Rows {
+		if n := utf8.RuneCountInString(row.Cells[0]); n > label {
+			label = n
+		}
+	}
+	if label > maxLabel {
+		label = maxLabel
+	}
+
+	top := chartMargin + 2*lineHeight
+	height := top + len(table.Rows)*(barHeight+barSpacing) + chartMargin
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{chartBackground}, image.Point{}, draw.Src)
+	drawText(img, chartMargin, chartMargin, r.Title+" - "+table.Title, chartText)
+
+	left := chartMargin + (label+1)*charWidth
+	// the room for the value at the end of the longest bar
+	room := chartWidth - left - chartMargin - 12*charWidth
+	for i, row := range table.Rows {
+		y := top + i*(barHeight+barSpacing)
+		textY := y + (barHeight-glyphHeight*scale)/2
+		drawText(img, chartMargin, textY, truncate(row.Cells[0], label), chartText)
+		w := int(row.Value / max * float64(room))
+		draw.Draw(img, image.Rect(left, y, left+w, y+barHeight), &image.Uniform{chartBar}, image.Point{}, draw.Src)
+		if len(row.Cells) > 1 {
+			drawText(img, left+w+charWidth, textY, row.Cells[len(row.Cells)-1], chartText)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return Output{}, err
+	}
+	return Output{File: buf.Bytes(), Filename: r.Name + ".png", Filetype: "png"}, nil
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "."
+}
+
+// the text is drawn with a 3x5 pixel font, scaled
+const (
+	glyphWidth  = 3
+	glyphHeight = 5
+	scale       = 2
+	charWidth   = (glyphWidth + 1) * scale
+	lineHeight  = (glyphHeight + 3) * scale
+)
+
+// glyphs are the rows of the characters of the font, from the top, the
+// missing ones are drawn as spaces
+var glyphs = map[rune][glyphHeight]string{
+	'0':  {"###", "#.#", "#.#", "#.#", "###"},
+	'1':  {".#.", "##.", ".#.", ".#.", "###"},
+	'2':  {"###", "..#", "###", "#..", "###"},
+	'3':  {"###", "..#", "###", "..#", "###"},
+	'4':  {"#.#", "#.#", "###", "..#", "..#"},
+	'5':  {"###", "#..", "###", "..#", "###"},
+	'6':  {"###", "#..", "###", "#.#", "###"},
+	'7':  {"###", "..#", "..#", ".#.", ".#."},
+	'8':  {"###", "#.#", "###", "#.#", "###"},
+	'9':  {"###", "#.#", "###", "..#", "###"},
+	'A':  {".#.", "#.#", "###", "#.#", "#.#"},
+	'B':  {"##.", "#.#", "##.", "#.#", "##."},
+	'C':  {".##", "#..", "#..", "#..", ".##"},
+	'D':  {"##.", "#.#", "#.#", "#.#", "##."},
+	'E':  {"###", "#..", "##.", "#..", "###"},
+	'F':  {"###", "#..", "##.", "#..", "#.."},
+	'G':  {".##", "#..", "#.#", "#.#", ".##"},
+	'H':  {"#.#", "#.#", "###", "#.#", "#.#"},
+	'I':  {"###", ".#.", ".#.", ".#.", "###"},
+	'J':  {"..#", "..#", "..#", "#.#", ".#."},
+	'K':  {"#.#", "#.#", "##.", "#.#", "#.#"},
+	'L':  {"#..", "#..", "#..", "#..", "###"},
+	'M':  {"#.#", "###", "###", "#.#", "#.#"},
+	'N':  {"##.", "#.#", "#.#", "#.#", "#.#"},
+	'O':  {".#.", "#.#", "#.#", "#.#", ".#."},
+	'P':  {"##.", "#.#", "##.", "#..", "#.."},
+	'Q':  {".#.", "#.#", "#.#", "##.", ".##"},
+	'R':  {"##.", "#.#", "##.", "#.#", "#.#"},
+	'S':  {".##", "#..", ".#.", "..#", "##."},
+	'T':  {"###", ".#.", ".#.", ".#.", ".#."},
+	'U':  {"#.#", "#.#", "#.#", "#.#", "###"},
+	'V':  {"#.#", "#.#", "#.#", "#.#", ".#."},
+	'W':  {"#.#", "#.#", "###", "###", "#.#"},
+	'X':  {"#.#", "#.#", ".#.", "#.#", "#.#"},
+	'Y':  {"#.#", "#.#", ".#.", ".#.", ".#."},
+	'Z':  {"###", "..#", ".#.", "#..", "###"},
+	'.':  {"...", "...", "...", "...", ".#."},
+	',':  {"...", "...", "...", ".#.", "#.."},
+	':':  {"...", ".#.", "...", ".#.", "..."},
+	'-':  {"...", "...", "###", "...", "..."},
+	'+':  {"...", ".#.", "###", ".#.", "..."},
+	'/':  {"..#", "..#", ".#.", "#..", "#.."},
+	'%':  {"#.#", "..#", ".#.", "#..", "#.#"},
+	'(':  {".#.", "#..", "#..", "#..", ".#."},
+	')':  {".#.", "..#", "..#", "..#", ".#."},
+	'\'': {".#.", ".#.", "...", "...", "..."},
+	'€':  {".##", "##.", "#..", "##.", ".##"},
+}
+
+// unaccented maps the accented letters to the ones of the font, which has
+// only the uppercase
+var unaccented = strings.NewReplacer("à", "A", "è", "E", "é", "E", "ì", "I", "ò", "O", "ù", "U")
+
+// drawText draws s with its top left corner at x, y
+func drawText(img draw.Image, x, y int, s string, c color.Color) {
+	for _, r := range strings.ToUpper(unaccented.Replace(s)) {
+		for row, bits := range glyphs[r] {
+			for col, bit := range bits {
+				if bit != '#' {
+					continue
+				}
+				for dy := 0; dy < scale; dy++ {
+					for dx := 0; dx < scale; dx++ {
+						img.Set(x+col*scale+dx, y+row*scale+dy, c)
+					}
+				}
+			}
+		}
+		x += charWidth
+	}
+}
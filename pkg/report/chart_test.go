@@ -0,0 +1,31 @@
+package report
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+)
+
+func TestRenderChart(t *testing.T) {
+	out, err := Render(sample, Chart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(out.File))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != chartWidth || out.Filename != "spesa-2019-03.png" {
+		t.Errorf("wrong chart %s: %v", out.Filename, img.Bounds())
+	}
+	// the longest bar starts after the labels
+	left := chartMargin + (len("luigi | verdi")+1)*charWidth
+	top := chartMargin + 2*lineHeight
+	if c := img.At(left+1, top+1); c != chartBar {
+		t.Errorf("no bar: %v", c)
+	}
+
+	if _, err := Render(Report{Tables: []Table{{Rows: []Row{{Cells: []string{"a"}}}}}}, Chart); err == nil {
+		t.Error("chart without values")
+	}
+}
@@ -0,0 +1,169 @@
+// Package report renders the reports of the lunches in the formats the bot
+// can post: Slack blocks, Markdown, CSV and PNG charts.
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/chat"
+)
+
+// Report is a report as a title, a summary and some tables, the same for all
+// the formats
+type Report struct {
+	// Name is the base of the name of the files, e.g. "spesa-2019-03"
+	Name    string
+	Title   string
+	Summary []string
+	Tables  []Table
+}
+
+// Table is a section of a report
+type Table struct {
+	Title   string
+	Columns []string
+	Rows    []Row
+}
+
+// Row is a row of a table, Value is the quantity it shows in a chart
+type Row struct {
+	Cells []string
+	Value float64
+}
+
+// Format is a way to render a report
+type Format string
+
+// The formats of the reports, as written in the commands
+const (
+	Blocks   Format = "blocchi"
+	Markdown Format = "markdown"
+	CSV      Format = "csv"
+	Chart    Format = "grafico"
+)
+
+// Formats are the formats of the reports, in the order of the help
+var Formats = []Format{Blocks, Markdown, CSV, Chart}
+
+// Output is a rendered report, either Text and Blocks to post or a File to
+// upload
+type Output struct {
+	Text   string
+	Blocks []chat.Block
+
+	File     []byte
+	Filename string
+	Filetype string
+}
+
+// Renderer renders a report in a format
+type Renderer func(Report) (Output, error)
+
+var renderers = map[Format]Renderer{
+	Blocks:   RenderBlocks,
+	Markdown: RenderMarkdown,
+	CSV:      RenderCSV,
+	Chart:    RenderChart,
+}
+
+// ParseFormat returns the format named by s, with or without the leading
+// "--" of a flag
+func ParseFormat(s string) (Format, bool) {
+	f := Format(strings.ToLower(strings.TrimPrefix(s, "--")))
+	_, ok := renderers[f]
+	return f, ok
+}
+
+// Render renders the report in the format
+func Render(r Report, f Format) (Output, error) {
+	render, ok := renderers[f]
+	if !ok {
+		return Output{}, fmt.Errorf("formato %s sconosciuto", f)
+	}
+	return render(r)
+}
+
+// RenderBlocks renders the report as the blocks of a Slack message, with the
+// title as the text of the notifications
+func RenderBlocks(r Report) (Output, error) {
+	text := "*" + r.Title + "*"
+	if len(r.Summary) > 0 {
+		text += "\n" + strings.Join(r.Summary, "\n")
+	}
+	blocks := []chat.Block{
+		chat.Section{Text: text},
+	}
+	for _, t := range r.Tables {
+		lines := []string{"*" + t.Title + "*"}
+		for _, row := range t.Rows {
+			line := row.Cells[0]
+			if len(row.Cells) > 1 {
+				line += ": " + strings.Join(row.Cells[1:], ", ")
+			}
+			lines = append(lines, line)
+		}
+		blocks = append(blocks,
+			chat.Divider{},
+			chat.Section{Text: strings.Join(lines, "\n")})
+	}
+	return Output{Text: r.Title, Blocks: blocks}, nil
+}
+
+// RenderMarkdown renders the report as a Markdown document, the tables as
+// Markdown tables
+func RenderMarkdown(r Report) (Output, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", r.Title)
+	if len(r.Summary) > 0 {
+		fmt.Fprintf(&b, "\n%s\n", strings.Join(r.Summary, "  \n"))
+	}
+	cell := strings.NewReplacer("|", "\\|")
+	row := func(cells []string) {
+		escaped := make([]string, len(cells))
+		for i, c := range cells {
+			escaped[i] = cell.Replace(c)
+		}
+		fmt.Fprintf(&b, "| %s |\n", strings.Join(escaped, " | "))
+	}
+	for _, t := range r.Tables {
+		fmt.Fprintf(&b, "\n## %s\n\n", t.Title)
+		row(t.Columns)
+		sep := make([]string, len(t.Columns))
+		for i := range sep {
+			sep[i] = "---"
+		}
+		row(sep)
+		for _, tr := range t.Rows {
+			row(tr.Cells)
+		}
+	}
+	return Output{File: []byte(b.String()), Filename: r.Name + ".md", Filetype: "markdown"}, nil
+}
+
+// RenderCSV renders the report as a CSV file: the title and the summary,
+// then each table with its title and its columns, separated by empty rows
+func RenderCSV(r Report) (Output, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	// the rows have different lengths
+	w.Write([]string{r.Title})
+	for _, s := range r.Summary {
+		w.Write([]string{s})
+	}
+	for _, t := range r.Tables {
+		w.Write([]string{})
+		w.Write([]string{t.Title})
+		w.Write(t.Columns)
+		for _, row := range t.Rows {
+			w.Write(row.Cells)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return Output{}, err
+	}
+	return Output{File: buf.Bytes(), Filename: r.Name + ".csv", Filetype: "csv"}, nil
+}
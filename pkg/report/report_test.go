@@ -0,0 +1,75 @@
+package report
+
+import (
+	"strings"
+	"testing"
+)
+
+var sample = Report{
+	Name:    "spesa-2019-03",
+	Title:   "Spesa di 03/2019",
+	Summary: []string{"Totale €17.00, 2 ordini"},
+	Tables: []Table{{
+		Title:   "Per persona",
+		Columns: []string{"Nome", "Totale"},
+		Rows: []Row{
+			{Cells: []string{"mario", "€12.00"}, Value: 12},
+			{Cells: []string{"luigi | verdi", "€5.00"}, Value: 5},
+		},
+	}},
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, s := range []string{"csv", "--csv", "--CSV"} {
+		if f, ok := ParseFormat(s); !ok || f != CSV {
+			t.Errorf("%s: got %q, %v", s, f, ok)
+		}
+	}
+	if _, ok := ParseFormat("--pdf"); ok {
+		t.Error("unknown format parsed")
+	}
+	if _, err := Render(sample, Format("pdf")); err == nil {
+		t.Error("unknown format rendered")
+	}
+}
+
+func TestRenderBlocks(t *testing.T) {
+	out, err := Render(sample, Blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Text != "Spesa di 03/2019" || len(out.Blocks) != 3 || out.File != nil {
+		t.Errorf("wrong output: %+v", out)
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	out, err := Render(sample, Markdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# Spesa di 03/2019\n\nTotale €17.00, 2 ordini\n\n## Per persona\n\n" +
+		"| Nome | Totale |\n| --- | --- |\n| mario | €12.00 |\n| luigi \\| verdi | €5.00 |\n"
+	if string(out.File) != want || out.Filename != "spesa-2019-03.md" {
+		t.Errorf("wrong markdown %s:\n%s", out.Filename, out.File)
+	}
+}
+
+func TestRenderCSV(t *testing.T) {
+	out, err := Render(sample, CSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"Spesa di 03/2019",
+		"\"Totale €17.00, 2 ordini\"",
+		"",
+		"Per persona",
+		"Nome,Totale",
+		"mario,€12.00",
+		"luigi | verdi,€5.00",
+	}, "\n") + "\n"
+	if string(out.File) != want || out.Filetype != "csv" {
+		t.Errorf("wrong csv:\n%s", out.File)
+	}
+}
@@ -0,0 +1,787 @@
+// The lunch domain for the internal integrations preferring typed RPC to
+// the REST API: the menu of the day, the order and its updates. The messages
+// mirror the JSON of the REST API, see pkg/tinabot/api.go, and the service is
+// implemented by pkg/rpc.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: lunches/v1/lunches.proto
+
+package lunchespb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// MenuRow is a dish of the menu, row is its index to order it
+type MenuRow struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Row     int32                  `protobuf:"varint,1,opt,name=row,proto3" json:"row,omitempty"`
+	Type    string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Content string                 `protobuf:"bytes,3,opt,name=content,proto3" json:"content,omitempty"`
+	// the price in euro, as a decimal string, empty if unknown
+	Price         string `protobuf:"bytes,4,opt,name=price,proto3" json:"price,omitempty"`
+	DailyProposal bool   `protobuf:"varint,5,opt,name=daily_proposal,json=dailyProposal,proto3" json:"daily_proposal,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MenuRow) Reset() {
+	*x = MenuRow{}
+	mi := &file_lunches_v1_lunches_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MenuRow) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MenuRow) ProtoMessage() {}
+
+func (x *MenuRow) ProtoReflect() protoreflect.Message {
+	mi := &file_lunches_v1_lunches_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MenuRow.ProtoReflect.Descriptor instead.
+func (*MenuRow) Descriptor() ([]byte, []int) {
+	return file_lunches_v1_lunches_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *MenuRow) GetRow() int32 {
+	if x != nil {
+		return x.Row
+	}
+	return 0
+}
+
+func (x *MenuRow) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *MenuRow) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *MenuRow) GetPrice() string {
+	if x != nil {
+		return x.Price
+	}
+	return ""
+}
+
+func (x *MenuRow) GetDailyProposal() bool {
+	if x != nil {
+		return x.DailyProposal
+	}
+	return false
+}
+
+// Menu is the menu of a day, date is "2006-01-02"
+type Menu struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Date          string                 `protobuf:"bytes,1,opt,name=date,proto3" json:"date,omitempty"`
+	Rows          []*MenuRow             `protobuf:"bytes,2,rep,name=rows,proto3" json:"rows,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Menu) Reset() {
+	*x = Menu{}
+	mi := &file_lunches_v1_lunches_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Menu) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Menu) ProtoMessage() {}
+
+func (x *Menu) ProtoReflect() protoreflect.Message {
+	mi := &file_lunches_v1_lunches_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Menu.ProtoReflect.Descriptor instead.
+func (*Menu) Descriptor() ([]byte, []int) {
+	return file_lunches_v1_lunches_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Menu) GetDate() string {
+	if x != nil {
+		return x.Date
+	}
+	return ""
+}
+
+func (x *Menu) GetRows() []*MenuRow {
+	if x != nil {
+		return x.Rows
+	}
+	return nil
+}
+
+// UserChoice is a choice of a user: a dish, or a main course with its sides
+type UserChoice struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Dishes        []string               `protobuf:"bytes,1,rep,name=dishes,proto3" json:"dishes,omitempty"`
+	Note          string                 `protobuf:"bytes,2,opt,name=note,proto3" json:"note,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserChoice) Reset() {
+	*x = UserChoice{}
+	mi := &file_lunches_v1_lunches_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserChoice) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserChoice) ProtoMessage() {}
+
+func (x *UserChoice) ProtoReflect() protoreflect.Message {
+	mi := &file_lunches_v1_lunches_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserChoice.ProtoReflect.Descriptor instead.
+func (*UserChoice) Descriptor() ([]byte, []int) {
+	return file_lunches_v1_lunches_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *UserChoice) GetDishes() []string {
+	if x != nil {
+		return x.Dishes
+	}
+	return nil
+}
+
+func (x *UserChoice) GetNote() string {
+	if x != nil {
+		return x.Note
+	}
+	return ""
+}
+
+// UserOrder is the order of a user
+type UserOrder struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name  string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// who ordered for the user, if someone else
+	OrderedBy     string        `protobuf:"bytes,3,opt,name=ordered_by,json=orderedBy,proto3" json:"ordered_by,omitempty"`
+	Choices       []*UserChoice `protobuf:"bytes,4,rep,name=choices,proto3" json:"choices,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserOrder) Reset() {
+	*x = UserOrder{}
+	mi := &file_lunches_v1_lunches_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserOrder) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserOrder) ProtoMessage() {}
+
+func (x *UserOrder) ProtoReflect() protoreflect.Message {
+	mi := &file_lunches_v1_lunches_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserOrder.ProtoReflect.Descriptor instead.
+func (*UserOrder) Descriptor() ([]byte, []int) {
+	return file_lunches_v1_lunches_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *UserOrder) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *UserOrder) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *UserOrder) GetOrderedBy() string {
+	if x != nil {
+		return x.OrderedBy
+	}
+	return ""
+}
+
+func (x *UserOrder) GetChoices() []*UserChoice {
+	if x != nil {
+		return x.Choices
+	}
+	return nil
+}
+
+// DishCount is how many times a dish has been ordered
+type DishCount struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Count         int32                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DishCount) Reset() {
+	*x = DishCount{}
+	mi := &file_lunches_v1_lunches_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DishCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DishCount) ProtoMessage() {}
+
+func (x *DishCount) ProtoReflect() protoreflect.Message {
+	mi := &file_lunches_v1_lunches_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DishCount.ProtoReflect.Descriptor instead.
+func (*DishCount) Descriptor() ([]byte, []int) {
+	return file_lunches_v1_lunches_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *DishCount) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *DishCount) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+// Order is the order of a day, state is "aperto", "bloccato", "inviato" or
+// "consegnato"
+type Order struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Date          string                 `protobuf:"bytes,1,opt,name=date,proto3" json:"date,omitempty"`
+	State         string                 `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	Users         []*UserOrder           `protobuf:"bytes,3,rep,name=users,proto3" json:"users,omitempty"`
+	Dishes        []*DishCount           `protobuf:"bytes,4,rep,name=dishes,proto3" json:"dishes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Order) Reset() {
+	*x = Order{}
+	mi := &file_lunches_v1_lunches_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Order) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Order) ProtoMessage() {}
+
+func (x *Order) ProtoReflect() protoreflect.Message {
+	mi := &file_lunches_v1_lunches_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Order.ProtoReflect.Descriptor instead.
+func (*Order) Descriptor() ([]byte, []int) {
+	return file_lunches_v1_lunches_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Order) GetDate() string {
+	if x != nil {
+		return x.Date
+	}
+	return ""
+}
+
+func (x *Order) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *Order) GetUsers() []*UserOrder {
+	if x != nil {
+		return x.Users
+	}
+	return nil
+}
+
+func (x *Order) GetDishes() []*DishCount {
+	if x != nil {
+		return x.Dishes
+	}
+	return nil
+}
+
+type GetMenuRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Office        string                 `protobuf:"bytes,1,opt,name=office,proto3" json:"office,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMenuRequest) Reset() {
+	*x = GetMenuRequest{}
+	mi := &file_lunches_v1_lunches_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMenuRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMenuRequest) ProtoMessage() {}
+
+func (x *GetMenuRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lunches_v1_lunches_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMenuRequest.ProtoReflect.Descriptor instead.
+func (*GetMenuRequest) Descriptor() ([]byte, []int) {
+	return file_lunches_v1_lunches_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetMenuRequest) GetOffice() string {
+	if x != nil {
+		return x.Office
+	}
+	return ""
+}
+
+type PlaceOrderRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Office   string                 `protobuf:"bytes,1,opt,name=office,proto3" json:"office,omitempty"`
+	UserId   string                 `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	UserName string                 `protobuf:"bytes,3,opt,name=user_name,json=userName,proto3" json:"user_name,omitempty"`
+	// the rows of today's menu, one dish for each choice, none to clear the
+	// order of the user
+	Rows          []int32 `protobuf:"varint,4,rep,packed,name=rows,proto3" json:"rows,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlaceOrderRequest) Reset() {
+	*x = PlaceOrderRequest{}
+	mi := &file_lunches_v1_lunches_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlaceOrderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlaceOrderRequest) ProtoMessage() {}
+
+func (x *PlaceOrderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lunches_v1_lunches_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlaceOrderRequest.ProtoReflect.Descriptor instead.
+func (*PlaceOrderRequest) Descriptor() ([]byte, []int) {
+	return file_lunches_v1_lunches_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *PlaceOrderRequest) GetOffice() string {
+	if x != nil {
+		return x.Office
+	}
+	return ""
+}
+
+func (x *PlaceOrderRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *PlaceOrderRequest) GetUserName() string {
+	if x != nil {
+		return x.UserName
+	}
+	return ""
+}
+
+func (x *PlaceOrderRequest) GetRows() []int32 {
+	if x != nil {
+		return x.Rows
+	}
+	return nil
+}
+
+type GetSummaryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Office        string                 `protobuf:"bytes,1,opt,name=office,proto3" json:"office,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSummaryRequest) Reset() {
+	*x = GetSummaryRequest{}
+	mi := &file_lunches_v1_lunches_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSummaryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSummaryRequest) ProtoMessage() {}
+
+func (x *GetSummaryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lunches_v1_lunches_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSummaryRequest.ProtoReflect.Descriptor instead.
+func (*GetSummaryRequest) Descriptor() ([]byte, []int) {
+	return file_lunches_v1_lunches_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetSummaryRequest) GetOffice() string {
+	if x != nil {
+		return x.Office
+	}
+	return ""
+}
+
+type StreamOrderUpdatesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Office        string                 `protobuf:"bytes,1,opt,name=office,proto3" json:"office,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamOrderUpdatesRequest) Reset() {
+	*x = StreamOrderUpdatesRequest{}
+	mi := &file_lunches_v1_lunches_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamOrderUpdatesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamOrderUpdatesRequest) ProtoMessage() {}
+
+func (x *StreamOrderUpdatesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lunches_v1_lunches_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamOrderUpdatesRequest.ProtoReflect.Descriptor instead.
+func (*StreamOrderUpdatesRequest) Descriptor() ([]byte, []int) {
+	return file_lunches_v1_lunches_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *StreamOrderUpdatesRequest) GetOffice() string {
+	if x != nil {
+		return x.Office
+	}
+	return ""
+}
+
+type OrderUpdate struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Time          *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+	Order         *Order                 `protobuf:"bytes,2,opt,name=order,proto3" json:"order,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OrderUpdate) Reset() {
+	*x = OrderUpdate{}
+	mi := &file_lunches_v1_lunches_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OrderUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OrderUpdate) ProtoMessage() {}
+
+func (x *OrderUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_lunches_v1_lunches_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OrderUpdate.ProtoReflect.Descriptor instead.
+func (*OrderUpdate) Descriptor() ([]byte, []int) {
+	return file_lunches_v1_lunches_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *OrderUpdate) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *OrderUpdate) GetOrder() *Order {
+	if x != nil {
+		return x.Order
+	}
+	return nil
+}
+
+var File_lunches_v1_lunches_proto protoreflect.FileDescriptor
+
+const file_lunches_v1_lunches_proto_rawDesc = "" +
+	"\n" +
+	"\x18lunches/v1/lunches.proto\x12\n" +
+	"lunches.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x86\x01\n" +
+	"\aMenuRow\x12\x10\n" +
+	"\x03row\x18\x01 \x01(\x05R\x03row\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x18\n" +
+	"\acontent\x18\x03 \x01(\tR\acontent\x12\x14\n" +
+	"\x05price\x18\x04 \x01(\tR\x05price\x12%\n" +
+	"\x0edaily_proposal\x18\x05 \x01(\bR\rdailyProposal\"C\n" +
+	"\x04Menu\x12\x12\n" +
+	"\x04date\x18\x01 \x01(\tR\x04date\x12'\n" +
+	"\x04rows\x18\x02 \x03(\v2\x13.lunches.v1.MenuRowR\x04rows\"8\n" +
+	"\n" +
+	"UserChoice\x12\x16\n" +
+	"\x06dishes\x18\x01 \x03(\tR\x06dishes\x12\x12\n" +
+	"\x04note\x18\x02 \x01(\tR\x04note\"\x80\x01\n" +
+	"\tUserOrder\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1d\n" +
+	"\n" +
+	"ordered_by\x18\x03 \x01(\tR\torderedBy\x120\n" +
+	"\achoices\x18\x04 \x03(\v2\x16.lunches.v1.UserChoiceR\achoices\"5\n" +
+	"\tDishCount\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x05R\x05count\"\x8d\x01\n" +
+	"\x05Order\x12\x12\n" +
+	"\x04date\x18\x01 \x01(\tR\x04date\x12\x14\n" +
+	"\x05state\x18\x02 \x01(\tR\x05state\x12+\n" +
+	"\x05users\x18\x03 \x03(\v2\x15.lunches.v1.UserOrderR\x05users\x12-\n" +
+	"\x06dishes\x18\x04 \x03(\v2\x15.lunches.v1.DishCountR\x06dishes\"(\n" +
+	"\x0eGetMenuRequest\x12\x16\n" +
+	"\x06office\x18\x01 \x01(\tR\x06office\"u\n" +
+	"\x11PlaceOrderRequest\x12\x16\n" +
+	"\x06office\x18\x01 \x01(\tR\x06office\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\tR\x06userId\x12\x1b\n" +
+	"\tuser_name\x18\x03 \x01(\tR\buserName\x12\x12\n" +
+	"\x04rows\x18\x04 \x03(\x05R\x04rows\"+\n" +
+	"\x11GetSummaryRequest\x12\x16\n" +
+	"\x06office\x18\x01 \x01(\tR\x06office\"3\n" +
+	"\x19StreamOrderUpdatesRequest\x12\x16\n" +
+	"\x06office\x18\x01 \x01(\tR\x06office\"f\n" +
+	"\vOrderUpdate\x12.\n" +
+	"\x04time\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12'\n" +
+	"\x05order\x18\x02 \x01(\v2\x11.lunches.v1.OrderR\x05order2\x9e\x02\n" +
+	"\aLunches\x127\n" +
+	"\aGetMenu\x12\x1a.lunches.v1.GetMenuRequest\x1a\x10.lunches.v1.Menu\x12B\n" +
+	"\n" +
+	"PlaceOrder\x12\x1d.lunches.v1.PlaceOrderRequest\x1a\x15.lunches.v1.UserOrder\x12>\n" +
+	"\n" +
+	"GetSummary\x12\x1d.lunches.v1.GetSummaryRequest\x1a\x11.lunches.v1.Order\x12V\n" +
+	"\x12StreamOrderUpdates\x12%.lunches.v1.StreamOrderUpdatesRequest\x1a\x17.lunches.v1.OrderUpdate0\x01B1Z/github.com/develersrl/lunches/pkg/rpc/lunchespbb\x06proto3"
+
+var (
+	file_lunches_v1_lunches_proto_rawDescOnce sync.Once
+	file_lunches_v1_lunches_proto_rawDescData []byte
+)
+
+func file_lunches_v1_lunches_proto_rawDescGZIP() []byte {
+	file_lunches_v1_lunches_proto_rawDescOnce.Do(func() {
+		file_lunches_v1_lunches_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_lunches_v1_lunches_proto_rawDesc), len(file_lunches_v1_lunches_proto_rawDesc)))
+	})
+	return file_lunches_v1_lunches_proto_rawDescData
+}
+
+var file_lunches_v1_lunches_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_lunches_v1_lunches_proto_goTypes = []any{
+	(*MenuRow)(nil),                   // 0: lunches.v1.MenuRow
+	(*Menu)(nil),                      // 1: lunches.v1.Menu
+	(*UserChoice)(nil),                // 2: lunches.v1.UserChoice
+	(*UserOrder)(nil),                 // 3: lunches.v1.UserOrder
+	(*DishCount)(nil),                 // 4: lunches.v1.DishCount
+	(*Order)(nil),                     // 5: lunches.v1.Order
+	(*GetMenuRequest)(nil),            // 6: lunches.v1.GetMenuRequest
+	(*PlaceOrderRequest)(nil),         // 7: lunches.v1.PlaceOrderRequest
+	(*GetSummaryRequest)(nil),         // 8: lunches.v1.GetSummaryRequest
+	(*StreamOrderUpdatesRequest)(nil), // 9: lunches.v1.StreamOrderUpdatesRequest
+	(*OrderUpdate)(nil),               // 10: lunches.v1.OrderUpdate
+	(*timestamppb.Timestamp)(nil),     // 11: google.protobuf.Timestamp
+}
+var file_lunches_v1_lunches_proto_depIdxs = []int32{
+	0,  // 0: lunches.v1.Menu.rows:type_name -> lunches.v1.MenuRow
+	2,  // 1: lunches.v1.UserOrder.choices:type_name -> lunches.v1.UserChoice
+	3,  // 2: lunches.v1.Order.users:type_name -> lunches.v1.UserOrder
+	4,  // 3: lunches.v1.Order.dishes:type_name -> lunches.v1.DishCount
+	11, // 4: lunches.v1.OrderUpdate.time:type_name -> google.protobuf.Timestamp
+	5,  // 5: lunches.v1.OrderUpdate.order:type_name -> lunches.v1.Order
+	6,  // 6: lunches.v1.Lunches.GetMenu:input_type -> lunches.v1.GetMenuRequest
+	7,  // 7: lunches.v1.Lunches.PlaceOrder:input_type -> lunches.v1.PlaceOrderRequest
+	8,  // 8: lunches.v1.Lunches.GetSummary:input_type -> lunches.v1.GetSummaryRequest
+	9,  // 9: lunches.v1.Lunches.StreamOrderUpdates:input_type -> lunches.v1.StreamOrderUpdatesRequest
+	1,  // 10: lunches.v1.Lunches.GetMenu:output_type -> lunches.v1.Menu
+	3,  // 11: lunches.v1.Lunches.PlaceOrder:output_type -> lunches.v1.UserOrder
+	5,  // 12: lunches.v1.Lunches.GetSummary:output_type -> lunches.v1.Order
+	10, // 13: lunches.v1.Lunches.StreamOrderUpdates:output_type -> lunches.v1.OrderUpdate
+	10, // [10:14] is the sub-list for method output_type
+	6,  // [6:10] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_lunches_v1_lunches_proto_init() }
+func file_lunches_v1_lunches_proto_init() {
+	if File_lunches_v1_lunches_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_lunches_v1_lunches_proto_rawDesc), len(file_lunches_v1_lunches_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_lunches_v1_lunches_proto_goTypes,
+		DependencyIndexes: file_lunches_v1_lunches_proto_depIdxs,
+		MessageInfos:      file_lunches_v1_lunches_proto_msgTypes,
+	}.Build()
+	File_lunches_v1_lunches_proto = out.File
+	file_lunches_v1_lunches_proto_goTypes = nil
+	file_lunches_v1_lunches_proto_depIdxs = nil
+}
@@ -0,0 +1,254 @@
+// The lunch domain for the internal integrations preferring typed RPC to
+// the REST API: the menu of the day, the order and its updates. The messages
+// mirror the JSON of the REST API, see pkg/tinabot/api.go, and the service is
+// implemented by pkg/rpc.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: lunches/v1/lunches.proto
+
+package lunchespb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Lunches_GetMenu_FullMethodName            = "/lunches.v1.Lunches/GetMenu"
+	Lunches_PlaceOrder_FullMethodName         = "/lunches.v1.Lunches/PlaceOrder"
+	Lunches_GetSummary_FullMethodName         = "/lunches.v1.Lunches/GetSummary"
+	Lunches_StreamOrderUpdates_FullMethodName = "/lunches.v1.Lunches/StreamOrderUpdates"
+)
+
+// LunchesClient is the client API for Lunches service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type LunchesClient interface {
+	// GetMenu returns today's menu, NOT_FOUND if it's not known yet
+	GetMenu(ctx context.Context, in *GetMenuRequest, opts ...grpc.CallOption) (*Menu, error)
+	// PlaceOrder replaces the order of the user, FAILED_PRECONDITION if the
+	// order can't be changed anymore
+	PlaceOrder(ctx context.Context, in *PlaceOrderRequest, opts ...grpc.CallOption) (*UserOrder, error)
+	// GetSummary returns today's order
+	GetSummary(ctx context.Context, in *GetSummaryRequest, opts ...grpc.CallOption) (*Order, error)
+	// StreamOrderUpdates sends the order every time it changes
+	StreamOrderUpdates(ctx context.Context, in *StreamOrderUpdatesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[OrderUpdate], error)
+}
+
+type lunchesClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewLunchesClient(cc grpc.ClientConnInterface) LunchesClient {
+	return &lunchesClient{cc}
+}
+
+func (c *lunchesClient) GetMenu(ctx context.Context, in *GetMenuRequest, opts ...grpc.CallOption) (*Menu, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Menu)
+	err := c.cc.Invoke(ctx, Lunches_GetMenu_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lunchesClient) PlaceOrder(ctx context.Context, in *PlaceOrderRequest, opts ...grpc.CallOption) (*UserOrder, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UserOrder)
+	err := c.cc.Invoke(ctx, Lunches_PlaceOrder_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lunchesClient) GetSummary(ctx context.Context, in *GetSummaryRequest, opts ...grpc.CallOption) (*Order, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Order)
+	err := c.cc.Invoke(ctx, Lunches_GetSummary_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lunchesClient) StreamOrderUpdates(ctx context.Context, in *StreamOrderUpdatesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[OrderUpdate], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Lunches_ServiceDesc.Streams[0], Lunches_StreamOrderUpdates_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamOrderUpdatesRequest, OrderUpdate]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Lunches_StreamOrderUpdatesClient = grpc.ServerStreamingClient[OrderUpdate]
+
+// LunchesServer is the server API for Lunches service.
+// All implementations must embed UnimplementedLunchesServer
+// for forward compatibility.
+type LunchesServer interface {
+	// GetMenu returns today's menu, NOT_FOUND if it's not known yet
+	GetMenu(context.Context, *GetMenuRequest) (*Menu, error)
+	// PlaceOrder replaces the order of the user, FAILED_PRECONDITION if the
+	// order can't be changed anymore
+	PlaceOrder(context.Context, *PlaceOrderRequest) (*UserOrder, error)
+	// GetSummary returns today's order
+	GetSummary(context.Context, *GetSummaryRequest) (*Order, error)
+	// StreamOrderUpdates sends the order every time it changes
+	StreamOrderUpdates(*StreamOrderUpdatesRequest, grpc.ServerStreamingServer[OrderUpdate]) error
+	mustEmbedUnimplementedLunchesServer()
+}
+
+// UnimplementedLunchesServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedLunchesServer struct{}
+
+func (UnimplementedLunchesServer) GetMenu(context.Context, *GetMenuRequest) (*Menu, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMenu not implemented")
+}
+func (UnimplementedLunchesServer) PlaceOrder(context.Context, *PlaceOrderRequest) (*UserOrder, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PlaceOrder not implemented")
+}
+func (UnimplementedLunchesServer) GetSummary(context.Context, *GetSummaryRequest) (*Order, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSummary not implemented")
+}
+func (UnimplementedLunchesServer) StreamOrderUpdates(*StreamOrderUpdatesRequest, grpc.ServerStreamingServer[OrderUpdate]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamOrderUpdates not implemented")
+}
+func (UnimplementedLunchesServer) mustEmbedUnimplementedLunchesServer() {}
+func (UnimplementedLunchesServer) testEmbeddedByValue()                 {}
+
+// UnsafeLunchesServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to LunchesServer will
+// result in compilation errors.
+type UnsafeLunchesServer interface {
+	mustEmbedUnimplementedLunchesServer()
+}
+
+func RegisterLunchesServer(s grpc.ServiceRegistrar, srv LunchesServer) {
+	// If the following call pancis, it indicates UnimplementedLunchesServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Lunches_ServiceDesc, srv)
+}
+
+func _Lunches_GetMenu_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMenuRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LunchesServer).GetMenu(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Lunches_GetMenu_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LunchesServer).GetMenu(ctx, req.(*GetMenuRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lunches_PlaceOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PlaceOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LunchesServer).PlaceOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Lunches_PlaceOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LunchesServer).PlaceOrder(ctx, req.(*PlaceOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lunches_GetSummary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSummaryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LunchesServer).GetSummary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Lunches_GetSummary_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LunchesServer).GetSummary(ctx, req.(*GetSummaryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lunches_StreamOrderUpdates_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamOrderUpdatesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LunchesServer).StreamOrderUpdates(m, &grpc.GenericServerStream[StreamOrderUpdatesRequest, OrderUpdate]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Lunches_StreamOrderUpdatesServer = grpc.ServerStreamingServer[OrderUpdate]
+
+// Lunches_ServiceDesc is the grpc.ServiceDesc for Lunches service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Lunches_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "lunches.v1.Lunches",
+	HandlerType: (*LunchesServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetMenu",
+			Handler:    _Lunches_GetMenu_Handler,
+		},
+		{
+			MethodName: "PlaceOrder",
+			Handler:    _Lunches_PlaceOrder_Handler,
+		},
+		{
+			MethodName: "GetSummary",
+			Handler:    _Lunches_GetSummary_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamOrderUpdates",
+			Handler:       _Lunches_StreamOrderUpdates_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "lunches/v1/lunches.proto",
+}
@@ -0,0 +1,223 @@
+// Package rpc implements the Lunches gRPC service of
+// proto/lunches/v1/lunches.proto on the brain, with the same rules of the
+// bot and of the REST API. Its types mirror the messages of the service, the
+// gRPC server converts them to the generated ones of lunchespb, see
+// NewServer.
+package rpc
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=github.com/develersrl/lunches --go-grpc_out=../.. --go-grpc_opt=module=github.com/develersrl/lunches lunches/v1/lunches.proto
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tinabot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// ErrNoOffice is returned for the offices that don't exist
+var ErrNoOffice = errors.New("rpc: no such office")
+
+// MenuRow is a dish of the menu, Row is its index to order it
+type MenuRow struct {
+	Row           int
+	Type          string
+	Content       string
+	Price         string
+	DailyProposal bool
+}
+
+// Menu is the menu of a day
+type Menu struct {
+	Date string
+	Rows []MenuRow
+}
+
+// UserChoice is a choice of a user
+type UserChoice struct {
+	Dishes []string
+	Note   string
+}
+
+// UserOrder is the order of a user
+type UserOrder struct {
+	ID        string
+	Name      string
+	OrderedBy string
+	Choices   []UserChoice
+}
+
+// DishCount is how many times a dish has been ordered
+type DishCount struct {
+	Name  string
+	Count int
+}
+
+// Order is the order of a day
+type Order struct {
+	Date   string
+	State  string
+	Users  []UserOrder
+	Dishes []DishCount
+}
+
+// OrderUpdate is an order sent by StreamOrderUpdates
+type OrderUpdate struct {
+	Time  time.Time
+	Order Order
+}
+
+// Service is the Lunches service, on the brain Root
+type Service struct {
+	Root brain.Store
+	// Now returns the time of the requests, time.Now in Europe/Rome if nil
+	Now func() time.Time
+	// Stop ends the streams of the order updates once closed, for the
+	// shutdown
+	Stop <-chan struct{}
+}
+
+func (s *Service) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	if loc, err := time.LoadLocation("Europe/Rome"); err == nil {
+		return time.Now().In(loc)
+	}
+	return time.Now()
+}
+
+// store returns the part of the brain of office, Root if empty
+func (s *Service) store(office string) (brain.Store, error) {
+	if office == "" {
+		return s.Root, nil
+	}
+	b, ok := tinabot.OfficeStore(s.Root, office)
+	if !ok {
+		return nil, ErrNoOffice
+	}
+	return b, nil
+}
+
+// newMenu returns the menu m, without the empty rows
+func newMenu(m tuttobene.Menu) Menu {
+	menu := Menu{Date: m.Date.Format("2006-01-02")}
+	for i, r := range m.Rows {
+		if r.Type == tuttobene.Empty || r.Type == tuttobene.Unknonwn {
+			continue
+		}
+		row := MenuRow{Row: i, Type: tuttobene.Titles[r.Type], Content: r.Content, DailyProposal: r.IsDailyProposal}
+		if !r.Price.IsZero() {
+			row.Price = r.Price.String()
+		}
+		menu.Rows = append(menu.Rows, row)
+	}
+	return menu
+}
+
+// newUserOrder returns the order of u in o
+func newUserOrder(o *tinabot.Order, u tinabot.User) UserOrder {
+	uo := UserOrder{ID: u.ID, Name: o.Name(u)}
+	if by, ok := o.OrderedBy[u]; ok {
+		uo.OrderedBy = o.Name(by)
+	}
+	for _, c := range o.Users[u] {
+		var dishes []string
+		for _, d := range c.Dishes {
+			dishes = append(dishes, d.Content)
+		}
+		uo.Choices = append(uo.Choices, UserChoice{dishes, c.Note})
+	}
+	return uo
+}
+
+// newOrder returns the order o, the users by name
+func newOrder(o *tinabot.Order) Order {
+	order := Order{Date: o.Timestamp.Format("2006-01-02"), State: o.State.String()}
+	for u := range o.Users {
+		order.Users = append(order.Users, newUserOrder(o, u))
+	}
+	sort.Slice(order.Users, func(i, j int) bool {
+		return order.Users[i].Name < order.Users[j].Name
+	})
+	for _, d := range tinabot.NewAPIOrder(o).Dishes {
+		order.Dishes = append(order.Dishes, DishCount{d.Name, d.Count})
+	}
+	return order
+}
+
+// GetMenu returns today's menu
+func (s *Service) GetMenu(ctx context.Context, office string) (Menu, error) {
+	b, err := s.store(office)
+	if err != nil {
+		return Menu{}, err
+	}
+	m, err := tinabot.LoadMenu(b, s.now())
+	if err != nil {
+		return Menu{}, err
+	}
+	return newMenu(m), nil
+}
+
+// PlaceOrder replaces the order of the user with the dishes of today's menu
+// at the indexes rows, see tinabot.PlaceAPIOrder
+func (s *Service) PlaceOrder(ctx context.Context, office, userID, userName string, rows []int) (UserOrder, error) {
+	b, err := s.store(office)
+	if err != nil {
+		return UserOrder{}, err
+	}
+	if userName == "" {
+		userName = userID
+	}
+	user := tinabot.User{Name: userName, ID: userID}
+	if _, err := tinabot.PlaceAPIOrder(b, user, rows, s.now()); err != nil {
+		return UserOrder{}, err
+	}
+	return newUserOrder(tinabot.TodayOrder(b), user), nil
+}
+
+// GetSummary returns today's order
+func (s *Service) GetSummary(ctx context.Context, office string) (Order, error) {
+	b, err := s.store(office)
+	if err != nil {
+		return Order{}, err
+	}
+	return newOrder(tinabot.TodayOrder(b)), nil
+}
+
+// StreamOrderUpdates calls send with the order every time it changes, until
+// ctx is done, Stop is closed or send fails. The brain must support pub/sub.
+func (s *Service) StreamOrderUpdates(ctx context.Context, office string, send func(OrderUpdate) error) error {
+	b, err := s.store(office)
+	if err != nil {
+		return err
+	}
+	updates := make(chan OrderUpdate, 16)
+	stop, err := tinabot.WatchOrder(b, func(o *tinabot.Order) {
+		select {
+		case updates <- OrderUpdate{time.Now(), newOrder(o)}:
+		default:
+			// the client is too slow, it gets the next ones
+		}
+	})
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.Stop:
+			return nil
+		case u := <-updates:
+			if err := send(u); err != nil {
+				return err
+			}
+		}
+	}
+}
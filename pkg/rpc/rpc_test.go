@@ -0,0 +1,73 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tinabot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestService(t *testing.T) {
+	b := brain.NewMemory()
+	today := tinabot.NewOrder().Timestamp
+	b.Set("menu", tuttobene.Menu{Date: today, Rows: []tuttobene.MenuRow{
+		{Content: "Primi", Type: tuttobene.Empty},
+		{Content: "Risotto", Type: tuttobene.Primo, IsDailyProposal: true},
+		{Content: "Pollo", Type: tuttobene.Secondo},
+	}})
+	s := &Service{Root: b, Now: func() time.Time { return today }}
+	ctx := context.Background()
+
+	menu, err := s.GetMenu(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(menu.Rows) != 2 || menu.Rows[0] != (MenuRow{Row: 1, Type: "primi piatti", Content: "Risotto", DailyProposal: true}) {
+		t.Errorf("unexpected menu %+v", menu)
+	}
+	if _, err := s.GetMenu(ctx, "T1:C1"); err != ErrNoOffice {
+		t.Errorf("unknown office found: %v", err)
+	}
+
+	// the updates are streamed until the context is done
+	sctx, cancel := context.WithCancel(ctx)
+	got := make(chan OrderUpdate)
+	done := make(chan error)
+	go func() {
+		done <- s.StreamOrderUpdates(sctx, "", func(u OrderUpdate) error {
+			got <- u
+			return nil
+		})
+	}()
+	// wait for the stream to subscribe
+	time.Sleep(10 * time.Millisecond)
+
+	u, err := s.PlaceOrder(ctx, "", "U1", "mario", []int{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "mario" || len(u.Choices) != 2 || u.Choices[1].Dishes[0] != "Pollo" {
+		t.Errorf("unexpected order %+v", u)
+	}
+
+	update := <-got
+	if len(update.Order.Users) != 1 || update.Order.Users[0].ID != "U1" {
+		t.Errorf("unexpected update %+v", update)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("stream ended with %v", err)
+	}
+
+	summary, err := s.GetSummary(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Dishes) != 2 || summary.State != "aperto" {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+}
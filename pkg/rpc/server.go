@@ -0,0 +1,153 @@
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/develersrl/lunches/pkg/rpc/lunchespb"
+)
+
+// NewServer returns the gRPC server of s, accepting only the calls with one
+// of the tokens, sent as the metadata "authorization: Bearer <token>" as in
+// the REST API
+func NewServer(s *Service, tokens func() []string, opts ...grpc.ServerOption) *grpc.Server {
+	a := auth{tokens}
+	opts = append(opts, grpc.UnaryInterceptor(a.unary), grpc.StreamInterceptor(a.stream))
+	srv := grpc.NewServer(opts...)
+	lunchespb.RegisterLunchesServer(srv, &server{s: s})
+	return srv
+}
+
+// auth checks the token of the calls
+type auth struct {
+	tokens func() []string
+}
+
+// check returns Unauthenticated if the call of ctx has none of the tokens
+func (a auth) check(ctx context.Context) error {
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("authorization"); len(v) > 0 && strings.HasPrefix(v[0], "Bearer ") {
+			token = strings.TrimPrefix(v[0], "Bearer ")
+		}
+	}
+	ok := false
+	for _, t := range a.tokens() {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			ok = true
+		}
+	}
+	if !ok || token == "" {
+		return status.Error(codes.Unauthenticated, "token non valido")
+	}
+	return nil
+}
+
+func (a auth) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := a.check(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a auth) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := a.check(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// server converts the calls of the generated service to the ones of Service
+type server struct {
+	lunchespb.UnimplementedLunchesServer
+	s *Service
+}
+
+// officeError returns the status of the errors finding the office, err
+// with code otherwise
+func officeError(err error, code codes.Code) error {
+	if err == ErrNoOffice {
+		return status.Error(codes.NotFound, "ufficio non trovato")
+	}
+	return status.Error(code, err.Error())
+}
+
+func (srv *server) GetMenu(ctx context.Context, req *lunchespb.GetMenuRequest) (*lunchespb.Menu, error) {
+	m, err := srv.s.GetMenu(ctx, req.GetOffice())
+	if err != nil {
+		return nil, officeError(err, codes.NotFound)
+	}
+	menu := &lunchespb.Menu{Date: m.Date}
+	for _, r := range m.Rows {
+		menu.Rows = append(menu.Rows, &lunchespb.MenuRow{
+			Row:           int32(r.Row),
+			Type:          r.Type,
+			Content:       r.Content,
+			Price:         r.Price,
+			DailyProposal: r.DailyProposal,
+		})
+	}
+	return menu, nil
+}
+
+func (srv *server) PlaceOrder(ctx context.Context, req *lunchespb.PlaceOrderRequest) (*lunchespb.UserOrder, error) {
+	if req.GetUserId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "utente mancante")
+	}
+	var rows []int
+	for _, r := range req.GetRows() {
+		rows = append(rows, int(r))
+	}
+	u, err := srv.s.PlaceOrder(ctx, req.GetOffice(), req.GetUserId(), req.GetUserName(), rows)
+	if err != nil {
+		return nil, officeError(err, codes.FailedPrecondition)
+	}
+	return userOrderPB(u), nil
+}
+
+func (srv *server) GetSummary(ctx context.Context, req *lunchespb.GetSummaryRequest) (*lunchespb.Order, error) {
+	o, err := srv.s.GetSummary(ctx, req.GetOffice())
+	if err != nil {
+		return nil, officeError(err, codes.Internal)
+	}
+	return orderPB(o), nil
+}
+
+func (srv *server) StreamOrderUpdates(req *lunchespb.StreamOrderUpdatesRequest, stream grpc.ServerStreamingServer[lunchespb.OrderUpdate]) error {
+	err := srv.s.StreamOrderUpdates(stream.Context(), req.GetOffice(), func(u OrderUpdate) error {
+		return stream.Send(&lunchespb.OrderUpdate{Time: timestamppb.New(u.Time), Order: orderPB(u.Order)})
+	})
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return status.FromContextError(err).Err()
+	}
+	if err != nil {
+		return officeError(err, codes.Unavailable)
+	}
+	return nil
+}
+
+func userOrderPB(u UserOrder) *lunchespb.UserOrder {
+	pb := &lunchespb.UserOrder{Id: u.ID, Name: u.Name, OrderedBy: u.OrderedBy}
+	for _, c := range u.Choices {
+		pb.Choices = append(pb.Choices, &lunchespb.UserChoice{Dishes: c.Dishes, Note: c.Note})
+	}
+	return pb
+}
+
+func orderPB(o Order) *lunchespb.Order {
+	pb := &lunchespb.Order{Date: o.Date, State: o.State}
+	for _, u := range o.Users {
+		pb.Users = append(pb.Users, userOrderPB(u))
+	}
+	for _, d := range o.Dishes {
+		pb.Dishes = append(pb.Dishes, &lunchespb.DishCount{Name: d.Name, Count: int32(d.Count)})
+	}
+	return pb
+}
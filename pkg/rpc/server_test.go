@@ -0,0 +1,103 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/rpc/lunchespb"
+	"github.com/develersrl/lunches/pkg/tinabot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestServer(t *testing.T) {
+	b := brain.NewMemory()
+	today := tinabot.NewOrder().Timestamp
+	b.Set("menu", tuttobene.Menu{Date: today, Rows: []tuttobene.MenuRow{
+		{Content: "Risotto", Type: tuttobene.Primo},
+		{Content: "Pollo", Type: tuttobene.Secondo},
+	}})
+	srv := NewServer(&Service{Root: b, Now: func() time.Time { return today }}, func() []string {
+		return []string{"segreto"}
+	})
+	lis := bufconn.Listen(1 << 20)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := lunchespb.NewLunchesClient(conn)
+
+	ctx := context.Background()
+	if _, err := client.GetMenu(ctx, &lunchespb.GetMenuRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("call without token not refused: %v", err)
+	}
+	bad := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer sbagliato")
+	if _, err := client.GetSummary(bad, &lunchespb.GetSummaryRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("call with a wrong token not refused: %v", err)
+	}
+
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer segreto")
+	menu, err := client.GetMenu(ctx, &lunchespb.GetMenuRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(menu.Rows) != 2 || menu.Rows[1].Content != "Pollo" || menu.Rows[1].Row != 1 {
+		t.Errorf("unexpected menu %v", menu)
+	}
+	if _, err := client.GetMenu(ctx, &lunchespb.GetMenuRequest{Office: "T1:C1"}); status.Code(err) != codes.NotFound {
+		t.Errorf("unknown office found: %v", err)
+	}
+
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.StreamOrderUpdates(sctx, &lunchespb.StreamOrderUpdatesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// wait for the stream to subscribe
+	time.Sleep(50 * time.Millisecond)
+
+	u, err := client.PlaceOrder(ctx, &lunchespb.PlaceOrderRequest{UserId: "U1", UserName: "mario", Rows: []int32{0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "mario" || len(u.Choices) != 1 || u.Choices[0].Dishes[0] != "Risotto" {
+		t.Errorf("unexpected order %v", u)
+	}
+	if _, err := client.PlaceOrder(ctx, &lunchespb.PlaceOrderRequest{UserId: "U1", Rows: []int32{9}}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("order of a missing dish placed: %v", err)
+	}
+
+	update, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(update.Order.Users) != 1 || update.Order.Users[0].Id != "U1" || update.Time.AsTime().IsZero() {
+		t.Errorf("unexpected update %v", update)
+	}
+
+	summary, err := client.GetSummary(ctx, &lunchespb.GetSummaryRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Dishes) != 1 || summary.Dishes[0].Count != 1 || summary.State != "aperto" {
+		t.Errorf("unexpected summary %v", summary)
+	}
+}
@@ -0,0 +1,101 @@
+package sentry
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+// scope is the context of the events reported while handling an event of
+// Slack, e.g. its team and its payload
+type scope struct {
+	parent *scope
+	tags   map[string]string
+	extra  map[string]any
+}
+
+type scopeKey struct{}
+
+// WithContext returns ctx adding tags, to search the events, and extra, the
+// details shown in the events, to the ones reported with it. The extra must
+// be sanitized, see Sanitize.
+func WithContext(ctx context.Context, tags map[string]string, extra map[string]any) context.Context {
+	parent, _ := ctx.Value(scopeKey{}).(*scope)
+	return context.WithValue(ctx, scopeKey{}, &scope{parent, tags, extra})
+}
+
+// apply adds the tags and the extra of s to ev, the inner ones winning
+func (s *scope) apply(ev *Event) {
+	if s.parent != nil {
+		s.parent.apply(ev)
+	}
+	for k, v := range s.tags {
+		if v != "" {
+			ev.Tags[k] = v
+		}
+	}
+	for k, v := range s.extra {
+		ev.Extra[k] = v
+	}
+}
+
+// filtered replaces the values of the secrets
+const filtered = "[filtered]"
+
+// maxString is the length of the strings kept, the longer ones are truncated
+const maxString = 500
+
+// secretKeys are the parts of the names of the fields holding secrets or
+// personal data not needed to debug
+var secretKeys = []string{"token", "secret", "password", "authorization", "cookie", "signature", "email", "phone"}
+
+// isSecret tells if the field key holds a secret
+func isSecret(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range secretKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sanitize returns a copy of the JSON value v, as decoded in an any, with
+// the secrets filtered and the long strings truncated, to be sent to Sentry
+func Sanitize(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		s := make(map[string]any, len(v))
+		for k, e := range v {
+			if isSecret(k) {
+				s[k] = filtered
+				continue
+			}
+			s[k] = Sanitize(e)
+		}
+		return s
+	case []any:
+		s := make([]any, len(v))
+		for i, e := range v {
+			s[i] = Sanitize(e)
+		}
+		return s
+	case string:
+		if r := []rune(v); len(r) > maxString {
+			return string(r[:maxString]) + "…"
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// SanitizeJSON returns the sanitized JSON payload, see Sanitize, or a note if
+// it's not valid
+func SanitizeJSON(payload []byte) any {
+	var v any
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return "invalid JSON payload, " + err.Error()
+	}
+	return Sanitize(v)
+}
@@ -0,0 +1,306 @@
+// Package sentry reports the panics and the failures of the bot to Sentry,
+// with the context of the event being handled, so that they don't get lost
+// in the logs. The events are sent to the envelope endpoint of the project
+// in SENTRY_DSN, in the background; nothing is sent without it.
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/tracing"
+)
+
+// Client sends the events to a Sentry project
+type Client struct {
+	endpoint    string
+	dsn         string
+	key         string
+	environment string
+	release     string
+	http        *http.Client
+
+	// pending counts the events being sent, see Flush
+	pending sync.WaitGroup
+	// queue bounds the events being sent, the ones over it are dropped
+	queue chan struct{}
+}
+
+// maxPending is the number of events sent at once, the ones over it are
+// dropped instead of piling up while Sentry is slow or down
+const maxPending = 20
+
+// New returns the client of the project of dsn, e.g.
+// https://<key>@o0.ingest.sentry.io/<project>
+func New(dsn, environment, release string) (*Client, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	project := strings.TrimPrefix(u.Path, "/")
+	if u.User == nil || u.User.Username() == "" || project == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid Sentry DSN %q", dsn)
+	}
+	endpoint := url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/api/" + project + "/envelope/"}
+	return &Client{
+		endpoint:    endpoint.String(),
+		dsn:         dsn,
+		key:         u.User.Username(),
+		environment: environment,
+		release:     release,
+		http:        &http.Client{Timeout: 5 * time.Second},
+		queue:       make(chan struct{}, maxPending),
+	}, nil
+}
+
+// client is the client set up by Setup, nil if the events aren't reported
+var client *Client
+
+// Setup reports the events to the project in SENTRY_DSN, tagged with the
+// environment in SENTRY_ENVIRONMENT and the release in SENTRY_RELEASE, or
+// in SOURCE_VERSION on Heroku
+func Setup() error {
+	dsn := os.Getenv("SENTRY_DSN")
+	if dsn == "" {
+		return nil
+	}
+	release := os.Getenv("SENTRY_RELEASE")
+	if release == "" {
+		release = os.Getenv("SOURCE_VERSION")
+	}
+	c, err := New(dsn, os.Getenv("SENTRY_ENVIRONMENT"), release)
+	if err != nil {
+		return err
+	}
+	client = c
+	return nil
+}
+
+// Flush waits for the events being sent, until ctx is done
+func Flush(ctx context.Context) error {
+	if client == nil {
+		return nil
+	}
+	return client.Flush(ctx)
+}
+
+// Flush waits for the events being sent, until ctx is done
+func (c *Client) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		c.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// The event of Sentry, see https://develop.sentry.dev/sdk/event-payloads/
+type (
+	Event struct {
+		EventID     string            `json:"event_id"`
+		Timestamp   string            `json:"timestamp"`
+		Platform    string            `json:"platform"`
+		Level       string            `json:"level"`
+		ServerName  string            `json:"server_name,omitempty"`
+		Environment string            `json:"environment,omitempty"`
+		Release     string            `json:"release,omitempty"`
+		Exception   []Exception       `json:"exception,omitempty"`
+		Tags        map[string]string `json:"tags,omitempty"`
+		Extra       map[string]any    `json:"extra,omitempty"`
+	}
+	Exception struct {
+		Type       string      `json:"type"`
+		Value      string      `json:"value"`
+		Stacktrace *Stacktrace `json:"stacktrace,omitempty"`
+	}
+	Stacktrace struct {
+		Frames []Frame `json:"frames"`
+	}
+	Frame struct {
+		Function string `json:"function"`
+		Module   string `json:"module,omitempty"`
+		Filename string `json:"filename"`
+		Line     int    `json:"lineno"`
+		InApp    bool   `json:"in_app"`
+	}
+)
+
+// module is the Go module of the bot, its frames are the ones "in app"
+const module = "github.com/develersrl/lunches"
+
+// stacktrace returns the stack of the caller, skipping skip frames, with the
+// outermost frame first as Sentry wants
+func stacktrace(skip int) *Stacktrace {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var st []Frame
+	for {
+		f, more := frames.Next()
+		pkg, fn := splitFunction(f.Function)
+		st = append(st, Frame{
+			Function: fn,
+			Module:   pkg,
+			Filename: f.File,
+			Line:     f.Line,
+			InApp:    strings.HasPrefix(pkg, module) && !strings.Contains(pkg, "/vendor/"),
+		})
+		if !more {
+			break
+		}
+	}
+	for i, j := 0, len(st)-1; i < j; i, j = i+1, j-1 {
+		st[i], st[j] = st[j], st[i]
+	}
+	return &Stacktrace{st}
+}
+
+// splitFunction splits the name of a function as reported by runtime, e.g.
+// github.com/develersrl/lunches/pkg/tinabot.(*TinaBot).Vote, in its package
+// and its name
+func splitFunction(name string) (string, string) {
+	slash := strings.LastIndex(name, "/")
+	if i := strings.Index(name[slash+1:], "."); i >= 0 {
+		return name[:slash+1+i], name[slash+2+i:]
+	}
+	return "", name
+}
+
+// newEventID returns a random ID of an event, 32 hex digits
+func newEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// event returns the event of the exception of type typ and value at level,
+// with the stack of the caller skipping skip frames and the context of ctx
+func (c *Client) event(ctx context.Context, level, typ, value string, skip int) *Event {
+	host, _ := os.Hostname()
+	ev := &Event{
+		EventID:     newEventID(),
+		Timestamp:   time.Now().UTC().Format(time.RFC3339Nano),
+		Platform:    "go",
+		Level:       level,
+		ServerName:  host,
+		Environment: c.environment,
+		Release:     c.release,
+		Exception:   []Exception{{Type: typ, Value: value, Stacktrace: stacktrace(skip + 1)}},
+		Tags:        map[string]string{},
+		Extra:       map[string]any{},
+	}
+	if sc, ok := ctx.Value(scopeKey{}).(*scope); ok {
+		sc.apply(ev)
+	}
+	if id := tracing.TraceID(ctx); id != "" {
+		ev.Tags["trace_id"] = id
+	}
+	return ev
+}
+
+// send sends ev in the background, unless too many are being sent
+func (c *Client) send(ev *Event) {
+	select {
+	case c.queue <- struct{}{}:
+	default:
+		slog.Warn("Too many events being sent to Sentry, dropped", "event_id", ev.EventID)
+		return
+	}
+	c.pending.Add(1)
+	go func() {
+		defer func() {
+			<-c.queue
+			c.pending.Done()
+		}()
+		if err := c.post(ev); err != nil {
+			slog.Warn("Error sending the event to Sentry", "event_id", ev.EventID, "err", err)
+		}
+	}()
+}
+
+// post posts ev to the envelope endpoint
+func (c *Client) post(ev *Event) error {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	header, _ := json.Marshal(map[string]string{"event_id": ev.EventID, "dsn": c.dsn, "sent_at": time.Now().UTC().Format(time.RFC3339)})
+	item, _ := json.Marshal(map[string]any{"type": "event", "length": len(payload)})
+	body.Write(header)
+	body.WriteByte('\n')
+	body.Write(item)
+	body.WriteByte('\n')
+	body.Write(payload)
+	body.WriteByte('\n')
+
+	req, err := http.NewRequest(http.MethodPost, c.endpoint, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", "Sentry sentry_version=7, sentry_client=lunches/1.0, sentry_key="+c.key)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("sentry: %s", resp.Status)
+	}
+	return nil
+}
+
+// errorType returns the type of err shown by Sentry, e.g. *url.Error
+func errorType(err error) string {
+	return reflect.TypeOf(err).String()
+}
+
+// CaptureError reports err with the context of ctx, see WithContext
+func CaptureError(ctx context.Context, err error) {
+	if client == nil || err == nil {
+		return
+	}
+	client.send(client.event(ctx, "error", errorType(err), err.Error(), 1))
+}
+
+// CapturePanic reports the panic v recovered by the caller, with the stack
+// of the panic and the context of ctx
+func CapturePanic(ctx context.Context, v any) {
+	if client == nil {
+		return
+	}
+	// the stack of a deferred function still has the frames of the panic,
+	// under the ones of the runtime
+	client.send(client.event(ctx, "fatal", "panic", fmt.Sprint(v), 1))
+}
+
+// Recover recovers a panic, logs it and reports it with the context of ctx.
+// It must be deferred directly, e.g. defer sentry.Recover(ctx), where a
+// panic must not crash the bot: the handling of an event which panicked is
+// given up, the other events are still handled.
+func Recover(ctx context.Context) {
+	if v := recover(); v != nil {
+		slog.Error("Panic", "panic", v, "stack", string(debug.Stack()))
+		CapturePanic(ctx, v)
+	}
+}
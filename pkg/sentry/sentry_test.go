@@ -0,0 +1,115 @@
+package sentry
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	c, err := New("https://abc@o1.ingest.sentry.io/42", "staging", "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.endpoint != "https://o1.ingest.sentry.io/api/42/envelope/" || c.key != "abc" {
+		t.Errorf("got %s with key %s", c.endpoint, c.key)
+	}
+	for _, dsn := range []string{"https://o1.ingest.sentry.io/42", "https://abc@o1.ingest.sentry.io/", "boh"} {
+		if _, err := New(dsn, "", ""); err == nil {
+			t.Errorf("%s: no error", dsn)
+		}
+	}
+}
+
+func TestCapture(t *testing.T) {
+	events := make(chan Event, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("X-Sentry-Auth"), "sentry_key=abc") {
+			t.Errorf("missing key in %q", r.Header.Get("X-Sentry-Auth"))
+		}
+		// the header of the envelope, the header of the item and the event
+		sc := bufio.NewScanner(r.Body)
+		for i := 0; i < 3 && sc.Scan(); i++ {
+			if i == 2 {
+				var ev Event
+				if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+					t.Error(err)
+				}
+				events <- ev
+			}
+		}
+	}))
+	defer srv.Close()
+
+	c, err := New(strings.Replace(srv.URL, "://", "://abc@", 1)+"/1", "test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = c
+	defer func() { client = nil }()
+
+	ctx := WithContext(context.Background(), map[string]string{"team": "T1"}, map[string]any{"event": "a"})
+	ctx = WithContext(ctx, map[string]string{"channel": "C1", "user": ""}, nil)
+	CaptureError(ctx, errors.New("redis: connection refused"))
+	func() {
+		defer Recover(ctx)
+		panic("boom")
+	}()
+	if err := Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	close(events)
+
+	var got []Event
+	for ev := range events {
+		got = append(got, ev)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d events", len(got))
+	}
+	for _, ev := range got {
+		if ev.Tags["team"] != "T1" || ev.Tags["channel"] != "C1" || ev.Extra["event"] != "a" || ev.Environment != "test" {
+			t.Errorf("context missing in %+v", ev)
+		}
+		if _, ok := ev.Tags["user"]; ok {
+			t.Errorf("empty tag in %+v", ev)
+		}
+		frames := ev.Exception[0].Stacktrace.Frames
+		if last := frames[len(frames)-1]; !last.InApp || last.Module != "github.com/develersrl/lunches/pkg/sentry" {
+			t.Errorf("unexpected innermost frame %+v", last)
+		}
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	payload := `{"token": "xoxb", "event": {"text": "per me lasagne", "user": "U1", "blocks": [{"bot_access_token": "x"}]}, "user_email": "a@b.c", "long": "` + strings.Repeat("è", 600) + `"}`
+	v := SanitizeJSON([]byte(payload)).(map[string]any)
+	ev := v["event"].(map[string]any)
+	if v["token"] != filtered || v["user_email"] != filtered || ev["blocks"].([]any)[0].(map[string]any)["bot_access_token"] != filtered {
+		t.Errorf("secrets not filtered: %v", v)
+	}
+	if ev["text"] != "per me lasagne" || ev["user"] != "U1" {
+		t.Errorf("details lost: %v", ev)
+	}
+	if long := []rune(v["long"].(string)); len(long) != maxString+1 {
+		t.Errorf("long string not truncated: %d", len(long))
+	}
+	if s, ok := SanitizeJSON([]byte("{")).(string); !ok || !strings.HasPrefix(s, "invalid JSON") {
+		t.Errorf("got %v", s)
+	}
+}
+
+func TestSplitFunction(t *testing.T) {
+	pkg, fn := splitFunction("github.com/develersrl/lunches/pkg/tinabot.(*TinaBot).Vote")
+	if pkg != "github.com/develersrl/lunches/pkg/tinabot" || fn != "(*TinaBot).Vote" {
+		t.Errorf("got %s %s", pkg, fn)
+	}
+	if pkg, fn := splitFunction("main.main"); pkg != "main" || fn != "main" {
+		t.Errorf("got %s %s", pkg, fn)
+	}
+}
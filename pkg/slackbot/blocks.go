@@ -0,0 +1,143 @@
+package slackbot
+
+import (
+	"encoding/json"
+
+	"github.com/nlopes/slack"
+
+	"github.com/develersrl/lunches/pkg/chat"
+)
+
+func plainText(s string) *slack.TextBlockObject {
+	return slack.NewTextBlockObject(slack.PlainTextType, s, false, false)
+}
+
+// staticSelect is a static select menu, the one of the slack package sends
+// an empty url in the options which Slack accepts only in the overflow menus
+type staticSelect struct {
+	Type          string                 `json:"type"`
+	Placeholder   *slack.TextBlockObject `json:"placeholder"`
+	ActionID      string                 `json:"action_id"`
+	Options       []*selectOption        `json:"options"`
+	InitialOption *selectOption          `json:"initial_option,omitempty"`
+}
+
+type selectOption struct {
+	Text  *slack.TextBlockObject `json:"text"`
+	Value string                 `json:"value"`
+}
+
+func (s *staticSelect) ElementType() slack.MessageElementType {
+	return slack.MessageElementType(s.Type)
+}
+
+// SlackBlocks returns the Block Kit blocks showing the blocks on Slack
+func SlackBlocks(blocks []chat.Block) []slack.Block {
+	var out []slack.Block
+	for _, b := range blocks {
+		switch b := b.(type) {
+		case chat.Section:
+			out = append(out, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, b.Text, false, false), nil, nil))
+		case chat.Context:
+			out = append(out, slack.NewContextBlock("", plainText(b.Text)))
+		case chat.Divider:
+			out = append(out, slack.NewDividerBlock())
+		case chat.Actions:
+			var elements []slack.BlockElement
+			for _, e := range b.Elements {
+				elements = append(elements, slackElement(e))
+			}
+			out = append(out, slack.NewActionBlock("", elements...))
+		}
+	}
+	return out
+}
+
+// slackElement returns the Block Kit element of an interactive element
+func slackElement(e chat.Element) slack.BlockElement {
+	switch e := e.(type) {
+	case chat.Button:
+		button := slack.NewButtonBlockElement(e.ActionID, e.Value, plainText(e.Text))
+		if e.Style != chat.Default {
+			button.WithStyle(slack.Style(e.Style))
+		}
+		if c := e.Confirm; c != nil {
+			button.Confirm = slack.NewConfirmationBlockObject(plainText(c.Title), plainText(c.Text), plainText(c.Yes), plainText(c.No))
+		}
+		return button
+	case chat.Select:
+		s := &staticSelect{
+			Type:        slack.OptTypeStatic,
+			Placeholder: plainText(e.Placeholder),
+			ActionID:    e.ActionID,
+		}
+		for _, o := range e.Options {
+			option := &selectOption{plainText(o.Text), o.Value}
+			s.Options = append(s.Options, option)
+			if e.Initial != "" && o.Value == e.Initial {
+				s.InitialOption = option
+			}
+		}
+		return s
+	}
+	return nil
+}
+
+// ChatInteraction returns the interaction of a callback of the blocks
+func ChatInteraction(cb slack.InteractionCallback) chat.Interaction {
+	in := chat.Interaction{
+		Team:        cb.Team.ID,
+		Channel:     cb.Channel.ID,
+		User:        cb.User.ID,
+		ResponseURL: cb.ResponseURL,
+		TriggerID:   cb.TriggerID,
+	}
+	for _, a := range cb.ActionCallback.BlockActions {
+		value := a.Value
+		if a.SelectedOption.Value != "" {
+			value = a.SelectedOption.Value
+		}
+		in.Actions = append(in.Actions, chat.Action{ActionID: a.ActionID, Value: value})
+	}
+	return in
+}
+
+// InteractionTypeViewSubmission is the type of the interactions submitting
+// a modal, missing in the slack package
+const InteractionTypeViewSubmission = slack.InteractionType("view_submission")
+
+// viewSubmission is the payload of a modal submitted, see Bot.OpenModal
+type viewSubmission struct {
+	Team slack.Team `json:"team"`
+	User slack.User `json:"user"`
+	View struct {
+		CallbackID      string `json:"callback_id"`
+		PrivateMetadata string `json:"private_metadata"`
+	} `json:"view"`
+}
+
+// ChatSubmission returns the submission of a modal carried by payload, an
+// interaction of type InteractionTypeViewSubmission
+func ChatSubmission(payload []byte) (chat.Submission, error) {
+	var v viewSubmission
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return chat.Submission{}, err
+	}
+	return chat.Submission{
+		Team:       v.Team.ID,
+		User:       v.User.ID,
+		CallbackID: v.View.CallbackID,
+		Metadata:   v.View.PrivateMetadata,
+	}, nil
+}
+
+// ChatCommand returns the command of a slash command
+func ChatCommand(cmd slack.SlashCommand) chat.Command {
+	return chat.Command{
+		Team:        cmd.TeamID,
+		Channel:     cmd.ChannelID,
+		User:        cmd.UserID,
+		Text:        cmd.Text,
+		ResponseURL: cmd.ResponseURL,
+	}
+}
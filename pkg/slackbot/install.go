@@ -0,0 +1,287 @@
+package slackbot
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/config"
+)
+
+// The addresses of the OAuth flow of Slack
+const (
+	AuthorizeURL = "https://slack.com/oauth/v2/authorize"
+	AccessURL    = "https://slack.com/api/oauth.v2.access"
+)
+
+// DefaultScopes are the bot scopes asked to the workspaces installing the
+// bot, if SLACK_SCOPES is not set
+const DefaultScopes = "app_mentions:read,channels:history,chat:write,commands,files:read,groups:history,im:history,im:write,pins:write,reactions:read,users:read,users:read.email"
+
+// stateTTL is how long the users have to authorize the installation
+const stateTTL = 10 * time.Minute
+
+var (
+	// ErrNotInstalled is returned for the workspaces which didn't install
+	// the bot, when there's no token for all of them
+	ErrNotInstalled = errors.New("slackbot: bot not installed in the workspace")
+	// ErrState is returned for the authorizations not started here or expired
+	ErrState = errors.New("slackbot: invalid or expired OAuth state")
+)
+
+// Installation is the bot installed in a workspace through OAuth
+type Installation struct {
+	TeamID      string
+	TeamName    string
+	BotUserID   string
+	BotToken    string
+	InstalledBy string
+	Time        time.Time
+}
+
+// Installations stores the installations in the brain, encrypted
+type Installations struct {
+	repo  brain.Repo[Installation]
+	valid bool
+}
+
+// installPrefix is where the installations are kept in the root brain
+const installPrefix = "slack:install:"
+
+// IsInstalled tells if team installed the bot through OAuth, without reading
+// its token. The errors of the brain count as installed, so that a workspace
+// is never given the state of the one configured by hand.
+func IsInstalled(root brain.Store, team string) bool {
+	if team == "" {
+		return false
+	}
+	_, err := root.Read(installPrefix + team)
+	return err != brain.ErrNotFound
+}
+
+// NewInstallations returns the installations of root, encrypted with key,
+// which must be 16, 24 or 32 bytes long
+func NewInstallations(root brain.Store, key []byte) (*Installations, error) {
+	enc, err := brain.Encrypt(root, key)
+	if err != nil {
+		return nil, err
+	}
+	return &Installations{brain.NewRepo[Installation](enc, installPrefix), true}, nil
+}
+
+// InstallationsFromEnv returns the installations of root encrypted with the
+// base64 key in SLACK_TOKEN_KEY. Without key nothing is installed through
+// OAuth, and only the token in SLACK_BOT_TOKEN is used.
+func InstallationsFromEnv(root brain.Store) (*Installations, error) {
+	env := os.Getenv("SLACK_TOKEN_KEY")
+	if env == "" {
+		return &Installations{}, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(env)
+	if err != nil {
+		return nil, fmt.Errorf("slackbot: invalid SLACK_TOKEN_KEY: %v", err)
+	}
+	return NewInstallations(root, key)
+}
+
+// Enabled tells if the workspaces can install the bot through OAuth
+func (i *Installations) Enabled() bool {
+	return i.valid
+}
+
+// Get returns the installation of team
+func (i *Installations) Get(team string) (Installation, error) {
+	if !i.valid {
+		return Installation{}, ErrNotInstalled
+	}
+	inst, err := i.repo.Get(team)
+	if err == brain.ErrNotFound {
+		return inst, ErrNotInstalled
+	}
+	return inst, err
+}
+
+// Save stores the installation of its workspace, replacing the previous one
+func (i *Installations) Save(inst Installation) error {
+	if !i.valid {
+		return errors.New("slackbot: set SLACK_TOKEN_KEY to install the bot")
+	}
+	return i.repo.Put(inst.TeamID, inst)
+}
+
+// Delete removes the installation of team, e.g. when the app is uninstalled
+func (i *Installations) Delete(team string) error {
+	if !i.valid {
+		return nil
+	}
+	return i.repo.Delete(team)
+}
+
+// LegacyTeam returns the ID of the workspace configured by hand, whose bot
+// token and user ID are in SLACK_BOT_TOKEN and BOT_ID: the one in
+// SLACK_TEAM_ID, empty if not set
+func LegacyTeam() string {
+	return os.Getenv("SLACK_TEAM_ID")
+}
+
+// isLegacy tells if team is the workspace configured by hand. Without
+// SLACK_TEAM_ID it's the only workspace, as long as no other one can install
+// the bot.
+func (i *Installations) isLegacy(team string) bool {
+	if legacy := LegacyTeam(); legacy != "" {
+		return team == legacy
+	}
+	return !i.valid
+}
+
+// Credentials returns the bot token and user ID for the events of team:
+// the ones of its installation, or the ones in SLACK_BOT_TOKEN and BOT_ID
+// for the workspace configured by hand, see LegacyTeam. ErrNotInstalled is
+// returned for the other workspaces.
+func (i *Installations) Credentials(team string) (token, botID string, err error) {
+	inst, err := i.Get(team)
+	if err == nil {
+		return inst.BotToken, inst.BotUserID, nil
+	}
+	if err != ErrNotInstalled {
+		return "", "", err
+	}
+	if token := os.Getenv("SLACK_BOT_TOKEN"); token != "" && i.isLegacy(team) {
+		return token, os.Getenv("BOT_ID"), nil
+	}
+	return "", "", ErrNotInstalled
+}
+
+// Teams returns the IDs of the workspaces which installed the bot through
+// OAuth, sorted
+func (i *Installations) Teams() ([]string, error) {
+	if !i.valid {
+		return nil, nil
+	}
+	return i.repo.IDs()
+}
+
+// OAuth is the Slack app installing the bot in the workspaces
+type OAuth struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       string
+	// AccessURL is where the codes are exchanged, AccessURL if empty
+	AccessURL string
+	HTTP      *http.Client
+}
+
+// OAuthFromEnv returns the app in SLACK_CLIENT_ID and SLACK_CLIENT_SECRET,
+// redirecting to /slack/oauth on the public address of the bot, see
+// config.Config.Host, false if it's not configured
+func OAuthFromEnv() (*OAuth, bool) {
+	o := &OAuth{
+		ClientID:     os.Getenv("SLACK_CLIENT_ID"),
+		ClientSecret: os.Getenv("SLACK_CLIENT_SECRET"),
+		Scopes:       os.Getenv("SLACK_SCOPES"),
+	}
+	host := config.Current().Host
+	if o.ClientID == "" || o.ClientSecret == "" || host == "" {
+		return nil, false
+	}
+	o.RedirectURL = host + "/slack/oauth"
+	if o.Scopes == "" {
+		o.Scopes = DefaultScopes
+	}
+	return o, true
+}
+
+func stateKey(state string) string {
+	return "slack:oauth:" + state
+}
+
+// AuthorizeURL returns the address where a user authorizes the installation,
+// with a state saved in b for a while to check the redirect
+func (o *OAuth) AuthorizeURL(b brain.Store) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	state := hex.EncodeToString(buf)
+	if err := b.SetWithTTL(stateKey(state), true, stateTTL); err != nil {
+		return "", err
+	}
+	q := url.Values{
+		"client_id":    {o.ClientID},
+		"scope":        {o.Scopes},
+		"redirect_uri": {o.RedirectURL},
+		"state":        {state},
+	}
+	return AuthorizeURL + "?" + q.Encode(), nil
+}
+
+// accessResponse is the response of oauth.v2.access
+type accessResponse struct {
+	Error       string `json:"error"`
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	BotUserID   string `json:"bot_user_id"`
+	Team        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"team"`
+	AuthedUser struct {
+		ID string `json:"id"`
+	} `json:"authed_user"`
+}
+
+// Exchange checks the state of the redirect and exchanges its code for the
+// installation of the workspace
+func (o *OAuth) Exchange(b brain.Store, state, code string) (Installation, error) {
+	var ok bool
+	if state == "" || b.Get(stateKey(state), &ok) != nil || !ok {
+		return Installation{}, ErrState
+	}
+	b.Delete(stateKey(state))
+
+	access, client := o.AccessURL, o.HTTP
+	if access == "" {
+		access = AccessURL
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.PostForm(access, url.Values{
+		"client_id":     {o.ClientID},
+		"client_secret": {o.ClientSecret},
+		"code":          {code},
+		"redirect_uri":  {o.RedirectURL},
+	})
+	if err != nil {
+		return Installation{}, err
+	}
+	defer resp.Body.Close()
+
+	var r accessResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return Installation{}, fmt.Errorf("slackbot: invalid oauth.v2.access response: %v", err)
+	}
+	if r.Error != "" {
+		return Installation{}, fmt.Errorf("slackbot: oauth.v2.access: %s", r.Error)
+	}
+	if r.AccessToken == "" || r.Team.ID == "" {
+		return Installation{}, errors.New("slackbot: oauth.v2.access: no bot token")
+	}
+	return Installation{
+		TeamID:      r.Team.ID,
+		TeamName:    r.Team.Name,
+		BotUserID:   r.BotUserID,
+		BotToken:    r.AccessToken,
+		InstalledBy: r.AuthedUser.ID,
+		Time:        time.Now(),
+	}, nil
+}
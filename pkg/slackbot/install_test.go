@@ -0,0 +1,106 @@
+package slackbot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/brain"
+)
+
+func TestInstallations(t *testing.T) {
+	root := brain.NewMemory()
+	inst, err := NewInstallations(root, []byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := inst.Save(Installation{TeamID: "T1", BotUserID: "B1", BotToken: "xoxb-secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ := root.Read("slack:install:T1"); strings.Contains(raw, "xoxb-secret") {
+		t.Errorf("token stored in plaintext: %s", raw)
+	}
+
+	t.Setenv("SLACK_BOT_TOKEN", "xoxb-env")
+	t.Setenv("BOT_ID", "B0")
+	t.Setenv("SLACK_TEAM_ID", "T2")
+	if token, botID, err := inst.Credentials("T1"); err != nil || token != "xoxb-secret" || botID != "B1" {
+		t.Errorf("unexpected credentials %s %s %v", token, botID, err)
+	}
+	if teams, err := inst.Teams(); err != nil || len(teams) != 1 || teams[0] != "T1" {
+		t.Errorf("unexpected teams %v %v", teams, err)
+	}
+	// the workspace configured by hand
+	if token, botID, err := inst.Credentials("T2"); err != nil || token != "xoxb-env" || botID != "B0" {
+		t.Errorf("unexpected credentials %s %s %v", token, botID, err)
+	}
+	// the other workspaces don't get its token
+	if _, _, err := inst.Credentials("T3"); err != ErrNotInstalled {
+		t.Errorf("expected ErrNotInstalled, got %v", err)
+	}
+	t.Setenv("SLACK_TEAM_ID", "")
+	if _, _, err := inst.Credentials("T3"); err != ErrNotInstalled {
+		t.Errorf("expected ErrNotInstalled without SLACK_TEAM_ID, got %v", err)
+	}
+	inst.Delete("T1")
+	t.Setenv("SLACK_BOT_TOKEN", "")
+	if _, _, err := inst.Credentials("T1"); err != ErrNotInstalled {
+		t.Errorf("expected ErrNotInstalled, got %v", err)
+	}
+
+	// without installations every workspace is the one configured by hand
+	t.Setenv("SLACK_BOT_TOKEN", "xoxb-env")
+	disabled, _ := InstallationsFromEnv(root)
+	if disabled.Enabled() || disabled.Save(Installation{TeamID: "T1"}) == nil {
+		t.Error("installation saved without key")
+	}
+	if token, _, err := disabled.Credentials("T3"); err != nil || token != "xoxb-env" {
+		t.Errorf("unexpected credentials %s %v", token, err)
+	}
+}
+
+func TestOAuth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "c0de" || r.FormValue("client_secret") != "s3cret" {
+			w.Write([]byte(`{"ok":false,"error":"invalid_code"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true,"access_token":"xoxb-1","bot_user_id":"B1","team":{"id":"T1","name":"Acme"},"authed_user":{"id":"U1"}}`))
+	}))
+	defer srv.Close()
+
+	b := brain.NewMemory()
+	o := &OAuth{ClientID: "id", ClientSecret: "s3cret", RedirectURL: "https://lunch/slack/oauth", Scopes: "chat:write", AccessURL: srv.URL}
+	u, err := o.AuthorizeURL(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _ := url.Parse(u)
+	state := parsed.Query().Get("state")
+	if state == "" || parsed.Query().Get("redirect_uri") != o.RedirectURL {
+		t.Fatalf("unexpected authorize URL %s", u)
+	}
+
+	if _, err := o.Exchange(b, "forged", "c0de"); err != ErrState {
+		t.Errorf("expected ErrState, got %v", err)
+	}
+	inst, err := o.Exchange(b, state, "c0de")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst.TeamID != "T1" || inst.TeamName != "Acme" || inst.BotToken != "xoxb-1" || inst.BotUserID != "B1" || inst.InstalledBy != "U1" {
+		t.Errorf("unexpected installation %+v", inst)
+	}
+	// the state is used once
+	if _, err := o.Exchange(b, state, "c0de"); err != ErrState {
+		t.Errorf("expected ErrState, got %v", err)
+	}
+
+	u, _ = o.AuthorizeURL(b)
+	parsed, _ = url.Parse(u)
+	if _, err := o.Exchange(b, parsed.Query().Get("state"), "wrong"); err == nil || !strings.Contains(err.Error(), "invalid_code") {
+		t.Errorf("expected invalid_code, got %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package slackbot
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	commandsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_commands_total",
+		Help: "Messages handled by the bot by intent and result: handled, refused, suggested, unknown or throttled.",
+	}, []string{"intent", "result"})
+
+	lastCommand = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "bot_last_command_timestamp_seconds",
+		Help: "When the bot last handled a message, to alert when it stops answering.",
+	})
+
+	apiErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "slack_api_errors_total",
+		Help: "Failed calls to the chat platform by method.",
+	}, []string{"method"})
+)
+
+func init() {
+	prometheus.MustRegister(commandsTotal, lastCommand, apiErrors)
+}
+
+// observeCommand counts a message of the users routed to intent, empty if
+// it matched none
+func observeCommand(intent, result string) {
+	if intent == "" {
+		intent = "none"
+	}
+	commandsTotal.WithLabelValues(intent, result).Inc()
+	lastCommand.Set(float64(time.Now().Unix()))
+}
+
+// APIError counts a failed call to method of the chat platform, e.g.
+// "chat.postMessage", for the errors of the calls made outside the bot
+func APIError(method string) {
+	apiErrors.WithLabelValues(method).Inc()
+}
@@ -0,0 +1,55 @@
+package slackbot
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/intent"
+)
+
+func TestCommandMetrics(t *testing.T) {
+	bot := New("B1", nil)
+	bot.Intent(intent.Help, "aiuto", "aiuto")
+	bot.Handle(intent.Help, "^aiuto$", func(*Bot, *BotMsg, *chat.User, ...string) {})
+	bot.DefaultResponse(func(*Bot, *BotMsg, *chat.User) {})
+
+	count := func(intent, result string) float64 {
+		return testutil.ToFloat64(commandsTotal.WithLabelValues(intent, result))
+	}
+	handled, unknown := count(intent.Help, "handled"), count("none", "unknown")
+	msg := &BotMsg{Channel: "D1", User: "U1"}
+	bot.route(msg, &chat.User{}, "aiuto")
+	bot.route(msg, &chat.User{}, "xyz")
+
+	if n := count(intent.Help, "handled"); n != handled+1 {
+		t.Errorf("expected %v handled, got %v", handled+1, n)
+	}
+	if n := count("none", "unknown"); n != unknown+1 {
+		t.Errorf("expected %v unknown, got %v", unknown+1, n)
+	}
+	if testutil.ToFloat64(lastCommand) == 0 {
+		t.Error("last command not set")
+	}
+
+	before := testutil.ToFloat64(apiErrors.WithLabelValues("users.info"))
+	APIError("users.info")
+	if n := testutil.ToFloat64(apiErrors.WithLabelValues("users.info")); n != before+1 {
+		t.Errorf("expected %v errors, got %v", before+1, n)
+	}
+}
+
+func TestThrottledMetrics(t *testing.T) {
+	bot := New("B1", nil)
+	// the users of throttled commands are not even looked up
+	bot.Platform = nil
+	bot.Throttle = func(channel, user string) (bool, string) {
+		return user == "U1", ""
+	}
+	before := testutil.ToFloat64(commandsTotal.WithLabelValues("none", "throttled"))
+	bot.HandleText("D1", "U1", "aiuto")
+	if n := testutil.ToFloat64(commandsTotal.WithLabelValues("none", "throttled")); n != before+1 {
+		t.Errorf("expected %v throttled, got %v", before+1, n)
+	}
+}
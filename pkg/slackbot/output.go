@@ -0,0 +1,52 @@
+package slackbot
+
+import (
+	"strings"
+)
+
+// Output is where the answer to a message is shown
+type Output int
+
+const (
+	// Public posts the answer in the channel, outside of any thread
+	Public Output = iota
+	// Thread posts the answer in the thread of the channel, if any, like
+	// Message
+	Thread
+	// Ephemeral shows the answer in the channel only to the sender
+	Ephemeral
+	// Direct sends the answer in a direct message to the sender
+	Direct
+)
+
+// Reply answers msg with text, shown as out. The answers to the slash
+// commands and to the interactions are always visible only to the sender.
+func (bot *Bot) Reply(msg *BotMsg, out Output, text string) {
+	if bot.answering(msg.Channel) {
+		bot.respond(text)
+		return
+	}
+	if strings.HasPrefix(msg.Channel, "D") && out != Thread {
+		// a direct message is already private
+		out = Public
+	}
+
+	var err error
+	switch out {
+	case Public:
+		_, err = bot.Platform.SendMessage(msg.Channel, "", text)
+	case Thread:
+		bot.Message(msg.Channel, text)
+	case Ephemeral:
+		err = bot.Platform.SendEphemeral(msg.Channel, bot.thread(msg.Channel), msg.User, text)
+	case Direct:
+		var ch string
+		if ch, err = bot.Platform.DirectChannel(msg.User); err == nil {
+			_, err = bot.Platform.SendMessage(ch, "", text)
+		}
+	}
+	if err != nil {
+		APIError("chat.postMessage")
+		bot.Logger().Error("Error sending the output", "channel", msg.Channel, "err", err)
+	}
+}
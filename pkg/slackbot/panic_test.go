@@ -0,0 +1,59 @@
+package slackbot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/intent"
+)
+
+// sentPlatform records the messages sent by the bot
+type sentPlatform struct {
+	chat.Platform
+	sent []string
+}
+
+func (p *sentPlatform) SendMessage(channel, thread, text string, blocks ...chat.Block) (string, error) {
+	p.sent = append(p.sent, text)
+	return "", nil
+}
+
+func TestHandlePanic(t *testing.T) {
+	p := &sentPlatform{}
+	bot := New("B1", nil)
+	bot.Platform = p
+	bot.Handle(intent.Other, "^boom$", func(*Bot, *BotMsg, *chat.User, ...string) {
+		panic("boom")
+	})
+
+	bot.route(&BotMsg{Channel: "C1", User: "U1"}, &chat.User{}, "boom")
+	if len(p.sent) != 1 || !strings.HasPrefix(p.sent[0], "Ops, qualcosa è andato storto!") {
+		t.Errorf("the user wasn't told, got %q", p.sent)
+	}
+	if bot.ctx != nil {
+		t.Error("the span of the command wasn't ended")
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	p := &sentPlatform{}
+	bot := New("B1", nil)
+	bot.Platform = p
+	ran := false
+	bot.Handle(intent.Admin, "^cron$", func(*Bot, *BotMsg, *chat.User, ...string) {
+		ran = true
+	})
+	bot.Authorize = func(intentName string, msg *BotMsg, user *chat.User) (bool, string) {
+		return intentName != intent.Admin || user.ID == "U1", "solo gli amministratori"
+	}
+
+	bot.route(&BotMsg{Channel: "C1", User: "U2"}, &chat.User{ID: "U2"}, "cron")
+	if ran || len(p.sent) != 1 || p.sent[0] != "solo gli amministratori" {
+		t.Errorf("the command wasn't refused, got %q", p.sent)
+	}
+	bot.route(&BotMsg{Channel: "C1", User: "U1"}, &chat.User{ID: "U1"}, "cron")
+	if !ran {
+		t.Error("the command of the admin wasn't run")
+	}
+}
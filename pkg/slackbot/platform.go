@@ -0,0 +1,200 @@
+package slackbot
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+
+	"github.com/develersrl/lunches/pkg/chat"
+)
+
+// Platform is the Slack adapter of chat.Platform
+type Platform struct {
+	Client *slack.Client
+	// VerificationToken checks the events received by ParseEvent
+	VerificationToken string
+}
+
+// SendMessage implements chat.Platform
+func (p *Platform) SendMessage(channel, thread, text string, blocks ...chat.Block) (string, error) {
+	_, ts, err := p.Client.PostMessage(channel, msgOptions(thread, text, blocks)...)
+	return ts, err
+}
+
+// msgOptions returns the options of a message with text and blocks, in the
+// thread if not empty
+func msgOptions(thread, text string, blocks []chat.Block) []slack.MsgOption {
+	opts := []slack.MsgOption{slack.MsgOptionText(text, false)}
+	if thread != "" {
+		opts = append(opts, slack.MsgOptionTS(thread))
+	}
+	if len(blocks) > 0 {
+		opts = append(opts, slack.MsgOptionBlocks(SlackBlocks(blocks)...))
+	}
+	return opts
+}
+
+// SendEphemeral implements chat.Platform
+func (p *Platform) SendEphemeral(channel, thread, userID, text string, blocks ...chat.Block) error {
+	_, err := p.Client.PostEphemeral(channel, userID, msgOptions(thread, text, blocks)...)
+	return err
+}
+
+// UpdateMessage implements chat.Platform
+func (p *Platform) UpdateMessage(channel, id, text string, blocks ...chat.Block) error {
+	_, _, _, err := p.Client.UpdateMessage(channel, id, msgOptions("", text, blocks)...)
+	return err
+}
+
+// DirectChannel implements chat.Platform
+func (p *Platform) DirectChannel(userID string) (string, error) {
+	_, _, ch, err := p.Client.OpenIMChannel(userID)
+	return ch, err
+}
+
+// UserInfo implements chat.Platform
+func (p *Platform) UserInfo(userID string) (*chat.User, error) {
+	u, err := p.Client.GetUserInfo(userID)
+	if err != nil {
+		return nil, err
+	}
+	return ChatUser(u), nil
+}
+
+// Users implements chat.Platform
+func (p *Platform) Users() ([]chat.User, error) {
+	users, err := p.Client.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]chat.User, len(users))
+	for i := range users {
+		out[i] = *ChatUser(&users[i])
+	}
+	return out, nil
+}
+
+// ChannelMembers implements chat.Platform
+func (p *Platform) ChannelMembers(channel string) ([]string, error) {
+	var ids []string
+	params := &slack.GetUsersInConversationParameters{ChannelID: channel, Limit: 200}
+	for {
+		page, cursor, err := p.Client.GetUsersInConversation(params)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, page...)
+		if cursor == "" {
+			return ids, nil
+		}
+		params.Cursor = cursor
+	}
+}
+
+// AddReaction implements chat.Platform
+func (p *Platform) AddReaction(channel, id, emoji string) error {
+	return p.Client.AddReaction(emoji, slack.NewRefToMessage(channel, id))
+}
+
+// Pin implements chat.Platform
+func (p *Platform) Pin(channel, id string) error {
+	return p.Client.AddPin(channel, slack.NewRefToMessage(channel, id))
+}
+
+// Unpin implements chat.Platform
+func (p *Platform) Unpin(channel, id string) error {
+	err := p.Client.RemovePin(channel, slack.NewRefToMessage(channel, id))
+	if err != nil && err.Error() == "no_pin" {
+		return nil
+	}
+	return err
+}
+
+// Pinned implements chat.Platform, for the pinned messages
+func (p *Platform) Pinned(channel string) ([]chat.Message, error) {
+	items, _, err := p.Client.ListPins(channel)
+	if err != nil {
+		return nil, err
+	}
+	var pinned []chat.Message
+	for _, it := range items {
+		if m := it.Message; m != nil {
+			pinned = append(pinned, chat.Message{ID: m.Timestamp, User: m.User, Text: m.Text})
+		}
+	}
+	return pinned, nil
+}
+
+// UploadFile implements chat.Platform
+func (p *Platform) UploadFile(channel string, f chat.File, data []byte) error {
+	_, err := p.Client.UploadFile(slack.FileUploadParameters{
+		Reader:   bytes.NewReader(data),
+		Filename: f.Name,
+		Filetype: f.Type,
+		Title:    f.Title,
+		Channels: []string{channel},
+	})
+	return err
+}
+
+// FileInfo implements chat.Platform
+func (p *Platform) FileInfo(id string) (*chat.File, error) {
+	f, _, _, err := p.Client.GetFileInfo(id, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &chat.File{
+		ID:    f.ID,
+		Name:  f.Name,
+		Title: f.Title,
+		Type:  f.Filetype,
+		Size:  int64(f.Size),
+		URL:   f.URLPrivateDownload,
+	}, nil
+}
+
+// DownloadFile implements chat.Platform
+func (p *Platform) DownloadFile(f *chat.File) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := p.Client.GetFile(f.URL, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ParseEvent implements chat.Platform for the messages and the mentions of
+// the Events API. The other events are only for Slack and are handled by
+// the caller.
+func (p *Platform) ParseEvent(r *http.Request) (*chat.Event, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	ev, err := slackevents.ParseEvent(json.RawMessage(body), slackevents.OptionVerifyToken(&slackevents.TokenComparator{VerificationToken: p.VerificationToken}))
+	if err != nil || ev.Type != slackevents.CallbackEvent {
+		return nil, err
+	}
+	switch m := ev.InnerEvent.Data.(type) {
+	case *slackevents.AppMentionEvent:
+		return &chat.Event{Channel: m.Channel, User: m.User, Text: m.Text}, nil
+	case *slackevents.MessageEvent:
+		return &chat.Event{Channel: m.Channel, User: m.User, Text: m.Text}, nil
+	}
+	return nil, nil
+}
+
+// ChatUser returns the chat user of a Slack user
+func ChatUser(u *slack.User) *chat.User {
+	return &chat.User{
+		ID:          u.ID,
+		Name:        u.Name,
+		DisplayName: u.Profile.DisplayName,
+		Email:       u.Profile.Email,
+		IsBot:       u.IsBot,
+		Deleted:     u.Deleted,
+	}
+}
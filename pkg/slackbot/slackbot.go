@@ -1,11 +1,23 @@
 package slackbot
 
 import (
-	"log"
-	"regexp"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
 	"strings"
 
 	"github.com/nlopes/slack"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/intent"
+	"github.com/develersrl/lunches/pkg/sentry"
+	"github.com/develersrl/lunches/pkg/tracing"
 )
 
 type BotMsg struct {
@@ -14,39 +26,272 @@ type BotMsg struct {
 	Text    string
 }
 
-type SimpleAction func(*Bot, *BotMsg, *slack.User)
-type Action func(*Bot, *BotMsg, *slack.User, ...string)
+type SimpleAction func(*Bot, *BotMsg, *chat.User)
+type Action func(*Bot, *BotMsg, *chat.User, ...string)
 
 type Bot struct {
 	UserID string
 
-	Client *slack.Client
+	// Platform sends the messages of the bot, Slack unless the bot is
+	// answering another chat platform, see HandleText
+	Platform chat.Platform
+	// Token is the bot token, for the API methods missing in the slack
+	// package, e.g. the Home tab
+	Token string
+	// Thread returns the thread where the messages to channel are posted,
+	// if any, so that the bot does not flood the channel
+	Thread func(channel string) string
+	// Throttle tells if the commands of user in channel exceed their rate
+	// limits, with the notice answering the dropped command, if any
+	Throttle func(channel, user string) (throttled bool, notice string)
+	// Authorize tells if user can run the commands of intentName in the
+	// channel of msg, with the notice answering the refused command. All
+	// the commands are allowed if nil.
+	Authorize func(intentName string, msg *BotMsg, user *chat.User) (allowed bool, notice string)
+	// Log is the logger of the event being handled, with its correlation
+	// ID, the default logger if nil
+	Log *slog.Logger
+
+	// ctx carries the span of the step being handled, see Trace
+	ctx context.Context
+
+	router     *intent.Router[Action]
+	defact     SimpleAction
+	didYouMean Action
 
-	actions map[*regexp.Regexp]Action
-	defact  SimpleAction
+	// the channel of the slash command being handled, answered through its
+	// response URL since the bot may not be a member of it
+	commandChannel string
+	responseURL    string
+}
+
+// commandResponse is the message posted to the response URL of a slash
+// command or of an interaction
+type commandResponse struct {
+	Text            string        `json:"text"`
+	ResponseType    string        `json:"response_type,omitempty"`
+	ReplaceOriginal bool          `json:"replace_original,omitempty"`
+	Blocks          []slack.Block `json:"blocks,omitempty"`
 }
 
 func New(botID string, api *slack.Client) *Bot {
 
 	bot := &Bot{
-		UserID:  botID,
-		Client:  api,
-		actions: make(map[*regexp.Regexp]Action),
+		UserID:   botID,
+		Platform: &Platform{Client: api},
+		router:   intent.New[Action](),
 	}
 
 	return bot
 }
 
+// Logger returns the logger of the event being handled, see Log
+func (bot *Bot) Logger() *slog.Logger {
+	if bot.Log == nil {
+		return slog.Default()
+	}
+	return bot.Log
+}
+
+// Context returns the context of the event being handled, with the span of
+// the step being handled, see Trace
+func (bot *Bot) Context() context.Context {
+	if bot.ctx == nil {
+		return context.Background()
+	}
+	return bot.ctx
+}
+
+// SetContext sets the context of the event being handled, with the span of
+// the request which carried it
+func (bot *Bot) SetContext(ctx context.Context) {
+	bot.ctx = ctx
+}
+
+// Trace starts the span named name, child of the one of the step being
+// handled, and makes it the current one until the returned function ends it
+// with the error of the step, if any
+func (bot *Bot) Trace(name string, attrs ...attribute.KeyValue) func(err error) {
+	parent := bot.ctx
+	ctx, span := tracing.Start(bot.Context(), name, attrs...)
+	bot.ctx = ctx
+	return func(err error) {
+		tracing.End(span, err)
+		bot.ctx = parent
+	}
+}
+
+// RespondTo runs action for the messages matching match, see Handle
 func (bot *Bot) RespondTo(match string, action Action) {
-	bot.actions[regexp.MustCompile(match)] = action
+	bot.Handle(intent.Other, match, action)
+}
+
+// Intent defines an intent of the users, see intent.Router.Intent
+func (bot *Bot) Intent(name, example string, keywords ...string) {
+	bot.router.Intent(name, example, keywords...)
+}
+
+// Handle runs action for the messages matching match, with the submatches
+// as arguments, as the given intent. The patterns are tried in the order
+// they were added. The usages document the commands for the help.
+func (bot *Bot) Handle(intentName, match string, action Action, usages ...intent.Usage) {
+	bot.router.Add(intentName, match, action, usages...)
+}
+
+// Route returns how a message sent to the bot is handled
+func (bot *Bot) Route(text string) intent.Result[Action] {
+	return bot.router.Route(text)
+}
+
+// Usages returns the usages of the commands handled, see Handle
+func (bot *Bot) Usages() []intent.Usage {
+	return bot.router.Usages()
+}
+
+// Lookup returns the usages of a command, see intent.Router.Lookup
+func (bot *Bot) Lookup(command string) []intent.Usage {
+	return bot.router.Lookup(command)
 }
 
 func (bot *Bot) DefaultResponse(action SimpleAction) {
 	bot.defact = action
 }
 
+// DidYouMean runs action for the messages matching no pattern but close to
+// some intents, with the examples of the intents as arguments, best first
+func (bot *Bot) DidYouMean(action Action) {
+	bot.didYouMean = action
+}
+
 func (bot *Bot) Message(channel string, msg string) {
-	bot.Client.PostMessage(channel, slack.MsgOptionText(msg, false))
+	if bot.answering(channel) {
+		bot.respond(msg)
+		return
+	}
+	if _, err := bot.Platform.SendMessage(channel, bot.thread(channel), msg); err != nil {
+		APIError("chat.postMessage")
+		bot.Logger().Error("Error sending a message", "channel", channel, "err", err)
+	}
+}
+
+// thread returns the thread of channel where the bot posts, if any
+func (bot *Bot) thread(channel string) string {
+	if bot.Thread == nil {
+		return ""
+	}
+	return bot.Thread(channel)
+}
+
+// Blocks shows the message blocks only to userID in channel, text is shown
+// in the notifications and on the platforms without blocks
+func (bot *Bot) Blocks(channel, userID, text string, blocks ...chat.Block) {
+	if bot.answering(channel) {
+		postResponse(bot.responseURL, commandResponse{Text: text, ResponseType: "ephemeral", Blocks: SlackBlocks(blocks)})
+		return
+	}
+	if err := bot.Platform.SendEphemeral(channel, bot.thread(channel), userID, text, blocks...); err != nil {
+		APIError("chat.postEphemeral")
+		bot.Logger().Error("Error posting the blocks", "channel", channel, "err", err)
+	}
+}
+
+// Replace replaces the message of an interaction with text and blocks, if any
+func (bot *Bot) Replace(responseURL, text string, blocks ...chat.Block) {
+	postResponse(responseURL, commandResponse{Text: text, ReplaceOriginal: true, Blocks: SlackBlocks(blocks)})
+}
+
+// The API methods of the views, not in Client
+var (
+	viewsPublishURL = "https://slack.com/api/views.publish"
+	viewsOpenURL    = "https://slack.com/api/views.open"
+)
+
+// PublishHome shows the blocks in the Home tab of the bot for userID
+func (bot *Bot) PublishHome(userID string, blocks ...chat.Block) error {
+	return bot.callViews(viewsPublishURL, "views.publish", map[string]interface{}{
+		"user_id": userID,
+		"view": map[string]interface{}{
+			"type":   "home",
+			"blocks": SlackBlocks(blocks),
+		},
+	})
+}
+
+// OpenModal opens the modal m in answer to the interaction triggerID, see
+// chat.Interaction
+func (bot *Bot) OpenModal(triggerID string, m chat.Modal) error {
+	return bot.callViews(viewsOpenURL, "views.open", map[string]interface{}{
+		"trigger_id": triggerID,
+		"view": map[string]interface{}{
+			"type":             "modal",
+			"callback_id":      m.CallbackID,
+			"private_metadata": m.Metadata,
+			"title":            plainText(m.Title),
+			"submit":           plainText(m.Submit),
+			"close":            plainText(m.Close),
+			"blocks":           SlackBlocks(m.Blocks),
+		},
+	})
+}
+
+// callViews calls the views API method name at url with the request
+func (bot *Bot) callViews(url, name string, request interface{}) error {
+	if bot.Token == "" {
+		return errors.New("no bot token to call " + name)
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+bot.Token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var res struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return err
+	}
+	if !res.OK {
+		APIError(name)
+		return errors.New(name + ": " + res.Error)
+	}
+	return nil
+}
+
+// respond posts msg to the response URL of the slash command, visible only
+// to the user who sent it
+func (bot *Bot) respond(msg string) {
+	postResponse(bot.responseURL, commandResponse{Text: msg, ResponseType: "ephemeral"})
+}
+
+func postResponse(responseURL string, r commandResponse) {
+	body, err := json.Marshal(r)
+	if err != nil {
+		slog.Error("Error encoding the response", "err", err)
+		return
+	}
+	resp, err := http.Post(responseURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		APIError("response_url")
+		slog.Error("Error posting to the response URL", "err", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		APIError("response_url")
+		slog.Error("Error posting to the response URL", "status", resp.Status)
+	}
 }
 
 func (bot *Bot) validMessage(msg *BotMsg) bool {
@@ -66,19 +311,118 @@ func (bot *Bot) HandleMsg(channel, username, text string) {
 
 	txt := bot.cleanupMsg(msg.Text)
 
-	user, err := bot.Client.GetUserInfo(msg.User)
+	bot.dispatch(msg, txt)
+}
+
+// HandleCommand runs text as a command sent to the bot by username in
+// channel, answering to the response URL of the slash command carrying it
+func (bot *Bot) HandleCommand(channel, username, text, responseURL string) {
+	bot.commandChannel, bot.responseURL = channel, responseURL
+	defer func() {
+		bot.commandChannel, bot.responseURL = "", ""
+	}()
+	bot.dispatch(&BotMsg{channel, username, text}, strings.TrimSpace(text))
+}
+
+// HandleText runs text as a command sent to the bot by userID in channel,
+// for the platforms whose events are already addressed to the bot, see
+// chat.Platform.ParseEvent
+func (bot *Bot) HandleText(channel, userID, text string) {
+	bot.dispatch(&BotMsg{channel, userID, text}, strings.TrimSpace(text))
+}
+
+// answering tells if the answers to channel go to the response URL of the
+// command being handled
+func (bot *Bot) answering(channel string) bool {
+	return bot.responseURL != "" && channel == bot.commandChannel
+}
+
+// HandleInteraction calls fn with the user who interacted with a message of
+// the bot in channel, the answers to channel go to responseURL
+func (bot *Bot) HandleInteraction(channel, username, responseURL string, fn SimpleAction) {
+	user, err := bot.Platform.UserInfo(username)
 	if err != nil {
-		log.Println(err.Error())
+		APIError("users.info")
+		bot.Logger().Error("Error getting the user", "err", err)
 		return
 	}
+	bot.commandChannel, bot.responseURL = channel, responseURL
+	defer func() {
+		bot.commandChannel, bot.responseURL = "", ""
+	}()
+	fn(bot, &BotMsg{channel, username, ""}, user)
+}
 
-	for match, action := range bot.actions {
-		if matches := match.FindAllStringSubmatch(txt, -1); matches != nil {
-			action(bot, msg, user, matches[0]...)
+// dispatch runs the action matching txt for the user who sent msg
+func (bot *Bot) dispatch(msg *BotMsg, txt string) {
+	if bot.Throttle != nil {
+		if throttled, notice := bot.Throttle(msg.Channel, msg.User); throttled {
+			bot.Logger().Warn("Command throttled", "channel", msg.Channel, "user", msg.User)
+			observeCommand("", "throttled")
+			if notice != "" {
+				bot.Message(msg.Channel, notice)
+			}
 			return
 		}
 	}
+	user, err := bot.Platform.UserInfo(msg.User)
+	if err != nil {
+		APIError("users.info")
+		bot.Logger().Error("Error getting the user", "err", err)
+		return
+	}
+	bot.route(msg, user, txt)
+}
 
+// handle runs the action of the command txt as intentName. A panic is
+// reported and the user is told, instead of crashing the bot with all the
+// events being handled.
+func (bot *Bot) handle(intentName string, action Action, msg *BotMsg, user *chat.User, txt string, args []string) {
+	end := bot.Trace("command "+intentName, attribute.String("intent", intentName))
+	defer func() {
+		v := recover()
+		if v == nil {
+			end(nil)
+			return
+		}
+		bot.Logger().Error("Panic handling a command", "intent", intentName, "panic", v, "stack", string(debug.Stack()))
+		ctx := sentry.WithContext(bot.Context(), map[string]string{"intent": intentName}, map[string]any{"command": sentry.Sanitize(txt)})
+		sentry.CapturePanic(ctx, v)
+		end(fmt.Errorf("panic: %v", v))
+		bot.Message(msg.Channel, "Ops, qualcosa è andato storto! L'ho segnalato a chi mi sviluppa, riprova tra poco")
+	}()
+	action(bot, msg, user, args...)
+}
+
+// route runs the action matching txt, suggesting the intents close to it
+// if none does, the default action if there's none close
+func (bot *Bot) route(msg *BotMsg, user *chat.User, txt string) {
+	res := bot.router.Route(txt)
+	if res.Route != nil {
+		bot.Logger().Debug("Handling a command", "intent", res.Route.Intent, "pattern", res.Route.Pattern.String())
+		if bot.Authorize != nil {
+			if allowed, notice := bot.Authorize(res.Route.Intent, msg, user); !allowed {
+				bot.Logger().Warn("Command refused", "intent", res.Route.Intent, "user", msg.User)
+				observeCommand(res.Route.Intent, "refused")
+				bot.Message(msg.Channel, notice)
+				return
+			}
+		}
+		observeCommand(res.Route.Intent, "handled")
+		bot.handle(res.Route.Intent, res.Route.Handler, msg, user, txt, res.Args)
+		return
+	}
+
+	if len(res.Suggestions) > 0 && bot.didYouMean != nil {
+		var examples []string
+		for _, s := range res.Suggestions {
+			examples = append(examples, s.Example)
+		}
+		observeCommand(res.Suggestions[0].Intent, "suggested")
+		bot.didYouMean(bot, msg, user, examples...)
+		return
+	}
+	observeCommand("", "unknown")
 	if bot.defact != nil {
 		bot.defact(bot, msg, user)
 	}
@@ -0,0 +1,189 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// connectionsOpenURL is the API method returning the Socket Mode URL, not in
+// the slack client
+var connectionsOpenURL = "https://slack.com/api/apps.connections.open"
+
+// The types of the Socket Mode envelopes
+const (
+	SocketHello        = "hello"
+	SocketDisconnect   = "disconnect"
+	SocketEventsAPI    = "events_api"
+	SocketSlashCommand = "slash_commands"
+	SocketInteractive  = "interactive"
+)
+
+// SocketEnvelope is a message received in Socket Mode, the payload is the
+// same Slack would send to the HTTP endpoint of its type
+type SocketEnvelope struct {
+	EnvelopeID string          `json:"envelope_id"`
+	Type       string          `json:"type"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// SocketHandler handles an envelope, the returned payload, if any, is sent
+// back with the acknowledgement, e.g. the reply to a slash command
+type SocketHandler func(env SocketEnvelope) interface{}
+
+// socketAck acknowledges an envelope, Slack sends it again if not
+// acknowledged within 3 seconds
+type socketAck struct {
+	EnvelopeID string      `json:"envelope_id"`
+	Payload    interface{} `json:"payload,omitempty"`
+}
+
+// maxSocketBackoff is the longest wait before connecting again
+const maxSocketBackoff = time.Minute
+
+// socketConnected is set while a Socket Mode connection is up
+var socketConnected atomic.Bool
+
+// SocketConnected tells if the bot is connected to Slack in Socket Mode
+func SocketConnected() bool {
+	return socketConnected.Load()
+}
+
+// openSocket returns the URL of a new Socket Mode connection
+func openSocket(appToken string) (string, error) {
+	req, err := http.NewRequest("POST", connectionsOpenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+appToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var res struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+		URL   string `json:"url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return "", err
+	}
+	if !res.OK {
+		return "", errors.New("apps.connections.open: " + res.Error)
+	}
+	return res.URL, nil
+}
+
+// ServeSocket receives the events from Slack in Socket Mode, through a
+// websocket opened with the app-level token, so that no public URL is
+// needed. It connects again when Slack asks to or the connection drops, and
+// returns only if appToken is missing or, once the envelope being handled is
+// done, when ctx is done.
+func ServeSocket(ctx context.Context, appToken string, h SocketHandler) error {
+	if appToken == "" {
+		return errors.New("no app token for Socket Mode")
+	}
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := serveSocket(ctx, appToken, h)
+		if ctx.Err() != nil {
+			slog.Info("Socket Mode disconnected")
+			return nil
+		}
+		if time.Since(start) > maxSocketBackoff {
+			backoff = time.Second
+		}
+		if err != nil {
+			slog.Warn("Socket Mode connection lost, retrying", "backoff", backoff.String(), "err", err)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return nil
+			}
+			if backoff *= 2; backoff > maxSocketBackoff {
+				backoff = maxSocketBackoff
+			}
+		}
+	}
+}
+
+// serveSocket handles the envelopes of a single connection, until Slack
+// closes it or, once the envelope being handled is acknowledged, ctx is
+// done. Slack sends the envelopes not acknowledged to the other connections.
+func serveSocket(ctx context.Context, appToken string, h SocketHandler) error {
+	url, err := openSocket(appToken)
+	if err != nil {
+		return err
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer socketConnected.Store(false)
+
+	// the deadline stops the reading of the next envelope, not the handling
+	// of the current one
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	for {
+		var env SocketEnvelope
+		if err := conn.ReadJSON(&env); err != nil {
+			if ctx.Err() != nil {
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+				return nil
+			}
+			return err
+		}
+		switch env.Type {
+		case SocketHello:
+			slog.Info("Socket Mode connected")
+			socketConnected.Store(true)
+			continue
+		case SocketDisconnect:
+			// Slack is about to close the connection, e.g. to refresh it
+			return nil
+		}
+		if env.EnvelopeID == "" {
+			continue
+		}
+
+		// the acknowledgement must come first, handling can take longer
+		// than Slack is willing to wait, except for the slash commands whose
+		// reply is the payload of the acknowledgement
+		if env.Type != SocketSlashCommand {
+			if err := conn.WriteJSON(socketAck{EnvelopeID: env.EnvelopeID}); err != nil {
+				return err
+			}
+			handleSocket(h, env)
+			continue
+		}
+		if err := conn.WriteJSON(socketAck{env.EnvelopeID, handleSocket(h, env)}); err != nil {
+			return err
+		}
+	}
+}
+
+// handleSocket calls h, logging its panics so that the connection survives
+func handleSocket(h SocketHandler, env SocketEnvelope) (payload interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Socket Mode handler failed", "type", env.Type, "envelope_id", env.EnvelopeID, "panic", r)
+			payload = nil
+		}
+	}()
+	return h(env)
+}
@@ -0,0 +1,70 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestServeSocketShutdown(t *testing.T) {
+	acks := make(chan socketAck, 1)
+	ws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteJSON(SocketEnvelope{Type: SocketHello})
+		conn.WriteJSON(SocketEnvelope{EnvelopeID: "E1", Type: SocketEventsAPI})
+		var ack socketAck
+		if conn.ReadJSON(&ack) == nil {
+			acks <- ack
+		}
+		// wait for the bot to close the connection
+		conn.ReadMessage()
+	}))
+	defer ws.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "url": "ws" + strings.TrimPrefix(ws.URL, "http")})
+	}))
+	defer api.Close()
+	defer func(u string) { connectionsOpenURL = u }(connectionsOpenURL)
+	connectionsOpenURL = api.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handled := make(chan string, 1)
+	served := make(chan error)
+	go func() {
+		served <- ServeSocket(ctx, "xapp", func(env SocketEnvelope) interface{} {
+			// the shutdown begins while handling the envelope
+			cancel()
+			time.Sleep(10 * time.Millisecond)
+			handled <- env.EnvelopeID
+			return nil
+		})
+	}()
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Socket Mode not stopped")
+	}
+	if id := <-handled; id != "E1" {
+		t.Fatalf("unexpected envelope %s", id)
+	}
+	if ack := <-acks; ack.EnvelopeID != "E1" {
+		t.Fatalf("unexpected ack %+v", ack)
+	}
+	if SocketConnected() {
+		t.Fatal("still connected")
+	}
+}
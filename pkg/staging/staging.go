@@ -0,0 +1,124 @@
+// Package staging is the mode of the bot for rehearsals, set with STAGING=1.
+// The bot keeps its state in a separate namespace of the brain, marks all
+// the messages it sends to Slack with [TEST], and can simulate the failures
+// of Redis and of Slack, to rehearse how they are handled without waiting
+// for a real incident.
+//
+// The failures are simulated by the instance receiving the command, for a
+// limited time: they're not in the brain, which can be failing itself.
+package staging
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Namespace is the namespace of the brain in staging mode
+const Namespace = "staging"
+
+// Prefix marks the messages sent in staging mode
+const Prefix = "[TEST] "
+
+// Enabled tells if the bot runs in staging mode, see STAGING
+func Enabled() bool {
+	on, _ := strconv.ParseBool(os.Getenv("STAGING"))
+	return on
+}
+
+// Mark returns text marked with Prefix in staging mode, for the messages
+// sent as the replies to the requests of Slack rather than with its API
+func Mark(text string) string {
+	if !Enabled() || text == "" {
+		return text
+	}
+	return mark(text)
+}
+
+// The targets of the simulated failures
+const (
+	// Redis makes the operations of the brain fail as if Redis was down
+	Redis = "redis"
+	// Slack makes the calls to the Slack API fail as rate limited
+	Slack = "slack"
+)
+
+// Targets are the targets of the simulated failures
+var Targets = []string{Redis, Slack}
+
+// Fault is a simulated failure of a target
+type Fault struct {
+	Target string
+	// Percent is the share of the operations failing
+	Percent int
+	Until   time.Time
+}
+
+func (f Fault) String() string {
+	return fmt.Sprintf("%s: %d%% delle operazioni falliscono fino alle %s", f.Target, f.Percent, f.Until.Format("15:04:05"))
+}
+
+var (
+	mu     sync.Mutex
+	faults = map[string]Fault{}
+)
+
+// Inject makes percent of the operations on target fail for d, replacing
+// the failure simulated until now
+func Inject(target string, percent int, d time.Duration) (Fault, error) {
+	known := false
+	for _, t := range Targets {
+		known = known || t == target
+	}
+	if !known {
+		return Fault{}, fmt.Errorf("non so simulare guasti di '%s'", target)
+	}
+	if percent <= 0 || percent > 100 {
+		return Fault{}, fmt.Errorf("percentuale %d non valida, deve essere tra 1 e 100", percent)
+	}
+	f := Fault{target, percent, time.Now().Add(d)}
+	mu.Lock()
+	defer mu.Unlock()
+	faults[target] = f
+	return f, nil
+}
+
+// Clear stops all the simulated failures
+func Clear() {
+	mu.Lock()
+	defer mu.Unlock()
+	faults = map[string]Fault{}
+}
+
+// Faults returns the failures being simulated, by target
+func Faults() []Fault {
+	mu.Lock()
+	defer mu.Unlock()
+	now := time.Now()
+	var fs []Fault
+	for _, f := range faults {
+		if now.Before(f.Until) {
+			fs = append(fs, f)
+		}
+	}
+	sort.Slice(fs, func(i, j int) bool { return fs[i].Target < fs[j].Target })
+	return fs
+}
+
+// Fail tells if an operation on target has to fail
+func Fail(target string) bool {
+	mu.Lock()
+	f, ok := faults[target]
+	mu.Unlock()
+	return ok && time.Now().Before(f.Until) && rand.Intn(100) < f.Percent
+}
+
+// ErrRedisDown is the error of the operations of the brain failing, a
+// network error like the ones of a Redis server down
+var ErrRedisDown error = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("simulated failure of staging mode")}
@@ -0,0 +1,48 @@
+package staging
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFaults(t *testing.T) {
+	defer Clear()
+	if Fail(Redis) {
+		t.Error("failing without faults")
+	}
+	if _, err := Inject("postgres", 50, time.Minute); err == nil {
+		t.Error("unknown target injected")
+	}
+	if _, err := Inject(Redis, 0, time.Minute); err == nil {
+		t.Error("invalid percent injected")
+	}
+
+	if _, err := Inject(Redis, 100, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Inject(Slack, 100, -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if !Fail(Redis) || Fail(Slack) {
+		t.Error("expected only redis failing, slack is over")
+	}
+	if fs := Faults(); len(fs) != 1 || fs[0].Target != Redis {
+		t.Errorf("got %v", fs)
+	}
+
+	Clear()
+	if Fail(Redis) || len(Faults()) != 0 {
+		t.Error("faults not cleared")
+	}
+}
+
+func TestMark(t *testing.T) {
+	t.Setenv("STAGING", "")
+	if Mark("ciao") != "ciao" {
+		t.Error("marked outside staging mode")
+	}
+	t.Setenv("STAGING", "1")
+	if Mark("ciao") != "[TEST] ciao" || Mark("[TEST] ciao") != "[TEST] ciao" || Mark("") != "" {
+		t.Error("not marked once in staging mode")
+	}
+}
@@ -0,0 +1,152 @@
+package staging
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Setup turns the staging mode on if STAGING is set: the requests to Slack
+// of all the clients using the default transport, like the ones of the
+// slack package and the responses to the slash commands, go through
+// Transport
+func Setup() {
+	if !Enabled() {
+		return
+	}
+	slog.Warn("Staging mode: the brain is namespaced and the messages are marked", "namespace", Namespace, "prefix", Prefix)
+	http.DefaultTransport = Transport(http.DefaultTransport)
+}
+
+// transport is the http.RoundTripper of the staging mode, see Transport
+type transport struct {
+	next http.RoundTripper
+}
+
+// Transport returns next marking the messages sent to Slack with Prefix,
+// and failing the calls to Slack as rate limited while simulated, see Fail
+func Transport(next http.RoundTripper) http.RoundTripper {
+	return transport{next}
+}
+
+// isSlack tells if host is the one of the API of Slack or of the response
+// URLs
+func isSlack(host string) bool {
+	return host == "slack.com" || strings.HasSuffix(host, ".slack.com")
+}
+
+// isMessage tells if the request to Slack sends a message: a method of the
+// chat API or a response URL
+func isMessage(u *url.URL) bool {
+	return strings.HasPrefix(u.Path, "/api/chat.") || strings.HasPrefix(u.Host, "hooks.")
+}
+
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isSlack(req.URL.Host) {
+		return t.next.RoundTrip(req)
+	}
+	if Fail(Slack) {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return rateLimited(req), nil
+	}
+	if req.Method != http.MethodPost || req.Body == nil || !isMessage(req.URL) {
+		return t.next.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		body = markJSON(body)
+	} else {
+		body = markForm(body)
+	}
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return t.next.RoundTrip(req)
+}
+
+// rateLimited returns the response of Slack to the calls over its rate
+// limits, to be retried after a second
+func rateLimited(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:     "429 Too Many Requests",
+		StatusCode: http.StatusTooManyRequests,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Retry-After": {"1"}, "Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"ok":false,"error":"ratelimited"}`)),
+		Request:    req,
+	}
+}
+
+// mark returns text starting with Prefix
+func mark(text string) string {
+	if strings.HasPrefix(text, Prefix) {
+		return text
+	}
+	return Prefix + text
+}
+
+// markBlocks returns the JSON blocks of a message with a first block showing
+// Prefix, since Slack shows the blocks instead of the text
+func markBlocks(blocks string) string {
+	var bs []json.RawMessage
+	if err := json.Unmarshal([]byte(blocks), &bs); err != nil || len(bs) == 0 {
+		return blocks
+	}
+	first, _ := json.Marshal(map[string]any{
+		"type":     "context",
+		"elements": []map[string]string{{"type": "mrkdwn", "text": strings.TrimSpace(Prefix)}},
+	})
+	marked, err := json.Marshal(append([]json.RawMessage{first}, bs...))
+	if err != nil {
+		return blocks
+	}
+	return string(marked)
+}
+
+// markForm marks the message in the form encoded body of a method of the
+// chat API
+func markForm(body []byte) []byte {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return body
+	}
+	if text := values.Get("text"); text != "" {
+		values.Set("text", mark(text))
+	}
+	if blocks := values.Get("blocks"); blocks != "" {
+		values.Set("blocks", markBlocks(blocks))
+	}
+	return []byte(values.Encode())
+}
+
+// markJSON marks the message in the JSON body posted to a response URL
+func markJSON(body []byte) []byte {
+	var msg map[string]any
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return body
+	}
+	if text, ok := msg["text"].(string); ok && text != "" {
+		msg["text"] = mark(text)
+	}
+	marked, err := json.Marshal(msg)
+	if err != nil {
+		return body
+	}
+	return marked
+}
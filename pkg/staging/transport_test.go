@@ -0,0 +1,67 @@
+package staging
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// recorder records the body of the requests, answering ok
+type recorder struct {
+	bodies []string
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, _ := io.ReadAll(req.Body)
+	r.bodies = append(r.bodies, string(body))
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"ok":true,"channel":"C1","ts":"1"}`)),
+		Request:    req,
+	}, nil
+}
+
+func TestTransport(t *testing.T) {
+	rec := &recorder{}
+	api := slack.New("xoxb", slack.OptionHTTPClient(&http.Client{Transport: Transport(rec)}))
+
+	blocks := slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, "menù", false, false), nil, nil)
+	if _, _, err := api.PostMessage("C1", slack.MsgOptionText("ciao", false), slack.MsgOptionBlocks(blocks)); err != nil {
+		t.Fatal(err)
+	}
+	values, _ := url.ParseQuery(rec.bodies[0])
+	if values.Get("text") != "[TEST] ciao" {
+		t.Errorf("text not marked: %q", values.Get("text"))
+	}
+	var bs []map[string]any
+	json.Unmarshal([]byte(values.Get("blocks")), &bs)
+	if len(bs) != 2 || bs[0]["type"] != "context" {
+		t.Errorf("blocks not marked: %s", values.Get("blocks"))
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "https://hooks.slack.com/commands/1", strings.NewReader(`{"text":"ok","response_type":"ephemeral"}`))
+	req.Header.Set("Content-Type", "application/json")
+	if _, err := Transport(rec).RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(rec.bodies[1], `"text":"[TEST] ok"`) {
+		t.Errorf("response not marked: %s", rec.bodies[1])
+	}
+
+	defer Clear()
+	Inject(Slack, 100, time.Minute)
+	_, _, err := api.PostMessage("C1", slack.MsgOptionText("ciao", false))
+	if _, ok := err.(*slack.RateLimitedError); !ok {
+		t.Errorf("expected rate limited, got %v", err)
+	}
+	if len(rec.bodies) != 2 {
+		t.Error("rate limited call sent")
+	}
+}
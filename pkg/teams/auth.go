@@ -0,0 +1,243 @@
+package teams
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The issuer of the tokens sent by the Bot Framework and where its keys are
+// published
+const (
+	botFrameworkIssuer = "https://api.botframework.com"
+	openIDConfigURL    = "https://login.botframework.com/v1/.well-known/openidconfiguration"
+)
+
+// keysTTL is how long the signing keys are cached, the Bot Framework rolls
+// them every few weeks
+const keysTTL = 24 * time.Hour
+
+// refetchInterval is the least time between two downloads of the keys, so
+// that the tokens signed with unknown keys can't make the bot flood the Bot
+// Framework with requests
+const refetchInterval = 5 * time.Minute
+
+// maxUnknownKeys is the most unknown keys remembered, see Verifier.unknown
+const maxUnknownKeys = 1000
+
+// clockSkew is the tolerance on the times of the tokens
+const clockSkew = 5 * time.Minute
+
+// ErrUnauthorized is returned for the requests not coming from the Bot
+// Framework for the bot
+var ErrUnauthorized = errors.New("teams: unauthorized")
+
+// Verifier checks that the requests to the messaging endpoint are sent by
+// the Bot Framework to the bot AppID, validating their JWT
+type Verifier struct {
+	AppID string
+	// ConfigURL is the OpenID configuration with the signing keys
+	ConfigURL string
+	HTTP      *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+	// tried is the last download of the keys, even if failed
+	tried time.Time
+	// unknown are the keys missing when last asked, refused without
+	// downloading the keys again for refetchInterval
+	unknown map[string]time.Time
+}
+
+// NewVerifier returns the verifier of the requests for the bot appID
+func NewVerifier(appID string) *Verifier {
+	return &Verifier{AppID: appID, ConfigURL: openIDConfigURL, HTTP: &http.Client{Timeout: 10 * time.Second}}
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// fetchKeys downloads the signing keys listed in the OpenID configuration
+func (v *Verifier) fetchKeys() (map[string]*rsa.PublicKey, error) {
+	var config struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := v.getJSON(v.ConfigURL, &config); err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := v.getJSON(config.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys, nil
+}
+
+func (v *Verifier) getJSON(url string, q interface{}) error {
+	resp, err := v.HTTP.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("teams keys: %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(q)
+}
+
+// key returns the signing key kid, downloading the keys again if it's
+// unknown, e.g. just rolled, at most every refetchInterval. The expired keys
+// are used until they can be downloaded again.
+func (v *Verifier) key(kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := time.Now()
+	k, ok := v.keys[kid]
+	if ok && now.Sub(v.fetched) < keysTTL {
+		return k, nil
+	}
+	if at, found := v.unknown[kid]; found && now.Sub(at) < refetchInterval {
+		return nil, ErrUnauthorized
+	}
+	if now.Sub(v.tried) < refetchInterval {
+		if ok {
+			return k, nil
+		}
+		v.markUnknown(kid, now)
+		return nil, ErrUnauthorized
+	}
+
+	v.tried = now
+	keys, err := v.fetchKeys()
+	if err != nil {
+		if ok {
+			return k, nil
+		}
+		return nil, err
+	}
+	v.keys, v.fetched, v.unknown = keys, now, nil
+	if k, ok := keys[kid]; ok {
+		return k, nil
+	}
+	v.markUnknown(kid, now)
+	return nil, ErrUnauthorized
+}
+
+// markUnknown remembers that the key kid was missing at now
+func (v *Verifier) markUnknown(kid string, now time.Time) {
+	if v.unknown == nil || len(v.unknown) >= maxUnknownKeys {
+		v.unknown = make(map[string]time.Time)
+	}
+	v.unknown[kid] = now
+}
+
+// claims are the claims of the token checked
+type claims struct {
+	Issuer     string          `json:"iss"`
+	Audience   json.RawMessage `json:"aud"`
+	Expires    int64           `json:"exp"`
+	NotBefore  int64           `json:"nbf"`
+	ServiceURL string          `json:"serviceurl"`
+}
+
+// audience tells if the aud claim, a string or a list, includes id
+func (c claims) audience(id string) bool {
+	var one string
+	if json.Unmarshal(c.Audience, &one) == nil {
+		return one == id
+	}
+	var many []string
+	json.Unmarshal(c.Audience, &many)
+	for _, a := range many {
+		if a == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify checks the Authorization header of a request carrying an activity
+// of serviceURL, returns ErrUnauthorized if it isn't valid
+func (v *Verifier) Verify(authorization, serviceURL string, now time.Time) error {
+	token := strings.TrimPrefix(authorization, "Bearer ")
+	parts := strings.Split(token, ".")
+	if token == authorization || len(parts) != 3 {
+		return ErrUnauthorized
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "RS256" {
+		return ErrUnauthorized
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return ErrUnauthorized
+	}
+	key, err := v.key(header.Kid)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], sig) != nil {
+		return ErrUnauthorized
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return ErrUnauthorized
+	}
+	switch {
+	case c.Issuer != botFrameworkIssuer, !c.audience(v.AppID):
+		return ErrUnauthorized
+	case now.After(time.Unix(c.Expires, 0).Add(clockSkew)):
+		return ErrUnauthorized
+	case c.NotBefore != 0 && now.Add(clockSkew).Before(time.Unix(c.NotBefore, 0)):
+		return ErrUnauthorized
+	case c.ServiceURL != "" && c.ServiceURL != serviceURL:
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// decodeSegment decodes a JSON segment of a JWT
+func decodeSegment(s string, q interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, q)
+}
@@ -0,0 +1,170 @@
+package teams
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/chat"
+)
+
+// Prefix tags the users, the conversations and the tenants of Teams, to keep
+// them apart from the Slack ones
+const Prefix = "teams:"
+
+// ErrUnknownUser is returned for the users other than the sender of the
+// activity, the bot can't look them up
+var ErrUnknownUser = errors.New("teams: unknown user")
+
+// Platform is the Teams adapter of chat.Platform. It answers the activity
+// received by ParseEvent, the bot is created for each of them.
+type Platform struct {
+	Client   *Client
+	Verifier *Verifier
+	// Activity is the activity received, set by ParseEvent
+	Activity *Activity
+}
+
+// NewPlatform returns the platform of the bot registered with TEAMS_APP_ID
+// and TEAMS_APP_PASSWORD, nil if they aren't set
+func NewPlatform() *Platform {
+	c := NewFromEnv()
+	if c == nil {
+		return nil
+	}
+	return &Platform{Client: c, Verifier: NewVerifier(c.AppID)}
+}
+
+// Team returns the tenant of the activity, as the team of the bot
+func (p *Platform) Team() string {
+	return Prefix + p.Activity.Conversation.TenantID
+}
+
+// conversation returns the conversation of Teams of channel
+func conversation(channel string) string {
+	return strings.TrimPrefix(channel, Prefix)
+}
+
+// sender returns the id of the user sending the activity, her Azure AD
+// object so that she's the same in every conversation
+func sender(a *Activity) string {
+	if a.From.AADObjectID != "" {
+		return Prefix + a.From.AADObjectID
+	}
+	return Prefix + a.From.ID
+}
+
+// ParseEvent implements chat.Platform, verifying that the activity was sent
+// by the Bot Framework: ErrUnauthorized is returned if it wasn't
+func (p *Platform) ParseEvent(r *http.Request) (*chat.Event, error) {
+	var a Activity
+	if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+		return nil, err
+	}
+	if err := p.Verifier.Verify(r.Header.Get("Authorization"), a.ServiceURL, time.Now()); err != nil {
+		return nil, err
+	}
+	p.Activity = &a
+	if a.Type != MessageActivity {
+		return nil, nil
+	}
+	text := CommandText(&a)
+	if text == "" {
+		return nil, nil
+	}
+	return &chat.Event{Channel: Prefix + a.Conversation.ID, User: sender(&a), Text: text}, nil
+}
+
+// SendMessage implements chat.Platform, converting the Slack formatting of
+// text. In the conversation of the activity it's sent as a reply to it. The
+// blocks aren't shown, text is.
+func (p *Platform) SendMessage(channel, thread, text string, blocks ...chat.Block) (string, error) {
+	conv := conversation(channel)
+	if conv == p.Activity.Conversation.ID {
+		reply := Reply(p.Activity, FromSlack(text))
+		return p.Client.post(p.Activity.ServiceURL, conv, p.Activity.ID, reply)
+	}
+	reply := Activity{Type: MessageActivity, From: p.Activity.Recipient, Text: FromSlack(text), TextFormat: "markdown"}
+	return p.Client.post(p.Activity.ServiceURL, conv, thread, reply)
+}
+
+// SendEphemeral implements chat.Platform: Teams has no messages visible to
+// a single user, text is sent in the conversation
+func (p *Platform) SendEphemeral(channel, thread, userID, text string, blocks ...chat.Block) error {
+	_, err := p.SendMessage(channel, thread, text)
+	return err
+}
+
+// UpdateMessage implements chat.Platform
+func (p *Platform) UpdateMessage(channel, id, text string, blocks ...chat.Block) error {
+	act := Activity{Type: MessageActivity, From: p.Activity.Recipient, Text: FromSlack(text), TextFormat: "markdown"}
+	return p.Client.update(p.Activity.ServiceURL, conversation(channel), id, act)
+}
+
+// DirectChannel implements chat.Platform for the sender of the activity:
+// the bot can't start new chats, it answers in the conversation of the
+// activity
+func (p *Platform) DirectChannel(userID string) (string, error) {
+	if userID != sender(p.Activity) {
+		return "", ErrUnknownUser
+	}
+	return Prefix + p.Activity.Conversation.ID, nil
+}
+
+// UserInfo implements chat.Platform for the sender of the activity
+func (p *Platform) UserInfo(userID string) (*chat.User, error) {
+	if userID != sender(p.Activity) {
+		return nil, ErrUnknownUser
+	}
+	name := p.Activity.From.Name
+	return &chat.User{ID: userID, Name: name, DisplayName: name}, nil
+}
+
+// Users implements chat.Platform: the bot can't list the users of a tenant
+func (p *Platform) Users() ([]chat.User, error) {
+	return nil, chat.ErrUnsupported
+}
+
+// ChannelMembers implements chat.Platform: the bot can't list the members
+// of a conversation
+func (p *Platform) ChannelMembers(channel string) ([]string, error) {
+	return nil, chat.ErrUnsupported
+}
+
+// AddReaction implements chat.Platform: the bot can't react on Teams
+func (p *Platform) AddReaction(channel, id, emoji string) error {
+	return chat.ErrUnsupported
+}
+
+// Pin implements chat.Platform: Teams has no pins
+func (p *Platform) Pin(channel, id string) error {
+	return chat.ErrUnsupported
+}
+
+// Unpin implements chat.Platform: Teams has no pins
+func (p *Platform) Unpin(channel, id string) error {
+	return chat.ErrUnsupported
+}
+
+// Pinned implements chat.Platform: Teams has no pins
+func (p *Platform) Pinned(channel string) ([]chat.Message, error) {
+	return nil, chat.ErrUnsupported
+}
+
+// UploadFile implements chat.Platform: the bot doesn't share files on Teams
+func (p *Platform) UploadFile(channel string, f chat.File, data []byte) error {
+	return chat.ErrUnsupported
+}
+
+// FileInfo implements chat.Platform: the bot doesn't receive files on Teams
+func (p *Platform) FileInfo(id string) (*chat.File, error) {
+	return nil, chat.ErrUnsupported
+}
+
+// DownloadFile implements chat.Platform: the bot doesn't receive files on
+// Teams
+func (p *Platform) DownloadFile(f *chat.File) ([]byte, error) {
+	return nil, chat.ErrUnsupported
+}
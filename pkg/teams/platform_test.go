@@ -0,0 +1,65 @@
+package teams
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPlatform(t *testing.T) {
+	var path string
+	var sent Activity
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "tok", "expires_in": 3600})
+			return
+		}
+		path = r.Method + " " + r.URL.Path
+		json.NewDecoder(r.Body).Decode(&sent)
+		json.NewEncoder(w).Encode(map[string]string{"id": "m2"})
+	}))
+	defer srv.Close()
+	tokenURL = srv.URL + "/token"
+
+	p := &Platform{
+		Client: &Client{AppID: "app", Password: "pw", HTTP: srv.Client()},
+		Activity: &Activity{
+			ID:           "m1",
+			ServiceURL:   srv.URL,
+			From:         Account{ID: "29:1", Name: "Mario Rossi", AADObjectID: "aad1"},
+			Recipient:    Account{ID: "28:bot", Name: "Tina"},
+			Conversation: Conversation{ID: "c1", TenantID: "t1"},
+		},
+	}
+
+	u, err := p.UserInfo("teams:aad1")
+	if err != nil || u.Name != "Mario Rossi" {
+		t.Fatalf("got %v, %v", u, err)
+	}
+	if _, err := p.UserInfo("teams:aad2"); err != ErrUnknownUser {
+		t.Errorf("unknown user found: %v", err)
+	}
+	ch, err := p.DirectChannel("teams:aad1")
+	if err != nil || ch != "teams:c1" {
+		t.Errorf("got %q, %v", ch, err)
+	}
+	if p.Team() != "teams:t1" {
+		t.Errorf("got team %q", p.Team())
+	}
+
+	id, err := p.SendMessage(ch, "", "*Ok*")
+	if err != nil || id != "m2" {
+		t.Fatalf("got %q, %v", id, err)
+	}
+	if path != "POST /v3/conversations/c1/activities/m1" || sent.Text != "**Ok**" || sent.Recipient.ID != "29:1" {
+		t.Errorf("sent %s %+v", path, sent)
+	}
+
+	if err := p.UpdateMessage(ch, "m2", "Chiuso"); err != nil {
+		t.Fatal(err)
+	}
+	if path != "PUT /v3/conversations/c1/activities/m2" || sent.Text != "Chiuso" {
+		t.Errorf("sent %s %+v", path, sent)
+	}
+}
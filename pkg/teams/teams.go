@@ -0,0 +1,266 @@
+// Package teams connects the bot to Microsoft Teams through the Bot
+// Framework: it receives the activities sent by Teams to the messaging
+// endpoint and answers them through the Bot Connector API.
+package teams
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Account is a user or a bot in a conversation
+type Account struct {
+	ID          string `json:"id"`
+	Name        string `json:"name,omitempty"`
+	AADObjectID string `json:"aadObjectId,omitempty"`
+}
+
+// Conversation is the chat, channel or group chat of an activity
+type Conversation struct {
+	ID       string `json:"id"`
+	IsGroup  bool   `json:"isGroup,omitempty"`
+	TenantID string `json:"tenantId,omitempty"`
+}
+
+// Activity is a message or an event exchanged with the Bot Framework, only
+// the fields used by the bot
+type Activity struct {
+	Type         string          `json:"type"`
+	ID           string          `json:"id,omitempty"`
+	ServiceURL   string          `json:"serviceUrl,omitempty"`
+	ChannelID    string          `json:"channelId,omitempty"`
+	From         Account         `json:"from"`
+	Recipient    Account         `json:"recipient"`
+	Conversation Conversation    `json:"conversation"`
+	Text         string          `json:"text,omitempty"`
+	TextFormat   string          `json:"textFormat,omitempty"`
+	ReplyToID    string          `json:"replyToId,omitempty"`
+	Value        json.RawMessage `json:"value,omitempty"`
+	Attachments  []Attachment    `json:"attachments,omitempty"`
+}
+
+// Attachment is a card sent with a message
+type Attachment struct {
+	ContentType string      `json:"contentType"`
+	Content     interface{} `json:"content"`
+}
+
+// MessageActivity is the type of the messages, the only activities handled
+const MessageActivity = "message"
+
+// mentionRe matches the mentions of the bot in the text of the messages
+var mentionRe = regexp.MustCompile(`(?s)<at>.*?</at>`)
+
+// submitData is the data of an Adaptive Card Action.Submit: the command it
+// runs, as if typed by the user
+type submitData struct {
+	Command string `json:"command"`
+}
+
+// CommandText returns the command sent by the user with the activity: the
+// text of the message without the mentions of the bot, or the command of the
+// card action she clicked
+func CommandText(a *Activity) string {
+	if len(a.Value) > 0 {
+		var d submitData
+		if json.Unmarshal(a.Value, &d) == nil && d.Command != "" {
+			return strings.TrimSpace(d.Command)
+		}
+	}
+	return strings.TrimSpace(mentionRe.ReplaceAllString(a.Text, ""))
+}
+
+// Reply returns the activity answering a with text
+func Reply(a *Activity, text string) Activity {
+	return Activity{
+		Type:         MessageActivity,
+		From:         a.Recipient,
+		Recipient:    a.From,
+		Conversation: a.Conversation,
+		Text:         text,
+		TextFormat:   "markdown",
+		ReplyToID:    a.ID,
+	}
+}
+
+// The Slack formatting converted to the markdown of Teams
+var (
+	slackBoldRe = regexp.MustCompile(`(^|[\s(])\*([^*\n]+)\*`)
+	slackLinkRe = regexp.MustCompile(`<((?:https?|mailto):[^|>]+)\|([^>]+)>`)
+)
+
+// FromSlack converts the text of an answer formatted for Slack to the
+// markdown of Teams: bold, links and line breaks
+func FromSlack(text string) string {
+	text = slackBoldRe.ReplaceAllString(text, "$1**$2**")
+	text = slackLinkRe.ReplaceAllString(text, "[$2]($1)")
+	return strings.Replace(text, "\n", "\n\n", -1)
+}
+
+// Button is a button of a card running Command when clicked, see
+// CommandText
+type Button struct {
+	Title   string
+	Command string
+}
+
+type cardAction struct {
+	Type  string     `json:"type"`
+	Title string     `json:"title"`
+	Data  submitData `json:"data"`
+}
+
+// ActionCard returns an Adaptive Card with text and the buttons, so that
+// the user can run the commands by clicking them
+func ActionCard(text string, buttons []Button) Attachment {
+	var actions []cardAction
+	for _, b := range buttons {
+		actions = append(actions, cardAction{"Action.Submit", b.Title, submitData{b.Command}})
+	}
+	return Attachment{
+		ContentType: "application/vnd.microsoft.card.adaptive",
+		Content: map[string]interface{}{
+			"type":    "AdaptiveCard",
+			"version": "1.2",
+			"body":    []interface{}{map[string]interface{}{"type": "TextBlock", "text": text, "wrap": true}},
+			"actions": actions,
+		},
+	}
+}
+
+// tokenURL is where the bot gets the token to call the Bot Connector API
+var tokenURL = "https://login.microsoftonline.com/botframework.com/oauth2/v2.0/token"
+
+// Client sends the activities of the bot to Teams
+type Client struct {
+	AppID    string
+	Password string
+	HTTP     *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewFromEnv returns the client of the bot registered with TEAMS_APP_ID and
+// TEAMS_APP_PASSWORD, nil if they aren't set
+func NewFromEnv() *Client {
+	id, password := os.Getenv("TEAMS_APP_ID"), os.Getenv("TEAMS_APP_PASSWORD")
+	if id == "" || password == "" {
+		return nil
+	}
+	return &Client{AppID: id, Password: password, HTTP: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// accessToken returns the token of the bot, asking for a new one when the
+// current one is about to expire
+func (c *Client) accessToken() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Before(c.expires) {
+		return c.token, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {c.AppID},
+		"client_secret": {c.Password},
+		"scope":         {"https://api.botframework.com/.default"},
+	}
+	resp, err := c.HTTP.PostForm(tokenURL, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("teams token: %s", resp.Status)
+	}
+	var t struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return "", err
+	}
+	if t.AccessToken == "" {
+		return "", errors.New("teams token: empty token")
+	}
+	c.token = t.AccessToken
+	// renewed a bit before it expires
+	c.expires = time.Now().Add(time.Duration(t.ExpiresIn)*time.Second - time.Minute)
+	return c.token, nil
+}
+
+// Send posts the activity in the conversation of a on its service, as a
+// reply to a
+func (c *Client) Send(a *Activity, reply Activity) error {
+	_, err := c.post(a.ServiceURL, a.Conversation.ID, a.ID, reply)
+	return err
+}
+
+// activitiesURL returns the address of the activities of a conversation on
+// serviceURL, or of the activity id if not empty
+func activitiesURL(serviceURL, conversation, id string) string {
+	u := strings.TrimSuffix(serviceURL, "/") + "/v3/conversations/" + url.PathEscape(conversation) + "/activities"
+	if id != "" {
+		u += "/" + url.PathEscape(id)
+	}
+	return u
+}
+
+// post sends the activity in conversation, as a reply to replyTo if not
+// empty, and returns its id
+func (c *Client) post(serviceURL, conversation, replyTo string, act Activity) (string, error) {
+	return c.do("POST", activitiesURL(serviceURL, conversation, replyTo), act)
+}
+
+// update replaces the activity id of conversation with act
+func (c *Client) update(serviceURL, conversation, id string, act Activity) error {
+	act.ID = id
+	_, err := c.do("PUT", activitiesURL(serviceURL, conversation, id), act)
+	return err
+}
+
+// do calls the Bot Connector API with the activity, returning the id of the
+// activity sent
+func (c *Client) do(method, u string, act Activity) (string, error) {
+	token, err := c.accessToken()
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(act)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("teams send: %s %s", resp.Status, msg)
+	}
+	var res struct {
+		ID string `json:"id"`
+	}
+	// the id is missing in the answers of some methods
+	json.NewDecoder(resp.Body).Decode(&res)
+	return res.ID, nil
+}
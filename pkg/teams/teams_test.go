@@ -0,0 +1,121 @@
+package teams
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCommandText(t *testing.T) {
+	a := Activity{Text: "<at>Tina</at> per me risotto "}
+	if got := CommandText(&a); got != "per me risotto" {
+		t.Errorf("got %q", got)
+	}
+	a.Value = json.RawMessage(`{"command": "per me pollo"}`)
+	if got := CommandText(&a); got != "per me pollo" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestFromSlack(t *testing.T) {
+	got := FromSlack("*Quota:*\nmario: €5 <mailto:a@b.it|Link>")
+	want := "**Quota:**\n\nmario: €5 [Link](mailto:a@b.it)"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func sign(t *testing.T, key *rsa.PrivateKey, kid string, c map[string]interface{}) string {
+	enc := func(v interface{}) string {
+		b, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	unsigned := enc(map[string]string{"alg": "RS256", "kid": kid}) + "." + enc(c)
+	hash := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "Bearer " + unsigned + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestVerify(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var srv *httptest.Server
+	fetches := 0
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/config" {
+			fetches++
+			fmt.Fprintf(w, `{"jwks_uri": "%s/keys"}`, srv.URL)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []jwk{{
+			Kid: "k1",
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	defer srv.Close()
+
+	v := NewVerifier("app")
+	v.ConfigURL = srv.URL + "/config"
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":        botFrameworkIssuer,
+		"aud":        "app",
+		"exp":        now.Add(time.Hour).Unix(),
+		"serviceurl": "https://smba.example.com/",
+	}
+
+	if err := v.Verify(sign(t, key, "k1", claims), "https://smba.example.com/", now); err != nil {
+		t.Errorf("valid token refused: %v", err)
+	}
+	if err := v.Verify(sign(t, key, "k1", claims), "https://evil.example.com/", now); err != ErrUnauthorized {
+		t.Errorf("token of another service accepted: %v", err)
+	}
+	if err := v.Verify(sign(t, key, "k1", claims), "https://smba.example.com/", now.Add(2*time.Hour)); err != ErrUnauthorized {
+		t.Errorf("expired token accepted: %v", err)
+	}
+	if err := v.Verify(sign(t, key, "k2", claims), "https://smba.example.com/", now); err != ErrUnauthorized {
+		t.Errorf("token of an unknown key accepted: %v", err)
+	}
+	// the keys are downloaded again at most every refetchInterval, and the
+	// unknown keys are remembered
+	if err := v.Verify(sign(t, key, "k3", claims), "https://smba.example.com/", now); err != ErrUnauthorized || fetches != 1 {
+		t.Errorf("keys downloaded %d times for the unknown keys: %v", fetches, err)
+	}
+	v.tried = v.tried.Add(-refetchInterval)
+	v.Verify(sign(t, key, "k3", claims), "https://smba.example.com/", now)
+	if fetches != 1 {
+		t.Errorf("keys downloaded %d times for a key known to be missing", fetches)
+	}
+	v.Verify(sign(t, key, "k4", claims), "https://smba.example.com/", now)
+	if fetches != 2 {
+		t.Errorf("keys downloaded %d times for a new key", fetches)
+	}
+	// the expired keys are used until they can be downloaded again
+	v.fetched = v.fetched.Add(-keysTTL)
+	if err := v.Verify(sign(t, key, "k1", claims), "https://smba.example.com/", now); err != nil || fetches != 2 {
+		t.Errorf("expired key not used, %d downloads: %v", fetches, err)
+	}
+	claims["aud"] = "other"
+	if err := v.Verify(sign(t, key, "k1", claims), "https://smba.example.com/", now); err != ErrUnauthorized {
+		t.Errorf("token of another bot accepted: %v", err)
+	}
+	if err := v.Verify("Bearer nope", "https://smba.example.com/", now); err != ErrUnauthorized {
+		t.Errorf("malformed token accepted: %v", err)
+	}
+}
@@ -0,0 +1,396 @@
+package tinabot
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/develersrl/lunches/pkg/analytics"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// accountingColumns are the columns the report of the accounting can have
+var accountingColumns = []string{"data", "utente", "nome", "centro", "importo", "azienda", "dipendente", "pagato_da", "piatti"}
+
+func validColumn(c string) bool {
+	for _, x := range accountingColumns {
+		if x == c {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultAccountingColumns are the columns of the report if none are set
+var DefaultAccountingColumns = []string{"data", "utente", "nome", "centro", "importo", "azienda", "dipendente"}
+
+// CostCenter is the cost center of a user, with the name for the report
+type CostCenter struct {
+	Name   string
+	Center string
+}
+
+// Accounting configures the monthly report of the cost of the lunches of
+// each user, for the expense tool: the CSV columns, the cost centers of the
+// users and the addresses it's sent to
+type Accounting struct {
+	Columns   []string
+	Separator string `json:",omitempty"` // "," if empty
+	// Centers are the cost centers by userKey, the others are in Default
+	Centers   map[string]CostCenter
+	Default   string
+	Addresses []string
+
+	// the month of the last report sent, "2006-01"
+	Sent string `json:",omitempty"`
+}
+
+// Load loads the settings of the accounting from brain
+func (a *Accounting) Load(brain DataStore) error {
+	if err := brain.Get("accounting", a); err != nil {
+		*a = Accounting{}
+		return err
+	}
+	return nil
+}
+
+// Save saves the settings of the accounting to brain
+func (a *Accounting) Save(brain DataStore) error {
+	return brain.Set("accounting", *a)
+}
+
+func (a *Accounting) columns() []string {
+	if len(a.Columns) == 0 {
+		return DefaultAccountingColumns
+	}
+	return a.Columns
+}
+
+// AccountingRow is the lunch of a user in a day
+type AccountingRow struct {
+	Date     time.Time
+	User     User
+	Center   string
+	Amount   decimal.Decimal
+	Company  decimal.Decimal
+	Personal decimal.Decimal
+	PaidBy   string
+	Dishes   []string
+}
+
+// monthKey returns the month of date, "2006-01"
+func monthKey(date time.Time) string {
+	return date.Format("2006-01")
+}
+
+// Rows returns the lunches of the users in the month of date from the
+// history of the orders, sorted by day and name. The names come from the
+// cost centers, the history or the ledger, the payers from the ledger, the
+// split with the company from the current policy.
+func (a *Accounting) Rows(brain DataStore, month time.Time) ([]AccountingRow, error) {
+	return a.rows(brain, analytics.Month(month))
+}
+
+// rows returns the lunches of the users in the period, like Rows
+func (a *Accounting) rows(brain DataStore, period analytics.Period) ([]AccountingRow, error) {
+	ids, err := historyRepo(brain).IDs()
+	if err != nil {
+		return nil, err
+	}
+	var ledger Ledger
+	ledger.Load(brain)
+	var policy Policy
+	policy.Load(brain)
+
+	names, _ := nameRepo(brain).All()
+	if names == nil {
+		names = make(map[string]string)
+	}
+	payers := make(map[string]string)
+	for _, e := range ledger.Entries {
+		// the ledger knows the names of the history saved before them
+		for _, u := range []User{e.Debtor, e.Creditor} {
+			if _, ok := names[userKey(u)]; !ok {
+				names[userKey(u)] = u.Name
+			}
+		}
+		if e.Kind == LedgerOrder {
+			payers[historyID(e.Debtor, e.Date)] = e.Creditor.Name
+		}
+	}
+
+	// the days of the period are read all together
+	dates := make(map[string]time.Time)
+	var inPeriod []string
+	for _, id := range ids {
+		i := strings.LastIndex(id, ":")
+		if i < 0 {
+			continue
+		}
+		date, err := time.ParseInLocation("2006-01-02", id[i+1:], period.Start.Location())
+		if err != nil || !period.Contains(date) {
+			continue
+		}
+		dates[id] = date
+		inPeriod = append(inPeriod, id)
+	}
+	history, err := historyRepo(brain).GetMany(inPeriod...)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []AccountingRow
+	for _, id := range inPeriod {
+		choices, ok := history[id]
+		if !ok {
+			continue
+		}
+		key, date := id[:strings.LastIndex(id, ":")], dates[id]
+
+		row := AccountingRow{Date: date, User: User{key, key}, Center: a.Default, Amount: choices.Price(), PaidBy: payers[id]}
+		if name, ok := names[key]; ok {
+			row.User.Name = name
+		}
+		if c, ok := a.Centers[key]; ok {
+			row.User.Name = c.Name
+			row.Center = c.Center
+		}
+		row.Company, row.Personal = policy.Split(row.Amount)
+		for _, c := range choices {
+			row.Dishes = append(row.Dishes, c.String())
+		}
+		rows = append(rows, row)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].Date.Equal(rows[j].Date) {
+			return rows[i].Date.Before(rows[j].Date)
+		}
+		return strings.ToLower(rows[i].User.Name) < strings.ToLower(rows[j].User.Name)
+	})
+	return rows, nil
+}
+
+// value returns the column of the row
+func (r AccountingRow) value(column string) string {
+	switch column {
+	case "data":
+		return dayKey(r.Date)
+	case "utente":
+		return r.User.ID
+	case "nome":
+		return r.User.Name
+	case "centro":
+		return r.Center
+	case "importo":
+		return r.Amount.StringFixed(2)
+	case "azienda":
+		return r.Company.StringFixed(2)
+	case "dipendente":
+		return r.Personal.StringFixed(2)
+	case "pagato_da":
+		return r.PaidBy
+	case "piatti":
+		return strings.Join(r.Dishes, ", ")
+	}
+	return ""
+}
+
+// WriteCSV writes the report of rows with the columns and the separator of
+// the accounting, with a header
+func (a *Accounting) WriteCSV(w io.Writer, rows []AccountingRow) error {
+	cw := csv.NewWriter(w)
+	if a.Separator != "" {
+		cw.Comma, _ = utf8.DecodeRuneInString(a.Separator)
+	}
+	columns := a.columns()
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		record := make([]string, len(columns))
+		for i, c := range columns {
+			record[i] = r.value(c)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Report returns the CSV report of the month of date
+func (a *Accounting) Report(brain DataStore, month time.Time) ([]byte, int, error) {
+	rows, err := a.Rows(brain, month)
+	if err != nil {
+		return nil, 0, err
+	}
+	var buf bytes.Buffer
+	err = a.WriteCSV(&buf, rows)
+	return buf.Bytes(), len(rows), err
+}
+
+// Due returns the month whose report is to be sent at now, the previous
+// one once it's over, false if it was sent already or there's nobody to send
+// it to
+func (a *Accounting) Due(now time.Time) (time.Time, bool) {
+	if len(a.Addresses) == 0 {
+		return time.Time{}, false
+	}
+	prev := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, -1, 0)
+	return prev, a.Sent != monthKey(prev)
+}
+
+func (a *Accounting) String() string {
+	r := []string{"Colonne del report: " + strings.Join(a.columns(), a.separator())}
+	if a.Default != "" {
+		r = append(r, "Centro di costo predefinito: "+a.Default)
+	}
+	var centers []string
+	for _, c := range a.Centers {
+		centers = append(centers, fmt.Sprintf("%s: %s", c.Name, c.Center))
+	}
+	sort.Strings(centers)
+	if len(centers) > 0 {
+		r = append(r, "Centri di costo:\n"+strings.Join(centers, "\n"))
+	}
+	if len(a.Addresses) > 0 {
+		r = append(r, "Il report del mese viene mandato a "+strings.Join(a.Addresses, ", "))
+	} else {
+		r = append(r, "Il report del mese non viene mandato a nessuno")
+	}
+	return strings.Join(r, "\n")
+}
+
+func (a *Accounting) separator() string {
+	if a.Separator == "" {
+		return ","
+	}
+	return a.Separator
+}
+
+// parseMonth returns the month "aaaa-mm" in the time zone of now, now if s
+// is empty
+func parseMonth(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return now, nil
+	}
+	return time.ParseInLocation("2006-01", s, now.Location())
+}
+
+// AccountingCmd shows or changes the settings of the monthly report of the
+// costs, or uploads the report of a month on the channel
+func (t *TinaBot) AccountingCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	var a Accounting
+	a.Load(t.brain)
+
+	f := strings.Fields(args[1])
+	if len(f) == 0 {
+		t.bot.Message(msg.Channel, a.String())
+		return
+	}
+	if !t.canAdmin(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono gestire la contabilità")
+		return
+	}
+
+	value := strings.Join(f[1:], " ")
+	switch strings.ToLower(f[0]) {
+	case "esporta":
+		t.uploadAccounting(msg, &a, value)
+		return
+	case "colonne":
+		var columns []string
+		for _, c := range strings.Split(strings.ToLower(value), ",") {
+			c = strings.TrimSpace(c)
+			if !validColumn(c) {
+				t.bot.Message(msg.Channel, fmt.Sprintf("Colonna '%s' sconosciuta, usa %s", c, strings.Join(accountingColumns, ", ")))
+				return
+			}
+			columns = append(columns, c)
+		}
+		a.Columns = columns
+	case "separatore":
+		if utf8.RuneCountInString(value) != 1 {
+			t.bot.Message(msg.Channel, "Il separatore deve essere un carattere, ad esempio `;`")
+			return
+		}
+		a.Separator = value
+	case "centro":
+		if len(f) < 3 {
+			t.bot.Message(msg.Channel, "Comando non valido, usa `contabilità centro <centro> <utente>...` o `contabilità centro predefinito <centro>`")
+			return
+		}
+		if strings.ToLower(f[1]) == "predefinito" {
+			a.Default = strings.Join(f[2:], " ")
+			break
+		}
+		if a.Centers == nil {
+			a.Centers = make(map[string]CostCenter)
+		}
+		for _, name := range f[2:] {
+			u := getUserInfo(t.bot.Platform, name)
+			if u == nil {
+				t.bot.Message(msg.Channel, fmt.Sprintf("Utente '%s' non trovato", name))
+				return
+			}
+			a.Centers[u.ID] = CostCenter{u.Name, f[1]}
+		}
+	case "aggiungi":
+		addr := parseEmail(value)
+		if !strings.Contains(addr, "@") {
+			t.bot.Message(msg.Channel, "Indirizzo email non valido")
+			return
+		}
+		a.Addresses = append(a.Addresses, addr)
+	case "togli":
+		addr := parseEmail(value)
+		var rest []string
+		for _, x := range a.Addresses {
+			if !strings.EqualFold(x, addr) {
+				rest = append(rest, x)
+			}
+		}
+		a.Addresses = rest
+	default:
+		t.bot.Message(msg.Channel, "Comando non valido, vedi `aiuto contabilità`")
+		return
+	}
+
+	if err := a.Save(t.brain); err != nil {
+		t.bot.Message(msg.Channel, "Errore nel salvare la contabilità: "+err.Error())
+		return
+	}
+	t.bot.Message(msg.Channel, "Ok\n"+a.String())
+}
+
+// uploadAccounting uploads on the channel the report of the month, the
+// current one if empty
+func (t *TinaBot) uploadAccounting(msg *slackbot.BotMsg, a *Accounting, month string) {
+	date, err := parseMonth(month, time.Now())
+	if err != nil {
+		t.bot.Message(msg.Channel, "Mese non valido, usa il formato aaaa-mm")
+		return
+	}
+	report, n, err := a.Report(t.brain, date)
+	if err != nil {
+		t.bot.Message(msg.Channel, "Errore nel preparare il report: "+err.Error())
+		return
+	}
+	if n == 0 {
+		t.bot.Message(msg.Channel, "Nessun pranzo registrato in "+monthKey(date))
+		return
+	}
+	f := chat.File{Name: "pranzi-" + monthKey(date) + ".csv", Type: "csv", Title: "Pranzi di " + monthKey(date)}
+	if err := t.bot.Platform.UploadFile(msg.Channel, f, report); err != nil {
+		t.bot.Message(msg.Channel, "Errore nel caricare il file: "+err.Error())
+	}
+}
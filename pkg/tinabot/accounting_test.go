@@ -0,0 +1,82 @@
+package tinabot
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestAccounting(t *testing.T) {
+	b := brain.NewBrainMock()
+	payer := User{"payer", "U1"}
+	u1 := User{"mario", "U2"}
+	guest := User{"ospite", ""}
+
+	var p, s UserChoice
+	p.Add(tuttobene.MenuRow{Content: "primo", Type: tuttobene.Primo, Price: decimal.New(5, 0)})
+	s.Add(tuttobene.MenuRow{Content: "secondo", Type: tuttobene.Secondo, Price: decimal.New(7, 0)})
+
+	march := time.Date(2019, 3, 12, 13, 0, 0, 0, time.UTC)
+	order := NewOrder()
+	order.Timestamp = march
+	order.Set(payer, []UserChoice{p})
+	order.Set(u1, []UserChoice{p, s})
+	order.Set(guest, []UserChoice{s})
+	assertEqual(t, SaveHistory(b, order), nil, "")
+
+	april := NewOrder()
+	april.Timestamp = march.AddDate(0, 1, 0)
+	april.Set(u1, []UserChoice{s})
+	assertEqual(t, SaveHistory(b, april), nil, "")
+
+	var l Ledger
+	l.AddOrder(payer, order, march)
+	assertEqual(t, l.Save(b), nil, "")
+	policy := Policy{Subsidy: decimal.New(6, 0)}
+	assertEqual(t, policy.Save(b), nil, "")
+
+	a := Accounting{
+		Columns:   []string{"data", "utente", "nome", "centro", "importo", "azienda", "dipendente", "pagato_da"},
+		Separator: ";",
+		Centers:   map[string]CostCenter{"U2": {"Mario Rossi", "R&D"}},
+		Default:   "Generale",
+	}
+	rows, err := a.Rows(b, march)
+	assertEqual(t, err, nil, "")
+	assertEqual(t, len(rows), 3, "")
+
+	var buf bytes.Buffer
+	assertEqual(t, a.WriteCSV(&buf, rows), nil, "")
+	assertEqual(t, buf.String(), "data;utente;nome;centro;importo;azienda;dipendente;pagato_da\n"+
+		"2019-03-12;U2;Mario Rossi;R&D;12.00;6.00;6.00;payer\n"+
+		"2019-03-12;ospite;ospite;Generale;7.00;6.00;1.00;payer\n"+
+		"2019-03-12;U1;payer;Generale;5.00;5.00;0.00;\n", "")
+
+	rows, _ = a.Rows(b, april.Timestamp)
+	assertEqual(t, len(rows), 1, "")
+	assertEqual(t, rows[0].Amount.String(), "7", "")
+}
+
+func TestAccountingDue(t *testing.T) {
+	now := time.Date(2019, 4, 2, 10, 0, 0, 0, time.UTC)
+	var a Accounting
+	_, due := a.Due(now)
+	assertEqual(t, due, false, "no addresses")
+
+	a.Addresses = []string{"spese@example.com"}
+	month, due := a.Due(now)
+	assertEqual(t, due, true, "")
+	assertEqual(t, monthKey(month), "2019-03", "")
+
+	a.Sent = "2019-03"
+	_, due = a.Due(now)
+	assertEqual(t, due, false, "already sent")
+
+	month, _ = a.Due(time.Date(2020, 1, 5, 0, 0, 0, 0, time.UTC))
+	assertEqual(t, monthKey(month), "2019-12", "")
+}
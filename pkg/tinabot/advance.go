@@ -0,0 +1,257 @@
+package tinabot
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/matcher"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// maxAdvanceDays is how far in the future orders can be placed
+const maxAdvanceDays = 14
+
+// dayRetention is how long the menu and the order of a day are kept after it
+const dayRetention = 30 * 24 * time.Hour
+
+func dayKey(date time.Time) string {
+	return date.Format("2006-01-02")
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// formatDay returns the name of the day and the date, e.g. "giovedì 14/03"
+func formatDay(date time.Time) string {
+	return weekNames[date.Weekday()] + " " + date.Format("02/01")
+}
+
+// parseDay returns the future date named by s, which can be "domani", a
+// day of the week, meaning the next one, or a date like "14/03"
+func parseDay(s string, now time.Time) (time.Time, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+
+	switch s {
+	case "domani":
+		return today.AddDate(0, 0, 1), true
+	case "dopodomani":
+		return today.AddDate(0, 0, 2), true
+	}
+
+	name := strings.NewReplacer("ì", "i", "í", "i").Replace(s)
+	for i, w := range weekNames {
+		if name == strings.Replace(w, "ì", "i", -1) {
+			days := (i - int(today.Weekday()) + 7) % 7
+			if days == 0 {
+				days = 7
+			}
+			return today.AddDate(0, 0, days), true
+		}
+	}
+
+	if date, err := time.ParseInLocation("02/01", s, now.Location()); err == nil {
+		date = time.Date(y, date.Month(), date.Day(), 0, 0, 0, 0, now.Location())
+		if !date.After(today) {
+			date = date.AddDate(1, 0, 0)
+		}
+		return date, true
+	}
+	return time.Time{}, false
+}
+
+// expireAfter makes key expire dayRetention after date, if brain supports it
+func expireAfter(brain DataStore, key string, date time.Time) {
+	if s, ok := brain.(ExpiringStore); ok {
+		if err := s.ExpireAt(key, date.Add(dayRetention)); err != nil {
+			slog.Error("Error setting the expiration", "key", key, "err", err)
+		}
+	}
+}
+
+// SaveMenu stores the menu of its day, it also becomes the current menu
+// unless it's for a future day. Its dishes are added to the catalog and its
+// prices to the history.
+func SaveMenu(brain DataStore, m tuttobene.Menu) error {
+	menus := menuRepo(brain)
+	if err := menus.Put(dayKey(m.Date), m); err != nil {
+		return err
+	}
+	expireAfter(brain, menus.Key(dayKey(m.Date)), m.Date)
+	if err := recordDishes(brain, m); err != nil {
+		slog.Error("Error recording the dishes of the menu", "err", err)
+	}
+	if err := recordPrices(brain, m); err != nil {
+		slog.Error("Error recording the prices of the menu", "err", err)
+	}
+	if !m.IsUpdated() && m.Date.After(time.Now()) {
+		return nil
+	}
+	if err := brain.Set("menu", m); err != nil {
+		return err
+	}
+	// compiled now rather than on the first order
+	matcher.IndexOf(m)
+	return nil
+}
+
+// LoadMenu returns the menu of date, if known
+func LoadMenu(brain DataStore, date time.Time) (tuttobene.Menu, error) {
+	var m tuttobene.Menu
+	if err := brain.Get("menu", &m); err == nil && sameDay(m.Date, date) {
+		return m, nil
+	}
+	return menuRepo(brain).Get(dayKey(date))
+}
+
+// todayMenu returns the menu of today if known, the current one otherwise
+func todayMenu(brain DataStore) (tuttobene.Menu, error) {
+	if loc, err := time.LoadLocation("Europe/Rome"); err == nil {
+		if m, err := LoadMenu(brain, time.Now().In(loc)); err == nil {
+			return m, nil
+		}
+	}
+	var m tuttobene.Menu
+	err := brain.Get("menu", &m)
+	return m, err
+}
+
+// LoadAdvance returns the order placed in advance for date, a new one if there's none
+func LoadAdvance(brain DataStore, date time.Time) *Order {
+	order, err := advanceRepo(brain).Get(dayKey(date))
+	if err != nil || !sameDay(order.Timestamp, date) {
+		order = *NewOrder()
+		order.Timestamp = date
+	}
+	return &order
+}
+
+// SaveAdvanceCAS applies fn to the order placed in advance for date and
+// saves it, see SaveCAS
+func SaveAdvanceCAS(brain CASStore, date time.Time, order *Order, fn func(*Order) error) error {
+	key := advanceRepo(brain).Key(dayKey(date))
+	err := brain.Update(key, order, func() error {
+		if !sameDay(order.Timestamp, date) {
+			*order = *NewOrder()
+			order.Timestamp = date
+		}
+		if err := fn(order); err != nil {
+			return err
+		}
+		order.Version++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	expireAfter(brain, key, date)
+	return nil
+}
+
+// freshOrder returns the order for today placed in advance, if any, or a new one
+func freshOrder(brain DataStore) *Order {
+	order := NewOrder()
+	if advance, err := advanceRepo(brain).Get(dayKey(order.Timestamp)); err == nil && advance.IsUpdated() {
+		slog.Info("Using the order placed in advance")
+		return &advance
+	}
+	return order
+}
+
+// forDay places the order of user for a future date
+func (t *TinaBot) forDay(msg *slackbot.BotMsg, user *chat.User, date time.Time, dish string) {
+	me := User{user.Name, user.ID}
+	day := formatDay(date)
+
+	if date.Sub(time.Now()) > maxAdvanceDays*24*time.Hour {
+		t.bot.Message(msg.Channel, fmt.Sprintf("Mi spiace, si può ordinare al massimo %d giorni prima", maxAdvanceDays))
+		return
+	}
+
+	var c Calendar
+	c.Load(t.brain)
+	if closed, reason := c.IsClosed(date); closed {
+		t.bot.Message(msg.Channel, fmt.Sprintf("%s non si ordina il pranzo: %s", strings.Title(day), reason))
+		return
+	}
+
+	var choice []UserChoice
+	reply := ""
+	if strings.ToLower(dish) != "niente" {
+		menu, err := LoadMenu(t.brain, date)
+		if err != nil {
+			t.bot.Message(msg.Channel, fmt.Sprintf("Il menù di %s non è ancora disponibile", day))
+			return
+		}
+		choice, reply, err = t.parseOrder(menu, me, dish, LoadAdvance(t.brain, date).Users[me])
+		if err != nil {
+			t.bot.Message(msg.Channel, reply+err.Error())
+			return
+		}
+
+		var rules CompositionRules
+		rules.Load(t.brain)
+		if err := rules.Validate(choice); err != nil {
+			t.bot.Message(msg.Channel, reply+"Mi spiace, "+err.Error()+"\nOrdine non aggiunto!")
+			return
+		}
+	}
+
+	var groups DeliveryGroups
+	groups.Load(t.brain)
+
+	var order Order
+	err := SaveAdvanceCAS(t.brain, date, &order, func(o *Order) error {
+		o.SetBy(me, me, choice)
+		if g, ok := groups[userKey(me)]; ok {
+			o.SetGroup(me, g)
+		}
+		return nil
+	})
+	if err != nil {
+		t.bot.Message(msg.Channel, reply+"Errore nel salvare l'ordine: "+err.Error())
+		return
+	}
+	t.confirmMatches(me, choice)
+
+	if len(choice) == 0 {
+		t.bot.Message(msg.Channel, fmt.Sprintf("Ok, cancello l'ordine di %s per %s", me.Name, day))
+		return
+	}
+	t.bot.Message(msg.Channel, reply+fmt.Sprintf("Ok, ordinat%s %d piatt%s per %s per %s",
+		plural(len(choice)), len(choice), plural(len(choice)), me.Name, day))
+}
+
+func plural(n int) string {
+	if n > 1 {
+		return "i"
+	}
+	return "o"
+}
+
+// AdvanceOrder shows the order placed in advance for a future day
+func (t *TinaBot) AdvanceOrder(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	loc, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		loc = time.Local
+	}
+	date, ok := parseDay(args[1], time.Now().In(loc))
+	if !ok {
+		t.bot.Message(msg.Channel, fmt.Sprintf("Non capisco quale giorno sia '%s'", args[1]))
+		return
+	}
+	order := LoadAdvance(t.brain, date)
+	if len(order.Users) == 0 {
+		t.bot.Message(msg.Channel, fmt.Sprintf("Nessun ordine per %s", formatDay(date)))
+		return
+	}
+	t.bot.Message(msg.Channel, fmt.Sprintf("Ecco l'ordine per %s:\n", formatDay(date))+order.Format(t.showNames(msg, user), false))
+}
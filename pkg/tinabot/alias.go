@@ -0,0 +1,142 @@
+package tinabot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// aliasesKey holds the Aliases by user ID
+const aliasesKey = "aliases"
+
+// maxAliasLen is the longest short name allowed
+const maxAliasLen = 20
+
+// Alias are the names of a Slack user: the user name, the display name and
+// the short name she chose to be shown in the orders
+type Alias struct {
+	Name        string
+	DisplayName string `json:",omitempty"`
+	Short       string `json:",omitempty"`
+}
+
+// loadAliases returns the aliases by user ID
+func loadAliases(brain DataStore) map[string]Alias {
+	aliases := make(map[string]Alias)
+	if err := brain.Get(aliasesKey, &aliases); err != nil {
+		return make(map[string]Alias)
+	}
+	return aliases
+}
+
+// orderAliases returns the short names of the users in order, by user ID
+func orderAliases(brain DataStore, order *Order) map[string]string {
+	aliases := loadAliases(brain)
+	if len(aliases) == 0 {
+		return nil
+	}
+	shorts := make(map[string]string)
+	add := func(u User) {
+		if a, ok := aliases[u.ID]; ok && u.ID != "" && a.Short != "" {
+			shorts[u.ID] = a.Short
+		}
+	}
+	for u := range order.Users {
+		add(u)
+	}
+	for _, by := range order.OrderedBy {
+		add(by)
+	}
+	if len(shorts) == 0 {
+		return nil
+	}
+	return shorts
+}
+
+// aliasOwner returns the ID of the user with the short name, case
+// insensitive, empty if nobody has it
+func aliasOwner(aliases map[string]Alias, short string) string {
+	for id, a := range aliases {
+		if a.Short != "" && strings.EqualFold(a.Short, short) {
+			return id
+		}
+	}
+	return ""
+}
+
+// findUser returns the user with the short name, or the user name or
+// display name, see getUserInfo
+func (t *TinaBot) findUser(name string) *chat.User {
+	if id := aliasOwner(loadAliases(t.brain), name); id != "" {
+		u, err := t.bot.Platform.UserInfo(id)
+		if err == nil {
+			return u
+		}
+		t.logger().Warn("Error getting the user", "name", name, "err", err)
+	}
+	return getUserInfo(t.bot.Platform, name)
+}
+
+// AliasCmd shows or changes the short name of the user shown in the orders,
+// "soprannomi" lists all of them
+func (t *TinaBot) AliasCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	aliases := loadAliases(t.brain)
+	if strings.EqualFold(args[1], "soprannomi") {
+		var lines []string
+		for _, a := range aliases {
+			if a.Short != "" {
+				lines = append(lines, fmt.Sprintf("%s: %s", a.Short, a.Name))
+			}
+		}
+		if len(lines) == 0 {
+			t.bot.Message(msg.Channel, "Nessuno ha ancora scelto un soprannome")
+			return
+		}
+		sort.Strings(lines)
+		t.bot.Message(msg.Channel, "Soprannomi:\n"+strings.Join(lines, "\n"))
+		return
+	}
+
+	short := strings.TrimSpace(args[2])
+	a := aliases[user.ID]
+	if short == "" {
+		if a.Short == "" {
+			t.bot.Message(msg.Channel, "Non hai un soprannome, sceglilo con `soprannome <nome>`")
+			return
+		}
+		t.bot.Message(msg.Channel, "Il tuo soprannome è "+a.Short)
+		return
+	}
+
+	switch {
+	case strings.EqualFold(short, "off"):
+		short = ""
+	case len([]rune(short)) > maxAliasLen || strings.ContainsAny(short, "<>@*_`"):
+		t.bot.Message(msg.Channel, fmt.Sprintf("Soprannome non valido, al massimo %d caratteri senza simboli di formattazione", maxAliasLen))
+		return
+	default:
+		if id := aliasOwner(aliases, short); id != "" && id != user.ID {
+			t.bot.Message(msg.Channel, "Mi spiace, il soprannome "+short+" è già di qualcun altro")
+			return
+		}
+	}
+
+	aliases[user.ID] = Alias{Name: user.Name, DisplayName: user.DisplayName, Short: short}
+	if err := t.brain.Set(aliasesKey, aliases); err != nil {
+		t.bot.Message(msg.Channel, "Errore nel salvare il soprannome: "+err.Error())
+		return
+	}
+	// shows the new name in today's order too
+	var order Order
+	if err := order.SaveCAS(t.brain, func(o *Order) error { return nil }); err != nil {
+		t.logger().Error("Error updating the order", "err", err)
+	}
+	if short == "" {
+		t.bot.Message(msg.Channel, "Ok, negli ordini comparirai come "+user.Name)
+		return
+	}
+	t.bot.Message(msg.Channel, "Ok, negli ordini comparirai come "+short)
+}
@@ -0,0 +1,51 @@
+package tinabot
+
+import (
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestOrderRename(t *testing.T) {
+	var c UserChoice
+	c.Add(tuttobene.MenuRow{Content: "primo", Type: tuttobene.Primo})
+
+	order := NewOrder()
+	old := User{"mario", "U1"}
+	order.SetBy(old, User{"guest_anna", ""}, []UserChoice{c})
+	order.Set(old, []UserChoice{c})
+
+	renamed := User{"mario.rossi", "U1"}
+	assertEqual(t, order.Ordered(renamed), true, "")
+	assertEqual(t, len(order.choicesOf(renamed)), 1, "")
+
+	order.Set(renamed, []UserChoice{c, c})
+	assertEqual(t, len(order.Users), 2, "")
+	assertEqual(t, len(order.Users[renamed]), 2, "")
+	assertEqual(t, order.OrderedBy[User{"guest_anna", ""}], renamed, "")
+	assertEqual(t, len(order.Dishes["primo"]), 3, "")
+	assertEqual(t, order.Ordered(old), true, "")
+	_, ok := order.Users[old]
+	assertEqual(t, ok, false, "")
+}
+
+func TestOrderAliases(t *testing.T) {
+	b := brain.NewBrainMock()
+	b.Set(aliasesKey, map[string]Alias{"U1": {Name: "mario.rossi", Short: "Mario"}, "U2": {Name: "anna"}})
+
+	var c UserChoice
+	c.Add(tuttobene.MenuRow{Content: "primo", Type: tuttobene.Primo})
+	var order Order
+	err := order.SaveCAS(b, func(o *Order) error {
+		o.Set(User{"mario.rossi", "U1"}, []UserChoice{c})
+		o.Set(User{"anna", "U2"}, []UserChoice{c})
+		return nil
+	})
+	assertEqual(t, err, nil, "")
+	assertEqual(t, len(order.Aliases), 1, "")
+	assertEqual(t, order.Format(true, false), "2 primo [Mario, anna]", "")
+
+	assertEqual(t, aliasOwner(loadAliases(b), "mario"), "U1", "")
+	assertEqual(t, aliasOwner(loadAliases(b), "anna"), "", "")
+}
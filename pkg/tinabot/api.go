@@ -0,0 +1,219 @@
+package tinabot
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/events"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// The menu and the order as served by the HTTP API and sent to the
+// webhooks, independent of how they're stored
+
+// APIMenu is a menu
+type APIMenu struct {
+	Date   string    `json:"date"`
+	Dishes []APIDish `json:"dishes"`
+}
+
+// APIDish is a dish of the menu, Row is its index to order it
+type APIDish struct {
+	Row   int    `json:"row"`
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Price string `json:"price,omitempty"`
+}
+
+// APIOrder is an order
+type APIOrder struct {
+	Date   string         `json:"date"`
+	State  string         `json:"state"`
+	Users  []APIUser      `json:"users"`
+	Dishes []APIDishCount `json:"dishes"`
+}
+
+// APIUser is the order of a user
+type APIUser struct {
+	ID        string   `json:"id,omitempty"`
+	Name      string   `json:"name"`
+	OrderedBy string   `json:"ordered_by,omitempty"`
+	Dishes    []string `json:"dishes"`
+}
+
+// APIDishCount is how many times a dish has been ordered
+type APIDishCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// NewAPIMenu returns the menu m, without the empty rows
+func NewAPIMenu(m tuttobene.Menu) APIMenu {
+	am := APIMenu{Date: dayKey(m.Date), Dishes: []APIDish{}}
+	for i, r := range m.Rows {
+		if r.Type == tuttobene.Empty || r.Type == tuttobene.Unknonwn {
+			continue
+		}
+		dish := APIDish{Row: i, Type: tuttobene.Titles[r.Type], Name: r.Content}
+		if !r.Price.IsZero() {
+			dish.Price = r.Price.String()
+		}
+		am.Dishes = append(am.Dishes, dish)
+	}
+	return am
+}
+
+// NewAPIOrder returns the order o, the users by name
+func NewAPIOrder(o *Order) APIOrder {
+	ao := APIOrder{Date: dayKey(o.Timestamp), State: o.State.String(), Users: []APIUser{}, Dishes: []APIDishCount{}}
+	for _, u := range o.users() {
+		au := APIUser{ID: u.ID, Name: o.Name(u), Dishes: []string{}}
+		if by, ok := o.OrderedBy[u]; ok {
+			au.OrderedBy = o.Name(by)
+		}
+		for _, c := range o.Users[u] {
+			au.Dishes = append(au.Dishes, c.String())
+		}
+		ao.Users = append(ao.Users, au)
+	}
+	for _, dish := range o.sorted() {
+		ao.Dishes = append(ao.Dishes, APIDishCount{dish, len(o.Dishes[dish])})
+	}
+	return ao
+}
+
+// APIMenuToday returns today's menu, if known
+func APIMenuToday(b DataStore, now time.Time) (APIMenu, error) {
+	menu, err := LoadMenu(b, now)
+	if err != nil {
+		return APIMenu{}, errors.New("il menù di oggi non è ancora disponibile")
+	}
+	return NewAPIMenu(menu), nil
+}
+
+// TodayOrder returns today's order, a new one if nobody ordered yet
+func TodayOrder(b DataStore) *Order {
+	return getOrder(b)
+}
+
+// APIOrderToday returns today's order
+func APIOrderToday(b DataStore) APIOrder {
+	return NewAPIOrder(TodayOrder(b))
+}
+
+// PlaceAPIOrder replaces the order of user with the dishes of today's menu
+// at the indexes rows, one dish for each choice, nothing if rows is empty.
+// It returns the order of the user.
+func PlaceAPIOrder(b brain.Store, user User, rows []int, now time.Time) (APIUser, error) {
+	menu, err := openMenu(b, now)
+	if err != nil {
+		return APIUser{}, err
+	}
+	choices, err := menuChoices(menu, rows)
+	if err != nil {
+		return APIUser{}, err
+	}
+
+	var order Order
+	err = order.SaveCAS(b, func(o *Order) error {
+		if err := o.Editable(); err != nil {
+			return err
+		}
+		o.ClearUser(user)
+		if len(choices) > 0 {
+			o.Set(user, choices)
+		}
+		return nil
+	})
+	if err != nil {
+		return APIUser{}, err
+	}
+	if len(choices) > 0 {
+		observeOrder("api")
+	}
+	if ps, ok := b.(brain.PubSub); ok {
+		// to the bots and the streams of the updates
+		if err := events.Send(ps, events.OrderUpdated, &order); err != nil {
+			slog.Error("Error sending the order update", "err", err)
+		}
+	}
+	au := APIUser{ID: user.ID, Name: order.Name(user), Dishes: []string{}}
+	for _, c := range order.Users[order.key(user)] {
+		au.Dishes = append(au.Dishes, c.String())
+	}
+	return au, nil
+}
+
+// WatchOrder calls fn with the order every time it's updated by any bot
+// instance sharing b, until the returned function is called. b must
+// support pub/sub.
+func WatchOrder(b brain.Store, fn func(*Order)) (func() error, error) {
+	ps, ok := b.(brain.PubSub)
+	if !ok {
+		return nil, brain.ErrNoPubSub
+	}
+	return events.Watch(ps, events.OrderUpdated, decodeOrder, func(ev events.Event) {
+		fn(ev.Data.(*Order))
+	})
+}
+
+// The kinds of the changes of the order, see OrderChange
+const (
+	ChangeDishAdded   = "dish_added"
+	ChangeDishRemoved = "dish_removed"
+	ChangeState       = "order_state"
+)
+
+// OrderChange is a change of the order as streamed to the live views, e.g.
+// the display of the kitchen: a dish added or removed by a user, or the new
+// state of the order, Editable false once it's locked
+type OrderChange struct {
+	Type     string `json:"type"`
+	UserID   string `json:"user_id,omitempty"`
+	User     string `json:"user,omitempty"`
+	Dish     string `json:"dish,omitempty"`
+	State    string `json:"state,omitempty"`
+	Editable bool   `json:"editable"`
+}
+
+// OrderChanges returns the changes from the order prev to next, the
+// removed dishes first. The order of another day counts as empty.
+func OrderChanges(prev, next APIOrder) []OrderChange {
+	editable := next.State == Open.String()
+	if prev.Date != next.Date {
+		prev = APIOrder{Date: next.Date, State: Open.String()}
+	}
+	changes := missingDishes(prev, next, ChangeDishRemoved, editable)
+	changes = append(changes, missingDishes(next, prev, ChangeDishAdded, editable)...)
+	if prev.State != next.State {
+		changes = append(changes, OrderChange{Type: ChangeState, State: next.State, Editable: editable})
+	}
+	return changes
+}
+
+// missingDishes returns a change of type kind for each dish of the users of
+// o not in other
+func missingDishes(o, other APIOrder, kind string, editable bool) []OrderChange {
+	key := func(u APIUser) string { return u.ID + "\x00" + u.Name }
+	left := make(map[string]map[string]int)
+	for _, u := range other.Users {
+		left[key(u)] = make(map[string]int)
+		for _, d := range u.Dishes {
+			left[key(u)][d]++
+		}
+	}
+
+	var changes []OrderChange
+	for _, u := range o.Users {
+		for _, d := range u.Dishes {
+			if left[key(u)][d] > 0 {
+				left[key(u)][d]--
+				continue
+			}
+			changes = append(changes, OrderChange{Type: kind, UserID: u.ID, User: u.Name, Dish: d, Editable: editable})
+		}
+	}
+	return changes
+}
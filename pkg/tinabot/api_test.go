@@ -0,0 +1,69 @@
+package tinabot
+
+import (
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestPlaceAPIOrder(t *testing.T) {
+	b := brain.NewBrainMock()
+	today := NewOrder().Timestamp
+	b.Set("menu", tuttobene.Menu{Date: today, Rows: []tuttobene.MenuRow{
+		{Content: "Primi", Type: tuttobene.Empty},
+		{Content: "Risotto", Type: tuttobene.Primo},
+		{Content: "Pollo", Type: tuttobene.Secondo},
+	}})
+
+	menu, err := APIMenuToday(b, today)
+	assertEqual(t, err, nil, "")
+	assertEqual(t, len(menu.Dishes), 2, "")
+	assertEqual(t, menu.Dishes[0], APIDish{Row: 1, Type: "primi piatti", Name: "Risotto"}, "")
+
+	mario := User{"mario", "U1"}
+	_, err = PlaceAPIOrder(b, mario, []int{0}, today)
+	assertEqual(t, err != nil, true, "")
+	u, err := PlaceAPIOrder(b, mario, []int{1, 2}, today)
+	assertEqual(t, err, nil, "")
+	assertEqual(t, len(u.Dishes), 2, "")
+
+	// the order is replaced
+	u, err = PlaceAPIOrder(b, mario, []int{2}, today)
+	assertEqual(t, err, nil, "")
+	assertEqual(t, len(u.Dishes), 1, "")
+	order := APIOrderToday(b)
+	assertEqual(t, len(order.Users), 1, "")
+	assertEqual(t, order.Users[0].Dishes[0], "Pollo", "")
+	assertEqual(t, order.Dishes[0], APIDishCount{"Pollo", 1}, "")
+
+	_, err = PlaceAPIOrder(b, mario, nil, today)
+	assertEqual(t, err, nil, "")
+	assertEqual(t, len(APIOrderToday(b).Users), 0, "")
+}
+
+func TestOrderChanges(t *testing.T) {
+	prev := APIOrder{Date: "2024-05-06", State: "aperto", Users: []APIUser{
+		{ID: "U1", Name: "mario", Dishes: []string{"Pollo", "Risotto"}},
+		{ID: "U2", Name: "anna", Dishes: []string{"Pollo"}},
+	}}
+	next := APIOrder{Date: "2024-05-06", State: "aperto", Users: []APIUser{
+		{ID: "U1", Name: "mario", Dishes: []string{"Pollo", "Pollo"}},
+	}}
+	changes := OrderChanges(prev, next)
+	assertEqual(t, len(changes), 3, "")
+	assertEqual(t, changes[0], OrderChange{Type: ChangeDishRemoved, UserID: "U1", User: "mario", Dish: "Risotto", Editable: true}, "")
+	assertEqual(t, changes[1], OrderChange{Type: ChangeDishRemoved, UserID: "U2", User: "anna", Dish: "Pollo", Editable: true}, "")
+	assertEqual(t, changes[2], OrderChange{Type: ChangeDishAdded, UserID: "U1", User: "mario", Dish: "Pollo", Editable: true}, "")
+
+	prev, next.State = next, Locked.String()
+	changes = OrderChanges(prev, next)
+	assertEqual(t, len(changes), 1, "")
+	assertEqual(t, changes[0], OrderChange{Type: ChangeState, State: "bloccato", Editable: false}, "")
+
+	// a new day starts from an empty order
+	next = APIOrder{Date: "2024-05-07", State: "aperto", Users: []APIUser{{Name: "mario", Dishes: []string{"Pollo"}}}}
+	changes = OrderChanges(prev, next)
+	assertEqual(t, len(changes), 1, "")
+	assertEqual(t, changes[0], OrderChange{Type: ChangeDishAdded, User: "mario", Dish: "Pollo", Editable: true}, "")
+}
@@ -0,0 +1,312 @@
+package tinabot
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/flags"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// The action ids of the interactive menu
+const (
+	actionPickDish = "pick_dish"
+	actionConfirm  = "confirm_order"
+	actionDiscard  = "discard_order"
+)
+
+// maxOptionText is the longest text of a select option allowed by Slack
+const maxOptionText = 75
+
+// Selection is the dishes picked by a user in the interactive menu, at most
+// one per type
+type Selection struct {
+	Day    string
+	Dishes map[tuttobene.MenuRowType]tuttobene.MenuRow
+}
+
+// loadSelection returns the selection of user for the menu of day, an empty
+// one if there's none
+func loadSelection(brain DataStore, user User, day string) Selection {
+	s, err := selectionRepo(brain).Get(userKey(user))
+	if err != nil || s.Day != day {
+		return Selection{Day: day, Dishes: make(map[tuttobene.MenuRowType]tuttobene.MenuRow)}
+	}
+	return s
+}
+
+// rows returns the picked dishes ordered by type
+func (s Selection) rows() []tuttobene.MenuRow {
+	var rows []tuttobene.MenuRow
+	for _, r := range s.Dishes {
+		rows = append(rows, r)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Type < rows[j].Type })
+	return rows
+}
+
+// Choices returns a choice for each picked dish
+func (s Selection) Choices() []UserChoice {
+	var choices []UserChoice
+	for _, r := range s.rows() {
+		var c UserChoice
+		c.Add(r)
+		choices = append(choices, c)
+	}
+	return choices
+}
+
+func (s Selection) String() string {
+	var dishes []string
+	for _, r := range s.rows() {
+		dishes = append(dishes, r.Content)
+	}
+	return strings.Join(dishes, " + ")
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
+
+// maxActionElements is the most elements allowed by Slack in an actions block
+const maxActionElements = 5
+
+// menuBlocks returns the interactive menu, a select for each type of dish
+// and the buttons to confirm or discard the selection
+func menuBlocks(menu tuttobene.Menu, sel Selection) []chat.Block {
+	blocks := []chat.Block{
+		chat.Section{Text: "Scegli i piatti del menù di oggi:"},
+	}
+
+	var selects []chat.Element
+	pickers := make(map[tuttobene.MenuRowType]int)
+	for i, r := range menu.Rows {
+		if r.Type == tuttobene.Empty || r.Type == tuttobene.Unknonwn {
+			continue
+		}
+		n, ok := pickers[r.Type]
+		if !ok {
+			n = len(selects)
+			pickers[r.Type] = n
+			selects = append(selects, chat.Select{
+				ActionID:    actionPickDish,
+				Placeholder: strings.Title(tuttobene.Titles[r.Type]),
+			})
+		}
+		picker := selects[n].(chat.Select)
+		text := r.Content
+		if r.Price.IsPositive() {
+			text = fmt.Sprintf("%s €%s", r.Content, r.Price.StringFixed(2))
+		}
+		value := strconv.Itoa(i)
+		picker.Options = append(picker.Options, chat.Option{Text: truncate(text, maxOptionText), Value: value})
+		if picked, ok := sel.Dishes[r.Type]; ok && picked.Content == r.Content {
+			picker.Initial = value
+		}
+		selects[n] = picker
+	}
+	for len(selects) > 0 {
+		n := len(selects)
+		if n > maxActionElements {
+			n = maxActionElements
+		}
+		blocks = append(blocks, chat.Actions{Elements: selects[:n]})
+		selects = selects[n:]
+	}
+
+	summary := "Non hai ancora scelto nessun piatto"
+	if len(sel.Dishes) > 0 {
+		summary = "Il tuo ordine: " + sel.String()
+	}
+	blocks = append(blocks, chat.Context{Text: summary})
+
+	// confirmed in the modal of confirmModal
+	confirm := chat.Button{ActionID: actionConfirm, Text: "Ordina", Style: chat.Primary}
+	discard := chat.Button{ActionID: actionDiscard, Text: "Annulla", Style: chat.Danger}
+	return append(blocks, chat.Actions{Elements: []chat.Element{confirm, discard}})
+}
+
+// callbackConfirmOrder is the callback id of the modal confirming the order
+// of the interactive menu
+const callbackConfirmOrder = "confirm_order"
+
+// confirmMetadata is the state of the modal confirming the order: where the
+// interactive menu is shown
+type confirmMetadata struct {
+	Channel     string `json:"channel"`
+	ResponseURL string `json:"response_url"`
+}
+
+// confirmModal returns the modal asking to confirm the order of the dishes
+// picked in sel, replacing prev, the dishes ordered before if any
+func confirmModal(sel Selection, prev UserChoiceArray, metadata string) chat.Modal {
+	var lines []string
+	for _, r := range sel.rows() {
+		line := "• " + r.Content
+		if r.Price.IsPositive() {
+			line += " €" + r.Price.StringFixed(2)
+		}
+		lines = append(lines, line)
+	}
+	blocks := []chat.Block{chat.Section{Text: "Stai per ordinare:\n" + strings.Join(lines, "\n")}}
+	if total := UserChoiceArray(sel.Choices()).Price(); total.IsPositive() {
+		blocks = append(blocks, chat.Context{Text: "Totale: €" + total.StringFixed(2)})
+	}
+	if len(prev) > 0 {
+		blocks = append(blocks, chat.Context{Text: "Sostituisce il tuo ordine di oggi:\n" + prev.String()})
+	}
+	return chat.Modal{
+		CallbackID: callbackConfirmOrder,
+		Metadata:   metadata,
+		Title:      "Confermi l'ordine?",
+		Submit:     "Ordina",
+		Close:      "Torna al menù",
+		Blocks:     blocks,
+	}
+}
+
+// flagOrderMenu rolls out the interactive menu, see OrderMenu
+var flagOrderMenu = flags.Define("menu-interattivo", "il menù con i pulsanti per scegliere i piatti, con `ordina`")
+
+// OrderMenu shows the interactive menu to order by picking the dishes
+func (t *TinaBot) OrderMenu(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	if !t.flagEnabled(flagOrderMenu, msg, user) {
+		t.bot.Message(msg.Channel, "Il menù interattivo non è ancora attivo qui, ordina con `per me <piatto>`")
+		return
+	}
+	menu, ok := t.orderableMenu(msg)
+	if !ok {
+		return
+	}
+	sel := loadSelection(t.brain, User{user.Name, user.ID}, dayKey(menu.Date))
+	t.bot.Blocks(msg.Channel, user.ID, "Ecco il menù di oggi", menuBlocks(menu, sel)...)
+}
+
+// orderableMenu returns today's menu if it's possible to order, otherwise
+// tells why not
+func (t *TinaBot) orderableMenu(msg *slackbot.BotMsg) (tuttobene.Menu, bool) {
+	if closed, reason := IsClosedToday(t.brain); closed {
+		t.bot.Message(msg.Channel, "Oggi non si ordina il pranzo: "+reason)
+		return tuttobene.Menu{}, false
+	}
+	menu, err := todayMenu(t.brain)
+	if err != nil {
+		t.bot.Message(msg.Channel, "Nessun menù impostato!")
+		return menu, false
+	}
+	if !menu.IsUpdated() {
+		t.bot.Message(msg.Channel, "Non puoi ordinare, il menù non è quello di oggi, riporta la data del "+menu.Date.Format("02/01/2006"))
+		return menu, false
+	}
+	return menu, true
+}
+
+// BlockAction handles the clicks on the interactive menu: picking a dish
+// updates the menu shown, ordering asks to confirm the order in a modal, see
+// ViewSubmission. The buttons of the
+// Home tab are handled by homeAction, the ones of the uploaded menus by
+// menuUploadAction, the votes of the poll by pollAction.
+func (t *TinaBot) BlockAction(in chat.Interaction) {
+	for _, a := range in.Actions {
+		a := a
+		if isHomeAction(a) {
+			t.homeAction(in.User, a)
+			continue
+		}
+		t.bot.HandleInteraction(in.Channel, in.User, in.ResponseURL, func(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User) {
+			switch a.ActionID {
+			case actionPublishMenu, actionDiscardMenu:
+				t.menuUploadAction(in.ResponseURL, msg, user, a)
+			case actionPollVote:
+				t.pollAction(in.ResponseURL, msg, user, a)
+			default:
+				t.menuAction(in, msg, user, a)
+			}
+		})
+	}
+}
+
+func (t *TinaBot) menuAction(in chat.Interaction, msg *slackbot.BotMsg, user *chat.User, a chat.Action) {
+	responseURL := in.ResponseURL
+	me := User{user.Name, user.ID}
+	menu, ok := t.orderableMenu(msg)
+	if !ok {
+		return
+	}
+	day := dayKey(menu.Date)
+	sel := loadSelection(t.brain, me, day)
+
+	switch a.ActionID {
+	case actionPickDish:
+		i, err := strconv.Atoi(a.Value)
+		if err != nil || i < 0 || i >= len(menu.Rows) {
+			t.bot.Replace(responseURL, "Il menù è cambiato, eccolo aggiornato", menuBlocks(menu, sel)...)
+			return
+		}
+		row := menu.Rows[i]
+		sel.Dishes[row.Type] = row
+		if err := selectionRepo(t.brain).Put(userKey(me), sel); err != nil {
+			t.bot.Message(msg.Channel, "Errore nel salvare la scelta: "+err.Error())
+			return
+		}
+		t.bot.Replace(responseURL, "Il tuo ordine: "+sel.String(), menuBlocks(menu, sel)...)
+
+	case actionConfirm:
+		if len(sel.Dishes) == 0 {
+			t.bot.Replace(responseURL, "Scegli almeno un piatto", menuBlocks(menu, sel)...)
+			return
+		}
+		metadata, _ := json.Marshal(confirmMetadata{msg.Channel, responseURL})
+		prev := getOrder(t.brain).choicesOf(me)
+		if err := t.bot.OpenModal(in.TriggerID, confirmModal(sel, prev, string(metadata))); err != nil {
+			t.logger().Error("Error opening the confirmation of the order", "err", err)
+			t.bot.Replace(responseURL, "Non riesco a chiederti conferma, riprova", menuBlocks(menu, sel)...)
+		}
+
+	case actionDiscard:
+		selectionRepo(t.brain).Delete(userKey(me))
+		t.bot.Replace(responseURL, "Ok, niente ordine")
+	}
+}
+
+// ViewSubmission handles the modals confirmed by the users: the one opened
+// by the interactive menu places the order of the dishes picked
+func (t *TinaBot) ViewSubmission(sub chat.Submission) {
+	if sub.CallbackID != callbackConfirmOrder {
+		return
+	}
+	var meta confirmMetadata
+	if err := json.Unmarshal([]byte(sub.Metadata), &meta); err != nil {
+		t.logger().Warn("Invalid confirmation of the order", "err", err)
+		return
+	}
+	t.bot.HandleInteraction(meta.Channel, sub.User, meta.ResponseURL, func(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User) {
+		t.confirmOrder(meta.ResponseURL, msg, user)
+	})
+}
+
+// confirmOrder places the order of the dishes picked by user in the
+// interactive menu, replacing the menu with the dishes ordered
+func (t *TinaBot) confirmOrder(responseURL string, msg *slackbot.BotMsg, user *chat.User) {
+	me := User{user.Name, user.ID}
+	menu, ok := t.orderableMenu(msg)
+	if !ok {
+		return
+	}
+	sel := loadSelection(t.brain, me, dayKey(menu.Date))
+	if len(sel.Dishes) == 0 {
+		t.bot.Replace(responseURL, "Scegli almeno un piatto", menuBlocks(menu, sel)...)
+		return
+	}
+	selectionRepo(t.brain).Delete(userKey(me))
+	t.bot.Replace(responseURL, "Ordine inviato: "+sel.String())
+	t.placeOrder(msg, user, me, "", sel.Choices(), "")
+}
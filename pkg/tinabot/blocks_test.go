@@ -0,0 +1,67 @@
+package tinabot
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestMenuBlocks(t *testing.T) {
+	menu := tuttobene.Menu{Rows: []tuttobene.MenuRow{
+		{Content: "Lasagne", Type: tuttobene.Primo},
+		{Content: "Penne", Type: tuttobene.Primo},
+		{Content: "Tagliata", Type: tuttobene.Secondo},
+		{Content: "Patate", Type: tuttobene.Contorno},
+	}}
+
+	b := brain.NewBrainMock()
+	user := User{"test", "123"}
+	sel := loadSelection(b, user, "2019-03-14")
+	assertEqual(t, len(sel.Dishes), 0, "")
+	sel.Dishes[tuttobene.Secondo] = menu.Rows[2]
+	sel.Dishes[tuttobene.Primo] = menu.Rows[1]
+	assertEqual(t, selectionRepo(b).Put(userKey(user), sel), nil, "")
+	assertEqual(t, loadSelection(b, user, "2019-03-14").String(), "Penne + Tagliata", "")
+	assertEqual(t, len(loadSelection(b, user, "2019-03-15").Dishes), 0, "")
+	assertEqual(t, len(sel.Choices()), 2, "")
+
+	// a section, the selects, the summary and the buttons
+	blocks := menuBlocks(menu, sel)
+	assertEqual(t, len(blocks), 4, "")
+	data, err := json.Marshal(slackbot.SlackBlocks(blocks))
+	assertEqual(t, err, nil, "")
+	assertEqual(t, strings.Contains(string(data), `"initial_option":{"text":{"type":"plain_text","text":"Penne"},"value":"1"}`), true, string(data))
+	assertEqual(t, strings.Contains(string(data), `"url"`), false, "")
+	assertEqual(t, strings.Contains(string(data), "Il tuo ordine: Penne + Tagliata"), true, "")
+
+	assertEqual(t, truncate("Lasagne", 5), "Lasa…", "")
+}
+
+func TestConfirmModal(t *testing.T) {
+	sel := Selection{Dishes: map[tuttobene.MenuRowType]tuttobene.MenuRow{
+		tuttobene.Primo:   {Content: "Lasagne", Type: tuttobene.Primo, Price: decimal.NewFromFloat(5)},
+		tuttobene.Secondo: {Content: "Tagliata", Type: tuttobene.Secondo, Price: decimal.NewFromFloat(7.5)},
+	}}
+	var c UserChoice
+	c.Add(tuttobene.MenuRow{Content: "Penne", Type: tuttobene.Primo})
+
+	m := confirmModal(sel, UserChoiceArray{c}, `{"channel":"C1"}`)
+	assertEqual(t, m.CallbackID, callbackConfirmOrder, "")
+	data, err := json.Marshal(slackbot.SlackBlocks(m.Blocks))
+	assertEqual(t, err, nil, "")
+	for _, s := range []string{"• Lasagne €5.00\\n• Tagliata €7.50", "Totale: €12.50", "Sostituisce il tuo ordine di oggi:\\nPenne"} {
+		assertEqual(t, strings.Contains(string(data), s), true, string(data))
+	}
+	assertEqual(t, len(confirmModal(sel, nil, "").Blocks), 2, "")
+
+	sub, err := slackbot.ChatSubmission([]byte(`{"type":"view_submission","team":{"id":"T1"},"user":{"id":"U1"},"view":{"callback_id":"confirm_order","private_metadata":"{\"channel\":\"C1\"}"}}`))
+	assertEqual(t, err, nil, "")
+	assertEqual(t, sub, chat.Submission{Team: "T1", User: "U1", CallbackID: callbackConfirmOrder, Metadata: `{"channel":"C1"}`}, "")
+}
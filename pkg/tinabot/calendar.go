@@ -0,0 +1,156 @@
+package tinabot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+const calendarDate = "02/01/2006"
+
+// Calendar holds the days when there's no lunch order
+type Calendar struct {
+	Weekly   int               // week mask of the days without lunch, eg. fridays
+	Holidays map[string]string // closed days, "2006-01-02" -> reason
+}
+
+// Load loads the calendar from brain, an empty one if not set
+func (c *Calendar) Load(brain DataStore) error {
+	if err := brain.Get("calendar", c); err != nil {
+		*c = Calendar{}
+		return err
+	}
+	return nil
+}
+
+// Save saves the calendar to brain
+func (c *Calendar) Save(brain DataStore) error {
+	return brain.Set("calendar", *c)
+}
+
+// IsClosed tells if there's no lunch on date, with the reason why
+func (c *Calendar) IsClosed(date time.Time) (bool, string) {
+	if reason, ok := c.Holidays[date.Format("2006-01-02")]; ok {
+		return true, reason
+	}
+	if c.Weekly&(1<<uint(date.Weekday())) != 0 {
+		return true, "niente pranzo il " + weekNames[date.Weekday()]
+	}
+	return false, ""
+}
+
+// everyDay tells if the week mask has all the seven days, the mask
+// of "tutti" has also the eighth bit
+func everyDay(mask int) bool {
+	return mask&0x7f == 0x7f
+}
+
+// Close adds date to the closed days
+func (c *Calendar) Close(date time.Time, reason string) {
+	if c.Holidays == nil {
+		c.Holidays = make(map[string]string)
+	}
+	c.Holidays[date.Format("2006-01-02")] = reason
+}
+
+// Open removes date from the closed days
+func (c *Calendar) Open(date time.Time) {
+	delete(c.Holidays, date.Format("2006-01-02"))
+}
+
+func (c *Calendar) String() string {
+	var r []string
+	if c.Weekly != 0 {
+		r = append(r, "Niente pranzo ogni "+formatWeekDays(c.Weekly))
+	}
+
+	var days []string
+	for d := range c.Holidays {
+		days = append(days, d)
+	}
+	sort.Strings(days)
+	for _, d := range days {
+		date, _ := time.Parse("2006-01-02", d)
+		l := date.Format(calendarDate)
+		if c.Holidays[d] != "" {
+			l += " - " + c.Holidays[d]
+		}
+		r = append(r, l)
+	}
+
+	if len(r) == 0 {
+		return "Nessun giorno di chiusura impostato"
+	}
+	return strings.Join(r, "\n")
+}
+
+// IsClosedToday loads the calendar and tells if there's no lunch today
+func IsClosedToday(brain DataStore) (bool, string) {
+	var c Calendar
+	c.Load(brain)
+
+	loc, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		return false, ""
+	}
+	return c.IsClosed(time.Now().In(loc))
+}
+
+// CalendarCmd shows or changes the days without lunch
+func (t *TinaBot) CalendarCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	var c Calendar
+	c.Load(t.brain)
+
+	f := strings.Fields(args[1])
+	if len(f) == 0 {
+		t.bot.Message(msg.Channel, c.String())
+		return
+	}
+	if strings.ToLower(f[0]) == "link" {
+		t.bot.Message(msg.Channel, t.icalLink(msg.Channel, msg.User))
+		return
+	}
+	if len(f) < 2 {
+		t.bot.Message(msg.Channel, "Argomenti insufficienti!")
+		return
+	}
+
+	switch strings.ToLower(f[0]) {
+	case "chiuso", "aperto":
+		date, err := time.Parse(calendarDate, f[1])
+		if err != nil {
+			t.bot.Message(msg.Channel, "Data non valida, usa il formato gg/mm/aaaa")
+			return
+		}
+		if strings.ToLower(f[0]) == "chiuso" {
+			c.Close(date, strings.Join(f[2:], " "))
+		} else {
+			c.Open(date)
+		}
+	case "settimana":
+		arg := strings.Join(f[1:], " ")
+		mask, ok := parseWeekMask(arg)
+		if !ok {
+			t.bot.Message(msg.Channel, fmt.Sprintf("Giorni '%s' non validi", arg))
+			return
+		}
+		if everyDay(mask) {
+			t.bot.Message(msg.Channel, "Non è possibile chiudere tutti i giorni!")
+			return
+		}
+		c.Weekly = mask
+	default:
+		t.bot.Message(msg.Channel, "Comando non valido, usa `chiuso`, `aperto` o `settimana`")
+		return
+	}
+
+	if err := c.Save(t.brain); err != nil {
+		t.bot.Message(msg.Channel, "Errore: "+err.Error())
+		return
+	}
+	t.bot.Message(msg.Channel, "Ok, calendario aggiornato:\n"+c.String())
+}
@@ -0,0 +1,73 @@
+package tinabot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+)
+
+func TestCalendar(t *testing.T) {
+	var c Calendar
+	friday := time.Date(2019, 3, 15, 12, 0, 0, 0, time.UTC)
+	monday := friday.AddDate(0, 0, 3)
+
+	closed, _ := c.IsClosed(friday)
+	assertEqual(t, closed, false, "")
+	assertEqual(t, c.String(), "Nessun giorno di chiusura impostato", "")
+
+	mask, ok := parseWeekMask("ven")
+	assertEqual(t, ok, true, "")
+	c.Weekly = mask
+	closed, reason := c.IsClosed(friday)
+	assertEqual(t, closed, true, "")
+	assertEqual(t, reason, "niente pranzo il venerdì", "")
+
+	c.Close(monday, "ponte")
+	closed, reason = c.IsClosed(monday)
+	assertEqual(t, closed, true, "")
+	assertEqual(t, reason, "ponte", "")
+	assertEqual(t, c.String(), "Niente pranzo ogni venerdì\n18/03/2019 - ponte", "")
+
+	b := brain.NewBrainMock()
+	c.Save(b)
+	var loaded Calendar
+	assertEqual(t, loaded.Load(b), nil, "")
+	loaded.Open(monday)
+	closed, _ = loaded.IsClosed(monday)
+	assertEqual(t, closed, false, "")
+}
+
+func TestEveryDay(t *testing.T) {
+	for arg, want := range map[string]bool{
+		"tutti":                       true,
+		"dom,lun,mar,mer,gio,ven,sab": true,
+		"lun,mar,mer,gio,ven,sab":     false,
+		"ven":                         false,
+	} {
+		mask, _ := parseWeekMask(arg)
+		assertEqual(t, everyDay(mask), want, arg)
+	}
+}
+
+func TestClearOldOrder(t *testing.T) {
+	b := brain.NewBrainMock()
+	cleared, err := ClearOldOrder(b)
+	assertEqual(t, cleared, false, "")
+	assertEqual(t, err, nil, "")
+
+	order := NewOrder()
+	order.Timestamp = order.Timestamp.AddDate(0, 0, -1)
+	order.Set(User{"mario", "U1"}, []UserChoice{{}})
+	order.Save(b)
+	cleared, err = ClearOldOrder(b)
+	assertEqual(t, cleared, true, "")
+	assertEqual(t, err, nil, "")
+
+	var loaded Order
+	loaded.Load(b)
+	assertEqual(t, loaded.IsUpdated(), true, "")
+	assertEqual(t, len(loaded.Users), 0, "")
+	cleared, _ = ClearOldOrder(b)
+	assertEqual(t, cleared, false, "")
+}
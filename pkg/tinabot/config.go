@@ -0,0 +1,68 @@
+package tinabot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/config"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// configString returns the keys of the configuration c with their values and
+// where they come from
+func configString(c *config.Config) string {
+	var b strings.Builder
+	b.WriteString("Configurazione:\n")
+	for _, k := range config.Keys {
+		v := c.Get(k.Name)
+		if v == "" {
+			v = "non impostato"
+		}
+		live := ""
+		if !k.Live {
+			live = ", solo al riavvio"
+		}
+		fmt.Fprintf(&b, "• `%s`: %s (%s%s)\n", k.Name, v, c.Source(k.Name), live)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// canConfig tells if user can change the configuration, shared by all the
+// offices: only the admins of all the channels can, or the admins of the
+// channel of msg until there are none. The workspaces installing the bot
+// through OAuth can't change the configuration of the others.
+func (t *TinaBot) canConfig(msg *slackbot.BotMsg, user *chat.User) bool {
+	if len(globalAdmins()) == 0 && !slackbot.IsInstalled(t.root, t.team) {
+		return t.canAdmin(msg, user)
+	}
+	return isAdmin(t.team, user.ID)
+}
+
+// ConfigCmd shows the configuration of the bot, or changes one of its live
+// keys for all the instances
+func (t *TinaBot) ConfigCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	f := strings.Fields(args[1])
+	if len(f) == 0 {
+		t.bot.Message(msg.Channel, configString(config.Current()))
+		return
+	}
+	if !t.canConfig(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori di tutti i canali possono cambiare la configurazione")
+		return
+	}
+	if len(f) < 2 {
+		t.bot.Message(msg.Channel, "Comando non valido, usa `config <chiave> <valore>` o `config <chiave> off`")
+		return
+	}
+
+	value := strings.Join(f[1:], " ")
+	if strings.EqualFold(value, "off") {
+		value = ""
+	}
+	if err := config.Set(t.root, f[0], value); err != nil {
+		t.bot.Message(msg.Channel, "Mi spiace, "+err.Error())
+		return
+	}
+	t.bot.Message(msg.Channel, "Ok\n"+configString(config.Current()))
+}
@@ -0,0 +1,60 @@
+package tinabot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/config"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// setConfig sets the environment variable key to value and reloads the
+// configuration, restoring both at the end of the test
+func setConfig(t *testing.T, key, value string) {
+	t.Cleanup(func() { config.Setup() })
+	t.Setenv(key, value)
+	if err := config.Setup(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigString(t *testing.T) {
+	setConfig(t, "ORDER_DEADLINE", "11:30")
+	s := configString(config.Current())
+	assertEqual(t, strings.Contains(s, "• `deadline`: 11:30 (env)"), true, s)
+	assertEqual(t, strings.Contains(s, "• `food_channel`: non impostato (default)"), true, s)
+	assertEqual(t, strings.Contains(s, "• `host`: non impostato (default, solo al riavvio)"), true, s)
+}
+
+func TestFeatures(t *testing.T) {
+	setConfig(t, "FEATURES", "-voti,-sondaggi")
+	bot := slackbot.New("B1", nil)
+	tb := New(bot, brain.NewBrainMock())
+	tb.AddCommands()
+
+	assertEqual(t, bot.Route("voto lasagne 5").Route == nil, true, "")
+	assertEqual(t, bot.Route("migliori piatti").Route == nil, true, "")
+	assertEqual(t, bot.Route("sondaggio").Route == nil, true, "")
+	assertEqual(t, bot.Route("link ospite").Route != nil, true, "")
+}
+
+func TestReminderDeadlineInherited(t *testing.T) {
+	setConfig(t, "ORDER_DEADLINE", "11:30")
+	b := brain.NewBrainMock()
+
+	var s ReminderSettings
+	s.Load(b)
+	assertEqual(t, s.Deadline, "11:30", "")
+	s.Channel = "C1"
+	assertEqual(t, s.Save(b), nil, "")
+
+	// the office follows the configuration until it sets its own deadline
+	setConfig(t, "ORDER_DEADLINE", "12:00")
+	s.Load(b)
+	assertEqual(t, s.Deadline, "12:00", "")
+	s.Deadline = "10:45"
+	assertEqual(t, s.Save(b), nil, "")
+	s.Load(b)
+	assertEqual(t, s.Deadline, "10:45", "")
+}
@@ -5,15 +5,14 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/nlopes/slack"
-
+	"github.com/develersrl/lunches/pkg/chat"
 	"github.com/develersrl/lunches/pkg/slackbot"
 	"github.com/robfig/cron"
 
 	"github.com/go-redis/redis"
 )
 
-func (t *TinaBot) Cron(bot *slackbot.Bot, msg *slackbot.BotMsg, user *slack.User, args ...string) {
+func (t *TinaBot) Cron(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
 
 	var crontab []string
 	if args[1] != "" {
@@ -0,0 +1,153 @@
+package tinabot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/events"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// noGroup is the label of the users without a delivery group in a split order
+const noGroup = "Senza gruppo"
+
+// DeliveryGroups maps each user key (see userKey) to the group, e.g. the
+// office or floor, where her lunch has to be delivered
+type DeliveryGroups map[string]string
+
+// Load loads the delivery groups from brain, empty if none is stored
+func (g *DeliveryGroups) Load(brain DataStore) error {
+	if err := brain.Get("groups", g); err != nil {
+		*g = DeliveryGroups{}
+		return err
+	}
+	if *g == nil {
+		*g = DeliveryGroups{}
+	}
+	return nil
+}
+
+// Save saves the delivery groups to brain
+func (g *DeliveryGroups) Save(brain DataStore) error {
+	return brain.Set("groups", *g)
+}
+
+// SetGroup sets the delivery group of user, an empty group removes it
+func (order *Order) SetGroup(user User, group string) {
+	if group == "" {
+		delete(order.Groups, user)
+		return
+	}
+	if order.Groups == nil {
+		order.Groups = make(map[User]string)
+	}
+	order.Groups[user] = group
+}
+
+// groupNames returns the sorted delivery groups of the users in the order,
+// users without a group are reported last as noGroup. Returns nil if no
+// user has a group.
+func (order *Order) groupNames() []string {
+	seen := make(map[string]bool)
+	ungrouped := false
+	for u := range order.Users {
+		if g, ok := order.Groups[u]; ok {
+			seen[g] = true
+		} else {
+			ungrouped = true
+		}
+	}
+	if len(seen) == 0 {
+		return nil
+	}
+
+	var names []string
+	for g := range seen {
+		names = append(names, g)
+	}
+	sort.Strings(names)
+	if ungrouped {
+		names = append(names, noGroup)
+	}
+	return names
+}
+
+// group returns the part of the order to deliver to group
+func (order *Order) group(group string) *Order {
+	sub := &Order{
+		State:     order.State,
+		Timestamp: order.Timestamp,
+		Dishes:    make(map[string][]User),
+		Users:     make(map[User]UserChoiceArray),
+		OrderedBy: make(map[User]User),
+		Aliases:   order.Aliases,
+	}
+	for _, u := range order.users() {
+		g, ok := order.Groups[u]
+		if (ok && g == group) || (!ok && group == noGroup) {
+			if by, ok := order.OrderedBy[u]; ok {
+				sub.SetBy(by, u, order.Users[u])
+			} else {
+				sub.Set(u, order.Users[u])
+			}
+		}
+	}
+	return sub
+}
+
+// formatGroups formats the order split by delivery group, each one with its
+// own total
+func (order *Order) formatGroups(groups []string, withUserNames, withPrices bool) string {
+	var r []string
+	for _, g := range groups {
+		r = append(r, fmt.Sprintf("*%s:*\n%s", g, order.group(g).format(withUserNames, withPrices)))
+	}
+	return strings.Join(r, "\n\n")
+}
+
+// Group shows or changes the delivery group of the user, today's order is
+// updated as well
+func (t *TinaBot) Group(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	me := User{user.Name, user.ID}
+	var groups DeliveryGroups
+	groups.Load(t.brain)
+
+	group := strings.TrimSpace(args[1])
+	if group == "" {
+		if g, ok := groups[userKey(me)]; ok {
+			t.bot.Message(msg.Channel, fmt.Sprintf("Il tuo gruppo di consegna è '%s'", g))
+		} else {
+			t.bot.Message(msg.Channel, "Non hai nessun gruppo di consegna")
+		}
+		return
+	}
+	if strings.EqualFold(group, "off") {
+		group = ""
+		delete(groups, userKey(me))
+	} else {
+		groups[userKey(me)] = group
+	}
+	if err := groups.Save(t.brain); err != nil {
+		t.bot.Message(msg.Channel, "Errore nel salvare il gruppo: "+err.Error())
+		return
+	}
+
+	var order Order
+	err := order.SaveCAS(t.brain, func(o *Order) error {
+		o.SetGroup(me, group)
+		return nil
+	})
+	if err != nil {
+		t.bot.Message(msg.Channel, "Errore nel salvare l'ordine: "+err.Error())
+		return
+	}
+	t.events.Publish(events.OrderUpdated, &order)
+
+	if group == "" {
+		t.bot.Message(msg.Channel, "Ok, non hai più un gruppo di consegna")
+		return
+	}
+	t.bot.Message(msg.Channel, fmt.Sprintf("Ok, i tuoi pranzi verranno consegnati al gruppo '%s'", group))
+}
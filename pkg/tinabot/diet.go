@@ -0,0 +1,173 @@
+package tinabot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// diet is a known dietary restriction, dishes are checked against it
+// looking for its keywords in their name
+type diet struct {
+	label        string
+	keywords     []string
+	vegetarianOK bool // dishes of type vegetariano are always fine
+}
+
+var (
+	meatKeywords = []string{"carne", "manzo", "vitello", "maiale", "pollo", "tacchino", "agnello", "coniglio",
+		"salsiccia", "prosciutto", "pancetta", "speck", "salame", "mortadella", "guanciale", "ragù", "ragu",
+		"bistecca", "tagliata", "arrosto", "polpett", "scaloppin", "cotoletta", "braciol", "bollito", "lampredotto", "trippa"}
+	fishKeywords = []string{"pesce", "tonno", "salmone", "merluzzo", "baccalà", "acciug", "alici", "gamber",
+		"cozze", "vongole", "calamar", "polpo", "seppi", "spada", "orata", "branzino"}
+	dairyKeywords = []string{"formaggio", "mozzarella", "parmigiano", "pecorino", "ricotta", "burrata",
+		"stracchino", "gorgonzola", "panna", "burro", "latte", "besciamella", "scamorza"}
+)
+
+// diets are the known restrictions, any other word stored in a profile is an
+// ingredient to avoid
+var diets = map[string]diet{
+	"vegetariano": {"dieta vegetariana", keywords(meatKeywords, fishKeywords), true},
+	"vegano":      {"dieta vegana", keywords(meatKeywords, fishKeywords, dairyKeywords, []string{"uova", "uovo", "frittata", "miele"}), false},
+	"maiale":      {"niente maiale", []string{"maiale", "salsiccia", "prosciutto", "pancetta", "speck", "salame", "mortadella", "guanciale", "porchetta", "cotechino"}, true},
+	"noci":        {"allergia alla frutta secca", []string{"noci", "nocciol", "mandorl", "pistacchi", "pinoli", "arachid", "anacardi", "pesto"}, false},
+	"glutine":     {"senza glutine", []string{"pasta", "pane", "panino", "penne", "spaghetti", "tagliatelle", "lasagn", "gnocchi", "ravioli", "tortelli", "farro", "orzo", "cous cous", "pizza", "focaccia", "impanat", "crostini", "torta"}, false},
+	"lattosio":    {"senza lattosio", dairyKeywords, false},
+}
+
+func keywords(lists ...[]string) []string {
+	var r []string
+	for _, l := range lists {
+		r = append(r, l...)
+	}
+	return r
+}
+
+// DietProfile lists the dietary restrictions of a user
+type DietProfile []string
+
+// LoadDietProfile loads the dietary profile of user from brain, empty if she has none
+func LoadDietProfile(brain DataStore, user User) DietProfile {
+	p, _ := dietRepo(brain).Get(userKey(user))
+	return p
+}
+
+// Save saves the dietary profile of user to brain
+func (p DietProfile) Save(brain DataStore, user User) error {
+	return dietRepo(brain).Put(userKey(user), p)
+}
+
+// Add adds a restriction to the profile, if not already there
+func (p DietProfile) Add(entry string) DietProfile {
+	for _, e := range p {
+		if e == entry {
+			return p
+		}
+	}
+	p = append(p, entry)
+	sort.Strings(p)
+	return p
+}
+
+// Remove removes a restriction from the profile, returns false if it wasn't there
+func (p DietProfile) Remove(entry string) (DietProfile, bool) {
+	for i, e := range p {
+		if e == entry {
+			return append(p[:i:i], p[i+1:]...), true
+		}
+	}
+	return p, false
+}
+
+// conflict returns the label of the first restriction of the profile broken by dish, if any
+func (p DietProfile) conflict(dish tuttobene.MenuRow) (string, bool) {
+	name := strings.ToLower(dish.Content)
+	for _, e := range p {
+		d, known := diets[e]
+		if !known {
+			d = diet{label: "da evitare", keywords: []string{e}}
+		}
+		if d.vegetarianOK && dish.Type == tuttobene.Vegetariano {
+			continue
+		}
+		for _, k := range d.keywords {
+			if strings.Contains(name, k) {
+				return fmt.Sprintf("%s (%s)", k, d.label), true
+			}
+		}
+	}
+	return "", false
+}
+
+// Conflicts returns a warning for each dish in choices, alternatives
+// included, that doesn't fit the profile
+func (p DietProfile) Conflicts(choices UserChoiceArray) []string {
+	var warnings []string
+	seen := make(map[string]bool)
+	check := func(c UserChoice) {
+		for _, d := range c.Dishes {
+			if seen[d.Content] {
+				continue
+			}
+			seen[d.Content] = true
+			if why, ok := p.conflict(d); ok {
+				warnings = append(warnings, fmt.Sprintf("'%s' contiene %s", d.Content, why))
+			}
+		}
+	}
+	for _, c := range choices {
+		check(c)
+		for _, a := range c.Alternatives {
+			check(a)
+		}
+	}
+	return warnings
+}
+
+func (p DietProfile) String() string {
+	if len(p) == 0 {
+		return "Nessuna preferenza alimentare"
+	}
+	var r []string
+	for _, e := range p {
+		if d, ok := diets[e]; ok {
+			r = append(r, d.label)
+		} else {
+			r = append(r, "niente "+e)
+		}
+	}
+	return "Preferenze alimentari: " + strings.Join(r, ", ")
+}
+
+// Diet shows or changes the dietary profile of the user
+func (t *TinaBot) Diet(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	me := User{user.Name, user.ID}
+	p := LoadDietProfile(t.brain, me)
+
+	f := strings.Fields(strings.ToLower(args[1]))
+	switch {
+	case len(f) == 0:
+		t.bot.Reply(msg, slackbot.Ephemeral, p.String())
+		return
+	case len(f) == 1 && f[0] == "off":
+		p = nil
+	case f[0] == "togli":
+		var ok bool
+		if p, ok = p.Remove(strings.Join(f[1:], " ")); !ok {
+			t.bot.Reply(msg, slackbot.Ephemeral, fmt.Sprintf("'%s' non è nelle tue preferenze", strings.Join(f[1:], " ")))
+			return
+		}
+	default:
+		p = p.Add(strings.Join(f, " "))
+	}
+
+	if err := p.Save(t.brain, me); err != nil {
+		t.bot.Reply(msg, slackbot.Ephemeral, "Errore nel salvare le preferenze: "+err.Error())
+		return
+	}
+	t.bot.Reply(msg, slackbot.Ephemeral, "Ok\n"+p.String())
+}
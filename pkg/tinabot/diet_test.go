@@ -0,0 +1,40 @@
+package tinabot
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestDietProfile(t *testing.T) {
+	var s, v, p UserChoice
+	s.Add(tuttobene.MenuRow{Content: "Salsiccia e fagioli", Type: tuttobene.Secondo})
+	v.Add(tuttobene.MenuRow{Content: "Sformato di formaggio", Type: tuttobene.Vegetariano})
+	p.Add(tuttobene.MenuRow{Content: "Penne al pesto", Type: tuttobene.Primo})
+	p.Alternatives = []UserChoice{s}
+
+	var profile DietProfile
+	assertEqual(t, len(profile.Conflicts(UserChoiceArray{s, v, p})), 0, "")
+
+	profile = profile.Add("vegetariano")
+	assertEqual(t, fmt.Sprint(profile.Conflicts(UserChoiceArray{s, v})), "['Salsiccia e fagioli' contiene salsiccia (dieta vegetariana)]", "")
+	assertEqual(t, len(profile.Conflicts(UserChoiceArray{p})), 1, "")
+
+	profile = profile.Add("noci").Add("noci")
+	assertEqual(t, len(profile), 2, "")
+	assertEqual(t, fmt.Sprint(profile.Conflicts(UserChoiceArray{p})), "['Penne al pesto' contiene pesto (allergia alla frutta secca) 'Salsiccia e fagioli' contiene salsiccia (dieta vegetariana)]", "")
+
+	profile, ok := profile.Remove("vegetariano")
+	assertEqual(t, ok, true, "")
+	profile = profile.Add("formaggio")
+	assertEqual(t, profile.String(), "Preferenze alimentari: niente formaggio, allergia alla frutta secca", "")
+	assertEqual(t, fmt.Sprint(profile.Conflicts(UserChoiceArray{v})), "['Sformato di formaggio' contiene formaggio (da evitare)]", "")
+
+	b := brain.NewBrainMock()
+	user := User{"guest_mario", ""}
+	assertEqual(t, len(LoadDietProfile(b, user)), 0, "")
+	assertEqual(t, profile.Save(b, user), nil, "")
+	assertEqual(t, fmt.Sprint([]string(LoadDietProfile(b, user))), "[formaggio noci]", "")
+}
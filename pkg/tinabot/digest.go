@@ -0,0 +1,175 @@
+package tinabot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// The emails of the digest
+const (
+	DigestMenu    = "menu"
+	DigestSummary = "riepilogo"
+)
+
+// Digest configures the emails sent to a mailing list, for the colleagues
+// who don't follow the channel: today's menu in the morning, from Time, and
+// the summary of the order at the deadline of the reminders
+type Digest struct {
+	Addresses []string
+	Time      string // "15:04" from when the menu is sent, as soon as it's known if empty
+
+	// the days of the last emails sent, each is sent once a day
+	MenuSent    string `json:",omitempty"`
+	SummarySent string `json:",omitempty"`
+}
+
+// Load loads the digest from brain, no emails are sent if missing
+func (d *Digest) Load(brain DataStore) error {
+	if err := brain.Get("digest", d); err != nil {
+		*d = Digest{}
+		return err
+	}
+	return nil
+}
+
+// Save saves the digest to brain
+func (d *Digest) Save(brain DataStore) error {
+	return brain.Set("digest", *d)
+}
+
+// DigestEmail is an email of the digest, Kind is DigestMenu or DigestSummary
+type DigestEmail struct {
+	Kind    string
+	Subject string
+	Body    string
+}
+
+// Due returns the emails of the digest to send at now and not sent yet
+// today: the menu once it's known, from Time, and the summary of the order
+// once the deadline has passed or the order has been sent, if anybody
+// ordered. Nothing is sent on the closing days.
+func (d *Digest) Due(brain DataStore, now time.Time) []DigestEmail {
+	if len(d.Addresses) == 0 {
+		return nil
+	}
+	var c Calendar
+	c.Load(brain)
+	if closed, _ := c.IsClosed(now); closed {
+		return nil
+	}
+
+	var emails []DigestEmail
+	day := dayKey(now)
+	if d.MenuSent != day {
+		from, ok := at(now, d.Time)
+		if menu, err := LoadMenu(brain, now); err == nil && len(menu.Rows) > 0 && (!ok || !now.Before(from)) {
+			emails = append(emails, DigestEmail{DigestMenu, "Menù del " + now.Format("02/01/2006"), menu.String()})
+		}
+	}
+
+	if d.SummarySent != day {
+		var order Order
+		order.Load(brain)
+		var settings ReminderSettings
+		settings.Load(brain)
+		deadline, ok := at(now, settings.Deadline)
+		closed := order.State >= Sent || (ok && !now.Before(deadline))
+		if closed && len(order.Users) > 0 && sameDay(order.Timestamp, now) {
+			var p Privacy
+			p.Load(brain)
+			body := fmt.Sprintf("Ordine del pranzo (%s), %d persone:\n\n%s", order.State, len(order.Users), order.Format(!p.Anonymous, false))
+			emails = append(emails, DigestEmail{DigestSummary, "Ordine del pranzo del " + now.Format("02/01/2006"), body})
+		}
+	}
+	return emails
+}
+
+// Sent records that the email of kind was sent on the day of now
+func (d *Digest) Sent(kind string, now time.Time) {
+	switch kind {
+	case DigestMenu:
+		d.MenuSent = dayKey(now)
+	case DigestSummary:
+		d.SummarySent = dayKey(now)
+	}
+}
+
+func (d *Digest) String() string {
+	if len(d.Addresses) == 0 {
+		return "Nessun digest via email impostato"
+	}
+	from := "appena disponibile"
+	if d.Time != "" {
+		from = "dalle " + d.Time
+	}
+	return fmt.Sprintf("Digest via email a %s: il menù di oggi %s e il riepilogo dell'ordine alla scadenza", strings.Join(d.Addresses, ", "), from)
+}
+
+// DigestCmd shows or changes the addresses and the time of the digest
+func (t *TinaBot) DigestCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	var d Digest
+	d.Load(t.brain)
+
+	f := strings.Fields(args[1])
+	if len(f) == 0 {
+		t.bot.Message(msg.Channel, d.String())
+		return
+	}
+	if !t.canAdmin(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono impostare il digest")
+		return
+	}
+
+	value := strings.Join(f[1:], " ")
+	switch strings.ToLower(f[0]) {
+	case "off":
+		d.Addresses = nil
+	case "ore":
+		if strings.ToLower(value) == "off" {
+			value = ""
+		} else if _, err := time.Parse("15:04", value); err != nil {
+			t.bot.Message(msg.Channel, "Orario non valido, usa il formato hh:mm")
+			return
+		}
+		d.Time = value
+	case "aggiungi":
+		addr := parseEmail(value)
+		if !strings.Contains(addr, "@") {
+			t.bot.Message(msg.Channel, "Indirizzo email non valido")
+			return
+		}
+		for _, a := range d.Addresses {
+			if strings.EqualFold(a, addr) {
+				t.bot.Message(msg.Channel, addr+" riceve già il digest")
+				return
+			}
+		}
+		d.Addresses = append(d.Addresses, addr)
+	case "togli":
+		addr := parseEmail(value)
+		var rest []string
+		for _, a := range d.Addresses {
+			if !strings.EqualFold(a, addr) {
+				rest = append(rest, a)
+			}
+		}
+		if len(rest) == len(d.Addresses) {
+			t.bot.Message(msg.Channel, addr+" non riceve il digest")
+			return
+		}
+		d.Addresses = rest
+	default:
+		t.bot.Message(msg.Channel, "Comando non valido, usa `digest aggiungi <indirizzo>`, `digest togli <indirizzo>`, `digest ore <hh:mm>|off` o `digest off`")
+		return
+	}
+
+	if err := d.Save(t.brain); err != nil {
+		t.bot.Message(msg.Channel, "Errore nel salvare il digest: "+err.Error())
+		return
+	}
+	t.bot.Message(msg.Channel, "Ok\n"+d.String())
+}
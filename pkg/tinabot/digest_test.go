@@ -0,0 +1,49 @@
+package tinabot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestDigestDue(t *testing.T) {
+	b := brain.NewBrainMock()
+	morning := time.Date(2019, 3, 15, 9, 0, 0, 0, time.UTC)
+
+	d := Digest{Time: "10:00"}
+	b.Set("menu", tuttobene.Menu{Date: morning, Rows: []tuttobene.MenuRow{{Content: "Risotto", Type: tuttobene.Primo}}})
+	assertEqual(t, len(d.Due(b, morning.Add(2*time.Hour))), 0, "no addresses")
+
+	d.Addresses = []string{"pranzo@example.com"}
+	assertEqual(t, len(d.Due(b, morning)), 0, "before the time")
+	emails := d.Due(b, morning.Add(time.Hour))
+	assertEqual(t, len(emails), 1, "")
+	assertEqual(t, emails[0].Kind, DigestMenu, "")
+	assertEqual(t, emails[0].Subject, "Menù del 15/03/2019", "")
+	d.Sent(DigestMenu, morning)
+	assertEqual(t, len(d.Due(b, morning.Add(time.Hour))), 0, "menu already sent")
+
+	settings := ReminderSettings{Deadline: "12:00"}
+	settings.Save(b)
+	order := NewOrder()
+	order.Timestamp = morning
+	var c UserChoice
+	c.Add(tuttobene.MenuRow{Content: "Risotto", Type: tuttobene.Primo})
+	order.Set(User{"mario", "U1"}, []UserChoice{c})
+	order.Save(b)
+	assertEqual(t, len(d.Due(b, morning.Add(2*time.Hour))), 0, "before the deadline")
+	emails = d.Due(b, morning.Add(3*time.Hour))
+	assertEqual(t, len(emails), 1, "")
+	assertEqual(t, emails[0].Kind, DigestSummary, "")
+	assertEqual(t, emails[0].Body, "Ordine del pranzo (aperto), 1 persone:\n\n"+order.Format(true, false), "")
+	d.Sent(DigestSummary, morning)
+	assertEqual(t, len(d.Due(b, morning.Add(3*time.Hour))), 0, "summary already sent")
+
+	d = Digest{Addresses: d.Addresses}
+	var cal Calendar
+	cal.Close(morning, "festa")
+	cal.Save(b)
+	assertEqual(t, len(d.Due(b, morning.Add(3*time.Hour))), 0, "closed")
+}
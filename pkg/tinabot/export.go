@@ -0,0 +1,134 @@
+package tinabot
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/tealeg/xlsx"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// exportUserRow is a dish ordered by a user
+type exportUserRow struct {
+	User      string
+	OrderedBy string
+	Dish      string
+	Price     decimal.Decimal
+}
+
+// exportDishRow is the total of a dish in the order
+type exportDishRow struct {
+	Dish  string
+	Count int
+	Total decimal.Decimal
+}
+
+func (order *Order) exportRows() ([]exportUserRow, []exportDishRow) {
+	var users []exportUserRow
+	for _, u := range order.users() {
+		by := ""
+		if b, ok := order.OrderedBy[u]; ok {
+			by = order.Name(b)
+		}
+		for _, c := range order.Users[u] {
+			users = append(users, exportUserRow{order.Name(u), by, c.String(), c.Price()})
+		}
+	}
+
+	var dishes []exportDishRow
+	for _, d := range order.sorted() {
+		r := exportDishRow{d, len(order.Dishes[d]), decimal.Zero}
+		for _, u := range order.Dishes[d] {
+			for _, c := range order.Users[u] {
+				if c.String() == d {
+					r.Total = r.Total.Add(c.Price())
+					break
+				}
+			}
+		}
+		dishes = append(dishes, r)
+	}
+	return users, dishes
+}
+
+// ExportCSV writes the order as CSV: one row for each dish of each user,
+// followed by the totals of each dish
+func (order *Order) ExportCSV(w io.Writer) error {
+	users, dishes := order.exportRows()
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"Utente", "Ordinato da", "Piatto", "Prezzo"})
+	for _, r := range users {
+		cw.Write([]string{r.User, r.OrderedBy, r.Dish, r.Price.StringFixed(2)})
+	}
+	cw.Write(nil)
+	cw.Write([]string{"Piatto", "Quantità", "Totale"})
+	for _, r := range dishes {
+		cw.Write([]string{r.Dish, strconv.Itoa(r.Count), r.Total.StringFixed(2)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func addXLSXRow(sheet *xlsx.Sheet, values ...interface{}) {
+	row := sheet.AddRow()
+	for _, v := range values {
+		cell := row.AddCell()
+		switch v := v.(type) {
+		case decimal.Decimal:
+			f, _ := v.Float64()
+			cell.SetFloatWithFormat(f, "0.00")
+		case int:
+			cell.SetInt(v)
+		default:
+			cell.SetString(fmt.Sprint(v))
+		}
+	}
+}
+
+// ExportXLSX writes the order as an Excel file with a sheet for the dishes of
+// each user and one for the totals of each dish
+func (order *Order) ExportXLSX(w io.Writer) error {
+	users, dishes := order.exportRows()
+
+	f := xlsx.NewFile()
+	sheet, err := f.AddSheet("Utenti")
+	if err != nil {
+		return err
+	}
+	addXLSXRow(sheet, "Utente", "Ordinato da", "Piatto", "Prezzo")
+	for _, r := range users {
+		addXLSXRow(sheet, r.User, r.OrderedBy, r.Dish, r.Price)
+	}
+
+	sheet, err = f.AddSheet("Piatti")
+	if err != nil {
+		return err
+	}
+	addXLSXRow(sheet, "Piatto", "Quantità", "Totale")
+	for _, r := range dishes {
+		addXLSXRow(sheet, r.Dish, r.Count, r.Total)
+	}
+
+	return f.Write(w)
+}
+
+// Export uploads today's order on the channel as a CSV or XLSX file, in the
+// background since the Excel files can take a while
+func (t *TinaBot) Export(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	format := strings.ToLower(strings.TrimSpace(args[1]))
+	if format == "" {
+		format = "xlsx"
+	}
+	if format != "csv" && format != "xlsx" {
+		t.bot.Message(msg.Channel, "Formato non supportato, usa `csv` o `xlsx`")
+		return
+	}
+	t.enqueue(msg.Channel, jobExport, exportJob{t.team, msg.Channel, user.ID, format})
+}
@@ -0,0 +1,116 @@
+package tinabot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/flags"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// flagChannel returns the channel where the flags of the messages of user in
+// the channel of msg are rolled out: the channel of their office for the
+// direct messages, so that the office tries the features all together
+func (t *TinaBot) flagChannel(msg *slackbot.BotMsg, user *chat.User) string {
+	if !isDirect(msg) {
+		return msg.Channel
+	}
+	if id, err := officeUserRepo(t.root).Get(officeID(t.team, user.ID)); err == nil {
+		return strings.TrimPrefix(id, t.team+":")
+	}
+	return msg.Channel
+}
+
+// flagEnabled tells if f is on for the messages of user in the channel of msg
+func (t *TinaBot) flagEnabled(f *flags.Flag, msg *slackbot.BotMsg, user *chat.User) bool {
+	return f.Enabled(t.root, t.flagChannel(msg, user))
+}
+
+// flagsString returns the flags with their rollout
+func (t *TinaBot) flagsString() string {
+	all := flags.All()
+	if len(all) == 0 {
+		return "Nessuna funzione sperimentale"
+	}
+	var b strings.Builder
+	b.WriteString("Funzioni sperimentali:")
+	for _, f := range all {
+		r, err := f.Get(t.root)
+		if err != nil {
+			fmt.Fprintf(&b, "\n• `%s`, %s: errore, %s", f.Name, f.Description, err)
+			continue
+		}
+		fmt.Fprintf(&b, "\n• `%s`, %s: %s", f.Name, f.Description, r)
+	}
+	return b.String()
+}
+
+// FlagCmd shows the experimental features and where they're on, or rolls one
+// out to a share of the channels, or turns it on or off in the channel
+func (t *TinaBot) FlagCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	f := strings.Fields(strings.ToLower(args[1]))
+	if len(f) == 0 {
+		t.bot.Message(msg.Channel, t.flagsString())
+		return
+	}
+	if len(f) < 2 || len(f) > 3 || (len(f) == 3 && f[2] != "qui") {
+		t.bot.Message(msg.Channel, "Comando non valido, usa `flag <nome> on|off|<percentuale>%` o `flag <nome> on|off|auto qui`")
+		return
+	}
+	flag, ok := flags.Lookup(f[0])
+	if !ok {
+		t.bot.Message(msg.Channel, "Mi spiace, la funzione `"+f[0]+"` non esiste, guarda `flag`")
+		return
+	}
+	here := len(f) == 3
+	if here && !t.canAdmin(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono attivare le funzioni sperimentali")
+		return
+	}
+	if !here && !t.canConfig(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori di tutti i canali possono attivare le funzioni sperimentali ovunque")
+		return
+	}
+
+	r, err := flag.Get(t.root)
+	if err != nil {
+		t.bot.Message(msg.Channel, "Errore: "+err.Error())
+		return
+	}
+	if here {
+		channel := t.flagChannel(msg, user)
+		if r.Channels == nil {
+			r.Channels = make(map[string]bool)
+		}
+		switch f[1] {
+		case "on", "off":
+			r.Channels[channel] = f[1] == "on"
+		case "auto":
+			delete(r.Channels, channel)
+		default:
+			t.bot.Message(msg.Channel, "Comando non valido, usa `flag <nome> on|off|auto qui`")
+			return
+		}
+	} else {
+		switch f[1] {
+		case "on":
+			r.Percent = 100
+		case "off":
+			r.Percent = 0
+		default:
+			p, err := strconv.Atoi(strings.TrimSuffix(f[1], "%"))
+			if err != nil {
+				t.bot.Message(msg.Channel, "Mi spiace, la percentuale `"+f[1]+"` non è un numero")
+				return
+			}
+			r.Percent = p
+		}
+	}
+	if err := flag.Set(t.root, r); err != nil {
+		t.bot.Message(msg.Channel, "Mi spiace, "+err.Error())
+		return
+	}
+	t.bot.Message(msg.Channel, "Ok\n"+t.flagsString())
+}
@@ -0,0 +1,32 @@
+package tinabot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/flags"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+func TestFlagChannel(t *testing.T) {
+	b := brain.NewBrainMock()
+	b.SAdd(officesKey, officeID("T1", "C1"))
+	tb := NewScoped(slackbot.New("B1", nil), b, "T1", "C1", "U1")
+	mario := &chat.User{ID: "U1", Name: "mario"}
+	luigi := &chat.User{ID: "U2", Name: "luigi"}
+
+	assertEqual(t, tb.flagChannel(&slackbot.BotMsg{Channel: "C2"}, mario), "C2", "")
+	// the direct messages follow the office of the user
+	assertEqual(t, tb.flagChannel(&slackbot.BotMsg{Channel: "D1"}, mario), "C1", "")
+	assertEqual(t, tb.flagChannel(&slackbot.BotMsg{Channel: "D2"}, luigi), "D2", "")
+
+	assertEqual(t, tb.flagEnabled(flagOrderMenu, &slackbot.BotMsg{Channel: "D1"}, mario), false, "")
+	flagOrderMenu.Set(b, flags.Rollout{Channels: map[string]bool{"C1": true}})
+	assertEqual(t, tb.flagEnabled(flagOrderMenu, &slackbot.BotMsg{Channel: "D1"}, mario), true, "")
+	assertEqual(t, tb.flagEnabled(flagOrderMenu, &slackbot.BotMsg{Channel: "C2"}, mario), false, "")
+
+	s := tb.flagsString()
+	assertEqual(t, strings.Contains(s, "• `menu-interattivo`, il menù con i pulsanti per scegliere i piatti, con `ordina`: spento, acceso in <#C1>"), true, s)
+}
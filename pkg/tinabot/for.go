@@ -1,51 +1,23 @@
 package tinabot
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"regexp"
 	"strings"
+	"time"
 
-	"github.com/nlopes/slack"
+	"go.opentelemetry.io/otel/attribute"
 
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/events"
+	"github.com/develersrl/lunches/pkg/grammar"
+	"github.com/develersrl/lunches/pkg/matcher"
 	"github.com/develersrl/lunches/pkg/slackbot"
 	"github.com/develersrl/lunches/pkg/tuttobene"
 )
 
-func unescape(s, sep string) string {
-
-	s = strings.Replace(s, "\\"+sep, sep, -1)
-	s = strings.Replace(s, "\\\\", "\\", -1)
-	return s
-}
-
-func splitEsc(s, sep string) []string {
-	escC := byte('\\')
-
-	n := strings.Count(s, sep)
-	var a []string
-	i := 0
-	start := 0
-	startcp := 0
-
-	for i < n {
-		m := strings.Index(s[start:], sep)
-		if m < 0 {
-			break
-		}
-		m += start
-		if m == 0 || (m > 0 && s[m-1] != escC) {
-			a = append(a, unescape(s[startcp:m], sep))
-			startcp = m + len(sep)
-		}
-		start = m + len(sep)
-		i++
-	}
-
-	a = append(a, unescape(s[startcp:], sep))
-	return a
-}
-
 func fuzzyMatch(dish, menuline string) bool {
 	dish = strings.ToLower(dish)
 
@@ -70,45 +42,173 @@ func findDishes(menu tuttobene.Menu, dish string) []tuttobene.MenuRow {
 	return matches
 }
 
-func getUserInfo(api *slack.Client, user string) *slack.User {
+// parseChoice builds a choice out of the dishes in req joined by "&", see matchChoice
+func parseChoice(m *matcher.Matcher, menu tuttobene.Menu, req string) (UserChoice, string, error) {
+	dishes, err := grammar.Default().ParseChoice(req)
+	if err != nil {
+		return UserChoice{}, "", errors.New(err.Error() + "\nOrdine non aggiunto!")
+	}
+	return matchChoice(m, menu, dishes)
+}
+
+// matchChoice builds a choice out of the dishes found in the menu, returns
+// the lines to add to the bot reply and an error message to show if the
+// dishes can't be ordered.
+func matchChoice(m *matcher.Matcher, menu tuttobene.Menu, dishes grammar.Choice) (UserChoice, string, error) {
+	var choice UserChoice
+	reply := ""
+
+	for _, d := range dishes {
+		dish, quoted := d.Text, d.Quoted
+
+		found := m.Match(menu, dish)
+		nDish := len(found)
+
+		if quoted && nDish != 1 {
+			p := tuttobene.MenuRow{
+				Content:         dish,
+				Type:            tuttobene.Empty,
+				IsDailyProposal: false,
+			}
+			reply = reply + fmt.Sprintf("Aggiungo testualmente: '%s'\n", dish)
+			choice.Add(p)
+		} else if nDish == 0 {
+			return choice, reply, errors.New("Non ho trovato nulla nel menù che corrisponda a '" + dish + "'\nOrdine non aggiunto!")
+		} else if nDish > 1 {
+			var matches []string
+			for _, d := range found {
+				matches = append(matches, d.Row.Content)
+			}
+
+			return choice, reply, &matcher.Ambiguous{Query: dish, Dishes: matches}
+		} else { // nDish == 1
+			d := found[0].Row
+			reply = reply + "Trovato: " + d.Content + fmt.Sprintf(" (%s)", tuttobene.Titles[d.Type])
+			if found[0].Learned {
+				reply = reply + ", come le altre volte"
+			}
+			reply = reply + "\n"
+
+			err := choice.Add(d)
+			if err != nil {
+				return choice, reply, errors.New("Errore nella personalizzazione: " + err.Error() + "\nOrdine non aggiunto!")
+			}
+		}
+	}
+	if choice.Customized() {
+		reply = reply + "Piatto personalizzato: " + choice.String() + "\n"
+	}
+	return choice, reply, nil
+}
+
+// parseOrder builds the choices of an order request out of menu. Removed
+// dishes are taken out of current, which is kept, otherwise the request
+// replaces it. Returns the lines to add to the bot reply and the error to
+// show if the order can't be placed.
+func (t *TinaBot) parseOrder(menu tuttobene.Menu, user User, dish string, current UserChoiceArray) ([]UserChoice, string, error) {
+	items, err := grammar.Default().Parse(strings.Replace(dish, "&amp;", "&", -1))
+	if err != nil {
+		return nil, "", errors.New(err.Error() + "\nOrdine non aggiunto!")
+	}
+
+	m := t.loadMatcher()
+	var choice []UserChoice
+	var removed []string
+	reply := ""
+	for _, item := range items {
+		if item.Remove {
+			removed = append(removed, item.Choices[0].String())
+			continue
+		}
+
+		var currChoice UserChoice
+		for i, alt := range item.Choices {
+			c, r, err := matchChoice(m, menu, alt)
+			reply = reply + r
+			if amb, ok := err.(*matcher.Ambiguous); ok {
+				t.addAmbiguous(user, amb)
+				return nil, reply, errors.New(err.Error() + "\nOrdine non aggiunto, prova ad essere più preciso!")
+			}
+			if err != nil {
+				return nil, reply, err
+			}
+			if i == 0 {
+				currChoice = c
+			} else {
+				reply = reply + "Alternativa: " + c.String() + "\n"
+				currChoice.Alternatives = append(currChoice.Alternatives, c)
+			}
+		}
+		if item.Note != "" {
+			reply = reply + fmt.Sprintf("Nota per %s: %s\n", currChoice.String(), item.Note)
+			currChoice.Note = item.Note
+		}
+		if item.Quantity > 1 {
+			reply = reply + fmt.Sprintf("Quantità: %d x %s\n", item.Quantity, currChoice.String())
+		}
+		for n := 0; n < item.Quantity; n++ {
+			choice = append(choice, currChoice)
+		}
+	}
+
+	// removing dishes changes the current order instead of replacing it
+	if len(removed) > 0 {
+		rest, err := removeChoices(current, removed)
+		if err != nil {
+			return nil, reply, errors.New(err.Error() + "\nOrdine non modificato!")
+		}
+		reply = reply + "Tolto: " + strings.Join(removed, ", ") + "\n"
+		choice = append(rest, choice...)
+	}
+	return choice, reply, nil
+}
+
+func getUserInfo(platform chat.Platform, user string) *chat.User {
 	if strings.HasPrefix(user, "<@") {
 		user = strings.Trim(user, "<@>")
-		u, err := api.GetUserInfo(user)
+		u, err := platform.UserInfo(user)
 		if err != nil {
-			log.Println(err)
+			slog.Warn("Error getting the user", "user", user, "err", err)
 			return nil
 		}
 		return u
 	}
 
-	users, err := api.GetUsers()
+	users, err := platform.Users()
 	if err != nil {
-		log.Println(err)
+		slog.Warn("Error getting the user", "user", user, "err", err)
 		return nil
 	}
 
 	for _, u := range users {
-		if strings.ToLower(u.Name) == strings.ToLower(user) {
+		if strings.EqualFold(u.Name, user) || strings.EqualFold(u.DisplayName, user) {
 			return &u
 		}
 	}
 	return nil
 }
 
-func (t *TinaBot) For(bot *slackbot.Bot, msg *slackbot.BotMsg, user *slack.User, args ...string) {
-	dest := args[1]
+func (t *TinaBot) For(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	dest := strings.TrimSuffix(args[1], ":")
 	dish := sanitize(args[2])
 
+	if loc, err := time.LoadLocation("Europe/Rome"); err == nil {
+		if date, ok := parseDay(dest, time.Now().In(loc)); ok {
+			t.forDay(msg, user, date, dish)
+			return
+		}
+	}
+
 	destUser := User{user.Name, user.ID}
 	destCh := ""
 
 	if strings.ToLower(dest) != "me" {
-		finduser := getUserInfo(t.bot.Client, dest)
+		finduser := t.findUser(dest)
 		if finduser != nil {
 			destUser = User{finduser.Name, finduser.ID}
-			_, _, ch, err := bot.Client.OpenIMChannel(destUser.ID)
+			ch, err := bot.Platform.DirectChannel(destUser.ID)
 			if err != nil {
-				log.Println(err)
+				t.logger().Error("Error opening the direct message", "err", err)
 			} else {
 				destCh = ch
 			}
@@ -122,9 +222,23 @@ func (t *TinaBot) For(bot *slackbot.Bot, msg *slackbot.BotMsg, user *slack.User,
 	}
 
 	if strings.ToLower(dish) == "niente" {
-		order := getOrder(t.brain)
-		old := order.ClearUser(destUser)
-		order.Save(t.brain)
+		var order Order
+		var old string
+		var prev UserChoiceArray
+		err := order.SaveCAS(t.brain, func(o *Order) error {
+			if err := o.Editable(); err != nil {
+				return err
+			}
+			prev = o.choicesOf(destUser)
+			old = o.ClearUser(destUser)
+			return nil
+		})
+		if err != nil {
+			t.bot.Message(msg.Channel, "Errore nel salvare l'ordine: "+err.Error())
+			return
+		}
+		t.record(destUser, prev)
+		t.events.Publish(events.OrderUpdated, &order)
 
 		t.bot.Message(msg.Channel, fmt.Sprintf("Ok, cancello ordine per %s:\n%s", destUser.Name, old))
 		if destCh != "" {
@@ -133,8 +247,12 @@ func (t *TinaBot) For(bot *slackbot.Bot, msg *slackbot.BotMsg, user *slack.User,
 		return
 	}
 
-	var menu tuttobene.Menu
-	err := t.brain.Get("menu", &menu)
+	if closed, reason := IsClosedToday(t.brain); closed {
+		t.bot.Message(msg.Channel, "Oggi non si ordina il pranzo: "+reason)
+		return
+	}
+
+	menu, err := todayMenu(t.brain)
 	if err != nil {
 		t.bot.Message(msg.Channel, "Nessun menù impostato!")
 		return
@@ -155,78 +273,147 @@ func (t *TinaBot) For(bot *slackbot.Bot, msg *slackbot.BotMsg, user *slack.User,
 			t.bot.Message(msg.Channel, fmt.Sprintf("E' necessario specificare da chi vuoi copiare l'ordine"))
 			return
 		}
-		finduser := getUserInfo(t.bot.Client, l[1])
-		name := User{Name: l[1], ID: ""}
-		if finduser != nil {
-			name = User{finduser.Name, finduser.ID}
-		}
+		when := strings.ToLower(strings.TrimSpace(strings.Join(l[1:], " ")))
+		if when == "ieri" || when == "settimana scorsa" {
+			var old UserChoiceArray
+			var date time.Time
+			var err error
+			if when == "ieri" {
+				old, date, err = LastHistory(t.brain, destUser, menu.Date)
+			} else {
+				date = menu.Date.AddDate(0, 0, -7)
+				old, err = LoadHistory(t.brain, destUser, date)
+			}
+			if err != nil || len(old) == 0 {
+				t.bot.Message(msg.Channel, fmt.Sprintf("Mi spiace, non trovo nessun ordine precedente di %s", destUser.Name))
+				return
+			}
 
-		order := getOrder(t.brain)
-		if newchoice, ok := order.Users[name]; ok {
-			reply = reply + fmt.Sprintf("Ok, copio l'ordine di %s:\n", name.Name)
-			for _, c := range newchoice {
+			var missing []string
+			var ambiguous []matcher.Ambiguous
+			choice, missing, ambiguous = repeatChoices(t.loadMatcher(), menu, old)
+			reply = reply + fmt.Sprintf("Ok, ripeto l'ordine di %s del %s:\n", destUser.Name, date.Format("02/01/2006"))
+			for _, c := range choice {
 				reply = reply + c.String() + "\n"
 			}
-			choice = newchoice
+			if len(missing) > 0 {
+				reply = reply + "Questi piatti non sono nel menù di oggi:\n" + strings.Join(missing, "\n") + "\n"
+			}
+			for i := range ambiguous {
+				t.addAmbiguous(User{user.Name, user.ID}, &ambiguous[i])
+				reply = reply + ambiguous[i].Error() + " Questo piatto non l'ho ripetuto\n"
+			}
+			if len(choice) == 0 {
+				t.bot.Message(msg.Channel, reply+"Ordine non aggiunto!")
+				return
+			}
 		} else {
-			t.bot.Message(msg.Channel, fmt.Sprintf("Mi spiace, ma non trovo l'utente '%s' nell'ordine", name.Name))
-			return
+			finduser := t.findUser(l[1])
+			name := User{Name: l[1], ID: ""}
+			if finduser != nil {
+				name = User{finduser.Name, finduser.ID}
+			}
+
+			order := getOrder(t.brain)
+			if newchoice, ok := order.Users[order.key(name)]; ok {
+				reply = reply + fmt.Sprintf("Ok, copio l'ordine di %s:\n", name.Name)
+				for _, c := range newchoice {
+					reply = reply + c.String() + "\n"
+				}
+				choice = newchoice
+			} else {
+				t.bot.Message(msg.Channel, fmt.Sprintf("Mi spiace, ma non trovo l'utente '%s' nell'ordine", name.Name))
+				return
+			}
 		}
 	} else {
-		reqs := splitEsc(dish, "+")
-
-		for _, req := range reqs {
-			dishes := splitEsc(req, "&amp;")
-			var currChoice UserChoice
-			for _, dish := range dishes {
-				dish = strings.TrimSpace(dish)
-
-				quoted := (dish[0] == '"' && dish[len(dish)-1] == '"')
-				dish = strings.Trim(dish, "\"")
-
-				found := findDishes(menu, dish)
-				nDish := len(found)
-
-				if quoted && nDish != 1 {
-					p := tuttobene.MenuRow{
-						Content:         dish,
-						Type:            tuttobene.Empty,
-						IsDailyProposal: false,
-					}
-					reply = reply + fmt.Sprintf("Aggiungo testualmente: '%s'\n", dish)
-					currChoice.Add(p)
-				} else if nDish == 0 {
-					t.bot.Message(msg.Channel, reply+"Non ho trovato nulla nel menù che corrisponda a '"+dish+"'\nOrdine non aggiunto!")
-					return
-				} else if nDish > 1 {
-					var matches []string
-					for _, d := range found {
-						matches = append(matches, d.Content)
-					}
-
-					t.bot.Message(msg.Channel, reply+"Cercando per '"+dish+"' ho trovato i seguenti piatti:\n"+strings.Join(matches, "\n")+"\n----\nOrdine non aggiunto, prova ad essere più preciso!")
-					return
-				} else { // nDish == 1
-					d := found[0]
-					reply = reply + "Trovato: " + d.Content + fmt.Sprintf(" (%s)\n", tuttobene.Titles[d.Type])
-
-					err := currChoice.Add(d)
-					if err != nil {
-						t.bot.Message(msg.Channel, reply+"Errore nella personalizzazione: "+err.Error()+"\nOrdine non aggiunto!")
-						return
-					}
-				}
+		var err error
+		choice, reply, err = t.parseOrder(menu, User{user.Name, user.ID}, dish, getOrder(t.brain).choicesOf(destUser))
+		if err != nil {
+			t.bot.Message(msg.Channel, reply+err.Error())
+			return
+		}
+	}
+
+	t.placeOrder(msg, user, destUser, destCh, choice, reply)
+}
+
+// placeOrder checks and saves the choice of destUser made by user,
+// notifying destUser in destCh if not empty. reply is prepended to the
+// answer.
+func (t *TinaBot) placeOrder(msg *slackbot.BotMsg, user *chat.User, destUser User, destCh string, choice []UserChoice, reply string) {
+	if current := getOrder(t.brain); len(current.Unavailable) > 0 {
+		for i, c := range choice {
+			a, ok := c.Available(current.Unavailable)
+			if !ok {
+				t.bot.Message(msg.Channel, reply+fmt.Sprintf("Mi spiace, %s è esaurito\nOrdine non aggiunto!", c.String()))
+				return
 			}
-			if currChoice.Customized() {
-				reply = reply + "Piatto personalizzato: " + currChoice.String() + "\n"
+			if !c.Contains(current.Unavailable) {
+				continue
 			}
-			choice = append(choice, currChoice)
+			reply = reply + fmt.Sprintf("%s è esaurito, ordino l'alternativa %s\n", c.String(), a.String())
+			choice[i] = a
+		}
+	}
+
+	var rules CompositionRules
+	rules.Load(t.brain)
+	if err := rules.Validate(choice); err != nil {
+		t.bot.Message(msg.Channel, reply+"Mi spiace, "+err.Error()+"\nOrdine non aggiunto!")
+		return
+	}
+
+	var policy Policy
+	policy.Load(t.brain)
+	total := UserChoiceArray(choice).Price()
+	if policy.OverBudget(total) {
+		if policy.Block {
+			t.bot.Message(msg.Channel, reply+fmt.Sprintf("L'ordine costa €%s e supera il budget giornaliero di €%s\nOrdine non aggiunto!", total.StringFixed(2), policy.Budget.StringFixed(2)))
+			return
 		}
+		reply = reply + fmt.Sprintf("Attenzione: l'ordine costa €%s e supera il budget giornaliero di €%s\n", total.StringFixed(2), policy.Budget.StringFixed(2))
+	}
+
+	if warnings := LoadDietProfile(t.brain, destUser).Conflicts(choice); len(warnings) > 0 {
+		reply = reply + "Attenzione, non rispetta le preferenze alimentari di " + destUser.Name + ":\n" +
+			strings.Join(warnings, "\n") + "\nSe è un errore usa `annulla`\n"
+	}
+
+	var settings ReminderSettings
+	settings.Load(t.brain)
+	if reason := lateReason(getOrder(t.brain), settings, romeNow()); reason != "" {
+		t.requestLate(msg, user, destUser, choice, reply, reason)
+		return
 	}
 
-	order := getOrder(t.brain)
-	list := order.Set(destUser, choice)
-	order.Save(t.brain)
+	var groups DeliveryGroups
+	groups.Load(t.brain)
+
+	var order Order
+	var list []string
+	var prev UserChoiceArray
+	end := t.bot.Trace("order.save", attribute.Int("order.dishes", len(choice)))
+	err := order.SaveCAS(t.brain, func(o *Order) error {
+		if err := o.Editable(); err != nil {
+			return err
+		}
+		prev = o.choicesOf(destUser)
+		list = o.SetBy(User{user.Name, user.ID}, destUser, choice)
+		if g, ok := groups[userKey(destUser)]; ok {
+			o.SetGroup(destUser, g)
+		}
+		return nil
+	})
+	end(err)
+	if err != nil {
+		t.bot.Message(msg.Channel, reply+"Errore nel salvare l'ordine: "+err.Error())
+		return
+	}
+	t.record(destUser, prev)
+	t.confirmMatches(User{user.Name, user.ID}, choice)
+	t.events.Publish(events.OrderUpdated, &order)
+	observeOrder("chat")
 
 	l := len(choice)
 	c := "o"
@@ -0,0 +1,139 @@
+package tinabot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/analytics"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// forecastSamples is how many past days the forecast averages
+const forecastSamples = 4
+
+// forecastWindow is how far back the history is read for a forecast, enough
+// to find forecastSamples days of the same weekday despite the closings
+const forecastWindow = 8 * 7
+
+// dayCounts returns how many people ordered and how many dishes of each
+// type in the days from the history, from start to end excluded
+func dayCounts(brain DataStore, start, end time.Time) ([]analytics.DayCount, error) {
+	history := historyRepo(brain)
+	ids, err := history.IDs()
+	if err != nil {
+		return nil, err
+	}
+	period := analytics.Period{Start: start, End: end}
+	var inPeriod []string
+	for _, id := range ids {
+		i := strings.LastIndex(id, ":")
+		if i < 0 {
+			continue
+		}
+		date, err := time.ParseInLocation("2006-01-02", id[i+1:], start.Location())
+		if err == nil && period.Contains(date) {
+			inPeriod = append(inPeriod, id)
+		}
+	}
+	// the days of the window are read all together
+	found, err := history.GetMany(inPeriod...)
+	if err != nil {
+		return nil, err
+	}
+
+	days := make(map[string]*analytics.DayCount)
+	for _, id := range inPeriod {
+		choices := found[id]
+		if len(choices) == 0 {
+			continue
+		}
+		day := id[strings.LastIndex(id, ":")+1:]
+		date, _ := time.ParseInLocation("2006-01-02", day, start.Location())
+		d, ok := days[day]
+		if !ok {
+			d = &analytics.DayCount{Date: date, Sections: make(map[tuttobene.MenuRowType]int)}
+			days[day] = d
+		}
+		d.People++
+		for _, c := range choices {
+			for _, r := range c.Dishes {
+				d.Sections[r.Type]++
+			}
+		}
+	}
+
+	counts := make([]analytics.DayCount, 0, len(days))
+	for _, d := range days {
+		counts = append(counts, *d)
+	}
+	return counts, nil
+}
+
+// ForecastDay returns the forecast of the order of date from the history of
+// the previous weeks
+func ForecastDay(brain DataStore, date time.Time) (analytics.Forecast, error) {
+	y, m, d := date.Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, date.Location())
+	counts, err := dayCounts(brain, day.AddDate(0, 0, -forecastWindow), day)
+	if err != nil {
+		return analytics.Forecast{Date: day}, err
+	}
+	return analytics.NewForecast(counts, day, forecastSamples), nil
+}
+
+// FormatForecast returns the expected people and dishes by section, to tell
+// the restaurant in advance, with how many already ordered in advance
+func FormatForecast(f analytics.Forecast, advance int) string {
+	day := formatDay(f.Date)
+	if len(f.Samples) == 0 {
+		return fmt.Sprintf("Non ci sono abbastanza ordini passati per una previsione di %s", day)
+	}
+	samples := make([]string, len(f.Samples))
+	for i, d := range f.Samples {
+		samples[i] = formatDay(d)
+	}
+	out := []string{fmt.Sprintf("*Previsione per %s*: circa %s, in base agli ordini di %s",
+		day, count(analytics.Round(f.People), "persona", "persone"), strings.Join(samples, ", "))}
+	for _, s := range f.Sections {
+		out = append(out, fmt.Sprintf("%s: circa %d", strings.Title(tuttobene.Titles[s.Type]), analytics.Round(s.Count)))
+	}
+	if advance > 0 {
+		out = append(out, fmt.Sprintf("Hanno già ordinato in anticipo: %s", count(advance, "persona", "persone")))
+	}
+	return strings.Join(out, "\n")
+}
+
+// ForecastCmd shows the expected order of a future day, tomorrow if not
+// given
+func (t *TinaBot) ForecastCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	loc, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		loc = time.Local
+	}
+	arg := strings.TrimSpace(args[1])
+	if arg == "" {
+		arg = "domani"
+	}
+	date, ok := parseDay(arg, time.Now().In(loc))
+	if !ok {
+		t.bot.Message(msg.Channel, fmt.Sprintf("Non capisco quale giorno sia '%s'", arg))
+		return
+	}
+
+	var c Calendar
+	c.Load(t.brain)
+	if closed, reason := c.IsClosed(date); closed {
+		t.bot.Message(msg.Channel, fmt.Sprintf("%s non si ordina il pranzo: %s", strings.Title(formatDay(date)), reason))
+		return
+	}
+
+	f, err := ForecastDay(t.brain, date)
+	if err != nil {
+		t.bot.Message(msg.Channel, "Errore nel calcolare la previsione: "+err.Error())
+		return
+	}
+	t.bot.Message(msg.Channel, FormatForecast(f, len(LoadAdvance(t.brain, date).Users)))
+}
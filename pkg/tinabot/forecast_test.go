@@ -0,0 +1,45 @@
+package tinabot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestForecast(t *testing.T) {
+	b := brain.NewBrainMock()
+	var primo, secondo UserChoice
+	primo.Add(tuttobene.MenuRow{Content: "primo", Type: tuttobene.Primo})
+	secondo.Add(tuttobene.MenuRow{Content: "secondo", Type: tuttobene.Secondo})
+	secondo.Add(tuttobene.MenuRow{Content: "contorno", Type: tuttobene.Contorno})
+
+	friday := time.Date(2019, 3, 29, 0, 0, 0, 0, time.UTC)
+	users := []User{{"mario", "U1"}, {"luigi", "U2"}, {"peach", "U3"}}
+	for w, n := range []int{3, 2, 1} {
+		order := NewOrder()
+		order.Timestamp = friday.AddDate(0, 0, -7*(w+1)).Add(13 * time.Hour)
+		for i, u := range users[:n] {
+			if i == 0 {
+				order.Set(u, []UserChoice{secondo})
+			} else {
+				order.Set(u, []UserChoice{primo})
+			}
+		}
+		assertEqual(t, SaveHistory(b, order), nil, "")
+	}
+
+	f, err := ForecastDay(b, friday.Add(10*time.Hour))
+	assertEqual(t, err, nil, "")
+	assertEqual(t, len(f.Samples), 3, "")
+	assertEqual(t, f.SameWeekday, true, "")
+
+	msg := FormatForecast(f, 1)
+	assertEqual(t, msg, "*Previsione per venerdì 29/03*: circa 2 persone, in base agli ordini di venerdì 22/03, venerdì 15/03, venerdì 08/03\n"+
+		"Primi Piatti: circa 1\nSecondi Piatti: circa 1\nContorni: circa 1\nHanno già ordinato in anticipo: 1 persona", "")
+
+	f, _ = ForecastDay(b, friday.AddDate(0, 0, -21))
+	assertEqual(t, strings.HasPrefix(FormatForecast(f, 0), "Non ci sono abbastanza ordini"), true, "")
+}
@@ -0,0 +1,467 @@
+package tinabot
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// Forgotten is how many items of a kind of data of a user were deleted
+type Forgotten struct {
+	Kind  string
+	Count int
+}
+
+// userData are the kinds of data kept per user, forget deletes the ones of
+// the user with key from brain and returns how many items it deleted
+var userData = []struct {
+	kind   string
+	forget func(brain CASStore, u User, key string) (int, error)
+}{
+	{"ordini nello storico", forgetHistory},
+	{"ordini di oggi e in anticipo", forgetOrders},
+	{"preferenze e impostazioni", forgetPreferences},
+	{"voti ai piatti", forgetRatings},
+	{"movimenti dei debiti", forgetLedger},
+}
+
+// ForgetUser deletes all the data of user from brain: the history of the
+// orders, the orders of today and in advance, the preferences, the ratings
+// and the debts. The data of the other users stays, e.g. the orders she
+// placed for them.
+func ForgetUser(brain CASStore, user User) ([]Forgotten, error) {
+	key := userKey(user)
+	var forgotten []Forgotten
+	for _, d := range userData {
+		n, err := d.forget(brain, user, key)
+		if err != nil {
+			return forgotten, fmt.Errorf("%s: %v", d.kind, err)
+		}
+		forgotten = append(forgotten, Forgotten{d.kind, n})
+	}
+	return forgotten, nil
+}
+
+// forgetHistory deletes the choices of the user in the history, her name and
+// the days she ordered first
+func forgetHistory(brain CASStore, u User, key string) (int, error) {
+	history := historyRepo(brain)
+	ids, err := history.IDs()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, id := range ids {
+		if strings.HasPrefix(id, key+":") {
+			if err := history.Delete(id); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+
+	firsts := firstRepo(brain)
+	days, err := firsts.All()
+	if err != nil {
+		return n, err
+	}
+	for day, first := range days {
+		if userKey(first) == key {
+			if err := firsts.Delete(day); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nameRepo(brain).Delete(key)
+}
+
+// removeUser removes the user with key from the order, and from the orders
+// she placed for the others; returns false if she wasn't in it
+func (order *Order) removeUser(key string) bool {
+	found := false
+	for u := range order.Users {
+		if userKey(u) == key {
+			order.ClearUser(u)
+			delete(order.Groups, u)
+			found = true
+		}
+	}
+	delete(order.Aliases, key)
+	for u, by := range order.OrderedBy {
+		if userKey(by) == key {
+			delete(order.OrderedBy, u)
+			found = true
+		}
+	}
+	if order.First != nil && userKey(*order.First) == key {
+		order.First = nil
+		found = true
+	}
+	return found
+}
+
+// forgetOrders removes the user from the order of today, the ones placed in
+// advance, their snapshots and the late requests
+func forgetOrders(brain CASStore, u User, key string) (int, error) {
+	n := 0
+	var saved snapshots
+	if brain.Get("order:snapshots", &saved) == nil {
+		removed := false
+		for name, o := range saved {
+			if o.removeUser(key) {
+				saved[name] = o
+				removed = true
+			}
+		}
+		if removed {
+			if err := brain.Set("order:snapshots", saved); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+
+	var order Order
+	if order.Load(brain) == nil && order.IsUpdated() {
+		removed := false
+		err := order.SaveCAS(brain, func(o *Order) error {
+			removed = o.removeUser(key)
+			return nil
+		})
+		if err != nil {
+			return n, err
+		}
+		if removed {
+			n++
+		}
+	}
+
+	ids, err := advanceRepo(brain).IDs()
+	if err != nil {
+		return n, err
+	}
+	for _, id := range ids {
+		// the other keys under "order:" are not orders
+		date, err := time.ParseInLocation("2006-01-02", id, time.Local)
+		if err != nil {
+			continue
+		}
+		if !LoadAdvance(brain, date).removeUser(key) {
+			continue
+		}
+		var advance Order
+		err = SaveAdvanceCAS(brain, date, &advance, func(o *Order) error {
+			o.removeUser(key)
+			return nil
+		})
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	var late LateRequests
+	removed := 0
+	err = late.SaveCAS(brain, func(l *LateRequests) error {
+		removed = 0
+		var pending []LateRequest
+		for _, r := range l.Pending {
+			if userKey(r.User) == key || userKey(r.By) == key {
+				removed++
+				continue
+			}
+			pending = append(pending, r)
+		}
+		l.Pending = pending
+		return nil
+	})
+	return n + removed, err
+}
+
+// forgetPreferences deletes the settings of the user and removes her from
+// the ones shared with the others
+func forgetPreferences(brain CASStore, u User, key string) (int, error) {
+	n := 0
+	for _, k := range []string{
+		dietRepo(brain).Key(key),
+		nutritionRepo(brain).Key(key),
+		journalRepo(brain).Key(key),
+		pendingMatchesRepo(brain).Key(key),
+		selectionRepo(brain).Key(key),
+		homeRepo(brain).Key(key),
+	} {
+		var v interface{}
+		if brain.Get(k, &v) != nil {
+			continue
+		}
+		if err := brain.Delete(k); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	// the reminders and the snoozes are by user ID
+	remind := make(map[string]int)
+	if brain.Get("remind", &remind) == nil {
+		if _, ok := remind[u.ID]; ok && u.ID != "" {
+			delete(remind, u.ID)
+			if err := brain.Set("remind", remind); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	var snoozes Snoozes
+	if snoozes.Load(brain) == nil {
+		if _, ok := snoozes[u.ID]; ok && u.ID != "" {
+			delete(snoozes, u.ID)
+			if err := snoozes.Save(brain); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+
+	var groups DeliveryGroups
+	if groups.Load(brain) == nil {
+		if _, ok := groups[key]; ok {
+			delete(groups, key)
+			if err := groups.Save(brain); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+
+	var a Accounting
+	if a.Load(brain) == nil {
+		if _, ok := a.Centers[key]; ok {
+			delete(a.Centers, key)
+			if err := a.Save(brain); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+
+	var p Poll
+	if p.Load(brain) == nil {
+		if _, ok := p.Votes[key]; ok {
+			err := p.SaveCAS(brain, func(p *Poll) error {
+				delete(p.Votes, key)
+				return nil
+			})
+			if err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// forgetRatings deletes the ratings of the user, and the dishes rated only
+// by her
+func forgetRatings(brain CASStore, u User, key string) (int, error) {
+	repo := ratingRepo(brain)
+	ratings, err := repo.All()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for dish, r := range ratings {
+		if _, ok := r.Ratings[key]; !ok {
+			continue
+		}
+		delete(r.Ratings, key)
+		if len(r.Ratings) == 0 {
+			err = repo.Delete(dish)
+		} else {
+			err = repo.Put(dish, r)
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// forgetLedger deletes the debts of the user and the ones owed to her
+func forgetLedger(brain CASStore, u User, key string) (int, error) {
+	n := 0
+	var ledger Ledger
+	err := ledger.SaveCAS(brain, func(l *Ledger) error {
+		n = 0
+		var entries []LedgerEntry
+		for _, e := range l.Entries {
+			if userKey(e.Debtor) == key || userKey(e.Creditor) == key {
+				n++
+				continue
+			}
+			entries = append(entries, e)
+		}
+		l.Entries = entries
+		return nil
+	})
+	return n, err
+}
+
+// forgetEverywhere forgets the user in the brain of each office and in the
+// shared one, with the office she chose and the guest links she created
+func (t *TinaBot) forgetEverywhere(user User) ([]Forgotten, error) {
+	stores := []CASStore{t.root}
+	ids, err := Offices(t.root)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if s, ok := OfficeStore(t.root, id); ok {
+			stores = append(stores, s)
+		}
+	}
+	if slackbot.IsInstalled(t.root, t.team) {
+		stores = append(stores, brain.Namespace(t.root, TeamNamespace(t.team)))
+	}
+
+	var total []Forgotten
+	for _, s := range stores {
+		forgotten, err := ForgetUser(s, user)
+		if err != nil {
+			return nil, err
+		}
+		if total == nil {
+			total = forgotten
+			continue
+		}
+		for i, f := range forgotten {
+			total[i].Count += f.Count
+		}
+	}
+
+	n, err := forgetVersions(t.root, user)
+	if err != nil {
+		return nil, err
+	}
+	total = append(total, Forgotten{"versioni precedenti dei dati", n})
+
+	if user.ID != "" {
+		officeUserRepo(t.root).Delete(officeID(t.team, user.ID))
+	}
+	links := guestLinkRepo(t.root)
+	if all, err := links.All(); err == nil {
+		for token, l := range all {
+			if userKey(l.By) == userKey(user) {
+				links.Delete(token)
+			}
+		}
+	}
+	return total, nil
+}
+
+// forgetVersions deletes the previous versions of the values of the user
+// kept with BRAIN_VERSIONS, see brain.Versions: the ones of her keys and the
+// ones mentioning her, in the brain of every office
+func forgetVersions(root DataStore, u User) (int, error) {
+	keys, err := root.Keys(brain.VersionsPrefix + "*")
+	if err != nil {
+		return 0, err
+	}
+	key := userKey(u)
+	text, _ := u.MarshalText()
+	names := map[string]bool{key: true, string(text): true}
+	n := 0
+	for _, k := range keys {
+		var versions []brain.Version
+		if root.Get(k, &versions) != nil {
+			continue
+		}
+		mentioned := strings.Contains(k+":", ":"+key+":")
+		for _, v := range versions {
+			var val interface{}
+			if json.Unmarshal(v.Value, &val) == nil && mentions(val, names) {
+				mentioned = true
+			}
+		}
+		if !mentioned {
+			continue
+		}
+		if err := root.Delete(k); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// mentions tells if one of names is a string or a key of the JSON value val
+func mentions(val interface{}, names map[string]bool) bool {
+	switch v := val.(type) {
+	case string:
+		return names[v]
+	case []interface{}:
+		for _, e := range v {
+			if mentions(e, names) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		for k, e := range v {
+			if names[k] || mentions(e, names) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// formatForgotten lists how many items of each kind were deleted
+func formatForgotten(forgotten []Forgotten) string {
+	lines := make([]string, len(forgotten))
+	for i, f := range forgotten {
+		lines[i] = fmt.Sprintf("%s: %d", strings.ToUpper(f.Kind[:1])+f.Kind[1:], f.Count)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// forget deletes the data of user after asking to confirm by repeating cmd,
+// answering in the channel of msg
+func (t *TinaBot) forget(msg *slackbot.BotMsg, user User, confirm, cmd, whose string) {
+	if confirm != "conferma" {
+		t.bot.Message(msg.Channel, fmt.Sprintf("Cancellerò %s: lo storico degli ordini, gli ordini di oggi e in anticipo, le preferenze, i voti ai piatti e i debiti. "+
+			"Non si può annullare, scrivi `%s conferma` per procedere", whose, cmd))
+		return
+	}
+	forgotten, err := t.forgetEverywhere(user)
+	if err != nil {
+		t.logger().Error("Error forgetting a user", "forgotten", userKey(user), "err", err)
+		t.bot.Message(msg.Channel, "Errore nel cancellare i dati: "+err.Error())
+		return
+	}
+	t.logger().Info("Forgot the data of a user", "forgotten", userKey(user))
+	t.bot.Message(msg.Channel, "Ok, dati cancellati\n"+formatForgotten(forgotten)+
+		"\nI backup del brain li conservano ancora, finché non vengono sostituiti dai nuovi")
+}
+
+// ForgetMe deletes all the data of the user
+func (t *TinaBot) ForgetMe(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	t.forget(msg, User{user.Name, user.ID}, strings.ToLower(strings.TrimSpace(args[1])), "dimenticami", "tutti i tuoi dati")
+}
+
+// Forget deletes all the data of another user, for the admins
+func (t *TinaBot) Forget(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	if !t.canAdmin(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono cancellare i dati degli altri")
+		return
+	}
+	target := User{args[1], ""}
+	if u := getUserInfo(t.bot.Platform, args[1]); u != nil {
+		target = User{u.Name, u.ID}
+	}
+	t.forget(msg, target, strings.ToLower(args[2]), "dimentica "+args[1], "tutti i dati di "+target.Name)
+}
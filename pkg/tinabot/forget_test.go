@@ -0,0 +1,122 @@
+package tinabot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestForgetUser(t *testing.T) {
+	b := brain.NewBrainMock()
+	mario := User{"mario", "U1"}
+	luigi := User{"luigi", "U2"}
+	var c UserChoice
+	c.Add(tuttobene.MenuRow{Content: "primo", Type: tuttobene.Primo, Price: decimal.New(5, 0)})
+
+	past := NewOrder()
+	past.Timestamp = time.Date(2019, 3, 11, 13, 0, 0, 0, time.UTC)
+	past.Set(mario, []UserChoice{c})
+	past.Set(luigi, []UserChoice{c})
+	assertEqual(t, SaveHistory(b, past), nil, "")
+
+	today := NewOrder()
+	today.SetBy(mario, luigi, []UserChoice{c})
+	today.Set(mario, []UserChoice{c})
+	assertEqual(t, today.Save(b), nil, "")
+	assertEqual(t, today.Snapshot(b, "prima"), nil, "")
+
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	var advance Order
+	assertEqual(t, SaveAdvanceCAS(b, tomorrow, &advance, func(o *Order) error {
+		o.Set(mario, []UserChoice{c})
+		return nil
+	}), nil, "")
+
+	assertEqual(t, DietProfile{}.Add("vegetariano").Save(b, mario), nil, "")
+	assertEqual(t, rate(b, mario, "primo", Rating{Stars: 5, Day: "2019-03-11"}), nil, "")
+	assertEqual(t, rate(b, luigi, "secondo", Rating{Stars: 3, Day: "2019-03-11"}), nil, "")
+	ledger := Ledger{}
+	ledger.AddOrder(luigi, past, past.Timestamp)
+	assertEqual(t, ledger.Save(b), nil, "")
+	assertEqual(t, (&DeliveryGroups{"U1": "A", "U2": "B"}).Save(b), nil, "")
+
+	forgotten, err := ForgetUser(b, mario)
+	assertEqual(t, err, nil, "")
+	counts := make(map[string]int)
+	for _, f := range forgotten {
+		counts[f.Kind] = f.Count
+	}
+	assertEqual(t, counts["ordini nello storico"], 1, "")
+	assertEqual(t, counts["ordini di oggi e in anticipo"], 3, "with the snapshot")
+	assertEqual(t, counts["preferenze e impostazioni"], 2, "diet and group")
+	assertEqual(t, counts["voti ai piatti"], 1, "")
+	assertEqual(t, counts["movimenti dei debiti"], 1, "")
+
+	_, err = LoadHistory(b, mario, past.Timestamp)
+	assertEqual(t, err != nil, true, "history deleted")
+	_, err = LoadHistory(b, luigi, past.Timestamp)
+	assertEqual(t, err, nil, "the others' history stays")
+	_, err = firstRepo(b).Get(dayKey(past.Timestamp))
+	assertEqual(t, err != nil, true, "mario ordered first")
+
+	var order Order
+	assertEqual(t, order.Load(b), nil, "")
+	assertEqual(t, order.Ordered(mario), false, "")
+	assertEqual(t, order.Ordered(luigi), true, "the order for luigi stays")
+	assertEqual(t, len(order.OrderedBy), 0, "")
+	assertEqual(t, order.First == nil, true, "")
+	assertEqual(t, LoadAdvance(b, tomorrow).Ordered(mario), false, "")
+	snapshot := loadSnapshots(b)["prima"]
+	assertEqual(t, snapshot.Ordered(mario), false, "")
+	assertEqual(t, snapshot.Ordered(luigi), true, "")
+
+	_, err = ratingRepo(b).Get(tuttobene.CanonicalName("primo"))
+	assertEqual(t, err != nil, true, "the dish rated only by mario")
+	ledger.Load(b)
+	assertEqual(t, len(ledger.Entries), 0, "")
+	var groups DeliveryGroups
+	groups.Load(b)
+	assertEqual(t, len(groups), 1, "")
+
+	forgotten, err = ForgetUser(b, mario)
+	assertEqual(t, err, nil, "")
+	for _, f := range forgotten {
+		assertEqual(t, f.Count, 0, f.Kind)
+	}
+}
+
+func TestForgetVersions(t *testing.T) {
+	b := brain.Versions(brain.NewMemory(), 3)
+	mario := User{"mario", "U1"}
+	luigi := User{"luigi", "U2"}
+	var c UserChoice
+	c.Add(tuttobene.MenuRow{Content: "primo", Type: tuttobene.Primo})
+
+	order := NewOrder()
+	order.Set(mario, []UserChoice{c})
+	assertEqual(t, order.Save(b), nil, "")
+	order.ClearUser(mario)
+	order.Set(luigi, []UserChoice{c})
+	assertEqual(t, order.Save(b), nil, "")
+	assertEqual(t, DietProfile{}.Add("vegetariano").Save(b, mario), nil, "")
+	assertEqual(t, dietRepo(b).Delete("U1"), nil, "")
+	assertEqual(t, b.Set("menu", "uno"), nil, "")
+	assertEqual(t, b.Set("menu", "due"), nil, "")
+
+	n, err := forgetVersions(b, mario)
+	assertEqual(t, err, nil, "")
+	assertEqual(t, n, 2, "the order and the diet")
+	versions, _ := b.History("order")
+	assertEqual(t, len(versions), 0, "")
+	versions, _ = b.History(dietRepo(b).Key("U1"))
+	assertEqual(t, len(versions), 0, "")
+	versions, _ = b.History("menu")
+	assertEqual(t, len(versions), 2, "the versions of the others stay")
+	// the versions deleted are not kept as versions
+	keys, _ := b.Keys(brain.VersionsPrefix + brain.VersionsPrefix + "*")
+	assertEqual(t, len(keys), 0, "")
+}
@@ -0,0 +1,244 @@
+package tinabot
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/config"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// guestLinkTTL is how long a guest link can be used
+const guestLinkTTL = 4 * time.Hour
+
+// maxGuestName is the longest name of a guest
+const maxGuestName = 30
+
+// ErrGuestLink is returned for the guest links expired, already used or
+// never existed
+var ErrGuestLink = errors.New("il link non è valido o è scaduto")
+
+// GuestLink lets a visitor without Slack place one order from the web, on
+// behalf of the user who created it and pays for it. The links are kept in
+// the root brain, by token, with the office where the order goes.
+type GuestLink struct {
+	By      User
+	Team    string
+	Channel string
+	// Claimed is set while the order of the link is being placed, so that
+	// it is placed only once
+	Claimed bool
+}
+
+// guestLinkRepo keeps the guest links by token, in the root brain
+func guestLinkRepo(b DataStore) brain.Repo[GuestLink] {
+	return brain.NewRepo[GuestLink](b, "guest:")
+}
+
+// newGuestToken returns a random token, hard to guess
+func newGuestToken() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// guestURL returns the address of the page of the guest link on the public
+// address of the app, see config.Config.Host
+func guestURL(token string) (string, bool) {
+	host := config.Current().Host
+	if host == "" {
+		return "", false
+	}
+	return host + "/guest/" + token, true
+}
+
+// loadGuestLink returns the link of token and the brain of its office
+func loadGuestLink(root brain.Store, token string) (GuestLink, brain.Store, error) {
+	l, err := guestLinkRepo(root).Get(token)
+	if err != nil || l.Claimed {
+		return l, nil, ErrGuestLink
+	}
+	return l, Scope(root, l.Team, l.Channel, l.By.ID), nil
+}
+
+// claimGuestLink atomically marks the link of token as claimed and returns
+// it with the brain of its office, ErrGuestLink if another order claimed
+// it first. The link keeps its expiration, see releaseGuestLink.
+func claimGuestLink(root brain.Store, token string) (GuestLink, brain.Store, error) {
+	var l GuestLink
+	err := root.Update(guestLinkRepo(root).Key(token), &l, func() error {
+		if l.By.Name == "" || l.Claimed {
+			return ErrGuestLink
+		}
+		l.Claimed = true
+		return nil
+	})
+	if err != nil {
+		return l, nil, ErrGuestLink
+	}
+	return l, Scope(root, l.Team, l.Channel, l.By.ID), nil
+}
+
+// releaseGuestLink makes the link of token usable again, after its order
+// failed
+func releaseGuestLink(root brain.Store, token string) {
+	var l GuestLink
+	err := root.Update(guestLinkRepo(root).Key(token), &l, func() error {
+		if l.By.Name == "" {
+			return ErrGuestLink
+		}
+		l.Claimed = false
+		return nil
+	})
+	if err != nil {
+		slog.Error("Error releasing the guest link", "err", err)
+	}
+}
+
+// openMenu returns today's menu if it can be ordered from now, outside of
+// the chat
+func openMenu(b DataStore, now time.Time) (tuttobene.Menu, error) {
+	if closed, reason := IsClosedToday(b); closed {
+		return tuttobene.Menu{}, fmt.Errorf("oggi non si ordina il pranzo: %s", reason)
+	}
+	menu, err := todayMenu(b)
+	if err != nil || !menu.IsUpdated() {
+		return tuttobene.Menu{}, errors.New("il menù di oggi non è ancora disponibile")
+	}
+	var settings ReminderSettings
+	settings.Load(b)
+	if reason := lateReason(getOrder(b), settings, now); reason != "" {
+		return tuttobene.Menu{}, errors.New("non si può più ordinare, " + reason)
+	}
+	return menu, nil
+}
+
+// GuestMenu returns who invited the guest of the link of token and the menu
+// she can order from
+func GuestMenu(root brain.Store, token string) (GuestLink, tuttobene.Menu, error) {
+	l, b, err := loadGuestLink(root, token)
+	if err != nil {
+		return l, tuttobene.Menu{}, err
+	}
+	menu, err := openMenu(b, romeNow())
+	return l, menu, err
+}
+
+// menuChoices returns a choice for each dish of menu at the indexes rows
+func menuChoices(menu tuttobene.Menu, rows []int) ([]UserChoice, error) {
+	var choices []UserChoice
+	for _, i := range rows {
+		if i < 0 || i >= len(menu.Rows) || menu.Rows[i].Type == tuttobene.Empty || menu.Rows[i].Type == tuttobene.Unknonwn {
+			return nil, errors.New("il menù è cambiato, ricarica la pagina")
+		}
+		var c UserChoice
+		c.Add(menu.Rows[i])
+		choices = append(choices, c)
+	}
+	return choices, nil
+}
+
+// guestUser returns the user of the guest named name, tagged as a guest
+// like the ones ordered for with "per guest_<nome>"
+func guestUser(name string) (User, error) {
+	name = strings.Join(strings.Fields(name), "_")
+	if name == "" || len([]rune(name)) > maxGuestName || strings.ContainsAny(name, "<>@*`") {
+		return User{}, fmt.Errorf("nome non valido, al massimo %d caratteri senza simboli", maxGuestName)
+	}
+	if !strings.HasPrefix(name, "guest_") {
+		name = "guest_" + name
+	}
+	return User{Name: name}, nil
+}
+
+// PlaceGuestOrder places the order of the guest named name with the dishes
+// of today's menu at the indexes rows, on behalf of the user who created the
+// link of token. The link can be used only once. It returns the link and
+// the order placed, to tell the channel.
+func PlaceGuestOrder(root brain.Store, token, name string, rows []int) (GuestLink, string, error) {
+	l, _, err := loadGuestLink(root, token)
+	if err != nil {
+		return l, "", err
+	}
+	guest, err := guestUser(name)
+	if err != nil {
+		return l, "", err
+	}
+	// the link is claimed before placing the order, so that two requests
+	// with the same token can't both place one
+	l, b, err := claimGuestLink(root, token)
+	if err != nil {
+		return l, "", err
+	}
+	list, err := placeGuestOrder(b, l, guest, rows)
+	if err != nil {
+		releaseGuestLink(root, token)
+		return l, "", err
+	}
+	observeOrder("guest")
+	if err := guestLinkRepo(root).Delete(token); err != nil {
+		slog.Error("Error deleting the guest link", "err", err)
+	}
+	return l, fmt.Sprintf("%s (ospite di %s) ha ordinato:\n%s", guest.Name, l.By.Name, strings.Join(list, "\n")), nil
+}
+
+// placeGuestOrder places the order of guest with the dishes of today's menu
+// at the indexes rows in b, on behalf of the user who created l
+func placeGuestOrder(b brain.Store, l GuestLink, guest User, rows []int) ([]string, error) {
+	menu, err := openMenu(b, romeNow())
+	if err != nil {
+		return nil, err
+	}
+	choices, err := menuChoices(menu, rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(choices) == 0 {
+		return nil, errors.New("scegli almeno un piatto")
+	}
+
+	var order Order
+	var list []string
+	err = order.SaveCAS(b, func(o *Order) error {
+		if err := o.Editable(); err != nil {
+			return err
+		}
+		if o.Ordered(guest) {
+			return fmt.Errorf("c'è già un ordine a nome di %s", guest.Name)
+		}
+		list = o.SetBy(l.By, guest, choices)
+		return nil
+	})
+	return list, err
+}
+
+// GuestLinkCmd creates a link to let a guest without Slack order once from
+// the web, the order is charged to the user
+func (t *TinaBot) GuestLinkCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	token, err := newGuestToken()
+	if err != nil {
+		t.bot.Message(msg.Channel, "Errore nel creare il link: "+err.Error())
+		return
+	}
+	url, ok := guestURL(token)
+	if !ok {
+		t.bot.Message(msg.Channel, "Mi spiace, non conosco l'indirizzo del sito per creare il link")
+		return
+	}
+	l := GuestLink{By: User{user.Name, user.ID}, Team: t.team, Channel: msg.Channel}
+	if err := t.root.SetWithTTL(guestLinkRepo(t.root).Key(token), l, guestLinkTTL); err != nil {
+		t.bot.Message(msg.Channel, "Errore nel salvare il link: "+err.Error())
+		return
+	}
+	t.bot.Reply(msg, slackbot.Ephemeral, fmt.Sprintf("Ecco il link per il tuo ospite, vale per un solo ordine per %d ore e il conto è a tuo carico:\n%s", int(guestLinkTTL.Hours()), url))
+}
@@ -0,0 +1,77 @@
+package tinabot
+
+import (
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestGuestUser(t *testing.T) {
+	u, err := guestUser("  Anna  Bianchi ")
+	assertEqual(t, err, nil, "")
+	assertEqual(t, u, User{Name: "guest_Anna_Bianchi"}, "")
+	u, _ = guestUser("guest_anna")
+	assertEqual(t, u.Name, "guest_anna", "")
+	_, err = guestUser("<@U1>")
+	assertEqual(t, err != nil, true, "")
+	_, err = guestUser(" ")
+	assertEqual(t, err != nil, true, "")
+}
+
+func TestPlaceGuestOrder(t *testing.T) {
+	b := brain.NewBrainMock()
+	today := NewOrder().Timestamp
+	b.Set("menu", tuttobene.Menu{Date: today, Rows: []tuttobene.MenuRow{
+		{Content: "Primi", Type: tuttobene.Empty},
+		{Content: "Risotto", Type: tuttobene.Primo},
+		{Content: "Pollo", Type: tuttobene.Secondo},
+	}})
+	by := User{"mario", "U1"}
+	guestLinkRepo(b).Put("tok", GuestLink{By: by, Channel: "C1"})
+
+	_, _, err := PlaceGuestOrder(b, "nope", "anna", []int{1})
+	assertEqual(t, err, ErrGuestLink, "")
+	_, _, err = PlaceGuestOrder(b, "tok", "anna", []int{0})
+	assertEqual(t, err != nil, true, "")
+	_, _, err = PlaceGuestOrder(b, "tok", "anna", nil)
+	assertEqual(t, err != nil, true, "")
+
+	l, placed, err := PlaceGuestOrder(b, "tok", "anna", []int{1, 2})
+	assertEqual(t, err, nil, "")
+	assertEqual(t, l.Channel, "C1", "")
+	assertEqual(t, placed, "guest_anna (ospite di mario) ha ordinato:\nRisotto\nPollo", "")
+
+	order := getOrder(b)
+	guest := User{Name: "guest_anna"}
+	assertEqual(t, len(order.Users[guest]), 2, "")
+	assertEqual(t, order.Payer(guest), by, "")
+
+	// the link places a single order
+	_, _, err = PlaceGuestOrder(b, "tok", "luca", []int{1})
+	assertEqual(t, err, ErrGuestLink, "")
+}
+
+func TestClaimGuestLink(t *testing.T) {
+	b := brain.NewMemory()
+	b.SetWithTTL(guestLinkRepo(b).Key("tok"), GuestLink{By: User{"mario", "U1"}, Channel: "C1"}, guestLinkTTL)
+
+	l, _, err := claimGuestLink(b, "tok")
+	assertEqual(t, err, nil, "")
+	assertEqual(t, l.By.Name, "mario", "")
+	// another request with the same token is refused while the order is placed
+	_, _, err = claimGuestLink(b, "tok")
+	assertEqual(t, err, ErrGuestLink, "")
+	_, _, err = PlaceGuestOrder(b, "tok", "anna", []int{1})
+	assertEqual(t, err, ErrGuestLink, "")
+
+	// a failed order gives the link back, with its expiration
+	releaseGuestLink(b, "tok")
+	_, _, err = claimGuestLink(b, "tok")
+	assertEqual(t, err, nil, "")
+	ttl, _ := b.TTL(guestLinkRepo(b).Key("tok"))
+	assertEqual(t, ttl > 0 && ttl <= guestLinkTTL, true, "")
+
+	_, _, err = claimGuestLink(b, "nope")
+	assertEqual(t, err, ErrGuestLink, "")
+}
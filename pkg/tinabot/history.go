@@ -0,0 +1,139 @@
+package tinabot
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/events"
+	"github.com/develersrl/lunches/pkg/matcher"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// how many days to look back when searching for the last order of a user
+const historyLookback = 7
+
+// userKey returns the key identifying the user in the brain, guests have no ID
+func userKey(u User) string {
+	if u.ID != "" {
+		return u.ID
+	}
+	return u.Name
+}
+
+func historyID(u User, date time.Time) string {
+	return userKey(u) + ":" + dayKey(date)
+}
+
+// SaveHistory stores the choices of each user in the order, under the order
+// date, with the names of the users and who ordered first, and clears the
+// reminder snoozes that are useless once the order is closed. Everything is
+// written at once, or nothing is.
+func SaveHistory(brain BatchStore, order *Order) error {
+	history := historyRepo(brain)
+	b := brain.Batch()
+	names := nameRepo(brain)
+	for u, choices := range order.Users {
+		b.Set(history.Key(historyID(u, order.Timestamp)), choices)
+		b.Set(names.Key(userKey(u)), u.Name)
+	}
+	if order.First != nil {
+		b.Set(firstRepo(brain).Key(dayKey(order.Timestamp)), *order.First)
+	}
+	b.Delete("remind:snooze")
+	return b.Commit()
+}
+
+// SubscribeHistory saves the order history every time the order is closed
+func SubscribeHistory(bus *events.Bus, brain BatchStore) {
+	bus.Subscribe(events.OrderClosed, func(ev events.Event) {
+		if err := SaveHistory(brain, ev.Data.(*Order)); err != nil {
+			slog.Error("Error saving order history", "err", err)
+		}
+	})
+}
+
+// LoadHistory returns the choices of user in the given date
+func LoadHistory(brain DataStore, user User, date time.Time) (UserChoiceArray, error) {
+	return historyRepo(brain).Get(historyID(user, date))
+}
+
+// LastHistory returns the most recent choices of user before date, looking
+// back at most historyLookback days.
+func LastHistory(brain DataStore, user User, date time.Time) (UserChoiceArray, time.Time, error) {
+	var err error
+	for i := 1; i <= historyLookback; i++ {
+		d := date.AddDate(0, 0, -i)
+		var choices UserChoiceArray
+		choices, err = LoadHistory(brain, user, d)
+		if err == nil && len(choices) > 0 {
+			return choices, d, nil
+		}
+	}
+	return nil, time.Time{}, fmt.Errorf("nessun ordine negli ultimi %d giorni", historyLookback)
+}
+
+// matchDish looks in the menu for a dish with the same canonical name as the
+// old one, falling back to the dishes of the same type matched by m: none if
+// nothing matches, more than one if the best ones are too close to choose.
+func matchDish(m *matcher.Matcher, menu tuttobene.Menu, old tuttobene.MenuRow) []matcher.Candidate {
+	name := tuttobene.CanonicalName(old.Content)
+
+	var typed tuttobene.Menu
+	for _, r := range menu.Rows {
+		if tuttobene.CanonicalName(r.Content) == name {
+			return []matcher.Candidate{{Row: r}}
+		}
+		if r.Type == old.Type {
+			typed.Rows = append(typed.Rows, r)
+		}
+	}
+	return m.Match(typed, name)
+}
+
+// repeatChoices rebuilds the old choices with today's menu dishes, returning
+// the names of the dishes that are not available anymore and the questions
+// about the ones matching more dishes, whose choices are not repeated.
+func repeatChoices(m *matcher.Matcher, menu tuttobene.Menu, old UserChoiceArray) ([]UserChoice, []string, []matcher.Ambiguous) {
+	var choices []UserChoice
+	var missing []string
+	var ambiguous []matcher.Ambiguous
+
+	for _, o := range old {
+		c := UserChoice{Note: o.Note}
+		ok := true
+		for _, d := range o.Dishes {
+			// dishes added verbatim are not in any menu
+			if d.Type == tuttobene.Empty {
+				c.Add(d)
+				continue
+			}
+
+			found := matchDish(m, menu, d)
+			if len(found) > 1 {
+				a := matcher.Ambiguous{Query: d.Content}
+				for _, f := range found {
+					a.Dishes = append(a.Dishes, f.Row.Content)
+				}
+				ambiguous = append(ambiguous, a)
+				ok = false
+				break
+			}
+			if len(found) == 0 {
+				missing = append(missing, d.Content)
+				ok = false
+				break
+			}
+			if err := c.Add(found[0].Row); err != nil {
+				slog.Warn("Error repeating dish", "dish", d.Content, "err", err)
+				missing = append(missing, d.Content)
+				ok = false
+				break
+			}
+		}
+		if ok && len(c.Dishes) > 0 {
+			choices = append(choices, c)
+		}
+	}
+	return choices, missing, ambiguous
+}
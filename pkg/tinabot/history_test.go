@@ -0,0 +1,59 @@
+package tinabot
+
+import (
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/matcher"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestHistory(t *testing.T) {
+	order := NewOrder()
+
+	var uc UserChoice
+	uc.Add(tuttobene.MenuRow{Content: "Penne all'arrabbiata", Type: tuttobene.Primo})
+	user := User{"test", "123"}
+	order.Set(user, []UserChoice{uc})
+
+	b := brain.NewBrainMock()
+	Snoozes{"123": order.Timestamp}.Save(b)
+	e := SaveHistory(b, order)
+	assertEqual(t, e, nil, "")
+	var snoozes Snoozes
+	assertEqual(t, snoozes.Load(b) != nil, true, "")
+
+	old, date, e := LastHistory(b, user, order.Timestamp.AddDate(0, 0, 3))
+	assertEqual(t, e, nil, "")
+	assertEqual(t, date.Format("2006-01-02"), order.Timestamp.Format("2006-01-02"), "")
+	assertEqual(t, old.String(), "Penne all'arrabbiata", "")
+
+	_, _, e = LastHistory(b, user, order.Timestamp.AddDate(0, 0, historyLookback+1))
+	assertEqual(t, e != nil, true, "")
+}
+
+func TestRepeatChoices(t *testing.T) {
+	menu := tuttobene.Menu{Rows: []tuttobene.MenuRow{
+		{Content: "Penne all arrabbiata", Type: tuttobene.Primo},
+		{Content: "Scaloppine al limone", Type: tuttobene.Secondo},
+		{Content: "Polpette al pomodoro e olive", Type: tuttobene.Secondo},
+		{Content: "Torta al cioccolato", Type: tuttobene.Dolce},
+		{Content: "Torta della nonna", Type: tuttobene.Dolce},
+	}}
+
+	var p, s, v, c, d UserChoice
+	p.Add(tuttobene.MenuRow{Content: "Penne all'arrabbiata", Type: tuttobene.Primo})
+	s.Add(tuttobene.MenuRow{Content: "Scaloppine limone", Type: tuttobene.Secondo})
+	v.Add(tuttobene.MenuRow{Content: "Tiramisù", Type: tuttobene.Dolce})
+	// no dish is ordered just because it looks like the old one
+	c.Add(tuttobene.MenuRow{Content: "Pollo", Type: tuttobene.Secondo})
+	d.Add(tuttobene.MenuRow{Content: "Torta", Type: tuttobene.Dolce})
+
+	choices, missing, ambiguous := repeatChoices(matcher.New(), menu, UserChoiceArray{p, s, v, c, d})
+	assertEqual(t, UserChoiceArray(choices).String(), "Penne all arrabbiata\nScaloppine al limone", "")
+	assertEqual(t, len(missing), 2, "")
+	assertEqual(t, missing[0], "Tiramisù", "")
+	assertEqual(t, missing[1], "Pollo", "")
+	assertEqual(t, len(ambiguous), 1, "")
+	assertEqual(t, ambiguous[0].Error(), "Cercando per 'Torta' intendevi 'Torta al cioccolato' o 'Torta della nonna'?", "")
+}
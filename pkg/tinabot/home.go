@@ -0,0 +1,192 @@
+package tinabot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/events"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// homeUsers is the set of the users who opened the Home tab of the bot, the
+// ones whose tab is refreshed when the order or the menu change
+const homeUsers = "home:users"
+
+// The action ids of the Home tab buttons
+const (
+	homeActionPrefix = "home_"
+	actionHomeRepeat = "home_repeat"
+	actionHomeClear  = "home_clear"
+)
+
+// maxSectionText is the longest text allowed by Slack in a section block
+const maxSectionText = 3000
+
+// homeRepo keeps the order shown in the Home tab of each user by userKey, to
+// publish it again only when it changes
+func homeRepo(b DataStore) brain.Repo[string] {
+	return brain.NewRepo[string](b, "home:published:")
+}
+
+// orderOf returns the choices of the user with the given ID in the order of
+// today, if any
+func orderOf(brain DataStore, userID string) (User, UserChoiceArray) {
+	var order Order
+	if err := order.Load(brain); err != nil || !order.IsUpdated() {
+		return User{ID: userID}, nil
+	}
+	for u, choices := range order.Users {
+		if u.ID == userID {
+			return u, choices
+		}
+	}
+	return User{ID: userID}, nil
+}
+
+// monthSpend returns how much user spent for lunch from the first of the
+// month of date, the order of date included
+func monthSpend(brain DataStore, user User, today UserChoiceArray, date time.Time) decimal.Decimal {
+	total := today.Price()
+	for d := date.AddDate(0, 0, 1-date.Day()); d.Day() < date.Day(); d = d.AddDate(0, 0, 1) {
+		if choices, err := LoadHistory(brain, user, d); err == nil {
+			total = total.Add(choices.Price())
+		}
+	}
+	return total
+}
+
+func orderText(choices UserChoiceArray) string {
+	if len(choices) == 0 {
+		return "Non hai ancora ordinato niente"
+	}
+	return choices.String()
+}
+
+// homeBlocks returns the Home tab: the order of the user, the menu of today,
+// the spending of the month and the buttons to repeat or clear the order
+func homeBlocks(choices UserChoiceArray, menu *tuttobene.Menu, spend decimal.Decimal) []chat.Block {
+	markdown := func(s string) chat.Section {
+		return chat.Section{Text: truncate(s, maxSectionText)}
+	}
+
+	blocks := []chat.Block{
+		markdown("*Il tuo ordine*\n" + orderText(choices)),
+		chat.Context{Text: fmt.Sprintf("Questo mese hai speso €%s", spend.StringFixed(2))},
+	}
+
+	repeat := chat.Button{ActionID: actionHomeRepeat, Text: "Ripeti ieri", Style: chat.Primary}
+	clear := chat.Button{
+		ActionID: actionHomeClear,
+		Text:     "Cancella ordine",
+		Style:    chat.Danger,
+		Confirm: &chat.Confirm{
+			Title: "Cancelli l'ordine?",
+			Text:  "Il tuo ordine di oggi sarà cancellato",
+			Yes:   "Cancella",
+			No:    "Lascia stare",
+		},
+	}
+	blocks = append(blocks, chat.Actions{Elements: []chat.Element{repeat, clear}}, chat.Divider{})
+
+	if menu == nil {
+		return append(blocks, markdown("*Il menù di oggi*\nNon è ancora arrivato"))
+	}
+	return append(blocks, markdown("*Il menù di oggi*\n"+menu.Format(true)))
+}
+
+// HomeOpened publishes the Home tab for the user who opened it, and keeps it
+// updated from then on
+func (t *TinaBot) HomeOpened(userID string) {
+	if err := t.brain.SAdd(homeUsers, userID); err != nil {
+		t.logger().Error("Error saving the home tab user", "err", err)
+	}
+	t.publishHome(userID)
+}
+
+// publishHome shows the current order, menu and spending in the Home tab of
+// the user
+func (t *TinaBot) publishHome(userID string) {
+	user, choices := orderOf(t.brain, userID)
+
+	now := NewOrder().Timestamp
+	var menu *tuttobene.Menu
+	if m, err := todayMenu(t.brain); err == nil && sameDay(m.Date, now) {
+		menu = &m
+	}
+	spend := monthSpend(t.brain, user, choices, now)
+
+	if err := t.bot.PublishHome(userID, homeBlocks(choices, menu, spend)...); err != nil {
+		t.logger().Error("Error publishing the home tab", "home_user", userID, "err", err)
+		return
+	}
+	homeRepo(t.brain).Put(userID, orderText(choices))
+}
+
+// subscribeHome refreshes the Home tabs on the events of this instance: all
+// of them when the menu is published, the ones whose order changed when the
+// order is updated
+func (t *TinaBot) subscribeHome() {
+	refresh := func(changed func(userID string) bool) {
+		users, err := t.brain.SMembers(homeUsers)
+		if err != nil {
+			t.logger().Error("Error loading the home tab users", "err", err)
+			return
+		}
+		for _, id := range users {
+			if changed(id) {
+				t.publishHome(id)
+			}
+		}
+	}
+
+	t.events.Subscribe(events.MenuPublished, func(ev events.Event) {
+		if ev.Remote {
+			return
+		}
+		refresh(func(string) bool { return true })
+	})
+	t.events.Subscribe(events.OrderUpdated, func(ev events.Event) {
+		if ev.Remote {
+			return
+		}
+		order := ev.Data.(*Order)
+		refresh(func(id string) bool {
+			var choices UserChoiceArray
+			for u, c := range order.Users {
+				if u.ID == id {
+					choices = c
+				}
+			}
+			shown, err := homeRepo(t.brain).Get(id)
+			return err != nil || shown != orderText(choices)
+		})
+	})
+}
+
+// homeAction handles the buttons of the Home tab, answering in the direct
+// messages of the user
+func (t *TinaBot) homeAction(userID string, a chat.Action) {
+	ch, err := t.bot.Platform.DirectChannel(userID)
+	if err != nil {
+		t.logger().Error("Error opening the direct message", "err", err)
+		return
+	}
+	t.bot.HandleInteraction(ch, userID, "", func(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User) {
+		switch a.ActionID {
+		case actionHomeRepeat:
+			t.For(bot, msg, user, "", "me", "come ieri")
+		case actionHomeClear:
+			t.For(bot, msg, user, "", "me", "niente")
+		}
+	})
+}
+
+func isHomeAction(a chat.Action) bool {
+	return strings.HasPrefix(a.ActionID, homeActionPrefix)
+}
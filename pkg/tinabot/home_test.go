@@ -0,0 +1,52 @@
+package tinabot
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestHomeBlocks(t *testing.T) {
+	price := func(s string) decimal.Decimal {
+		d, _ := decimal.NewFromString(s)
+		return d
+	}
+	choice := func(content, p string) UserChoiceArray {
+		var uc UserChoice
+		uc.Add(tuttobene.MenuRow{Content: content, Type: tuttobene.Primo, Price: price(p)})
+		return UserChoiceArray{uc}
+	}
+
+	b := brain.NewBrainMock()
+	user := User{"test", "123"}
+	date := time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC)
+	history := historyRepo(b)
+	history.Put(historyID(user, date.AddDate(0, 0, -13)), choice("Lasagne", "5.50"))
+	history.Put(historyID(user, date.AddDate(0, 0, -1)), choice("Penne", "4"))
+	// last month and other users are not counted
+	history.Put(historyID(user, date.AddDate(0, 0, -14)), choice("Penne", "4"))
+	history.Put(historyID(User{"other", "456"}, date.AddDate(0, 0, -1)), choice("Penne", "4"))
+
+	today := choice("Tagliata", "7")
+	spend := monthSpend(b, user, today, date)
+	assertEqual(t, spend.StringFixed(2), "16.50", "")
+	assertEqual(t, monthSpend(b, user, nil, date.AddDate(0, 0, -13)).StringFixed(2), "0.00", "")
+
+	data, err := json.Marshal(slackbot.SlackBlocks(homeBlocks(today, nil, spend)))
+	assertEqual(t, err, nil, "")
+	for _, s := range []string{"Tagliata", "€16.50", "Non è ancora arrivato", actionHomeRepeat, actionHomeClear} {
+		assertEqual(t, strings.Contains(string(data), s), true, s)
+	}
+
+	menu := tuttobene.Menu{Date: date, Rows: []tuttobene.MenuRow{{Content: "Risotto", Type: tuttobene.Primo}}}
+	data, _ = json.Marshal(slackbot.SlackBlocks(homeBlocks(nil, &menu, decimal.Zero)))
+	assertEqual(t, strings.Contains(string(data), "Risotto"), true, "")
+	assertEqual(t, strings.Contains(string(data), "Non hai ancora ordinato niente"), true, "")
+}
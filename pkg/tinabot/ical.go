@@ -0,0 +1,113 @@
+package tinabot
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/config"
+)
+
+// icalDays is how many days from today the calendar feed covers
+const icalDays = 14
+
+// icalTime is the format of the times in UTC of the iCalendar files
+const icalTime = "20060102T150405Z"
+
+// The length of the events of the calendar feed
+const (
+	deadlineLength = 15 * time.Minute
+	deliveryLength = 30 * time.Minute
+)
+
+// icalEscape escapes the text of a property of an iCalendar file
+func icalEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// icalEvent returns the lines of an event of the feed
+func icalEvent(uid string, start time.Time, length time.Duration, summary, description string, now time.Time) []string {
+	ev := []string{
+		"BEGIN:VEVENT",
+		"UID:" + uid,
+		"DTSTAMP:" + now.UTC().Format(icalTime),
+		"DTSTART:" + start.UTC().Format(icalTime),
+		"DTEND:" + start.Add(length).UTC().Format(icalTime),
+		"SUMMARY:" + icalEscape(summary),
+	}
+	if description != "" {
+		ev = append(ev, "DESCRIPTION:"+icalEscape(description))
+	}
+	return append(ev, "END:VEVENT")
+}
+
+// ICalFeed returns the iCalendar feed of the order deadlines and of the
+// expected deliveries of the next icalDays days from now, from the deadline
+// of the reminders and the delivery time of the restaurant. The weekends and
+// the closing days of the calendar are skipped. office tells the events of
+// the offices apart, see Offices.
+func ICalFeed(brain DataStore, office string, now time.Time) string {
+	var c Calendar
+	c.Load(brain)
+	var settings ReminderSettings
+	settings.Load(brain)
+	info := LoadRestaurantInfo(brain)
+	if office == "" {
+		office = "default"
+	}
+	domain := strings.NewReplacer(":", "-", "@", "-").Replace(office) + "@lunches"
+
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Develer//Tinabot//IT",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:Pranzo",
+	}
+	for i := 0; i < icalDays; i++ {
+		day := now.AddDate(0, 0, i)
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		if closed, _ := c.IsClosed(day); closed {
+			continue
+		}
+		if t, ok := at(day, settings.Deadline); ok {
+			lines = append(lines, icalEvent("deadline-"+dayKey(day)+"-"+domain, t, deadlineLength,
+				"Scadenza ordine pranzo", "Ultimo momento per ordinare il pranzo", now)...)
+		}
+		if t, ok := at(day, strings.TrimSpace(info.DeliveryTime)); ok {
+			lines = append(lines, icalEvent("delivery-"+dayKey(day)+"-"+domain, t, deliveryLength,
+				"Arrivo del pranzo", info.Address, now)...)
+		}
+	}
+	lines = append(lines, "END:VCALENDAR")
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+// icalURL returns the address of the calendar feed of the office, the one
+// outside of the offices if empty, on the public address of the app
+func icalURL(office string) (string, bool) {
+	host := config.Current().Host
+	if host == "" {
+		return "", false
+	}
+	u := host + "/calendar.ics"
+	if office != "" {
+		u += "?office=" + url.QueryEscape(office)
+	}
+	return u, true
+}
+
+// icalLink returns the message with the address of the calendar feed of
+// the office of the messages of user in channel
+func (t *TinaBot) icalLink(channel, user string) string {
+	u, ok := icalURL(officeOf(t.root, t.team, channel, user))
+	if !ok {
+		return "Mi spiace, non conosco l'indirizzo del sito per il calendario"
+	}
+	return fmt.Sprintf("Aggiungi al tuo calendario le scadenze degli ordini e gli arrivi del pranzo dei prossimi %d giorni:\n%s", icalDays, u)
+}
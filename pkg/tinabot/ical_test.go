@@ -0,0 +1,36 @@
+package tinabot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+)
+
+func TestICalFeed(t *testing.T) {
+	b := brain.NewBrainMock()
+	loc := time.FixedZone("CET", 3600)
+	friday := time.Date(2019, 3, 15, 9, 0, 0, 0, loc)
+
+	feed := ICalFeed(b, "", friday)
+	assertEqual(t, strings.Count(feed, "BEGIN:VEVENT"), 0, "nothing scheduled")
+
+	settings := ReminderSettings{Deadline: "11:30"}
+	settings.Save(b)
+	b.Set(restaurantKey, RestaurantInfo{DeliveryTime: "12:45", Address: "Via Pisa, 1"})
+	var c Calendar
+	c.Close(time.Date(2019, 3, 18, 0, 0, 0, 0, loc), "ponte")
+	c.Save(b)
+
+	feed = ICalFeed(b, "T1:C1", friday)
+	// 10 weekdays in two weeks, the 18th closed
+	assertEqual(t, strings.Count(feed, "SUMMARY:Scadenza ordine pranzo"), 9, "")
+	assertEqual(t, strings.Count(feed, "SUMMARY:Arrivo del pranzo"), 9, "")
+	assertEqual(t, strings.Contains(feed, "UID:deadline-2019-03-15-T1-C1@lunches\r\nDTSTAMP:20190315T080000Z\r\nDTSTART:20190315T103000Z\r\nDTEND:20190315T104500Z\r\n"), true, "")
+	assertEqual(t, strings.Contains(feed, "DTSTART:20190319T114500Z"), true, "")
+	assertEqual(t, strings.Contains(feed, "DESCRIPTION:Via Pisa\\, 1"), true, "")
+	assertEqual(t, strings.Contains(feed, "20190316"), false, "saturday")
+	assertEqual(t, strings.Contains(feed, "20190318"), false, "closed")
+	assertEqual(t, strings.HasSuffix(feed, "END:VCALENDAR\r\n"), true, "")
+}
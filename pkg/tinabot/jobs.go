@@ -0,0 +1,124 @@
+package tinabot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/jobs"
+	"github.com/develersrl/lunches/pkg/webhook"
+)
+
+// jobsKey is the queue of the jobs in the root brain, shared by the offices
+const jobsKey = "jobs"
+
+// The kinds of the jobs of the bot
+const (
+	jobWebhook = "webhook"
+	jobExport  = "export"
+	jobMark    = "mark"
+)
+
+// Jobs returns the queue of the slow side effects of the bot, in the root
+// brain so that a worker runs the ones of all the offices
+func Jobs(root brain.Store) *jobs.Queue {
+	return jobs.New(root, jobsKey)
+}
+
+// webhookJob sends an event to a webhook
+type webhookJob struct {
+	Hook    webhook.Hook
+	Payload webhook.Payload
+}
+
+// exportJob uploads the order of the office of User in Channel, see Export
+type exportJob struct {
+	Team    string
+	Channel string
+	User    string
+	Format  string
+}
+
+// markJob marks the lunch of User on the sheet, see Mark
+type markJob struct {
+	Team    string
+	Channel string
+	User    chat.User
+	Food    string
+}
+
+// TeamPlatform returns the chat platform of the bot of a team
+type TeamPlatform func(team string) (chat.Platform, error)
+
+// HandleJobs runs the jobs of the bot with w, root is the brain where they
+// are queued and platform posts their outcome
+func HandleJobs(w *jobs.Worker, root brain.Store, platform TeamPlatform) {
+	hooks := webhook.New()
+	w.Handle(jobWebhook, func(data json.RawMessage) error {
+		var j webhookJob
+		if err := json.Unmarshal(data, &j); err != nil {
+			return err
+		}
+		return hooks.Send(j.Hook, j.Payload)
+	})
+	w.Handle(jobExport, func(data json.RawMessage) error {
+		var j exportJob
+		if err := json.Unmarshal(data, &j); err != nil {
+			return err
+		}
+		p, err := platform(j.Team)
+		if err != nil {
+			return err
+		}
+		return runExport(p, Scope(root, j.Team, j.Channel, j.User), j.Channel, j.Format)
+	})
+	w.Handle(jobMark, func(data json.RawMessage) error {
+		var j markJob
+		if err := json.Unmarshal(data, &j); err != nil {
+			return err
+		}
+		p, err := platform(j.Team)
+		if err != nil {
+			return err
+		}
+		if err := MarkUser(&j.User, j.Food); err != nil {
+			return err
+		}
+		text := fmt.Sprintf("Ok, segnato '%s' per %s sul foglio dei pranzi", j.Food, j.User.Name)
+		_, err = p.SendMessage(j.Channel, MenuThread(Scope(root, j.Team, j.Channel, j.User.ID), j.Channel), text)
+		return err
+	})
+}
+
+// runExport uploads today's order of brain on channel as a file of format,
+// the errors of the export are told on channel instead of being retried
+func runExport(platform chat.Platform, brain DataStore, channel, format string) error {
+	order := getOrder(brain)
+	var buf bytes.Buffer
+	var err error
+	if format == "csv" {
+		err = order.ExportCSV(&buf)
+	} else {
+		err = order.ExportXLSX(&buf)
+	}
+	if err != nil {
+		_, err = platform.SendMessage(channel, "", "Errore nell'esportazione: "+err.Error())
+		return err
+	}
+
+	return platform.UploadFile(channel, chat.File{
+		Name:  "ordine-" + order.Timestamp.Format("2006-01-02") + "." + format,
+		Type:  format,
+		Title: "Ordine del " + order.Timestamp.Format("02/01/2006"),
+	}, buf.Bytes())
+}
+
+// enqueue queues a job of kind with data, telling on channel if it can't
+func (t *TinaBot) enqueue(channel, kind string, data interface{}) {
+	if err := Jobs(t.root).Push(kind, data); err != nil {
+		t.logger().Error("Error queuing the job", "kind", kind, "err", err)
+		t.bot.Message(channel, "Non riesco a farlo adesso, riprova tra poco")
+	}
+}
@@ -0,0 +1,63 @@
+package tinabot
+
+import (
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/jobs"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// uploadPlatform records the files uploaded, the other methods of
+// chat.Platform aren't implemented
+type uploadPlatform struct {
+	chat.Platform
+	uploads chan upload
+}
+
+type upload struct {
+	channel string
+	file    chat.File
+	content string
+}
+
+func (p *uploadPlatform) UploadFile(channel string, f chat.File, data []byte) error {
+	p.uploads <- upload{channel, f, string(data)}
+	return nil
+}
+
+func TestExportJob(t *testing.T) {
+	uploads := make(chan upload, 1)
+
+	root := brain.NewBrainMock()
+	order := NewOrder()
+	var c UserChoice
+	c.Add(tuttobene.MenuRow{Content: "Risotto", Type: tuttobene.Primo})
+	order.Set(User{"mario", "U1"}, []UserChoice{c})
+	order.Save(root)
+
+	bot := slackbot.New("B1", nil)
+	tb := NewScoped(bot, root, "T1", "C1", "U1")
+	tb.Export(bot, &slackbot.BotMsg{Channel: "C1", User: "U1"}, &chat.User{ID: "U1"}, "esporta csv", " csv")
+
+	// the handler only queues the job
+	var queued []jobs.Job
+	root.Range(jobsKey, 0, -1, &queued)
+	assertEqual(t, len(queued), 1, "")
+	assertEqual(t, queued[0].Kind, jobExport, "")
+
+	w := jobs.NewWorker(Jobs(root))
+	HandleJobs(w, root, func(team string) (chat.Platform, error) {
+		assertEqual(t, team, "T1", "")
+		return &uploadPlatform{uploads: uploads}, nil
+	})
+	if ran, err := w.Next(); !ran || err != nil {
+		t.Fatalf("export not run: %v", err)
+	}
+	u := <-uploads
+	assertEqual(t, u.channel, "C1", "")
+	assertEqual(t, u.file.Name, "ordine-"+order.Timestamp.Format("2006-01-02")+".csv", "")
+	assertEqual(t, u.content[:len("Utente,")], "Utente,", "")
+}
@@ -0,0 +1,161 @@
+package tinabot
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/events"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// maxJournal is how many changes can be undone
+const maxJournal = 20
+
+// Journal keeps the previous versions of the order of a user, to undo and
+// redo the changes of the day
+type Journal struct {
+	Date string
+	Undo []UserChoiceArray
+	Redo []UserChoiceArray
+}
+
+func today() string {
+	loc, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		slog.Error("LoadLocation error", "err", err)
+		return time.Now().Format("2006-01-02")
+	}
+	return time.Now().In(loc).Format("2006-01-02")
+}
+
+// LoadJournal loads the journal of user, discarding the ones of the previous days
+func LoadJournal(brain DataStore, user User) *Journal {
+	j, err := journalRepo(brain).Get(userKey(user))
+	if err != nil || j.Date != today() {
+		return &Journal{Date: today()}
+	}
+	return &j
+}
+
+// Save saves the journal of user
+func (j *Journal) Save(brain DataStore, user User) error {
+	return journalRepo(brain).Put(userKey(user), *j)
+}
+
+// Record saves the choices before a change, discarding the changes to redo
+func (j *Journal) Record(prev UserChoiceArray) {
+	j.Undo = append(j.Undo, prev)
+	if len(j.Undo) > maxJournal {
+		j.Undo = j.Undo[len(j.Undo)-maxJournal:]
+	}
+	j.Redo = nil
+}
+
+// Back returns the choices to restore to undo the last change, cur being the
+// current ones, false if there's nothing to undo
+func (j *Journal) Back(cur UserChoiceArray) (UserChoiceArray, bool) {
+	if len(j.Undo) == 0 {
+		return nil, false
+	}
+	prev := j.Undo[len(j.Undo)-1]
+	j.Undo = j.Undo[:len(j.Undo)-1]
+	j.Redo = append(j.Redo, cur)
+	return prev, true
+}
+
+// Forward returns the choices to restore to redo the last undone change, false
+// if there's nothing to redo
+func (j *Journal) Forward(cur UserChoiceArray) (UserChoiceArray, bool) {
+	if len(j.Redo) == 0 {
+		return nil, false
+	}
+	next := j.Redo[len(j.Redo)-1]
+	j.Redo = j.Redo[:len(j.Redo)-1]
+	j.Undo = append(j.Undo, cur)
+	return next, true
+}
+
+// choicesOf returns a copy of the choices of user, safe from later changes to the order
+func (order *Order) choicesOf(user User) UserChoiceArray {
+	return append(UserChoiceArray(nil), order.Users[order.key(user)]...)
+}
+
+// restore sets the choices of user to the saved ones, keeping who ordered for her
+func (order *Order) restore(user User, choices UserChoiceArray) {
+	by, proxied := order.OrderedBy[user]
+	order.Set(user, choices)
+	if proxied && len(choices) > 0 {
+		order.OrderedBy[user] = by
+	}
+}
+
+// record adds the choices of user before a change to her journal
+func (t *TinaBot) record(user User, prev UserChoiceArray) {
+	j := LoadJournal(t.brain, user)
+	j.Record(prev)
+	if err := j.Save(t.brain, user); err != nil {
+		t.logger().Error("Error saving journal", "err", err)
+	}
+}
+
+// copy returns a journal that can be changed without affecting j
+func (j *Journal) copy() *Journal {
+	return &Journal{
+		Date: j.Date,
+		Undo: append([]UserChoiceArray(nil), j.Undo...),
+		Redo: append([]UserChoiceArray(nil), j.Redo...),
+	}
+}
+
+// Undo reverts the last change to the user order, or re-applies the last
+// reverted one if args[1] is set
+func (t *TinaBot) Undo(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	me := User{user.Name, user.ID}
+	redo := args[1] != ""
+	orig := LoadJournal(t.brain, me)
+
+	var order Order
+	var j *Journal
+	found := false
+	err := order.SaveCAS(t.brain, func(o *Order) error {
+		if err := o.Editable(); err != nil {
+			return err
+		}
+		j = orig.copy()
+		var choices UserChoiceArray
+		if redo {
+			choices, found = j.Forward(o.choicesOf(me))
+		} else {
+			choices, found = j.Back(o.choicesOf(me))
+		}
+		if found {
+			o.restore(me, choices)
+		}
+		return nil
+	})
+	if err != nil {
+		t.bot.Message(msg.Channel, "Errore: "+err.Error())
+		return
+	}
+	if !found {
+		if redo {
+			t.bot.Message(msg.Channel, "Non c'è nessuna modifica da ripristinare")
+		} else {
+			t.bot.Message(msg.Channel, "Non c'è nessuna modifica da annullare")
+		}
+		return
+	}
+	if err := j.Save(t.brain, me); err != nil {
+		t.logger().Error("Error saving journal", "err", err)
+	}
+	t.events.Publish(events.OrderUpdated, &order)
+
+	choices := order.Users[me]
+	if len(choices) == 0 {
+		t.bot.Message(msg.Channel, fmt.Sprintf("Ok, ora %s non ha ordinato nulla", me.Name))
+		return
+	}
+	t.bot.Message(msg.Channel, fmt.Sprintf("Ok, ora l'ordine di %s è:\n%s", me.Name, choices.String()))
+}
@@ -0,0 +1,48 @@
+package tinabot
+
+import (
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestJournal(t *testing.T) {
+	var p, s UserChoice
+	p.Add(tuttobene.MenuRow{Content: "primo", Type: tuttobene.Primo})
+	s.Add(tuttobene.MenuRow{Content: "secondo", Type: tuttobene.Secondo})
+	user := User{"test", "123"}
+
+	order := NewOrder()
+	j := LoadJournal(brain.NewBrainMock(), user)
+
+	j.Record(order.choicesOf(user))
+	order.Set(user, []UserChoice{p, s})
+	j.Record(order.choicesOf(user))
+	order.RemoveItem(user, 0)
+	assertEqual(t, order.Users[user].String(), "secondo", "")
+
+	prev, ok := j.Back(order.choicesOf(user))
+	assertEqual(t, ok, true, "")
+	order.restore(user, prev)
+	assertEqual(t, order.Users[user].String(), "primo\nsecondo", "")
+
+	prev, _ = j.Back(order.choicesOf(user))
+	order.restore(user, prev)
+	assertEqual(t, len(order.Users[user]), 0, "")
+	_, ok = j.Back(order.choicesOf(user))
+	assertEqual(t, ok, false, "")
+
+	next, ok := j.Forward(order.choicesOf(user))
+	assertEqual(t, ok, true, "")
+	order.restore(user, next)
+	assertEqual(t, order.String(), "1 primo [test]\n1 secondo [test]", "")
+
+	j.Record(order.choicesOf(user))
+	_, ok = j.Forward(order.choicesOf(user))
+	assertEqual(t, ok, false, "")
+
+	b := brain.NewBrainMock()
+	assertEqual(t, j.Save(b, user), nil, "")
+	assertEqual(t, len(LoadJournal(b, user).Undo), 2, "")
+}
@@ -0,0 +1,259 @@
+package tinabot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/events"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// LateRequest is an order placed after the deadline, waiting for an admin
+type LateRequest struct {
+	ID      int
+	User    User // who the dishes are for
+	By      User // who asked for them
+	Choices UserChoiceArray
+	Date    time.Time
+}
+
+// LateRequests are the pending late orders
+type LateRequests struct {
+	Next    int
+	Pending []LateRequest
+}
+
+// Load loads the pending requests from brain, the ones of the previous days are dropped
+func (l *LateRequests) Load(brain DataStore) error {
+	if err := brain.Get("late", l); err != nil {
+		*l = LateRequests{}
+		return err
+	}
+	l.dropOld()
+	return nil
+}
+
+// dropOld drops the requests of the previous days
+func (l *LateRequests) dropOld() {
+	now := romeNow()
+	y, m, d := now.Date()
+	var pending []LateRequest
+	for _, r := range l.Pending {
+		if ry, rm, rd := r.Date.In(now.Location()).Date(); ry == y && rm == m && rd == d {
+			pending = append(pending, r)
+		}
+	}
+	l.Pending = pending
+}
+
+// Save saves the pending requests to brain
+func (l *LateRequests) Save(brain DataStore) error {
+	return brain.Set("late", *l)
+}
+
+// SaveCAS applies fn to the latest stored requests and saves them, so that
+// no concurrent change is lost
+func (l *LateRequests) SaveCAS(brain CASStore, fn func(*LateRequests) error) error {
+	return brain.Update("late", l, func() error {
+		l.dropOld()
+		return fn(l)
+	})
+}
+
+// Add adds a new pending request, returns its id
+func (l *LateRequests) Add(r LateRequest) int {
+	l.Next++
+	r.ID = l.Next
+	l.Pending = append(l.Pending, r)
+	return r.ID
+}
+
+// Take removes the request with the given id, returns false if there's none
+func (l *LateRequests) Take(id int) (LateRequest, bool) {
+	for i, r := range l.Pending {
+		if r.ID == id {
+			l.Pending = append(l.Pending[:i], l.Pending[i+1:]...)
+			return r, true
+		}
+	}
+	return LateRequest{}, false
+}
+
+func (r *LateRequest) String() string {
+	who := r.User.Name
+	if r.By != r.User {
+		who += " da " + r.By.Name
+	}
+	var dishes []string
+	for _, c := range r.Choices {
+		dishes = append(dishes, c.String())
+	}
+	return fmt.Sprintf("%d. %s: %s", r.ID, who, strings.Join(dishes, ", "))
+}
+
+// Expired returns true if the order deadline of the day has passed at now
+func (s *ReminderSettings) Expired(now time.Time) bool {
+	deadline, ok := at(now, s.Deadline)
+	return ok && now.After(deadline)
+}
+
+// romeNow returns the current time in Rome, where the deadlines of the
+// orders are set
+func romeNow() time.Time {
+	if loc, err := time.LoadLocation("Europe/Rome"); err == nil {
+		return time.Now().In(loc)
+	}
+	return time.Now()
+}
+
+// lateReason returns why an order placed now needs the approval of an admin,
+// empty if it doesn't
+func lateReason(order *Order, settings ReminderSettings, now time.Time) string {
+	switch order.State {
+	case Locked, Sent:
+		return fmt.Sprintf("l'ordine è %s", order.State)
+	case Open:
+		if settings.Expired(now) {
+			return "la scadenza delle " + settings.Deadline + " è passata"
+		}
+	}
+	return ""
+}
+
+// RestaurantDelta renders the changes to an order already sent to the
+// restaurant: the dishes to add and the ones to remove
+func RestaurantDelta(date time.Time, added, removed UserChoiceArray) (string, string) {
+	subj := "Modifica ordine Develer del giorno " + date.Format("02/01/2006")
+
+	format := func(choices UserChoiceArray) string {
+		o := NewOrder()
+		o.Set(User{}, choices)
+		return o.Format(false, false)
+	}
+	var body []string
+	if len(added) > 0 {
+		body = append(body, "Da aggiungere:\n"+format(added))
+	}
+	if len(removed) > 0 {
+		body = append(body, "Da togliere:\n"+format(removed))
+	}
+	return subj, strings.Join(body, "\n\n")
+}
+
+// requestLate stores the choice of user as a request for the admins
+func (t *TinaBot) requestLate(msg *slackbot.BotMsg, user *chat.User, dest User, choice []UserChoice, reply, reason string) {
+	var late LateRequests
+	r := LateRequest{User: dest, By: User{user.Name, user.ID}, Choices: choice, Date: romeNow()}
+	err := late.SaveCAS(t.brain, func(l *LateRequests) error {
+		r.ID = l.Add(r)
+		return nil
+	})
+	if err != nil {
+		t.bot.Message(msg.Channel, reply+"Errore nel salvare la richiesta: "+err.Error())
+		return
+	}
+
+	for _, id := range teamAdmins(t.team) {
+		ch, err := t.bot.Platform.DirectChannel(id)
+		if err != nil {
+			t.logger().Error("Error opening the direct message", "err", err)
+			continue
+		}
+		t.bot.Message(ch, fmt.Sprintf("Nuova richiesta di ordine in ritardo:\n%s\nUsa `approva %d` o `rifiuta %d`", r.String(), r.ID, r.ID))
+	}
+
+	t.bot.Message(msg.Channel, reply+fmt.Sprintf("Mi spiace, %s: ho inviato la richiesta %d agli amministratori, ti farò sapere!", reason, r.ID))
+}
+
+// LateCmd lists, approves or rejects the late orders
+func (t *TinaBot) LateCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	var late LateRequests
+	late.Load(t.brain)
+
+	cmd := strings.ToLower(args[1])
+	if cmd == "richieste" {
+		if len(late.Pending) == 0 {
+			t.bot.Message(msg.Channel, "Nessuna richiesta in attesa")
+			return
+		}
+		var r []string
+		for _, p := range late.Pending {
+			r = append(r, p.String())
+		}
+		t.bot.Message(msg.Channel, "Richieste in attesa:\n"+strings.Join(r, "\n"))
+		return
+	}
+
+	if !t.canAdmin(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono approvare le richieste")
+		return
+	}
+	id, err := strconv.Atoi(args[2])
+	if err != nil {
+		t.bot.Message(msg.Channel, fmt.Sprintf("Richiesta '%s' non valida", args[2]))
+		return
+	}
+	var r LateRequest
+	errMissing := fmt.Errorf("Non c'è nessuna richiesta %d in attesa", id)
+	err = late.SaveCAS(t.brain, func(l *LateRequests) error {
+		var ok bool
+		if r, ok = l.Take(id); !ok {
+			return errMissing
+		}
+		return nil
+	})
+	if err == errMissing {
+		t.bot.Message(msg.Channel, err.Error())
+		return
+	}
+	if err != nil {
+		t.bot.Message(msg.Channel, "Errore nel salvare le richieste: "+err.Error())
+		return
+	}
+
+	var prev UserChoiceArray
+	if cmd == "approva" {
+		var order Order
+		err = order.SaveCAS(t.brain, func(o *Order) error {
+			if o.State > Sent {
+				return fmt.Errorf("l'ordine è %s", o.State)
+			}
+			prev = o.choicesOf(r.User)
+			o.SetBy(r.By, r.User, r.Choices)
+			return nil
+		})
+		if err != nil {
+			// put the request back, so that it can be approved again
+			late.SaveCAS(t.brain, func(l *LateRequests) error {
+				l.Pending = append(l.Pending, r)
+				return nil
+			})
+			t.bot.Message(msg.Channel, "Errore nel salvare l'ordine: "+err.Error())
+			return
+		}
+		t.record(r.User, prev)
+		t.events.Publish(events.OrderUpdated, &order)
+		observeOrder("late")
+	}
+
+	outcome := "rifiutata"
+	if cmd == "approva" {
+		outcome = "approvata"
+		subj, body := RestaurantDelta(r.Date, r.Choices, prev)
+		t.bot.Message(msg.Channel, fmt.Sprintf("Ok, richiesta %d approvata. Ecco la modifica per il ristorante:\n%s\n%s\n\n%s", r.ID, subj, body, mailtoLink(subj, body)))
+	} else {
+		t.bot.Message(msg.Channel, fmt.Sprintf("Ok, richiesta %d rifiutata", r.ID))
+	}
+
+	if r.By.ID != "" {
+		ch, err := bot.Platform.DirectChannel(r.By.ID)
+		if err != nil {
+			t.logger().Error("Error opening the direct message", "err", err)
+			return
+		}
+		t.bot.Message(ch, fmt.Sprintf("La tua richiesta di ordine in ritardo è stata %s da %s:\n%s", outcome, user.Name, r.String()))
+	}
+}
@@ -0,0 +1,73 @@
+package tinabot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestLateRequests(t *testing.T) {
+	now := time.Date(2019, 3, 12, 12, 30, 0, 0, time.UTC)
+	order := NewOrder()
+
+	var settings ReminderSettings
+	assertEqual(t, lateReason(order, settings, now), "", "")
+	settings.Deadline = "12:00"
+	assertEqual(t, lateReason(order, settings, now), "la scadenza delle 12:00 è passata", "")
+	assertEqual(t, lateReason(order, settings, now.Add(-time.Hour)), "", "")
+	order.State = Sent
+	assertEqual(t, lateReason(order, settings, now.Add(-time.Hour)), "l'ordine è inviato", "")
+	order.State = Delivered
+	assertEqual(t, lateReason(order, settings, now), "", "")
+
+	var p UserChoice
+	p.Add(tuttobene.MenuRow{Content: "primo", Type: tuttobene.Primo})
+
+	var late LateRequests
+	a := late.Add(LateRequest{User: User{"a", "1"}, By: User{"a", "1"}, Choices: UserChoiceArray{p}})
+	b := late.Add(LateRequest{User: User{"guest_b", ""}, By: User{"a", "1"}, Choices: UserChoiceArray{p, p}})
+	assertEqual(t, a, 1, "")
+	assertEqual(t, b, 2, "")
+
+	r, ok := late.Take(2)
+	assertEqual(t, ok, true, "")
+	assertEqual(t, r.String(), "2. guest_b da a: primo, primo", "")
+	_, ok = late.Take(2)
+	assertEqual(t, ok, false, "")
+	assertEqual(t, len(late.Pending), 1, "")
+
+	subj, body := RestaurantDelta(now, r.Choices, UserChoiceArray{p})
+	assertEqual(t, subj, "Modifica ordine Develer del giorno 12/03/2019", "")
+	assertEqual(t, body, "Da aggiungere:\n2 primo\n\nDa togliere:\n1 primo", "")
+}
+
+func TestLateRequestsSaveCAS(t *testing.T) {
+	b := brain.NewBrainMock()
+	old := LateRequests{Next: 3, Pending: []LateRequest{{ID: 3, Date: time.Now().AddDate(0, 0, -1)}}}
+	old.Save(b)
+
+	var late LateRequests
+	id := 0
+	err := late.SaveCAS(b, func(l *LateRequests) error {
+		id = l.Add(LateRequest{Date: time.Now()})
+		return nil
+	})
+	assertEqual(t, err, nil, "")
+	assertEqual(t, id, 4, "")
+
+	late.Load(b)
+	assertEqual(t, len(late.Pending), 1, "")
+	assertEqual(t, late.Pending[0].ID, 4, "")
+}
+
+func TestLateReasonInRome(t *testing.T) {
+	settings := ReminderSettings{Deadline: "12:00"}
+	order := NewOrder()
+	// 10:30 UTC is 12:30 in Rome, in summer
+	now := time.Date(2019, 7, 11, 10, 30, 0, 0, time.UTC)
+	assertEqual(t, lateReason(order, settings, now), "", "")
+	assertEqual(t, lateReason(order, settings, now.In(order.Timestamp.Location())), "la scadenza delle 12:00 è passata", "")
+	assertEqual(t, romeNow().Location().String(), "Europe/Rome", "")
+}
@@ -0,0 +1,223 @@
+package tinabot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/analytics"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/report"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// how many users each ranking of the leaderboard lists
+const leaderboardTop = 3
+
+// LeaderboardSettings configures the leaderboard of the previous month
+// posted on Channel on its first day
+type LeaderboardSettings struct {
+	Channel string
+
+	// the month of the last leaderboard posted, "2006-01"
+	Sent string `json:",omitempty"`
+}
+
+// Load loads the settings from brain, no leaderboard is posted if missing
+func (s *LeaderboardSettings) Load(brain DataStore) error {
+	if err := brain.Get("leaderboard", s); err != nil {
+		*s = LeaderboardSettings{}
+		return err
+	}
+	return nil
+}
+
+// Save saves the settings to brain
+func (s *LeaderboardSettings) Save(brain DataStore) error {
+	return brain.Set("leaderboard", *s)
+}
+
+// Due returns the previous month if its leaderboard is to be posted at now:
+// from reportTime on the first day of the month, or on the following days if
+// it was skipped
+func (s *LeaderboardSettings) Due(now time.Time) (analytics.Period, bool) {
+	if s.Channel == "" {
+		return analytics.Period{}, false
+	}
+	month := analytics.Month(now)
+	from, _ := at(month.Start, reportTime)
+	prev := analytics.Month(month.Start.AddDate(0, 0, -1))
+	return prev, !now.Before(from) && s.Sent != monthKey(prev.Start)
+}
+
+func (s *LeaderboardSettings) String() string {
+	if s.Channel == "" {
+		return "Nessuna classifica mensile automatica impostata"
+	}
+	return fmt.Sprintf("La classifica del mese precedente viene pubblicata su <#%s> il primo del mese dalle %s", s.Channel, reportTime)
+}
+
+// Participation returns how often the users ordered in the period, from the
+// history of the orders
+func Participation(brain DataStore, period analytics.Period) (analytics.Participation, error) {
+	var a Accounting
+	a.Load(brain)
+	rows, err := a.rows(brain, period)
+	if err != nil {
+		return analytics.Participation{}, err
+	}
+	lunches := make([]analytics.Lunch, len(rows))
+	for i, r := range rows {
+		lunches[i] = analytics.Lunch{Date: r.Date, User: userKey(r.User), Name: r.User.Name}
+	}
+
+	first := make(map[string]string)
+	firsts := firstRepo(brain)
+	for d := period.Start; d.Before(period.End); d = d.AddDate(0, 0, 1) {
+		if u, err := firsts.Get(dayKey(d)); err == nil {
+			first[dayKey(d)] = userKey(u)
+		}
+	}
+	return analytics.NewParticipation(lunches, first, period), nil
+}
+
+// FormatLeaderboard returns the rankings of the month for the channel: who
+// ordered more often, the longest streaks and who ordered first more often
+func FormatLeaderboard(p analytics.Participation) string {
+	title := "*Classifica dei pranzi di " + p.Start.Format("01/2006") + "*"
+	if p.Days == 0 {
+		return title + "\nNessun ordine nel mese"
+	}
+	out := []string{fmt.Sprintf("%s\nSi è ordinato in %s", title, count(p.Days, "giorno", "giorni"))}
+
+	rankings := []struct {
+		title string
+		value func(analytics.Attendance) int
+		line  func(analytics.Attendance) string
+	}{{
+		":trophy: *Presenze*",
+		func(a analytics.Attendance) int { return a.Days },
+		func(a analytics.Attendance) string {
+			return fmt.Sprintf("%s: %s su %d (%d%%)", a.Name, count(a.Days, "giorno", "giorni"), p.Days, a.Rate(p))
+		},
+	}, {
+		":fire: *Serie più lunga*",
+		func(a analytics.Attendance) int { return a.Longest },
+		func(a analytics.Attendance) string {
+			return fmt.Sprintf("%s: %s di fila", a.Name, count(a.Longest, "giorno", "giorni"))
+		},
+	}, {
+		":zap: *Chi ordina per primo*",
+		func(a analytics.Attendance) int { return a.Firsts },
+		func(a analytics.Attendance) string {
+			return fmt.Sprintf("%s: %s", a.Name, count(a.Firsts, "volta", "volte"))
+		},
+	}}
+	for _, r := range rankings {
+		users := p.By(leaderboardTop, r.value)
+		if len(users) == 0 {
+			continue
+		}
+		lines := []string{r.title}
+		for i, a := range users {
+			lines = append(lines, fmt.Sprintf("%d. %s", i+1, r.line(a)))
+		}
+		out = append(out, strings.Join(lines, "\n"))
+	}
+	return strings.Join(out, "\n\n")
+}
+
+// LeaderboardReport returns the rankings of the month to render in the other
+// formats, with all the users
+func LeaderboardReport(p analytics.Participation) report.Report {
+	rep := report.Report{Name: "classifica-" + monthKey(p.Start), Title: "Classifica dei pranzi di " + p.Start.Format("01/2006")}
+	if p.Days == 0 {
+		rep.Summary = []string{"Nessun ordine nel mese"}
+		return rep
+	}
+	rep.Summary = []string{"Si è ordinato in " + count(p.Days, "giorno", "giorni")}
+
+	rankings := []struct {
+		title, column string
+		value         func(analytics.Attendance) int
+	}{
+		{"Presenze", "Giorni", func(a analytics.Attendance) int { return a.Days }},
+		{"Serie più lunga", "Giorni di fila", func(a analytics.Attendance) int { return a.Longest }},
+		{"Chi ordina per primo", "Volte", func(a analytics.Attendance) int { return a.Firsts }},
+	}
+	for _, r := range rankings {
+		t := report.Table{Title: r.title, Columns: []string{"Posizione", "Nome", r.column}}
+		for i, a := range p.By(len(p.Users), r.value) {
+			t.Rows = append(t.Rows, report.Row{
+				Cells: []string{fmt.Sprint(i + 1), a.Name, fmt.Sprint(r.value(a))},
+				Value: float64(r.value(a)),
+			})
+		}
+		rep.Tables = append(rep.Tables, t)
+	}
+	return rep
+}
+
+// LeaderboardCmd shows the leaderboard of the current month or of the one
+// given, in the format of the flag if any, with the stats of the user, or
+// turns on and off the monthly post on the channel
+func (t *TinaBot) LeaderboardCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	format, f, ok := parseFormat(strings.Fields(strings.ToLower(args[1])))
+	if !ok {
+		t.bot.Message(msg.Channel, "Formato non valido, usa "+formatFlags())
+		return
+	}
+	if len(f) > 0 && f[0] == "automatica" {
+		t.leaderboardAuto(msg, user, f[1:])
+		return
+	}
+
+	month, err := parseMonth(strings.Join(f, " "), time.Now())
+	if err != nil {
+		t.bot.Message(msg.Channel, "Mese non valido, usa il formato aaaa-mm")
+		return
+	}
+	p, err := Participation(t.brain, analytics.Month(month))
+	if err != nil {
+		t.bot.Message(msg.Channel, "Errore nel calcolare la classifica: "+err.Error())
+		return
+	}
+	if format != "" {
+		t.postReport(msg, LeaderboardReport(p), format)
+	} else {
+		t.bot.Message(msg.Channel, FormatLeaderboard(p))
+	}
+	if a, ok := p.User(user.ID); ok {
+		t.bot.Reply(msg, slackbot.Ephemeral, fmt.Sprintf("Hai ordinato %d giorni su %d (%d%%), la tua serie più lunga è di %d giorni, quella in corso di %d, e hai ordinato per primo %d volte",
+			a.Days, p.Days, a.Rate(p), a.Longest, a.Current, a.Firsts))
+	}
+}
+
+// leaderboardAuto shows the settings of the monthly leaderboard, or turns it
+// on the channel or off
+func (t *TinaBot) leaderboardAuto(msg *slackbot.BotMsg, user *chat.User, f []string) {
+	var s LeaderboardSettings
+	s.Load(t.brain)
+	if len(f) == 0 {
+		t.bot.Message(msg.Channel, s.String())
+		return
+	}
+	if !t.canAdmin(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono impostare la classifica mensile")
+		return
+	}
+	switch {
+	case len(f) == 1 && f[0] == "on":
+		s.Channel = msg.Channel
+	case len(f) == 1 && f[0] == "off":
+		s.Channel = ""
+	default:
+		t.bot.Message(msg.Channel, "Comando non valido, usa `classifica automatica on|off`")
+		return
+	}
+	if err := s.Save(t.brain); err != nil {
+		t.bot.Message(msg.Channel, "Errore: "+err.Error())
+		return
+	}
+	t.bot.Message(msg.Channel, "Ok\n"+s.String())
+}
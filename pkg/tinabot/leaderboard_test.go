@@ -0,0 +1,64 @@
+package tinabot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/analytics"
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestLeaderboard(t *testing.T) {
+	b := brain.NewBrainMock()
+	mario := User{"mario", "U1"}
+	luigi := User{"luigi", "U2"}
+	var c UserChoice
+	c.Add(tuttobene.MenuRow{Content: "primo", Type: tuttobene.Primo})
+
+	march := time.Date(2019, 3, 11, 13, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		order := NewOrder()
+		order.Timestamp = march.AddDate(0, 0, i)
+		if i == 1 {
+			order.SetBy(luigi, User{"ospite", ""}, []UserChoice{c})
+		}
+		order.Set(mario, []UserChoice{c})
+		if i != 2 {
+			order.Set(luigi, []UserChoice{c})
+		}
+		assertEqual(t, SaveHistory(b, order), nil, "")
+	}
+
+	p, err := Participation(b, analytics.Month(march))
+	assertEqual(t, err, nil, "")
+	assertEqual(t, p.Days, 3, "")
+	m, _ := p.User("U1")
+	assertEqual(t, m, analytics.Attendance{User: "U1", Name: "mario", Days: 3, Longest: 3, Current: 3, Firsts: 2}, "")
+	l, _ := p.User("U2")
+	assertEqual(t, l.Firsts, 1, "who ordered for the guest was first")
+
+	msg := FormatLeaderboard(p)
+	assertEqual(t, strings.HasPrefix(msg, "*Classifica dei pranzi di 03/2019*\nSi è ordinato in 3 giorni"), true, msg)
+	assertEqual(t, strings.Contains(msg, ":trophy: *Presenze*\n1. mario: 3 giorni su 3 (100%)\n2. luigi: 2 giorni su 3 (66%)\n3. ospite: 1 giorno su 3 (33%)"), true, msg)
+	assertEqual(t, strings.Contains(msg, ":zap: *Chi ordina per primo*\n1. mario: 2 volte\n2. luigi: 1 volta"), true, msg)
+}
+
+func TestLeaderboardDue(t *testing.T) {
+	var s LeaderboardSettings
+	first := time.Date(2019, 4, 1, 10, 0, 0, 0, time.UTC)
+	_, due := s.Due(first)
+	assertEqual(t, due, false, "no channel")
+
+	s.Channel = "C1"
+	_, due = s.Due(first.Add(-2 * time.Hour))
+	assertEqual(t, due, false, "too early")
+	month, due := s.Due(first)
+	assertEqual(t, due, true, "")
+	assertEqual(t, monthKey(month.Start), "2019-03", "")
+
+	s.Sent = "2019-03"
+	_, due = s.Due(first.AddDate(0, 0, 3))
+	assertEqual(t, due, false, "already sent")
+}
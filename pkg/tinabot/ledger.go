@@ -0,0 +1,297 @@
+package tinabot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/events"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// LedgerEntryKind tells why a debt was recorded
+type LedgerEntryKind string
+
+const (
+	// LedgerOrder is a debt created by paying the group order for someone
+	LedgerOrder LedgerEntryKind = "order"
+	// LedgerPayment is money given back to settle a debt
+	LedgerPayment LedgerEntryKind = "payment"
+)
+
+// LedgerEntry records that Debtor owes Amount to Creditor
+type LedgerEntry struct {
+	Date     time.Time
+	Kind     LedgerEntryKind
+	Debtor   User
+	Creditor User
+	Amount   decimal.Decimal
+}
+
+// Ledger keeps track of who paid for the group orders and who owes whom
+type Ledger struct {
+	Entries []LedgerEntry
+}
+
+// Load loads the ledger from brain, an empty ledger is returned if none is stored
+func (l *Ledger) Load(brain DataStore) error {
+	if err := brain.Get("ledger", l); err != nil {
+		l.Entries = nil
+		return err
+	}
+	return nil
+}
+
+// Save saves the ledger to brain
+func (l *Ledger) Save(brain DataStore) error {
+	return brain.Set("ledger", *l)
+}
+
+// SaveCAS applies fn to the latest stored ledger and saves it, so that no
+// concurrent change is lost
+func (l *Ledger) SaveCAS(brain CASStore, fn func(*Ledger) error) error {
+	return brain.Update("ledger", l, func() error {
+		return fn(l)
+	})
+}
+
+// orderDebtors returns the users owing money to payer for the order, sorted by name
+func orderDebtors(payer User, debts map[User]decimal.Decimal) []User {
+	var users []User
+	for u, amount := range debts {
+		if u != payer && !amount.IsZero() {
+			users = append(users, u)
+		}
+	}
+	sortUsers(users)
+	return users
+}
+
+// AddOrder records that payer paid the whole order, each other user owes her
+// own share and the ones of her guests. Returns the number of debts added.
+func (l *Ledger) AddOrder(payer User, order *Order, date time.Time) int {
+	debts := order.Debts()
+	debtors := orderDebtors(payer, debts)
+	for _, u := range debtors {
+		l.Entries = append(l.Entries, LedgerEntry{date, LedgerOrder, u, payer, debts[u]})
+	}
+	return len(debtors)
+}
+
+// PaidBy returns who paid the order of the day of date, if someone did
+func (l *Ledger) PaidBy(date time.Time) (User, bool) {
+	for _, e := range l.Entries {
+		if e.Kind == LedgerOrder && sameDay(e.Date, date) {
+			return e.Creditor, true
+		}
+	}
+	return User{}, false
+}
+
+// Counterpart returns the user named name who owes user money, or who user
+// owes money to, in the ledger
+func (l *Ledger) Counterpart(user User, name string) (User, bool) {
+	for _, e := range l.Entries {
+		if e.Debtor == user && strings.EqualFold(e.Creditor.Name, name) {
+			return e.Creditor, true
+		}
+		if e.Creditor == user && strings.EqualFold(e.Debtor.Name, name) {
+			return e.Debtor, true
+		}
+	}
+	return User{}, false
+}
+
+// Settle records that debtor gave back amount to creditor
+func (l *Ledger) Settle(debtor, creditor User, amount decimal.Decimal, date time.Time) {
+	l.Entries = append(l.Entries, LedgerEntry{date, LedgerPayment, creditor, debtor, amount})
+}
+
+// Owed returns how much debtor owes to creditor, negative if it's the other way round
+func (l *Ledger) Owed(debtor, creditor User) decimal.Decimal {
+	owed := decimal.Zero
+	for _, e := range l.Entries {
+		if e.Debtor == debtor && e.Creditor == creditor {
+			owed = owed.Add(e.Amount)
+		} else if e.Debtor == creditor && e.Creditor == debtor {
+			owed = owed.Sub(e.Amount)
+		}
+	}
+	return owed
+}
+
+// Balances returns the non zero balances of user with everyone else,
+// positive amounts are owed by user.
+func (l *Ledger) Balances(user User) map[User]decimal.Decimal {
+	balances := make(map[User]decimal.Decimal)
+	for _, e := range l.Entries {
+		if e.Debtor == user {
+			balances[e.Creditor] = balances[e.Creditor].Add(e.Amount)
+		} else if e.Creditor == user {
+			balances[e.Debtor] = balances[e.Debtor].Sub(e.Amount)
+		}
+	}
+	for u, b := range balances {
+		if b.IsZero() {
+			delete(balances, u)
+		}
+	}
+	return balances
+}
+
+// Monthly returns how much user spent for her lunches and how much she
+// anticipated for the others in the month of date.
+func (l *Ledger) Monthly(user User, date time.Time) (spent, lent decimal.Decimal) {
+	spent, lent = decimal.Zero, decimal.Zero
+	for _, e := range l.Entries {
+		if e.Kind != LedgerOrder || e.Date.Year() != date.Year() || e.Date.Month() != date.Month() {
+			continue
+		}
+		if e.Debtor == user {
+			spent = spent.Add(e.Amount)
+		} else if e.Creditor == user {
+			lent = lent.Add(e.Amount)
+		}
+	}
+	return spent, lent
+}
+
+func formatBalances(balances map[User]decimal.Decimal) string {
+	var r []string
+	for u, b := range balances {
+		if b.IsPositive() {
+			r = append(r, fmt.Sprintf("Devi €%s a %s", b.StringFixed(2), u.Name))
+		} else {
+			r = append(r, fmt.Sprintf("%s ti deve €%s", u.Name, b.Neg().StringFixed(2)))
+		}
+	}
+	sort.Strings(r)
+	return strings.Join(r, "\n")
+}
+
+// Paid records that user paid today's order for everyone
+func (t *TinaBot) Paid(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	order := getOrder(t.brain)
+	payer := User{user.Name, user.ID}
+
+	var ledger Ledger
+	var paidBy User
+	errPaid := fmt.Errorf("already paid")
+	n := 0
+	err := ledger.SaveCAS(t.brain, func(l *Ledger) error {
+		if u, ok := l.PaidBy(order.Timestamp); ok {
+			paidBy = u
+			return errPaid
+		}
+		n = l.AddOrder(payer, order, order.Timestamp)
+		return nil
+	})
+	if err == errPaid {
+		t.bot.Message(msg.Channel, fmt.Sprintf("L'ordine di oggi l'ha già pagato %s", paidBy.Name))
+		return
+	}
+	if err != nil {
+		t.bot.Message(msg.Channel, "Errore nel salvare i debiti: "+err.Error())
+		return
+	}
+	if n == 0 {
+		t.bot.Message(msg.Channel, "Non c'è nessun debito da registrare per l'ordine di oggi")
+		return
+	}
+
+	var r []string
+	debts := order.Debts()
+	for _, u := range orderDebtors(payer, debts) {
+		r = append(r, fmt.Sprintf("%s deve €%s", u.Name, debts[u].StringFixed(2)))
+	}
+	t.bot.Message(msg.Channel, fmt.Sprintf("Ok, %s ha pagato l'ordine di oggi:\n%s", payer.Name, strings.Join(r, "\n")))
+}
+
+// Balance tells the user who she owes money to, and who owes her
+func (t *TinaBot) Balance(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	var ledger Ledger
+	ledger.Load(t.brain)
+
+	balances := ledger.Balances(User{user.Name, user.ID})
+	if len(balances) == 0 {
+		t.bot.Reply(msg, slackbot.Ephemeral, "Non hai nessun debito o credito!")
+		return
+	}
+	t.bot.Reply(msg, slackbot.Ephemeral, "Ecco i tuoi conti:\n"+formatBalances(balances))
+}
+
+// Settle records that the user gave back money to someone, the whole debt if
+// no amount is given
+func (t *TinaBot) Settle(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	debtor := User{user.Name, user.ID}
+	creditor := User{args[1], ""}
+	if finduser := getUserInfo(t.bot.Platform, args[1]); finduser != nil {
+		creditor = User{finduser.Name, finduser.ID}
+	}
+
+	var amount decimal.Decimal
+	if args[2] != "" {
+		a, err := parseAmount(args[2])
+		if err != nil || !a.IsPositive() {
+			t.bot.Message(msg.Channel, fmt.Sprintf("Importo '%s' non valido", args[2]))
+			return
+		}
+		amount = a
+	}
+
+	var ledger Ledger
+	errNoDebt := fmt.Errorf("Non hai nessun debito con %s", creditor.Name)
+	err := ledger.SaveCAS(t.brain, func(l *Ledger) error {
+		// the creditor not found on the platform is looked up in the
+		// ledger, whose entries carry the user IDs
+		if creditor.ID == "" {
+			if u, ok := l.Counterpart(debtor, creditor.Name); ok {
+				creditor = u
+			}
+		}
+		if args[2] == "" {
+			amount = l.Owed(debtor, creditor)
+		}
+		if !amount.IsPositive() {
+			return errNoDebt
+		}
+		l.Settle(debtor, creditor, amount, time.Now())
+		return nil
+	})
+	if err == errNoDebt {
+		t.bot.Message(msg.Channel, err.Error())
+		return
+	}
+	if err != nil {
+		t.bot.Message(msg.Channel, "Errore nel salvare il pagamento: "+err.Error())
+		return
+	}
+	t.events.Publish(events.PaymentSettled, ledger.Entries[len(ledger.Entries)-1])
+
+	reply := fmt.Sprintf("Ok, hai restituito €%s a %s", amount.StringFixed(2), creditor.Name)
+	if left := ledger.Owed(debtor, creditor); left.IsPositive() {
+		reply += fmt.Sprintf(", gli devi ancora €%s", left.StringFixed(2))
+	}
+	t.bot.Message(msg.Channel, reply)
+}
+
+// MonthlySummary shows the user how much she spent in the current month
+func (t *TinaBot) MonthlySummary(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	var ledger Ledger
+	ledger.Load(t.brain)
+
+	now := time.Now()
+	u := User{user.Name, user.ID}
+	spent, lent := ledger.Monthly(u, now)
+
+	reply := fmt.Sprintf("Bilancio di %s per %s:\nSpesa pranzi pagati da altri: €%s\nAnticipato per gli altri: €%s",
+		u.Name, now.Format("01/2006"), spent.StringFixed(2), lent.StringFixed(2))
+	if balances := ledger.Balances(u); len(balances) > 0 {
+		reply += "\n" + formatBalances(balances)
+	}
+	t.bot.Reply(msg, slackbot.Ephemeral, reply)
+}
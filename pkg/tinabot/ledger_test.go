@@ -0,0 +1,94 @@
+package tinabot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestLedger(t *testing.T) {
+	payer := User{"payer", "1"}
+	u1 := User{"u1", "2"}
+	u2 := User{"u2", "3"}
+
+	order := NewOrder()
+	var p, s UserChoice
+	p.Add(tuttobene.MenuRow{Content: "primo", Type: tuttobene.Primo, Price: decimal.New(5, 0)})
+	s.Add(tuttobene.MenuRow{Content: "secondo", Type: tuttobene.Secondo, Price: decimal.New(7, 0)})
+	order.Set(payer, []UserChoice{p})
+	order.Set(u1, []UserChoice{p, s})
+	order.Set(u2, []UserChoice{s})
+
+	var l Ledger
+	date := time.Date(2019, 3, 12, 13, 0, 0, 0, time.UTC)
+	assertEqual(t, l.AddOrder(payer, order, date), 2, "")
+	assertEqual(t, l.Owed(u1, payer).String(), "12", "")
+	assertEqual(t, l.Owed(payer, u2).String(), "-7", "")
+	assertEqual(t, len(l.Balances(payer)), 2, "")
+	paidBy, ok := l.PaidBy(date.Add(-time.Hour))
+	assertEqual(t, paidBy, payer, "")
+	assertEqual(t, ok, true, "")
+	_, ok = l.PaidBy(date.AddDate(0, 0, 1))
+	assertEqual(t, ok, false, "")
+
+	// the users not found on the platform are matched by name
+	creditor, ok := l.Counterpart(u1, "PAYER")
+	assertEqual(t, creditor, payer, "")
+	assertEqual(t, ok, true, "")
+	_, ok = l.Counterpart(u1, "u2")
+	assertEqual(t, ok, false, "")
+
+	l.Settle(u1, payer, decimal.New(10, 0), date)
+	assertEqual(t, l.Owed(u1, payer).String(), "2", "")
+	l.Settle(u2, payer, decimal.New(7, 0), date)
+	assertEqual(t, len(l.Balances(u2)), 0, "")
+	assertEqual(t, formatBalances(l.Balances(payer)), "u1 ti deve €2.00", "")
+
+	spent, lent := l.Monthly(u1, date)
+	assertEqual(t, spent.String(), "12", "")
+	assertEqual(t, lent.String(), "0", "")
+	_, lent = l.Monthly(payer, date.AddDate(0, 1, 0))
+	assertEqual(t, lent.String(), "0", "")
+
+	b := brain.NewBrainMock()
+	assertEqual(t, l.Save(b), nil, "")
+	var loaded Ledger
+	assertEqual(t, loaded.Load(b), nil, "")
+	assertEqual(t, loaded.Owed(u1, payer).String(), "2", "")
+}
+
+func TestLedgerGuests(t *testing.T) {
+	payer := User{"payer", "1"}
+	host := User{"host", "2"}
+	guest := User{"guest_mario", ""}
+	assistant := User{"assistant", "3"}
+	boss := User{"boss", "4"}
+
+	var p UserChoice
+	p.Add(tuttobene.MenuRow{Content: "primo", Type: tuttobene.Primo, Price: decimal.New(5, 0)})
+
+	order := NewOrder()
+	order.SetBy(host, host, []UserChoice{p})
+	order.SetBy(host, guest, []UserChoice{p})
+	order.SetBy(assistant, boss, []UserChoice{p})
+
+	assertEqual(t, order.Payer(guest), host, "")
+	assertEqual(t, order.Payer(boss), boss, "")
+	_, ok := order.OrderedBy[host]
+	assertEqual(t, ok, false, "")
+	assertEqual(t, order.String(), "3 primo [host, guest_mario da host, boss da assistant]", "")
+
+	var l Ledger
+	assertEqual(t, l.AddOrder(payer, order, order.Timestamp), 2, "")
+	assertEqual(t, l.Owed(host, payer).String(), "10", "")
+	assertEqual(t, l.Owed(boss, payer).String(), "5", "")
+	assertEqual(t, l.Owed(assistant, payer).String(), "0", "")
+
+	order.ClearUser(guest)
+	_, ok = order.OrderedBy[guest]
+	assertEqual(t, ok, false, "")
+}
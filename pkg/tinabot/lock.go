@@ -0,0 +1,58 @@
+package tinabot
+
+import "sync"
+
+// keyLocks serializes the updates of the same key of the brain by the events
+// handled at once, each one in its goroutine: without it they keep
+// conflicting on the busiest keys, like the order at noon, until the retries
+// of the brain run out and an order gets lost
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+// keyLock is the lock of a key, dropped when nobody waits for it
+type keyLock struct {
+	sync.Mutex
+	waiting int
+}
+
+// orderLocks are the locks of the orders, one per office
+var orderLocks = keyLocks{locks: make(map[string]*keyLock)}
+
+// Lock locks key and returns the function unlocking it
+func (k *keyLocks) Lock(key string) func() {
+	k.mu.Lock()
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyLock{}
+		k.locks[key] = l
+	}
+	l.waiting++
+	k.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		k.mu.Lock()
+		if l.waiting--; l.waiting == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
+
+// keyer is a view of the brain, like a namespace, writing its keys with
+// another name in the underlying store
+type keyer interface {
+	Key(key string) string
+}
+
+// storeKey returns key as written by brain in the underlying store, so that
+// the views of the same office lock the same key
+func storeKey(brain DataStore, key string) string {
+	if k, ok := brain.(keyer); ok {
+		return k.Key(key)
+	}
+	return key
+}
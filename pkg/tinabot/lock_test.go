@@ -0,0 +1,102 @@
+package tinabot
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestKeyLocks(t *testing.T) {
+	k := keyLocks{locks: make(map[string]*keyLock)}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	holding, most := 0, 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer k.Lock("order")()
+			mu.Lock()
+			holding++
+			most = max(most, holding)
+			mu.Unlock()
+			mu.Lock()
+			holding--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	assertEqual(t, most, 1, "a key is locked by one at a time")
+	assertEqual(t, len(k.locks), 0, "the unused locks are dropped")
+
+	// the other keys aren't blocked
+	unlock := k.Lock("a")
+	k.Lock("b")()
+	unlock()
+}
+
+// noRetry is a store failing the conflicting updates instead of retrying
+// them, like the brain once its retries run out
+type noRetry struct {
+	*brain.Memory
+	mu sync.Mutex
+}
+
+func (s *noRetry) Update(key string, q interface{}, fn func() error) error {
+	old, err := s.Read(key)
+	if err == nil {
+		if err := json.Unmarshal([]byte(old), q); err != nil {
+			return err
+		}
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cur, _ := s.Read(key); cur != old {
+		return brain.ErrConflict
+	}
+	return s.Set(key, q)
+}
+
+func TestOrderConcurrent(t *testing.T) {
+	root := &noRetry{Memory: brain.NewMemory()}
+	var p UserChoice
+	p.Add(tuttobene.MenuRow{Content: "primo", Type: tuttobene.Primo})
+
+	// each event has its view of the office, see Scope, and they all update
+	// the order at once
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			var order Order
+			errs <- order.SaveCAS(brain.Namespace(root, "office:T1:C1"), func(o *Order) error {
+				time.Sleep(time.Millisecond)
+				o.Set(User{fmt.Sprint("user", i), fmt.Sprint("U", i)}, []UserChoice{p})
+				return nil
+			})
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assertEqual(t, err, nil, "")
+	}
+
+	var order Order
+	assertEqual(t, order.Load(brain.Namespace(root, "office:T1:C1")), nil, "")
+	assertEqual(t, len(order.Users), 20, "no order is lost")
+	assertEqual(t, order.Version, 20, "")
+}
@@ -3,18 +3,18 @@ package tinabot
 import (
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/config"
 	"github.com/develersrl/lunches/pkg/slackbot"
-	"github.com/nlopes/slack"
 )
 
 func Mark(user, food string) error {
-	markURL := os.Getenv("MARK_URL")
+	markURL := config.Current().MarkURL
 	if markURL == "" {
 		return errors.New("no mark URL found")
 	}
@@ -33,14 +33,14 @@ func Mark(user, food string) error {
 			defer resp.Body.Close()
 			break
 		} else {
-			log.Printf("ERROR marking user %s: %s, retrying\n", user, err.Error())
+			slog.Warn("Error marking user, retrying", "user", user, "err", err)
 		}
 	}
 	return err
 }
 
-func MarkUser(user *slack.User, food string) error {
-	mail := user.Profile.Email
+func MarkUser(user *chat.User, food string) error {
+	mail := user.Email
 	if strings.Contains(mail, "@develer.com") {
 		nick := strings.TrimSuffix(mail, "@develer.com")
 		return Mark(nick, food)
@@ -48,7 +48,7 @@ func MarkUser(user *slack.User, food string) error {
 	return errors.New("user does not have a Develer mail")
 }
 
-func (t *TinaBot) Mark(bot *slackbot.Bot, msg *slackbot.BotMsg, user *slack.User, args ...string) {
+func (t *TinaBot) Mark(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
 	food := strings.TrimSpace(args[1])
 
 	validFood := []string{
@@ -69,15 +69,12 @@ func (t *TinaBot) Mark(bot *slackbot.Bot, msg *slackbot.BotMsg, user *slack.User
 
 	for _, f := range validFood {
 		if strings.ToUpper(f) == strings.ToUpper(food) {
-			// This can be slow so spawn a goroutine to give Slack a fast reply and avoid retrys
-			go func() {
-				err := MarkUser(user, f)
-				if err != nil {
-					t.bot.Message(msg.Channel, "errore: "+err.Error())
-					return
-				}
-				t.bot.Message(msg.Channel, fmt.Sprintf("Ok, segnato '%s' per %s sul foglio dei pranzi", f, user.Name))
-			}()
+			if !strings.Contains(user.Email, "@develer.com") {
+				t.bot.Message(msg.Channel, "errore: user does not have a Develer mail")
+				return
+			}
+			// This can be slow so it's a job, to give Slack a fast reply and avoid retrys
+			t.enqueue(msg.Channel, jobMark, markJob{t.team, msg.Channel, *user, f})
 			return
 		}
 	}
@@ -0,0 +1,66 @@
+package tinabot
+
+import (
+	"github.com/develersrl/lunches/pkg/matcher"
+)
+
+// loadMatcher returns the dish matcher with the choices learned so far
+func (t *TinaBot) loadMatcher() *matcher.Matcher {
+	m := matcher.New()
+	m.Load(t.brain)
+	return m
+}
+
+// addAmbiguous remembers that user was asked which dish she meant, the next
+// order she places tells it
+func (t *TinaBot) addAmbiguous(user User, a *matcher.Ambiguous) {
+	repo := pendingMatchesRepo(t.brain)
+	pending, _ := repo.Get(userKey(user))
+	pending = append(pending, *a)
+	if err := repo.Put(userKey(user), pending); err != nil {
+		t.logger().Error("Error saving pending matches", "err", err)
+	}
+}
+
+// learnMatches teaches m the dishes of choices that answer the pending
+// questions, returns how many were answered
+func learnMatches(m *matcher.Matcher, pending []matcher.Ambiguous, choices []UserChoice) int {
+	ordered := make(map[string]bool)
+	for _, c := range choices {
+		for _, d := range c.Dishes {
+			ordered[d.Content] = true
+		}
+	}
+
+	learned := 0
+	for _, a := range pending {
+		for _, d := range a.Dishes {
+			if ordered[d] {
+				m.Learn(a.Query, d)
+				learned++
+				break
+			}
+		}
+	}
+	return learned
+}
+
+// confirmMatches learns from the order placed by user after being asked
+// which dish she meant
+func (t *TinaBot) confirmMatches(user User, choices []UserChoice) {
+	repo := pendingMatchesRepo(t.brain)
+	pending, err := repo.Get(userKey(user))
+	if err != nil || len(pending) == 0 {
+		return
+	}
+
+	m := t.loadMatcher()
+	if learnMatches(m, pending, choices) > 0 {
+		if err := m.Save(t.brain); err != nil {
+			t.logger().Error("Error saving matcher", "err", err)
+		}
+	}
+	if err := repo.Put(userKey(user), []matcher.Ambiguous{}); err != nil {
+		t.logger().Error("Error saving pending matches", "err", err)
+	}
+}
@@ -0,0 +1,36 @@
+package tinabot
+
+import (
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/matcher"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestLearnMatches(t *testing.T) {
+	menu := tuttobene.Menu{Rows: []tuttobene.MenuRow{
+		{Content: "Pollo arrosto", Type: tuttobene.Secondo},
+		{Content: "Insalata di pollo", Type: tuttobene.Secondo},
+	}}
+	m := matcher.New()
+
+	_, _, err := parseChoice(m, menu, "pollo")
+	amb, ok := err.(*matcher.Ambiguous)
+	assertEqual(t, ok, true, "")
+
+	c, _, err := parseChoice(m, menu, "insalata")
+	assertEqual(t, err, nil, "")
+	assertEqual(t, learnMatches(m, []matcher.Ambiguous{*amb}, []UserChoice{c}), 1, "")
+
+	c, reply, err := parseChoice(m, menu, "pollo")
+	assertEqual(t, err, nil, "")
+	assertEqual(t, c.String(), "Insalata di pollo", "")
+	assertEqual(t, reply, "Trovato: Insalata di pollo (secondi piatti), come le altre volte\n", "")
+
+	// the empty side dishes are skipped
+	c, _, err = parseChoice(m, menu, "insalata & ")
+	assertEqual(t, err, nil, "")
+	assertEqual(t, c.String(), "Insalata di pollo", "")
+	_, _, err = parseChoice(m, menu, " & ")
+	assertEqual(t, err != nil, true, "")
+}
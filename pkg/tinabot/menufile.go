@@ -0,0 +1,191 @@
+package tinabot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/sentry"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// The action ids of the buttons of the uploaded menu preview
+const (
+	actionPublishMenu = "publish_menu"
+	actionDiscardMenu = "discard_menu"
+)
+
+// uploadRepo keeps the menus uploaded on Slack waiting to be confirmed, by
+// file ID
+func uploadRepo(b DataStore) brain.Repo[tuttobene.Menu] {
+	return brain.NewRepo[tuttobene.Menu](b, "menu:upload:")
+}
+
+// menuDiagnostics returns the problems found in a parsed menu that may be
+// due to a parse error, e.g. missing prices or courses
+func menuDiagnostics(m tuttobene.Menu, now time.Time) []string {
+	var diags []string
+	switch {
+	case m.Date.IsZero():
+		diags = append(diags, "non ho trovato la data del menù")
+	case !sameDay(m.Date, now) && m.Date.Before(now):
+		diags = append(diags, "il menù è vecchio, è del "+m.Date.Format("02/01/2006"))
+	case !sameDay(m.Date, now):
+		diags = append(diags, "il menù è per "+formatDay(m.Date)+", non per oggi")
+	}
+
+	count := make(map[tuttobene.MenuRowType]int)
+	var noPrice, unknown []string
+	for _, r := range m.Rows {
+		count[r.Type]++
+		switch {
+		case r.Type == tuttobene.Unknonwn:
+			unknown = append(unknown, r.Content)
+		case r.Type != tuttobene.Empty && !r.Price.IsPositive():
+			noPrice = append(noPrice, r.Content)
+		}
+	}
+	for _, typ := range []tuttobene.MenuRowType{tuttobene.Primo, tuttobene.Secondo, tuttobene.Contorno} {
+		if count[typ] == 0 {
+			diags = append(diags, "nessun piatto tra i "+tuttobene.Titles[typ])
+		}
+	}
+	if len(noPrice) > 0 {
+		diags = append(diags, "piatti senza prezzo: "+strings.Join(noPrice, ", "))
+	}
+	if len(unknown) > 0 {
+		diags = append(diags, "righe non riconosciute: "+strings.Join(unknown, ", "))
+	}
+	return diags
+}
+
+// menuUploadBlocks returns the preview of the uploaded menu, with the
+// diagnostics and the buttons to publish or discard it
+func menuUploadBlocks(fileID, name string, m tuttobene.Menu, diags []string) []chat.Block {
+	report := fmt.Sprintf("Nessun problema trovato, %d piatti", len(m.Rows))
+	if len(diags) > 0 {
+		report = "Attenzione: " + strings.Join(diags, "; ")
+	}
+
+	publish := chat.Button{
+		ActionID: actionPublishMenu,
+		Value:    fileID,
+		Text:     "Pubblica",
+		Style:    chat.Primary,
+		Confirm: &chat.Confirm{
+			Title: "Pubblichi il menù?",
+			Text:  "Il menù sarà impostato e mostrato a tutti",
+			Yes:   "Pubblica",
+			No:    "Torna indietro",
+		},
+	}
+	discard := chat.Button{ActionID: actionDiscardMenu, Value: fileID, Text: "Scarta", Style: chat.Danger}
+
+	return []chat.Block{
+		chat.Section{Text: "Ho letto il menù da *" + name + "*:"},
+		chat.Section{Text: truncate(m.Format(true), maxSectionText)},
+		chat.Context{Text: truncate(report, maxSectionText)},
+		chat.Actions{Elements: []chat.Element{publish, discard}},
+	}
+}
+
+// IsMenuFileRefused tells if err is the refusal of a menu file over the
+// limits of the parser, rather than a failure
+func IsMenuFileRefused(err error) bool {
+	return errors.Is(err, tuttobene.ErrTooLarge) || errors.Is(err, tuttobene.ErrTooManyRows)
+}
+
+// MenuFileError returns the message of the error parsing the menu file name
+func MenuFileError(name string, err error) string {
+	switch {
+	case errors.Is(err, tuttobene.ErrTooLarge):
+		return "Il file " + name + " è troppo grande per essere un menù!"
+	case errors.Is(err, tuttobene.ErrTooManyRows):
+		return fmt.Sprintf("Il file %s ha troppe righe per essere un menù, al massimo %d!", name, tuttobene.MaxRows)
+	}
+	return "Menù " + name + " ricevuto, errore durante l'analisi: " + err.Error()
+}
+
+// FileShared reads the XLSX menus shared in the channels of the bot, or
+// sent to it by an admin, showing a preview to the user who shared it: the
+// menu is published only when an admin confirms it. The other files are
+// ignored.
+func (t *TinaBot) FileShared(channel, userID, fileID string) {
+	if channel == "" || userID == t.bot.UserID {
+		return
+	}
+	file, err := t.bot.Platform.FileInfo(fileID)
+	if err != nil {
+		slackbot.APIError("files.info")
+		t.logger().Error("Error getting the shared file", "file", fileID, "err", err)
+		return
+	}
+	if file.Type != "xlsx" && !strings.HasSuffix(strings.ToLower(file.Name), ".xlsx") {
+		return
+	}
+	if strings.HasPrefix(channel, "D") && !canAdmin(t.brain, t.team, channel, User{ID: userID}) {
+		t.bot.Message(channel, "Mi spiace, solo gli amministratori possono mandarmi il menù")
+		return
+	}
+	if file.Size > tuttobene.MaxFileSize {
+		t.bot.Message(channel, "Il file "+file.Name+" è troppo grande per essere un menù!")
+		return
+	}
+
+	data, err := t.bot.Platform.DownloadFile(file)
+	if err != nil {
+		slackbot.APIError("files.download")
+		t.logger().Error("Error downloading the shared file", "file", fileID, "err", err)
+		t.bot.Message(channel, "Non riesco a scaricare il file "+file.Name+": "+err.Error())
+		return
+	}
+	end := t.bot.Trace("menu.parse", attribute.String("menu.file", file.Name))
+	m, err := tuttobene.ParseMenuBytes(data)
+	end(err)
+	if err != nil {
+		if !IsMenuFileRefused(err) {
+			sentry.CaptureError(sentry.WithContext(t.bot.Context(), nil, map[string]any{"file": file.Name, "size": file.Size}), err)
+		}
+		t.bot.Message(channel, MenuFileError(file.Name, err))
+		return
+	}
+	if err := uploadRepo(t.brain).Put(fileID, *m); err != nil {
+		t.bot.Message(channel, "Errore nel salvare il menù: "+err.Error())
+		return
+	}
+
+	diags := menuDiagnostics(*m, NewOrder().Timestamp)
+	t.bot.Blocks(channel, userID, "Menù letto da "+file.Name, menuUploadBlocks(fileID, file.Name, *m, diags)...)
+}
+
+// menuUploadAction handles the buttons of the uploaded menu preview, only
+// the admins can publish it
+func (t *TinaBot) menuUploadAction(responseURL string, msg *slackbot.BotMsg, user *chat.User, a chat.Action) {
+	uploads := uploadRepo(t.brain)
+	m, err := uploads.Get(a.Value)
+	if err != nil {
+		t.bot.Replace(responseURL, "Il menù non è più disponibile, caricalo di nuovo")
+		return
+	}
+
+	switch a.ActionID {
+	case actionPublishMenu:
+		if !t.canAdmin(msg, user) {
+			t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono pubblicare il menù")
+			return
+		}
+		uploads.Delete(a.Value)
+		t.bot.Replace(responseURL, "Menù pubblicato da "+user.Name)
+		t.setMenu(msg.Channel, m)
+
+	case actionDiscardMenu:
+		uploads.Delete(a.Value)
+		t.bot.Replace(responseURL, "Ok, menù scartato")
+	}
+}
@@ -0,0 +1,91 @@
+package tinabot
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestMenuDiagnostics(t *testing.T) {
+	now := time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC)
+	price := decimal.New(5, 0)
+	menu := tuttobene.Menu{Date: now, Rows: []tuttobene.MenuRow{
+		{Content: "Lasagne", Type: tuttobene.Primo, Price: price},
+		{Content: "Tagliata", Type: tuttobene.Secondo, Price: price},
+		{Content: "Patate", Type: tuttobene.Contorno, Price: price},
+	}}
+	assertEqual(t, len(menuDiagnostics(menu, now)), 0, "")
+
+	menu.Date = now.AddDate(0, 0, -1)
+	menu.Rows = append(menu.Rows[:2], tuttobene.MenuRow{Content: "Boh", Type: tuttobene.Unknonwn})
+	menu.Rows[0].Price = decimal.Zero
+	diags := menuDiagnostics(menu, now)
+	assertEqual(t, strings.Join(diags, "; "), "il menù è vecchio, è del 13/03/2019; nessun piatto tra i contorni; piatti senza prezzo: Lasagne; righe non riconosciute: Boh", "")
+
+	menu.Date = time.Time{}
+	assertEqual(t, menuDiagnostics(menu, now)[0], "non ho trovato la data del menù", "")
+
+	data, err := json.Marshal(slackbot.SlackBlocks(menuUploadBlocks("F123", "menu.xlsx", menu, diags)))
+	assertEqual(t, err, nil, "")
+	for _, s := range []string{"menu.xlsx", "Lasagne", "Attenzione: il menù è vecchio", `"value":"F123"`, actionPublishMenu, actionDiscardMenu} {
+		assertEqual(t, strings.Contains(string(data), s), true, s)
+	}
+}
+
+func TestMenuFileError(t *testing.T) {
+	_, err := tuttobene.ParseMenuBytes(make([]byte, tuttobene.MaxFileSize+1))
+	assertEqual(t, IsMenuFileRefused(err), true, "")
+	assertEqual(t, MenuFileError("menu.xlsx", err), "Il file menu.xlsx è troppo grande per essere un menù!", "")
+
+	_, err = tuttobene.ParseMenuBytes([]byte("boh"))
+	assertEqual(t, IsMenuFileRefused(err), false, "")
+	assertEqual(t, strings.HasPrefix(MenuFileError("menu.xlsx", err), "Menù menu.xlsx ricevuto, errore durante l'analisi: "), true, "")
+}
+
+// filePlatform shares file, recording the messages sent
+type filePlatform struct {
+	sentPlatform
+	file chat.File
+}
+
+func (p *filePlatform) FileInfo(id string) (*chat.File, error) {
+	f := p.file
+	return &f, nil
+}
+
+func TestFileShared(t *testing.T) {
+	b := brain.NewBrainMock()
+	p := &filePlatform{file: chat.File{ID: "F1", Name: "menu.xlsx", Type: "xlsx", Size: tuttobene.MaxFileSize + 1}}
+	bot := slackbot.New("B1", nil)
+	bot.Platform = p
+	tb := New(bot, b)
+	b.SAdd(adminsPrefix+"C1", "U1")
+	tooLarge := "Il file menu.xlsx è troppo grande per essere un menù!"
+
+	// in any channel of the bot
+	tb.FileShared("C9", "U2", "F1")
+	assertEqual(t, strings.Join(p.sent, "\n"), tooLarge, "")
+
+	// in the direct messages only from the admins
+	p.sent = nil
+	tb.FileShared("D2", "U2", "F1")
+	assertEqual(t, strings.Join(p.sent, "\n"), "Mi spiace, solo gli amministratori possono mandarmi il menù", "")
+	p.sent = nil
+	tb.FileShared("D1", "U1", "F1")
+	assertEqual(t, strings.Join(p.sent, "\n"), tooLarge, "")
+
+	// the files of the bot and the other files are ignored
+	p.sent = nil
+	tb.FileShared("C9", "B1", "F1")
+	p.file.Name, p.file.Type = "menu.pdf", "pdf"
+	tb.FileShared("C9", "U2", "F1")
+	assertEqual(t, len(p.sent), 0, "")
+}
@@ -0,0 +1,170 @@
+package tinabot
+
+import (
+	"strings"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// defaultMenuRequest is the text of the menu request if none is set, $DATE
+// is replaced by the day
+const defaultMenuRequest = "Buongiorno, potete mandarci il menù di $DATE? Grazie!"
+
+// MenuRequest configures the request of the menu sent every weekday
+// morning for a channel: by email to the restaurant if Email is set,
+// otherwise as a reminder to the admins of the channel
+type MenuRequest struct {
+	Time     string // "15:04" of the request
+	Email    string `json:",omitempty"`
+	Template string `json:",omitempty"`
+}
+
+// menuRequestRepo keeps the menu requests by channel
+func menuRequestRepo(b DataStore) brain.Repo[MenuRequest] {
+	return brain.NewRepo[MenuRequest](b, "menurequest:")
+}
+
+// Text returns the request of the menu of the day of now
+func (r MenuRequest) Text(now time.Time) string {
+	tmpl := r.Template
+	if tmpl == "" {
+		tmpl = defaultMenuRequest
+	}
+	return strings.Replace(tmpl, "$DATE", "oggi "+now.Format("02/01/2006"), -1)
+}
+
+// Due tells if the request has to be sent in the interval centered on now,
+// only from Monday to Friday
+func (r MenuRequest) Due(now time.Time, interval time.Duration) bool {
+	if now.Weekday() == time.Saturday || now.Weekday() == time.Sunday {
+		return false
+	}
+	t, ok := at(now, r.Time)
+	return ok && inWindow(t, now, interval)
+}
+
+func (r MenuRequest) String() string {
+	s := "Richiesta del menù nei giorni feriali alle " + r.Time
+	if r.Email != "" {
+		s += ", per email a " + r.Email
+	} else {
+		s += ", come promemoria agli amministratori del canale"
+	}
+	return s + ":\n> " + r.Text(time.Now())
+}
+
+// DueMenuRequests returns the menu requests to send in the interval
+// centered on now, by channel. None is due if today's menu is already set
+// or there's no lunch today.
+func DueMenuRequests(brain DataStore, now time.Time, interval time.Duration) map[string]MenuRequest {
+	var c Calendar
+	c.Load(brain)
+	if closed, _ := c.IsClosed(now); closed {
+		return nil
+	}
+	if _, err := LoadMenu(brain, now); err == nil {
+		return nil
+	}
+	all, _ := menuRequestRepo(brain).All()
+	due := make(map[string]MenuRequest)
+	for ch, r := range all {
+		if r.Due(now, interval) {
+			due[ch] = r
+		}
+	}
+	return due
+}
+
+// MenuRequestReminder returns the reminder to ask for the menu posted on
+// channel, mentioning its admins
+func MenuRequestReminder(brain RoleStore, channel string, r MenuRequest, now time.Time) string {
+	var mentions []string
+	ids, _ := brain.SMembers(adminsPrefix + channel)
+	for _, id := range ids {
+		mentions = append(mentions, "<@"+id+">")
+	}
+	for _, a := range globalAdmins() {
+		if _, id, ok := strings.Cut(a, ":"); ok && id != "" {
+			mentions = append(mentions, "<@"+id+">")
+		}
+	}
+	who := "Amministratori"
+	if len(mentions) > 0 {
+		who = strings.Join(mentions, " ")
+	}
+	return who + ", non c'è ancora il menù di oggi, è ora di chiederlo al ristorante:\n> " + r.Text(now)
+}
+
+// parseEmail returns the address of an email as formatted by Slack, e.g.
+// <mailto:a@b.it|a@b.it>
+func parseEmail(s string) string {
+	s = strings.TrimPrefix(strings.TrimSuffix(s, ">"), "<mailto:")
+	return strings.SplitN(s, "|", 2)[0]
+}
+
+// MenuRequestCmd shows or changes the daily request of the menu of the
+// channel
+func (t *TinaBot) MenuRequestCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	repo := menuRequestRepo(t.brain)
+	r, err := repo.Get(msg.Channel)
+	enabled := err == nil
+
+	f := strings.Fields(args[1])
+	if len(f) == 0 {
+		if !enabled {
+			t.bot.Message(msg.Channel, "Nessuna richiesta del menù impostata in questo canale, attivala con `richiesta menu ore <hh:mm>`")
+			return
+		}
+		t.bot.Message(msg.Channel, r.String())
+		return
+	}
+	if isDirect(msg) {
+		t.bot.Message(msg.Channel, "La richiesta del menù si imposta nel canale del pranzo, non in privato")
+		return
+	}
+	if !t.canAdmin(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono impostare la richiesta del menù")
+		return
+	}
+
+	value := strings.Join(f[1:], " ")
+	switch strings.ToLower(f[0]) {
+	case "off":
+		repo.Delete(msg.Channel)
+		t.bot.Message(msg.Channel, "Ok, richiesta del menù disattivata")
+		return
+	case "ore":
+		if _, err := time.Parse("15:04", value); err != nil {
+			t.bot.Message(msg.Channel, "Orario non valido, usa il formato hh:mm")
+			return
+		}
+		r.Time = value
+	case "email":
+		if strings.ToLower(value) == "off" {
+			r.Email = ""
+		} else if r.Email = parseEmail(value); !strings.Contains(r.Email, "@") {
+			t.bot.Message(msg.Channel, "Indirizzo email non valido")
+			return
+		}
+	case "testo":
+		if strings.ToLower(value) == "off" {
+			value = ""
+		}
+		r.Template = value
+	default:
+		t.bot.Message(msg.Channel, "Comando non valido, usa `richiesta menu ore <hh:mm>`, `richiesta menu email <indirizzo>|off`, `richiesta menu testo <testo>|off` o `richiesta menu off`")
+		return
+	}
+	if r.Time == "" {
+		t.bot.Message(msg.Channel, "Imposta prima l'orario della richiesta con `richiesta menu ore <hh:mm>`")
+		return
+	}
+	if err := repo.Put(msg.Channel, r); err != nil {
+		t.bot.Message(msg.Channel, "Errore nel salvare la richiesta del menù: "+err.Error())
+		return
+	}
+	t.bot.Message(msg.Channel, "Ok\n"+r.String())
+}
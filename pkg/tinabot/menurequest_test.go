@@ -0,0 +1,61 @@
+package tinabot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestMenuRequestDue(t *testing.T) {
+	r := MenuRequest{Time: "09:30"}
+	friday := time.Date(2019, 3, 15, 9, 32, 0, 0, time.UTC)
+	interval := 10 * time.Minute
+
+	assertEqual(t, r.Due(friday, interval), true, "")
+	assertEqual(t, r.Due(friday.Add(time.Hour), interval), false, "")
+	assertEqual(t, r.Due(friday.AddDate(0, 0, 1), interval), false, "")
+	assertEqual(t, MenuRequest{}.Due(friday, interval), false, "")
+
+	assertEqual(t, r.Text(friday), "Buongiorno, potete mandarci il menù di oggi 15/03/2019? Grazie!", "")
+	r.Template = "Menù di $DATE?"
+	assertEqual(t, r.Text(friday), "Menù di oggi 15/03/2019?", "")
+}
+
+func TestDueMenuRequests(t *testing.T) {
+	b := brain.NewBrainMock()
+	friday := time.Date(2019, 3, 15, 9, 32, 0, 0, time.UTC)
+	interval := 10 * time.Minute
+
+	menuRequestRepo(b).Put("C1", MenuRequest{Time: "09:30", Email: "menu@example.com"})
+	menuRequestRepo(b).Put("C2", MenuRequest{Time: "11:00"})
+	due := DueMenuRequests(b, friday, interval)
+	assertEqual(t, len(due), 1, "")
+	assertEqual(t, due["C1"].Email, "menu@example.com", "")
+
+	var c Calendar
+	c.Close(friday, "festa")
+	c.Save(b)
+	assertEqual(t, len(DueMenuRequests(b, friday, interval)), 0, "")
+	c.Open(friday)
+	c.Save(b)
+	assertEqual(t, len(DueMenuRequests(b, friday, interval)), 1, "")
+
+	b.Set("menu", tuttobene.Menu{Date: friday})
+	assertEqual(t, len(DueMenuRequests(b, friday, interval)), 0, "")
+}
+
+func TestMenuRequestReminder(t *testing.T) {
+	b := brain.NewBrainMock()
+	b.SAdd(adminsPrefix+"C1", "U1")
+	friday := time.Date(2019, 3, 15, 9, 30, 0, 0, time.UTC)
+
+	txt := MenuRequestReminder(b, "C1", MenuRequest{Time: "09:30", Template: "Menù di $DATE?"}, friday)
+	assertEqual(t, txt, "<@U1>, non c'è ancora il menù di oggi, è ora di chiederlo al ristorante:\n> Menù di oggi 15/03/2019?", "")
+}
+
+func TestParseEmail(t *testing.T) {
+	assertEqual(t, parseEmail("<mailto:menu@example.com|menu@example.com>"), "menu@example.com", "")
+	assertEqual(t, parseEmail("menu@example.com"), "menu@example.com", "")
+}
@@ -0,0 +1,19 @@
+package tinabot
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var ordersPlaced = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "orders_placed_total",
+	Help: "Orders placed by source: chat, api, guest, template or late.",
+}, []string{"source"})
+
+func init() {
+	prometheus.MustRegister(ordersPlaced)
+}
+
+// observeOrder counts an order placed from source
+func observeOrder(source string) {
+	ordersPlaced.WithLabelValues(source).Inc()
+}
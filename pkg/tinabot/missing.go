@@ -0,0 +1,119 @@
+package tinabot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// missingOptOutKey is the set of the users, by ID, who don't want to be
+// listed among the ones missing from the order, e.g. never eating at the
+// office
+const missingOptOutKey = "missing:optout"
+
+// missingUsers returns the members who haven't ordered yet, sorted by name:
+// the bots, the deleted users and the ones who opted out are left out
+func missingUsers(members []chat.User, order *Order, optOut []string) []chat.User {
+	skip := make(map[string]bool)
+	for _, id := range optOut {
+		skip[id] = true
+	}
+	for u := range order.Users {
+		skip[u.ID] = true
+	}
+
+	var missing []chat.User
+	for _, u := range members {
+		if u.IsBot || u.Deleted || u.ID == "USLACKBOT" || skip[u.ID] {
+			continue
+		}
+		missing = append(missing, u)
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		return missing[i].Name < missing[j].Name
+	})
+	return missing
+}
+
+// MissingCmd lists the members of the channel who haven't ordered yet,
+// mentioning them with "avvisa"; "escludimi" and "includimi" remove the user
+// from the list or add her back
+func (t *TinaBot) MissingCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	arg := strings.ToLower(strings.TrimSpace(args[1]))
+	switch arg {
+	case "escludimi", "includimi":
+		var err error
+		if arg == "escludimi" {
+			err = t.brain.SAdd(missingOptOutKey, user.ID)
+		} else {
+			err = t.brain.SRem(missingOptOutKey, user.ID)
+		}
+		if err != nil {
+			t.bot.Message(msg.Channel, "Errore nel salvare la preferenza: "+err.Error())
+			return
+		}
+		if arg == "escludimi" {
+			t.bot.Message(msg.Channel, "Ok, non ti elencherò più tra chi non ha ordinato")
+		} else {
+			t.bot.Message(msg.Channel, "Ok, ti elencherò di nuovo tra chi non ha ordinato")
+		}
+		return
+	case "", "avvisa":
+	default:
+		t.bot.Message(msg.Channel, "Comando non valido, usa `mancanti [avvisa|escludimi|includimi]`")
+		return
+	}
+
+	if isDirect(msg) {
+		t.bot.Message(msg.Channel, "Chiedimi chi manca nel canale del pranzo, non in privato")
+		return
+	}
+	ids, err := t.bot.Platform.ChannelMembers(msg.Channel)
+	if err != nil {
+		slackbot.APIError("conversations.members")
+		t.logger().Error("Error getting the channel members", "err", err)
+		t.bot.Message(msg.Channel, "Non riesco a leggere i membri del canale: "+err.Error())
+		return
+	}
+	users, err := t.bot.Platform.Users()
+	if err != nil {
+		slackbot.APIError("users.list")
+		t.logger().Error("Error getting the users", "err", err)
+		t.bot.Message(msg.Channel, "Non riesco a leggere gli utenti: "+err.Error())
+		return
+	}
+	inChannel := make(map[string]bool)
+	for _, id := range ids {
+		inChannel[id] = true
+	}
+	var members []chat.User
+	for _, u := range users {
+		if inChannel[u.ID] {
+			members = append(members, u)
+		}
+	}
+
+	optOut, _ := t.brain.SMembers(missingOptOutKey)
+	missing := missingUsers(members, getOrder(t.brain), optOut)
+	if len(missing) == 0 {
+		t.bot.Message(msg.Channel, "Hanno ordinato tutti!")
+		return
+	}
+
+	var names []string
+	for _, u := range missing {
+		if arg == "avvisa" {
+			names = append(names, "<@"+u.ID+">")
+		} else {
+			names = append(names, u.Name)
+		}
+	}
+	text := fmt.Sprintf("Non hanno ancora ordinato (%d): %s", len(missing), strings.Join(names, ", "))
+	if arg == "avvisa" {
+		text += "\nSe oggi pranzate, ordinate ora per favore!"
+	}
+	t.bot.Message(msg.Channel, text)
+}
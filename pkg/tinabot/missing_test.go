@@ -0,0 +1,28 @@
+package tinabot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/chat"
+)
+
+func TestMissingUsers(t *testing.T) {
+	order := NewOrder()
+	order.Users[User{"mario", "U1"}] = UserChoiceArray{{Note: "lasagne"}}
+
+	members := []chat.User{
+		{ID: "U3", Name: "zeno"},
+		{ID: "U1", Name: "mario"},
+		{ID: "U2", Name: "luigi"},
+		{ID: "U4", Name: "tinabot", IsBot: true},
+		{ID: "U5", Name: "ex", Deleted: true},
+		{ID: "USLACKBOT", Name: "slackbot"},
+		{ID: "U6", Name: "anna"},
+	}
+	var names []string
+	for _, u := range missingUsers(members, order, []string{"U6"}) {
+		names = append(names, u.Name)
+	}
+	assertEqual(t, strings.Join(names, ","), "luigi,zeno", "")
+}
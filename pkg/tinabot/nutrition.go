@@ -0,0 +1,290 @@
+package tinabot
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/analytics"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// Nutrients is a rough estimate of the energy, in kcal, and of the macros,
+// in grams, of a portion
+type Nutrients struct {
+	Kcal    int
+	Protein int
+	Carbs   int
+	Fat     int
+}
+
+// Add returns the sum of the nutrients
+func (n Nutrients) Add(o Nutrients) Nutrients {
+	return Nutrients{n.Kcal + o.Kcal, n.Protein + o.Protein, n.Carbs + o.Carbs, n.Fat + o.Fat}
+}
+
+// Div returns the nutrients divided by count, e.g. to average them
+func (n Nutrients) Div(count int) Nutrients {
+	if count == 0 {
+		return Nutrients{}
+	}
+	return Nutrients{n.Kcal / count, n.Protein / count, n.Carbs / count, n.Fat / count}
+}
+
+func (n Nutrients) String() string {
+	return fmt.Sprintf("~%d kcal (proteine ~%d g, carboidrati ~%d g, grassi ~%d g)", n.Kcal, n.Protein, n.Carbs, n.Fat)
+}
+
+// nutritionKeywords are the estimates of the dishes whose name contains the
+// keyword, the longest keyword found wins
+var nutritionKeywords = map[string]Nutrients{
+	"pasta": {550, 18, 85, 14}, "penne": {550, 18, 85, 14}, "spaghetti": {550, 18, 85, 14},
+	"tagliatelle": {550, 18, 85, 14}, "fusilli": {550, 18, 85, 14}, "rigatoni": {550, 18, 85, 14},
+	"ravioli": {520, 20, 60, 22}, "tortelli": {520, 20, 60, 22}, "lasagn": {650, 30, 55, 32},
+	"risotto": {500, 12, 80, 14}, "gnocchi": {520, 12, 85, 14}, "farro": {420, 14, 70, 9}, "orzo": {420, 14, 70, 9},
+	"zuppa": {300, 12, 40, 8}, "minestr": {300, 12, 40, 8}, "vellutata": {250, 6, 30, 10},
+	"pollo": {350, 40, 5, 18}, "tacchino": {330, 40, 5, 16}, "manzo": {450, 42, 2, 30}, "vitello": {400, 40, 2, 24},
+	"bistecca": {450, 42, 2, 30}, "tagliata": {450, 42, 2, 30}, "maiale": {500, 32, 3, 40}, "arista": {450, 34, 3, 33},
+	"salsiccia": {500, 24, 3, 43}, "polpett": {420, 28, 12, 28}, "arrosto": {420, 38, 3, 28},
+	"pesce": {300, 35, 2, 15}, "merluzzo": {250, 35, 2, 10}, "salmone": {400, 36, 2, 27}, "orata": {300, 36, 2, 16},
+	"branzino": {300, 36, 2, 16}, "tonno": {350, 38, 2, 20},
+	"frittata": {320, 20, 3, 25}, "uova": {320, 20, 3, 25}, "mozzarella": {350, 20, 5, 28}, "caprese": {380, 20, 6, 30},
+	"fagioli": {250, 14, 35, 5}, "ceci": {260, 14, 38, 6}, "lenticchie": {250, 16, 35, 4},
+	"insalata": {80, 2, 8, 5}, "patate": {250, 4, 35, 10}, "verdure": {120, 4, 12, 6}, "spinaci": {100, 5, 6, 7},
+	"zucchine": {100, 3, 8, 7}, "fagiolini": {100, 3, 10, 6},
+	"frutta": {90, 1, 22, 0}, "macedonia": {100, 1, 24, 0}, "mela": {80, 0, 20, 0},
+	"torta": {380, 6, 45, 20}, "crostata": {400, 6, 55, 18}, "tiramis": {450, 8, 45, 26},
+	"panino": {450, 20, 50, 18},
+}
+
+// nutritionDefaults are the estimates of the dishes with no keyword, by type
+var nutritionDefaults = map[tuttobene.MenuRowType]Nutrients{
+	tuttobene.Primo:       {500, 16, 75, 14},
+	tuttobene.Secondo:     {400, 32, 8, 25},
+	tuttobene.Contorno:    {120, 3, 12, 7},
+	tuttobene.Vegetariano: {400, 16, 45, 18},
+	tuttobene.Frutta:      {90, 1, 22, 0},
+	tuttobene.Dolce:       {350, 5, 45, 17},
+	tuttobene.Panino:      {450, 20, 50, 18},
+}
+
+// NutritionTable are the estimates set by the admins, by keyword, which
+// override the built-in ones
+type NutritionTable map[string]Nutrients
+
+// LoadNutritionTable loads the estimates of the admins from brain
+func LoadNutritionTable(brain DataStore) NutritionTable {
+	var t NutritionTable
+	brain.Get("nutrition:table", &t)
+	return t
+}
+
+// Save saves the estimates to brain
+func (t NutritionTable) Save(brain DataStore) error {
+	return brain.Set("nutrition:table", t)
+}
+
+// longestKeyword returns the estimate of the longest keyword of table in name
+func longestKeyword(table map[string]Nutrients, name string) (Nutrients, bool) {
+	best := ""
+	for k := range table {
+		if strings.Contains(name, k) && len(k) > len(best) {
+			best = k
+		}
+	}
+	return table[best], best != ""
+}
+
+// Dish returns the estimate of a portion of the dish: the one of the admins,
+// the built-in one or the one of its type
+func (t NutritionTable) Dish(dish tuttobene.MenuRow) Nutrients {
+	name := tuttobene.CanonicalName(dish.Content)
+	if n, ok := longestKeyword(t, name); ok {
+		return n
+	}
+	if n, ok := longestKeyword(nutritionKeywords, name); ok {
+		return n
+	}
+	return nutritionDefaults[dish.Type]
+}
+
+// Lunch returns the estimate of the choices of a user, the alternatives not
+// included
+func (t NutritionTable) Lunch(choices UserChoiceArray) Nutrients {
+	var n Nutrients
+	for _, c := range choices {
+		for _, d := range c.Dishes {
+			n = n.Add(t.Dish(d))
+		}
+	}
+	return n
+}
+
+// NutritionUser is a user who asked for the weekly estimates
+type NutritionUser struct {
+	// the Monday of the last week sent
+	Sent string `json:",omitempty"`
+}
+
+// Due returns the previous week if its digest is to be sent at now
+func (u *NutritionUser) Due(now time.Time) (analytics.Period, bool) {
+	return weeklyDue(now, u.Sent)
+}
+
+// NutritionWeek returns the average estimate of the lunches of user in the
+// week, with how many they were
+func NutritionWeek(brain DataStore, user User, week analytics.Period) (Nutrients, int) {
+	table := LoadNutritionTable(brain)
+	var total Nutrients
+	n := 0
+	for d := week.Start; d.Before(week.End); d = d.AddDate(0, 0, 1) {
+		choices, err := LoadHistory(brain, user, d)
+		if err != nil || len(choices) == 0 {
+			continue
+		}
+		total = total.Add(table.Lunch(choices))
+		n++
+	}
+	return total.Div(n), n
+}
+
+// NutritionDigest returns the message with the estimates of the week for
+// user, false if they had no lunch
+func NutritionDigest(brain DataStore, user User, week analytics.Period) (string, bool) {
+	avg, n := NutritionWeek(brain, user, week)
+	if n == 0 {
+		return "", false
+	}
+	return fmt.Sprintf("Nella settimana dal %s al %s hai ordinato %s, in media %s a pranzo.\n_Sono stime indicative in base ai nomi dei piatti. Scrivimi `nutrizione off` per non ricevere più questo messaggio._",
+		week.Start.Format("02/01"), week.End.AddDate(0, 0, -1).Format("02/01"), count(n, "pranzo", "pranzi"), avg), true
+}
+
+// SendNutritionDigests sends with send the estimates of the previous week to
+// the users who asked for them, once the week is over, and records them sent.
+// The users send fails for are tried again the next time.
+func SendNutritionDigests(brain DataStore, now time.Time, send func(userID, text string) error) error {
+	users := nutritionRepo(brain)
+	all, err := users.All()
+	if err != nil {
+		return err
+	}
+	for id, u := range all {
+		week, due := u.Due(now)
+		if !due {
+			continue
+		}
+		if text, ok := NutritionDigest(brain, User{ID: id}, week); ok {
+			if err := send(id, text); err != nil {
+				slog.Error("Error sending the nutrition estimates", "user", id, "err", err)
+				continue
+			}
+		}
+		u.Sent = dayKey(week.Start)
+		if err := users.Put(id, u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NutritionCmd turns on and off the weekly estimates of the user, shows the
+// estimates of today's order and of the week so far, or lets the admins
+// change the estimates of the dishes
+func (t *TinaBot) NutritionCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	u := User{user.Name, user.ID}
+	users := nutritionRepo(t.brain)
+	f := strings.Fields(strings.ToLower(args[1]))
+	if len(f) == 0 {
+		table := LoadNutritionTable(t.brain)
+		order := getOrder(t.brain)
+		var reply []string
+		if choices := order.Users[order.key(u)]; order.IsUpdated() && len(choices) > 0 {
+			reply = append(reply, "Il tuo ordine di oggi: "+table.Lunch(choices).String())
+		}
+		if avg, n := NutritionWeek(t.brain, u, analytics.Week(time.Now())); n > 0 {
+			reply = append(reply, fmt.Sprintf("Questa settimana, in %s: in media %s", count(n, "pranzo", "pranzi"), avg))
+		}
+		if _, err := users.Get(userKey(u)); err == nil {
+			reply = append(reply, "Ricevi le stime della settimana ogni lunedì, `nutrizione off` per smettere")
+		} else {
+			reply = append(reply, "Scrivi `nutrizione on` per ricevere le stime della settimana ogni lunedì")
+		}
+		t.bot.Reply(msg, slackbot.Ephemeral, strings.Join(reply, "\n"))
+		return
+	}
+
+	switch f[0] {
+	case "on":
+		// keep the week sent, if already on
+		nu, _ := users.Get(userKey(u))
+		if err := users.Put(userKey(u), nu); err != nil {
+			t.bot.Reply(msg, slackbot.Ephemeral, "Errore: "+err.Error())
+			return
+		}
+		t.bot.Reply(msg, slackbot.Ephemeral, "Ok, ogni lunedì ti manderò le stime dei pranzi della settimana precedente")
+	case "off":
+		if err := users.Delete(userKey(u)); err != nil {
+			t.bot.Reply(msg, slackbot.Ephemeral, "Errore: "+err.Error())
+			return
+		}
+		t.bot.Reply(msg, slackbot.Ephemeral, "Ok, non ti manderò più le stime dei pranzi")
+	case "valori", "togli":
+		t.nutritionTableCmd(msg, user, f)
+	default:
+		t.bot.Reply(msg, slackbot.Ephemeral, "Comando non valido, vedi `aiuto nutrizione`")
+	}
+}
+
+// nutritionTableCmd sets or removes the estimate of the dishes with a
+// keyword, or lists the ones set
+func (t *TinaBot) nutritionTableCmd(msg *slackbot.BotMsg, user *chat.User, f []string) {
+	if !t.canAdmin(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono cambiare le stime dei piatti")
+		return
+	}
+	table := LoadNutritionTable(t.brain)
+	if table == nil {
+		table = make(NutritionTable)
+	}
+
+	switch {
+	case f[0] == "valori" && len(f) == 1:
+		if len(table) == 0 {
+			t.bot.Message(msg.Channel, "Nessuna stima impostata, uso quelle predefinite")
+			return
+		}
+		var lines []string
+		for k, n := range table {
+			lines = append(lines, k+": "+n.String())
+		}
+		sort.Strings(lines)
+		t.bot.Message(msg.Channel, strings.Join(lines, "\n"))
+		return
+	case f[0] == "valori" && len(f) == 6:
+		var v [4]int
+		for i := range v {
+			n, err := strconv.Atoi(f[i+2])
+			if err != nil || n < 0 {
+				t.bot.Message(msg.Channel, fmt.Sprintf("Valore '%s' non valido", f[i+2]))
+				return
+			}
+			v[i] = n
+		}
+		table[tuttobene.CanonicalName(f[1])] = Nutrients{v[0], v[1], v[2], v[3]}
+	case f[0] == "togli" && len(f) == 2:
+		delete(table, tuttobene.CanonicalName(f[1]))
+	default:
+		t.bot.Message(msg.Channel, "Comando non valido, usa `nutrizione valori <parola> <kcal> <proteine> <carboidrati> <grassi>` o `nutrizione togli <parola>`")
+		return
+	}
+	if err := table.Save(t.brain); err != nil {
+		t.bot.Message(msg.Channel, "Errore: "+err.Error())
+		return
+	}
+	t.bot.Message(msg.Channel, "Ok")
+}
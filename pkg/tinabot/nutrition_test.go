@@ -0,0 +1,61 @@
+package tinabot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/analytics"
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestNutritionTable(t *testing.T) {
+	var table NutritionTable
+	pasta := tuttobene.MenuRow{Content: "Pasta al pomodoro", Type: tuttobene.Primo}
+	assertEqual(t, table.Dish(pasta), Nutrients{550, 18, 85, 14}, "")
+	// the longest keyword wins
+	assertEqual(t, table.Dish(tuttobene.MenuRow{Content: "Insalata di pollo", Type: tuttobene.Secondo}), Nutrients{80, 2, 8, 5}, "")
+	assertEqual(t, table.Dish(tuttobene.MenuRow{Content: "Sformato della casa", Type: tuttobene.Secondo}), nutritionDefaults[tuttobene.Secondo], "")
+
+	table = NutritionTable{"pomodoro": {400, 10, 70, 8}}
+	assertEqual(t, table.Dish(pasta), Nutrients{400, 10, 70, 8}, "")
+
+	var secondo UserChoice
+	secondo.Add(tuttobene.MenuRow{Content: "Pollo arrosto", Type: tuttobene.Secondo})
+	secondo.Add(tuttobene.MenuRow{Content: "Patate al forno", Type: tuttobene.Contorno})
+	assertEqual(t, table.Lunch(UserChoiceArray{secondo}), Nutrients{670, 42, 38, 38}, "")
+	assertEqual(t, Nutrients{670, 42, 38, 38}.Div(2).String(), "~335 kcal (proteine ~21 g, carboidrati ~19 g, grassi ~19 g)", "")
+}
+
+func TestNutritionDigests(t *testing.T) {
+	b := brain.NewBrainMock()
+	monday := time.Date(2019, 3, 18, 10, 0, 0, 0, time.UTC)
+	for i, dish := range []string{"Pasta al pomodoro", "Risotto ai funghi"} {
+		var c UserChoice
+		c.Add(tuttobene.MenuRow{Content: dish, Type: tuttobene.Primo})
+		order := NewOrder()
+		order.Timestamp = monday.AddDate(0, 0, -7+i)
+		order.Set(User{"mario", "U1"}, []UserChoice{c})
+		order.Set(User{"luigi", "U2"}, []UserChoice{c})
+		assertEqual(t, SaveHistory(b, order), nil, "")
+	}
+
+	avg, n := NutritionWeek(b, User{"mario", "U1"}, analytics.Week(monday.AddDate(0, 0, -7)))
+	assertEqual(t, n, 2, "")
+	assertEqual(t, avg.Kcal, 525, "")
+
+	assertEqual(t, nutritionRepo(b).Put("U1", NutritionUser{}), nil, "")
+	sent := make(map[string]string)
+	send := func(id, text string) error {
+		sent[id] = text
+		return nil
+	}
+	assertEqual(t, SendNutritionDigests(b, monday, send), nil, "")
+	assertEqual(t, len(sent), 1, "only the users who asked")
+	assertEqual(t, strings.HasPrefix(sent["U1"], "Nella settimana dal 11/03 al 17/03 hai ordinato 2 pranzi, in media ~525 kcal"), true, sent["U1"])
+
+	sent = make(map[string]string)
+	assertEqual(t, SendNutritionDigests(b, monday.Add(time.Hour), send), nil, "")
+	assertEqual(t, len(sent), 0, "already sent")
+}
@@ -0,0 +1,196 @@
+package tinabot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// An office is a channel with its own menu, order, settings and admins,
+// kept in the namespace "office:<team>:<channel>" of the brain, so that the
+// same bot serves several offices. The channels of the workspace configured
+// by hand that are not an office, and their users, share the keys outside of
+// the namespaces, as before offices existed. The other channels of the
+// workspaces installing the bot through OAuth are kept in the namespace
+// "team:<team>", so that they never see the state of another company.
+
+// officesKey is the set of the offices, by "<team>:<channel>"
+const officesKey = "offices"
+
+// officeUserRepo keeps the office of each user by "<team>:<user>", where
+// her direct messages to the bot belong
+func officeUserRepo(b DataStore) brain.Repo[string] {
+	return brain.NewRepo[string](b, "offices:user:")
+}
+
+// officeID returns the ID of the office of channel in team
+func officeID(team, channel string) string {
+	return team + ":" + channel
+}
+
+// OfficeNamespace returns the brain namespace of the office, e.g. to run the
+// cron tasks of the office with BRAIN_URL "...?namespace=<namespace>"
+func OfficeNamespace(id string) string {
+	return "office:" + id
+}
+
+// TeamNamespace returns the brain namespace of the channels of team which
+// are not an office, for the workspaces installing the bot through OAuth
+func TeamNamespace(team string) string {
+	return "team:" + team
+}
+
+// Offices returns the IDs of the offices
+func Offices(root RoleStore) ([]string, error) {
+	return root.SMembers(officesKey)
+}
+
+// isOffice returns true if id is an office
+func isOffice(root RoleStore, id string) bool {
+	ids, _ := Offices(root)
+	for _, o := range ids {
+		if o == id {
+			return true
+		}
+	}
+	return false
+}
+
+// officeOf returns the office of the messages of user in channel: the
+// channel itself if it's an office, the office of the user in the direct
+// messages, empty if none. Writing in an office sets the office of the user.
+func officeOf(root RoleStore, team, channel, user string) string {
+	if strings.HasPrefix(channel, "D") {
+		id, _ := officeUserRepo(root).Get(officeID(team, user))
+		return id
+	}
+	id := officeID(team, channel)
+	if !isOffice(root, id) {
+		return ""
+	}
+	if user != "" {
+		users := officeUserRepo(root)
+		if old, err := users.Get(officeID(team, user)); err != nil || old != id {
+			users.Put(officeID(team, user), id)
+		}
+	}
+	return id
+}
+
+// Scope returns the part of the root brain holding the state of the office
+// of the messages of user in channel, see officeOf, or the one of the
+// workspace if it installed the bot through OAuth
+func Scope(root brain.Store, team, channel, user string) brain.Store {
+	if id := officeOf(root, team, channel, user); id != "" {
+		return brain.Namespace(root, OfficeNamespace(id))
+	}
+	if slackbot.IsInstalled(root, team) {
+		return brain.Namespace(root, TeamNamespace(team))
+	}
+	return root
+}
+
+// OfficeStore returns the part of the root brain of the office id, false if
+// it's not an office
+func OfficeStore(root brain.Store, id string) (brain.Store, bool) {
+	if !isOffice(root, id) {
+		return nil, false
+	}
+	return brain.Namespace(root, OfficeNamespace(id)), true
+}
+
+// NewScoped returns the bot for the messages of user in channel, working on
+// the state of their office
+func NewScoped(bot *slackbot.Bot, root brain.Store, team, channel, user string) *TinaBot {
+	return newBot(bot, Scope(root, team, channel, user), root, team)
+}
+
+// parseChannel returns the ID of a channel mentioned as <#C123|name>
+func parseChannel(s string) (string, bool) {
+	if !strings.HasPrefix(s, "<#") || !strings.HasSuffix(s, ">") {
+		return "", false
+	}
+	id := strings.TrimSuffix(strings.TrimPrefix(s, "<#"), ">")
+	if i := strings.Index(id, "|"); i >= 0 {
+		id = id[:i]
+	}
+	return id, id != ""
+}
+
+// OfficeCmd shows the office of the channel or of the user, makes the channel
+// an office or not anymore, or chooses the office of the user
+func (t *TinaBot) OfficeCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	f := strings.Fields(args[1])
+	if len(f) == 0 {
+		t.bot.Message(msg.Channel, t.officeString(msg, user))
+		return
+	}
+
+	cmd := strings.ToLower(f[0])
+	switch {
+	case cmd == "scegli" && len(f) == 2:
+		ch, ok := parseChannel(f[1])
+		if !ok {
+			t.bot.Message(msg.Channel, "Indica il canale dell'ufficio, es. `ufficio scegli #pranzo-milano`")
+			return
+		}
+		id := officeID(t.team, ch)
+		if !isOffice(t.root, id) {
+			t.bot.Message(msg.Channel, fmt.Sprintf("<#%s> non è un ufficio", ch))
+			return
+		}
+		if err := officeUserRepo(t.root).Put(officeID(t.team, user.ID), id); err != nil {
+			t.bot.Message(msg.Channel, "Errore nel salvare l'ufficio: "+err.Error())
+			return
+		}
+		t.bot.Message(msg.Channel, fmt.Sprintf("Ok, in privato ora ordini per l'ufficio di <#%s>", ch))
+
+	case (cmd == "crea" || cmd == "elimina") && len(f) == 1:
+		if isDirect(msg) {
+			t.bot.Message(msg.Channel, "Gli uffici si creano nel loro canale, non in privato")
+			return
+		}
+		if !t.canAdmin(msg, user) {
+			t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono gestire gli uffici")
+			return
+		}
+		id := officeID(t.team, msg.Channel)
+		var err error
+		if cmd == "crea" {
+			err = t.root.SAdd(officesKey, id)
+		} else {
+			err = t.root.SRem(officesKey, id)
+		}
+		if err != nil {
+			t.bot.Message(msg.Channel, "Errore nel salvare gli uffici: "+err.Error())
+			return
+		}
+		t.logger().Info("Office changed", "office", id, "cmd", cmd, "by", user.Name)
+		if cmd == "crea" {
+			t.bot.Message(msg.Channel, "Ok, questo canale ora è un ufficio, con menù, ordine, impostazioni e amministratori suoi")
+		} else {
+			t.bot.Message(msg.Channel, "Ok, questo canale non è più un ufficio, i suoi dati sono conservati se dovesse tornarlo")
+		}
+
+	default:
+		t.bot.Message(msg.Channel, "Comando non valido, usa `ufficio crea`, `ufficio elimina` o `ufficio scegli #canale`")
+	}
+}
+
+// officeString describes the office of the channel of msg
+func (t *TinaBot) officeString(msg *slackbot.BotMsg, user *chat.User) string {
+	if isDirect(msg) {
+		id, err := officeUserRepo(t.root).Get(officeID(t.team, user.ID))
+		if err != nil {
+			return "In privato ordini per l'ufficio principale, scegline un altro con `ufficio scegli #canale`"
+		}
+		return fmt.Sprintf("In privato ordini per l'ufficio di <#%s>", strings.TrimPrefix(id, t.team+":"))
+	}
+	if isOffice(t.root, officeID(t.team, msg.Channel)) {
+		return "Questo canale è un ufficio, con menù, ordine, impostazioni e amministratori suoi"
+	}
+	return "Questo canale usa l'ufficio principale, per renderlo un ufficio usa `ufficio crea`"
+}
@@ -0,0 +1,54 @@
+package tinabot
+
+import (
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/brain"
+)
+
+func TestOffices(t *testing.T) {
+	b := brain.NewBrainMock()
+
+	// without offices everything shares the root brain
+	assertEqual(t, officeOf(b, "T1", "C1", "U1"), "", "")
+	assertEqual(t, Scope(b, "T1", "C1", "U1") == b, true, "")
+
+	b.SAdd(officesKey, officeID("T1", "C1"))
+	assertEqual(t, officeOf(b, "T1", "C1", "U1"), "T1:C1", "")
+	assertEqual(t, officeOf(b, "T1", "C2", "U1"), "", "")
+	assertEqual(t, officeOf(b, "T2", "C1", "U1"), "", "")
+
+	// the direct messages belong to the last office the user wrote in
+	assertEqual(t, officeOf(b, "T1", "D1", "U1"), "T1:C1", "")
+	assertEqual(t, officeOf(b, "T1", "D2", "U2"), "", "")
+	assertEqual(t, officeOf(b, "T2", "D1", "U1"), "", "")
+
+	// the state of an office is kept apart
+	Scope(b, "T1", "C1", "U1").Set(restaurantKey, RestaurantInfo{DeliveryTime: "12:30"})
+	assertEqual(t, LoadRestaurantInfo(Scope(b, "T1", "D1", "U1")).DeliveryTime, "12:30", "")
+	setConfig(t, "DELIVERY_TIME", "13:00")
+	assertEqual(t, LoadRestaurantInfo(b).DeliveryTime, "13:00", "")
+	assertEqual(t, LoadRestaurantInfo(Scope(b, "T1", "C2", "U1")).DeliveryTime, "13:00", "")
+
+	// the workspaces installed through OAuth never share the root brain
+	b.Set("slack:install:T3", "sealed")
+	Scope(b, "T3", "C1", "U1").Set("order", "altro ordine")
+	_, err := b.Read("order")
+	assertEqual(t, err, brain.ErrNotFound, "")
+	raw, _ := b.Read("team:T3:order")
+	assertEqual(t, raw, `"altro ordine"`, "")
+	assertEqual(t, Scope(b, "T1", "C9", "U1") == b, true, "")
+}
+
+func TestParseChannel(t *testing.T) {
+	for s, want := range map[string]string{
+		"<#C123|pranzo>": "C123",
+		"<#C123>":        "C123",
+		"#pranzo":        "",
+		"<#>":            "",
+	} {
+		id, ok := parseChannel(s)
+		assertEqual(t, id, want, s)
+		assertEqual(t, ok, want != "", s)
+	}
+}
@@ -3,17 +3,36 @@ package tinabot
 import (
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/shopspring/decimal"
+
+	"github.com/develersrl/lunches/pkg/brain"
 )
 
 type DataStore interface {
-	Set(string, interface{}) error
-	Get(string, interface{}) error
+	brain.KV
+}
+
+// CASStore is a DataStore able to atomically read-modify-write a key
+type CASStore interface {
+	DataStore
+	Update(key string, q interface{}, fn func() error) error
+}
+
+// BatchStore is a DataStore able to apply several writes atomically
+type BatchStore interface {
+	DataStore
+	Batch() *brain.Batch
+}
+
+// ExpiringStore is a DataStore able to expire keys
+type ExpiringStore interface {
+	DataStore
+	ExpireAt(key string, at time.Time) error
 }
 
 // User data
@@ -44,16 +63,32 @@ func (u *User) UnmarshalText(text []byte) error {
 
 // Order is a structure holding Tinabot orders
 type Order struct {
+	Version   int // incremented on each SaveCAS
+	State     OrderState
 	Timestamp time.Time
 	Dishes    map[string][]User        //map dishes with users
 	Users     map[User]UserChoiceArray //map each user to his/her dishes
+	OrderedBy map[User]User            //map each user to who ordered for her, if someone else
+	Groups    map[User]string          `json:",omitempty"` //map each user to her delivery group, if any
+	Aliases   map[string]string        `json:",omitempty"` //map the user IDs to the short names shown, see SaveCAS
+
+	Unavailable []string // dishes sold out today
+
+	First *User `json:",omitempty"` // the first who ordered today
+}
+
+// Substitution is a choice changed because of a sold out dish
+type Substitution struct {
+	User User
+	Old  string
+	New  string // empty if no alternative was available and the choice was removed
 }
 
 // NewOrder returns a new empty order
 func NewOrder() *Order {
 	loc, err := time.LoadLocation("Europe/Rome")
 	if err != nil {
-		log.Println("LoadLocation error: ", err)
+		slog.Error("LoadLocation error", "err", err)
 		return nil
 	}
 
@@ -61,31 +96,132 @@ func NewOrder() *Order {
 		Timestamp: time.Now().In(loc),
 		Dishes:    make(map[string][]User),
 		Users:     make(map[User]UserChoiceArray),
+		OrderedBy: make(map[User]User),
+	}
+}
+
+// key returns the user in the order with the same ID of user, whatever her
+// name was when she ordered, or user itself
+func (order *Order) key(user User) User {
+	if user.ID == "" {
+		return user
 	}
+	for u := range order.Users {
+		if u.ID == user.ID {
+			return u
+		}
+	}
+	for u, by := range order.OrderedBy {
+		if u.ID == user.ID {
+			return u
+		}
+		if by.ID == user.ID {
+			return by
+		}
+	}
+	return user
+}
+
+// rename replaces the user with the same ID of user with user, so that a
+// user renamed on Slack has a single entry in the order, with her new name
+func (order *Order) rename(user User) {
+	old := order.key(user)
+	if old == user {
+		return
+	}
+	if choices, ok := order.Users[old]; ok {
+		delete(order.Users, old)
+		order.Users[user] = append(order.Users[user], choices...)
+	}
+	for d, users := range order.Dishes {
+		for i, u := range users {
+			if u == old {
+				order.Dishes[d][i] = user
+			}
+		}
+	}
+	for u, by := range order.OrderedBy {
+		if by == old {
+			order.OrderedBy[u] = user
+		}
+	}
+	if by, ok := order.OrderedBy[old]; ok {
+		delete(order.OrderedBy, old)
+		order.OrderedBy[user] = by
+	}
+	if g, ok := order.Groups[old]; ok {
+		delete(order.Groups, old)
+		order.Groups[user] = g
+	}
+}
+
+// Name returns the name shown for user, her short name if she set one
+func (order *Order) Name(user User) string {
+	if a, ok := order.Aliases[user.ID]; ok && user.ID != "" {
+		return a
+	}
+	return user.Name
+}
+
+// Ordered tells if user has something in the order
+func (order *Order) Ordered(user User) bool {
+	_, ok := order.Users[order.key(user)]
+	return ok
 }
 
 // ClearUser clear the user order, returns the cleared dishes, if any
 func (order *Order) ClearUser(user User) string {
+	order.rename(user)
 	var deleted []string
 
 	for _, d := range order.sorted() {
-		users := order.Dishes[d]
-		for i, u := range users {
+		// the same dish can be ordered more than once by the same user
+		var users []User
+		for _, u := range order.Dishes[d] {
 			if u == user {
 				deleted = append(deleted, d)
-				order.Dishes[d] = append(order.Dishes[d][:i], order.Dishes[d][i+1:]...)
-				break
+			} else {
+				users = append(users, u)
 			}
 		}
+		order.Dishes[d] = users
 		if len(order.Dishes[d]) == 0 {
 			delete(order.Dishes, d)
 		}
 	}
 
 	delete(order.Users, user)
+	delete(order.OrderedBy, user)
 	return strings.Join(deleted, "\n")
 }
 
+// RemoveItem removes the i-th choice of user, returns the removed dish
+func (order *Order) RemoveItem(user User, i int) (string, error) {
+	order.rename(user)
+	choices := order.Users[user]
+	if i < 0 || i >= len(choices) {
+		return "", fmt.Errorf("piatto %d inesistente", i+1)
+	}
+
+	d := choices[i].String()
+	users := order.Dishes[d]
+	for j, u := range users {
+		if u == user {
+			order.Dishes[d] = append(users[:j], users[j+1:]...)
+			break
+		}
+	}
+	if len(order.Dishes[d]) == 0 {
+		delete(order.Dishes, d)
+	}
+
+	order.Users[user] = append(choices[:i], choices[i+1:]...)
+	if len(order.Users[user]) == 0 {
+		delete(order.Users, user)
+	}
+	return d, nil
+}
+
 // sorted return an array of ordered dished sorted by dish type, dishname
 func (order *Order) sorted() []string {
 	// Create a map of ordered string -> rendered string
@@ -128,12 +264,62 @@ func (order *Order) Load(brain DataStore) error {
 // Save saves order to redis brain
 func (order *Order) Save(brain DataStore) error {
 	fmt.Println("save")
+	defer orderLocks.Lock(storeKey(brain, "order"))()
 	return brain.Set("order", *order)
 }
 
+// ClearOrder replaces today's order with an empty one and returns it
+func ClearOrder(brain CASStore) (*Order, error) {
+	var order Order
+	err := order.SaveCAS(brain, func(o *Order) error {
+		version := o.Version
+		*o = *NewOrder()
+		o.Version = version
+		return nil
+	})
+	return &order, err
+}
+
+// ClearOldOrder replaces the order of a previous day with today's one, so
+// that it doesn't outlive the days when no one orders. It returns false if
+// the order is already today's.
+func ClearOldOrder(brain CASStore) (bool, error) {
+	var order Order
+	if err := order.Load(brain); err != nil || order.IsUpdated() {
+		return false, nil
+	}
+	return true, order.SaveCAS(brain, func(o *Order) error {
+		return nil
+	})
+}
+
+// SaveCAS applies fn to the latest stored order and saves it, retrying if
+// someone else saved the order in the meantime, so that no update is lost.
+// An outdated order is replaced by a new one before calling fn. The updates
+// of the same order by this instance are serialized, the concurrent ones of
+// the other instances are retried.
+func (order *Order) SaveCAS(brain CASStore, fn func(*Order) error) error {
+	defer orderLocks.Lock(storeKey(brain, "order"))()
+	return brain.Update("order", order, func() error {
+		if !order.IsUpdated() {
+			slog.Info("Deleting old order")
+			*order = *freshOrder(brain)
+		}
+		if err := fn(order); err != nil {
+			return err
+		}
+		order.Aliases = orderAliases(brain, order)
+		order.Version++
+		return nil
+	})
+}
+
 // Set set the current order for user to her choice, returns a string array of what she ordered
 func (order *Order) Set(user User, choice []UserChoice) []string {
 	order.ClearUser(user)
+	if order.First == nil && len(choice) > 0 {
+		order.First = &user
+	}
 	var list []string
 	for _, c := range choice {
 		order.Dishes[c.String()] = append(order.Dishes[c.String()], user)
@@ -144,16 +330,149 @@ func (order *Order) Set(user User, choice []UserChoice) []string {
 	return list
 }
 
+// MarkSoldOut adds dish to the unavailable ones, once, replacing each
+// choice containing it with its next available alternative
+func (order *Order) MarkSoldOut(dish string) []Substitution {
+	soldOut := false
+	for _, u := range order.Unavailable {
+		soldOut = soldOut || u == dish
+	}
+	if !soldOut {
+		order.Unavailable = append(order.Unavailable, dish)
+	}
+
+	var subs []Substitution
+	for _, u := range order.users() {
+		changed := false
+		var choices []UserChoice
+		for _, c := range order.Users[u] {
+			if !c.Contains(order.Unavailable) {
+				choices = append(choices, c)
+				continue
+			}
+			changed = true
+			s := Substitution{User: u, Old: c.String()}
+			if a, ok := c.Available(order.Unavailable); ok {
+				s.New = a.String()
+				choices = append(choices, a)
+			}
+			subs = append(subs, s)
+		}
+
+		if changed {
+			by, proxied := order.OrderedBy[u]
+			order.Set(u, choices)
+			if proxied && len(choices) > 0 {
+				order.OrderedBy[u] = by
+			}
+		}
+	}
+	return subs
+}
+
+// SetBy sets the order of user placed by someone else, see Set
+func (order *Order) SetBy(by, user User, choice []UserChoice) []string {
+	order.rename(by)
+	if order.First == nil && len(choice) > 0 {
+		order.First = &by
+	}
+	list := order.Set(user, choice)
+	if by != user && len(choice) > 0 {
+		if order.OrderedBy == nil {
+			order.OrderedBy = make(map[User]User)
+		}
+		order.OrderedBy[user] = by
+	}
+	return list
+}
+
+// Payer returns who has to pay for the dishes of user: guests without a
+// slack account are charged to whoever ordered for them
+func (order *Order) Payer(user User) User {
+	if by, ok := order.OrderedBy[user]; ok && user.ID == "" {
+		return by
+	}
+	return user
+}
+
+// Debts returns the amount due by each paying user, see Payer
+func (order *Order) Debts() map[User]decimal.Decimal {
+	debts := make(map[User]decimal.Decimal)
+	for u := range order.Users {
+		p := order.Payer(u)
+		debts[p] = debts[p].Add(order.TotalFor(u))
+	}
+	return debts
+}
+
 func (order *Order) String() string {
 	return order.Format(true, false)
 }
 
-func (order *Order) Bill() string {
-	return order.Format(true, true)
+// Bill returns the order with prices and the amount due by each user
+func (order *Order) Bill(p Policy) string {
+	return order.Format(true, true) + "\n\n*Quota per persona:*\n" + order.Costs(p)
+}
+
+// TotalFor returns how much user has to pay for her dishes
+func (order *Order) TotalFor(user User) decimal.Decimal {
+	return order.Users[user].Price()
+}
+
+// GrandTotal returns the cost of the whole order
+func (order *Order) GrandTotal() decimal.Decimal {
+	total := decimal.Zero
+	for u := range order.Users {
+		total = total.Add(order.TotalFor(u))
+	}
+	return total
 }
 
-// Format convert the order to a string, with or without the user names
+// users returns the users in the order sorted by name
+func (order *Order) users() []User {
+	var users []User
+	for u := range order.Users {
+		users = append(users, u)
+	}
+	sortUsers(users)
+	return users
+}
+
+func sortUsers(users []User) {
+	sort.Slice(users, func(i, j int) bool {
+		return strings.Compare(users[i].Name, users[j].Name) < 0
+	})
+}
+
+// Costs returns the amount due by each user, one per line, split between
+// company and personal part if the policy has a subsidy
+func (order *Order) Costs(p Policy) string {
+	var r []string
+	for _, u := range order.users() {
+		total := order.TotalFor(u)
+		l := fmt.Sprintf("%s: €%s", order.Name(u), total.StringFixed(2))
+		if p.Subsidy.IsPositive() {
+			company, personal := p.Split(total)
+			l += fmt.Sprintf(" (azienda €%s, personale €%s)", company.StringFixed(2), personal.StringFixed(2))
+		}
+		if p.OverBudget(total) {
+			l += " *fuori budget!*"
+		}
+		r = append(r, l)
+	}
+	return strings.Join(r, "\n")
+}
+
+// Format convert the order to a string, with or without the user names.
+// The order is split by delivery group if any user has one.
 func (order *Order) Format(withUserNames, withPrices bool) string {
+	if groups := order.groupNames(); groups != nil {
+		return order.formatGroups(groups, withUserNames, withPrices)
+	}
+	return order.format(withUserNames, withPrices)
+}
+
+func (order *Order) format(withUserNames, withPrices bool) string {
 	var r []string
 	var noPrice []string
 	total := decimal.Zero
@@ -164,7 +483,11 @@ func (order *Order) Format(withUserNames, withPrices bool) string {
 			//gather names
 			var names []string
 			for _, u := range order.Dishes[d] {
-				names = append(names, u.Name)
+				if by, ok := order.OrderedBy[u]; ok {
+					names = append(names, order.Name(u)+" da "+order.Name(by))
+				} else {
+					names = append(names, order.Name(u))
+				}
 			}
 			l += " [" + strings.Join(names, ", ") + "]"
 		}
@@ -211,7 +534,7 @@ func (order *Order) IsUpdated() bool {
 	loc, err := time.LoadLocation("Europe/Rome")
 
 	if err != nil {
-		log.Println("LoadLocation error: ", err)
+		slog.Error("LoadLocation error", "err", err)
 		return false
 	}
 
@@ -1,10 +1,18 @@
 package tinabot
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/shopspring/decimal"
+	"github.com/tealeg/xlsx"
+
 	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/grammar"
+	"github.com/develersrl/lunches/pkg/matcher"
 	"github.com/develersrl/lunches/pkg/tuttobene"
 )
 
@@ -33,7 +41,7 @@ func TestOrder(t *testing.T) {
 	uclist2 := []UserChoice{uc3}
 	order.Set(User{"test", "123"}, uclist)
 	assertEqual(t, order.String(), "1 primo [test]\n1 secondo [test]", "")
-	assertEqual(t, order.Format(false), "1 primo\n1 secondo", "")
+	assertEqual(t, order.Format(false, false), "1 primo\n1 secondo", "")
 	order.Set(User{"test2", "456"}, uclist)
 	assertEqual(t, order.String(), "2 primo [test, test2]\n2 secondo [test, test2]", "")
 	order.Set(User{"test3", "789"}, uclist2)
@@ -48,7 +56,8 @@ func TestOrder(t *testing.T) {
 	b := brain.NewBrainMock()
 	e := order.Save(b)
 	assertEqual(t, e, nil, "")
-	assertEqual(t, len(b), 1, "")
+	keys, _ := b.Keys("*")
+	assertEqual(t, len(keys), 1, "")
 	neworder := NewOrder()
 	e = neworder.Load(b)
 	assertEqual(t, e, nil, "")
@@ -58,3 +67,327 @@ func TestOrder(t *testing.T) {
 	neworder.Timestamp = neworder.Timestamp.Add(24 * time.Hour)
 	assertEqual(t, neworder.IsUpdated(), false, "")
 }
+
+func TestOrderTotals(t *testing.T) {
+	order := NewOrder()
+
+	var p, s UserChoice
+	p.Add(tuttobene.MenuRow{Content: "primo", Type: tuttobene.Primo, Price: decimal.New(55, -1)})
+	s.Add(tuttobene.MenuRow{Content: "secondo", Type: tuttobene.Secondo, Price: decimal.New(7, 0)})
+	s.Add(tuttobene.MenuRow{Content: "contorno", Type: tuttobene.Contorno, Price: decimal.New(3, 0)})
+
+	order.Set(User{"test", "123"}, []UserChoice{p, s})
+	order.Set(User{"atest", "456"}, []UserChoice{p})
+
+	assertEqual(t, order.TotalFor(User{"test", "123"}).String(), "12.5", "")
+	assertEqual(t, order.TotalFor(User{"nobody", ""}).String(), "0", "")
+	assertEqual(t, order.GrandTotal().String(), "18", "")
+	assertEqual(t, order.Costs(Policy{}), "atest: €5.50\ntest: €12.50", "")
+	policy := Policy{Budget: decimal.New(10, 0), Subsidy: decimal.New(7, 0)}
+	assertEqual(t, order.Costs(policy), "atest: €5.50 (azienda €5.50, personale €0.00)\ntest: €12.50 (azienda €7.00, personale €5.50) *fuori budget!*", "")
+}
+
+func TestOrderSaveCAS(t *testing.T) {
+	b := brain.NewBrainMock()
+
+	var p UserChoice
+	p.Add(tuttobene.MenuRow{Content: "primo", Type: tuttobene.Primo})
+
+	var o1, o2 Order
+	e := o1.SaveCAS(b, func(o *Order) error {
+		o.Set(User{"test", "123"}, []UserChoice{p})
+		return nil
+	})
+	assertEqual(t, e, nil, "")
+	e = o2.SaveCAS(b, func(o *Order) error {
+		o.Set(User{"test2", "456"}, []UserChoice{p})
+		return nil
+	})
+	assertEqual(t, e, nil, "")
+	assertEqual(t, o2.Version, 2, "")
+	assertEqual(t, o2.String(), "2 primo [test, test2]", "")
+
+	// an outdated order is discarded
+	o2.Timestamp = o2.Timestamp.Add(-24 * time.Hour)
+	o2.Save(b)
+	e = o1.SaveCAS(b, func(o *Order) error { return nil })
+	assertEqual(t, e, nil, "")
+	assertEqual(t, o1.Version, 1, "")
+	assertEqual(t, len(o1.Users), 0, "")
+
+	// nothing changes while the brain is down
+	down := errors.New("connection refused")
+	b.Fail("update", "order", down)
+	e = o1.SaveCAS(b, func(o *Order) error {
+		o.Set(User{"test", "123"}, []UserChoice{p})
+		return nil
+	})
+	assertEqual(t, e, down, "")
+	b.Recover()
+	var stored Order
+	assertEqual(t, stored.Load(b), nil, "")
+	assertEqual(t, stored.Version, 1, "")
+	assertEqual(t, len(stored.Users), 0, "")
+}
+
+func TestOrderRemoveItem(t *testing.T) {
+	order := NewOrder()
+
+	var p, s UserChoice
+	p.Add(tuttobene.MenuRow{Content: "primo", Type: tuttobene.Primo})
+	s.Add(tuttobene.MenuRow{Content: "secondo", Type: tuttobene.Secondo})
+	user := User{"test", "123"}
+	order.Set(user, []UserChoice{p, s})
+	order.Set(User{"test2", "456"}, []UserChoice{p})
+
+	assertEqual(t, fmt.Sprint(findChoices(order.Users[user], "second")), "[1]", "")
+	assertEqual(t, fmt.Sprint(findChoices(order.Users[user], "1")), "[0]", "")
+	assertEqual(t, len(findChoices(order.Users[user], "3")), 0, "")
+
+	d, e := order.RemoveItem(user, 0)
+	assertEqual(t, e, nil, "")
+	assertEqual(t, d, "primo", "")
+	assertEqual(t, order.String(), "1 primo [test2]\n1 secondo [test]", "")
+
+	_, e = order.RemoveItem(user, 1)
+	assertEqual(t, e != nil, true, "")
+
+	order.RemoveItem(user, 0)
+	_, ok := order.Users[user]
+	assertEqual(t, ok, false, "")
+	assertEqual(t, order.String(), "1 primo [test2]", "")
+}
+
+func TestOrderState(t *testing.T) {
+	order := NewOrder()
+	assertEqual(t, order.State, Open, "")
+	assertEqual(t, order.Editable(), nil, "")
+
+	assertEqual(t, order.Transition(Sent) != nil, true, "")
+	assertEqual(t, order.Transition(Locked), nil, "")
+	assertEqual(t, order.Editable() != nil, true, "")
+	assertEqual(t, order.Transition(Open), nil, "")
+	assertEqual(t, order.Transition(Locked), nil, "")
+	assertEqual(t, order.Transition(Sent), nil, "")
+	assertEqual(t, order.Transition(Open) != nil, true, "")
+	assertEqual(t, order.Transition(Delivered), nil, "")
+	assertEqual(t, order.Transition(Archived), nil, "")
+	assertEqual(t, order.Transition(Open) != nil, true, "")
+
+	s, e := ParseOrderState("Inviato")
+	assertEqual(t, e, nil, "")
+	assertEqual(t, s, Sent, "")
+	_, e = ParseOrderState("perso")
+	assertEqual(t, e != nil, true, "")
+}
+
+func TestOrderSoldOut(t *testing.T) {
+	items, e := grammar.Default().Parse("tagliata, altrimenti pollo Altrimenti uova")
+	assertEqual(t, e, nil, "")
+	assertEqual(t, fmt.Sprint(items[0].Choices), "[tagliata pollo uova]", "")
+
+	menu := tuttobene.Menu{Rows: []tuttobene.MenuRow{
+		{Content: "Tagliata", Type: tuttobene.Secondo},
+		{Content: "Pollo", Type: tuttobene.Secondo},
+		{Content: "Uova", Type: tuttobene.Secondo},
+	}}
+	var c UserChoice
+	for i, alt := range items[0].Choices {
+		a, _, e := matchChoice(matcher.New(), menu, alt)
+		assertEqual(t, e, nil, "")
+		if i == 0 {
+			c = a
+		} else {
+			c.Alternatives = append(c.Alternatives, a)
+		}
+	}
+	var only UserChoice
+	only.Add(menu.Rows[0])
+
+	order := NewOrder()
+	order.SetBy(User{"host", "1"}, User{"guest_a", ""}, []UserChoice{c})
+	order.Set(User{"b", "2"}, []UserChoice{only})
+
+	subs := order.MarkSoldOut("Tagliata")
+	assertEqual(t, len(subs), 2, "")
+	assertEqual(t, subs[0], Substitution{User{"b", "2"}, "Tagliata", ""}, "")
+	assertEqual(t, subs[1], Substitution{User{"guest_a", ""}, "Tagliata", "Pollo"}, "")
+	assertEqual(t, order.String(), "1 Pollo [guest_a da host]", "")
+
+	subs = order.MarkSoldOut("Pollo")
+	assertEqual(t, len(subs), 1, "")
+	assertEqual(t, subs[0].New, "Uova", "")
+	assertEqual(t, len(order.Users[User{"guest_a", ""}][0].Alternatives), 0, "")
+
+	_, ok := only.Available(order.Unavailable)
+	assertEqual(t, ok, false, "")
+
+	// marking it again changes nothing
+	subs = order.MarkSoldOut("Pollo")
+	assertEqual(t, len(subs), 0, "")
+	assertEqual(t, len(order.Unavailable), 2, "")
+}
+
+func TestOrderRestaurantEmail(t *testing.T) {
+	order := NewOrder()
+	order.Timestamp = time.Date(2019, 3, 12, 10, 0, 0, 0, time.UTC)
+
+	var p UserChoice
+	p.Add(tuttobene.MenuRow{Content: "primo", Type: tuttobene.Primo})
+	p.Note = "senza sale"
+	order.Set(User{"test", "123"}, []UserChoice{p})
+	order.Set(User{"test2", "456"}, []UserChoice{p})
+
+	subj, body := order.RestaurantEmail(RestaurantInfo{"12:45", "Via Mugellese 1/A"}, false, false)
+	assertEqual(t, subj, "Ordine Develer del giorno 12/03/2019", "")
+	assertEqual(t, body, "2 primo (senza sale)\n\nConsegna alle: 12:45\nIndirizzo: Via Mugellese 1/A", "")
+
+	_, body = order.RestaurantEmail(RestaurantInfo{}, false, false)
+	assertEqual(t, body, "2 primo (senza sale)", "")
+}
+
+func TestOrderExport(t *testing.T) {
+	order := NewOrder()
+
+	var p, s UserChoice
+	p.Add(tuttobene.MenuRow{Content: "primo", Type: tuttobene.Primo, Price: decimal.New(5, 0)})
+	s.Add(tuttobene.MenuRow{Content: "secondo", Type: tuttobene.Secondo, Price: decimal.New(75, -1)})
+	order.Set(User{"test", "123"}, []UserChoice{p, s})
+	order.SetBy(User{"test", "123"}, User{"guest_a", ""}, []UserChoice{p})
+
+	var buf bytes.Buffer
+	assertEqual(t, order.ExportCSV(&buf), nil, "")
+	assertEqual(t, buf.String(), "Utente,Ordinato da,Piatto,Prezzo\n"+
+		"guest_a,test,primo,5.00\n"+
+		"test,,primo,5.00\n"+
+		"test,,secondo,7.50\n"+
+		"\n"+
+		"Piatto,Quantità,Totale\n"+
+		"primo,2,10.00\n"+
+		"secondo,1,7.50\n", "")
+
+	buf.Reset()
+	assertEqual(t, order.ExportXLSX(&buf), nil, "")
+	f, e := xlsx.OpenBinary(buf.Bytes())
+	assertEqual(t, e, nil, "")
+	assertEqual(t, len(f.Sheets), 2, "")
+	assertEqual(t, f.Sheets[1].Cell(1, 0).Value, "primo", "")
+	assertEqual(t, f.Sheets[1].Cell(1, 1).Value, "2", "")
+}
+
+func TestOrderGroups(t *testing.T) {
+	order := NewOrder()
+
+	var p, s UserChoice
+	p.Add(tuttobene.MenuRow{Content: "primo", Type: tuttobene.Primo, Price: decimal.New(5, 0)})
+	s.Add(tuttobene.MenuRow{Content: "secondo", Type: tuttobene.Secondo, Price: decimal.New(7, 0)})
+	order.Set(User{"a", "1"}, []UserChoice{p})
+	order.Set(User{"b", "2"}, []UserChoice{p, s})
+	order.SetBy(User{"b", "2"}, User{"guest_c", ""}, []UserChoice{s})
+	assertEqual(t, order.Format(false, false), "2 primo\n2 secondo", "")
+
+	order.SetGroup(User{"a", "1"}, "ufficio 2")
+	order.SetGroup(User{"b", "2"}, "ufficio 1")
+	assertEqual(t, order.Format(true, false),
+		"*ufficio 1:*\n1 primo [b]\n1 secondo [b]\n\n"+
+			"*ufficio 2:*\n1 primo [a]\n\n"+
+			"*Senza gruppo:*\n1 secondo [guest_c da b]", "")
+	assertEqual(t, order.Format(false, true),
+		"*ufficio 1:*\n1 primo -> €5\n1 secondo -> €7\n*Prezzo TOTALE: €12*\n\n"+
+			"*ufficio 2:*\n1 primo -> €5\n*Prezzo TOTALE: €5*\n\n"+
+			"*Senza gruppo:*\n1 secondo -> €7\n*Prezzo TOTALE: €7*", "")
+
+	order.SetGroup(User{"a", "1"}, "")
+	order.SetGroup(User{"b", "2"}, "")
+	assertEqual(t, order.Format(false, false), "2 primo\n2 secondo", "")
+}
+
+func TestOrderSnapshot(t *testing.T) {
+	b := brain.NewBrainMock()
+	order := NewOrder()
+
+	var p UserChoice
+	p.Add(tuttobene.MenuRow{Content: "primo", Type: tuttobene.Primo})
+	order.Set(User{"a", "1"}, []UserChoice{p})
+	order.Set(User{"b", "2"}, []UserChoice{p})
+	assertEqual(t, order.Snapshot(b, "Prima"), nil, "")
+
+	order.ClearUser(User{"a", "1"})
+	order.Version = 3
+	assertEqual(t, order.String(), "1 primo [b]", "")
+
+	assertEqual(t, order.Restore(b, "nessuno") != nil, true, "")
+	assertEqual(t, order.Restore(b, "prima"), nil, "")
+	assertEqual(t, order.String(), "2 primo [a, b]", "")
+	assertEqual(t, order.Version, 3, "")
+
+	old := NewOrder()
+	old.Timestamp = old.Timestamp.AddDate(0, 0, -1)
+	assertEqual(t, old.Snapshot(b, "ieri"), nil, "")
+	assertEqual(t, order.Restore(b, "ieri") != nil, true, "")
+	assertEqual(t, len(loadSnapshots(b)), 1, "")
+}
+
+func TestOrderAdvance(t *testing.T) {
+	loc, _ := time.LoadLocation("Europe/Rome")
+	thu := time.Date(2019, 3, 14, 10, 0, 0, 0, loc) // a thursday
+
+	tests := map[string]string{
+		"domani":     "2019-03-15",
+		"giovedì":    "2019-03-21",
+		"Venerdi":    "2019-03-15",
+		"lunedì":     "2019-03-18",
+		"20/03":      "2019-03-20",
+		"01/01":      "2020-01-01",
+		"dopodomani": "2019-03-16",
+	}
+	for s, want := range tests {
+		date, ok := parseDay(s, thu)
+		assertEqual(t, ok, true, s)
+		assertEqual(t, dayKey(date), want, s)
+	}
+	_, ok := parseDay("mario", thu)
+	assertEqual(t, ok, false, "")
+	date, _ := parseDay("venerdì", thu)
+	assertEqual(t, formatDay(date), "venerdì 15/03", "")
+
+	b := brain.NewBrainMock()
+	tomorrow := NewOrder().Timestamp.AddDate(0, 0, 1)
+	menu := tuttobene.Menu{Date: tomorrow, Rows: []tuttobene.MenuRow{{Content: "primo", Type: tuttobene.Primo}}}
+	assertEqual(t, SaveMenu(b, menu), nil, "")
+	_, err := LoadMenu(b, NewOrder().Timestamp)
+	assertEqual(t, err != nil, true, "")
+	m, err := LoadMenu(b, tomorrow)
+	assertEqual(t, err, nil, "")
+	assertEqual(t, m.Rows[0].Content, "primo", "")
+
+	var p UserChoice
+	p.Add(m.Rows[0])
+	var order Order
+	assertEqual(t, SaveAdvanceCAS(b, NewOrder().Timestamp, &order, func(o *Order) error {
+		o.Set(User{"a", "1"}, []UserChoice{p})
+		return nil
+	}), nil, "")
+	assertEqual(t, len(LoadAdvance(b, tomorrow).Users), 0, "")
+	assertEqual(t, freshOrder(b).String(), "1 primo [a]", "")
+
+	// the menus of the past days expire
+	b.Advance(dayRetention + 48*time.Hour)
+	_, err = LoadMenu(b, tomorrow)
+	assertEqual(t, err != nil, true, "")
+}
+
+func TestClearOrder(t *testing.T) {
+	b := brain.NewBrainMock()
+	var order Order
+	order.SaveCAS(b, func(o *Order) error {
+		o.Set(User{"mario", "U1"}, UserChoiceArray{{Dishes: []tuttobene.MenuRow{{Content: "Pollo", Type: tuttobene.Secondo}}}})
+		return nil
+	})
+
+	cleared, err := ClearOrder(b)
+	assertEqual(t, err, nil, "")
+	assertEqual(t, len(cleared.Users), 0, "")
+	assertEqual(t, cleared.Version, order.Version+1, "")
+	assertEqual(t, len(TodayOrder(b).Users), 0, "")
+}
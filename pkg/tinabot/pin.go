@@ -0,0 +1,93 @@
+package tinabot
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+const (
+	menuPinsKey   = "menu:pins"
+	menuPinHeader = "Menù del giorno"
+)
+
+// MenuPin is a menu message pinned by the bot
+type MenuPin struct {
+	Channel   string
+	Timestamp string
+	Date      time.Time
+}
+
+func isUpdated(date time.Time) bool {
+	m := tuttobene.Menu{Date: date}
+	return m.IsUpdated()
+}
+
+// PinMenu posts the menu on channel and pins it, replacing the menu pinned before
+func PinMenu(platform chat.Platform, brain DataStore, botID, channel string, menu tuttobene.Menu) error {
+	if err := UnpinMenus(platform, brain, botID, channel, false); err != nil {
+		slog.Error("Error removing old pins", "err", err)
+	}
+
+	menu = withRatings(brain, menu)
+	ts, err := platform.SendMessage(channel, "", menuPinHeader+"\n"+menu.String())
+	if err != nil {
+		return err
+	}
+
+	err = platform.Pin(channel, ts)
+	if err != nil {
+		return err
+	}
+
+	var pins []MenuPin
+	brain.Get(menuPinsKey, &pins)
+	pins = append(pins, MenuPin{channel, ts, menu.Date})
+	return brain.Set(menuPinsKey, pins)
+}
+
+// UnpinMenus removes the menus pinned by the bot on channel. If onlyOld is
+// true the pin of today's menu is kept.
+// Pins not tracked in the brain (eg. left by a crashed run) are removed too.
+func UnpinMenus(platform chat.Platform, brain DataStore, botID, channel string, onlyOld bool) error {
+	var pins, kept []MenuPin
+	brain.Get(menuPinsKey, &pins)
+
+	keep := make(map[string]bool)
+	for _, p := range pins {
+		if p.Channel != channel || (onlyOld && isUpdated(p.Date)) {
+			kept = append(kept, p)
+			keep[p.Channel+p.Timestamp] = true
+			continue
+		}
+
+		err := platform.Unpin(p.Channel, p.Timestamp)
+		if err != nil {
+			slackbot.APIError("pins.remove")
+			slog.Error("Error removing pin", "err", err)
+		}
+	}
+
+	pinned, err := platform.Pinned(channel)
+	if err != nil {
+		return err
+	}
+	for _, m := range pinned {
+		if m.User != botID || !strings.HasPrefix(m.Text, menuPinHeader) || keep[channel+m.ID] {
+			continue
+		}
+
+		slog.Info("Removing stale menu pin", "ts", m.ID)
+		err := platform.Unpin(channel, m.ID)
+		if err != nil {
+			slackbot.APIError("pins.remove")
+			slog.Error("Error removing stale pin", "err", err)
+		}
+	}
+
+	return brain.Set(menuPinsKey, kept)
+}
@@ -0,0 +1,126 @@
+package tinabot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// Policy holds the office rules on how much a lunch can cost
+type Policy struct {
+	Budget  decimal.Decimal // daily cap for each user, zero means no cap
+	Subsidy decimal.Decimal // amount paid by the company for each user
+	Block   bool            // refuse the orders over budget instead of warning
+}
+
+// Load loads the policy from brain, no budget and no subsidy if it's not set
+func (p *Policy) Load(brain DataStore) error {
+	if err := brain.Get("policy", p); err != nil {
+		*p = Policy{}
+		return err
+	}
+	return nil
+}
+
+// Save saves the policy to brain
+func (p *Policy) Save(brain DataStore) error {
+	return brain.Set("policy", *p)
+}
+
+// OverBudget returns true if amount exceeds the daily budget
+func (p *Policy) OverBudget(amount decimal.Decimal) bool {
+	return p.Budget.IsPositive() && amount.GreaterThan(p.Budget)
+}
+
+// Split returns the part of amount paid by the company and the one paid by the user
+func (p *Policy) Split(amount decimal.Decimal) (company, personal decimal.Decimal) {
+	company = decimal.Min(amount, p.Subsidy)
+	return company, amount.Sub(company)
+}
+
+func (p *Policy) String() string {
+	var r []string
+	if p.Budget.IsPositive() {
+		mode := "avviso"
+		if p.Block {
+			mode = "blocco"
+		}
+		r = append(r, fmt.Sprintf("Budget giornaliero: €%s (%s)", p.Budget.StringFixed(2), mode))
+	} else {
+		r = append(r, "Nessun budget giornaliero")
+	}
+	if p.Subsidy.IsPositive() {
+		r = append(r, fmt.Sprintf("Contributo aziendale: €%s", p.Subsidy.StringFixed(2)))
+	} else {
+		r = append(r, "Nessun contributo aziendale")
+	}
+	return strings.Join(r, "\n")
+}
+
+func parseAmount(s string) (decimal.Decimal, error) {
+	s = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), "€"))
+	return decimal.NewFromString(strings.Replace(s, ",", ".", 1))
+}
+
+// Budget shows or changes the budget policy
+func (t *TinaBot) Budget(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	var p Policy
+	p.Load(t.brain)
+
+	arg := strings.ToLower(strings.TrimSpace(args[1]))
+	switch arg {
+	case "":
+		t.bot.Message(msg.Channel, p.String())
+		return
+	case "blocca":
+		p.Block = true
+	case "avvisa":
+		p.Block = false
+	case "off":
+		p.Budget = decimal.Zero
+	default:
+		a, err := parseAmount(arg)
+		if err != nil || a.IsNegative() {
+			t.bot.Message(msg.Channel, fmt.Sprintf("Importo '%s' non valido", arg))
+			return
+		}
+		p.Budget = a
+	}
+
+	if err := p.Save(t.brain); err != nil {
+		t.bot.Message(msg.Channel, "Errore: "+err.Error())
+		return
+	}
+	t.bot.Message(msg.Channel, "Ok\n"+p.String())
+}
+
+// Subsidy shows or changes the amount paid by the company
+func (t *TinaBot) Subsidy(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	var p Policy
+	p.Load(t.brain)
+
+	arg := strings.ToLower(strings.TrimSpace(args[1]))
+	if arg == "" {
+		t.bot.Message(msg.Channel, p.String())
+		return
+	}
+	if arg == "off" {
+		arg = "0"
+	}
+	a, err := parseAmount(arg)
+	if err != nil || a.IsNegative() {
+		t.bot.Message(msg.Channel, fmt.Sprintf("Importo '%s' non valido", arg))
+		return
+	}
+	p.Subsidy = a
+
+	if err := p.Save(t.brain); err != nil {
+		t.bot.Message(msg.Channel, "Errore: "+err.Error())
+		return
+	}
+	t.bot.Message(msg.Channel, "Ok\n"+p.String())
+}
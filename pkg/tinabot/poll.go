@@ -0,0 +1,310 @@
+package tinabot
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// actionPollVote is the action id of the buttons of the poll, the value is
+// the index of the option
+const actionPollVote = "poll_vote"
+
+// maxPollOptions is the most restaurants of a poll, two rows of buttons
+const maxPollOptions = 2 * maxActionElements
+
+// sourceKey holds the restaurant chosen for the day, see MenuSource
+const sourceKey = "menu:source"
+
+// MenuSource is the restaurant the menu and the order of the day are from
+type MenuSource struct {
+	Name string
+	Day  string
+}
+
+// ActiveSource returns the restaurant chosen for the day of date, empty if
+// none was chosen
+func ActiveSource(brain DataStore, date time.Time) string {
+	var s MenuSource
+	if brain.Get(sourceKey, &s) != nil || s.Day != dayKey(date) {
+		return ""
+	}
+	return s.Name
+}
+
+// Poll is the vote on the restaurant of the day, started before ordering
+type Poll struct {
+	Day     string
+	Options []string
+	Votes   map[string]int // the option voted by userKey
+	Close   string         `json:",omitempty"` // "15:04" of the automatic close
+	Closed  bool
+
+	// the message of the poll
+	Channel string
+	TS      string
+}
+
+// Load loads the poll from brain
+func (p *Poll) Load(brain DataStore) error {
+	if err := brain.Get("poll", p); err != nil {
+		*p = Poll{}
+		return err
+	}
+	return nil
+}
+
+// SaveCAS applies fn to the latest stored poll and saves it, so that no
+// concurrent vote is lost
+func (p *Poll) SaveCAS(brain CASStore, fn func(*Poll) error) error {
+	return brain.Update("poll", p, func() error {
+		return fn(p)
+	})
+}
+
+// Open tells if the poll of the day of now is still accepting votes
+func (p *Poll) Open(now time.Time) bool {
+	return p.Day == dayKey(now) && !p.Closed
+}
+
+// Due tells if the poll has to be closed at now, at its closing time
+func (p *Poll) Due(now time.Time) bool {
+	if !p.Open(now) {
+		return false
+	}
+	t, ok := at(now, p.Close)
+	return ok && !now.Before(t)
+}
+
+// Tally returns the votes of each option
+func (p *Poll) Tally() []int {
+	tally := make([]int, len(p.Options))
+	for _, i := range p.Votes {
+		if i >= 0 && i < len(tally) {
+			tally[i]++
+		}
+	}
+	return tally
+}
+
+// Winner returns the most voted option, the first one on a tie, and false
+// if nobody voted
+func (p *Poll) Winner() (string, bool) {
+	best := -1
+	tally := p.Tally()
+	for i, n := range tally {
+		if n > 0 && (best < 0 || n > tally[best]) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return "", false
+	}
+	return p.Options[best], true
+}
+
+func (p *Poll) String() string {
+	lines := make([]string, len(p.Options))
+	for i, n := range p.Tally() {
+		lines[i] = fmt.Sprintf("%s: %d", p.Options[i], n)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Result returns the outcome of the closed poll
+func (p *Poll) Result() string {
+	winner, ok := p.Winner()
+	if !ok {
+		return "Sondaggio chiuso, nessuno ha votato: il ristorante di oggi non cambia"
+	}
+	return "Sondaggio chiuso, oggi si mangia da *" + winner + "*!\n" + p.String()
+}
+
+// pollBlocks returns the message of the poll, with a button for each option
+// while it's open
+func pollBlocks(p Poll) []chat.Block {
+	header := "*Dove mangiamo oggi?*"
+	if p.Closed {
+		header = "*Dove mangiamo oggi?* (chiuso)"
+	} else if p.Close != "" {
+		header += " Si vota fino alle " + p.Close
+	}
+	blocks := []chat.Block{
+		chat.Section{Text: header},
+		chat.Context{Text: p.String()},
+	}
+	if p.Closed {
+		return blocks
+	}
+
+	var buttons []chat.Element
+	for i, o := range p.Options {
+		buttons = append(buttons, chat.Button{ActionID: actionPollVote, Value: strconv.Itoa(i), Text: truncate(o, maxOptionText)})
+	}
+	for len(buttons) > 0 {
+		n := min(len(buttons), maxActionElements)
+		blocks = append(blocks, chat.Actions{Elements: buttons[:n]})
+		buttons = buttons[n:]
+	}
+	return blocks
+}
+
+// ClosePoll closes the poll of the day of now, if it's due or force, and
+// makes the winner the restaurant of the day. It returns the poll and if it
+// was closed now.
+func ClosePoll(brain CASStore, now time.Time, force bool) (Poll, bool, error) {
+	var p Poll
+	closed := false
+	err := p.SaveCAS(brain, func(p *Poll) error {
+		closed = p.Open(now) && (force || p.Due(now))
+		if closed {
+			p.Closed = true
+		}
+		return nil
+	})
+	if err != nil || !closed {
+		return p, false, err
+	}
+	if winner, ok := p.Winner(); ok {
+		if err := brain.Set(sourceKey, MenuSource{winner, p.Day}); err != nil {
+			return p, true, err
+		}
+	}
+	return p, true, nil
+}
+
+// PublishPollResult shows the closed poll on its message and posts the
+// result on the channel
+func PublishPollResult(platform chat.Platform, p Poll) {
+	if err := platform.UpdateMessage(p.Channel, p.TS, "Sondaggio chiuso", pollBlocks(p)...); err != nil {
+		slog.Error("Error updating the poll", "err", err)
+	}
+	platform.SendMessage(p.Channel, "", p.Result())
+}
+
+// pollRe splits the options of the poll from the closing time
+var pollRe = regexp.MustCompile(`^(?i)(.+?)(?:\s+fino alle\s+(\d{1,2}:\d{2}))?$`)
+
+// pollSepRe separates the options of the poll, "a vs b" or "a, b"
+var pollSepRe = regexp.MustCompile(`(?i)\s+vs\.?\s+|,`)
+
+// parsePoll returns the options and the closing time of "a vs b [fino alle hh:mm]"
+func parsePoll(s string) ([]string, string, error) {
+	m := pollRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return nil, "", fmt.Errorf("nessun ristorante indicato")
+	}
+	if m[2] != "" {
+		if _, err := time.Parse("15:04", m[2]); err != nil {
+			return nil, "", fmt.Errorf("orario %s non valido, usa il formato hh:mm", m[2])
+		}
+	}
+	var options []string
+	for _, o := range pollSepRe.Split(m[1], -1) {
+		if o = strings.TrimSpace(o); o != "" {
+			options = append(options, o)
+		}
+	}
+	if len(options) < 2 {
+		return nil, "", fmt.Errorf("servono almeno due ristoranti")
+	}
+	if len(options) > maxPollOptions {
+		return nil, "", fmt.Errorf("al massimo %d ristoranti", maxPollOptions)
+	}
+	return options, m[2], nil
+}
+
+// PollCmd shows the poll on the restaurant of the day, starts a new one or
+// closes it
+func (t *TinaBot) PollCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	arg := strings.TrimSpace(args[1])
+	now := time.Now()
+	var p Poll
+	p.Load(t.brain)
+
+	if arg == "" {
+		if p.Day != dayKey(now) {
+			t.bot.Message(msg.Channel, "Nessun sondaggio oggi, avvialo con `sondaggio <ristorante> vs <ristorante> [fino alle <hh:mm>]`")
+			return
+		}
+		state := "aperto"
+		if p.Closed {
+			state = "chiuso"
+		}
+		t.bot.Message(msg.Channel, "Sondaggio "+state+":\n"+p.String())
+		return
+	}
+	if isDirect(msg) {
+		t.bot.Message(msg.Channel, "Il sondaggio si fa nel canale del pranzo, non in privato")
+		return
+	}
+	if !t.canAdmin(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono gestire il sondaggio")
+		return
+	}
+
+	if strings.ToLower(arg) == "chiudi" {
+		p, closed, err := ClosePoll(t.brain, now, true)
+		if err != nil {
+			t.bot.Message(msg.Channel, "Errore nel chiudere il sondaggio: "+err.Error())
+			return
+		}
+		if !closed {
+			t.bot.Message(msg.Channel, "Nessun sondaggio aperto oggi")
+			return
+		}
+		PublishPollResult(t.bot.Platform, p)
+		return
+	}
+
+	if p.Open(now) {
+		t.bot.Message(msg.Channel, "C'è già un sondaggio aperto, chiudilo prima con `sondaggio chiudi`")
+		return
+	}
+	options, closeAt, err := parsePoll(arg)
+	if err != nil {
+		t.bot.Message(msg.Channel, "Comando non valido, "+err.Error()+": usa `sondaggio <ristorante> vs <ristorante> [fino alle <hh:mm>]`")
+		return
+	}
+	p = Poll{Day: dayKey(now), Options: options, Votes: make(map[string]int), Close: closeAt, Channel: msg.Channel}
+	ts, err := t.bot.Platform.SendMessage(msg.Channel, "", "Dove mangiamo oggi?", pollBlocks(p)...)
+	if err != nil {
+		t.bot.Message(msg.Channel, "Errore nel pubblicare il sondaggio: "+err.Error())
+		return
+	}
+	p.TS = ts
+	if err := t.brain.Set("poll", p); err != nil {
+		t.bot.Message(msg.Channel, "Errore nel salvare il sondaggio: "+err.Error())
+	}
+}
+
+// pollAction records the vote of a button of the poll, a new vote replaces
+// the previous one of the user
+func (t *TinaBot) pollAction(responseURL string, msg *slackbot.BotMsg, user *chat.User, a chat.Action) {
+	i, _ := strconv.Atoi(a.Value)
+	var p Poll
+	err := p.SaveCAS(t.brain, func(p *Poll) error {
+		if !p.Open(time.Now()) {
+			return fmt.Errorf("il sondaggio è chiuso")
+		}
+		if i < 0 || i >= len(p.Options) {
+			return fmt.Errorf("il ristorante votato non è nel sondaggio")
+		}
+		if p.Votes == nil {
+			p.Votes = make(map[string]int)
+		}
+		p.Votes[userKey(User{user.Name, user.ID})] = i
+		return nil
+	})
+	if err != nil {
+		t.bot.Reply(msg, slackbot.Ephemeral, "Voto non valido: "+err.Error())
+		return
+	}
+	t.bot.Replace(responseURL, "Dove mangiamo oggi?", pollBlocks(p)...)
+}
@@ -0,0 +1,63 @@
+package tinabot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+)
+
+func TestParsePoll(t *testing.T) {
+	options, closeAt, err := parsePoll("Tuttobene vs pizzeria VS poke fino alle 11:30")
+	assertEqual(t, err, nil, "")
+	assertEqual(t, strings.Join(options, "|"), "Tuttobene|pizzeria|poke", "")
+	assertEqual(t, closeAt, "11:30", "")
+
+	options, closeAt, err = parsePoll("Tuttobene, sushi")
+	assertEqual(t, err, nil, "")
+	assertEqual(t, strings.Join(options, "|"), "Tuttobene|sushi", "")
+	assertEqual(t, closeAt, "", "")
+
+	_, _, err = parsePoll("Tuttobene")
+	assertEqual(t, err != nil, true, "")
+	_, _, err = parsePoll("a vs b fino alle 25:00")
+	assertEqual(t, err != nil, true, "")
+}
+
+func TestPollWinner(t *testing.T) {
+	p := Poll{Options: []string{"Tuttobene", "pizzeria", "poke"}, Votes: map[string]int{}}
+	_, ok := p.Winner()
+	assertEqual(t, ok, false, "")
+
+	p.Votes["U1"] = 2
+	p.Votes["U2"] = 1
+	w, _ := p.Winner()
+	assertEqual(t, w, "pizzeria", "")
+
+	p.Votes["U3"] = 2
+	w, _ = p.Winner()
+	assertEqual(t, w, "poke", "")
+	assertEqual(t, p.String(), "Tuttobene: 0\npizzeria: 1\npoke: 2", "")
+}
+
+func TestClosePoll(t *testing.T) {
+	b := brain.NewBrainMock()
+	now := time.Date(2019, 3, 15, 10, 55, 0, 0, time.UTC)
+	b.Set("poll", Poll{Day: dayKey(now), Options: []string{"Tuttobene", "pizzeria"}, Votes: map[string]int{"U1": 1}, Close: "11:00"})
+
+	_, closed, err := ClosePoll(b, now, false)
+	assertEqual(t, err, nil, "")
+	assertEqual(t, closed, false, "")
+	assertEqual(t, ActiveSource(b, now), "", "")
+
+	p, closed, err := ClosePoll(b, now.Add(5*time.Minute), false)
+	assertEqual(t, err, nil, "")
+	assertEqual(t, closed, true, "")
+	assertEqual(t, p.Closed, true, "")
+	assertEqual(t, ActiveSource(b, now), "pizzeria", "")
+	assertEqual(t, ActiveSource(b, now.AddDate(0, 0, 1)), "", "")
+
+	_, closed, _ = ClosePoll(b, now.Add(10*time.Minute), true)
+	assertEqual(t, closed, false, "")
+}
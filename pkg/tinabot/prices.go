@@ -0,0 +1,264 @@
+package tinabot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/develersrl/lunches/pkg/analytics"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// priceRetention is how long the price changes are kept
+const priceRetention = 13 // months
+
+// DishPrice is the last known price of a dish
+type DishPrice struct {
+	Name  string
+	Type  tuttobene.MenuRowType
+	Price decimal.Decimal
+	Day   string
+}
+
+// PriceHistory is the last price of each dish by canonical name, and how the
+// prices changed from a menu to the following one, kept for priceRetention
+// unlike the menus
+type PriceHistory struct {
+	Last    map[string]DishPrice
+	Changes []analytics.PriceChange
+	// Pending are the increases not yet posted on the channel of the alerts
+	Pending []analytics.PriceChange `json:",omitempty"`
+}
+
+// Load loads the history from brain, empty if missing
+func (h *PriceHistory) Load(brain DataStore) error {
+	if err := brain.Get("prices", h); err != nil {
+		*h = PriceHistory{}
+		return err
+	}
+	return nil
+}
+
+// Save saves the history to brain
+func (h *PriceHistory) Save(brain DataStore) error {
+	return brain.Set("prices", *h)
+}
+
+// Add records the prices of the menu, comparing them to the ones of the
+// previous menus, and returns the changes. The increases are also added to
+// the pending ones if alert is true. The menus older than the last one of a
+// dish don't change it, neither does saving again the menu of the same day.
+func (h *PriceHistory) Add(m tuttobene.Menu, alert bool) []analytics.PriceChange {
+	day := dayKey(m.Date)
+	if h.Last == nil {
+		h.Last = make(map[string]DishPrice)
+	}
+	var changes []analytics.PriceChange
+	for _, r := range m.Rows {
+		name := tuttobene.CanonicalName(r.Content)
+		if r.Type == tuttobene.Empty || r.Type == tuttobene.Unknonwn || name == "" || !r.Price.IsPositive() {
+			continue
+		}
+		last, ok := h.Last[name]
+		if ok && day < last.Day {
+			continue
+		}
+		if ok && day > last.Day && !r.Price.Equal(last.Price) {
+			c := analytics.PriceChange{Date: m.Date, Name: r.Content, Type: r.Type, Old: last.Price, New: r.Price}
+			changes = append(changes, c)
+			if alert && c.New.GreaterThan(c.Old) {
+				h.Pending = append(h.Pending, c)
+			}
+		}
+		h.Last[name] = DishPrice{r.Content, r.Type, r.Price, day}
+	}
+	h.Changes = append(h.Changes, changes...)
+
+	since := m.Date.AddDate(0, -priceRetention, 0)
+	for len(h.Changes) > 0 && h.Changes[0].Date.Before(since) {
+		h.Changes = h.Changes[1:]
+	}
+	return changes
+}
+
+// recordPrices adds the prices of the menu to the history, the increases are
+// alerted if the alerts are on
+func recordPrices(brain DataStore, m tuttobene.Menu) error {
+	var s PriceAlerts
+	s.Load(brain)
+	var h PriceHistory
+	h.Load(brain)
+	h.Add(m, s.Channel != "")
+	return h.Save(brain)
+}
+
+// PriceAlerts configures the alerts on Channel of the price increases, and
+// the summary of the changes of the previous month posted on its first day
+type PriceAlerts struct {
+	Channel string
+
+	// the month of the last summary posted, "2006-01"
+	Sent string `json:",omitempty"`
+}
+
+// Load loads the settings from brain, no alert is posted if missing
+func (s *PriceAlerts) Load(brain DataStore) error {
+	if err := brain.Get("prices:alerts", s); err != nil {
+		*s = PriceAlerts{}
+		return err
+	}
+	return nil
+}
+
+// Save saves the settings to brain
+func (s *PriceAlerts) Save(brain DataStore) error {
+	return brain.Set("prices:alerts", *s)
+}
+
+// Due returns the previous month if its summary is to be posted at now: from
+// reportTime on the first day of the month, or on the following days if it
+// was skipped
+func (s *PriceAlerts) Due(now time.Time) (analytics.Period, bool) {
+	if s.Channel == "" {
+		return analytics.Period{}, false
+	}
+	month := analytics.Month(now)
+	from, _ := at(month.Start, reportTime)
+	prev := analytics.Month(month.Start.AddDate(0, 0, -1))
+	return prev, !now.Before(from) && s.Sent != monthKey(prev.Start)
+}
+
+func (s *PriceAlerts) String() string {
+	if s.Channel == "" {
+		return "Nessun avviso sui prezzi impostato"
+	}
+	return fmt.Sprintf("Gli aumenti dei prezzi vengono segnalati su <#%s>, con il riepilogo del mese precedente il primo del mese dalle %s", s.Channel, reportTime)
+}
+
+// PendingPriceAlert returns the message with the price increases not yet
+// posted, and clears them; the message is empty if there are none
+func PendingPriceAlert(brain DataStore) (string, error) {
+	var h PriceHistory
+	if err := h.Load(brain); err != nil || len(h.Pending) == 0 {
+		return "", nil
+	}
+	msg := FormatPriceAlert(h.Pending)
+	h.Pending = nil
+	return msg, h.Save(brain)
+}
+
+// formatPercent returns the change in percent with its sign, e.g. "+7.7%"
+func formatPercent(p float64) string {
+	return fmt.Sprintf("%+.1f%%", p)
+}
+
+func formatPriceChange(c analytics.PriceChange) string {
+	return fmt.Sprintf("%s: da €%s a €%s (%s)", c.Name, c.Old.StringFixed(2), c.New.StringFixed(2), formatPercent(c.Percent()))
+}
+
+// FormatPriceAlert returns the message with the price increases
+func FormatPriceAlert(changes []analytics.PriceChange) string {
+	out := []string{":chart_with_upwards_trend: *Prezzi aumentati*"}
+	for _, c := range changes {
+		out = append(out, fmt.Sprintf("%s, %s", formatPriceChange(c), formatDay(c.Date)))
+	}
+	return strings.Join(out, "\n")
+}
+
+// PriceChanges returns the changes of the prices in the period
+func PriceChanges(brain DataStore, period analytics.Period) []analytics.PriceChange {
+	var h PriceHistory
+	h.Load(brain)
+	var changes []analytics.PriceChange
+	for _, c := range h.Changes {
+		if period.Contains(c.Date) {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// FormatInflation returns the summary of the changes of the prices in the
+// month for each section, with the changes listed if details is true
+func FormatInflation(changes []analytics.PriceChange, month analytics.Period, details bool) string {
+	title := "*Variazioni dei prezzi di " + month.Start.Format("01/2006") + "*"
+	inflation := analytics.NewInflation(changes, month)
+	if len(inflation) == 0 {
+		return title + "\nNessun prezzo cambiato nel mese"
+	}
+	out := []string{title}
+	for _, s := range inflation {
+		var moves []string
+		if s.Increases > 0 {
+			moves = append(moves, count(s.Increases, "aumento", "aumenti"))
+		}
+		if s.Decreases > 0 {
+			moves = append(moves, count(s.Decreases, "calo", "cali"))
+		}
+		out = append(out, fmt.Sprintf("%s: %s, in media %s", strings.Title(tuttobene.Titles[s.Type]), strings.Join(moves, " e "), formatPercent(s.Average)))
+	}
+	if details {
+		out = append(out, "")
+		for _, c := range changes {
+			out = append(out, formatPriceChange(c))
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// PricesCmd shows the changes of the prices of the current month or of the
+// one given, or turns on and off the alerts on the channel
+func (t *TinaBot) PricesCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	f := strings.Fields(strings.ToLower(args[1]))
+	if len(f) > 0 && f[0] == "avvisi" {
+		t.priceAlerts(msg, user, f[1:])
+		return
+	}
+
+	month, err := parseMonth(strings.Join(f, " "), time.Now())
+	if err != nil {
+		t.bot.Message(msg.Channel, "Mese non valido, usa il formato aaaa-mm")
+		return
+	}
+	period := analytics.Month(month)
+	t.bot.Message(msg.Channel, FormatInflation(PriceChanges(t.brain, period), period, true))
+}
+
+// priceAlerts shows the settings of the price alerts, or turns them on the
+// channel or off
+func (t *TinaBot) priceAlerts(msg *slackbot.BotMsg, user *chat.User, f []string) {
+	var s PriceAlerts
+	s.Load(t.brain)
+	if len(f) == 0 {
+		t.bot.Message(msg.Channel, s.String())
+		return
+	}
+	if !t.canAdmin(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono impostare gli avvisi sui prezzi")
+		return
+	}
+	switch {
+	case len(f) == 1 && f[0] == "on":
+		s.Channel = msg.Channel
+	case len(f) == 1 && f[0] == "off":
+		s.Channel = ""
+		// the increases not posted would be stale when turned on again
+		var h PriceHistory
+		if h.Load(t.brain) == nil && len(h.Pending) > 0 {
+			h.Pending = nil
+			h.Save(t.brain)
+		}
+	default:
+		t.bot.Message(msg.Channel, "Comando non valido, usa `prezzi avvisi on|off`")
+		return
+	}
+	if err := s.Save(t.brain); err != nil {
+		t.bot.Message(msg.Channel, "Errore: "+err.Error())
+		return
+	}
+	t.bot.Message(msg.Channel, "Ok\n"+s.String())
+}
@@ -0,0 +1,64 @@
+package tinabot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/develersrl/lunches/pkg/analytics"
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestPriceHistory(t *testing.T) {
+	menu := func(d int, pasta, pollo float64) tuttobene.Menu {
+		return tuttobene.Menu{Date: time.Date(2019, 3, d, 0, 0, 0, 0, time.UTC), Rows: []tuttobene.MenuRow{
+			{Content: "Pasta al pomodoro", Type: tuttobene.Primo, Price: decimal.NewFromFloat(pasta)},
+			{Content: "Pollo arrosto", Type: tuttobene.Secondo, Price: decimal.NewFromFloat(pollo)},
+			{Content: "", Type: tuttobene.Empty},
+		}}
+	}
+	var h PriceHistory
+	assertEqual(t, len(h.Add(menu(11, 6.5, 8), true)), 0, "")
+	changes := h.Add(menu(12, 7, 7.5), true)
+	assertEqual(t, len(changes), 2, "")
+	assertEqual(t, len(h.Pending), 1, "only the increases are pending")
+	// the same day again, and an older menu, change nothing
+	assertEqual(t, len(h.Add(menu(12, 7, 7.5), true)), 0, "")
+	assertEqual(t, len(h.Add(menu(10, 5, 5), true)), 0, "")
+	assertEqual(t, h.Last["pasta al pomodoro"].Price.String(), "7", "")
+	h.Add(menu(13, 7.5, 7.5), false)
+	assertEqual(t, len(h.Pending), 1, "no alert if off")
+	assertEqual(t, len(h.Changes), 3, "")
+
+	assertEqual(t, FormatPriceAlert(h.Pending),
+		":chart_with_upwards_trend: *Prezzi aumentati*\nPasta al pomodoro: da €6.50 a €7.00 (+7.7%), martedì 12/03", "")
+
+	march := analytics.Month(time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC))
+	assertEqual(t, FormatInflation(h.Changes, march, false),
+		"*Variazioni dei prezzi di 03/2019*\nPrimi Piatti: 2 aumenti, in media +7.4%\nSecondi Piatti: 1 calo, in media -6.2%", "")
+	assertEqual(t, FormatInflation(nil, march, true), "*Variazioni dei prezzi di 03/2019*\nNessun prezzo cambiato nel mese", "")
+
+	// the oldest changes are dropped
+	m := menu(13, 9, 7.5)
+	m.Date = time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	h.Add(m, false)
+	assertEqual(t, len(h.Changes), 1, "")
+}
+
+func TestPendingPriceAlert(t *testing.T) {
+	b := brain.NewBrainMock()
+	(&PriceAlerts{Channel: "C1"}).Save(b)
+	for d, price := range []float64{7, 8} {
+		m := tuttobene.Menu{Date: time.Date(2019, 3, 11+d, 0, 0, 0, 0, time.UTC), Rows: []tuttobene.MenuRow{
+			{Content: "Lasagne", Type: tuttobene.Primo, Price: decimal.NewFromFloat(price)},
+		}}
+		assertEqual(t, recordPrices(b, m), nil, "")
+	}
+	msg, err := PendingPriceAlert(b)
+	assertEqual(t, err, nil, "")
+	assertEqual(t, msg != "", true, "")
+	msg, _ = PendingPriceAlert(b)
+	assertEqual(t, msg, "", "the alert is posted once")
+}
@@ -0,0 +1,112 @@
+package tinabot
+
+import (
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/config"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// Privacy tells how much of the order is shown in the public channels
+type Privacy struct {
+	Anonymous bool // show only the dish counts, without the user names
+}
+
+// Load loads the privacy settings from brain, the full order is shown if they're not set
+func (p *Privacy) Load(brain DataStore) error {
+	if err := brain.Get("privacy", p); err != nil {
+		*p = Privacy{}
+		return err
+	}
+	return nil
+}
+
+// Save saves the privacy settings to brain
+func (p *Privacy) Save(brain DataStore) error {
+	return brain.Set("privacy", *p)
+}
+
+// ShowNames returns true if the per-user breakdown can be shown, in
+// anonymous mode only admins can see it and only in a direct message
+func (p *Privacy) ShowNames(admin, direct bool) bool {
+	return !p.Anonymous || (admin && direct)
+}
+
+func (p *Privacy) String() string {
+	if p.Anonymous {
+		return "Modalità anonima: nei canali pubblici l'ordine mostra solo il numero dei piatti"
+	}
+	return "Modalità completa: l'ordine mostra chi ha ordinato cosa"
+}
+
+// globalAdmins returns the admins of all the channels as "<team>:<user ID>",
+// see config.Config.Admins. The names are not unique across the workspaces.
+func globalAdmins() []string {
+	return config.Current().Admins
+}
+
+// teamAdmins returns the user IDs of the admins of all the channels of team
+func teamAdmins(team string) []string {
+	var ids []string
+	for _, a := range globalAdmins() {
+		if t, id, ok := strings.Cut(a, ":"); ok && t == team && id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// isAdmin returns true if the user id of team is one of the admins of all
+// the channels
+func isAdmin(team, id string) bool {
+	for _, a := range teamAdmins(team) {
+		if a == id {
+			return true
+		}
+	}
+	return false
+}
+
+// isDirect returns true if msg was sent in a direct message to the bot
+func isDirect(msg *slackbot.BotMsg) bool {
+	return strings.HasPrefix(msg.Channel, "D")
+}
+
+// showNames returns true if the per-user breakdown of the order can be sent
+// as a reply to msg
+func (t *TinaBot) showNames(msg *slackbot.BotMsg, user *chat.User) bool {
+	var p Privacy
+	p.Load(t.brain)
+	return p.ShowNames(isAdminOf(t.brain, t.team, msg.Channel, User{user.Name, user.ID}), isDirect(msg))
+}
+
+// PrivacyCmd shows or changes how the order is shown in the public channels
+func (t *TinaBot) PrivacyCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	var p Privacy
+	p.Load(t.brain)
+
+	arg := strings.ToLower(strings.TrimSpace(args[1]))
+	switch arg {
+	case "":
+		t.bot.Message(msg.Channel, p.String())
+		return
+	case "anonimo", "anonima":
+		p.Anonymous = true
+	case "completo", "completa":
+		p.Anonymous = false
+	default:
+		t.bot.Message(msg.Channel, "Non ho capito, usa `privacy anonimo` o `privacy completo`")
+		return
+	}
+
+	if !t.canAdmin(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono cambiare la privacy dell'ordine")
+		return
+	}
+	if err := p.Save(t.brain); err != nil {
+		t.bot.Message(msg.Channel, "Errore nel salvare le impostazioni: "+err.Error())
+		return
+	}
+	t.bot.Message(msg.Channel, "Ok\n"+p.String())
+}
@@ -0,0 +1,24 @@
+package tinabot
+
+import (
+	"testing"
+)
+
+func TestPrivacy(t *testing.T) {
+	var p Privacy
+	assertEqual(t, p.ShowNames(false, false), true, "")
+
+	p.Anonymous = true
+	assertEqual(t, p.ShowNames(false, false), false, "")
+	assertEqual(t, p.ShowNames(false, true), false, "")
+	assertEqual(t, p.ShowNames(true, false), false, "")
+	assertEqual(t, p.ShowNames(true, true), true, "")
+
+	setConfig(t, "TINABOT_ADMINS", "T1:U1, T1:U2, luigi")
+	assertEqual(t, isAdmin("T1", "U2"), true, "")
+	assertEqual(t, isAdmin("T1", "U3"), false, "")
+	assertEqual(t, isAdmin("T1", ""), false, "")
+	// the same user ID or name in another workspace is not an admin
+	assertEqual(t, isAdmin("T2", "U2"), false, "")
+	assertEqual(t, isAdmin("", "luigi"), false, "")
+}
@@ -0,0 +1,201 @@
+package tinabot
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// Rate is the limit of a token bucket: Burst commands at once, refilled at
+// Burst commands every Per. There's no limit if Burst is 0.
+type Rate struct {
+	Burst int           `json:",omitempty"`
+	Per   time.Duration `json:",omitempty"`
+}
+
+func (r Rate) String() string {
+	if r.Burst <= 0 {
+		return "nessun limite"
+	}
+	return fmt.Sprintf("%s ogni %s", count(r.Burst, "comando", "comandi"), count(int(r.Per.Seconds()), "secondo", "secondi"))
+}
+
+// parseRate parses a rate as "<commands>/<duration>", e.g. "10/1m"
+func parseRate(s string) (Rate, error) {
+	i := strings.Index(s, "/")
+	if i < 0 {
+		return Rate{}, fmt.Errorf("limite '%s' non valido, usa ad esempio 10/1m", s)
+	}
+	n, err := strconv.Atoi(s[:i])
+	if err != nil || n <= 0 {
+		return Rate{}, fmt.Errorf("numero di comandi '%s' non valido", s[:i])
+	}
+	d, err := time.ParseDuration(s[i+1:])
+	if err != nil || d < time.Second {
+		return Rate{}, fmt.Errorf("durata '%s' non valida, usa ad esempio 30s o 1m", s[i+1:])
+	}
+	return Rate{n, d}, nil
+}
+
+// RateLimits are the rate limits of the commands of each user and in each
+// channel, so that a script or a prank can't flood the order or use up the
+// quota of the Slack API
+type RateLimits struct {
+	User    Rate
+	Channel Rate
+}
+
+// defaultRateLimits are the rate limits until the admins change them
+var defaultRateLimits = RateLimits{
+	User:    Rate{10, time.Minute},
+	Channel: Rate{30, time.Minute},
+}
+
+// Load loads the limits from brain, the default ones if missing
+func (l *RateLimits) Load(brain DataStore) error {
+	if err := brain.Get("ratelimit:settings", l); err != nil {
+		*l = defaultRateLimits
+		return err
+	}
+	return nil
+}
+
+// Save saves the limits to brain
+func (l *RateLimits) Save(brain DataStore) error {
+	return brain.Set("ratelimit:settings", *l)
+}
+
+func (l *RateLimits) String() string {
+	return "Limite per utente: " + l.User.String() + "\nLimite per canale: " + l.Channel.String()
+}
+
+// bucket is a token bucket kept in the brain
+type bucket struct {
+	Tokens float64
+	At     time.Time
+	// Warned is set when the user was told about the throttling, so that
+	// the bot doesn't flood the channel itself
+	Warned bool `json:",omitempty"`
+}
+
+// take takes a token from the bucket at now, refilling it at rate r.
+// Returns false if it's empty, with the time until the next token.
+func (b *bucket) take(r Rate, now time.Time) (bool, time.Duration) {
+	every := r.Per / time.Duration(r.Burst)
+	if b.At.IsZero() {
+		b.Tokens = float64(r.Burst)
+	} else if now.After(b.At) {
+		b.Tokens = math.Min(float64(r.Burst), b.Tokens+float64(now.Sub(b.At))/float64(every))
+	}
+	b.At = now
+	if b.Tokens < 1 {
+		return false, time.Duration((1 - b.Tokens) * float64(every))
+	}
+	b.Tokens--
+	b.Warned = false
+	return true, 0
+}
+
+// takeToken takes a token from the bucket of key in store at now, the bucket
+// expires once full again. Returns whether the command is allowed, if the
+// throttling is to be told, and the time until the next token.
+func takeToken(store brain.Store, key string, r Rate, now time.Time) (ok, warn bool, wait time.Duration, err error) {
+	var b bucket
+	err = store.Update(key, &b, func() error {
+		ok, wait = b.take(r, now)
+		warn = !ok && !b.Warned
+		if warn {
+			b.Warned = true
+		}
+		return nil
+	})
+	if err != nil {
+		return false, false, 0, err
+	}
+	store.ExpireAt(key, now.Add(r.Per))
+	return ok, warn, wait, nil
+}
+
+// throttle tells if the command of user in channel exceeds the rate limits
+// at now, with the notice to answer it once each time a limit is hit. The
+// commands are allowed if the buckets can't be read, not to lock everybody
+// out when the brain has problems.
+func (t *TinaBot) throttle(channel, user string, now time.Time) (bool, string) {
+	var limits RateLimits
+	limits.Load(t.brain)
+	checks := []struct {
+		key    string
+		rate   Rate
+		notice string
+	}{
+		{"user:" + user, limits.User, "Piano con i comandi! Riprova tra %s"},
+		{"channel:" + channel, limits.Channel, "Troppi comandi in questo canale, riprova tra %s"},
+	}
+	for _, c := range checks {
+		if c.rate.Burst <= 0 {
+			continue
+		}
+		ok, warn, wait, err := takeToken(t.root, "ratelimit:"+t.team+":"+c.key, c.rate, now)
+		if err != nil {
+			t.logger().Error("Error checking the rate limit", "key", c.key, "err", err)
+			continue
+		}
+		if ok {
+			continue
+		}
+		if !warn {
+			return true, ""
+		}
+		secs := int(math.Ceil(wait.Seconds()))
+		return true, fmt.Sprintf(c.notice, count(secs, "secondo", "secondi"))
+	}
+	return false, ""
+}
+
+// RateLimitCmd shows or changes the rate limits of the commands
+func (t *TinaBot) RateLimitCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	var l RateLimits
+	l.Load(t.brain)
+	f := strings.Fields(strings.ToLower(args[1]))
+	if len(f) == 0 {
+		t.bot.Message(msg.Channel, l.String())
+		return
+	}
+	if !t.canAdmin(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono cambiare i limiti dei comandi")
+		return
+	}
+	if len(f) != 2 {
+		t.bot.Message(msg.Channel, "Comando non valido, usa `limiti utente|canale <comandi>/<durata>|off`")
+		return
+	}
+
+	var r Rate
+	if f[1] != "off" {
+		var err error
+		if r, err = parseRate(f[1]); err != nil {
+			t.bot.Message(msg.Channel, "Mi spiace, "+err.Error())
+			return
+		}
+	}
+	switch f[0] {
+	case "utente":
+		l.User = r
+	case "canale":
+		l.Channel = r
+	default:
+		t.bot.Message(msg.Channel, "Comando non valido, usa `limiti utente|canale <comandi>/<durata>|off`")
+		return
+	}
+	if err := l.Save(t.brain); err != nil {
+		t.bot.Message(msg.Channel, "Errore: "+err.Error())
+		return
+	}
+	t.bot.Message(msg.Channel, "Ok\n"+l.String())
+}
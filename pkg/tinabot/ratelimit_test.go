@@ -0,0 +1,83 @@
+package tinabot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+func TestBucket(t *testing.T) {
+	r := Rate{3, 30 * time.Second}
+	now := time.Date(2020, 3, 16, 12, 0, 0, 0, time.UTC)
+	var b bucket
+	for i := 0; i < 3; i++ {
+		ok, _ := b.take(r, now)
+		assertEqual(t, ok, true, "burst")
+	}
+	ok, wait := b.take(r, now)
+	assertEqual(t, ok, false, "empty")
+	assertEqual(t, wait, 10*time.Second, "")
+
+	ok, _ = b.take(r, now.Add(5*time.Second))
+	assertEqual(t, ok, false, "half a token")
+	ok, _ = b.take(r, now.Add(10*time.Second))
+	assertEqual(t, ok, true, "refilled")
+	ok, _ = b.take(r, now.Add(time.Hour))
+	assertEqual(t, ok, true, "")
+	assertEqual(t, b.Tokens, 2.0, "never more than the burst")
+}
+
+func TestParseRate(t *testing.T) {
+	r, err := parseRate("5/30s")
+	assertEqual(t, err, nil, "")
+	assertEqual(t, r, Rate{5, 30 * time.Second}, "")
+	assertEqual(t, r.String(), "5 comandi ogni 30 secondi", "")
+	for _, s := range []string{"5", "0/1m", "x/1m", "5/boh", "5/1ms"} {
+		_, err := parseRate(s)
+		assertEqual(t, err != nil, true, s)
+	}
+	assertEqual(t, Rate{}.String(), "nessun limite", "")
+}
+
+func TestThrottle(t *testing.T) {
+	b := brain.NewBrainMock()
+	tb := New(slackbot.New("B1", nil), b)
+	tb.team = "T1"
+	limits := RateLimits{User: Rate{2, time.Minute}, Channel: Rate{3, time.Minute}}
+	assertEqual(t, limits.Save(b), nil, "")
+
+	// the buckets expire by the clock of the brain
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		throttled, _ := tb.throttle("C1", "U1", now)
+		assertEqual(t, throttled, false, "")
+	}
+	throttled, notice := tb.throttle("C1", "U1", now)
+	assertEqual(t, throttled, true, "")
+	assertEqual(t, notice, "Piano con i comandi! Riprova tra 30 secondi", "")
+	throttled, notice = tb.throttle("C1", "U1", now.Add(time.Second))
+	assertEqual(t, throttled, true, "")
+	assertEqual(t, notice, "", "told once")
+
+	throttled, _ = tb.throttle("C1", "U2", now)
+	assertEqual(t, throttled, false, "another user")
+	throttled, notice = tb.throttle("C1", "U3", now)
+	assertEqual(t, throttled, true, "")
+	assertEqual(t, notice, "Troppi comandi in questo canale, riprova tra 20 secondi", "")
+
+	throttled, _ = tb.throttle("C1", "U1", now.Add(time.Minute))
+	assertEqual(t, throttled, false, "refilled")
+
+	limits.User, limits.Channel = Rate{}, Rate{}
+	limits.Save(b)
+	for i := 0; i < 10; i++ {
+		throttled, _ := tb.throttle("C1", "U1", now)
+		assertEqual(t, throttled, false, "no limits")
+	}
+
+	var l RateLimits
+	l.Load(brain.NewBrainMock())
+	assertEqual(t, l, defaultRateLimits, "")
+}
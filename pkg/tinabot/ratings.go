@@ -0,0 +1,192 @@
+package tinabot
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// maxLeaderboard is the most dishes shown by "migliori piatti"
+const maxLeaderboard = 10
+
+// Rating is the vote of a user to a dish, from 1 to 5 stars
+type Rating struct {
+	Stars   int
+	Comment string `json:",omitempty"`
+	Day     string
+}
+
+// DishRatings are the ratings of a dish by userKey, the last one of each
+// user counts
+type DishRatings struct {
+	Name    string // the dish as last rated, for display
+	Ratings map[string]Rating
+}
+
+// ratingRepo keeps the ratings by the canonical name of the dish
+func ratingRepo(b DataStore) brain.Repo[DishRatings] {
+	return brain.NewRepo[DishRatings](b, "ratings:")
+}
+
+// Average returns the average stars of the dish and the number of ratings
+func (r DishRatings) Average() (float64, int) {
+	if len(r.Ratings) == 0 {
+		return 0, 0
+	}
+	sum := 0
+	for _, v := range r.Ratings {
+		sum += v.Stars
+	}
+	return float64(sum) / float64(len(r.Ratings)), len(r.Ratings)
+}
+
+// ratingString formats the average stars of r, e.g. "★4.5 (2)"
+func ratingString(r DishRatings) string {
+	avg, n := r.Average()
+	return fmt.Sprintf("★%.1f (%d)", avg, n)
+}
+
+// rate records the rating of user to dish
+func rate(brain DataStore, user User, dish string, rating Rating) error {
+	repo := ratingRepo(brain)
+	key := tuttobene.CanonicalName(dish)
+	r, err := repo.Get(key)
+	if err != nil || r.Ratings == nil {
+		r = DishRatings{Ratings: make(map[string]Rating)}
+	}
+	r.Name = dish
+	r.Ratings[userKey(user)] = rating
+	return repo.Put(key, r)
+}
+
+// withRatings returns a copy of menu showing the average rating next to the
+// dishes rated before
+func withRatings(brain DataStore, menu tuttobene.Menu) tuttobene.Menu {
+	ratings, err := ratingRepo(brain).All()
+	if err != nil || len(ratings) == 0 {
+		return menu
+	}
+	rows := make([]tuttobene.MenuRow, len(menu.Rows))
+	for i, r := range menu.Rows {
+		if dr, ok := ratings[tuttobene.CanonicalName(r.Content)]; ok && len(dr.Ratings) > 0 {
+			r.Content += " " + ratingString(dr)
+		}
+		rows[i] = r
+	}
+	menu.Rows = rows
+	return menu
+}
+
+// leaderboard returns the best rated dishes, by average and then by number
+// of ratings
+func leaderboard(ratings map[string]DishRatings, n int) []DishRatings {
+	var best []DishRatings
+	for _, r := range ratings {
+		if len(r.Ratings) > 0 {
+			best = append(best, r)
+		}
+	}
+	sort.Slice(best, func(i, j int) bool {
+		ai, ni := best[i].Average()
+		aj, nj := best[j].Average()
+		if ai != aj {
+			return ai > aj
+		}
+		if ni != nj {
+			return ni > nj
+		}
+		return best[i].Name < best[j].Name
+	})
+	if len(best) > n {
+		best = best[:n]
+	}
+	return best
+}
+
+// askRatings asks the users of the delivered order to rate their dishes
+func (t *TinaBot) askRatings(order *Order) {
+	for _, u := range order.users() {
+		if u.ID == "" {
+			continue
+		}
+		ch, err := t.bot.Platform.DirectChannel(u.ID)
+		if err != nil {
+			slackbot.APIError("conversations.open")
+			t.logger().Error("Error opening the direct message", "err", err)
+			continue
+		}
+		var list []string
+		for i, c := range order.Users[u] {
+			list = append(list, fmt.Sprintf("%d. %s", i+1, c.String()))
+		}
+		t.bot.Message(ch, "Il pranzo è arrivato, buon appetito! Dopo dimmi com'era con `voto <n> <da 1 a 5> [commento]`:\n"+strings.Join(list, "\n"))
+	}
+}
+
+// voteRe splits the vote in the dish, the stars and the comment
+var voteRe = regexp.MustCompile(`^(.+?)\s+([1-5])(?:\s+(.*))?$`)
+
+// Vote rates a dish ordered today by the user, once the order is delivered
+func (t *TinaBot) Vote(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	m := voteRe.FindStringSubmatch(strings.TrimSpace(args[1]))
+	if m == nil {
+		t.bot.Message(msg.Channel, "Comando non valido, usa `voto <piatto o numero> <da 1 a 5> [commento]`, es. `voto 1 5 ottima!`")
+		return
+	}
+	stars, _ := strconv.Atoi(m[2])
+	me := User{user.Name, user.ID}
+
+	order := getOrder(t.brain)
+	if order.State < Delivered {
+		t.bot.Message(msg.Channel, "Potrai votare i piatti quando il pranzo sarà arrivato")
+		return
+	}
+	me = order.key(me)
+	found := findChoices(order.Users[me], m[1])
+	if len(found) != 1 {
+		t.bot.Message(msg.Channel, fmt.Sprintf("Non trovo '%s' tra i piatti che hai ordinato oggi, indicalo col suo numero", m[1]))
+		return
+	}
+	choice := order.Users[me][found[0]]
+	if len(choice.Dishes) == 0 {
+		t.bot.Message(msg.Channel, "Questo piatto non è nel menù, non si può votare")
+		return
+	}
+
+	rating := Rating{stars, strings.TrimSpace(m[3]), dayKey(order.Timestamp)}
+	var names []string
+	for _, d := range choice.Dishes {
+		if err := rate(t.brain, me, d.Content, rating); err != nil {
+			t.bot.Message(msg.Channel, "Errore nel salvare il voto: "+err.Error())
+			return
+		}
+		names = append(names, d.Content)
+	}
+	t.bot.Reply(msg, slackbot.Ephemeral, fmt.Sprintf("Grazie, hai dato %d stelle a %s", stars, strings.Join(names, " + ")))
+}
+
+// BestDishes shows the best rated dishes
+func (t *TinaBot) BestDishes(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	ratings, err := ratingRepo(t.brain).All()
+	if err != nil {
+		t.bot.Message(msg.Channel, "Errore nel leggere i voti: "+err.Error())
+		return
+	}
+	best := leaderboard(ratings, maxLeaderboard)
+	if len(best) == 0 {
+		t.bot.Message(msg.Channel, "Nessun piatto è stato ancora votato")
+		return
+	}
+	lines := []string{"I piatti migliori:"}
+	for i, r := range best {
+		lines = append(lines, fmt.Sprintf("%d. %s %s", i+1, r.Name, ratingString(r)))
+	}
+	t.bot.Message(msg.Channel, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,53 @@
+package tinabot
+
+import (
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestRatings(t *testing.T) {
+	b := brain.NewBrainMock()
+	mario := User{"mario", "U1"}
+	luigi := User{"luigi", "U2"}
+
+	rate(b, mario, "Lasagne al forno", Rating{Stars: 5, Day: "2019-03-14"})
+	rate(b, luigi, "lasagne al forno.", Rating{Stars: 3, Day: "2019-03-14"})
+	rate(b, mario, "Tagliata", Rating{Stars: 2, Day: "2019-03-14"})
+	rate(b, mario, "Tagliata", Rating{Stars: 4, Comment: "meglio", Day: "2019-03-15"})
+	rate(b, luigi, "Patate", Rating{Stars: 4, Day: "2019-03-15"})
+
+	r, err := ratingRepo(b).Get("lasagne al forno")
+	assertEqual(t, err, nil, "")
+	avg, n := r.Average()
+	assertEqual(t, avg, 4.0, "")
+	assertEqual(t, n, 2, "")
+	assertEqual(t, r.Name, "lasagne al forno.", "")
+
+	menu := tuttobene.Menu{Rows: []tuttobene.MenuRow{
+		{Content: "Tagliata", Type: tuttobene.Secondo},
+		{Content: "Pesce", Type: tuttobene.Secondo},
+	}}
+	rated := withRatings(b, menu)
+	assertEqual(t, rated.Rows[0].Content, "Tagliata ★4.0 (1)", "")
+	assertEqual(t, rated.Rows[1].Content, "Pesce", "")
+	assertEqual(t, menu.Rows[0].Content, "Tagliata", "")
+
+	all, _ := ratingRepo(b).All()
+	best := leaderboard(all, 2)
+	assertEqual(t, len(best), 2, "")
+	assertEqual(t, best[0].Name, "lasagne al forno.", "")
+	assertEqual(t, best[1].Name, "Patate", "")
+}
+
+func TestVoteRe(t *testing.T) {
+	m := voteRe.FindStringSubmatch("pasta al forno 4 buona, 3 pomodori")
+	assertEqual(t, m[1], "pasta al forno", "")
+	assertEqual(t, m[3], "buona, 3 pomodori", "")
+	m = voteRe.FindStringSubmatch("1 5 ottima")
+	assertEqual(t, m[1], "1", "")
+	assertEqual(t, m[2], "5", "")
+	assertEqual(t, m[3], "ottima", "")
+	assertEqual(t, voteRe.FindStringSubmatch("1 7") == nil, true, "")
+}
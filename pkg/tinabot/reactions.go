@@ -0,0 +1,229 @@
+package tinabot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/events"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// reactionsModeKey is set when the menu is also posted numbered, to order
+// by reacting with the number of the dish
+const reactionsModeKey = "reactions:mode"
+
+// numberEmojis are the emojis of the numbers of the dishes, a message of the
+// numbered menu holds at most as many dishes
+var numberEmojis = []string{"one", "two", "three", "four", "five", "six", "seven", "eight", "nine", "keycap_ten"}
+
+// ReactionMenu is a message of the numbered menu, the dishes in the order of
+// their numbers
+type ReactionMenu struct {
+	Day  string
+	Rows []tuttobene.MenuRow
+}
+
+// reactionMenuRepo keeps the messages of the numbered menu by
+// "<channel>:<timestamp>"
+func reactionMenuRepo(b DataStore) brain.Repo[ReactionMenu] {
+	return brain.NewRepo[ReactionMenu](b, "reactions:menu:")
+}
+
+// reactionsEnabled tells if the menu is posted numbered
+func reactionsEnabled(brain DataStore) bool {
+	var on bool
+	return brain.Get(reactionsModeKey, &on) == nil && on
+}
+
+// reactionChunks splits the dishes of menu in the messages of the numbered
+// menu
+func reactionChunks(menu tuttobene.Menu) [][]tuttobene.MenuRow {
+	var chunks [][]tuttobene.MenuRow
+	var rows []tuttobene.MenuRow
+	for _, r := range menu.Rows {
+		if r.Type == tuttobene.Empty || r.Type == tuttobene.Unknonwn {
+			continue
+		}
+		rows = append(rows, r)
+		if len(rows) == len(numberEmojis) {
+			chunks = append(chunks, rows)
+			rows = nil
+		}
+	}
+	if len(rows) > 0 {
+		chunks = append(chunks, rows)
+	}
+	return chunks
+}
+
+// reactionText returns the message of the numbered menu with rows, the
+// part-th of parts
+func reactionText(rows []tuttobene.MenuRow, part, parts int) string {
+	header := "Ordina reagendo col numero del piatto, togli la reazione per toglierlo dall'ordine"
+	if parts > 1 {
+		header += fmt.Sprintf(" (%d/%d)", part, parts)
+	}
+	lines := []string{header}
+	for i, r := range rows {
+		line := fmt.Sprintf(":%s: %s", numberEmojis[i], r.Content)
+		if r.Price.IsPositive() {
+			line += " €" + r.Price.StringFixed(2)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// reactionDish returns the dish of the numbered menu message for the emoji
+func reactionDish(m ReactionMenu, emoji string) (tuttobene.MenuRow, bool) {
+	// the skin tone of the reaction, if any, doesn't matter
+	emoji = strings.SplitN(emoji, "::", 2)[0]
+	for i, e := range numberEmojis {
+		if e == emoji && i < len(m.Rows) {
+			return m.Rows[i], true
+		}
+	}
+	return tuttobene.MenuRow{}, false
+}
+
+// postReactionMenu posts the numbered menu on channel, adding the number
+// reactions to ease ordering
+func (t *TinaBot) postReactionMenu(channel string, menu tuttobene.Menu) {
+	chunks := reactionChunks(menu)
+	for i, rows := range chunks {
+		ts, err := t.bot.Platform.SendMessage(channel, "", reactionText(rows, i+1, len(chunks)))
+		if err != nil {
+			slackbot.APIError("chat.postMessage")
+			t.logger().Error("Error posting the numbered menu", "err", err)
+			return
+		}
+		if err := reactionMenuRepo(t.brain).Put(channel+":"+ts, ReactionMenu{dayKey(menu.Date), rows}); err != nil {
+			t.logger().Error("Error saving the numbered menu", "err", err)
+			return
+		}
+		for j := range rows {
+			t.bot.Platform.AddReaction(channel, ts, numberEmojis[j])
+		}
+	}
+}
+
+// Reaction orders the dish of the numbered menu the user reacted to, or
+// removes it from her order when the reaction is removed. The replies are
+// sent in a direct message. Reactions to other messages are ignored.
+func (t *TinaBot) Reaction(channel, ts, userID, emoji string, added bool) {
+	if userID == "" || userID == t.bot.UserID {
+		return
+	}
+	m, err := reactionMenuRepo(t.brain).Get(channel + ":" + ts)
+	if err != nil {
+		return
+	}
+	row, ok := reactionDish(m, emoji)
+	if !ok {
+		return
+	}
+	im, err := t.bot.Platform.DirectChannel(userID)
+	if err != nil {
+		slackbot.APIError("conversations.open")
+		t.logger().Error("Error opening the direct message", "err", err)
+		return
+	}
+
+	t.bot.HandleInteraction(im, userID, "", func(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User) {
+		menu, ok := t.orderableMenu(msg)
+		if !ok {
+			return
+		}
+		if dayKey(menu.Date) != m.Day {
+			t.bot.Message(msg.Channel, "Quel menù non è più valido, usa quello di oggi")
+			return
+		}
+		var c UserChoice
+		c.Add(row)
+		me := User{user.Name, user.ID}
+		if added {
+			choices := append(getOrder(t.brain).choicesOf(me), c)
+			t.placeOrder(msg, user, me, "", choices, "")
+			return
+		}
+		t.removeReaction(msg, me, c)
+	})
+}
+
+// removeReaction removes the choice c from the order of me
+func (t *TinaBot) removeReaction(msg *slackbot.BotMsg, me User, c UserChoice) {
+	var order Order
+	var removed string
+	var prev UserChoiceArray
+	err := order.SaveCAS(t.brain, func(o *Order) error {
+		removed = ""
+		if err := o.Editable(); err != nil {
+			return err
+		}
+		for i, oc := range o.choicesOf(me) {
+			if oc.String() == c.String() {
+				prev = o.choicesOf(me)
+				var err error
+				removed, err = o.RemoveItem(me, i)
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.bot.Message(msg.Channel, "Errore: "+err.Error())
+		return
+	}
+	if removed == "" {
+		return
+	}
+
+	t.record(me, prev)
+	t.events.Publish(events.OrderUpdated, &order)
+	reply := fmt.Sprintf("Ok, tolto %s dal tuo ordine", removed)
+	if rest := order.Users[me]; len(rest) > 0 {
+		reply += ", ti rimane:\n" + rest.String()
+	}
+	t.bot.Message(msg.Channel, reply)
+}
+
+// ReactionsCmd shows if the menu is posted numbered to order with the
+// reactions, or turns it on and off. Turning it on posts today's menu.
+func (t *TinaBot) ReactionsCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	arg := strings.ToLower(strings.TrimSpace(args[1]))
+	switch arg {
+	case "":
+		if reactionsEnabled(t.brain) {
+			t.bot.Message(msg.Channel, "Il menù viene pubblicato anche numerato, per ordinare con le reazioni")
+		} else {
+			t.bot.Message(msg.Channel, "Ordine con le reazioni disattivato")
+		}
+		return
+	case "on", "off":
+	default:
+		t.bot.Message(msg.Channel, "Comando non valido, usa `reazioni on` o `reazioni off`")
+		return
+	}
+	if !t.canAdmin(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono cambiare il modo di ordinare")
+		return
+	}
+	if err := t.brain.Set(reactionsModeKey, arg == "on"); err != nil {
+		t.bot.Message(msg.Channel, "Errore nel salvare l'impostazione: "+err.Error())
+		return
+	}
+	if arg == "off" {
+		t.bot.Message(msg.Channel, "Ok, ordine con le reazioni disattivato")
+		return
+	}
+	t.bot.Message(msg.Channel, "Ok, da ora il menù viene pubblicato anche numerato, per ordinare con le reazioni")
+	if isDirect(msg) {
+		return
+	}
+	if menu, err := todayMenu(t.brain); err == nil && menu.IsUpdated() {
+		t.postReactionMenu(msg.Channel, menu)
+	}
+}
@@ -0,0 +1,41 @@
+package tinabot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestReactionMenu(t *testing.T) {
+	menu := tuttobene.Menu{Rows: []tuttobene.MenuRow{{Content: "Primi", Type: tuttobene.Empty}}}
+	for i := 0; i < 12; i++ {
+		menu.Rows = append(menu.Rows, tuttobene.MenuRow{Content: fmt.Sprintf("Piatto %d", i+1), Type: tuttobene.Primo})
+	}
+	menu.Rows[1].Price = decimal.New(5, 0)
+
+	chunks := reactionChunks(menu)
+	assertEqual(t, len(chunks), 2, "")
+	assertEqual(t, len(chunks[0]), 10, "")
+	assertEqual(t, len(chunks[1]), 2, "")
+
+	text := reactionText(chunks[0], 1, 2)
+	assertEqual(t, strings.Contains(text, "(1/2)"), true, "")
+	assertEqual(t, strings.Contains(text, ":one: Piatto 1 €5.00\n:two: Piatto 2\n"), true, "")
+	assertEqual(t, strings.Contains(text, ":keycap_ten: Piatto 10"), true, "")
+	assertEqual(t, strings.Contains(reactionText(chunks[1], 1, 1), "(1/1)"), false, "")
+
+	m := ReactionMenu{"2019-03-14", chunks[1]}
+	row, ok := reactionDish(m, "two")
+	assertEqual(t, ok, true, "")
+	assertEqual(t, row.Content, "Piatto 12", "")
+	row, ok = reactionDish(m, "one::skin-tone-2")
+	assertEqual(t, row.Content, "Piatto 11", "")
+	_, ok = reactionDish(m, "three")
+	assertEqual(t, ok, false, "")
+	_, ok = reactionDish(m, "pizza")
+	assertEqual(t, ok, false, "")
+}
@@ -1,68 +1,94 @@
 package tinabot
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-redis/redis"
-	"github.com/nlopes/slack"
 
+	"github.com/develersrl/lunches/pkg/chat"
 	"github.com/develersrl/lunches/pkg/slackbot"
 )
 
-func formatReminder(mask int) string {
-	weekNames := []string{
-		"domenica",
-		"lunedì",
-		"martedì",
-		"mercoledì",
-		"giovedì",
-		"venerdì",
-		"sabato",
-	}
+// weekMask maps the (prefix of the) week day names to the bits of a week mask
+var weekMask = map[string]int{
+	"off": 0,
+	"dis": 0,
+	"fal": 0,
+	"0":   0,
+	"on":  0xff,
+	"ena": 0xff,
+	"tru": 0xff,
+	"1":   0xff,
+	"sem": 0xff,
+	"tut": 0xff,
+	"all": 0xff,
 
-	if mask == 0 {
-		return "Reminder disattivato"
+	"dom": 1 << 0,
+	"lun": 1 << 1,
+	"mar": 1 << 2,
+	"mer": 1 << 3,
+	"gio": 1 << 4,
+	"ven": 1 << 5,
+	"sab": 1 << 6,
+}
+
+// parseWeekMask parses a comma separated list of week days, returns false
+// if none is recognized
+func parseWeekMask(arg string) (int, bool) {
+	mask := 0
+	found := false
+	for _, d := range strings.Split(strings.ToLower(arg), ",") {
+		d = strings.TrimSpace(d)
+		if len(d) > 3 {
+			d = d[:3]
+		}
+
+		if m, ok := weekMask[d]; ok {
+			mask |= m
+			found = true
+		}
 	}
+	return mask, found
+}
 
-	reply := "Reminder attivo "
+var weekNames = []string{
+	"domenica",
+	"lunedì",
+	"martedì",
+	"mercoledì",
+	"giovedì",
+	"venerdì",
+	"sabato",
+}
+
+// formatWeekDays returns the names of the days in the week mask
+func formatWeekDays(mask int) string {
 	if mask == 0xff {
-		reply += "tutti i giorni"
-	} else {
-		var days []string
-		for i := uint(0); i < 7; i++ {
-			if ((1 << i) & mask) != 0 {
-				days = append(days, weekNames[i])
-			}
+		return "tutti i giorni"
+	}
+	var days []string
+	for i := uint(0); i < 7; i++ {
+		if ((1 << i) & mask) != 0 {
+			days = append(days, weekNames[i])
 		}
-		reply += strings.Join(days, ", ")
 	}
-	return reply
+	return strings.Join(days, ", ")
 }
 
-func (t *TinaBot) Remind(bot *slackbot.Bot, msg *slackbot.BotMsg, user *slack.User, args ...string) {
-	weekMask := map[string]int{
-		"off": 0,
-		"dis": 0,
-		"fal": 0,
-		"0":   0,
-		"on":  0xff,
-		"ena": 0xff,
-		"tru": 0xff,
-		"1":   0xff,
-		"sem": 0xff,
-		"tut": 0xff,
-		"all": 0xff,
-
-		"dom": 1 << 0,
-		"lun": 1 << 1,
-		"mar": 1 << 2,
-		"mer": 1 << 3,
-		"gio": 1 << 4,
-		"ven": 1 << 5,
-		"sab": 1 << 6,
+func formatReminder(mask int) string {
+	if mask == 0 {
+		return "Reminder disattivato"
 	}
 
-	if args[1] == "" {
+	return "Reminder attivo " + formatWeekDays(mask)
+}
+
+func (t *TinaBot) Remind(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+
+	if strings.TrimSpace(args[1]) == "" {
 		var remind map[string]int
 		err := t.brain.Get("remind", &remind)
 		if err == redis.Nil || len(remind) == 0 {
@@ -75,23 +101,26 @@ func (t *TinaBot) Remind(bot *slackbot.Bot, msg *slackbot.BotMsg, user *slack.Us
 			}
 		}
 
-	} else {
-		arg := strings.ToLower(args[1])
-		mask := 0
-
-		cmdFound := false
-		for _, d := range strings.Split(arg, ",") {
-			d = strings.TrimSpace(d)
-			if len(d) > 3 {
-				d = d[:3]
-			}
-
-			if m, ok := weekMask[d]; ok {
-				mask |= m
-				cmdFound = true
+	} else if f := strings.Fields(strings.ToLower(args[1])); f[0] == "rimanda" || f[0] == "snooze" {
+		minutes := 15
+		if len(f) > 1 {
+			n, err := strconv.Atoi(f[1])
+			if err != nil || n <= 0 {
+				bot.Message(msg.Channel, "Numero di minuti non valido")
+				return
 			}
+			minutes = n
 		}
 
+		var snoozes Snoozes
+		snoozes.Load(t.brain)
+		until := time.Now().Add(time.Duration(minutes) * time.Minute)
+		snoozes[user.ID] = until
+		snoozes.Save(t.brain)
+
+		bot.Message(msg.Channel, fmt.Sprintf("Ok, te lo ricordo di nuovo tra %d minuti", minutes))
+	} else {
+		mask, cmdFound := parseWeekMask(args[1])
 		if !cmdFound {
 			bot.Message(msg.Channel, "Mi spiace, ma non ho capito cosa mi stai chiedendo di ricordare")
 			return
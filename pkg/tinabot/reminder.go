@@ -0,0 +1,203 @@
+package tinabot
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/config"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// ReminderSettings configures the reminders sent before the order deadline
+type ReminderSettings struct {
+	Time       string // "15:04" when the users who haven't ordered yet get a message
+	Deadline   string // "15:04" of the order deadline
+	Countdowns []int  // minutes before the deadline to post a countdown on Channel
+	Channel    string
+
+	// inherited is the deadline of the configuration, used when the office
+	// has none, not to be saved as the one of the office
+	inherited string
+}
+
+// Load loads the settings from brain, no reminders are set if missing. The
+// deadline is the one of the configuration if the office has none.
+func (s *ReminderSettings) Load(brain DataStore) error {
+	err := brain.Get("remind:settings", s)
+	if err != nil {
+		*s = ReminderSettings{}
+	}
+	if s.Deadline == "" {
+		s.Deadline = config.Current().Deadline
+		s.inherited = s.Deadline
+	}
+	return err
+}
+
+// Save saves the settings to brain
+func (s *ReminderSettings) Save(brain DataStore) error {
+	saved := *s
+	if saved.Deadline == s.inherited {
+		saved.Deadline = ""
+	}
+	return brain.Set("remind:settings", saved)
+}
+
+// at returns the time of day hhmm in the day of now
+func at(now time.Time, hhmm string) (time.Time, bool) {
+	t, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return time.Time{}, false
+	}
+	y, m, d := now.Date()
+	return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, now.Location()), true
+}
+
+// inWindow returns true if t falls in the interval centered on now, like the crons
+func inWindow(t, now time.Time, interval time.Duration) bool {
+	start := now.Add(-interval / 2)
+	return !t.Before(start) && t.Before(start.Add(interval))
+}
+
+// Due tells if the personal reminders have to be sent in the interval
+// centered on now, and which countdowns have to be posted
+func (s *ReminderSettings) Due(now time.Time, interval time.Duration) (bool, []int) {
+	dm := false
+	if t, ok := at(now, s.Time); ok {
+		dm = inWindow(t, now, interval)
+	}
+
+	var countdowns []int
+	if deadline, ok := at(now, s.Deadline); ok {
+		for _, m := range s.Countdowns {
+			if inWindow(deadline.Add(-time.Duration(m)*time.Minute), now, interval) {
+				countdowns = append(countdowns, m)
+			}
+		}
+	}
+	return dm, countdowns
+}
+
+func (s *ReminderSettings) String() string {
+	if s.Time == "" && s.Deadline == "" {
+		return "Nessun promemoria automatico impostato"
+	}
+
+	var r []string
+	if s.Time != "" {
+		r = append(r, "Reminder personali alle "+s.Time)
+	}
+	if s.Deadline != "" {
+		r = append(r, "Scadenza ordini alle "+s.Deadline)
+	}
+	if len(s.Countdowns) > 0 && s.Channel != "" {
+		var c []string
+		for _, m := range s.Countdowns {
+			c = append(c, strconv.Itoa(m))
+		}
+		r = append(r, fmt.Sprintf("Conto alla rovescia in <#%s> a %s minuti dalla scadenza", s.Channel, strings.Join(c, ", ")))
+	}
+	return strings.Join(r, "\n")
+}
+
+// Snoozes maps the user ID to the time the reminder has been postponed to
+type Snoozes map[string]time.Time
+
+// Load loads the snoozes from brain
+func (s *Snoozes) Load(brain DataStore) error {
+	if err := brain.Get("remind:snooze", s); err != nil {
+		*s = make(Snoozes)
+		return err
+	}
+	return nil
+}
+
+// Save saves the snoozes to brain
+func (s Snoozes) Save(brain DataStore) error {
+	return brain.Set("remind:snooze", s)
+}
+
+// ReminderRecipients returns the IDs of the users to remind in the interval
+// centered on now: the ones with the reminder active today if dmDue and not
+// snoozed, plus the ones whose snooze expires now.
+func ReminderRecipients(remind map[string]int, snoozes Snoozes, dmDue bool, now time.Time, interval time.Duration) []string {
+	weekmask := 1 << uint(now.Weekday())
+
+	var users []string
+	for id, mask := range remind {
+		if mask&weekmask == 0 {
+			continue
+		}
+		until, snoozed := snoozes[id]
+		if snoozed && until.Year() == now.Year() && until.YearDay() == now.YearDay() {
+			if inWindow(until, now, interval) {
+				users = append(users, id)
+			}
+			continue
+		}
+		if dmDue {
+			users = append(users, id)
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+// ReminderCmd shows or changes the automatic reminders settings
+func (t *TinaBot) ReminderCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	var s ReminderSettings
+	s.Load(t.brain)
+
+	f := strings.Fields(args[1])
+	if len(f) == 0 {
+		t.bot.Message(msg.Channel, s.String())
+		return
+	}
+
+	switch strings.ToLower(f[0]) {
+	case "ore", "scadenza":
+		if len(f) < 2 {
+			t.bot.Message(msg.Channel, "Argomenti insufficienti!")
+			return
+		}
+		if _, err := time.Parse("15:04", f[1]); err != nil && f[1] != "off" {
+			t.bot.Message(msg.Channel, "Orario non valido, usa il formato hh:mm")
+			return
+		}
+		hhmm := f[1]
+		if hhmm == "off" {
+			hhmm = ""
+		}
+		if strings.ToLower(f[0]) == "ore" {
+			s.Time = hhmm
+		} else {
+			s.Deadline = hhmm
+		}
+	case "countdown":
+		s.Countdowns = nil
+		if len(f) > 1 && f[1] != "off" {
+			for _, m := range strings.Split(f[1], ",") {
+				n, err := strconv.Atoi(strings.TrimSpace(m))
+				if err != nil || n <= 0 {
+					t.bot.Message(msg.Channel, fmt.Sprintf("Minuti '%s' non validi", m))
+					return
+				}
+				s.Countdowns = append(s.Countdowns, n)
+			}
+		}
+		s.Channel = msg.Channel
+	default:
+		t.bot.Message(msg.Channel, "Comando non valido, usa `ore`, `scadenza` o `countdown`")
+		return
+	}
+
+	if err := s.Save(t.brain); err != nil {
+		t.bot.Message(msg.Channel, "Errore: "+err.Error())
+		return
+	}
+	t.bot.Message(msg.Channel, "Ok\n"+s.String())
+}
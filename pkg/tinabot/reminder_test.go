@@ -0,0 +1,51 @@
+package tinabot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestReminderDue(t *testing.T) {
+	s := ReminderSettings{Time: "11:50", Deadline: "12:00", Countdowns: []int{30, 10}}
+	day := time.Date(2019, 3, 15, 0, 0, 0, 0, time.UTC)
+	interval := 10 * time.Minute
+
+	dm, c := s.Due(day.Add(11*time.Hour+30*time.Minute), interval)
+	assertEqual(t, dm, false, "")
+	assertEqual(t, fmt.Sprint(c), "[30]", "")
+
+	dm, c = s.Due(day.Add(11*time.Hour+52*time.Minute), interval)
+	assertEqual(t, dm, true, "")
+	assertEqual(t, fmt.Sprint(c), "[10]", "")
+
+	dm, c = s.Due(day.Add(14*time.Hour), interval)
+	assertEqual(t, dm, false, "")
+	assertEqual(t, len(c), 0, "")
+
+	var empty ReminderSettings
+	dm, c = empty.Due(day.Add(11*time.Hour+50*time.Minute), interval)
+	assertEqual(t, dm, false, "")
+	assertEqual(t, len(c), 0, "")
+}
+
+func TestReminderRecipients(t *testing.T) {
+	friday := time.Date(2019, 3, 15, 11, 50, 0, 0, time.UTC)
+	interval := 10 * time.Minute
+	remind := map[string]int{
+		"always":  0xff,
+		"mondays": 1 << 1,
+		"snoozed": 0xff,
+		"old":     0xff,
+	}
+	snoozes := Snoozes{
+		"snoozed": friday.Add(20 * time.Minute),
+		"old":     friday.AddDate(0, 0, -1),
+	}
+
+	users := ReminderRecipients(remind, snoozes, true, friday, interval)
+	assertEqual(t, fmt.Sprint(users), "[always old]", "")
+
+	users = ReminderRecipients(remind, snoozes, false, friday.Add(20*time.Minute), interval)
+	assertEqual(t, fmt.Sprint(users), "[snoozed]", "")
+}
@@ -0,0 +1,105 @@
+package tinabot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/events"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// findChoices returns the indexes of the choices matching dish, which can
+// also be the 1-based position of the choice
+func findChoices(choices UserChoiceArray, dish string) []int {
+	dish = strings.TrimSpace(dish)
+	if n, err := strconv.Atoi(dish); err == nil {
+		if n < 1 || n > len(choices) {
+			return nil
+		}
+		return []int{n - 1}
+	}
+
+	var found []int
+	for i, c := range choices {
+		if strings.EqualFold(c.String(), dish) {
+			return []int{i}
+		}
+		if fuzzyMatch(dish, c.String()) {
+			found = append(found, i)
+		}
+	}
+	return found
+}
+
+// removeChoices returns choices without the ones matching dishes, each dish
+// has to match a single choice, or identical ones
+func removeChoices(choices UserChoiceArray, dishes []string) (UserChoiceArray, error) {
+	rest := append(UserChoiceArray(nil), choices...)
+	for _, d := range dishes {
+		found := findChoices(rest, d)
+		if len(found) == 0 {
+			return nil, fmt.Errorf("Non trovo '%s' nel tuo ordine", d)
+		}
+		for _, i := range found[1:] {
+			if rest[i].String() != rest[found[0]].String() {
+				return nil, fmt.Errorf("Nel tuo ordine ci sono più piatti che corrispondono a '%s'", d)
+			}
+		}
+		rest = append(rest[:found[0]], rest[found[0]+1:]...)
+	}
+	return rest, nil
+}
+
+// Remove drops a single dish from the user order, keeping the others
+func (t *TinaBot) Remove(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	dish := sanitize(args[1])
+	me := User{user.Name, user.ID}
+
+	var order Order
+	var removed string
+	var matches int
+	var prev UserChoiceArray
+	err := order.SaveCAS(t.brain, func(o *Order) error {
+		removed = ""
+		if err := o.Editable(); err != nil {
+			return err
+		}
+		found := findChoices(o.choicesOf(me), dish)
+		matches = len(found)
+		if matches != 1 {
+			return nil
+		}
+
+		prev = o.choicesOf(me)
+		var err error
+		removed, err = o.RemoveItem(me, found[0])
+		return err
+	})
+	if err != nil {
+		t.bot.Message(msg.Channel, "Errore: "+err.Error())
+		return
+	}
+
+	if removed == "" {
+		if matches == 0 {
+			t.bot.Message(msg.Channel, fmt.Sprintf("Non hai ordinato nulla che corrisponda a '%s'", dish))
+			return
+		}
+		list := ""
+		for i, c := range order.choicesOf(me) {
+			list += fmt.Sprintf("%d. %s\n", i+1, c.String())
+		}
+		t.bot.Message(msg.Channel, fmt.Sprintf("Cercando per '%s' ho trovato più piatti nel tuo ordine:\n%sIndica il piatto da togliere col suo numero, es. `togli 1`", dish, list))
+		return
+	}
+
+	t.record(me, prev)
+	t.events.Publish(events.OrderUpdated, &order)
+	reply := fmt.Sprintf("Ok, tolto %s dall'ordine di %s", removed, me.Name)
+	if rest := order.Users[me]; len(rest) > 0 {
+		reply += ", ti rimane:\n" + rest.String()
+	}
+	t.bot.Message(msg.Channel, reply)
+}
@@ -0,0 +1,56 @@
+package tinabot
+
+import (
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/report"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// parseFormat takes the flag of the format of a report out of the fields of
+// a command, e.g. "--csv"; the format is empty without it, and the flag is
+// false if it's not a known format
+func parseFormat(f []string) (report.Format, []string, bool) {
+	var format report.Format
+	var rest []string
+	for _, s := range f {
+		if !strings.HasPrefix(s, "--") {
+			rest = append(rest, s)
+			continue
+		}
+		var ok bool
+		if format, ok = report.ParseFormat(s); !ok {
+			return "", rest, false
+		}
+	}
+	return format, rest, true
+}
+
+// formatFlags lists the flags of the formats of the reports, for the errors
+func formatFlags() string {
+	flags := make([]string, len(report.Formats))
+	for i, f := range report.Formats {
+		flags[i] = "`--" + string(f) + "`"
+	}
+	return strings.Join(flags, ", ")
+}
+
+// postReport renders the report in the format and posts it on the channel of
+// msg, as a message or as a file
+func (t *TinaBot) postReport(msg *slackbot.BotMsg, r report.Report, f report.Format) {
+	out, err := report.Render(r, f)
+	if err != nil {
+		t.bot.Message(msg.Channel, "Errore nel preparare il report: "+err.Error())
+		return
+	}
+	if out.File == nil {
+		_, err = t.bot.Platform.SendMessage(msg.Channel, "", out.Text, out.Blocks...)
+	} else {
+		f := chat.File{Name: out.Filename, Type: out.Filetype, Title: r.Title}
+		err = t.bot.Platform.UploadFile(msg.Channel, f, out.File)
+	}
+	if err != nil {
+		t.bot.Message(msg.Channel, "Errore nel pubblicare il report: "+err.Error())
+	}
+}
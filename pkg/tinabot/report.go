@@ -0,0 +1,256 @@
+package tinabot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/analytics"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/report"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// reportTime is when the weekly reports are sent on Monday
+const reportTime = "09:00"
+
+// how many users and days the reports list
+const (
+	reportTopUsers = 10
+	reportTopDays  = 3
+)
+
+// ReportSettings configures the report of the spending of the previous week
+// posted on Channel every Monday morning
+type ReportSettings struct {
+	Channel string
+
+	// the Monday of the last week reported
+	Sent string `json:",omitempty"`
+}
+
+// Load loads the settings from brain, no report is posted if missing
+func (s *ReportSettings) Load(brain DataStore) error {
+	if err := brain.Get("report", s); err != nil {
+		*s = ReportSettings{}
+		return err
+	}
+	return nil
+}
+
+// Save saves the settings to brain
+func (s *ReportSettings) Save(brain DataStore) error {
+	return brain.Set("report", *s)
+}
+
+// Due returns the previous week if its report is to be posted at now: from
+// reportTime on Monday, or on the following days if it was skipped
+func (s *ReportSettings) Due(now time.Time) (analytics.Period, bool) {
+	if s.Channel == "" {
+		return analytics.Period{}, false
+	}
+	return weeklyDue(now, s.Sent)
+}
+
+// weeklyDue returns the previous week if it's reportTime on Monday or later
+// and the week is not the one sent, the dayKey of its Monday
+func weeklyDue(now time.Time, sent string) (analytics.Period, bool) {
+	week := analytics.Week(now)
+	from, _ := at(week.Start, reportTime)
+	prev := analytics.Week(week.Start.AddDate(0, 0, -1))
+	return prev, !now.Before(from) && sent != dayKey(prev.Start)
+}
+
+func (s *ReportSettings) String() string {
+	if s.Channel == "" {
+		return "Nessun report settimanale automatico impostato"
+	}
+	return fmt.Sprintf("Il report della settimana precedente viene pubblicato su <#%s> il lunedì dalle %s", s.Channel, reportTime)
+}
+
+// Spending returns the report of the spending in the period, from the
+// history of the orders, with the cost centers of the accounting as teams
+func Spending(brain DataStore, period analytics.Period) (analytics.Report, error) {
+	var a Accounting
+	a.Load(brain)
+	rows, err := a.rows(brain, period)
+	if err != nil {
+		return analytics.Report{}, err
+	}
+	lunches := make([]analytics.Lunch, len(rows))
+	for i, r := range rows {
+		lunches[i] = analytics.Lunch{Date: r.Date, User: userKey(r.User), Name: r.User.Name, Team: r.Center, Amount: r.Amount}
+	}
+	return analytics.New(lunches, period), nil
+}
+
+// FormatSpending returns the report as a message, the spending of each user
+// only with names
+func FormatSpending(r analytics.Report, names bool) string {
+	title := fmt.Sprintf("*Spesa dal %s al %s*", r.Start.Format("02/01"), r.End.AddDate(0, 0, -1).Format("02/01/2006"))
+	if r.Orders == 0 {
+		return title + "\nNessun ordine nel periodo"
+	}
+	out := []string{fmt.Sprintf("%s\nTotale €%s, %s, in media €%s a ordine", title, r.Total.StringFixed(2), count(r.Orders, "ordine", "ordini"), r.Average().StringFixed(2))}
+
+	if len(r.Teams) > 1 {
+		lines := []string{"*Per centro di costo:*"}
+		for _, s := range r.Teams {
+			lines = append(lines, formatSpend(s))
+		}
+		out = append(out, strings.Join(lines, "\n"))
+	}
+
+	if names {
+		lines := []string{"*Per persona:*"}
+		for i, s := range r.Users {
+			if i == reportTopUsers {
+				lines = append(lines, fmt.Sprintf("e altre %d persone", len(r.Users)-i))
+				break
+			}
+			lines = append(lines, formatSpend(s))
+		}
+		out = append(out, strings.Join(lines, "\n"))
+	}
+
+	lines := []string{"*I giorni più cari:*"}
+	for i, d := range r.Days {
+		if i == reportTopDays {
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%s %s: €%s, %s", weekNames[d.Date.Weekday()], d.Date.Format("02/01"), d.Total.StringFixed(2), count(d.Orders, "ordine", "ordini")))
+	}
+	out = append(out, strings.Join(lines, "\n"))
+	return strings.Join(out, "\n\n")
+}
+
+// SpendingReport returns the report to render in the other formats, with
+// all the users only with names and all the days
+func SpendingReport(r analytics.Report, names bool) report.Report {
+	rep := report.Report{
+		Name:  "spesa-" + dayKey(r.Start),
+		Title: fmt.Sprintf("Spesa dal %s al %s", r.Start.Format("02/01"), r.End.AddDate(0, 0, -1).Format("02/01/2006")),
+	}
+	if r.Orders == 0 {
+		rep.Summary = []string{"Nessun ordine nel periodo"}
+		return rep
+	}
+	rep.Summary = []string{fmt.Sprintf("Totale €%s, %s, in media €%s a ordine", r.Total.StringFixed(2), count(r.Orders, "ordine", "ordini"), r.Average().StringFixed(2))}
+
+	spends := func(title, column string, spends []analytics.Spend) report.Table {
+		t := report.Table{Title: title, Columns: []string{column, "Ordini", "Media", "Totale"}}
+		for _, s := range spends {
+			total, _ := s.Total.Float64()
+			t.Rows = append(t.Rows, report.Row{
+				Cells: []string{s.Name, fmt.Sprint(s.Orders), "€" + s.Average().StringFixed(2), "€" + s.Total.StringFixed(2)},
+				Value: total,
+			})
+		}
+		return t
+	}
+	if len(r.Teams) > 1 {
+		rep.Tables = append(rep.Tables, spends("Per centro di costo", "Centro di costo", r.Teams))
+	}
+	if names {
+		rep.Tables = append(rep.Tables, spends("Per persona", "Nome", r.Users))
+	}
+	days := report.Table{Title: "I giorni più cari", Columns: []string{"Giorno", "Ordini", "Totale"}}
+	for _, d := range r.Days {
+		total, _ := d.Total.Float64()
+		days.Rows = append(days.Rows, report.Row{
+			Cells: []string{formatDay(d.Date), fmt.Sprint(d.Orders), "€" + d.Total.StringFixed(2)},
+			Value: total,
+		})
+	}
+	rep.Tables = append(rep.Tables, days)
+	return rep
+}
+
+func formatSpend(s analytics.Spend) string {
+	return fmt.Sprintf("%s: €%s, %s, in media €%s", s.Name, s.Total.StringFixed(2), count(s.Orders, "ordine", "ordini"), s.Average().StringFixed(2))
+}
+
+// count returns n with the singular or the plural noun
+func count(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
+
+// ReportCmd shows the spending of the current or the previous week or
+// month, in the format of the flag if any, or turns on and off the weekly
+// report on the channel
+func (t *TinaBot) ReportCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	format, f, ok := parseFormat(strings.Fields(strings.ToLower(args[1])))
+	if !ok {
+		t.bot.Message(msg.Channel, "Formato non valido, usa "+formatFlags())
+		return
+	}
+	if len(f) == 0 {
+		var s ReportSettings
+		s.Load(t.brain)
+		t.bot.Message(msg.Channel, s.String())
+		return
+	}
+
+	now := time.Now()
+	var period analytics.Period
+	switch f[0] {
+	case "settimanale":
+		period = analytics.Week(now)
+		if len(f) > 1 && strings.HasPrefix(f[1], "scors") {
+			period = analytics.Week(period.Start.AddDate(0, 0, -1))
+		}
+	case "mensile":
+		period = analytics.Month(now)
+		if len(f) > 1 && strings.HasPrefix(f[1], "scors") {
+			period = analytics.Month(period.Start.AddDate(0, 0, -1))
+		}
+	case "automatico":
+		t.reportAuto(msg, user, f[1:])
+		return
+	default:
+		t.bot.Message(msg.Channel, "Comando non valido, usa `settimanale`, `mensile` o `automatico`")
+		return
+	}
+
+	r, err := Spending(t.brain, period)
+	if err != nil {
+		t.bot.Message(msg.Channel, "Errore nel calcolare la spesa: "+err.Error())
+		return
+	}
+	if format != "" {
+		t.postReport(msg, SpendingReport(r, t.showNames(msg, user)), format)
+	} else {
+		t.bot.Message(msg.Channel, FormatSpending(r, t.showNames(msg, user)))
+	}
+	if s, ok := r.User(user.ID); ok {
+		s.Name = "La tua spesa"
+		t.bot.Reply(msg, slackbot.Ephemeral, formatSpend(s))
+	}
+}
+
+// reportAuto turns on the weekly report on the channel, or off
+func (t *TinaBot) reportAuto(msg *slackbot.BotMsg, user *chat.User, f []string) {
+	if !t.canAdmin(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono impostare il report settimanale")
+		return
+	}
+	var s ReportSettings
+	s.Load(t.brain)
+	switch {
+	case len(f) == 1 && f[0] == "on":
+		s.Channel = msg.Channel
+	case len(f) == 1 && f[0] == "off":
+		s.Channel = ""
+	default:
+		t.bot.Message(msg.Channel, "Comando non valido, usa `report automatico on|off`")
+		return
+	}
+	if err := s.Save(t.brain); err != nil {
+		t.bot.Message(msg.Channel, "Errore: "+err.Error())
+		return
+	}
+	t.bot.Message(msg.Channel, "Ok\n"+s.String())
+}
@@ -0,0 +1,88 @@
+package tinabot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/develersrl/lunches/pkg/analytics"
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/report"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestReportDue(t *testing.T) {
+	monday := time.Date(2019, 3, 18, 8, 0, 0, 0, time.UTC)
+	var s ReportSettings
+	_, due := s.Due(monday.Add(2 * time.Hour))
+	assertEqual(t, due, false, "no channel")
+
+	s.Channel = "C1"
+	_, due = s.Due(monday)
+	assertEqual(t, due, false, "too early")
+	week, due := s.Due(monday.Add(2 * time.Hour))
+	assertEqual(t, due, true, "")
+	assertEqual(t, dayKey(week.Start), "2019-03-11", "")
+
+	s.Sent = "2019-03-11"
+	_, due = s.Due(monday.AddDate(0, 0, 1))
+	assertEqual(t, due, false, "already sent")
+	week, due = s.Due(monday.AddDate(0, 0, 7).Add(time.Hour))
+	assertEqual(t, due, true, "")
+	assertEqual(t, dayKey(week.Start), "2019-03-18", "")
+}
+
+func TestSpending(t *testing.T) {
+	b := brain.NewBrainMock()
+	var p, s UserChoice
+	p.Add(tuttobene.MenuRow{Content: "primo", Type: tuttobene.Primo, Price: decimal.New(5, 0)})
+	s.Add(tuttobene.MenuRow{Content: "secondo", Type: tuttobene.Secondo, Price: decimal.New(7, 0)})
+
+	order := NewOrder()
+	order.Timestamp = time.Date(2019, 3, 12, 13, 0, 0, 0, time.UTC)
+	order.Set(User{"mario", "U1"}, []UserChoice{p, s})
+	order.Set(User{"luigi", "U2"}, []UserChoice{p})
+	assertEqual(t, SaveHistory(b, order), nil, "")
+
+	a := Accounting{Centers: map[string]CostCenter{"U1": {"Mario Rossi", "R&D"}, "U2": {"Luigi Verdi", "Vendite"}}}
+	assertEqual(t, a.Save(b), nil, "")
+
+	r, err := Spending(b, analytics.Week(order.Timestamp))
+	assertEqual(t, err, nil, "")
+	assertEqual(t, r.Total.String(), "17", "")
+	assertEqual(t, len(r.Teams), 2, "")
+
+	msg := FormatSpending(r, false)
+	assertEqual(t, strings.Contains(msg, "*Spesa dal 11/03 al 17/03/2019*\nTotale €17.00, 2 ordini, in media €8.50 a ordine"), true, msg)
+	assertEqual(t, strings.Contains(msg, "R&D: €12.00, 1 ordine"), true, msg)
+	assertEqual(t, strings.Contains(msg, "Mario Rossi"), false, msg)
+	assertEqual(t, strings.Contains(msg, "martedì 12/03: €17.00, 2 ordini"), true, msg)
+	assertEqual(t, strings.Contains(FormatSpending(r, true), "Mario Rossi: €12.00"), true, "")
+
+	rep := SpendingReport(r, false)
+	assertEqual(t, rep.Name, "spesa-2019-03-11", "")
+	assertEqual(t, len(rep.Tables), 2, "no users without names")
+	assertEqual(t, strings.Join(rep.Tables[0].Rows[0].Cells, ","), "R&D,1,€12.00,€12.00", "")
+	assertEqual(t, rep.Tables[0].Rows[0].Value, 12.0, "")
+	assertEqual(t, rep.Tables[1].Rows[0].Cells[0], "martedì 12/03", "")
+	assertEqual(t, len(SpendingReport(r, true).Tables), 3, "")
+
+	r, _ = Spending(b, analytics.Week(order.Timestamp.AddDate(0, 0, 7)))
+	assertEqual(t, strings.HasSuffix(FormatSpending(r, true), "Nessun ordine nel periodo"), true, "")
+}
+
+func TestParseFormat(t *testing.T) {
+	format, rest, ok := parseFormat([]string{"mensile", "--CSV", "scorso"})
+	assertEqual(t, ok, true, "")
+	assertEqual(t, format, report.CSV, "")
+	assertEqual(t, strings.Join(rest, " "), "mensile scorso", "")
+
+	format, _, ok = parseFormat([]string{"mensile"})
+	assertEqual(t, ok, true, "")
+	assertEqual(t, format, report.Format(""), "")
+
+	_, _, ok = parseFormat([]string{"--pdf"})
+	assertEqual(t, ok, false, "")
+}
@@ -0,0 +1,56 @@
+package tinabot
+
+import (
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/matcher"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// The data kept per user is stored by userKey, the one kept per day by dayKey
+
+func dietRepo(b DataStore) brain.Repo[DietProfile] {
+	return brain.NewRepo[DietProfile](b, "diet:")
+}
+
+// nutritionRepo keeps the users who asked for the weekly nutrition estimates
+func nutritionRepo(b DataStore) brain.Repo[NutritionUser] {
+	return brain.NewRepo[NutritionUser](b, "nutrition:user:")
+}
+
+func journalRepo(b DataStore) brain.Repo[Journal] {
+	return brain.NewRepo[Journal](b, "journal:")
+}
+
+func pendingMatchesRepo(b DataStore) brain.Repo[[]matcher.Ambiguous] {
+	return brain.NewRepo[[]matcher.Ambiguous](b, "matcher:pending:")
+}
+
+// historyRepo keeps the choices of the users by "<userKey>:<dayKey>"
+func historyRepo(b DataStore) brain.Repo[UserChoiceArray] {
+	return brain.NewRepo[UserChoiceArray](b, "orders:history:")
+}
+
+// nameRepo keeps the last name of the users in the history, by userKey
+func nameRepo(b DataStore) brain.Repo[string] {
+	return brain.NewRepo[string](b, "orders:name:")
+}
+
+// firstRepo keeps who ordered first each day, by dayKey
+func firstRepo(b DataStore) brain.Repo[User] {
+	return brain.NewRepo[User](b, "orders:first:")
+}
+
+func menuRepo(b DataStore) brain.Repo[tuttobene.Menu] {
+	return brain.NewRepo[tuttobene.Menu](b, "menu:")
+}
+
+// advanceRepo keeps the orders placed in advance, the other keys under
+// "order:" are not orders so All must not be used
+func advanceRepo(b DataStore) brain.Repo[Order] {
+	return brain.NewRepo[Order](b, "order:")
+}
+
+// selectionRepo keeps the dishes picked in the interactive menu by userKey
+func selectionRepo(b DataStore) brain.Repo[Selection] {
+	return brain.NewRepo[Selection](b, "selection:")
+}
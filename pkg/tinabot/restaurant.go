@@ -0,0 +1,129 @@
+package tinabot
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/config"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// RestaurantInfo are the delivery details added to the email for the restaurant
+type RestaurantInfo struct {
+	DeliveryTime string
+	Address      string
+}
+
+// RestaurantInfoFromConfig returns the delivery details of the
+// configuration, shared by all the offices
+func RestaurantInfoFromConfig() RestaurantInfo {
+	c := config.Current()
+	return RestaurantInfo{
+		DeliveryTime: c.DeliveryTime,
+		Address:      c.OfficeAddress,
+	}
+}
+
+// restaurantKey holds the delivery details set with the ristorante command,
+// overriding the environment, e.g. for each office
+const restaurantKey = "restaurant"
+
+// LoadRestaurantInfo returns the delivery details stored in brain, the ones
+// of the configuration for the details not set
+func LoadRestaurantInfo(brain DataStore) RestaurantInfo {
+	info := RestaurantInfoFromConfig()
+	var stored RestaurantInfo
+	if brain.Get(restaurantKey, &stored) == nil {
+		if stored.DeliveryTime != "" {
+			info.DeliveryTime = stored.DeliveryTime
+		}
+		if stored.Address != "" {
+			info.Address = stored.Address
+		}
+	}
+	return info
+}
+
+func (info RestaurantInfo) String() string {
+	orNone := func(s string) string {
+		if s == "" {
+			return "non impostato"
+		}
+		return s
+	}
+	return "Consegna alle: " + orNone(info.DeliveryTime) + "\nIndirizzo: " + orNone(info.Address)
+}
+
+// RestaurantCmd shows or changes the delivery details, "off" restores the
+// ones of the configuration
+func (t *TinaBot) RestaurantCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	f := strings.Fields(args[1])
+	if len(f) == 0 {
+		t.bot.Message(msg.Channel, LoadRestaurantInfo(t.brain).String())
+		return
+	}
+	if len(f) < 2 {
+		t.bot.Message(msg.Channel, "Comando non valido, usa `ristorante consegna <ora>` o `ristorante indirizzo <indirizzo>`")
+		return
+	}
+	if !t.canAdmin(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono cambiare i dati della consegna")
+		return
+	}
+
+	var stored RestaurantInfo
+	t.brain.Get(restaurantKey, &stored)
+	value := strings.Join(f[1:], " ")
+	if strings.ToLower(value) == "off" {
+		value = ""
+	}
+	switch strings.ToLower(f[0]) {
+	case "consegna":
+		stored.DeliveryTime = value
+	case "indirizzo":
+		stored.Address = value
+	default:
+		t.bot.Message(msg.Channel, "Comando non valido, usa `ristorante consegna <ora>` o `ristorante indirizzo <indirizzo>`")
+		return
+	}
+	if err := t.brain.Set(restaurantKey, stored); err != nil {
+		t.bot.Message(msg.Channel, "Errore nel salvare i dati della consegna: "+err.Error())
+		return
+	}
+	t.bot.Message(msg.Channel, "Ok\n"+LoadRestaurantInfo(t.brain).String())
+}
+
+// RestaurantEmail renders the order in the format expected by the restaurant,
+// returning the subject and the body of the email
+func (order *Order) RestaurantEmail(info RestaurantInfo, withUserNames, withPrices bool) (string, string) {
+	subj := "Ordine Develer del giorno " + order.Timestamp.Format("02/01/2006")
+
+	body := []string{order.Format(withUserNames, withPrices)}
+	var details []string
+	if info.DeliveryTime != "" {
+		details = append(details, "Consegna alle: "+info.DeliveryTime)
+	}
+	if info.Address != "" {
+		details = append(details, "Indirizzo: "+info.Address)
+	}
+	if len(details) > 0 {
+		body = append(body, strings.Join(details, "\n"))
+	}
+	return subj, strings.Join(body, "\n\n")
+}
+
+// mailtoLink returns a slack link opening the email for the restaurant in the mail client
+func mailtoLink(subj, body string) string {
+	return "<mailto:info@tuttobene-bar.it,sara@tuttobene-bar.it" +
+		"?subject=" + url.PathEscape(subj) +
+		"&body=" + url.PathEscape(body) +
+		"|Link `mailto` clickabile>"
+}
+
+// EmailPreview shows the email that will be sent to the restaurant, without sending it
+func (t *TinaBot) EmailPreview(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	order := getOrder(t.brain)
+	subj, body := order.RestaurantEmail(LoadRestaurantInfo(t.brain), false, false)
+	t.bot.Message(msg.Channel, "Anteprima della mail per il ristorante:\n```Oggetto: "+subj+"\n\n"+body+"```")
+}
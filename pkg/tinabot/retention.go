@@ -0,0 +1,195 @@
+package tinabot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// RetentionSettings are how many months the data of the users is kept
+// before it's purged, 0 means forever
+type RetentionSettings struct {
+	History int `json:",omitempty"`
+	Ratings int `json:",omitempty"`
+
+	// the day of the last purge
+	Purged string `json:",omitempty"`
+}
+
+// Load loads the settings from brain, everything is kept if missing
+func (s *RetentionSettings) Load(brain DataStore) error {
+	if err := brain.Get("retention", s); err != nil {
+		*s = RetentionSettings{}
+		return err
+	}
+	return nil
+}
+
+// Save saves the settings to brain
+func (s *RetentionSettings) Save(brain DataStore) error {
+	return brain.Set("retention", *s)
+}
+
+// Due tells if the data is to be purged at now, once a day
+func (s *RetentionSettings) Due(now time.Time) bool {
+	return (s.History > 0 || s.Ratings > 0) && s.Purged != dayKey(now)
+}
+
+func (s *RetentionSettings) String() string {
+	limit := func(what string, months int) string {
+		if months == 0 {
+			return what + " per sempre"
+		}
+		return fmt.Sprintf("%s per %s", what, count(months, "mese", "mesi"))
+	}
+	return limit("Lo storico degli ordini viene conservato", s.History) + "\n" + limit("I voti ai piatti vengono conservati", s.Ratings)
+}
+
+// Purge deletes the data older than the limits at now: the choices in the
+// history with who ordered first each day, the names of the users left
+// without history, and the ratings. Returns how many items were deleted.
+func (s *RetentionSettings) Purge(brain DataStore, now time.Time) (int, error) {
+	n := 0
+	if s.History > 0 {
+		h, err := purgeHistory(brain, dayKey(now.AddDate(0, -s.History, 0)))
+		n += h
+		if err != nil {
+			return n, err
+		}
+	}
+	if s.Ratings > 0 {
+		r, err := purgeRatings(brain, dayKey(now.AddDate(0, -s.Ratings, 0)))
+		n += r
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// purgeHistory deletes the history of the days before the dayKey before
+func purgeHistory(brain DataStore, before string) (int, error) {
+	history := historyRepo(brain)
+	ids, err := history.IDs()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	kept := make(map[string]bool) // the users with some history left
+	for _, id := range ids {
+		i := strings.LastIndex(id, ":")
+		if i < 0 {
+			continue
+		}
+		if id[i+1:] >= before {
+			kept[id[:i]] = true
+			continue
+		}
+		if err := history.Delete(id); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	firsts := firstRepo(brain)
+	days, err := firsts.IDs()
+	if err != nil {
+		return n, err
+	}
+	for _, day := range days {
+		if day < before {
+			if err := firsts.Delete(day); err != nil {
+				return n, err
+			}
+		}
+	}
+
+	names := nameRepo(brain)
+	keys, err := names.IDs()
+	if err != nil {
+		return n, err
+	}
+	for _, key := range keys {
+		if !kept[key] {
+			if err := names.Delete(key); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// purgeRatings deletes the ratings given before the dayKey before
+func purgeRatings(brain DataStore, before string) (int, error) {
+	repo := ratingRepo(brain)
+	ratings, err := repo.All()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for dish, r := range ratings {
+		old := 0
+		for key, rating := range r.Ratings {
+			if rating.Day < before {
+				delete(r.Ratings, key)
+				old++
+			}
+		}
+		if old == 0 {
+			continue
+		}
+		if len(r.Ratings) == 0 {
+			err = repo.Delete(dish)
+		} else {
+			err = repo.Put(dish, r)
+		}
+		if err != nil {
+			return n, err
+		}
+		n += old
+	}
+	return n, nil
+}
+
+// RetentionCmd shows or changes how long the data of the users is kept
+func (t *TinaBot) RetentionCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	var s RetentionSettings
+	s.Load(t.brain)
+	f := strings.Fields(strings.ToLower(args[1]))
+	if len(f) == 0 {
+		t.bot.Message(msg.Channel, s.String())
+		return
+	}
+	if !t.canAdmin(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono cambiare la conservazione dei dati")
+		return
+	}
+
+	months := 0
+	if len(f) == 2 && f[1] != "off" {
+		n, err := strconv.Atoi(f[1])
+		if err != nil || n <= 0 {
+			t.bot.Message(msg.Channel, fmt.Sprintf("Numero di mesi '%s' non valido", f[1]))
+			return
+		}
+		months = n
+	}
+	switch {
+	case len(f) == 2 && f[0] == "storico":
+		s.History = months
+	case len(f) == 2 && f[0] == "voti":
+		s.Ratings = months
+	default:
+		t.bot.Message(msg.Channel, "Comando non valido, usa `conservazione storico|voti <mesi>|off`")
+		return
+	}
+	if err := s.Save(t.brain); err != nil {
+		t.bot.Message(msg.Channel, "Errore: "+err.Error())
+		return
+	}
+	t.bot.Message(msg.Channel, "Ok\n"+s.String())
+}
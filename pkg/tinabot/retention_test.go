@@ -0,0 +1,58 @@
+package tinabot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestRetentionPurge(t *testing.T) {
+	b := brain.NewBrainMock()
+	mario := User{"mario", "U1"}
+	luigi := User{"luigi", "U2"}
+	var c UserChoice
+	c.Add(tuttobene.MenuRow{Content: "primo", Type: tuttobene.Primo})
+
+	now := time.Date(2020, 3, 15, 10, 0, 0, 0, time.UTC)
+	for _, d := range []time.Time{now.AddDate(-2, 0, 0), now.AddDate(0, -1, 0)} {
+		order := NewOrder()
+		order.Timestamp = d
+		order.Set(mario, []UserChoice{c})
+		if d.Before(now.AddDate(-1, 0, 0)) {
+			order.Set(luigi, []UserChoice{c})
+		}
+		assertEqual(t, SaveHistory(b, order), nil, "")
+	}
+	assertEqual(t, rate(b, mario, "primo", Rating{Stars: 5, Day: "2018-01-01"}), nil, "")
+	assertEqual(t, rate(b, luigi, "primo", Rating{Stars: 3, Day: "2020-03-01"}), nil, "")
+
+	var s RetentionSettings
+	assertEqual(t, s.Due(now), false, "nothing to purge")
+	s.History = 12
+	assertEqual(t, s.Due(now), true, "")
+	n, err := s.Purge(b, now)
+	assertEqual(t, err, nil, "")
+	assertEqual(t, n, 2, "")
+
+	ids, _ := historyRepo(b).IDs()
+	assertEqual(t, len(ids), 1, "")
+	_, err = nameRepo(b).Get("U2")
+	assertEqual(t, err != nil, true, "luigi has no history left")
+	_, err = nameRepo(b).Get("U1")
+	assertEqual(t, err, nil, "")
+	r, _ := ratingRepo(b).Get(tuttobene.CanonicalName("primo"))
+	assertEqual(t, len(r.Ratings), 2, "the ratings are kept")
+
+	s.Ratings = 12
+	n, err = s.Purge(b, now)
+	assertEqual(t, err, nil, "")
+	assertEqual(t, n, 1, "")
+	r, _ = ratingRepo(b).Get(tuttobene.CanonicalName("primo"))
+	assertEqual(t, len(r.Ratings), 1, "")
+
+	s.Purged = dayKey(now)
+	assertEqual(t, s.Due(now), false, "once a day")
+	assertEqual(t, s.String(), "Lo storico degli ordini viene conservato per 12 mesi\nI voti ai piatti vengono conservati per 12 mesi", "")
+}
@@ -0,0 +1,147 @@
+package tinabot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/intent"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// The admins of each channel are kept in the set adminsPrefix+channel, by
+// user ID. The users listed in TINABOT_ADMINS, by team and user ID, are
+// admins everywhere in their workspace.
+const adminsPrefix = "roles:admins:"
+
+// RoleStore is a DataStore able to keep sets, for the roles of the users
+type RoleStore interface {
+	DataStore
+	SAdd(key string, members ...string) error
+	SRem(key string, members ...string) error
+	SMembers(key string) ([]string, error)
+}
+
+// isAdminOf returns true if user of team is listed in TINABOT_ADMINS or was
+// made admin of channel. The admins of any channel are admins in the direct
+// messages too.
+func isAdminOf(brain RoleStore, team, channel string, user User) bool {
+	if user.ID == "" {
+		return false
+	}
+	if isAdmin(team, user.ID) {
+		return true
+	}
+	keys := []string{adminsPrefix + channel}
+	if strings.HasPrefix(channel, "D") {
+		keys, _ = brain.Keys(adminsPrefix + "*")
+	}
+	for _, k := range keys {
+		ids, _ := brain.SMembers(k)
+		for _, id := range ids {
+			if id == user.ID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// canAdmin returns true if user of team can run the admin commands in
+// channel: everyone can until the first admin of the team is set
+func canAdmin(brain RoleStore, team, channel string, user User) bool {
+	if len(teamAdmins(team)) == 0 {
+		if keys, err := brain.Keys(adminsPrefix + "*"); err == nil && len(keys) == 0 {
+			return true
+		}
+	}
+	return isAdminOf(brain, team, channel, user)
+}
+
+// canAdmin tells if user can run the admin commands in the channel of msg
+func (t *TinaBot) canAdmin(msg *slackbot.BotMsg, user *chat.User) bool {
+	return canAdmin(t.brain, t.team, msg.Channel, User{user.Name, user.ID})
+}
+
+// authorize refuses the admin commands to the users who can't run them,
+// see slackbot.Bot.Authorize
+func (t *TinaBot) authorize(intentName string, msg *slackbot.BotMsg, user *chat.User) (bool, string) {
+	if intentName != intent.Admin || t.canAdmin(msg, user) {
+		return true, ""
+	}
+	return false, "Mi spiace " + user.Name + ", solo gli amministratori possono farlo.\nScrivi `admin` per sapere chi sono."
+}
+
+// AdminCmd shows the admins of the channel, or makes a user admin of the
+// channel or not anymore
+func (t *TinaBot) AdminCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	f := strings.Fields(args[1])
+	if len(f) == 0 {
+		t.bot.Message(msg.Channel, t.adminsString(msg.Channel))
+		return
+	}
+
+	cmd := strings.ToLower(f[0])
+	if len(f) != 2 || (cmd != "aggiungi" && cmd != "togli") {
+		t.bot.Message(msg.Channel, "Comando non valido, usa `admin aggiungi <utente>` o `admin togli <utente>`")
+		return
+	}
+	if isDirect(msg) {
+		t.bot.Message(msg.Channel, "Gli amministratori si gestiscono nel loro canale, non in privato")
+		return
+	}
+	if !t.canAdmin(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono gestire gli amministratori")
+		return
+	}
+	target := getUserInfo(t.bot.Platform, f[1])
+	if target == nil {
+		t.bot.Message(msg.Channel, fmt.Sprintf("Utente '%s' non trovato", f[1]))
+		return
+	}
+
+	key := adminsPrefix + msg.Channel
+	var err error
+	if cmd == "aggiungi" {
+		err = t.brain.SAdd(key, target.ID)
+	} else {
+		err = t.brain.SRem(key, target.ID)
+	}
+	if err != nil {
+		t.bot.Message(msg.Channel, "Errore nel salvare gli amministratori: "+err.Error())
+		return
+	}
+	t.bot.Message(msg.Channel, "Ok\n"+t.adminsString(msg.Channel))
+}
+
+// adminsString lists the admins of channel
+func (t *TinaBot) adminsString(channel string) string {
+	var names []string
+	ids, _ := t.brain.SMembers(adminsPrefix + channel)
+	for _, id := range ids {
+		name := id
+		if u, err := t.bot.Platform.UserInfo(id); err == nil {
+			name = u.Name
+		}
+		names = append(names, name)
+	}
+
+	var r []string
+	if len(names) == 0 {
+		r = append(r, "Questo canale non ha amministratori")
+	} else {
+		r = append(r, "Amministratori di questo canale: "+strings.Join(names, ", "))
+	}
+	var global []string
+	for _, id := range teamAdmins(t.team) {
+		name := id
+		if u, err := t.bot.Platform.UserInfo(id); err == nil {
+			name = u.Name
+		}
+		global = append(global, name)
+	}
+	if len(global) > 0 {
+		r = append(r, "Amministratori ovunque: "+strings.Join(global, ", "))
+	}
+	return strings.Join(r, "\n")
+}
@@ -0,0 +1,79 @@
+package tinabot
+
+import (
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/intent"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+func TestRoles(t *testing.T) {
+	b := brain.NewBrainMock()
+	mario := User{"mario", "U1"}
+	luigi := User{"luigi", "U2"}
+
+	// everyone is admin until the first admin is set
+	assertEqual(t, canAdmin(b, "T1", "C1", luigi), true, "")
+	assertEqual(t, isAdminOf(b, "T1", "C1", luigi), false, "")
+
+	b.SAdd(adminsPrefix+"C1", mario.ID)
+	assertEqual(t, canAdmin(b, "T1", "C1", mario), true, "")
+	assertEqual(t, canAdmin(b, "T1", "C1", luigi), false, "")
+	assertEqual(t, canAdmin(b, "T1", "C2", mario), false, "")
+	assertEqual(t, canAdmin(b, "T1", "D1", mario), true, "")
+	assertEqual(t, canAdmin(b, "T1", "D1", luigi), false, "")
+	assertEqual(t, canAdmin(b, "T1", "C1", User{"guest_mario", ""}), false, "")
+
+	setConfig(t, "TINABOT_ADMINS", "T1:U2")
+	assertEqual(t, canAdmin(b, "T1", "C2", luigi), true, "")
+	assertEqual(t, canAdmin(b, "T2", "C2", luigi), false, "")
+	assertEqual(t, canAdmin(b, "T1", "C2", User{"luigi", "U9"}), false, "")
+
+	b.SRem(adminsPrefix+"C1", mario.ID)
+	assertEqual(t, canAdmin(b, "T1", "C1", mario), false, "")
+}
+
+func TestAdminRoutes(t *testing.T) {
+	b := brain.NewBrainMock()
+	bot := slackbot.New("B1", nil)
+	tb := New(bot, b)
+	tb.AddCommands()
+	b.SAdd(adminsPrefix+"C1", "U1")
+
+	tests := map[string]string{
+		"cron":                 intent.Admin,
+		"rmorder mario":        intent.Admin,
+		"stato ordine chiuso":  intent.Admin,
+		"stato ordine":         intent.QueryOrder,
+		"calendario settimana": intent.Admin,
+		"calendario":           intent.Other,
+		"calendario link":      intent.Other,
+		"ufficio":              intent.Other,
+		"ufficio scegli #C2":   intent.Other,
+		"ufficio crea":         intent.Admin,
+		"admin":                intent.Other,
+		"approva 1":            intent.Admin,
+		"rifiuta 1":            intent.Admin,
+		"admin aggiungi mario": intent.Admin,
+		"set order:x {}":       "",
+		"read roles:admins:C1": "",
+	}
+	for text, want := range tests {
+		got := ""
+		if res := bot.Route(text); res.Route != nil {
+			got = res.Route.Intent
+		}
+		assertEqual(t, got, want, text)
+	}
+
+	msg := &slackbot.BotMsg{Channel: "C1"}
+	allowed, notice := bot.Authorize(intent.Admin, msg, &chat.User{Name: "luigi", ID: "U2"})
+	assertEqual(t, allowed, false, "")
+	assertEqual(t, notice != "", true, "")
+	allowed, _ = bot.Authorize(intent.Admin, msg, &chat.User{Name: "mario", ID: "U1"})
+	assertEqual(t, allowed, true, "")
+	allowed, _ = bot.Authorize(intent.Other, msg, &chat.User{Name: "luigi", ID: "U2"})
+	assertEqual(t, allowed, true, "")
+}
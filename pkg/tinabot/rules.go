@@ -0,0 +1,162 @@
+package tinabot
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// typeNames are the names used in the commands for each dish type
+var typeNames = map[string]tuttobene.MenuRowType{
+	"primo":       tuttobene.Primo,
+	"secondo":     tuttobene.Secondo,
+	"contorno":    tuttobene.Contorno,
+	"vegetariano": tuttobene.Vegetariano,
+	"frutta":      tuttobene.Frutta,
+	"dolce":       tuttobene.Dolce,
+	"panino":      tuttobene.Panino,
+}
+
+// CompositionRules are the office rules on what each person can order
+type CompositionRules struct {
+	MaxPerType           map[tuttobene.MenuRowType]int // max dishes of each type per person, missing means no limit
+	ContornoNeedsSecondo bool                          // side dishes can be ordered only with a second course
+	NoProposalMix        bool                          // the daily proposal can't be ordered with other dishes
+}
+
+// Load loads the rules from brain, no rules are enforced if missing
+func (r *CompositionRules) Load(brain DataStore) error {
+	if err := brain.Get("rules", r); err != nil {
+		*r = CompositionRules{}
+		return err
+	}
+	return nil
+}
+
+// Save saves the rules to brain
+func (r *CompositionRules) Save(brain DataStore) error {
+	return brain.Set("rules", *r)
+}
+
+// Validate returns an error explaining why the choices of a person break the rules
+func (r *CompositionRules) Validate(choices UserChoiceArray) error {
+	count := make(map[tuttobene.MenuRowType]int)
+	proposal := false
+	dishes := 0
+	for _, c := range choices {
+		for _, d := range c.Dishes {
+			count[d.Type]++
+			dishes++
+			if d.IsDailyProposal {
+				proposal = true
+			}
+		}
+	}
+
+	for _, t := range sortedTypes(r.MaxPerType) {
+		if max := r.MaxPerType[t]; count[t] > max {
+			return fmt.Errorf("puoi ordinare al massimo %d %s, ne hai scelti %d", max, tuttobene.Titles[t], count[t])
+		}
+	}
+
+	if r.ContornoNeedsSecondo && count[tuttobene.Contorno] > 0 && count[tuttobene.Secondo]+count[tuttobene.Vegetariano] == 0 {
+		return fmt.Errorf("i contorni si possono ordinare solo insieme a un secondo")
+	}
+
+	if r.NoProposalMix && proposal && dishes > 1 {
+		return fmt.Errorf("la proposta del giorno non si può ordinare insieme ad altri piatti")
+	}
+	return nil
+}
+
+func sortedTypes(m map[tuttobene.MenuRowType]int) []tuttobene.MenuRowType {
+	var types []tuttobene.MenuRowType
+	for t := range m {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+func (r *CompositionRules) String() string {
+	var l []string
+	for _, t := range sortedTypes(r.MaxPerType) {
+		l = append(l, fmt.Sprintf("Massimo %d %s a persona", r.MaxPerType[t], tuttobene.Titles[t]))
+	}
+	l = append(l, "Contorni solo con un secondo: "+onOff(r.ContornoNeedsSecondo))
+	l = append(l, "Proposta del giorno da sola: "+onOff(r.NoProposalMix))
+	return strings.Join(l, "\n")
+}
+
+// Rules shows or changes the composition rules
+func (t *TinaBot) Rules(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	var r CompositionRules
+	r.Load(t.brain)
+
+	f := strings.Fields(strings.ToLower(args[1]))
+	if len(f) == 0 {
+		t.bot.Message(msg.Channel, r.String())
+		return
+	}
+	if len(f) < 2 {
+		t.bot.Message(msg.Channel, "Argomenti insufficienti!")
+		return
+	}
+
+	switch f[0] {
+	case "max":
+		if len(f) < 3 {
+			t.bot.Message(msg.Channel, "Argomenti insufficienti!")
+			return
+		}
+		dt, ok := typeNames[f[1]]
+		if !ok {
+			t.bot.Message(msg.Channel, fmt.Sprintf("Tipo di piatto '%s' sconosciuto", f[1]))
+			return
+		}
+		if r.MaxPerType == nil {
+			r.MaxPerType = make(map[tuttobene.MenuRowType]int)
+		}
+		if f[2] == "off" {
+			delete(r.MaxPerType, dt)
+			break
+		}
+		n, err := strconv.Atoi(f[2])
+		if err != nil || n < 0 {
+			t.bot.Message(msg.Channel, fmt.Sprintf("Numero '%s' non valido", f[2]))
+			return
+		}
+		r.MaxPerType[dt] = n
+	case "contorno", "proposta":
+		if f[1] != "on" && f[1] != "off" {
+			t.bot.Message(msg.Channel, "Usa `on` oppure `off`")
+			return
+		}
+		if f[0] == "contorno" {
+			r.ContornoNeedsSecondo = f[1] == "on"
+		} else {
+			r.NoProposalMix = f[1] == "on"
+		}
+	default:
+		t.bot.Message(msg.Channel, "Comando non valido, usa `max`, `contorno` o `proposta`")
+		return
+	}
+
+	if err := r.Save(t.brain); err != nil {
+		t.bot.Message(msg.Channel, "Errore: "+err.Error())
+		return
+	}
+	t.bot.Message(msg.Channel, "Ok, regole aggiornate:\n"+r.String())
+}
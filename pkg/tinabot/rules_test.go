@@ -0,0 +1,40 @@
+package tinabot
+
+import (
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestCompositionRules(t *testing.T) {
+	var p, s, c, sc, dp UserChoice
+	p.Add(tuttobene.MenuRow{Content: "primo", Type: tuttobene.Primo})
+	s.Add(tuttobene.MenuRow{Content: "secondo", Type: tuttobene.Secondo})
+	c.Add(tuttobene.MenuRow{Content: "contorno", Type: tuttobene.Contorno})
+	sc.Add(tuttobene.MenuRow{Content: "secondo", Type: tuttobene.Secondo})
+	sc.Add(tuttobene.MenuRow{Content: "contorno", Type: tuttobene.Contorno})
+	dp.Add(tuttobene.MenuRow{Content: "proposta", Type: tuttobene.Primo, IsDailyProposal: true})
+
+	var r CompositionRules
+	assertEqual(t, r.Validate(UserChoiceArray{p, p, s, s, c}), nil, "")
+
+	r.MaxPerType = map[tuttobene.MenuRowType]int{tuttobene.Primo: 1, tuttobene.Secondo: 1}
+	assertEqual(t, r.Validate(UserChoiceArray{p, sc}), nil, "")
+	e := r.Validate(UserChoiceArray{p, p})
+	assertEqual(t, e.Error(), "puoi ordinare al massimo 1 primi piatti, ne hai scelti 2", "")
+
+	r.ContornoNeedsSecondo = true
+	assertEqual(t, r.Validate(UserChoiceArray{p, c}).Error(), "i contorni si possono ordinare solo insieme a un secondo", "")
+	assertEqual(t, r.Validate(UserChoiceArray{s, c}), nil, "")
+
+	r.NoProposalMix = true
+	assertEqual(t, r.Validate(UserChoiceArray{dp}), nil, "")
+	assertEqual(t, r.Validate(UserChoiceArray{dp, s}).Error(), "la proposta del giorno non si può ordinare insieme ad altri piatti", "")
+
+	b := brain.NewBrainMock()
+	r.Save(b)
+	var loaded CompositionRules
+	assertEqual(t, loaded.Load(b), nil, "")
+	assertEqual(t, loaded.String(), r.String(), "")
+}
@@ -0,0 +1,249 @@
+package tinabot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// ErrPostUsage is returned by CronPost without a channel and a message
+var ErrPostUsage = errors.New("not enough arguments, usage: post <channel> [<options>] <message>")
+
+// CronPost returns the channel and the message of the post cron task with
+// args, "<channel> [<options>] <message>", false if it must not be posted:
+// the option -o posts only with an order of today, -m with a menu of today.
+// The message can contain $MENU, $ORDER, $ORDER_NONAMES, $BILL and
+// $BILL_NONAMES, replaced with the menu and the order.
+func CronPost(brain DataStore, args []string) (string, string, bool, error) {
+	if len(args) < 2 {
+		return "", "", false, ErrPostUsage
+	}
+	channel := args[0]
+	onlyValidOrder := false
+	onlyValidMenu := false
+	startMsg := 1
+	for i := 1; i < len(args); i++ {
+		opt := args[i]
+		if strings.HasPrefix(opt, "-") {
+			startMsg = i + 1
+			// Post only if there is a valid order
+			if strings.Contains(opt, "o") {
+				onlyValidOrder = true
+			}
+
+			// Post only if there is a valid menu
+			if strings.Contains(opt, "m") {
+				onlyValidMenu = true
+			}
+		}
+	}
+
+	var order Order
+	order.Load(brain)
+
+	var menu tuttobene.Menu
+	hasMenu := brain.Get("menu", &menu) == nil
+
+	if onlyValidMenu && (!hasMenu || !menu.IsUpdated()) {
+		return channel, "", false, nil
+	}
+	if onlyValidOrder && !order.IsUpdated() {
+		return channel, "", false, nil
+	}
+
+	var privacy Privacy
+	privacy.Load(brain)
+	names := !privacy.Anonymous
+
+	msg := strings.Join(args[startMsg:], " ")
+	msg = strings.Replace(msg, "$MENU", menu.String(), -1)
+	msg = strings.Replace(msg, "$ORDER_NONAMES", order.Format(false, false), -1)
+	msg = strings.Replace(msg, "$ORDER", order.Format(names, false), -1)
+	msg = strings.Replace(msg, "$BILL", order.Format(names, true), -1)
+	msg = strings.Replace(msg, "$BILL_NONAMES", order.Format(false, true), -1)
+	msg = strings.Replace(msg, "\\n", "\n", -1)
+	return channel, msg, true, nil
+}
+
+// Countdown returns the message posted on the channel of the reminders the
+// given minutes before the deadline, when ordered users ordered so far
+func (s *ReminderSettings) Countdown(minutes, ordered int) string {
+	return fmt.Sprintf("Mancano %d minuti alla scadenza degli ordini delle %s, finora hanno ordinato in %d!", minutes, s.Deadline, ordered)
+}
+
+// Simulation replays the cron tasks of a day on a test channel at the time
+// they are due, without writing to the brain and without reaching anyone
+// else: today's menu is pinned on the test channel (the publish), the post
+// task is posted there, the reminders and the email of the order (the
+// sendmail task, the cutoff) are shown there, and the outdated menus pinned
+// there are removed (the unpinmenu task, the cleanup). The other tasks are
+// only announced.
+type Simulation struct {
+	Brain DataStore
+	// Post posts text on the test channel
+	Post func(text string)
+	// Pin pins menu on the test channel, nil if it can't be done
+	Pin func(menu tuttobene.Menu) error
+	// Unpin removes the outdated menus pinned on the test channel, nil if
+	// it can't be done
+	Unpin func() error
+}
+
+// Closed tells if there's no lunch on the day of now
+func (s *Simulation) Closed(now time.Time) (bool, string) {
+	var c Calendar
+	c.Load(s.Brain)
+	return c.IsClosed(now)
+}
+
+// PublishMenu replays the publish of today's menu at now, as when the menu
+// is loaded
+func (s *Simulation) PublishMenu(now time.Time) {
+	clock := now.Format("15:04")
+	var menu tuttobene.Menu
+	if s.Brain.Get("menu", &menu) != nil || !menu.IsUpdated() {
+		s.Post(fmt.Sprintf("[%s] nessun menù di oggi da pubblicare", clock))
+		return
+	}
+	if s.Pin == nil {
+		s.Post(fmt.Sprintf("[%s] menù di oggi, non fissato in simulazione:\n%s", clock, menu.String()))
+		return
+	}
+	s.Post(fmt.Sprintf("[%s] pubblicazione del menù di oggi", clock))
+	if err := s.Pin(menu); err != nil {
+		s.Post(fmt.Sprintf("[%s] errore: %s", clock, err.Error()))
+	}
+}
+
+// ClearOldOrder replays at now the removal of the order of a previous day,
+// done by the cron on the days without lunch, without removing it
+func (s *Simulation) ClearOldOrder(now time.Time) {
+	var order Order
+	if err := order.Load(s.Brain); err != nil || order.IsUpdated() {
+		return
+	}
+	s.Post(fmt.Sprintf("[%s] l'ordine del %s verrebbe cancellato", now.Format("15:04"), order.Timestamp.Format("02/01/2006")))
+}
+
+// Task replays the cron task cmd, with its arguments, at now
+func (s *Simulation) Task(now time.Time, cmd string) {
+	args := strings.Fields(cmd)
+	if len(args) == 0 {
+		return
+	}
+	clock := now.Format("15:04")
+
+	var err error
+	switch args[0] {
+	case "post":
+		if len(args) < 2 {
+			err = ErrPostUsage
+			break
+		}
+		// on the test channel instead of the real one
+		var msg string
+		var ok bool
+		_, msg, ok, err = CronPost(s.Brain, append([]string{""}, args[2:]...))
+		if ok {
+			s.Post(msg)
+		}
+	case "reminder":
+		s.reminders(now, 0, true)
+	case "sendmail":
+		s.sendmail(clock, args[1:])
+	case "unpinmenu":
+		if s.Unpin == nil {
+			s.Post(fmt.Sprintf("[%s] menù fissati non rimossi in simulazione", clock))
+			break
+		}
+		err = s.Unpin()
+	default:
+		s.Post(fmt.Sprintf("[%s] task `%s` non eseguito in simulazione", clock, args[0]))
+	}
+	if err != nil {
+		s.Post(fmt.Sprintf("[%s] errore: %s", clock, err.Error()))
+	}
+}
+
+// Reminders replays the reminders due in the interval centered on now, as
+// the cron does at each run
+func (s *Simulation) Reminders(now time.Time, interval time.Duration) {
+	s.reminders(now, interval, false)
+}
+
+// reminders shows the countdowns and the users who would be reminded at
+// now, everyone with the reminder active today if force
+func (s *Simulation) reminders(now time.Time, interval time.Duration, force bool) {
+	var settings ReminderSettings
+	settings.Load(s.Brain)
+	dmDue, countdowns := force, []int(nil)
+	if !force {
+		dmDue, countdowns = settings.Due(now, interval)
+	}
+
+	var remind map[string]int
+	s.Brain.Get("remind", &remind)
+	var snoozes Snoozes
+	snoozes.Load(s.Brain)
+	users := ReminderRecipients(remind, snoozes, dmDue, now, interval)
+	if len(users) == 0 && len(countdowns) == 0 {
+		return
+	}
+
+	var order Order
+	order.Load(s.Brain)
+	var menu tuttobene.Menu
+	if s.Brain.Get("menu", &menu) != nil || !menu.IsUpdated() || !order.IsUpdated() || order.State != Open {
+		return
+	}
+
+	clock := now.Format("15:04")
+	for _, m := range countdowns {
+		s.Post(fmt.Sprintf("[%s] %s", clock, settings.Countdown(m, len(order.Users))))
+	}
+	var reminded []string
+	for _, id := range users {
+		if !order.Ordered(User{ID: id}) {
+			reminded = append(reminded, "<@"+id+">")
+		}
+	}
+	if len(reminded) > 0 {
+		s.Post(fmt.Sprintf("[%s] promemoria per chi non ha ancora ordinato: %s", clock, strings.Join(reminded, ", ")))
+	}
+}
+
+// sendmail shows the email of the order that the sendmail task with args
+// would send, without sending it nor marking the order as sent
+func (s *Simulation) sendmail(clock string, args []string) {
+	var order Order
+	order.Load(s.Brain)
+	var menu tuttobene.Menu
+	if s.Brain.Get("menu", &menu) != nil || !menu.IsUpdated() || !order.IsUpdated() {
+		s.Post(fmt.Sprintf("[%s] nessun ordine di oggi da inviare al ristorante", clock))
+		return
+	}
+
+	bill, names := false, false
+	var addresses []string
+	for _, a := range args {
+		switch a {
+		case "--bill":
+			bill = true
+		case "--names":
+			names = true
+		case "--dry-run":
+		default:
+			addresses = append(addresses, a)
+		}
+	}
+	var privacy Privacy
+	privacy.Load(s.Brain)
+	if privacy.Anonymous {
+		names = true
+	}
+	subj, body := order.RestaurantEmail(LoadRestaurantInfo(s.Brain), names, bill)
+	s.Post(fmt.Sprintf("[%s] email che verrebbe inviata a %s:\n*%s*\n%s", clock, strings.Join(addresses, ", "), subj, body))
+}
@@ -0,0 +1,115 @@
+package tinabot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestCronPost(t *testing.T) {
+	b := brain.NewBrainMock()
+	_, _, _, err := CronPost(b, []string{"C1"})
+	assertEqual(t, err, ErrPostUsage, "")
+
+	// no menu of today yet
+	_, _, ok, err := CronPost(b, []string{"C1", "-m", "Ecco", "$MENU"})
+	assertEqual(t, ok, false, "")
+	assertEqual(t, err, nil, "")
+
+	today := NewOrder().Timestamp
+	b.Set("menu", tuttobene.Menu{Date: today, Rows: []tuttobene.MenuRow{{Content: "Risotto", Type: tuttobene.Primo}}})
+	channel, msg, ok, _ := CronPost(b, []string{"C1", "-m", "Ecco\\n$MENU"})
+	assertEqual(t, channel, "C1", "")
+	assertEqual(t, ok, true, "")
+	assertEqual(t, strings.HasPrefix(msg, "Ecco\n") && strings.Contains(msg, "Risotto"), true, msg)
+}
+
+func TestSimulation(t *testing.T) {
+	b := brain.NewBrainMock()
+	loc, _ := time.LoadLocation("Europe/Rome")
+	now := NewOrder().Timestamp
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	b.Set("menu", tuttobene.Menu{Date: now, Rows: []tuttobene.MenuRow{{Content: "Risotto", Type: tuttobene.Primo}}})
+	order := NewOrder()
+	var c UserChoice
+	c.Add(tuttobene.MenuRow{Content: "Risotto", Type: tuttobene.Primo})
+	order.Set(User{"mario", "U1"}, []UserChoice{c})
+	order.Save(b)
+	(&ReminderSettings{Time: "11:00", Deadline: "12:00", Countdowns: []int{30}, Channel: "C1"}).Save(b)
+	b.Set("remind", map[string]int{"U1": 0xff, "U2": 0xff})
+
+	var posted []string
+	sim := &Simulation{Brain: b, Post: func(text string) { posted = append(posted, text) }}
+	step := 10 * time.Minute
+
+	// the menu is pinned on the test channel
+	var pinned []string
+	sim.PublishMenu(day)
+	assertEqual(t, strings.Contains(posted[0], "Risotto"), true, posted[0])
+	sim.Pin = func(menu tuttobene.Menu) error {
+		pinned = append(pinned, menu.String())
+		return nil
+	}
+	posted = nil
+	sim.PublishMenu(day.Add(8 * time.Hour))
+	assertEqual(t, strings.Join(posted, "\n"), "[08:00] pubblicazione del menù di oggi", "")
+	assertEqual(t, len(pinned), 1, "")
+	assertEqual(t, strings.Contains(pinned[0], "Risotto"), true, pinned[0])
+
+	// today's order is not cleared
+	posted = nil
+	sim.ClearOldOrder(day)
+	assertEqual(t, len(posted), 0, "")
+
+	// a post with only the channel is reported, not fatal
+	sim.Task(day.Add(9*time.Hour), "post #pranzo")
+	sim.Task(day.Add(9*time.Hour), "post #pranzo Il menù di oggi: $MENU")
+	assertEqual(t, len(posted), 2, "")
+	assertEqual(t, strings.Contains(posted[0], "errore"), true, posted[0])
+	assertEqual(t, strings.Contains(posted[1], "Risotto"), true, posted[1])
+
+	// the reminders follow the simulated clock
+	posted = nil
+	sim.Reminders(day.Add(10*time.Hour), step)
+	assertEqual(t, len(posted), 0, "")
+	sim.Reminders(day.Add(11*time.Hour), step)
+	assertEqual(t, strings.Join(posted, "\n"), "[11:00] promemoria per chi non ha ancora ordinato: <@U2>", "")
+	posted = nil
+	sim.Reminders(day.Add(11*time.Hour+30*time.Minute), step)
+	assertEqual(t, strings.Join(posted, "\n"), "[11:30] Mancano 30 minuti alla scadenza degli ordini delle 12:00, finora hanno ordinato in 1!", "")
+
+	// the cutoff shows the email without sending the order
+	posted = nil
+	sim.Task(day.Add(12*time.Hour), "sendmail --names cibo@example.com")
+	assertEqual(t, len(posted), 1, "")
+	assertEqual(t, strings.Contains(posted[0], "cibo@example.com") && strings.Contains(posted[0], "Risotto"), true, posted[0])
+	assertEqual(t, getOrder(b).State, Open, "")
+
+	posted = nil
+	sim.Task(day.Add(23*time.Hour), "unpinmenu #pranzo")
+	sim.Task(day.Add(23*time.Hour), "digest")
+	assertEqual(t, len(posted), 2, "")
+	assertEqual(t, strings.Contains(posted[1], "non eseguito"), true, posted[1])
+}
+
+func TestSimulationClosed(t *testing.T) {
+	b := brain.NewBrainMock()
+	order := NewOrder()
+	order.Timestamp = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	b.Set("order", order)
+
+	var posted []string
+	sim := &Simulation{Brain: b, Post: func(text string) { posted = append(posted, text) }}
+	sim.ClearOldOrder(time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC))
+	assertEqual(t, strings.Join(posted, "\n"), "[00:00] l'ordine del 01/03/2024 verrebbe cancellato", "")
+	var stored Order
+	stored.Load(b)
+	assertEqual(t, stored.Timestamp.Equal(order.Timestamp), true, "")
+
+	posted = nil
+	sim.PublishMenu(time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC))
+	assertEqual(t, strings.Join(posted, "\n"), "[00:00] nessun menù di oggi da pubblicare", "")
+}
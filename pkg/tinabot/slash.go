@@ -0,0 +1,60 @@
+package tinabot
+
+import (
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/chat"
+)
+
+// slashCommands maps the subcommands of the slash command to the bot
+// commands, the text after the subcommand is appended
+var slashCommands = map[string]string{
+	"menu":    "menu",
+	"order":   "per me",
+	"cancel":  "per me niente",
+	"summary": "ordine",
+}
+
+// slashBare maps the subcommands given without any text to the bot commands
+var slashBare = map[string]string{
+	"order": "ordina",
+}
+
+// SlashUsage is the answer to a slash command without a subcommand
+const SlashUsage = "Uso: `/lunch menu`, `/lunch order <ordine>`, `/lunch cancel` o `/lunch summary`; `/lunch order` da solo mostra il menù interattivo.\n" +
+	"Tutti gli altri comandi funzionano come scrivendo al bot, es. `/lunch conto`."
+
+// slashText returns the bot command for the text of a slash command, the
+// text itself if it's not a known subcommand
+func slashText(text string) string {
+	text = strings.TrimSpace(text)
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return ""
+	}
+	sub := strings.ToLower(fields[0])
+	cmd, ok := slashCommands[sub]
+	if !ok {
+		return text
+	}
+	if bare, ok := slashBare[sub]; ok && len(fields) == 1 {
+		return bare
+	}
+	if rest := strings.TrimSpace(text[len(fields[0]):]); rest != "" {
+		return cmd + " " + rest
+	}
+	return cmd
+}
+
+// SlashCommand handles a slash command, e.g. /lunch order lasagne, so that
+// the bot can be used from any channel without mentioning it. The answers
+// are posted to the response URL of the command; the returned text, if
+// any, is to be sent as the immediate response.
+func (t *TinaBot) SlashCommand(cmd chat.Command) string {
+	text := slashText(cmd.Text)
+	if text == "" {
+		return SlashUsage
+	}
+	t.bot.HandleCommand(cmd.Channel, cmd.User, text, cmd.ResponseURL)
+	return ""
+}
@@ -0,0 +1,22 @@
+package tinabot
+
+import (
+	"testing"
+)
+
+func TestSlashText(t *testing.T) {
+	tests := map[string]string{
+		"":                         "",
+		"  ":                       "",
+		"menu":                     "menu",
+		"Order lasagne + tiramisù": "per me lasagne + tiramisù",
+		"order":                    "ordina",
+		"cancel":                   "per me niente",
+		"summary":                  "ordine",
+		"conto":                    "conto",
+		"per   mario  pizza":       "per   mario  pizza",
+	}
+	for text, want := range tests {
+		assertEqual(t, slashText(text), want, text)
+	}
+}
@@ -0,0 +1,96 @@
+package tinabot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/events"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// snapshots are the named copies of today's order
+type snapshots map[string]Order
+
+func loadSnapshots(brain DataStore) snapshots {
+	var s snapshots
+	if err := brain.Get("order:snapshots", &s); err != nil || s == nil {
+		return snapshots{}
+	}
+	// keep only the ones of today
+	for name, o := range s {
+		if !o.IsUpdated() {
+			delete(s, name)
+		}
+	}
+	return s
+}
+
+// Snapshot saves a copy of the order with the given name, replacing any
+// previous snapshot with the same name. Snapshots last until the end of the day.
+func (order *Order) Snapshot(brain DataStore, name string) error {
+	s := loadSnapshots(brain)
+	s[strings.ToLower(name)] = *order
+	return brain.Set("order:snapshots", s)
+}
+
+// Restore replaces the order with the snapshot with the given name, taken today
+func (order *Order) Restore(brain DataStore, name string) error {
+	saved, ok := loadSnapshots(brain)[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("non c'è nessun salvataggio '%s' di oggi", name)
+	}
+	version := order.Version
+	*order = saved
+	order.Version = version
+	return nil
+}
+
+// SnapshotCmd saves, restores or lists the snapshots of today's order
+func (t *TinaBot) SnapshotCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	cmd, name := strings.ToLower(args[1]), strings.TrimSpace(args[2])
+	if cmd == "salvataggi" {
+		var names []string
+		for n, o := range loadSnapshots(t.brain) {
+			names = append(names, fmt.Sprintf("%s (%d utenti)", n, len(o.Users)))
+		}
+		if len(names) == 0 {
+			t.bot.Message(msg.Channel, "Nessun salvataggio dell'ordine di oggi")
+			return
+		}
+		sort.Strings(names)
+		t.bot.Message(msg.Channel, "Salvataggi dell'ordine di oggi:\n"+strings.Join(names, "\n"))
+		return
+	}
+
+	if !t.canAdmin(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono salvare e ripristinare l'ordine")
+		return
+	}
+	if name == "" {
+		t.bot.Message(msg.Channel, "Devi indicare il nome del salvataggio")
+		return
+	}
+
+	if cmd == "salva" {
+		order := getOrder(t.brain)
+		if err := order.Snapshot(t.brain, name); err != nil {
+			t.bot.Message(msg.Channel, "Errore nel salvare l'ordine: "+err.Error())
+			return
+		}
+		t.bot.Message(msg.Channel, fmt.Sprintf("Ok, ordine salvato come '%s', per tornare a questo punto usa `ripristina ordine %s`", name, name))
+		return
+	}
+
+	var order Order
+	err := order.SaveCAS(t.brain, func(o *Order) error {
+		return o.Restore(t.brain, name)
+	})
+	if err != nil {
+		t.bot.Message(msg.Channel, "Errore nel ripristinare l'ordine: "+err.Error())
+		return
+	}
+	t.events.Publish(events.OrderUpdated, &order)
+	t.bot.Message(msg.Channel, fmt.Sprintf("Ok, ordine ripristinato a '%s':\n%s", name, order.String()))
+}
@@ -0,0 +1,67 @@
+package tinabot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/events"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// SoldOut marks a dish of the menu as sold out, switching the users who
+// ordered it to their alternatives and letting them know
+func (t *TinaBot) SoldOut(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	menu, err := todayMenu(t.brain)
+	if err != nil {
+		t.bot.Message(msg.Channel, "Nessun menù impostato!")
+		return
+	}
+
+	found := findDishes(menu, sanitize(args[1]))
+	if len(found) != 1 {
+		t.bot.Message(msg.Channel, fmt.Sprintf("Non trovo un solo piatto nel menù che corrisponda a '%s'", args[1]))
+		return
+	}
+	dish := found[0].Content
+
+	var order Order
+	var subs []Substitution
+	err = order.SaveCAS(t.brain, func(o *Order) error {
+		subs = o.MarkSoldOut(dish)
+		return nil
+	})
+	if err != nil {
+		t.bot.Message(msg.Channel, "Errore nel salvare l'ordine: "+err.Error())
+		return
+	}
+	t.events.Publish(events.OrderUpdated, &order)
+
+	var r []string
+	for _, s := range subs {
+		var txt string
+		if s.New != "" {
+			r = append(r, fmt.Sprintf("%s: %s → %s", s.User.Name, s.Old, s.New))
+			txt = fmt.Sprintf("Ciao %s, %s è esaurito: ho ordinato per te l'alternativa %s", s.User.Name, dish, s.New)
+		} else {
+			r = append(r, fmt.Sprintf("%s: %s tolto, nessuna alternativa", s.User.Name, s.Old))
+			txt = fmt.Sprintf("Ciao %s, %s è esaurito e non avevi indicato alternative: ho tolto %s dal tuo ordine", s.User.Name, dish, s.Old)
+		}
+
+		if s.User.ID == "" {
+			continue
+		}
+		ch, err := bot.Platform.DirectChannel(s.User.ID)
+		if err != nil {
+			t.logger().Error("Error opening the direct message", "err", err)
+			continue
+		}
+		t.bot.Message(ch, txt)
+	}
+
+	reply := fmt.Sprintf("Ok, %s è esaurito", dish)
+	if len(r) > 0 {
+		reply += ", ho aggiornato questi ordini:\n" + strings.Join(r, "\n")
+	}
+	t.bot.Message(msg.Channel, reply)
+}
@@ -0,0 +1,69 @@
+package tinabot
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/staging"
+)
+
+// defaultFaultDuration is how long a failure is simulated if not told
+const defaultFaultDuration = 5 * time.Minute
+
+// faultsString returns the failures being simulated
+func faultsString() string {
+	fs := staging.Faults()
+	if len(fs) == 0 {
+		return "Nessun guasto simulato"
+	}
+	var lines []string
+	for _, f := range fs {
+		lines = append(lines, "Guasto simulato di "+f.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SimulateCmd shows, starts or stops the failures simulated in staging mode,
+// to rehearse the incidents
+func (t *TinaBot) SimulateCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	f := strings.Fields(strings.ToLower(args[1]))
+	if len(f) == 0 {
+		t.bot.Message(msg.Channel, faultsString())
+		return
+	}
+	// anybody can stop the failures, the admins may not be found while the
+	// brain is failing
+	if len(f) == 1 && f[0] == "off" {
+		staging.Clear()
+		t.bot.Message(msg.Channel, "Ok, guasti simulati finiti")
+		return
+	}
+	if !t.canAdmin(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono simulare i guasti")
+		return
+	}
+	if len(f) < 2 || len(f) > 3 {
+		t.bot.Message(msg.Channel, "Comando non valido, usa `simula redis|slack <percentuale>% [<durata>]` o `simula off`")
+		return
+	}
+	percent, err := strconv.Atoi(strings.TrimSuffix(f[1], "%"))
+	if err != nil {
+		t.bot.Message(msg.Channel, "Mi spiace, la percentuale `"+f[1]+"` non è un numero")
+		return
+	}
+	d := defaultFaultDuration
+	if len(f) == 3 {
+		if d, err = time.ParseDuration(f[2]); err != nil || d <= 0 {
+			t.bot.Message(msg.Channel, "Mi spiace, durata `"+f[2]+"` non valida, usa ad esempio 30s o 5m")
+			return
+		}
+	}
+	if _, err := staging.Inject(f[0], percent, d); err != nil {
+		t.bot.Message(msg.Channel, "Mi spiace, "+err.Error())
+		return
+	}
+	t.bot.Message(msg.Channel, "Ok\n"+faultsString())
+}
@@ -0,0 +1,115 @@
+package tinabot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/events"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// OrderState is the step of the order lifecycle
+type OrderState int
+
+const (
+	// Open orders accept new dishes
+	Open OrderState = iota
+	// Locked orders are being reviewed before sending them
+	Locked
+	// Sent orders have been sent to the restaurant
+	Sent
+	// Delivered orders have arrived
+	Delivered
+	// Archived orders are closed for good
+	Archived
+)
+
+var stateNames = map[OrderState]string{
+	Open:      "aperto",
+	Locked:    "bloccato",
+	Sent:      "inviato",
+	Delivered: "consegnato",
+	Archived:  "archiviato",
+}
+
+// transitions lists the states reachable from each state
+var transitions = map[OrderState][]OrderState{
+	Open:      {Locked},
+	Locked:    {Open, Sent},
+	Sent:      {Delivered},
+	Delivered: {Archived},
+}
+
+func (s OrderState) String() string {
+	return stateNames[s]
+}
+
+// ParseOrderState returns the state with the given name
+func ParseOrderState(name string) (OrderState, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for s, n := range stateNames {
+		if n == name {
+			return s, nil
+		}
+	}
+	return Open, fmt.Errorf("stato '%s' sconosciuto", name)
+}
+
+// Transition moves the order to the state to, if allowed
+func (order *Order) Transition(to OrderState) error {
+	for _, s := range transitions[order.State] {
+		if s == to {
+			order.State = to
+			return nil
+		}
+	}
+	return fmt.Errorf("l'ordine è %s, non può diventare %s", order.State, to)
+}
+
+// Editable returns an error if the order does not accept changes anymore
+func (order *Order) Editable() error {
+	if order.State != Open {
+		return fmt.Errorf("l'ordine è %s, non è più possibile modificarlo", order.State)
+	}
+	return nil
+}
+
+// State shows the state of today's order to everyone, the admins can also
+// change it
+func (t *TinaBot) State(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	if strings.TrimSpace(args[1]) == "" {
+		order := getOrder(t.brain)
+		t.bot.Message(msg.Channel, "L'ordine è "+order.State.String())
+		return
+	}
+	if !t.canAdmin(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono cambiare lo stato dell'ordine")
+		return
+	}
+
+	to, err := ParseOrderState(args[1])
+	if err != nil {
+		t.bot.Message(msg.Channel, err.Error())
+		return
+	}
+
+	var order Order
+	err = order.SaveCAS(t.brain, func(o *Order) error {
+		return o.Transition(to)
+	})
+	if err != nil {
+		t.bot.Message(msg.Channel, "Errore: "+err.Error())
+		return
+	}
+
+	t.events.Publish(events.OrderUpdated, &order)
+	if to == Sent {
+		t.events.Publish(events.OrderClosed, &order)
+	}
+	if to == Delivered {
+		t.events.Publish(events.OrderDelivered, &order)
+		t.askRatings(&order)
+	}
+	t.bot.Message(msg.Channel, "Ok, l'ordine ora è "+to.String())
+}
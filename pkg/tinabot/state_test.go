@@ -0,0 +1,41 @@
+package tinabot
+
+import (
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// sentPlatform records the messages sent by the bot
+type sentPlatform struct {
+	chat.Platform
+	sent []string
+}
+
+func (p *sentPlatform) SendMessage(channel, thread, text string, blocks ...chat.Block) (string, error) {
+	p.sent = append(p.sent, text)
+	return "", nil
+}
+
+func TestStateAdmin(t *testing.T) {
+	b := brain.NewBrainMock()
+	p := &sentPlatform{}
+	bot := slackbot.New("B1", nil)
+	bot.Platform = p
+	tb := New(bot, b)
+	b.SAdd(adminsPrefix+"C1", "U1")
+	msg := &slackbot.BotMsg{Channel: "C1"}
+
+	tb.State(bot, msg, &chat.User{Name: "luigi", ID: "U2"}, "stato ordine", "")
+	assertEqual(t, p.sent[len(p.sent)-1], "L'ordine è aperto", "")
+
+	tb.State(bot, msg, &chat.User{Name: "luigi", ID: "U2"}, "stato ordine bloccato", " bloccato")
+	assertEqual(t, getOrder(b).State, Open, "")
+	assertEqual(t, p.sent[len(p.sent)-1], "Mi spiace, solo gli amministratori possono cambiare lo stato dell'ordine", "")
+
+	tb.State(bot, msg, &chat.User{Name: "mario", ID: "U1"}, "stato ordine bloccato", " bloccato")
+	assertEqual(t, getOrder(b).State, Locked, "")
+	assertEqual(t, p.sent[len(p.sent)-1], "Ok, l'ordine ora è bloccato", "")
+}
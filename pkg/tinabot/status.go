@@ -0,0 +1,35 @@
+package tinabot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// Status tells whether the bot can reach its brain
+func (t *TinaBot) Status(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	start := time.Now()
+	if err := t.brain.Healthy(); err != nil {
+		t.bot.Message(msg.Channel, "Non riesco a raggiungere la memoria: "+err.Error()+"\nGli ordini potrebbero non essere salvati!")
+		return
+	}
+	t.bot.Message(msg.Channel, fmt.Sprintf("Tutto ok, memoria raggiungibile in %v", time.Since(start).Round(time.Millisecond)))
+}
+
+// MyOrder shows only to the user what she ordered today
+func (t *TinaBot) MyOrder(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	order := getOrder(t.brain)
+	me := order.key(User{user.Name, user.ID})
+	choices := order.Users[me]
+	if len(choices) == 0 {
+		t.bot.Reply(msg, slackbot.Ephemeral, "Oggi non hai ancora ordinato niente")
+		return
+	}
+	reply := fmt.Sprintf("Oggi hai ordinato: %s, €%s", choices.String(), order.TotalFor(me).StringFixed(2))
+	if by, ok := order.OrderedBy[me]; ok {
+		reply += " (ordinato da " + order.Name(by) + ")"
+	}
+	t.bot.Reply(msg, slackbot.Ephemeral, reply)
+}
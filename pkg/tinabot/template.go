@@ -0,0 +1,208 @@
+package tinabot
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/events"
+	"github.com/develersrl/lunches/pkg/matcher"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// TemplateItem is a dish of a template, ordered Count times
+type TemplateItem struct {
+	Count int
+	Dish  string // request matched against the menu, as in the "per" command
+}
+
+// Template is a recurring order for a team, e.g. for a meeting
+type Template struct {
+	Name  string
+	Items []TemplateItem
+}
+
+// Templates maps the lowercase name of each template to it
+type Templates map[string]Template
+
+// Load loads the templates from brain, empty if none is stored
+func (ts *Templates) Load(brain DataStore) error {
+	if err := brain.Get("templates", ts); err != nil {
+		*ts = Templates{}
+		return err
+	}
+	if *ts == nil {
+		*ts = Templates{}
+	}
+	return nil
+}
+
+// Save saves the templates to brain
+func (ts *Templates) Save(brain DataStore) error {
+	return brain.Set("templates", *ts)
+}
+
+// ParseTemplate parses a template definition like
+// "riunione lunedì: 5 pizze margherita, 2 acqua", a missing count means 1
+func ParseTemplate(def string) (Template, error) {
+	i := strings.Index(def, ":")
+	if i < 0 {
+		return Template{}, errors.New("manca il nome del modello, es. `riunione: 5 pizze`")
+	}
+	tmpl := Template{Name: strings.TrimSpace(def[:i])}
+	if tmpl.Name == "" {
+		return Template{}, errors.New("il nome del modello è vuoto")
+	}
+
+	for _, item := range strings.Split(def[i+1:], ",") {
+		f := strings.Fields(item)
+		if len(f) == 0 {
+			continue
+		}
+		count := 1
+		if n, err := strconv.Atoi(f[0]); err == nil {
+			if n <= 0 || len(f) == 1 {
+				return Template{}, fmt.Errorf("'%s' non è valido", strings.TrimSpace(item))
+			}
+			count, f = n, f[1:]
+		}
+		tmpl.Items = append(tmpl.Items, TemplateItem{count, strings.Join(f, " ")})
+	}
+	if len(tmpl.Items) == 0 {
+		return Template{}, fmt.Errorf("il modello '%s' non contiene nessun piatto", tmpl.Name)
+	}
+	return tmpl, nil
+}
+
+// User returns the pseudo user the dishes of the template are ordered for,
+// a guest charged to whoever uses the template
+func (tmpl *Template) User() User {
+	return User{"team_" + strings.Join(strings.Fields(strings.ToLower(tmpl.Name)), "_"), ""}
+}
+
+// Choices matches the template items with menu, returns the choices and the
+// messages about the matched dishes
+func (tmpl *Template) Choices(m *matcher.Matcher, menu tuttobene.Menu) ([]UserChoice, string, error) {
+	var choices []UserChoice
+	reply := ""
+	for _, item := range tmpl.Items {
+		c, r, err := parseChoice(m, menu, item.Dish)
+		reply += r
+		if err != nil {
+			return nil, reply, err
+		}
+		for i := 0; i < item.Count; i++ {
+			choices = append(choices, c)
+		}
+	}
+	return choices, reply, nil
+}
+
+func (tmpl *Template) String() string {
+	var items []string
+	for _, item := range tmpl.Items {
+		items = append(items, fmt.Sprintf("%d %s", item.Count, item.Dish))
+	}
+	return tmpl.Name + ": " + strings.Join(items, ", ")
+}
+
+func (ts Templates) String() string {
+	if len(ts) == 0 {
+		return "Nessun modello salvato"
+	}
+	var r []string
+	for _, tmpl := range ts {
+		r = append(r, tmpl.String())
+	}
+	sort.Strings(r)
+	return "Modelli salvati:\n" + strings.Join(r, "\n")
+}
+
+// TemplateCmd lists, defines, removes or orders a team template
+func (t *TinaBot) TemplateCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	var ts Templates
+	ts.Load(t.brain)
+
+	arg := strings.TrimSpace(args[1])
+	if arg == "" {
+		t.bot.Message(msg.Channel, ts.String())
+		return
+	}
+
+	if !t.canAdmin(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono gestire i modelli")
+		return
+	}
+
+	f := strings.Fields(arg)
+	name := strings.ToLower(strings.Join(f[1:], " "))
+	switch cmd := strings.ToLower(f[0]); {
+	case strings.Contains(arg, ":"):
+		tmpl, err := ParseTemplate(arg)
+		if err != nil {
+			t.bot.Message(msg.Channel, "Mi spiace, "+err.Error())
+			return
+		}
+		ts[strings.ToLower(tmpl.Name)] = tmpl
+	case cmd == "usa" || cmd == "togli":
+		tmpl, ok := ts[name]
+		if !ok {
+			t.bot.Message(msg.Channel, fmt.Sprintf("Non conosco il modello '%s'", name))
+			return
+		}
+		if cmd == "usa" {
+			t.useTemplate(msg, user, tmpl)
+			return
+		}
+		delete(ts, name)
+	default:
+		t.bot.Message(msg.Channel, "Comando non valido, usa `modello <nome>: <piatti>`, `modello usa <nome>` o `modello togli <nome>`")
+		return
+	}
+
+	if err := ts.Save(t.brain); err != nil {
+		t.bot.Message(msg.Channel, "Errore nel salvare i modelli: "+err.Error())
+		return
+	}
+	t.bot.Message(msg.Channel, "Ok\n"+ts.String())
+}
+
+// useTemplate adds the dishes of tmpl to today's order, charged to user
+func (t *TinaBot) useTemplate(msg *slackbot.BotMsg, user *chat.User, tmpl Template) {
+	menu, _ := todayMenu(t.brain)
+	if !menu.IsUpdated() {
+		t.bot.Message(msg.Channel, "Non puoi ordinare, il menù non è quello di oggi, riporta la data del "+menu.Date.Format("02/01/2006"))
+		return
+	}
+
+	choices, reply, err := tmpl.Choices(t.loadMatcher(), menu)
+	if err != nil {
+		t.bot.Message(msg.Channel, reply+err.Error())
+		return
+	}
+
+	team := tmpl.User()
+	var order Order
+	var prev UserChoiceArray
+	err = order.SaveCAS(t.brain, func(o *Order) error {
+		if err := o.Editable(); err != nil {
+			return err
+		}
+		prev = o.choicesOf(team)
+		o.SetBy(User{user.Name, user.ID}, team, choices)
+		return nil
+	})
+	if err != nil {
+		t.bot.Message(msg.Channel, reply+"Errore nel salvare l'ordine: "+err.Error())
+		return
+	}
+	t.record(team, prev)
+	t.events.Publish(events.OrderUpdated, &order)
+	observeOrder("template")
+
+	t.bot.Message(msg.Channel, reply+fmt.Sprintf("Ok, aggiunti %d piatti per %s, a carico di %s", len(choices), team.Name, user.Name))
+}
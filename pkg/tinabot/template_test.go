@@ -0,0 +1,43 @@
+package tinabot
+
+import (
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/matcher"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestTemplate(t *testing.T) {
+	_, err := ParseTemplate("riunione 5 pizze")
+	assertEqual(t, err != nil, true, "")
+	_, err = ParseTemplate("riunione: 0 pizze")
+	assertEqual(t, err != nil, true, "")
+	_, err = ParseTemplate("riunione: ")
+	assertEqual(t, err != nil, true, "")
+
+	tmpl, err := ParseTemplate("Riunione Lunedì: 3 pizza margherita, \"acqua\"")
+	assertEqual(t, err, nil, "")
+	assertEqual(t, tmpl.String(), "Riunione Lunedì: 3 pizza margherita, 1 \"acqua\"", "")
+	assertEqual(t, tmpl.User(), User{"team_riunione_lunedì", ""}, "")
+
+	menu := tuttobene.Menu{Rows: []tuttobene.MenuRow{
+		{Content: "Pizza margherita", Type: tuttobene.Panino},
+		{Content: "Pizza marinara", Type: tuttobene.Panino},
+	}}
+	choices, _, err := tmpl.Choices(matcher.New(), menu)
+	assertEqual(t, err, nil, "")
+	assertEqual(t, len(choices), 4, "")
+
+	order := NewOrder()
+	admin := User{"admin", "1"}
+	order.SetBy(admin, tmpl.User(), choices)
+	assertEqual(t, order.String(), "1 acqua [team_riunione_lunedì da admin]\n3 Pizza margherita [team_riunione_lunedì da admin, team_riunione_lunedì da admin, team_riunione_lunedì da admin]", "")
+	assertEqual(t, order.Payer(tmpl.User()), admin, "")
+
+	order.ClearUser(tmpl.User())
+	assertEqual(t, len(order.Dishes), 0, "")
+
+	tmpl, _ = ParseTemplate("pranzo: pizza")
+	_, _, err = tmpl.Choices(matcher.New(), menu)
+	assertEqual(t, err != nil, true, "")
+}
@@ -0,0 +1,110 @@
+package tinabot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/events"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// The menu of the day pinned in a channel is the parent message of the day:
+// the replies of the bot and the order summary go in its thread
+
+// Summary is the order summary posted in the thread of the menu, edited in
+// place at each change of the order
+type Summary struct {
+	Timestamp string
+	Date      time.Time
+}
+
+// summaryRepo keeps the summary of today by channel
+func summaryRepo(b DataStore) brain.Repo[Summary] {
+	return brain.NewRepo[Summary](b, "thread:summary:")
+}
+
+// MenuThread returns the timestamp of the menu of today pinned on channel,
+// the thread where the bot posts on channel, empty if there's none
+func MenuThread(brain DataStore, channel string) string {
+	var pins []MenuPin
+	brain.Get(menuPinsKey, &pins)
+	for _, p := range pins {
+		if p.Channel == channel && isUpdated(p.Date) {
+			return p.Timestamp
+		}
+	}
+	return ""
+}
+
+// summaryText returns the summary of the order shown in the threads
+func summaryText(brain DataStore, order *Order) string {
+	var p Privacy
+	p.Load(brain)
+	if order.State == Sent {
+		return "Ordine inviato:\n" + order.Format(!p.Anonymous, false)
+	}
+	return fmt.Sprintf("Riepilogo dell'ordine (%s):\n", order.State) + order.Format(!p.Anonymous, false)
+}
+
+// updateSummaries shows the order in the thread of each menu pinned today,
+// editing the summary posted before
+func (t *TinaBot) updateSummaries(order *Order) {
+	if !order.IsUpdated() {
+		return
+	}
+	var pins []MenuPin
+	t.brain.Get(menuPinsKey, &pins)
+
+	var channels []string
+	for _, p := range pins {
+		if isUpdated(p.Date) {
+			channels = append(channels, p.Channel)
+		}
+	}
+	if len(channels) == 0 {
+		return
+	}
+
+	text := summaryText(t.brain, order)
+	summaries := summaryRepo(t.brain)
+	// the summaries of all the channels are read together, the updates come
+	// in bursts before the deadline
+	posted, err := summaries.GetMany(channels...)
+	if err != nil {
+		t.logger().Error("Error reading the order summaries", "err", err)
+	}
+	for _, p := range pins {
+		if !isUpdated(p.Date) {
+			continue
+		}
+		if s, ok := posted[p.Channel]; ok && isUpdated(s.Date) {
+			err := t.bot.Platform.UpdateMessage(p.Channel, s.Timestamp, text)
+			if err == nil {
+				continue
+			}
+			slackbot.APIError("chat.update")
+			t.logger().Error("Error updating the order summary", "err", err)
+		}
+		ts, err := t.bot.Platform.SendMessage(p.Channel, p.Timestamp, text)
+		if err != nil {
+			slackbot.APIError("chat.postMessage")
+			t.logger().Error("Error posting the order summary", "err", err)
+			continue
+		}
+		summaries.Put(p.Channel, Summary{ts, order.Timestamp})
+	}
+}
+
+// subscribeThreads keeps the order summaries updated, the final one is
+// shown when the order is sent
+func (t *TinaBot) subscribeThreads() {
+	update := func(ev events.Event) {
+		if ev.Remote {
+			return
+		}
+		t.updateSummaries(ev.Data.(*Order))
+	}
+	t.events.Subscribe(events.OrderUpdated, update)
+	t.events.Subscribe(events.OrderClosed, update)
+}
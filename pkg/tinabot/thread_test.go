@@ -0,0 +1,32 @@
+package tinabot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestMenuThread(t *testing.T) {
+	b := brain.NewBrainMock()
+	assertEqual(t, MenuThread(b, "C1"), "", "")
+
+	now := NewOrder().Timestamp
+	b.Set(menuPinsKey, []MenuPin{
+		{"C1", "100.1", now.AddDate(0, 0, -1)},
+		{"C1", "200.1", now},
+		{"C2", "300.1", now.AddDate(0, 0, -1)},
+	})
+	assertEqual(t, MenuThread(b, "C1"), "200.1", "")
+	assertEqual(t, MenuThread(b, "C2"), "", "")
+
+	order := NewOrder()
+	var uc UserChoice
+	uc.Add(tuttobene.MenuRow{Content: "Lasagne", Type: tuttobene.Primo})
+	order.Set(User{"test", "123"}, []UserChoice{uc})
+	assertEqual(t, strings.HasPrefix(summaryText(b, order), "Riepilogo dell'ordine (aperto)"), true, summaryText(b, order))
+	assertEqual(t, strings.Contains(summaryText(b, order), "Lasagne"), true, "")
+	order.State = Sent
+	assertEqual(t, strings.HasPrefix(summaryText(b, order), "Ordine inviato:"), true, "")
+}
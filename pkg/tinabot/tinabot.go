@@ -1,29 +1,34 @@
 package tinabot
 
 import (
+	"encoding/json"
 	"fmt"
-	"log"
-	"net/url"
+	"log/slog"
 	"strings"
-
-	"github.com/nlopes/slack"
+	"time"
 
 	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/config"
+	"github.com/develersrl/lunches/pkg/events"
+	"github.com/develersrl/lunches/pkg/intent"
 	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/staging"
 	"github.com/develersrl/lunches/pkg/tuttobene"
 	"github.com/go-redis/redis"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-func getOrder(brain *brain.Brain) *Order {
+func getOrder(brain DataStore) *Order {
 	var order Order
 
 	if order.Load(brain) != nil {
-		return NewOrder()
+		return freshOrder(brain)
 	}
 
 	if !order.IsUpdated() {
-		log.Println("Deleting old order")
-		return NewOrder()
+		slog.Info("Deleting old order")
+		return freshOrder(brain)
 	}
 	return &order
 }
@@ -37,56 +42,289 @@ func sanitize(s string) string {
 }
 
 type TinaBot struct {
-	bot   *slackbot.Bot
-	brain *brain.Brain
+	bot    *slackbot.Bot
+	brain  brain.Store
+	events *events.Bus
+
+	// root is the whole brain, brain is the part of the office, see Scope
+	root brain.Store
+	team string
+
+	// stops stop sharing the events with the other instances, see Close
+	stops []func() error
+}
+
+func New(bot *slackbot.Bot, b brain.Store) *TinaBot {
+	return newBot(bot, b, b, "")
+}
+
+// newBot returns the bot working on b, the part of the root brain of team
+func newBot(bot *slackbot.Bot, b, root brain.Store, team string) *TinaBot {
+	t := &TinaBot{bot: bot, brain: b, events: events.New(), root: root, team: team}
+	bot.Thread = func(channel string) string {
+		return MenuThread(b, channel)
+	}
+	bot.Throttle = func(channel, user string) (bool, string) {
+		return t.throttle(channel, user, time.Now())
+	}
+	bot.Authorize = t.authorize
+	t.subscribeThreads()
+	SubscribeWebhooks(t.events, b, Jobs(root))
+	if ps, ok := b.(brain.PubSub); ok {
+		t.shareEvents(ps)
+	}
+	if bot.Token != "" {
+		t.subscribeHome()
+	}
+	return t
+}
+
+// logger returns the logger of the event being handled, with its correlation
+// ID
+func (t *TinaBot) logger() *slog.Logger {
+	return t.bot.Logger()
+}
+
+// decodeOrder decodes the order of the events shared by the bot instances
+func decodeOrder(data []byte) (interface{}, error) {
+	var order Order
+	err := json.Unmarshal(data, &order)
+	return &order, err
+}
+
+// shareEvents shares the order and menu updates with the other bot
+// instances using the same brain, e.g. during a rolling deploy
+func (t *TinaBot) shareEvents(ps brain.PubSub) {
+	decoders := map[events.Topic]events.Decoder{
+		events.OrderUpdated: decodeOrder,
+		events.MenuPublished: func(data []byte) (interface{}, error) {
+			var m tuttobene.Menu
+			err := json.Unmarshal(data, &m)
+			return m, err
+		},
+	}
+	for topic, decode := range decoders {
+		stop, err := t.events.Share(ps, topic, decode)
+		if err != nil {
+			t.logger().Error("Error sharing events", "topic", topic, "err", err)
+			continue
+		}
+		t.stops = append(t.stops, stop)
+	}
 }
 
-func New(bot *slackbot.Bot, b *brain.Brain) *TinaBot {
-	return &TinaBot{bot, b}
+// Close stops sharing the events with the other bot instances. The bot is
+// created for each event it handles, it must be closed once it's done,
+// otherwise its subscriptions to the brain are kept until the brain is.
+func (t *TinaBot) Close() error {
+	var first error
+	for _, stop := range t.stops {
+		if err := stop(); err != nil && first == nil {
+			first = err
+		}
+	}
+	t.stops = nil
+	return first
+}
+
+// setMenu saves the menu and, if it's not for a future day, publishes it and
+// pins it on channel
+func (t *TinaBot) setMenu(channel string, m tuttobene.Menu) {
+	SaveMenu(t.brain, m)
+	if !m.IsUpdated() && m.Date.After(time.Now()) {
+		t.bot.Message(channel, "Ok, menù impostato per "+formatDay(m.Date))
+		return
+	}
+	t.events.Publish(events.MenuPublished, m)
+	t.bot.Message(channel, "Ok, menù impostato")
+	err := PinMenu(t.bot.Platform, t.brain, t.bot.UserID, channel, m)
+	if err != nil {
+		t.logger().Error("Error pinning menu", "err", err)
+		t.bot.Message(channel, m.String())
+	}
+	if reactionsEnabled(t.brain) {
+		t.postReactionMenu(channel, m)
+	}
+}
+
+// didYouMean lists the examples of the intents suggested to the user
+func didYouMean(examples []string) string {
+	quoted := make([]string, len(examples))
+	for i, e := range examples {
+		quoted[i] = "`" + e + "`"
+	}
+	if len(quoted) == 1 {
+		return quoted[0]
+	}
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " o " + quoted[len(quoted)-1]
+}
+
+// Events returns the bus where the bot publishes its events
+func (t *TinaBot) Events() *events.Bus {
+	return t.events
 }
 
 func (t *TinaBot) AddCommands() {
+	cfg := config.Current()
+
+	t.bot.Intent(intent.Order, "per me <piatto>", "per", "ordina", "ordino", "prendo", "voglio", "ieri")
+	t.bot.Intent(intent.Cancel, "per me niente", "niente", "annulla", "cancella", "togli", "elimina")
+	t.bot.Intent(intent.QueryMenu, "menu", "menu", "menù", "piatti", "mangiare")
+	t.bot.Intent(intent.QueryOrder, "ordine", "ordine", "ordinato", "conto", "riepilogo", "mancanti")
+	t.bot.Intent(intent.Help, "aiuto", "aiuto", "help", "comandi")
+	// the admin commands are never suggested
+	t.bot.Intent(intent.Admin, "", "setmenu", "cron", "regole")
 
-	t.bot.DefaultResponse(func(b *slackbot.Bot, msg *slackbot.BotMsg, user *slack.User) {
+	t.bot.DefaultResponse(func(b *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User) {
 		t.bot.Message(msg.Channel, "Mi dispiace "+user.Name+", purtroppo non posso farlo.\nProva con `aiuto` per vedere l'elenco delle cose che posso fare.")
 	})
 
-	t.bot.RespondTo("^(?i)(help|aiut).*$", func(b *slackbot.Bot, msg *slackbot.BotMsg, user *slack.User, args ...string) {
-		t.bot.Message(msg.Channel, strings.Replace(HelpStr, "‘", "`", -1))
+	t.bot.DidYouMean(func(b *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, examples ...string) {
+		t.bot.Message(msg.Channel, "Non ho capito, forse intendevi "+didYouMean(examples)+"?\nProva con `aiuto` per vedere l'elenco delle cose che posso fare.")
 	})
 
-	t.bot.RespondTo("^(?i)per (\\S+) (.*)$", t.For)
+	t.bot.Handle(intent.Help, "^(?i)(?:help|aiut\\S*)\\s*(.*)$", t.Help, usageHelp...)
+
+	t.bot.Handle(intent.Order, "^(?i)per ([^\\s:]+:?)\\s+(.*)$", t.For, usageFor...)
+
+	t.bot.Handle(intent.Order, "^(?i)(come (ieri|settimana scorsa))$", func(b *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+		t.For(b, msg, user, args[0], "me", args[1])
+	}, usageAsBefore...)
+
+	t.bot.Handle(intent.Cancel, "^(?i)togli (.+)$", t.Remove, usageRemove...)
+
+	t.bot.Handle(intent.Cancel, "^(?i)(?:annulla|(ripristina))$", t.Undo, usageUndo...)
+
+	t.bot.Handle(intent.Admin, "^(?i)esaurito (.+)$", t.SoldOut, usageSoldOut...)
+
+	t.bot.Handle(intent.Admin, "^(?i)(richieste|approva|rifiuta)\\s*(\\S*)$", t.LateCmd, usageLate...)
+
+	t.bot.Handle(intent.QueryOrder, "^(?i)stato ordine(\\s*)$", t.State, usageShowState...)
 
-	t.bot.RespondTo("^(?i)ordine$", func(b *slackbot.Bot, msg *slackbot.BotMsg, user *slack.User, args ...string) {
+	t.bot.Handle(intent.Admin, "^(?i)stato ordine(.*)$", t.State, usageState...)
+
+	t.bot.Handle(intent.Other, "^(?i)stato$", t.Status, usageStatus...)
+
+	t.bot.Handle(intent.QueryOrder, "^(?i)ordine$", func(b *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
 		order := getOrder(t.brain)
-		t.bot.Message(msg.Channel, "Ecco l'ordine:\n"+order.String())
-	})
+		t.bot.Message(msg.Channel, fmt.Sprintf("Ecco l'ordine (%s):\n", order.State)+order.Format(t.showNames(msg, user), false))
+	}, usageOrder...)
+
+	t.bot.Handle(intent.QueryOrder, "^(?i)cosa ho ordinato\\??$", t.MyOrder, usageMyOrder...)
+
+	t.bot.Handle(intent.QueryOrder, "^(?i)(?:mancanti|chi manca\\??)\\s*(.*)$", t.MissingCmd, usageMissing...)
+
+	t.bot.Handle(intent.Admin, "^(?i)modell[oi](.*)$", t.TemplateCmd, usageTemplate...)
+
+	t.bot.Handle(intent.Other, "^(?i)gruppo(.*)$", t.Group, usageGroup...)
+
+	t.bot.Handle(intent.Other, "^(?i)dieta(.*)$", t.Diet, usageDiet...)
 
-	t.bot.RespondTo("^(?i)conto$", func(b *slackbot.Bot, msg *slackbot.BotMsg, user *slack.User, args ...string) {
+	t.bot.Handle(intent.Other, "^(?i)nutrizione(.*)$", t.NutritionCmd, usageNutrition...)
+
+	t.bot.Handle(intent.Other, "^(?i)(soprannom[ei])\\s*(.*)$", t.AliasCmd, usageAlias...)
+
+	t.bot.Handle(intent.QueryOrder, "^(?i)ordine (\\S+)$", t.AdvanceOrder, usageAdvanceOrder...)
+
+	t.bot.Handle(intent.Order, "^(?i)ordina$", t.OrderMenu, usageOrderMenu...)
+
+	if cfg.Enabled(config.FeatureGuests) {
+		t.bot.Handle(intent.Order, "^(?i)link ospite$", t.GuestLinkCmd, usageGuestLink...)
+	}
+
+	t.bot.Handle(intent.Admin, "^(?i)reazioni(.*)$", t.ReactionsCmd, usageReactions...)
+
+	t.bot.Handle(intent.QueryOrder, "^(?i)esporta(.*)$", t.Export, usageExport...)
+
+	t.bot.Handle(intent.QueryOrder, "^(?i)conto$", func(b *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
 		order := getOrder(t.brain)
-		t.bot.Message(msg.Channel, "Ecco il conto:\n"+order.Bill())
-	})
+		if !t.showNames(msg, user) {
+			t.bot.Message(msg.Channel, "Ecco il conto:\n"+order.Format(false, true))
+			return
+		}
+		var p Policy
+		p.Load(t.brain)
+		t.bot.Message(msg.Channel, "Ecco il conto:\n"+order.Bill(p))
+	}, usageBill...)
+
+	t.bot.Handle(intent.Other, "^(?i)ho pagato$", t.Paid, usagePaid...)
+
+	t.bot.Handle(intent.Other, "^(?i)quanto devo\\??$", t.Balance, usageBalance...)
+
+	t.bot.Handle(intent.Other, "^(?i)saldato (\\S+)\\s*(\\S*)$", t.Settle, usageSettle...)
+
+	t.bot.Handle(intent.Other, "^(?i)bilancio$", t.MonthlySummary, usageMonthly...)
+
+	t.bot.Handle(intent.Other, "^(?i)report(.*)$", t.ReportCmd, usageReport...)
 
-	t.bot.RespondTo("^(?i)cancella ordine$", func(b *slackbot.Bot, msg *slackbot.BotMsg, user *slack.User, args ...string) {
-		order := NewOrder()
-		order.Save(t.brain)
+	t.bot.Handle(intent.Other, "^(?i)variet[àa](.*)$", t.VarietyCmd, usageVariety...)
+
+	t.bot.Handle(intent.Other, "^(?i)classifica(.*)$", t.LeaderboardCmd, usageLeaderboard...)
+
+	t.bot.Handle(intent.Other, "^(?i)prezzi(.*)$", t.PricesCmd, usagePrices...)
+
+	t.bot.Handle(intent.Admin, "^(?i)previsione(.*)$", t.ForecastCmd, usageForecast...)
+
+	t.bot.Handle(intent.Admin, "^(?i)regole(.*)$", t.Rules, usageRules...)
+
+	t.bot.Handle(intent.Admin, "^(?i)privacy(.*)$", t.PrivacyCmd, usagePrivacy...)
+
+	t.bot.Handle(intent.Other, "^(?i)dimenticami(.*)$", t.ForgetMe, usageForgetMe...)
+
+	t.bot.Handle(intent.Admin, "^(?i)dimentica (\\S+)\\s*(\\S*)$", t.Forget, usageForget...)
+
+	t.bot.Handle(intent.Admin, "^(?i)conservazione(.*)$", t.RetentionCmd, usageRetention...)
+
+	t.bot.Handle(intent.Admin, "^(?i)limiti(.*)$", t.RateLimitCmd, usageRateLimit...)
+
+	t.bot.Handle(intent.Admin, "^(?i)config(.*)$", t.ConfigCmd, usageConfig...)
+
+	t.bot.Handle(intent.Admin, "^(?i)flag(.*)$", t.FlagCmd, usageFlag...)
+
+	if staging.Enabled() {
+		t.bot.Handle(intent.Admin, "^(?i)simula(.*)$", t.SimulateCmd, usageSimulate...)
+	}
+
+	t.bot.Handle(intent.Admin, "^(?i)budget(.*)$", t.Budget, usageBudget...)
+
+	t.bot.Handle(intent.Admin, "^(?i)contributo(.*)$", t.Subsidy, usageSubsidy...)
+
+	t.bot.Handle(intent.Admin, "^(?i)contabilit[àa](.*)$", t.AccountingCmd, usageAccounting...)
+
+	t.bot.Handle(intent.Admin, "^(?i)(salva|ripristina) ordine\\s*(.*)$", t.SnapshotCmd, usageSnapshot...)
+
+	t.bot.Handle(intent.Admin, "^(?i)salvataggi$", func(b *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+		t.SnapshotCmd(b, msg, user, args[0], "salvataggi", "")
+	}, usageSnapshots...)
+
+	t.bot.Handle(intent.Admin, "^(?i)cancella ordine$", func(b *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+		if !t.canAdmin(msg, user) {
+			t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono cancellare l'ordine")
+			return
+		}
+		order, err := ClearOrder(t.brain)
+		if err != nil {
+			t.bot.Message(msg.Channel, "Errore nel cancellare l'ordine: "+err.Error())
+			return
+		}
+		t.events.Publish(events.OrderUpdated, order)
 		t.bot.Message(msg.Channel, "Ordine cancellato")
-	})
+	}, usageClearOrder...)
+
+	t.bot.Handle(intent.Admin, "^(?i)anteprima email$", t.EmailPreview, usageEmailPreview...)
 
-	t.bot.RespondTo("^(?i)email$", func(b *slackbot.Bot, msg *slackbot.BotMsg, user *slack.User, args ...string) {
+	t.bot.Handle(intent.Admin, "^(?i)email$", func(b *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+		if !t.canAdmin(msg, user) {
+			t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono preparare la mail per il ristorante")
+			return
+		}
 		order := getOrder(t.brain)
-		subj := "Ordine Develer del giorno " + order.Timestamp.Format("02/01/2006")
-		body := order.Format(false, false)
-
-		out := subj + "\n" + body + "\n\n" +
-			"<mailto:info@tuttobene-bar.it,sara@tuttobene-bar.it" +
-			"?subject=" + url.PathEscape(subj) +
-			"&body=" + url.PathEscape(body) +
-			"|Link `mailto` clickabile>"
-		t.bot.Message(msg.Channel, out)
-	})
+		subj, body := order.RestaurantEmail(LoadRestaurantInfo(t.brain), false, false)
+
+		t.bot.Message(msg.Channel, subj+"\n"+body+"\n\n"+mailtoLink(subj, body))
+	}, usageEmail...)
 
-	t.bot.RespondTo("^(?i)menu([\\s\\S]*)?", func(b *slackbot.Bot, msg *slackbot.BotMsg, user *slack.User, args ...string) {
+	t.bot.Handle(intent.QueryMenu, "^(?i)menu([\\s\\S]*)?", func(b *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
 
 		showPrices := false
 
@@ -97,85 +335,107 @@ func (t *TinaBot) AddCommands() {
 			return
 		}
 
-		var m tuttobene.Menu
-		err := t.brain.Get("menu", &m)
+		m, err := todayMenu(t.brain)
 		if err == redis.Nil {
 			t.bot.Message(msg.Channel, "Non c'è nessun menù impostato!")
 		} else {
-			t.bot.Message(msg.Channel, "Ecco il menù:\n"+m.Format(showPrices))
+			m = withRatings(t.brain, m)
+			header := "Ecco il menù:\n"
+			if source := ActiveSource(t.brain, time.Now()); source != "" {
+				header = "Oggi si mangia da " + source + ", ecco il menù:\n"
+			}
+			t.bot.Message(msg.Channel, header+m.Format(showPrices))
 		}
-	})
+	}, usageMenu...)
 
-	t.bot.RespondTo("^(?i)setmenu([\\s\\S]*)?", func(b *slackbot.Bot, msg *slackbot.BotMsg, user *slack.User, args ...string) {
+	if cfg.Enabled(config.FeatureRatings) {
+		t.bot.Handle(intent.QueryMenu, "^(?i)migliori piatti$", t.BestDishes, usageBest...)
+
+		t.bot.Handle(intent.Other, "^(?i)vot[oa] (.+)$", t.Vote, usageVote...)
+	}
+
+	t.bot.Handle(intent.Admin, "^(?i)setmenu([\\s\\S]*)?", func(b *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+		if !t.canAdmin(msg, user) {
+			t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono impostare il menù")
+			return
+		}
 		if args[1] != "" {
 			menu := strings.Split(strings.TrimSpace(sanitize(args[1])), "\n")
+			end := t.bot.Trace("menu.parse", attribute.Int("menu.lines", len(menu)))
 			m, err := tuttobene.ParseMenuCells(menu, []string{})
+			end(err)
 			if err != nil {
 				t.bot.Message(msg.Channel, "Menu parse error: "+err.Error())
 				return
 			}
-			t.brain.Set("menu", *m)
-			t.bot.Message(msg.Channel, "Ok, menù impostato:\n"+m.String())
+			t.setMenu(msg.Channel, *m)
 		} else {
 			t.bot.Message(msg.Channel, "Non hai indicato nessun nuovo menù!")
 		}
-	})
+	}, usageSetMenu...)
 
-	t.bot.RespondTo("^set (.*)$", func(b *slackbot.Bot, msg *slackbot.BotMsg, user *slack.User, args ...string) {
-		ar := strings.Split(args[1], " ")
-		key := ar[0]
-		val := ar[1]
-		err := t.brain.Set(key, val)
-		if err != nil {
-			t.bot.Message(msg.Channel, "Error: "+err.Error())
-		} else {
-			t.bot.Message(msg.Channel, "Ok")
-		}
-	})
+	t.bot.Handle(intent.Admin, "^(?i)cron(.*)$", t.Cron, usageCron...)
 
-	t.bot.RespondTo("^get (.*)$", func(b *slackbot.Bot, msg *slackbot.BotMsg, user *slack.User, args ...string) {
-		key := args[1]
-		var val string
-		err := t.brain.Get(key, &val)
-		if err != nil {
-			t.bot.Message(msg.Channel, "Error: "+err.Error())
-		} else {
-			t.bot.Message(msg.Channel, key+": "+val)
-		}
-	})
+	// who are the admins and the offices is for everyone to see, as the
+	// choice of the office of the direct messages
+	t.bot.Handle(intent.Other, "^(?i)admin()$", t.AdminCmd, usageAdmins...)
 
-	t.bot.RespondTo("^read (.*)$", func(b *slackbot.Bot, msg *slackbot.BotMsg, user *slack.User, args ...string) {
-		key := args[1]
+	t.bot.Handle(intent.Admin, "^(?i)admin(.*)$", t.AdminCmd, usageAdmin...)
 
-		val, err := t.brain.Read(key)
-		if err != nil {
-			t.bot.Message(msg.Channel, "Error: "+err.Error())
-		} else {
-			t.bot.Message(msg.Channel, key+": "+val)
-		}
-	})
+	t.bot.Handle(intent.Other, "^(?i)ufficio(|\\s+scegli\\s+.*)$", t.OfficeCmd, usageOffice...)
+
+	t.bot.Handle(intent.Admin, "^(?i)ufficio(.*)$", t.OfficeCmd, usageOfficeAdmin...)
 
-	t.bot.RespondTo("^(?i)cron(.*)$", t.Cron)
+	t.bot.Handle(intent.Admin, "^(?i)ristorante(.*)$", t.RestaurantCmd, usageRestaurant...)
 
-	t.bot.RespondTo("^(?i)remind(.*)$", t.Remind)
+	t.bot.Handle(intent.Other, "^(?i)calendario(|\\s+link)$", t.CalendarCmd, usageCalendar...)
 
-	t.bot.RespondTo("^(?i)segna(.*)$", t.Mark)
+	t.bot.Handle(intent.Admin, "^(?i)calendario(.*)$", t.CalendarCmd, usageCalendarAdmin...)
 
-	t.bot.RespondTo("^(?i)rmorder (.*)$", func(b *slackbot.Bot, msg *slackbot.BotMsg, user *slack.User, args ...string) {
+	t.bot.Handle(intent.Admin, "^(?i)richiesta menu(.*)$", t.MenuRequestCmd, usageMenuRequest...)
+
+	t.bot.Handle(intent.Admin, "^(?i)digest(.*)$", t.DigestCmd, usageDigest...)
+
+	t.bot.Handle(intent.Admin, "^(?i)webhook(.*)$", t.WebhookCmd, usageWebhook...)
+
+	if cfg.Enabled(config.FeaturePolls) {
+		t.bot.Handle(intent.Other, "^(?i)sondaggio()$", t.PollCmd, usagePollResults...)
+
+		t.bot.Handle(intent.Admin, "^(?i)sondaggio(.*)$", t.PollCmd, usagePoll...)
+	}
+
+	t.bot.Handle(intent.Other, "^(?i)remind(.*)$", t.Remind, usageRemind...)
+
+	t.bot.Handle(intent.Other, "^(?i)promemoria(.*)$", t.ReminderCmd, usageReminders...)
+
+	t.bot.Handle(intent.Other, "^(?i)segna(.*)$", t.Mark, usageMark...)
+
+	t.bot.Handle(intent.Admin, "^(?i)rmorder (.*)$", func(b *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
 		u := args[1]
 		name := User{u, ""}
-		finduser := getUserInfo(b.Client, u)
+		finduser := t.findUser(u)
 		if finduser != nil {
 			name = User{finduser.Name, finduser.ID}
 		}
-		order := getOrder(t.brain)
-		old := order.ClearUser(name)
+		var order Order
+		var old string
+		err := order.SaveCAS(t.brain, func(o *Order) error {
+			if err := o.Editable(); err != nil {
+				return err
+			}
+			old = o.ClearUser(name)
+			return nil
+		})
+		if err != nil {
+			t.bot.Message(msg.Channel, "Errore nel salvare l'ordine: "+err.Error())
+			return
+		}
+		t.events.Publish(events.OrderUpdated, &order)
+
 		if old != "" {
 			t.bot.Message(msg.Channel, fmt.Sprintf("Ok, cancello ordine di %s:\n%s", name.Name, old))
 		} else {
 			t.bot.Message(msg.Channel, fmt.Sprintf("%s non aveva ordinato nulla", name.Name))
 		}
-
-		order.Save(t.brain)
 	})
 }
@@ -1,122 +1,638 @@
 package tinabot
 
-// HelpStr is Tinabot help string
-const HelpStr = `Elenco comandi supportati da tinabot9000:
-
-*PER ORDINARE UN PIATTO:*
-‘@Tinabot 9000 per <utente> <ordine>‘
-*<utente>* può essere ‘me‘ per ordinare per se stessi, oppure il nome di un altro utente slack (che verrà avvisato!). *E' possibile ordinare per ospiti esterni senza utente slack* chiamandoli ‘guest_<nome>‘.
-
-*<ordine>* può essere una serie di stringhe separate da spazi, tinabot9000 cercherà di fare un il meglio che può per capire il piatto tra le voci presenti nel menù.
-
-*E' possibile usare le seguenti funzionalità speciali per personalizzare l'ordine:*
-*&* - E commerciale
-Se usato per unire due o più stringhe, permette di personalizzare il secondo, aggiungendo uno o più contorni. Il piatto creato sarà indicato come *un* solo secondo.
-es.
-‘‘‘
-@Tinabot 9000 per me scorfano & piselli
-
-Tinabot 9000:
-Trovato: Scorfano con ginger lime (secondi piatti)
-Trovato: Piselli (contorni)
-Piatto personalizzato: Scorfano con ginger lime con Piselli
-Ok, aggiunto 1 piatto per batt
-‘‘‘
-
-*+* - Più
-Se usato per unire due o più stringhe, permette, con un’unico ordine, di richiedere *più di una portata alla volta*: un primo e un secondo, un secondo e una frutta, etc… I piatti ordinati saranno assegnati allo stesso utente
-es. 
-‘‘‘
-@Tinabot 9000 per me fusilli + peposo
-Tinabot 9000:
-Trovato: Fusilli con salsiccia pomodoro e olive (primi piatti)
-Trovato: Peposo con patate in umido (secondi piatti)
-Ok, aggiunti 2 piatti per batt
-‘‘‘
-
-*"* - Virgolette
-Inserendo una stringa tra virgolette tinabot9000 aggiungerà *testualmente* quello che avete scritto all’ordine. E’ una funzionalità utile da usare in casi particolari (tipo per le insalate o per il “senza glutine”). Mi raccomando, è pensato per essere usato in situazioni particolari, quindi non abusatene!
-‘‘‘
-@Tinabot 9000 per me "pasta senza glutine al ragù"
-
-Tinabot 9000:
-Aggiungo testualmente: 'pasta senza glutine al ragù'
-Ok, aggiunto 1 piatto per batt
-‘‘‘
-
-*come* - Copia ordine
-Indicando "per me come <utente>", tinabot9000 copierà l'ordine dell'utente indicato
-‘‘‘
-@Tinabot 9000 per me come djeasy
-
-Tinabot 9000:
-Ok, copio l'ordine di djeasy:
-Baccalà alla livornese con ceci
-Ok, aggiunto 1 piatto per batt
-‘‘‘
-
-Le funzionalità speciali possono anche essere combinate tra loro
-
-*PER CANCELLARE UN ORDINE:*
-‘@Tinabot 9000 per <utente> niente‘
-*<utente>* può essere ‘me‘ o il nome di un altro utente slack (che verrà avvisato). 
-
-*PER VEDERE I PIATTI ORDINATI:*
-‘@Tinabot 9000 ordine‘
-
-*PER INVIARE LA MAIL AL TUTTOBENE:*
-‘@Tinabot 9000 email‘
-Verrà fornito un link che autocompone una mail nel client di posta locale. Chiunque può inviare la mail al tuttobene.
-
-*PER VEDERE IL MENÙ DEI PIATTI:*
-‘@Tinabot 9000 menu‘
-
-*PER IMPOSTARE IL MENÙ DEI PIATTI:*
-‘@Tinabot 9000 setmenu <stringa menu>‘
-*<stringa menu>* può essere multilinea. E' sufficiente copiare le celle dal file excel inviato per mail dal tuttobene. Chiunque può impostare il menù.
-
-*PER IMPOSTARE IL REMINDER:*
-Nel caso tu abbia attivato la funzionalità reminder, se è impostato un menù valido per il giorno e non hai ancora ordinato, alle 11:50 ti verrà inviato un messaggio privato contenente il menù del giorno.
-Ecco come fare:
-‘@Tinabot 9000 remind <giorni>‘
-*<giorni>* può essere ‘on‘ per indicare tutti i giorni:
-‘‘‘
-@Tinabot 9000 remind on
-Tinabot 9000:
-Reminder attivo tutti i giorni
-‘‘‘
-
-E' anche possibile specificare i singoli giorni separati da virgola:
-‘‘‘
-@Tinabot 9000 remind lun, mar
-Tinabot 9000:
-Reminder attivo lunedì, martedì
-‘‘‘
-
-Per disattivare il reminder usare ‘off‘:
-‘‘‘
-@Tinabot 9000 remind off
-Tinabot 9000:
-Reminder disattivato
-‘‘‘
-
-*PER VEDERE LO STATO DEL REMINDER:*
-‘@Tinabot 9000 remind‘
-
-*PER SEGNARE IL PRANZO:*
-Tinabot 9000 è in grado di segnare *in automatico* il pranzo sul foglio google di riepilogo, usato dall'amministrazione per tenere traccia dei pasti e dei buoni.
-Se hai ordinato il pranzo con Tinabot, *verrà registrato in automatico alle 14:00*.
-E' comunque possibile modificare quanto segnato sul foglio manualmente:
-‘@Tinabot 9000 segna <cibo>‘
-*<cibo>* può essere:
-‘P‘, ‘PS‘, ‘PD‘, ‘S‘, ‘SD‘, ‘D‘, ‘PSD‘ oppure ‘Niente‘
-
-es. 
-‘‘‘
-@Tinabot 9000 segna p
-Tinabot 9000:
-Ok, segnato 'P' per batt sul foglio dei pranzi
-‘‘‘
-
-
-`
+import (
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/intent"
+	"github.com/develersrl/lunches/pkg/slackbot"
+)
+
+// The usages of the commands, declared with their handlers in AddCommands.
+// In the texts ‘ stands for the backquote, rendered as code by Slack.
+
+var usageHelp = []intent.Usage{{
+	Syntax:      "aiuto [<comando>]",
+	Description: "mostra l'elenco dei comandi, o i dettagli e gli esempi di *<comando>*, es. ‘aiuto per‘",
+	Details: `Il comando ‘/lunch‘ funziona in ogni canale e in privato, senza nominare il bot, e risponde solo a te: ‘/lunch menu‘, ‘/lunch order <ordine>‘, ‘/lunch cancel‘ e ‘/lunch summary‘. Gli altri comandi funzionano come scrivendo al bot, es. ‘/lunch conto‘.
+Aprendo la scheda *Home* di Tinabot 9000 vedi il tuo ordine, il menù di oggi e quanto hai speso questo mese, aggiornati a ogni modifica. I pulsanti ‘Ripeti ieri‘ e ‘Cancella ordine‘ funzionano come ‘come ieri‘ e ‘per me niente‘.`,
+	Examples: []string{"aiuto", "aiuto per"},
+}}
+
+var usageFor = []intent.Usage{{
+	Syntax:      "per <utente> <ordine>",
+	Description: "ordina i piatti di *<ordine>* per te (‘me‘), per un altro utente slack, che verrà avvisato, o per un ospite",
+	Details: `*<utente>* può essere ‘me‘ per ordinare per se stessi, oppure il nome di un altro utente slack (che verrà avvisato!). *E' possibile ordinare per ospiti esterni senza utente slack* chiamandoli ‘guest_<nome>‘: la quota dell'ospite verrà addebitata a chi ha ordinato per lui. Dopo il nome si possono anche mettere i due punti, es. ‘per guest_mario: lasagne‘
+*<ordine>* può essere una serie di stringhe separate da spazi, tinabot9000 cercherà di fare il meglio che può per capire il piatto tra le voci presenti nel menù.
+
+*E' possibile usare le seguenti funzionalità speciali per personalizzare l'ordine, anche combinandole tra loro:*
+*&* - unisce un secondo e uno o più contorni in *un* solo piatto personalizzato.
+*+* - ordina *più di una portata alla volta*, es. un primo e un secondo, assegnati allo stesso utente.
+*"* - una stringa tra virgolette viene aggiunta *testualmente* all'ordine. Utile in casi particolari, come le insalate o il “senza glutine”, quindi non abusatene!
+*nota:* - "+ nota: <testo>" dopo un piatto riporta il testo nell'ordine e nella mail per il ristorante.
+*altrimenti* - con "<piatto>, altrimenti <altro piatto>" (o "oppure") viene ordinato il primo piatto, ma se dovesse essere esaurito si passa in automatico all'alternativa, avvisandoti.
+*<n>* - un numero davanti al piatto lo ordina più volte, es. ‘2 pizza margherita‘ o ‘2x pizza margherita‘.
+*-* - un piatto preceduto da ‘-‘ viene tolto dal tuo ordine attuale, tenendo gli altri.
+*come* - "per me come <utente>" copia l'ordine dell'utente indicato, "per me come ieri" ripete il tuo ultimo ordine.`,
+	Examples: []string{
+		"per me lasagne",
+		"per me scorfano & piselli",
+		"per me fusilli + peposo",
+		`per me "pasta senza glutine al ragù"`,
+		"per me tagliata + nota: al sangue",
+		"per me tagliata, altrimenti pollo",
+		"per me 2 pizza margherita",
+		"per me -pollo + tagliata",
+		"per me come djeasy",
+	},
+}, {
+	Syntax:      "per <giorno> <ordine>",
+	Description: "ordina per te in anticipo, se il menù di quel giorno è già stato impostato",
+	Details:     "*<giorno>* può essere ‘domani‘, un giorno della settimana (il prossimo) o una data come ‘14/03‘. ‘per <giorno> niente‘ cancella l'ordine. L'ordine viene aggiunto automaticamente a quello del giorno.",
+	Examples:    []string{"per giovedì: lasagne", "per 14/03 tagliata", "per giovedì niente"},
+}, {
+	Intent:      intent.Cancel,
+	Syntax:      "per <utente> niente",
+	Description: "cancella l'ordine di *<utente>*, ‘me‘ o un altro utente slack, che verrà avvisato",
+	Examples:    []string{"per me niente"},
+}}
+
+var usageAsBefore = []intent.Usage{{
+	Syntax:      "come ieri",
+	Description: "ripete il tuo ultimo ordine cercando gli stessi piatti nel menù di oggi, ‘come settimana scorsa‘ quello di una settimana fa",
+	Examples:    []string{"come ieri", "come settimana scorsa"},
+}}
+
+var usageRemove = []intent.Usage{{
+	Syntax:      "togli <piatto>",
+	Description: "toglie dal tuo ordine il piatto indicato lasciando gli altri",
+	Details:     "Se più piatti corrispondono, tinabot9000 li elenca numerati ed è possibile indicare il numero.",
+	Examples:    []string{"togli pollo", "togli 2"},
+}}
+
+var usageUndo = []intent.Usage{{
+	Syntax:      "annulla",
+	Description: "riporta il tuo ordine a com'era prima dell'ultima modifica della giornata, ‘ripristina‘ rifà la modifica annullata",
+}}
+
+var usageSoldOut = []intent.Usage{{
+	Syntax:      "esaurito <piatto>",
+	Description: "segnala un piatto esaurito: chi lo ha ordinato passa alla sua alternativa, se l'ha indicata, e viene avvisato",
+	Examples:    []string{"esaurito tagliata"},
+}}
+
+var usageLate = []intent.Usage{{
+	Syntax:      "richieste",
+	Description: "mostra le richieste degli ordini in ritardo in attesa",
+	Details:     "Se si ordina dopo la scadenza impostata con ‘promemoria scadenza‘, o quando l'ordine è bloccato o inviato, tinabot9000 crea una richiesta e la manda agli amministratori.",
+}, {
+	Syntax:      "approva <n>",
+	Description: "approva la richiesta *<n>*, che viene aggiunta all'ordine mostrando la modifica da mandare al ristorante, ‘rifiuta <n>‘ la rifiuta",
+	Examples:    []string{"approva 1", "rifiuta 2"},
+}}
+
+var usageShowState = []intent.Usage{{
+	Syntax:      "stato ordine",
+	Description: "mostra lo stato dell'ordine di oggi",
+}}
+
+var usageState = []intent.Usage{{
+	Syntax:      "stato ordine <stato>",
+	Description: "cambia lo stato dell'ordine di oggi",
+	Details:     "L'ordine passa per gli stati ‘aperto‘ → ‘bloccato‘ → ‘inviato‘ → ‘consegnato‘ → ‘archiviato‘. Solo un ordine aperto può essere modificato, un ordine bloccato può essere riaperto. Quando diventa ‘consegnato‘ chi ha ordinato viene invitato a votare i piatti.",
+	Examples:    []string{"stato ordine bloccato"},
+}}
+
+var usageStatus = []intent.Usage{{
+	Syntax:      "stato",
+	Description: "controlla che Tinabot riesca a raggiungere la memoria dove salva gli ordini",
+}}
+
+var usageOrder = []intent.Usage{{
+	Syntax:      "ordine",
+	Description: "mostra i piatti ordinati oggi",
+}}
+
+var usageMyOrder = []intent.Usage{{
+	Syntax:      "cosa ho ordinato?",
+	Description: "mostra solo a te il tuo ordine di oggi e quanto costa",
+}}
+
+var usageMissing = []intent.Usage{{
+	Syntax:      "mancanti [avvisa|escludimi|includimi]",
+	Description: "elenca i membri del canale che non hanno ancora ordinato, con ‘avvisa‘ li menziona",
+	Details:     "Si può scrivere anche ‘chi manca?‘. ‘mancanti escludimi‘ ti toglie dall'elenco se non pranzi mai in ufficio, ‘mancanti includimi‘ ti rimette.",
+}}
+
+var usageTemplate = []intent.Usage{{
+	Intent:      intent.Order,
+	Syntax:      "modello <nome>: <n> <piatto>[, <n> <piatto>...]",
+	Description: "salva un modello per il pranzo ricorrente di un team",
+	Examples:    []string{`modello riunione lunedì: 5 pizze margherita, 2 "acqua naturale"`},
+}, {
+	Intent:      intent.Order,
+	Syntax:      "modello usa <nome>",
+	Description: "aggiunge all'ordine i piatti del modello per l'utente ‘team_<nome>‘, a carico di chi lo usa",
+}, {
+	Syntax:      "modello togli <nome>",
+	Description: "cancella il modello, ‘modelli‘ mostra quelli salvati",
+}}
+
+var usageGroup = []intent.Usage{{
+	Syntax:      "gruppo [<nome>|off]",
+	Description: "imposta il tuo gruppo di consegna: l'ordine, il conto e la mail per il ristorante vengono divisi per gruppo, ognuno con il suo totale",
+	Examples:    []string{"gruppo ufficio 2"},
+}}
+
+var usageDiet = []intent.Usage{{
+	Syntax:      "dieta [<voce>|togli <voce>|off]",
+	Description: "imposta le tue preferenze alimentari, quando ordini un piatto che non le rispetta tinabot9000 ti avvisa",
+	Details:     "*<voce>* può essere ‘vegetariano‘, ‘vegano‘, ‘maiale‘, ‘noci‘, ‘glutine‘, ‘lattosio‘ oppure un qualsiasi ingrediente da evitare.",
+	Examples:    []string{"dieta vegetariano", "dieta togli noci"},
+}}
+
+var usageNutrition = []intent.Usage{{
+	Syntax:      "nutrizione [on|off]",
+	Description: "mostra solo a te una stima delle calorie e dei macronutrienti del tuo ordine di oggi e dei pranzi della settimana, con ‘on‘ te la manda ogni lunedì per la settimana precedente",
+	Details:     "Sono stime indicative, in base alle parole nei nomi dei piatti o alla loro portata.",
+}, {
+	Syntax:      "nutrizione valori [<parola> <kcal> <proteine> <carboidrati> <grassi>]",
+	Description: "mostra o imposta la stima di una porzione dei piatti con *<parola>* nel nome, al posto di quella predefinita",
+	Examples:    []string{"nutrizione valori parmigiana 600 25 30 40"},
+}, {
+	Syntax:      "nutrizione togli <parola>",
+	Description: "torna alla stima predefinita per i piatti con *<parola>* nel nome",
+}}
+
+var usageAdvanceOrder = []intent.Usage{{
+	Syntax:      "ordine <giorno>",
+	Description: "mostra quello che è stato ordinato finora in anticipo per *<giorno>*",
+	Examples:    []string{"ordine domani"},
+}}
+
+var usageOrderMenu = []intent.Usage{{
+	Syntax:      "ordina",
+	Description: "mostra solo a te il menù di oggi, con una tendina per ogni portata: scegli i piatti e premi ‘Ordina‘, senza il rischio di scriverli male",
+}}
+
+var usageReactions = []intent.Usage{{
+	Syntax:      "reazioni [on|off]",
+	Description: "fa pubblicare il menù anche numerato, per ordinare con le reazioni",
+	Details:     "Si ordina un piatto reagendo col suo numero, es. :one:, e lo si toglie dall'ordine togliendo la reazione. Le conferme arrivano in privato.",
+}}
+
+var usageExport = []intent.Usage{{
+	Syntax:      "esporta [csv|xlsx]",
+	Description: "carica nel canale un foglio con i piatti di ogni utente e i totali per piatto, in formato Excel se non indicato",
+}}
+
+var usageBill = []intent.Usage{{
+	Syntax:      "conto",
+	Description: "mostra il prezzo dei piatti ordinati e la quota di ciascuno",
+}}
+
+var usagePaid = []intent.Usage{{
+	Syntax:      "ho pagato",
+	Description: "registra che hai pagato tu l'ordine di oggi: ogni altro utente ti deve la propria quota",
+}}
+
+var usageBalance = []intent.Usage{{
+	Syntax:      "quanto devo?",
+	Description: "mostra solo a te a chi devi dei soldi e chi li deve a te",
+}}
+
+var usageSettle = []intent.Usage{{
+	Syntax:      "saldato <utente> [importo]",
+	Description: "registra che hai restituito a *<utente>* l'importo indicato, o tutto il debito se non indichi l'importo",
+	Examples:    []string{"saldato mario 5.50"},
+}}
+
+var usageMonthly = []intent.Usage{{
+	Syntax:      "bilancio",
+	Description: "mostra solo a te quanto hai speso nel mese corrente",
+}}
+
+var usageReport = []intent.Usage{{
+	Syntax:      "report settimanale|mensile [scorso] [--blocchi|--markdown|--csv|--grafico]",
+	Description: "mostra la spesa della settimana o del mese, in corso o precedente: il totale, la media a ordine, la spesa per centro di costo e per persona e i giorni più cari",
+	Details:     "In modalità privacy anonima la spesa per persona si vede solo in messaggio diretto agli amministratori, la tua spesa la vedi solo tu. I centri di costo sono quelli di ‘contabilità‘. Con un formato il report è completo: ‘--blocchi‘ lo pubblica come messaggio a blocchi, ‘--markdown‘ e ‘--csv‘ come file, ‘--grafico‘ come immagine della prima tabella.",
+	Examples:    []string{"report settimanale", "report mensile scorso", "report mensile --grafico"},
+}, {
+	Syntax:      "report automatico on|off",
+	Description: "pubblica nel canale il report della settimana precedente ogni lunedì mattina, o non più",
+}}
+
+var usageVariety = []intent.Usage{{
+	Syntax:      "varietà [<aaaa-mm>] [--blocchi|--markdown|--csv|--grafico]",
+	Description: "mostra quanto sono vari i menù del mese, quello corrente se non indicato: i piatti più ripetuti, quelli nuovi e i prezzi medi di ogni portata",
+	Details:     "Utile da girare al ristorante quando i menù diventano ripetitivi, anche come file con ‘--csv‘ o ‘--markdown‘. I menù vengono conservati per 30 giorni, i mesi precedenti possono essere incompleti.",
+	Examples:    []string{"varietà", "varietà 2019-03", "varietà --csv"},
+}}
+
+var usageLeaderboard = []intent.Usage{{
+	Syntax:      "classifica [<aaaa-mm>] [--blocchi|--markdown|--csv|--grafico]",
+	Description: "mostra la classifica del mese, quello corrente se non indicato: chi ha ordinato più spesso, le serie di giorni di fila più lunghe e chi ha ordinato per primo più volte",
+	Details:     "Contano solo i giorni in cui qualcuno ha ordinato. Le tue statistiche le vedi solo tu. Con un formato le classifiche comprendono tutti, ‘--grafico‘ mostra le presenze.",
+	Examples:    []string{"classifica", "classifica 2019-03", "classifica --grafico"},
+}, {
+	Syntax:      "classifica automatica [on|off]",
+	Description: "pubblica nel canale la classifica del mese precedente il primo di ogni mese, o non più",
+}}
+
+var usagePrices = []intent.Usage{{
+	Syntax:      "prezzi [<aaaa-mm>]",
+	Description: "mostra come sono cambiati i prezzi dei piatti nel mese, quello corrente se non indicato: gli aumenti e i cali di ogni portata e la variazione media",
+	Details:     "Il prezzo di un piatto è confrontato con quello dell'ultimo menù in cui c'era. Le variazioni vengono conservate per 13 mesi.",
+	Examples:    []string{"prezzi", "prezzi 2019-03"},
+}, {
+	Syntax:      "prezzi avvisi [on|off]",
+	Description: "segnala nel canale i piatti il cui prezzo aumenta, con il riepilogo del mese precedente il primo di ogni mese, o non più",
+}}
+
+var usageForecast = []intent.Usage{{
+	Syntax:      "previsione [<giorno>]",
+	Description: "stima quante persone ordineranno e quanti piatti di ogni portata, domani se il giorno non è indicato, per avvisare prima il ristorante",
+	Details:     "La stima è la media degli ultimi 4 giorni della stessa settimana in cui qualcuno ha ordinato, o degli ultimi 4 giorni se sono troppo pochi. Il giorno può essere ‘domani‘, un giorno della settimana o una data come ‘14/03‘.",
+	Examples:    []string{"previsione", "previsione venerdì"},
+}}
+
+var usageRules = []intent.Usage{{
+	Syntax:      "regole max <tipo> <n>|off",
+	Description: "limita il numero di piatti di un tipo (‘primo‘, ‘secondo‘, ‘contorno‘, ‘vegetariano‘, ‘frutta‘, ‘dolce‘, ‘panino‘) che ognuno può ordinare",
+	Details:     "Senza argomenti ‘regole‘ mostra le regole correnti.",
+	Examples:    []string{"regole max primo 1"},
+}, {
+	Syntax:      "regole contorno on|off",
+	Description: "permette di ordinare i contorni solo insieme a un secondo",
+}, {
+	Syntax:      "regole proposta on|off",
+	Description: "impedisce di ordinare la proposta del giorno insieme ad altri piatti",
+}}
+
+var usagePrivacy = []intent.Usage{{
+	Syntax:      "privacy [anonimo|completo]",
+	Description: "in modalità anonima ‘ordine‘ e ‘conto‘ mostrano solo il numero dei piatti",
+	Details:     "Il dettaglio per persona resta visibile agli amministratori in messaggio diretto e nella mail per il ristorante.",
+}}
+
+var usageForgetMe = []intent.Usage{{
+	Syntax:      "dimenticami [conferma]",
+	Description: "cancella tutti i tuoi dati: lo storico degli ordini, gli ordini di oggi e in anticipo, le preferenze, i voti ai piatti e i debiti",
+	Details:     "Senza ‘conferma‘ mostra solo cosa verrà cancellato. I dati vengono cancellati in tutti gli uffici, con le loro versioni precedenti, e non si possono recuperare: restano solo nei backup del brain, finché non vengono sostituiti dai nuovi.",
+}}
+
+var usageForget = []intent.Usage{{
+	Syntax:      "dimentica <utente> [conferma]",
+	Description: "cancella tutti i dati di un altro utente, come se avesse scritto ‘dimenticami‘",
+	Examples:    []string{"dimentica @mario conferma"},
+}}
+
+var usageRetention = []intent.Usage{{
+	Syntax:      "conservazione [storico|voti <mesi>|off]",
+	Description: "imposta per quanti mesi vengono conservati lo storico degli ordini e i voti ai piatti, quelli più vecchi vengono cancellati ogni giorno",
+	Details:     "Senza argomenti mostra i limiti correnti, con ‘off‘ i dati vengono conservati per sempre. I report dei mesi cancellati restano vuoti.",
+	Examples:    []string{"conservazione storico 12", "conservazione voti off"},
+}}
+
+var usageRateLimit = []intent.Usage{{
+	Syntax:      "limiti [utente|canale <comandi>/<durata>|off]",
+	Description: "imposta quanti comandi può dare ogni utente e quanti ne possono arrivare da ogni canale, i comandi oltre il limite vengono ignorati",
+	Details:     "Senza argomenti mostra i limiti correnti, di base 10 comandi al minuto per utente e 30 per canale. Con ‘off‘ il limite viene tolto.",
+	Examples:    []string{"limiti utente 5/30s", "limiti canale off"},
+}}
+
+var usageConfig = []intent.Usage{{
+	Syntax:      "config [<chiave> <valore>|off]",
+	Description: "mostra o cambia la configurazione del bot comune a tutti gli uffici: canali, amministratori, scadenza, consegna e funzioni accese",
+	Details:     "Senza argomenti mostra ogni chiave con il suo valore e da dove viene. Le chiavi modificabili vengono aggiornate subito su tutte le istanze del bot, le altre si cambiano solo nel file di configurazione o con le variabili d'ambiente. Con ‘off‘ torna il valore di partenza.",
+	Examples:    []string{"config deadline 11:30", "config features -sondaggi", "config deadline off"},
+}}
+
+var usageFlag = []intent.Usage{{
+	Syntax:      "flag [<nome> on|off|<percentuale>%|on qui|off qui|auto qui]",
+	Description: "attiva le funzioni sperimentali in una parte dei canali, per provarle poco a poco senza aggiornare il bot",
+	Details:     "Senza argomenti mostra le funzioni sperimentali e dove sono attive. Con una percentuale la funzione si attiva in quella parte dei canali, sempre gli stessi. Con ‘qui‘ la funzione si attiva o si spegne solo nel canale, a prescindere dalla percentuale, e con ‘auto qui‘ il canale torna a seguire la percentuale.",
+	Examples:    []string{"flag menu-interattivo 20%", "flag menu-interattivo on qui", "flag menu-interattivo off"},
+}}
+
+var usageSimulate = []intent.Usage{{
+	Syntax:      "simula [redis|slack <percentuale>% [<durata>]|off]",
+	Description: "simula i guasti di Redis o di Slack per provare come vengono gestiti, solo nell'ambiente di staging",
+	Details:     "Senza argomenti mostra i guasti simulati. La percentuale indica quante operazioni falliscono: con Redis come se fosse irraggiungibile, con Slack come se rifiutasse le chiamate oltre il limite. Il guasto dura 5 minuti se non indicato, con ‘off‘ finiscono subito tutti.",
+	Examples:    []string{"simula redis 50%", "simula slack 100% 1m", "simula off"},
+}}
+
+var usageBudget = []intent.Usage{{
+	Syntax:      "budget [<importo>|off|blocca|avvisa]",
+	Description: "imposta la spesa massima giornaliera per persona: gli ordini che la superano vengono segnalati (‘avvisa‘) o rifiutati (‘blocca‘)",
+	Details:     "Senza argomenti mostra l'impostazione corrente.",
+	Examples:    []string{"budget 8", "budget blocca"},
+}}
+
+var usageSubsidy = []intent.Usage{{
+	Syntax:      "contributo [<importo>|off]",
+	Description: "imposta la quota del pranzo pagata dall'azienda, il conto mostrerà la parte aziendale e quella personale",
+	Details:     "Senza argomenti mostra l'impostazione corrente.",
+	Examples:    []string{"contributo 5"},
+}}
+
+var usageAccounting = []intent.Usage{{
+	Syntax:      "contabilità",
+	Description: "mostra le impostazioni del report mensile dei pranzi per le note spese",
+	Details:     "All'inizio di ogni mese il report CSV del mese precedente, con il costo dei pranzi di ogni persona per giorno, viene mandato agli indirizzi della contabilità.",
+}, {
+	Syntax:      "contabilità colonne <colonna>,<colonna>...",
+	Description: "sceglie le colonne del report tra data, utente, nome, centro, importo, azienda, dipendente, pagato_da e piatti",
+	Examples:    []string{"contabilità colonne data,nome,centro,dipendente"},
+}, {
+	Syntax:      "contabilità separatore <carattere>",
+	Description: "imposta il separatore delle colonne del report, la virgola se non impostato",
+	Examples:    []string{"contabilità separatore ;"},
+}, {
+	Syntax:      "contabilità centro <centro> <utente>...",
+	Description: "assegna gli utenti a un centro di costo",
+	Details:     "Con ‘contabilità centro predefinito <centro>‘ imposta il centro di costo degli utenti non assegnati.",
+	Examples:    []string{"contabilità centro R&D @mario @luigi"},
+}, {
+	Syntax:      "contabilità aggiungi|togli <email>",
+	Description: "aggiunge o toglie un indirizzo a cui mandare il report mensile",
+}, {
+	Syntax:      "contabilità esporta [<aaaa-mm>]",
+	Description: "carica sul canale il report del mese, quello corrente se non indicato",
+}}
+
+var usageSnapshot = []intent.Usage{{
+	Syntax:      "salva ordine <nome>",
+	Description: "salva una copia dell'ordine di oggi, utile prima di modifiche importanti",
+}, {
+	Syntax:      "ripristina ordine <nome>",
+	Description: "riporta l'ordine a com'era quando è stato salvato",
+}}
+
+var usageSnapshots = []intent.Usage{{
+	Syntax:      "salvataggi",
+	Description: "mostra i salvataggi dell'ordine di oggi",
+}}
+
+var usageClearOrder = []intent.Usage{{
+	Syntax:      "cancella ordine",
+	Description: "cancella l'intero ordine di oggi",
+}}
+
+var usageEmailPreview = []intent.Usage{{
+	Syntax:      "anteprima email",
+	Description: "mostra la mail che verrà inviata in automatico al ristorante, con orario di consegna e indirizzo",
+}}
+
+var usageEmail = []intent.Usage{{
+	Syntax:      "email",
+	Description: "fornisce un link che autocompone la mail per il ristorante nel client di posta locale",
+}}
+
+var usageMenu = []intent.Usage{{
+	Syntax:      "menu [price]",
+	Description: "mostra il menù di oggi, con ‘price‘ anche i prezzi",
+	Details:     "Accanto ai piatti già votati c'è la media delle stelle, es. ★4.5 (2) con 2 voti.",
+}}
+
+var usageBest = []intent.Usage{{
+	Syntax:      "migliori piatti",
+	Description: "mostra i piatti con i voti migliori",
+}}
+
+var usageVote = []intent.Usage{{
+	Intent:      intent.QueryMenu,
+	Syntax:      "voto <piatto> <stelle> [commento]",
+	Description: "dà da 1 a 5 *<stelle>* a un piatto ordinato oggi, indicato col nome o col suo numero nell'ordine",
+	Details:     "Quando l'ordine passa a ‘consegnato‘ Tinabot 9000 chiede in privato a chi ha ordinato di votare i suoi piatti. Si può cambiare voto ripetendo il comando.",
+	Examples:    []string{"voto 1 5 ottima!", "voto tagliata 3"},
+}}
+
+var usageSetMenu = []intent.Usage{{
+	Syntax:      "setmenu <stringa menu>",
+	Description: "imposta il menù, copiando le celle dal file excel inviato per mail dal ristorante",
+	Details: `*<stringa menu>* può essere multilinea. Se il menù è di un giorno futuro viene conservato per gli ordini in anticipo.
+Il menù impostato viene fissato (pin) nel canale e sostituito il giorno successivo. Per non intasare il canale, le risposte di Tinabot 9000 e i promemoria vanno nel thread del menù del giorno, dove c'è anche il riepilogo dell'ordine, aggiornato a ogni modifica.
+In alternativa basta caricare il file excel del menù in un canale dove c'è Tinabot 9000, o mandarlo in privato se sei un amministratore: Tinabot 9000 ti mostra il menù letto e gli eventuali problemi (data, prezzi o portate mancanti), e lo imposta solo quando premi ‘Pubblica‘.`,
+}}
+
+var usageCron = []intent.Usage{{
+	Syntax:      "cron [add <pianificazione>;<comando>|rm <n>]",
+	Description: "mostra, aggiunge o toglie i comandi eseguiti periodicamente",
+	Examples:    []string{"cron add 0 12 * * 1-5;post #pranzo Ordinate!", "cron rm 0"},
+}}
+
+var usageAdmins = []intent.Usage{{
+	Syntax:      "admin",
+	Description: "mostra gli amministratori del canale",
+	Details:     "Gli amministratori di un canale lo sono anche in messaggio diretto, quelli elencati nella variabile ‘TINABOT_ADMINS‘, come ‘<team>:<ID utente>‘, lo sono ovunque nel loro workspace. Finché non c'è nessun amministratore chiunque può usare i comandi per gli amministratori.",
+}}
+
+var usageAdmin = []intent.Usage{{
+	Syntax:      "admin aggiungi|togli <utente>",
+	Description: "rende *<utente>* amministratore del canale o non più",
+	Examples:    []string{"admin aggiungi mario"},
+}}
+
+var usageOffice = []intent.Usage{{
+	Syntax:      "ufficio",
+	Description: "mostra l'ufficio del canale",
+	Details:     "I canali che non sono uffici usano l'ufficio principale.",
+}, {
+	Syntax:      "ufficio scegli #canale",
+	Description: "sceglie l'ufficio per cui si ordina in messaggio diretto, di solito è l'ultimo in cui si è scritto",
+}}
+
+var usageOfficeAdmin = []intent.Usage{{
+	Syntax:      "ufficio crea|elimina",
+	Description: "rende il canale un ufficio con menù, ordine, impostazioni e amministratori suoi, o non più",
+}}
+
+var usageRestaurant = []intent.Usage{{
+	Syntax:      "ristorante [consegna|indirizzo <valore>|off]",
+	Description: "mostra o cambia l'orario di consegna e l'indirizzo mandati al ristorante dell'ufficio, ‘off‘ torna a quelli predefiniti",
+	Examples:    []string{"ristorante consegna 12:45"},
+}}
+
+var usageCalendar = []intent.Usage{{
+	Syntax:      "calendario",
+	Description: "mostra i giorni in cui non si ordina il pranzo",
+	Details:     "Nei giorni di chiusura non è possibile ordinare, i reminder non vengono inviati e i cron non vengono eseguiti.",
+}, {
+	Syntax:      "calendario link",
+	Description: "mostra l'indirizzo del calendario iCal con le scadenze degli ordini e gli arrivi del pranzo, da aggiungere alla tua app del calendario",
+	Details:     "Le scadenze sono quelle dei promemoria, gli arrivi quelli dei dati del ristorante. I giorni di chiusura e i fine settimana non compaiono.",
+}}
+
+var usageCalendarAdmin = []intent.Usage{{
+	Syntax:      "calendario chiuso <gg/mm/aaaa> [motivo]",
+	Description: "aggiunge un giorno di chiusura, ‘calendario aperto <gg/mm/aaaa>‘ lo toglie",
+	Examples:    []string{"calendario chiuso 25/12/2026 Natale"},
+}, {
+	Syntax:      "calendario settimana <giorni>",
+	Description: "imposta i giorni della settimana senza pranzo",
+	Examples:    []string{"calendario settimana ven", "calendario settimana off"},
+}}
+
+var usagePollResults = []intent.Usage{{
+	Syntax:      "sondaggio",
+	Description: "mostra i voti del sondaggio di oggi sul ristorante",
+}}
+
+var usagePoll = []intent.Usage{{
+	Syntax:      "sondaggio <ristorante> vs <ristorante> [fino alle <hh:mm>]",
+	Description: "avvia il sondaggio sul ristorante di oggi, si vota coi pulsanti",
+	Details:     "Il sondaggio si chiude da solo all'orario indicato, o con ‘sondaggio chiudi‘: il ristorante più votato diventa quello di oggi.",
+	Examples:    []string{"sondaggio Tuttobene vs pizzeria vs poke fino alle 11:00"},
+}, {
+	Syntax:      "sondaggio chiudi",
+	Description: "chiude il sondaggio e annuncia il ristorante di oggi",
+}}
+
+var usageAlias = []intent.Usage{{
+	Syntax:      "soprannome [<nome>|off]",
+	Description: "mostra o cambia il nome breve con cui compari negli ordini",
+	Details:     "Il soprannome si può usare anche per ordinare per qualcuno, es. ‘per <soprannome> <piatto>‘.",
+	Examples:    []string{"soprannome Gigi"},
+}, {
+	Syntax:      "soprannomi",
+	Description: "elenca i soprannomi di tutti",
+}}
+
+var usageGuestLink = []intent.Usage{{
+	Syntax:      "link ospite",
+	Description: "crea un link con cui un ospite senza Slack può vedere il menù e ordinare una volta, a tuo carico",
+	Details:     "Il link vale per poche ore e solo per un ordine, che compare a nome di guest_<nome> come ordinato da te.",
+}}
+
+var usageMenuRequest = []intent.Usage{{
+	Syntax:      "richiesta menu [ore <hh:mm>|off]",
+	Description: "mostra, attiva all'orario indicato o disattiva la richiesta del menù, mandata dal lunedì al venerdì se il menù di oggi non è ancora impostato",
+	Details:     "Nei giorni di chiusura la richiesta non viene mandata. Senza email la richiesta è un promemoria per gli amministratori del canale.",
+	Examples:    []string{"richiesta menu ore 09:30"},
+}, {
+	Syntax:      "richiesta menu email <indirizzo>|off",
+	Description: "manda la richiesta per email al ristorante, invece che come promemoria agli amministratori",
+	Examples:    []string{"richiesta menu email info@tuttobene-bar.it"},
+}, {
+	Syntax:      "richiesta menu testo <testo>|off",
+	Description: "cambia il testo della richiesta, ‘$DATE‘ viene sostituito dal giorno",
+	Examples:    []string{"richiesta menu testo Ciao, ci mandate il menù di $DATE?"},
+}}
+
+var usageDigest = []intent.Usage{{
+	Syntax:      "digest [off]",
+	Description: "mostra o disattiva il digest via email, con il menù del mattino e il riepilogo dell'ordine alla scadenza",
+	Details:     "Il digest è per chi non segue il canale. Il riepilogo parte alla scadenza dei promemoria o quando l'ordine viene inviato; nei giorni di chiusura non parte niente.",
+}, {
+	Syntax:      "digest aggiungi|togli <indirizzo>",
+	Description: "aggiunge o toglie un indirizzo, ad esempio una mailing list, tra i destinatari del digest",
+	Examples:    []string{"digest aggiungi pranzo@develer.com"},
+}, {
+	Syntax:      "digest ore <hh:mm>|off",
+	Description: "manda il menù non prima dell'orario indicato, con ‘off‘ appena è disponibile",
+	Examples:    []string{"digest ore 10:30"},
+}}
+
+var usageWebhook = []intent.Usage{{
+	Syntax:      "webhook [aggiungi|togli <url>]",
+	Description: "mostra, aggiunge o toglie gli indirizzi a cui vengono mandati gli eventi del pranzo in JSON",
+	Details:     "Gli eventi sono ‘menu_published‘, ‘order_updated‘, ‘order_finalized‘ e ‘order_delivered‘. Le richieste sono firmate come quelle di Slack, con il segreto che ti viene mandato in privato quando aggiungi il webhook.",
+	Examples:    []string{"webhook aggiungi https://intranet.develer.com/pranzo/hook"},
+}}
+
+var usageRemind = []intent.Usage{{
+	Syntax:      "remind [<giorni>|rimanda [<minuti>]]",
+	Description: "mostra o imposta il reminder: se non hai ancora ordinato, all'orario dei promemoria ti viene inviato in privato il menù del giorno",
+	Details:     "*<giorni>* può essere ‘on‘ per indicare tutti i giorni, i singoli giorni separati da virgola oppure ‘off‘ per disattivarlo. ‘remind rimanda‘ rimanda il reminder dei minuti indicati, 15 se non indicati.",
+	Examples:    []string{"remind on", "remind lun, mar", "remind off", "remind rimanda 30"},
+}}
+
+var usageReminders = []intent.Usage{{
+	Syntax:      "promemoria ore <hh:mm>",
+	Description: "imposta l'orario dei reminder personali",
+	Details:     "Senza argomenti ‘promemoria‘ mostra le impostazioni correnti, ‘off‘ disattiva la singola impostazione.",
+}, {
+	Syntax:      "promemoria scadenza <hh:mm>",
+	Description: "imposta l'orario di scadenza degli ordini",
+}, {
+	Syntax:      "promemoria countdown <minuti>",
+	Description: "pubblica nel canale corrente un conto alla rovescia ai minuti indicati prima della scadenza",
+	Examples:    []string{"promemoria countdown 30,10"},
+}}
+
+var usageMark = []intent.Usage{{
+	Syntax:      "segna <cibo>",
+	Description: "segna il pranzo sul foglio google di riepilogo, usato dall'amministrazione per tenere traccia dei pasti e dei buoni",
+	Details:     "Se hai ordinato il pranzo con Tinabot, *verrà registrato in automatico alle 14:00*, il comando serve a correggerlo. *<cibo>* può essere ‘P‘, ‘PS‘, ‘PD‘, ‘S‘, ‘SD‘, ‘D‘, ‘PSD‘ oppure ‘Niente‘.",
+	Examples:    []string{"segna p"},
+}}
+
+// helpCategories are the sections of the help, by intent
+var helpCategories = []struct {
+	intent string
+	title  string
+}{
+	{intent.Order, "PER ORDINARE"},
+	{intent.Cancel, "PER CANCELLARE O MODIFICARE L'ORDINE"},
+	{intent.QueryMenu, "PER VEDERE E VOTARE IL MENÙ"},
+	{intent.QueryOrder, "PER VEDERE L'ORDINE E I CONTI"},
+	{intent.Other, "PER LE IMPOSTAZIONI PERSONALI"},
+	{intent.Admin, "PER GLI AMMINISTRATORI"},
+	{intent.Help, "PER L'AIUTO"},
+}
+
+// helpText renders the backquotes of the help texts
+func helpText(s string) string {
+	return strings.Replace(s, "‘", "`", -1)
+}
+
+// helpOverview lists the commands of usages by category
+func helpOverview(usages []intent.Usage) string {
+	out := []string{"Elenco comandi supportati da tinabot9000, scrivi `aiuto <comando>` per i dettagli e gli esempi:"}
+	for _, c := range helpCategories {
+		var lines []string
+		for _, u := range usages {
+			if u.Intent == c.intent {
+				lines = append(lines, "`"+u.Syntax+"` "+helpText(u.Description))
+			}
+		}
+		if len(lines) > 0 {
+			out = append(out, "*"+c.title+":*\n"+strings.Join(lines, "\n"))
+		}
+	}
+	return strings.Join(out, "\n\n")
+}
+
+// helpDetails shows everything about the commands of usages
+func helpDetails(usages []intent.Usage) string {
+	var out []string
+	for _, u := range usages {
+		desc := []rune(helpText(u.Description))
+		lines := []string{"*`@Tinabot 9000 " + u.Syntax + "`*", strings.ToUpper(string(desc[:1])) + string(desc[1:]) + "."}
+		if u.Details != "" {
+			lines = append(lines, helpText(u.Details))
+		}
+		if len(u.Examples) > 0 {
+			lines = append(lines, "Esempi:\n```@Tinabot 9000 "+strings.Join(u.Examples, "\n@Tinabot 9000 ")+"```")
+		}
+		out = append(out, strings.Join(lines, "\n"))
+	}
+	return strings.Join(out, "\n\n")
+}
+
+// Help shows the overview of the commands, or the details of the command
+// given as argument
+func (t *TinaBot) Help(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	command := strings.TrimSpace(args[1])
+	if command == "" {
+		t.bot.Message(msg.Channel, helpOverview(t.bot.Usages()))
+		return
+	}
+	usages := t.bot.Lookup(command)
+	if len(usages) == 0 {
+		t.bot.Message(msg.Channel, "Non conosco il comando '"+command+"', scrivi `aiuto` per l'elenco dei comandi")
+		return
+	}
+	t.bot.Message(msg.Channel, helpDetails(usages))
+}
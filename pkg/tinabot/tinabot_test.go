@@ -1,7 +1,13 @@
 package tinabot
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/grammar"
+	"github.com/develersrl/lunches/pkg/intent"
+	"github.com/develersrl/lunches/pkg/slackbot"
 )
 
 func TestSplitSep(t *testing.T) {
@@ -21,7 +27,7 @@ func TestSplitSep(t *testing.T) {
 	}
 
 	for i := range tests {
-		out := splitEsc(i, "&")
+		out := grammar.SplitEscaped(i, "&")
 		for j := range out {
 			if out[j] != tests[i][j] {
 				t.Fatalf("Error, wanted %v, got %v", tests[i], out)
@@ -30,3 +36,101 @@ func TestSplitSep(t *testing.T) {
 
 	}
 }
+
+func TestIntents(t *testing.T) {
+	bot := slackbot.New("B1", nil)
+	tb := New(bot, brain.NewBrainMock())
+	tb.AddCommands()
+
+	tests := map[string]string{
+		"per me lasagne":      intent.Order,
+		"come ieri":           intent.Order,
+		"ordina":              intent.Order,
+		"togli lasagne":       intent.Cancel,
+		"annulla":             intent.Cancel,
+		"Menu price":          intent.QueryMenu,
+		"ordine":              intent.QueryOrder,
+		"ordine domani":       intent.QueryOrder,
+		"cosa ho ordinato?":   intent.QueryOrder,
+		"aiuto":               intent.Help,
+		"setmenu lasagne":     intent.Admin,
+		"ripristina ordine":   intent.Admin,
+		"stato ordine chiuso": intent.Admin,
+		"stato ordine":        intent.QueryOrder,
+		"quanto devo?":        intent.Other,
+	}
+	for text, want := range tests {
+		res := bot.Route(text)
+		if res.Route == nil {
+			t.Errorf("%q not routed", text)
+			continue
+		}
+		assertEqual(t, res.Route.Intent, want, text)
+	}
+
+	res := bot.Route("mneu")
+	assertEqual(t, res.Route == nil, true, "")
+	assertEqual(t, len(res.Suggestions), 1, "")
+	assertEqual(t, res.Suggestions[0].Example, "menu", "")
+	assertEqual(t, len(bot.Route("setmneu").Suggestions), 0, "")
+
+	assertEqual(t, didYouMean([]string{"menu"}), "`menu`", "")
+	assertEqual(t, didYouMean([]string{"menu", "ordine", "aiuto"}), "`menu`, `ordine` o `aiuto`", "")
+}
+
+func TestHelp(t *testing.T) {
+	bot := slackbot.New("B1", nil)
+	tb := New(bot, brain.NewBrainMock())
+	tb.AddCommands()
+
+	overview := helpOverview(bot.Usages())
+	for _, s := range []string{"*PER ORDINARE:*\n`per <utente> <ordine>` ordina", "*PER CANCELLARE O MODIFICARE L'ORDINE:*\n`per <utente> niente`", "`voto <piatto> <stelle> [commento]`", "`aiuto [<comando>]`"} {
+		assertEqual(t, strings.Contains(overview, s), true, s)
+	}
+	assertEqual(t, strings.Contains(overview, "‘"), false, "")
+	assertEqual(t, strings.Contains(overview, "rmorder"), false, "")
+
+	// every route but the debug ones is documented
+	for _, u := range bot.Usages() {
+		assertEqual(t, u.Description != "", true, u.Syntax)
+	}
+	for _, text := range []string{"per me lasagne", "menu", "conto", "segna p", "ufficio crea", "chi manca?"} {
+		assertEqual(t, len(bot.Lookup(text)) > 0, true, text)
+	}
+
+	details := helpDetails(bot.Lookup("per"))
+	assertEqual(t, strings.Count(details, "*`@Tinabot 9000 per "), 3, "")
+	assertEqual(t, strings.Contains(details, "Ordina i piatti"), true, "")
+	assertEqual(t, strings.Contains(details, "```@Tinabot 9000 per me lasagne\n@Tinabot 9000 per me scorfano & piselli"), true, "")
+	assertEqual(t, len(bot.Lookup("boh")), 0, "")
+}
+
+// subscribersStore counts the subscriptions to its brain still open
+type subscribersStore struct {
+	*brain.Memory
+	open int
+}
+
+func (s *subscribersStore) Subscribe(channel string, fn func(msg []byte)) (func() error, error) {
+	stop, err := s.Memory.Subscribe(channel, fn)
+	if err != nil {
+		return nil, err
+	}
+	s.open++
+	return func() error {
+		s.open--
+		return stop()
+	}, nil
+}
+
+func TestCloseStopsSharing(t *testing.T) {
+	b := &subscribersStore{Memory: brain.NewMemory()}
+	for i := 0; i < 2; i++ {
+		tb := New(slackbot.New("B1", nil), b)
+		if b.open == 0 {
+			t.Fatal("events not shared")
+		}
+		tb.Close()
+		assertEqual(t, b.open, 0, "")
+	}
+}
@@ -15,12 +15,44 @@ import (
 type UserChoice struct {
 	DishMask uint
 	Dishes   []tuttobene.MenuRow
+	Note     string // free text customization for the restaurant, eg. "senza cipolla"
+
+	Alternatives []UserChoice `json:",omitempty"` // ranked choices to use if a dish is sold out
 }
 
 // Clear clears the current user choice
 func (u *UserChoice) Clear() {
 	u.DishMask = 0
 	u.Dishes = nil
+	u.Note = ""
+}
+
+// Contains returns true if one of the dishes is one of contents
+func (u *UserChoice) Contains(contents []string) bool {
+	for _, d := range u.Dishes {
+		for _, c := range contents {
+			if d.Content == c {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Available returns the first choice, among u and its alternatives, not
+// containing any of the unavailable dishes. The returned choice keeps the
+// following alternatives.
+func (u *UserChoice) Available(unavailable []string) (UserChoice, bool) {
+	if !u.Contains(unavailable) {
+		return *u, true
+	}
+	for i, a := range u.Alternatives {
+		if !a.Contains(unavailable) {
+			a.Alternatives = u.Alternatives[i+1:]
+			return a, true
+		}
+	}
+	return UserChoice{}, false
 }
 
 // Customized returns true if the user choosed to customize her dish adding one or more side dishes
@@ -99,6 +131,9 @@ func (u *UserChoice) String() string {
 		}
 		out += strings.Join(side, ", ")
 	}
+	if u.Note != "" {
+		out += " (" + u.Note + ")"
+	}
 	return out
 }
 
@@ -143,3 +178,12 @@ func (u UserChoiceArray) String() string {
 	}
 	return strings.Join(choices, "\n")
 }
+
+// Price returns the cost of all the choices
+func (u UserChoiceArray) Price() decimal.Decimal {
+	p := decimal.Zero
+	for _, c := range u {
+		p = p.Add(c.Price())
+	}
+	return p
+}
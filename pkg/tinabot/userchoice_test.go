@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/develersrl/lunches/pkg/grammar"
 	"github.com/develersrl/lunches/pkg/tuttobene"
 )
 
@@ -119,3 +120,24 @@ func TestUserChoice(t *testing.T) {
 
 	assertEqual(t, choice.Customized(), true, "")
 }
+
+func TestUserChoiceNote(t *testing.T) {
+	var choice UserChoice
+	s := tuttobene.MenuRow{
+		Content: "tagliata",
+		Type:    tuttobene.Secondo,
+	}
+	choice.Add(s)
+	choice.Note = "al sangue"
+	assertEqual(t, choice.String(), "tagliata (al sangue)", "")
+
+	items, err := grammar.Default().Parse("tagliata + nota: senza cipolla ")
+	assertEqual(t, err, nil, "")
+	assertEqual(t, items[0].Note, "senza cipolla", "")
+	items, err = grammar.Default().Parse("notare")
+	assertEqual(t, err, nil, "")
+	assertEqual(t, items[0].Note, "", "")
+
+	choice.Clear()
+	assertEqual(t, choice.Note, "", "")
+}
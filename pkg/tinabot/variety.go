@@ -0,0 +1,212 @@
+package tinabot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/analytics"
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/report"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+// how many dishes the variety report lists
+const (
+	varietyTopDishes = 10
+	varietyNewDishes = 15
+)
+
+// DishCatalog is the day each dish first appeared in a menu, by canonical
+// name, to tell the new dishes apart. It's kept since Since, unlike the
+// menus which expire after dayRetention.
+type DishCatalog struct {
+	Since string
+	First map[string]string
+}
+
+// Load loads the catalog from brain, empty if missing
+func (c *DishCatalog) Load(brain DataStore) error {
+	if err := brain.Get("dishes", c); err != nil {
+		*c = DishCatalog{}
+		return err
+	}
+	return nil
+}
+
+// Save saves the catalog to brain
+func (c *DishCatalog) Save(brain DataStore) error {
+	return brain.Set("dishes", *c)
+}
+
+// Add records the dishes of the menu, returns false if all were known
+func (c *DishCatalog) Add(m tuttobene.Menu) bool {
+	day := dayKey(m.Date)
+	changed := false
+	if c.First == nil {
+		c.First = make(map[string]string)
+	}
+	if c.Since == "" || day < c.Since {
+		c.Since = day
+		changed = true
+	}
+	for _, r := range m.Rows {
+		if r.Type == tuttobene.Empty || r.Type == tuttobene.Unknonwn {
+			continue
+		}
+		name := tuttobene.CanonicalName(r.Content)
+		if first, ok := c.First[name]; !ok || day < first {
+			c.First[name] = day
+			changed = true
+		}
+	}
+	return changed
+}
+
+// Known returns the dishes which appeared before date
+func (c *DishCatalog) Known(date time.Time) map[string]bool {
+	day := dayKey(date)
+	known := make(map[string]bool)
+	for name, first := range c.First {
+		if first < day {
+			known[name] = true
+		}
+	}
+	return known
+}
+
+// recordDishes adds the dishes of the menu to the catalog
+func recordDishes(brain DataStore, m tuttobene.Menu) error {
+	var c DishCatalog
+	c.Load(brain)
+	if !c.Add(m) {
+		return nil
+	}
+	return c.Save(brain)
+}
+
+// MenuVariety returns the variety of the menus of the period still kept,
+// and false if the catalog doesn't go back to its start, so the new dishes
+// are unknown
+func MenuVariety(brain DataStore, period analytics.Period) (analytics.Variety, bool) {
+	var menus []tuttobene.Menu
+	for d := period.Start; d.Before(period.End); d = d.AddDate(0, 0, 1) {
+		if m, err := LoadMenu(brain, d); err == nil && len(m.Rows) > 0 {
+			menus = append(menus, m)
+		}
+	}
+	var c DishCatalog
+	c.Load(brain)
+	return analytics.NewVariety(menus, c.Known(period.Start)), c.Since != "" && c.Since < dayKey(period.Start)
+}
+
+// FormatVariety returns the variety of the menus of month as a message, with
+// the new dishes if known
+func FormatVariety(v analytics.Variety, month time.Time, withNew bool) string {
+	title := "*Varietà dei menù di " + month.Format("01/2006") + "*"
+	if v.Menus == 0 {
+		return title + "\nNessun menù nel periodo"
+	}
+	summary := fmt.Sprintf("%d menù, %d piatti diversi", v.Menus, len(v.Dishes))
+	if withNew {
+		summary += fmt.Sprintf(", %d nuovi", len(v.New))
+	}
+	out := []string{title + "\n" + summary}
+
+	if recurring := v.Recurring(2); len(recurring) > 0 {
+		lines := []string{"*Piatti più ripetuti:*"}
+		for i, d := range recurring {
+			if i == varietyTopDishes {
+				lines = append(lines, fmt.Sprintf("e altri %d piatti ripetuti", len(recurring)-i))
+				break
+			}
+			lines = append(lines, fmt.Sprintf("%s: %d volte su %d", d.Name, d.Days, v.Menus))
+		}
+		out = append(out, strings.Join(lines, "\n"))
+	}
+
+	lines := []string{"*Prezzi medi:*"}
+	for _, s := range v.Sections {
+		line := fmt.Sprintf("%s: %d piatti", strings.Title(tuttobene.Titles[s.Type]), s.Dishes)
+		if s.Average.IsPositive() {
+			line += fmt.Sprintf(", in media €%s (da €%s a €%s)", s.Average.StringFixed(2), s.Min.StringFixed(2), s.Max.StringFixed(2))
+		}
+		lines = append(lines, line)
+	}
+	out = append(out, strings.Join(lines, "\n"))
+
+	if withNew && len(v.New) > 0 {
+		lines := []string{"*Piatti nuovi:*"}
+		for i, d := range v.New {
+			if i == varietyNewDishes {
+				lines = append(lines, fmt.Sprintf("e altri %d", len(v.New)-i))
+				break
+			}
+			lines = append(lines, d.Name)
+		}
+		out = append(out, strings.Join(lines, "\n"))
+	}
+	return strings.Join(out, "\n\n")
+}
+
+// VarietyReport returns the variety of the menus of month to render in the
+// other formats, with all the recurring dishes and the new ones if known
+func VarietyReport(v analytics.Variety, month time.Time, withNew bool) report.Report {
+	rep := report.Report{Name: "varieta-" + monthKey(month), Title: "Varietà dei menù di " + month.Format("01/2006")}
+	if v.Menus == 0 {
+		rep.Summary = []string{"Nessun menù nel periodo"}
+		return rep
+	}
+	summary := fmt.Sprintf("%d menù, %d piatti diversi", v.Menus, len(v.Dishes))
+	if withNew {
+		summary += fmt.Sprintf(", %d nuovi", len(v.New))
+	}
+	rep.Summary = []string{summary}
+
+	recurring := report.Table{Title: "Piatti più ripetuti", Columns: []string{"Piatto", "Portata", "Menù"}}
+	for _, d := range v.Recurring(2) {
+		recurring.Rows = append(recurring.Rows, report.Row{
+			Cells: []string{d.Name, tuttobene.Titles[d.Type], fmt.Sprint(d.Days)},
+			Value: float64(d.Days),
+		})
+	}
+	prices := report.Table{Title: "Prezzi medi", Columns: []string{"Portata", "Piatti", "Minimo", "Massimo", "Media"}}
+	for _, s := range v.Sections {
+		average, _ := s.Average.Float64()
+		prices.Rows = append(prices.Rows, report.Row{
+			Cells: []string{strings.Title(tuttobene.Titles[s.Type]), fmt.Sprint(s.Dishes), "€" + s.Min.StringFixed(2), "€" + s.Max.StringFixed(2), "€" + s.Average.StringFixed(2)},
+			Value: average,
+		})
+	}
+	rep.Tables = []report.Table{recurring, prices}
+	if withNew {
+		t := report.Table{Title: "Piatti nuovi", Columns: []string{"Piatto", "Portata"}}
+		for _, d := range v.New {
+			t.Rows = append(t.Rows, report.Row{Cells: []string{d.Name, tuttobene.Titles[d.Type]}})
+		}
+		rep.Tables = append(rep.Tables, t)
+	}
+	return rep
+}
+
+// VarietyCmd shows the variety of the menus of the current month, or of the
+// one given, in the format of the flag if any
+func (t *TinaBot) VarietyCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	format, f, ok := parseFormat(strings.Fields(args[1]))
+	if !ok {
+		t.bot.Message(msg.Channel, "Formato non valido, usa "+formatFlags())
+		return
+	}
+	month, err := parseMonth(strings.Join(f, " "), time.Now())
+	if err != nil {
+		t.bot.Message(msg.Channel, "Mese non valido, usa il formato aaaa-mm")
+		return
+	}
+	v, withNew := MenuVariety(t.brain, analytics.Month(month))
+	if format != "" {
+		t.postReport(msg, VarietyReport(v, month, withNew), format)
+		return
+	}
+	t.bot.Message(msg.Channel, FormatVariety(v, month, withNew))
+}
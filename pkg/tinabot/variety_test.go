@@ -0,0 +1,53 @@
+package tinabot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/develersrl/lunches/pkg/analytics"
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+)
+
+func TestMenuVariety(t *testing.T) {
+	b := brain.NewBrainMock()
+	// the menus expire, the dates are in the future
+	year := time.Now().Year() + 1
+	row := func(content string, typ tuttobene.MenuRowType, price int64) tuttobene.MenuRow {
+		return tuttobene.MenuRow{Content: content, Type: typ, Price: decimal.New(price, 0)}
+	}
+	feb := time.Date(year, 2, 28, 0, 0, 0, 0, time.UTC)
+	assertEqual(t, SaveMenu(b, tuttobene.Menu{Date: feb, Rows: []tuttobene.MenuRow{row("Risotto", tuttobene.Primo, 6)}}), nil, "")
+
+	march := time.Date(year, 3, 11, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		rows := []tuttobene.MenuRow{row("Pasta al pomodoro", tuttobene.Primo, 5), row("Risotto", tuttobene.Primo, 7)}
+		if i == 2 {
+			rows = append(rows, row("Pollo arrosto", tuttobene.Secondo, 8))
+		}
+		assertEqual(t, SaveMenu(b, tuttobene.Menu{Date: march.AddDate(0, 0, i), Rows: rows}), nil, "")
+	}
+
+	var c DishCatalog
+	assertEqual(t, c.Load(b), nil, "")
+	assertEqual(t, c.Since, dayKey(feb), "")
+	assertEqual(t, c.First["pasta al pomodoro"], dayKey(march), "")
+	assertEqual(t, c.Add(tuttobene.Menu{Date: march, Rows: []tuttobene.MenuRow{row("Risotto", tuttobene.Primo, 6)}}), false, "")
+
+	v, withNew := MenuVariety(b, analytics.Month(march))
+	assertEqual(t, withNew, true, "")
+	assertEqual(t, v.Menus, 3, "")
+	assertEqual(t, len(v.New), 2, "")
+
+	msg := FormatVariety(v, march, withNew)
+	assertEqual(t, strings.HasPrefix(msg, "*Varietà dei menù di "+march.Format("01/2006")+"*\n3 menù, 3 piatti diversi, 2 nuovi"), true, msg)
+	assertEqual(t, strings.Contains(msg, "Pasta al pomodoro: 3 volte su 3"), true, msg)
+	assertEqual(t, strings.Contains(msg, "Primi Piatti: 2 piatti, in media €6.00 (da €5.00 a €7.00)"), true, msg)
+	assertEqual(t, strings.Contains(msg, "*Piatti nuovi:*\nPasta al pomodoro\nPollo arrosto"), true, msg)
+
+	_, withNew = MenuVariety(b, analytics.Month(feb))
+	assertEqual(t, withNew, false, "the catalog starts in february")
+}
@@ -0,0 +1,177 @@
+package tinabot
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"github.com/develersrl/lunches/pkg/chat"
+	"github.com/develersrl/lunches/pkg/events"
+	"github.com/develersrl/lunches/pkg/jobs"
+	"github.com/develersrl/lunches/pkg/slackbot"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+	"github.com/develersrl/lunches/pkg/webhook"
+)
+
+// webhookEvents are the names of the events sent to the webhooks, by topic
+var webhookEvents = map[events.Topic]string{
+	events.MenuPublished:  "menu_published",
+	events.OrderUpdated:   "order_updated",
+	events.OrderClosed:    "order_finalized",
+	events.OrderDelivered: "order_delivered",
+}
+
+// Webhooks are the URLs of the other tools receiving the events of the bot
+type Webhooks []webhook.Hook
+
+// Load loads the webhooks from brain, none if missing
+func (w *Webhooks) Load(brain DataStore) error {
+	if err := brain.Get("webhooks", w); err != nil {
+		*w = nil
+		return err
+	}
+	return nil
+}
+
+// Save saves the webhooks to brain
+func (w Webhooks) Save(brain DataStore) error {
+	return brain.Set("webhooks", w)
+}
+
+func (w Webhooks) String() string {
+	if len(w) == 0 {
+		return "Nessun webhook impostato"
+	}
+	var urls []string
+	for _, h := range w {
+		urls = append(urls, h.URL)
+	}
+	return "Gli eventi vengono mandati a:\n" + strings.Join(urls, "\n")
+}
+
+// webhookData returns the data of an event as sent to the webhooks, nil if
+// the event isn't sent
+func webhookData(ev events.Event) interface{} {
+	switch d := ev.Data.(type) {
+	case tuttobene.Menu:
+		return NewAPIMenu(d)
+	case *Order:
+		return NewAPIOrder(d)
+	}
+	return nil
+}
+
+// SubscribeWebhooks sends the events published on bus to the webhooks set
+// in brain, queuing a job for each webhook, see HandleJobs. The events
+// shared by the other instances are sent by the instance publishing them.
+func SubscribeWebhooks(bus *events.Bus, brain DataStore, queue *jobs.Queue) {
+	for topic, name := range webhookEvents {
+		name := name
+		bus.Subscribe(topic, func(ev events.Event) {
+			if ev.Remote {
+				return
+			}
+			var hooks Webhooks
+			if hooks.Load(brain) != nil || len(hooks) == 0 {
+				return
+			}
+			data := webhookData(ev)
+			if data == nil {
+				return
+			}
+			p := webhook.Payload{Event: name, Time: ev.Time, Data: data}
+			for _, h := range hooks {
+				if err := queue.Push(jobWebhook, webhookJob{h, p}); err != nil {
+					slog.Error("Error queuing the webhook", "event", name, "url", h.URL, "err", err)
+				}
+			}
+		})
+	}
+}
+
+// newWebhookSecret returns a random secret to sign the requests of a webhook
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// parseURL returns the address of a link as formatted by Slack, e.g.
+// <https://a.it/hook|a.it/hook>
+func parseURL(s string) (string, bool) {
+	s = strings.TrimPrefix(strings.TrimSuffix(s, ">"), "<")
+	s = strings.SplitN(s, "|", 2)[0]
+	u, err := url.Parse(s)
+	return s, err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+// WebhookCmd shows, adds or removes the webhooks receiving the events of
+// the bot. The secret of a new webhook is sent to the admin in private.
+func (t *TinaBot) WebhookCmd(bot *slackbot.Bot, msg *slackbot.BotMsg, user *chat.User, args ...string) {
+	if !t.canAdmin(msg, user) {
+		t.bot.Message(msg.Channel, "Mi spiace, solo gli amministratori possono gestire i webhook")
+		return
+	}
+	var hooks Webhooks
+	hooks.Load(t.brain)
+
+	f := strings.Fields(args[1])
+	if len(f) == 0 {
+		t.bot.Reply(msg, slackbot.Ephemeral, hooks.String())
+		return
+	}
+	if len(f) != 2 {
+		t.bot.Message(msg.Channel, "Comando non valido, usa `webhook aggiungi <url>` o `webhook togli <url>`")
+		return
+	}
+	u, ok := parseURL(f[1])
+	if !ok {
+		t.bot.Message(msg.Channel, "Indirizzo non valido, usa un URL http o https")
+		return
+	}
+
+	var reply string
+	switch strings.ToLower(f[0]) {
+	case "aggiungi":
+		for _, h := range hooks {
+			if h.URL == u {
+				t.bot.Message(msg.Channel, "Il webhook c'è già")
+				return
+			}
+		}
+		secret, err := newWebhookSecret()
+		if err != nil {
+			t.bot.Message(msg.Channel, "Errore nel creare il webhook: "+err.Error())
+			return
+		}
+		hooks = append(hooks, webhook.Hook{URL: u, Secret: secret})
+		reply = fmt.Sprintf("Ok, mando gli eventi a %s. Le richieste sono firmate con il segreto `%s`, vedi l'header %s", u, secret, webhook.SignatureHeader)
+	case "togli":
+		var rest Webhooks
+		for _, h := range hooks {
+			if h.URL != u {
+				rest = append(rest, h)
+			}
+		}
+		if len(rest) == len(hooks) {
+			t.bot.Message(msg.Channel, "Webhook non trovato")
+			return
+		}
+		hooks = rest
+		reply = "Ok, non mando più gli eventi a " + u
+	default:
+		t.bot.Message(msg.Channel, "Comando non valido, usa `webhook aggiungi <url>` o `webhook togli <url>`")
+		return
+	}
+
+	if err := hooks.Save(t.brain); err != nil {
+		t.bot.Message(msg.Channel, "Errore nel salvare i webhook: "+err.Error())
+		return
+	}
+	t.bot.Reply(msg, slackbot.Direct, reply)
+}
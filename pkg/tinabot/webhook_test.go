@@ -0,0 +1,67 @@
+package tinabot
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/develersrl/lunches/pkg/brain"
+	"github.com/develersrl/lunches/pkg/events"
+	"github.com/develersrl/lunches/pkg/jobs"
+	"github.com/develersrl/lunches/pkg/tuttobene"
+	"github.com/develersrl/lunches/pkg/webhook"
+)
+
+func TestSubscribeWebhooks(t *testing.T) {
+	type received struct {
+		payload webhook.Payload
+		err     error
+	}
+	got := make(chan received, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var p webhook.Payload
+		json.Unmarshal(body, &p)
+		got <- received{p, webhook.Verify(r.Header, body, "s3cret", time.Now())}
+	}))
+	defer srv.Close()
+
+	b := brain.NewBrainMock()
+	Webhooks{{URL: srv.URL, Secret: "s3cret"}}.Save(b)
+	bus := events.New()
+	SubscribeWebhooks(bus, b, Jobs(b))
+
+	order := NewOrder()
+	var c UserChoice
+	c.Add(tuttobene.MenuRow{Content: "Risotto", Type: tuttobene.Primo})
+	order.Set(User{"mario", "U1"}, []UserChoice{c})
+	bus.Publish(events.OrderDelivered, order)
+
+	// the webhook is sent by the worker
+	w := jobs.NewWorker(Jobs(b))
+	HandleJobs(w, b, nil)
+	if ran, err := w.Next(); !ran || err != nil {
+		t.Fatalf("webhook not sent: %v", err)
+	}
+	r := <-got
+	assertEqual(t, r.err, nil, "")
+	assertEqual(t, r.payload.Event, "order_delivered", "")
+	data := r.payload.Data.(map[string]interface{})
+	assertEqual(t, data["state"], "aperto", "")
+	users := data["users"].([]interface{})
+	assertEqual(t, len(users), 1, "")
+	assertEqual(t, users[0].(map[string]interface{})["name"], "mario", "")
+	dishes := data["dishes"].([]interface{})
+	assertEqual(t, dishes[0].(map[string]interface{})["count"], 1.0, "")
+}
+
+func TestParseURL(t *testing.T) {
+	u, ok := parseURL("<https://intranet.example.com/hook|intranet.example.com/hook>")
+	assertEqual(t, u, "https://intranet.example.com/hook", "")
+	assertEqual(t, ok, true, "")
+	_, ok = parseURL("ftp://example.com")
+	assertEqual(t, ok, false, "")
+}
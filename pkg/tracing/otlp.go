@@ -0,0 +1,202 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Exporter sends the spans to an OTLP endpoint over HTTP, JSON encoded as
+// the OTLP specification allows, so that no protobuf and gRPC libraries are
+// needed. Jaeger and the OpenTelemetry collector accept it.
+type Exporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+// NewExporter returns an exporter posting the spans to endpoint, e.g.
+// http://localhost:4318/v1/traces, with headers
+func NewExporter(endpoint string, headers map[string]string) *Exporter {
+	return &Exporter{endpoint, headers, &http.Client{Timeout: 10 * time.Second}}
+}
+
+// The messages of OTLP, see
+// https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/trace/v1/trace.proto
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name    string `json:"name"`
+		Version string `json:"version,omitempty"`
+	}
+	otlpSpan struct {
+		TraceID      string         `json:"traceId"`
+		SpanID       string         `json:"spanId"`
+		ParentSpanID string         `json:"parentSpanId,omitempty"`
+		Name         string         `json:"name"`
+		Kind         int            `json:"kind"`
+		Start        string         `json:"startTimeUnixNano"`
+		End          string         `json:"endTimeUnixNano"`
+		Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+		Events       []otlpEvent    `json:"events,omitempty"`
+		Status       otlpStatus     `json:"status"`
+	}
+	otlpEvent struct {
+		Time       string         `json:"timeUnixNano"`
+		Name       string         `json:"name"`
+		Attributes []otlpKeyValue `json:"attributes,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		String *string  `json:"stringValue,omitempty"`
+		Bool   *bool    `json:"boolValue,omitempty"`
+		Int    *string  `json:"intValue,omitempty"`
+		Double *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+// The status codes of OTLP, which differ from the ones of codes
+const (
+	otlpStatusOK    = 1
+	otlpStatusError = 2
+)
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func otlpAttributes(attrs []attribute.KeyValue) []otlpKeyValue {
+	var kvs []otlpKeyValue
+	for _, a := range attrs {
+		var v otlpValue
+		switch a.Value.Type() {
+		case attribute.BOOL:
+			b := a.Value.AsBool()
+			v.Bool = &b
+		case attribute.INT64:
+			i := strconv.FormatInt(a.Value.AsInt64(), 10)
+			v.Int = &i
+		case attribute.FLOAT64:
+			f := a.Value.AsFloat64()
+			v.Double = &f
+		default:
+			s := a.Value.Emit()
+			v.String = &s
+		}
+		kvs = append(kvs, otlpKeyValue{string(a.Key), v})
+	}
+	return kvs
+}
+
+// otlpSpans returns the OTLP request carrying spans, grouped by resource and
+// by instrumentation scope
+func otlpSpans(spans []sdktrace.ReadOnlySpan) otlpRequest {
+	var req otlpRequest
+	resources := make(map[string]int)
+	scopes := make(map[string]int)
+	for _, s := range spans {
+		res := s.Resource().String()
+		ri, ok := resources[res]
+		if !ok {
+			ri = len(req.ResourceSpans)
+			resources[res] = ri
+			req.ResourceSpans = append(req.ResourceSpans, otlpResourceSpans{
+				Resource: otlpResource{otlpAttributes(s.Resource().Attributes())},
+			})
+		}
+		rs := &req.ResourceSpans[ri]
+		scope := s.InstrumentationScope()
+		key := res + "\x00" + scope.Name + "\x00" + scope.Version
+		si, ok := scopes[key]
+		if !ok {
+			si = len(rs.ScopeSpans)
+			scopes[key] = si
+			rs.ScopeSpans = append(rs.ScopeSpans, otlpScopeSpans{Scope: otlpScope{scope.Name, scope.Version}})
+		}
+
+		span := otlpSpan{
+			TraceID:    s.SpanContext().TraceID().String(),
+			SpanID:     s.SpanContext().SpanID().String(),
+			Name:       s.Name(),
+			Kind:       int(s.SpanKind()),
+			Start:      unixNano(s.StartTime()),
+			End:        unixNano(s.EndTime()),
+			Attributes: otlpAttributes(s.Attributes()),
+		}
+		if s.Parent().HasSpanID() {
+			span.ParentSpanID = s.Parent().SpanID().String()
+		}
+		for _, e := range s.Events() {
+			span.Events = append(span.Events, otlpEvent{unixNano(e.Time), e.Name, otlpAttributes(e.Attributes)})
+		}
+		switch s.Status().Code {
+		case codes.Ok:
+			span.Status.Code = otlpStatusOK
+		case codes.Error:
+			span.Status = otlpStatus{otlpStatusError, s.Status().Description}
+		}
+		rs.ScopeSpans[si].Spans = append(rs.ScopeSpans[si].Spans, span)
+	}
+	return req
+}
+
+// ExportSpans posts spans to the endpoint, see sdktrace.SpanExporter
+func (e *Exporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(otlpSpans(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp: %s exporting %d spans", resp.Status, len(spans))
+	}
+	return nil
+}
+
+// Shutdown does nothing, the requests are not kept open
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,107 @@
+// Package tracing traces the handling of the events with OpenTelemetry, from
+// the Slack event to the commands, the brain and the menu parser, exporting
+// the spans with OTLP over HTTP, e.g. to Jaeger or to a collector.
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the name of the tracer of the bot
+const tracerName = "github.com/develersrl/lunches"
+
+// defaultService is the name of the service without OTEL_SERVICE_NAME
+const defaultService = "lunches"
+
+// provider is the tracer provider installed by Setup, nil if the spans
+// aren't exported
+var provider *sdktrace.TracerProvider
+
+// Setup exports the spans to the OTLP endpoint in
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, or to the /v1/traces path of the one in
+// OTEL_EXPORTER_OTLP_ENDPOINT, with the headers in OTEL_EXPORTER_OTLP_HEADERS,
+// e.g. "authorization=Bearer xyz". The spans are dropped without them.
+func Setup() {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+			endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+		}
+	}
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	if endpoint == "" {
+		return
+	}
+
+	service := os.Getenv("OTEL_SERVICE_NAME")
+	if service == "" {
+		service = defaultService
+	}
+	exp := NewExporter(endpoint, parseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")))
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", service))),
+	)
+	otel.SetTracerProvider(provider)
+}
+
+// Shutdown exports the spans still buffered, before exiting
+func Shutdown(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+	return provider.Shutdown(ctx)
+}
+
+// parseHeaders parses the comma separated key=value pairs of
+// OTEL_EXPORTER_OTLP_HEADERS
+func parseHeaders(s string) map[string]string {
+	headers := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		if i := strings.Index(kv, "="); i > 0 {
+			headers[strings.TrimSpace(kv[:i])] = strings.TrimSpace(kv[i+1:])
+		}
+	}
+	return headers
+}
+
+// Start starts a span named name, child of the one in ctx if any
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends span, marking it failed if err is not nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// FromRequest returns the context of req with the span propagated by the
+// caller in the traceparent header, if any
+func FromRequest(req *http.Request) context.Context {
+	return otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+}
+
+// TraceID returns the ID of the trace of ctx, empty if it has none, to find
+// the trace of the lines of the logs
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
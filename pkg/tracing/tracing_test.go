@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestParseHeaders(t *testing.T) {
+	h := parseHeaders("authorization=Bearer xyz, x-team = lunches,broken")
+	if len(h) != 2 || h["authorization"] != "Bearer xyz" || h["x-team"] != "lunches" {
+		t.Fatalf("unexpected headers %v", h)
+	}
+	if h := parseHeaders(""); len(h) != 0 {
+		t.Fatalf("unexpected headers %v", h)
+	}
+}
+
+func TestExporter(t *testing.T) {
+	var got otlpRequest
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("unexpected body: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(NewExporter(srv.URL, map[string]string{"authorization": "Bearer xyz"})),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "lunches"))),
+	)
+	ctx, parent := tp.Tracer(tracerName).Start(context.Background(), "slack.event")
+	if TraceID(ctx) != parent.SpanContext().TraceID().String() {
+		t.Fatalf("unexpected trace ID %q", TraceID(ctx))
+	}
+	_, child := tp.Tracer(tracerName).Start(ctx, "menu.parse")
+	child.SetAttributes(attribute.Int("menu.dishes", 12))
+	End(child, errors.New("broken menu"))
+
+	if auth != "Bearer xyz" {
+		t.Fatalf("unexpected authorization %q", auth)
+	}
+	if len(got.ResourceSpans) != 1 || len(got.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected request %+v", got)
+	}
+	if attrs := got.ResourceSpans[0].Resource.Attributes; len(attrs) != 1 || *attrs[0].Value.String != "lunches" {
+		t.Fatalf("unexpected resource %+v", attrs)
+	}
+	spans := got.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	s := spans[0]
+	if s.Name != "menu.parse" || s.TraceID != TraceID(ctx) || s.ParentSpanID != parent.SpanContext().SpanID().String() {
+		t.Fatalf("unexpected span %+v", s)
+	}
+	if s.Status.Code != otlpStatusError || s.Status.Message != "broken menu" || len(s.Events) != 1 {
+		t.Fatalf("expected a failed span, got %+v", s)
+	}
+	if len(s.Attributes) != 1 || *s.Attributes[0].Value.Int != "12" {
+		t.Fatalf("unexpected attributes %+v", s.Attributes)
+	}
+}
+
+func TestTraceID(t *testing.T) {
+	if id := TraceID(context.Background()); id != "" {
+		t.Fatalf("unexpected trace ID %q", id)
+	}
+	req := httptest.NewRequest("POST", "/slack/events", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	Setup()
+	if id := TraceID(FromRequest(req)); id != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("unexpected trace ID %q", id)
+	}
+}
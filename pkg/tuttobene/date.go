@@ -1,7 +1,7 @@
 package tuttobene
 
 import (
-	"log"
+	"log/slog"
 	"strconv"
 	"strings"
 	"time"
@@ -101,14 +101,14 @@ func parseDate(content string) (bool, time.Time) {
 	month := findMonth(content)
 	day := findDay(content)
 
-	log.Println("DATA: ", weekDay, month, day)
+	slog.Debug("Menu date", "weekday", weekDay, "month", month, "day", day)
 	if weekDay == -1 || month == -1 || day == -1 {
 		return false, time.Time{}
 	}
 
 	loc, err := time.LoadLocation("Europe/Rome")
 	if err != nil {
-		log.Println("LoadLocation error: ", err)
+		slog.Error("LoadLocation error", "err", err)
 		return false, time.Time{}
 	}
 
@@ -120,7 +120,7 @@ func parseDate(content string) (bool, time.Time) {
 	}
 	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, loc)
 	if date.Weekday() != time.Weekday(weekDay) {
-		log.Println("Weekday mismatch!")
+		slog.Warn("Weekday mismatch in the menu date", "weekday", weekDay, "date", date.Format("2006-01-02"))
 		return false, time.Time{}
 	}
 	return true, date
@@ -2,9 +2,10 @@ package tuttobene
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/shopspring/decimal"
 )
@@ -38,7 +39,7 @@ type Menu struct {
 func (m *Menu) IsUpdated() bool {
 	loc, err := time.LoadLocation("Europe/Rome")
 	if err != nil {
-		log.Println("LoadLocation error: ", err)
+		slog.Error("LoadLocation error", "err", err)
 		return false
 	}
 
@@ -73,6 +74,32 @@ func (m *Menu) Format(withPrices bool) string {
 	return out
 }
 
+// Diagnostics returns the problems of the menu that may be due to a parser
+// regression or to an unusual file: the missing date, no first or second
+// courses, the rows of unknown type and the dishes without price
+func (m *Menu) Diagnostics() []string {
+	var d []string
+	if m.Date.IsZero() {
+		d = append(d, "missing date")
+	}
+	found := make(map[MenuRowType]bool)
+	for i, r := range m.Rows {
+		found[r.Type] = true
+		switch {
+		case r.Type == Unknonwn:
+			d = append(d, fmt.Sprintf("row %d: unknown type: %q", i, r.Content))
+		case r.Type != Empty && !r.Price.IsPositive():
+			d = append(d, fmt.Sprintf("row %d: no price: %q", i, r.Content))
+		}
+	}
+	for _, t := range []MenuRowType{Primo, Secondo} {
+		if !found[t] {
+			d = append(d, "no "+Titles[t])
+		}
+	}
+	return d
+}
+
 func (m *Menu) Add(mr *MenuRow) {
 
 	//Check and remove duplicate dishes, keep only the last one added
@@ -85,3 +112,15 @@ func (m *Menu) Add(mr *MenuRow) {
 
 	m.Rows = append(m.Rows, *mr)
 }
+
+// CanonicalName returns the dish name lowercased and stripped of punctuation
+// and extra spaces, to compare dishes across different menus.
+func CanonicalName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, s)
+	return normalizeSpaces(s)
+}
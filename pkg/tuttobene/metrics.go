@@ -0,0 +1,23 @@
+package tuttobene
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var menuParses = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "menu_parses_total",
+	Help: "Menus parsed by format, xlsx or text, and result.",
+}, []string{"format", "result"})
+
+func init() {
+	prometheus.MustRegister(menuParses)
+}
+
+// observeParse counts a menu parsed from format, failed if err is not nil
+func observeParse(format string, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	menuParses.WithLabelValues(format, result).Inc()
+}
@@ -1,8 +1,11 @@
 package tuttobene
 
 import (
+	"bytes"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"os"
 	"strings"
 	"time"
 
@@ -22,50 +25,64 @@ var Titles = map[MenuRowType]string{
 	Panino:      "i nostri panini espressi",
 }
 
-// ParseMenuBytes takes io.ReaderAt of an XLSX file and returns a populated
+// ParseMenuBytes takes the bytes of an XLSX file and returns a populated
 // menu struct.
-func ParseMenuBytes(bs []byte) (*Menu, error) {
-	f, err := xlsx.OpenBinary(bs)
-	if err != nil {
-		return nil, errors.Annotate(err, "while opening binary")
-	}
-
-	if len(f.Sheet) == 0 {
-		return nil, errors.New("no sheets in file")
-	}
-
-	// Menu is expected to be on the first sheet
-	return ParseSheet(f.Sheets[0])
+func ParseMenuBytes(bs []byte) (m *Menu, err error) {
+	defer func() { observeParse("xlsx", err) }()
+	return parseMenuReader(bytes.NewReader(bs), int64(len(bs)))
 }
 
 // ParseMenuFile takes the path to an XLSX file and returns a populated
 // menu struct.
-func ParseMenuFile(path string) (*Menu, error) {
-	f, err := xlsx.OpenFile(path)
+func ParseMenuFile(path string) (m *Menu, err error) {
+	defer func() { observeParse("xlsx", err) }()
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, errors.Annotatef(err, "while opening file %s", path)
 	}
-
-	if len(f.Sheet) == 0 {
-		return nil, errors.New(fmt.Sprintf("no sheets in file %s", path))
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, errors.Annotatef(err, "while opening file %s", path)
 	}
+	return parseMenuReader(f, info.Size())
+}
 
+// parseMenuReader reads the XLSX file of size bytes from r as a stream, so
+// that a large file is refused before loading it, see MaxFileSize and
+// MaxRows
+func parseMenuReader(r io.ReaderAt, size int64) (*Menu, error) {
 	// Menu is expected to be on the first sheet
-	return ParseSheet(f.Sheets[0])
+	rows, err := readFirstSheet(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return parseRows(rows)
 }
 
 // ParseSheet takes an xlsx.Sheet and returns a populated menu struct.
 func ParseSheet(s *xlsx.Sheet) (*Menu, error) {
-	// attempt at having a sensible number of rows required in menu
-	if len(s.Rows) < 12 {
-		return nil, errors.New(fmt.Sprintf("not enough rows: %d", len(s.Rows)))
+	rows := make([][]string, len(s.Rows))
+	for i, r := range s.Rows {
+		for _, c := range r.Cells {
+			rows[i] = append(rows[i], c.String())
+		}
 	}
+	return parseRows(rows)
+}
 
+// parseRows takes the cells of the rows of the menu sheet and returns a
+// populated menu struct.
+func parseRows(rows [][]string) (*Menu, error) {
+	// attempt at having a sensible number of rows required in menu
+	if len(rows) < 12 {
+		return nil, errors.New(fmt.Sprintf("not enough rows: %d", len(rows)))
+	}
 
 	// Check tuttobene menu format (dishes in column 0 or 1)
 	col := 0
-	if len(s.Rows[0].Cells) >= 2 {
-		sheetTitle := s.Rows[0].Cells[1].String()
+	if len(rows[0]) >= 2 {
+		sheetTitle := rows[0][1]
 		sheetTitle = strings.TrimSpace(sheetTitle)
 		sheetTitle = strings.ToLower(sheetTitle)
 		if sheetTitle == "tuttobene" {
@@ -74,16 +91,16 @@ func ParseSheet(s *xlsx.Sheet) (*Menu, error) {
 	}
 
 	var nameCol, priceCol []string
-	for _, r := range s.Rows {
-		if len(r.Cells) >= col+1 {
-			nameCol = append(nameCol, r.Cells[col].String())
+	for _, r := range rows {
+		if len(r) >= col+1 {
+			nameCol = append(nameCol, r[col])
 		}
-		if len(r.Cells) >= col+2 {
-			priceCol = append(priceCol, r.Cells[col + 1].String())
+		if len(r) >= col+2 {
+			priceCol = append(priceCol, r[col+1])
 		}
 	}
 
-	return ParseMenuCells(nameCol, priceCol)
+	return parseMenuCells(nameCol, priceCol)
 }
 
 func normalizeDish(r *MenuRow) *MenuRow {
@@ -115,7 +132,12 @@ func normalizeDish(r *MenuRow) *MenuRow {
 }
 
 // ParseMenuCells takes a slice of strings and returns a populated menu struct.
-func ParseMenuCells(nameCol []string, priceCol []string) (*Menu, error) {
+func ParseMenuCells(nameCol []string, priceCol []string) (m *Menu, err error) {
+	defer func() { observeParse("text", err) }()
+	return parseMenuCells(nameCol, priceCol)
+}
+
+func parseMenuCells(nameCol []string, priceCol []string) (*Menu, error) {
 	var (
 		currentType MenuRowType
 		menuRows    Menu
@@ -190,7 +212,7 @@ func ParseMenuCells(nameCol []string, priceCol []string) (*Menu, error) {
 	if (menuRows.Date == time.Time{}) {
 		loc, err := time.LoadLocation("Europe/Rome")
 		if err != nil {
-			log.Println("LoadLocation error: ", err)
+			slog.Error("LoadLocation error", "err", err)
 			return nil, err
 		}
 		menuRows.Date = time.Now().In(loc)
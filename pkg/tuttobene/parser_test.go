@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	_ "github.com/tealeg/xlsx"
@@ -329,3 +330,39 @@ func TestParseMenu(t *testing.T) {
 		})
 	}
 }
+
+func TestDiagnostics(t *testing.T) {
+	m, err := ParseMenuFile(filepath.Join("test-fixtures", "testmenu1.xlsx"))
+	assert.NoError(t, err)
+	assert.Empty(t, m.Diagnostics())
+
+	m = &Menu{Rows: []MenuRow{
+		{"Primi", Empty, false, decimal.Zero},
+		{"Risotto", Primo, false, decimal.Zero},
+		{"Boh", Unknonwn, false, decimal.Zero},
+	}}
+	assert.Equal(t, []string{
+		"missing date",
+		`row 1: no price: "Risotto"`,
+		`row 2: unknown type: "Boh"`,
+		"no secondi piatti",
+	}, m.Diagnostics())
+}
+
+func TestParseMetrics(t *testing.T) {
+	count := func(format, result string) float64 {
+		return testutil.ToFloat64(menuParses.WithLabelValues(format, result))
+	}
+	ok, failed, text := count("xlsx", "ok"), count("xlsx", "error"), count("text", "ok")
+
+	_, err := ParseMenuFile(filepath.Join("test-fixtures", "testmenu1.xlsx"))
+	assert.NoError(t, err)
+	_, err = ParseMenuFile(filepath.Join("test-fixtures", "missing.xlsx"))
+	assert.Error(t, err)
+	_, err = ParseMenuCells([]string{"Primi piatti", "Pasta al pomodoro"}, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, ok+1, count("xlsx", "ok"))
+	assert.Equal(t, failed+1, count("xlsx", "error"))
+	assert.Equal(t, text+1, count("text", "ok"))
+}
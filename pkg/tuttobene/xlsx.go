@@ -0,0 +1,362 @@
+package tuttobene
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// MaxFileSize is the size of the largest XLSX menu parsed, in bytes
+const MaxFileSize = 500000
+
+// MaxRows is the number of rows of the largest menu parsed, the empty rows
+// after the last one are ignored
+const MaxRows = 1000
+
+// maxPartSize is the uncompressed size of the largest XML part of the file
+// read, so that a small file can't expand to a huge one
+const maxPartSize = 20 << 20
+
+// sheetCols are the columns of the sheet kept, the ones of the dishes and of
+// the prices in both formats, see ParseSheet
+const sheetCols = 3
+
+var (
+	// ErrTooLarge is the error of a file over MaxFileSize, or expanding
+	// to too much data
+	ErrTooLarge = errors.New("file too large")
+	// ErrTooManyRows is the error of a sheet with more than MaxRows rows
+	ErrTooManyRows = errors.New("too many rows")
+)
+
+// limitedReader reads up to n bytes from r, then fails with ErrTooLarge
+type limitedReader struct {
+	r    io.Reader
+	n    int64
+	name string
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		return 0, fmt.Errorf("%w: %s is over %d bytes uncompressed", ErrTooLarge, l.name, maxPartSize)
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// xlsxReader reads the first sheet of a XLSX file as a stream, keeping in
+// memory only the shared strings and the columns needed
+type xlsxReader struct {
+	parts map[string]*zip.File
+}
+
+// newXLSXReader opens the XLSX file of size bytes read from r
+func newXLSXReader(r io.ReaderAt, size int64) (*xlsxReader, error) {
+	if size > MaxFileSize {
+		return nil, fmt.Errorf("%w: %d bytes, at most %d", ErrTooLarge, size, MaxFileSize)
+	}
+	z, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.Annotate(err, "while opening the zip")
+	}
+	x := &xlsxReader{parts: make(map[string]*zip.File)}
+	for _, f := range z.File {
+		x.parts[f.Name] = f
+	}
+	return x, nil
+}
+
+// decoder returns the XML decoder of the part name, the caller must close it
+func (x *xlsxReader) decoder(name string) (*xml.Decoder, io.Closer, error) {
+	f, ok := x.parts[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("missing %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, nil, errors.Annotatef(err, "while opening %s", name)
+	}
+	return xml.NewDecoder(&limitedReader{rc, maxPartSize, name}), rc, nil
+}
+
+// relationships returns the targets of the relationships of the part name,
+// by ID and by type, as names of parts
+func (x *xlsxReader) relationships(name string) (map[string]string, error) {
+	dir, base := path.Split(name)
+	d, c, err := x.decoder(dir + "_rels/" + base + ".rels")
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	var rels struct {
+		Relationship []struct {
+			ID     string `xml:"Id,attr"`
+			Type   string `xml:"Type,attr"`
+			Target string `xml:"Target,attr"`
+		}
+	}
+	if err := d.Decode(&rels); err != nil {
+		return nil, errors.Annotatef(err, "while reading the relationships of %s", name)
+	}
+	targets := make(map[string]string)
+	for _, r := range rels.Relationship {
+		target := path.Join(dir, r.Target)
+		if strings.HasPrefix(r.Target, "/") {
+			target = strings.TrimPrefix(r.Target, "/")
+		}
+		targets[r.ID] = target
+		targets[path.Base(r.Type)] = target
+	}
+	return targets, nil
+}
+
+// firstSheet returns the name of the part of the first sheet of the workbook
+func (x *xlsxReader) firstSheet(rels map[string]string) (string, error) {
+	d, c, err := x.decoder("xl/workbook.xml")
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+
+	var wb struct {
+		Sheets struct {
+			Sheet []struct {
+				ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+			} `xml:"sheet"`
+		} `xml:"sheets"`
+	}
+	if err := d.Decode(&wb); err != nil {
+		return "", errors.Annotate(err, "while reading the workbook")
+	}
+	if len(wb.Sheets.Sheet) == 0 {
+		return "", errors.New("no sheets in file")
+	}
+	sheet, ok := rels[wb.Sheets.Sheet[0].ID]
+	if !ok {
+		return "", fmt.Errorf("missing sheet %s", wb.Sheets.Sheet[0].ID)
+	}
+	return sheet, nil
+}
+
+// text returns the text of the element just started, up to its end, joining
+// the text of the elements want inside it but not inside skip
+func text(d *xml.Decoder, want, skip string) (string, error) {
+	var b strings.Builder
+	depth, skipped, inText := 1, 0, false
+	for depth > 0 {
+		tok, err := d.Token()
+		if err != nil {
+			return "", err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if tok.Name.Local == skip || skipped > 0 {
+				skipped++
+			}
+			inText = tok.Name.Local == want && skipped == 0
+		case xml.EndElement:
+			depth--
+			if skipped > 0 {
+				skipped--
+			}
+			inText = false
+		case xml.CharData:
+			if inText {
+				b.Write(tok)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// sharedStrings returns the strings shared by the cells of the sheets
+func (x *xlsxReader) sharedStrings(name string) ([]string, error) {
+	if _, ok := x.parts[name]; !ok {
+		return nil, nil
+	}
+	d, c, err := x.decoder(name)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	var strs []string
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return strs, nil
+		}
+		if err != nil {
+			return nil, errors.Annotate(err, "while reading the shared strings")
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == "si" {
+			// the phonetic runs repeat the text for the asian languages
+			s, err := text(d, "t", "rPh")
+			if err != nil {
+				return nil, errors.Annotate(err, "while reading the shared strings")
+			}
+			strs = append(strs, s)
+		}
+	}
+}
+
+// attr returns the value of the attribute name of se
+func attr(se xml.StartElement, name string) string {
+	for _, a := range se.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// cellColumn returns the index of the column of the cell ref, e.g. 1 for B3,
+// -1 if it's not valid
+func cellColumn(ref string) int {
+	col := 0
+	for i, c := range ref {
+		if c < 'A' || c > 'Z' {
+			if i == 0 {
+				return -1
+			}
+			break
+		}
+		col = col*26 + int(c-'A') + 1
+	}
+	return col - 1
+}
+
+// cellValue returns the text of the cell of type typ with the value v
+func cellValue(typ, v string, strs []string) (string, error) {
+	switch typ {
+	case "s":
+		i, err := strconv.Atoi(v)
+		if err != nil || i < 0 || i >= len(strs) {
+			return "", fmt.Errorf("invalid shared string %q", v)
+		}
+		return strs[i], nil
+	case "", "n":
+		// the prices are numbers, in their shortest form
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return strconv.FormatFloat(f, 'f', -1, 64), nil
+		}
+	}
+	return v, nil
+}
+
+// readRows returns the first sheetCols cells of each row of the sheet, the
+// missing rows and cells are empty
+func (x *xlsxReader) readRows(name string, strs []string) ([][]string, error) {
+	d, c, err := x.decoder(name)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	var (
+		rows  [][]string
+		row   []string
+		rowN  int
+		empty bool
+	)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, errors.Annotate(err, "while reading the sheet")
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			switch tok.Name.Local {
+			case "row":
+				rowN++
+				if r, err := strconv.Atoi(attr(tok, "r")); err == nil && r > 0 {
+					rowN = r
+				}
+				row, empty = nil, true
+				// the cells of the spans are there even if missing
+				if from, to, ok := strings.Cut(attr(tok, "spans"), ":"); ok && !strings.Contains(to, " ") {
+					if n, err := strconv.Atoi(to); err == nil && from != "" {
+						row = make([]string, min(n, sheetCols))
+					}
+				}
+			case "c":
+				col := cellColumn(attr(tok, "r"))
+				if col < 0 {
+					col = len(row)
+				}
+				typ := attr(tok, "t")
+				var v string
+				if typ == "inlineStr" {
+					v, err = text(d, "t", "rPh")
+				} else if v, err = text(d, "v", ""); err == nil {
+					v, err = cellValue(typ, v, strs)
+				}
+				if err != nil {
+					return nil, errors.Annotatef(err, "while reading row %d", rowN)
+				}
+				empty = empty && v == ""
+				if col < sheetCols {
+					for len(row) <= col {
+						row = append(row, "")
+					}
+					row[col] = v
+				}
+			}
+		case xml.EndElement:
+			if tok.Name.Local != "row" {
+				continue
+			}
+			if rowN > MaxRows {
+				if empty {
+					continue
+				}
+				return nil, fmt.Errorf("%w: row %d, at most %d", ErrTooManyRows, rowN, MaxRows)
+			}
+			for len(rows) < rowN-1 {
+				rows = append(rows, nil)
+			}
+			rows = append(rows, row)
+		}
+	}
+}
+
+// readFirstSheet reads the cells of the first sheet of the XLSX file of size
+// bytes read from r, see readRows
+func readFirstSheet(r io.ReaderAt, size int64) ([][]string, error) {
+	x, err := newXLSXReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	rels, err := x.relationships("xl/workbook.xml")
+	if err != nil {
+		return nil, err
+	}
+	sheet, err := x.firstSheet(rels)
+	if err != nil {
+		return nil, err
+	}
+	shared := rels["sharedStrings"]
+	if shared == "" {
+		shared = "xl/sharedStrings.xml"
+	}
+	strs, err := x.sharedStrings(shared)
+	if err != nil {
+		return nil, err
+	}
+	return x.readRows(sheet, strs)
+}
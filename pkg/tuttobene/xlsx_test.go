@@ -0,0 +1,88 @@
+package tuttobene
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeXLSX returns a XLSX file with a sheet of sheetData and the shared
+// strings strs
+func writeXLSX(t *testing.T, sheetData string, strs ...string) []byte {
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	add := func(name, content string) {
+		w, err := z.Create(name)
+		assert.NoError(t, err)
+		io.WriteString(w, content)
+	}
+	add("xl/workbook.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`+
+		`<sheets><sheet name="Menu" sheetId="1" r:id="rId2"/><sheet name="Altro" sheetId="2" r:id="rId3"/></sheets></workbook>`)
+	add("xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`+
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/sharedStrings" Target="sharedStrings.xml"/>`+
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="/xl/worksheets/menu.xml"/>`+
+		`<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/other.xml"/>`+
+		`</Relationships>`)
+	var sst strings.Builder
+	for _, s := range strs {
+		sst.WriteString("<si>" + s + "</si>")
+	}
+	add("xl/sharedStrings.xml", `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`+sst.String()+`</sst>`)
+	add("xl/worksheets/menu.xml", `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`+sheetData+`</sheetData></worksheet>`)
+	add("xl/worksheets/other.xml", `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData/></worksheet>`)
+	assert.NoError(t, z.Close())
+	return buf.Bytes()
+}
+
+func TestReadFirstSheet(t *testing.T) {
+	data := writeXLSX(t, `<row r="1" spans="1:4"><c r="B1" t="s"><v>0</v></c></row>`+
+		`<row r="3"><c r="A3" t="inlineStr"><is><t>Primi</t></is></c><c r="B3" t="s"><v>1</v></c><c r="C3"><v>8.9000000000000004</v></c><c r="D3" t="s"><v>0</v></c></row>`+
+		`<row><c t="str"><f>A3</f><v>Primi</v></c><c r="C4" s="2"/></row>`,
+		"<t>TUTTOBENE</t>", `<r><t>Pasta </t></r><r><t xml:space="preserve">al pomodoro</t></r><rPh><t>x</t></rPh>`)
+
+	rows, err := readFirstSheet(bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"", "TUTTOBENE", ""},
+		nil,
+		{"Primi", "Pasta al pomodoro", "8.9"},
+		{"Primi", "", ""},
+	}, rows)
+}
+
+func TestParseMenuLimits(t *testing.T) {
+	_, err := ParseMenuBytes(make([]byte, MaxFileSize+1))
+	assert.True(t, errors.Is(err, ErrTooLarge), "%v", err)
+
+	var rows strings.Builder
+	for i := 1; i <= MaxRows; i++ {
+		fmt.Fprintf(&rows, `<row r="%d"><c r="A%d" t="inlineStr"><is><t>Riga</t></is></c></row>`, i, i)
+	}
+	// the empty rows over the limit are ignored
+	data := writeXLSX(t, rows.String()+fmt.Sprintf(`<row r="%d"><c r="A%d" s="1"/></row>`, MaxRows+1, MaxRows+1))
+	_, err = ParseMenuBytes(data)
+	assert.False(t, errors.Is(err, ErrTooManyRows), "%v", err)
+
+	data = writeXLSX(t, rows.String()+`<row r="1048576"><c r="A1048576" t="inlineStr"><is><t>Riga</t></is></c></row>`)
+	_, err = ParseMenuBytes(data)
+	assert.True(t, errors.Is(err, ErrTooManyRows), "%v", err)
+	assert.Contains(t, err.Error(), "row 1048576")
+
+	// a part can't expand over the limit
+	r := &limitedReader{strings.NewReader("12345"), 3, "xl/sharedStrings.xml"}
+	_, err = io.ReadAll(r)
+	assert.True(t, errors.Is(err, ErrTooLarge), "%v", err)
+
+	path := filepath.Join(t.TempDir(), "menu.xlsx")
+	assert.NoError(t, os.WriteFile(path, make([]byte, MaxFileSize+1), 0o600))
+	_, err = ParseMenuFile(path)
+	assert.True(t, errors.Is(err, ErrTooLarge), "%v", err)
+}
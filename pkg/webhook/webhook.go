@@ -0,0 +1,106 @@
+// Package webhook sends the events of the bot as signed JSON to the URLs of
+// other tools. The signature is computed like the one of the Slack requests:
+// the hex HMAC-SHA256, with the secret of the hook, of
+// "v0:<timestamp>:<body>", sent as "v0=<hmac>" in SignatureHeader with the
+// Unix timestamp in TimestampHeader.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// The headers of the signature
+const (
+	SignatureHeader = "X-Lunches-Signature"
+	TimestampHeader = "X-Lunches-Request-Timestamp"
+)
+
+// maxAge is how old a request can be to be accepted by Verify
+const maxAge = 5 * time.Minute
+
+// ErrSignature is returned by Verify for the requests not signed with the
+// secret or too old
+var ErrSignature = errors.New("webhook: invalid signature")
+
+// Hook is a URL receiving the events, signed with Secret
+type Hook struct {
+	URL    string
+	Secret string
+}
+
+// Payload is the body of the requests
+type Payload struct {
+	Event string      `json:"event"`
+	Time  time.Time   `json:"time"`
+	Data  interface{} `json:"data"`
+}
+
+// Sign returns the signature of body sent at timestamp
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%d:", timestamp)
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a request received at now, for the tools
+// receiving the hooks
+func Verify(header http.Header, body []byte, secret string, now time.Time) error {
+	ts, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return ErrSignature
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > maxAge || d < -maxAge {
+		return ErrSignature
+	}
+	if !hmac.Equal([]byte(header.Get(SignatureHeader)), []byte(Sign(secret, ts, body))) {
+		return ErrSignature
+	}
+	return nil
+}
+
+// Client sends the payloads to the hooks
+type Client struct {
+	HTTP *http.Client
+}
+
+// New returns a client giving up on the hooks not answering in a few
+// seconds, so that they can't hold the bot
+func New() *Client {
+	return &Client{HTTP: &http.Client{Timeout: 5 * time.Second}}
+}
+
+// Send posts the payload to the hook, an error is returned if it doesn't
+// answer with a 2xx status
+func (c *Client) Send(h Hook, p Payload) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, strconv.FormatInt(ts, 10))
+	req.Header.Set(SignatureHeader, Sign(h.Secret, ts, body))
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook %s: %s", h.URL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSend(t *testing.T) {
+	var verr error
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verr = Verify(r.Header, body, "s3cret", time.Now())
+	}))
+	defer srv.Close()
+
+	c := New()
+	if err := c.Send(Hook{srv.URL, "s3cret"}, Payload{"order_updated", time.Now(), map[string]int{"Risotto": 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if verr != nil {
+		t.Errorf("valid signature refused: %v", verr)
+	}
+	if err := c.Send(Hook{srv.URL, "other"}, Payload{Event: "order_updated"}); err != nil {
+		t.Fatal(err)
+	}
+	if verr != ErrSignature {
+		t.Errorf("signature with another secret accepted: %v", verr)
+	}
+}
+
+func TestVerifyOld(t *testing.T) {
+	body := []byte(`{}`)
+	ts := time.Now().Add(-time.Hour).Unix()
+	h := http.Header{}
+	h.Set(TimestampHeader, strconv.FormatInt(ts, 10))
+	h.Set(SignatureHeader, Sign("s", ts, body))
+	if Verify(h, body, "s", time.Now()) != ErrSignature {
+		t.Error("old request accepted")
+	}
+}
@@ -0,0 +1,9 @@
+//go:build tools
+
+// The tools needed to build the bot, pinned in go.mod: the Dockerfile runs
+// them with go run.
+package main
+
+import (
+	_ "github.com/gobuffalo/packr/packr"
+)